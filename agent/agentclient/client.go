@@ -14,11 +14,13 @@
 package agentclient
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/url"
+	"time"
 
 	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/utils/httputil"
@@ -37,18 +39,42 @@ type Client interface {
 
 // HTTPClient provides a wrapper for HTTP operations on an agent.
 type HTTPClient struct {
-	addr string
+	addr    string
+	tls     *tls.Config
+	timeout time.Duration
+}
+
+// Option allows setting optional HTTPClient parameters.
+type Option func(*HTTPClient)
+
+// WithTLS configures an HTTPClient with tls configuration, which is required
+// by agents with mutual TLS enabled.
+func WithTLS(tls *tls.Config) Option {
+	return func(c *HTTPClient) { c.tls = tls }
+}
+
+// WithTimeout configures an HTTPClient with a request timeout, which bounds
+// how long Download may wait for the agent to fetch a blob.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *HTTPClient) { c.timeout = timeout }
 }
 
 // New creates a new client for an agent at addr.
-func New(addr string) *HTTPClient {
-	return &HTTPClient{addr}
+func New(addr string, opts ...Option) *HTTPClient {
+	c := &HTTPClient{addr: addr, timeout: 60 * time.Second}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // GetTag resolves tag into a digest. Returns ErrTagNotFound if the tag does
 // not exist.
 func (c *HTTPClient) GetTag(tag string) (core.Digest, error) {
-	resp, err := httputil.Get(fmt.Sprintf("http://%s/tags/%s", c.addr, url.PathEscape(tag)))
+	resp, err := httputil.Get(
+		fmt.Sprintf("http://%s/tags/%s", c.addr, url.PathEscape(tag)),
+		httputil.SendTLS(c.tls),
+		httputil.SendTimeout(c.timeout))
 	if err != nil {
 		if httputil.IsNotFound(err) {
 			return core.Digest{}, ErrTagNotFound
@@ -73,7 +99,9 @@ func (c *HTTPClient) Download(namespace string, d core.Digest) (io.ReadCloser, e
 	resp, err := httputil.Get(
 		fmt.Sprintf(
 			"http://%s/namespace/%s/blobs/%s",
-			c.addr, url.PathEscape(namespace), d))
+			c.addr, url.PathEscape(namespace), d),
+		httputil.SendTLS(c.tls),
+		httputil.SendTimeout(c.timeout))
 	if err != nil {
 		return nil, err
 	}
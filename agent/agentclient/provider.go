@@ -0,0 +1,34 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentclient
+
+// Provider defines an interface for creating Client scoped to an agent addr.
+type Provider interface {
+	Provide(addr string) Client
+}
+
+// HTTPProvider provides HTTPClients.
+type HTTPProvider struct {
+	opts []Option
+}
+
+// NewProvider returns a new HTTPProvider.
+func NewProvider(opts ...Option) HTTPProvider {
+	return HTTPProvider{opts}
+}
+
+// Provide implements Provider's Provide.
+func (p HTTPProvider) Provide(addr string) Client {
+	return New(addr, p.opts...)
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentserver
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/store/metadata"
+)
+
+// Pin describes an image tag whose blobs are pinned to the cache.
+type Pin struct {
+	Tag string `json:"tag"`
+
+	// Digest is the manifest digest the tag resolved to when it was pinned.
+	// It is only set in response to pin requests.
+	Digest *core.Digest `json:"digest,omitempty"`
+
+	Blobs []core.Digest `json:"blobs"`
+}
+
+// pinStore pins blobs to the cache on behalf of image tags. The tags pinning
+// a blob are recorded in its Pins metadata, and blobs with at least one pin
+// are marked as persisted, which excludes them from cleanup and quota
+// eviction. Since both are stored alongside the blob, pins survive restarts.
+type pinStore struct {
+	mu   sync.Mutex
+	cads *store.CADownloadStore
+}
+
+func newPinStore(cads *store.CADownloadStore) *pinStore {
+	return &pinStore{cads: cads}
+}
+
+// pin pins blobs, which must be in the cache, on behalf of tag. Blobs which
+// tag pinned before but no longer references are released.
+func (p *pinStore) pin(tag string, blobs []core.Digest) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	keep := make(map[string]bool)
+	for _, d := range blobs {
+		keep[d.Hex()] = true
+	}
+	if _, err := p.release(tag, keep); err != nil {
+		return err
+	}
+	for _, d := range blobs {
+		if err := p.update(d.Hex(), func(pins *metadata.Pins) bool {
+			return pins.Add(tag)
+		}); err != nil {
+			return fmt.Errorf("pin %s: %s", d, err)
+		}
+	}
+	return nil
+}
+
+// unpin releases all blobs pinned on behalf of tag. Returns false if tag did
+// not pin any blobs.
+func (p *pinStore) unpin(tag string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.release(tag, nil)
+}
+
+// list returns all pinned tags, sorted by tag.
+func (p *pinStore) list() ([]Pin, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	names, err := p.cads.Cache().ListNames()
+	if err != nil {
+		return nil, fmt.Errorf("list cache: %s", err)
+	}
+	sort.Strings(names)
+	blobs := make(map[string][]core.Digest)
+	for _, name := range names {
+		var pins metadata.Pins
+		if err := p.cads.Cache().GetMetadata(name, &pins); err != nil {
+			continue
+		}
+		d, err := core.NewSHA256DigestFromHex(name)
+		if err != nil {
+			continue
+		}
+		for _, tag := range pins.Tags {
+			blobs[tag] = append(blobs[tag], d)
+		}
+	}
+	result := []Pin{}
+	for tag, ds := range blobs {
+		result = append(result, Pin{Tag: tag, Blobs: ds})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Tag < result[j].Tag })
+	return result, nil
+}
+
+// release removes tag from the pins of all cached blobs not in keep.
+func (p *pinStore) release(tag string, keep map[string]bool) (bool, error) {
+	names, err := p.cads.Cache().ListNames()
+	if err != nil {
+		return false, fmt.Errorf("list cache: %s", err)
+	}
+	var found bool
+	for _, name := range names {
+		if keep[name] {
+			continue
+		}
+		if err := p.update(name, func(pins *metadata.Pins) bool {
+			if pins.Remove(tag) {
+				found = true
+				return true
+			}
+			return false
+		}); err != nil {
+			return false, fmt.Errorf("unpin %s: %s", name, err)
+		}
+	}
+	return found, nil
+}
+
+// update applies f to the pins of name, and persists the result if f reports
+// a change.
+func (p *pinStore) update(name string, f func(*metadata.Pins) bool) error {
+	var pins metadata.Pins
+	if err := p.cads.Cache().GetMetadata(name, &pins); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("get pins: %s", err)
+	}
+	if !f(&pins) {
+		return nil
+	}
+	if _, err := p.cads.Cache().SetMetadata(name, &pins); err != nil {
+		return fmt.Errorf("set pins: %s", err)
+	}
+	if _, err := p.cads.Cache().SetMetadata(name, metadata.NewPersist(len(pins.Tags) > 0)); err != nil {
+		return fmt.Errorf("set persist: %s", err)
+	}
+	return nil
+}
@@ -16,10 +16,10 @@ package agentserver
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"io"
 	"net/http"
 	_ "net/http/pprof" // Registers /debug/pprof endpoints in http.DefaultServeMux.
+	"net/url"
 	"os"
 	"strings"
 
@@ -29,15 +29,22 @@ import (
 	"github.com/uber/kraken/lib/middleware"
 	"github.com/uber/kraken/lib/store"
 	"github.com/uber/kraken/lib/torrent/scheduler"
+	"github.com/uber/kraken/utils/dockerutil"
 	"github.com/uber/kraken/utils/handler"
 	"github.com/uber/kraken/utils/httputil"
 
+	"github.com/c2h5oh/datasize"
+	"github.com/docker/distribution"
 	"github.com/go-chi/chi"
 	"github.com/uber-go/tally"
 )
 
 // Config defines Server configuration.
-type Config struct{}
+type Config struct {
+	// DebugToken enables the /x/torrents debug, /x/cache/evict and PATCH
+	// /x/bandwidth endpoints, which require it as bearer token.
+	DebugToken string `yaml:"debug_token"`
+}
 
 // Server defines the agent HTTP server.
 type Server struct {
@@ -47,6 +54,7 @@ type Server struct {
 	sched            scheduler.ReloadableScheduler
 	tags             tagclient.Client
 	containerRuntime containerruntime.Factory
+	pins             *pinStore
 }
 
 // New creates a new Server.
@@ -62,13 +70,14 @@ func New(
 		"module": "agentserver",
 	})
 
-	return &Server{config, stats, cads, sched, tags, containerRuntime}
+	return &Server{config, stats, cads, sched, tags, containerRuntime, newPinStore(cads)}
 }
 
 // Handler returns the HTTP handler.
 func (s *Server) Handler() http.Handler {
 	r := chi.NewRouter()
 
+	r.Use(middleware.Tracing())
 	r.Use(middleware.StatusCounter(s.stats))
 	r.Use(middleware.LatencyTimer(s.stats))
 
@@ -83,11 +92,29 @@ func (s *Server) Handler() http.Handler {
 	// Preheat/preload endpoints.
 	r.Get("/preload/tags/{tag}", handler.Wrap(s.preloadTagHandler))
 
+	// Pins images to the cache, such that they are never evicted.
+	r.Get("/pin", handler.Wrap(s.listPinsHandler))
+	r.Post("/pin/{tag}", handler.Wrap(s.pinHandler))
+	r.Delete("/pin/{tag}", handler.Wrap(s.unpinHandler))
+
 	// Dangerous endpoint for running experiments.
 	r.Patch("/x/config/scheduler", handler.Wrap(s.patchSchedulerConfigHandler))
 
 	r.Get("/x/blacklist", handler.Wrap(s.getBlacklistHandler))
 
+	r.Get("/x/bandwidth", handler.Wrap(s.getBandwidthHandler))
+
+	r.Get("/x/cache", handler.Wrap(s.getCacheUsageHandler))
+
+	if s.config.DebugToken != "" {
+		r.Mount("/x/torrents",
+			middleware.RequireToken(s.config.DebugToken)(scheduler.DebugHandler(s.sched)))
+		r.With(middleware.RequireToken(s.config.DebugToken)).
+			Post("/x/cache/evict", handler.Wrap(s.evictCacheHandler))
+		r.With(middleware.RequireToken(s.config.DebugToken)).
+			Patch("/x/bandwidth", handler.Wrap(s.patchBandwidthHandler))
+	}
+
 	// Serves /debug/pprof endpoints.
 	r.Mount("/", http.DefaultServeMux)
 
@@ -111,7 +138,9 @@ func (s *Server) getTagHandler(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
-// downloadBlobHandler downloads a blob through p2p.
+// downloadBlobHandler downloads a blob through p2p. The optional priority
+// query parameter (low, normal or high) sets the priority of the download.
+// Range requests are served once the full blob is downloaded.
 func (s *Server) downloadBlobHandler(w http.ResponseWriter, r *http.Request) error {
 	namespace, err := httputil.ParseParam(r, "namespace")
 	if err != nil {
@@ -121,10 +150,14 @@ func (s *Server) downloadBlobHandler(w http.ResponseWriter, r *http.Request) err
 	if err != nil {
 		return err
 	}
+	priority, err := scheduler.ParsePriority(r.URL.Query().Get("priority"))
+	if err != nil {
+		return handler.Errorf("parse priority: %s", err).Status(http.StatusBadRequest)
+	}
 	f, err := s.cads.Cache().GetFileReader(d.Hex())
 	if err != nil {
 		if os.IsNotExist(err) || s.cads.InDownloadError(err) {
-			if err := s.sched.Download(namespace, d); err != nil {
+			if err := s.sched.DownloadWithPriority(namespace, d, priority); err != nil {
 				if err == scheduler.ErrTorrentNotFound {
 					return handler.ErrorStatus(http.StatusNotFound)
 				}
@@ -138,9 +171,9 @@ func (s *Server) downloadBlobHandler(w http.ResponseWriter, r *http.Request) err
 			return handler.Errorf("store: %s", err)
 		}
 	}
-	if _, err := io.Copy(w, f); err != nil {
-		return fmt.Errorf("copy file: %s", err)
-	}
+	defer f.Close()
+
+	store.ServeFile(w, r, d, f)
 	return nil
 }
 
@@ -186,6 +219,110 @@ func (s *Server) preloadTagHandler(w http.ResponseWriter, r *http.Request) error
 	return nil
 }
 
+// pinHandler downloads the manifest of a tag and every blob it references, and
+// pins them to the cache.
+func (s *Server) pinHandler(w http.ResponseWriter, r *http.Request) error {
+	tag, err := httputil.ParseParam(r, "tag")
+	if err != nil {
+		return err
+	}
+	parts := strings.Split(tag, ":")
+	if len(parts) != 2 {
+		return handler.Errorf("failed to parse docker image tag").Status(http.StatusBadRequest)
+	}
+	repo := parts[0]
+	d, err := s.tags.Get(tag)
+	if err != nil {
+		if err == tagclient.ErrTagNotFound {
+			return handler.ErrorStatus(http.StatusNotFound)
+		}
+		return handler.Errorf("get tag: %s", err)
+	}
+	manifest, err := s.fetchManifest(repo, d)
+	if err != nil {
+		return err
+	}
+	refs, err := dockerutil.GetAllManifestReferences(manifest, func(d core.Digest) (distribution.Manifest, error) {
+		return s.fetchManifest(repo, d)
+	})
+	if err != nil {
+		return handler.Errorf("resolve manifest: %s", err)
+	}
+	for _, ref := range refs {
+		if err := s.ensureCached(repo, ref); err != nil {
+			return err
+		}
+	}
+	blobs := append([]core.Digest{d}, refs...)
+	if err := s.pins.pin(tag, blobs); err != nil {
+		return handler.Errorf("pin: %s", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(Pin{Tag: tag, Digest: &d, Blobs: blobs}); err != nil {
+		return handler.Errorf("json encode: %s", err)
+	}
+	return nil
+}
+
+// unpinHandler releases the blobs pinned by a tag, such that they may be
+// evicted again unless pinned by other tags.
+func (s *Server) unpinHandler(w http.ResponseWriter, r *http.Request) error {
+	tag, err := httputil.ParseParam(r, "tag")
+	if err != nil {
+		return err
+	}
+	found, err := s.pins.unpin(tag)
+	if err != nil {
+		return handler.Errorf("unpin: %s", err)
+	}
+	if !found {
+		return handler.ErrorStatus(http.StatusNotFound)
+	}
+	return nil
+}
+
+func (s *Server) listPinsHandler(w http.ResponseWriter, r *http.Request) error {
+	pins, err := s.pins.list()
+	if err != nil {
+		return handler.Errorf("list pins: %s", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(pins); err != nil {
+		return handler.Errorf("json encode: %s", err)
+	}
+	return nil
+}
+
+// ensureCached downloads d through p2p unless it is already in the cache.
+func (s *Server) ensureCached(namespace string, d core.Digest) error {
+	if _, err := s.cads.Cache().GetFileStat(d.Hex()); err == nil {
+		return nil
+	}
+	if err := s.sched.Download(namespace, d); err != nil {
+		if err == scheduler.ErrTorrentNotFound {
+			return handler.Errorf("blob %s not found", d).Status(http.StatusNotFound)
+		}
+		return handler.Errorf("download %s: %s", d, err)
+	}
+	return nil
+}
+
+func (s *Server) fetchManifest(namespace string, d core.Digest) (distribution.Manifest, error) {
+	if err := s.ensureCached(namespace, d); err != nil {
+		return nil, err
+	}
+	f, err := s.cads.Cache().GetFileReader(d.Hex())
+	if err != nil {
+		return nil, handler.Errorf("store: %s", err)
+	}
+	defer f.Close()
+	manifest, _, err := dockerutil.ParseManifest(f)
+	if err != nil {
+		return nil, handler.Errorf("parse manifest %s: %s", d, err)
+	}
+	return manifest, nil
+}
+
 func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) error {
 	if err := s.sched.Probe(); err != nil {
 		return handler.Errorf("probe torrent client: %s", err)
@@ -217,6 +354,89 @@ func (s *Server) getBlacklistHandler(w http.ResponseWriter, r *http.Request) err
 	return nil
 }
 
+func (s *Server) getBandwidthHandler(w http.ResponseWriter, r *http.Request) error {
+	limits := s.sched.BandwidthLimits()
+	if err := json.NewEncoder(w).Encode(&limits); err != nil {
+		return handler.Errorf("json encode: %s", err)
+	}
+	return nil
+}
+
+// patchBandwidthHandler adjusts the bandwidth limits of the torrent scheduler
+// without restarting it. Fields omitted from the request body keep their
+// current values.
+func (s *Server) patchBandwidthHandler(w http.ResponseWriter, r *http.Request) error {
+	defer r.Body.Close()
+	limits := s.sched.BandwidthLimits()
+	if err := json.NewDecoder(r.Body).Decode(&limits); err != nil {
+		return handler.Errorf("json decode: %s", err).Status(http.StatusBadRequest)
+	}
+	if err := s.sched.SetBandwidthLimits(limits); err != nil {
+		return handler.Errorf("set bandwidth limits: %s", err).Status(http.StatusBadRequest)
+	}
+	return nil
+}
+
+// getCacheUsageHandler returns the disk usage of the cache, per namespace.
+func (s *Server) getCacheUsageHandler(w http.ResponseWriter, r *http.Request) error {
+	usage, err := s.cads.Quota().Usage()
+	if err != nil {
+		return handler.Errorf("cache usage: %s", err)
+	}
+	if err := json.NewEncoder(w).Encode(usage); err != nil {
+		return handler.Errorf("json encode: %s", err)
+	}
+	return nil
+}
+
+// evictCacheHandler forces eviction of cache files. Without query arguments,
+// files are evicted until the configured quotas are met. Otherwise, files are
+// evicted until the usage of the namespace argument, or of the entire cache if
+// omitted, is at most the required target argument (e.g. 10GB).
+func (s *Server) evictCacheHandler(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+	var result *store.EvictionResult
+	var err error
+	if len(q) == 0 {
+		result, err = s.cads.Quota().Enforce()
+	} else {
+		target, perr := parseEvictTarget(q)
+		if perr != nil {
+			return perr
+		}
+		result, err = s.cads.Quota().Evict(q.Get("namespace"), target)
+	}
+	if err != nil {
+		return handler.Errorf("evict: %s", err)
+	}
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		return handler.Errorf("json encode: %s", err)
+	}
+	return nil
+}
+
+// parseEvictTarget validates the evict query arguments and returns the target
+// in bytes.
+func parseEvictTarget(q url.Values) (int64, error) {
+	for k := range q {
+		if k != "namespace" && k != "target" {
+			return 0, handler.Errorf("unknown argument %q", k).Status(http.StatusBadRequest)
+		}
+	}
+	if ns, ok := q["namespace"]; ok && ns[0] == "" {
+		return 0, handler.Errorf("empty namespace").Status(http.StatusBadRequest)
+	}
+	t := q.Get("target")
+	if t == "" {
+		return 0, handler.Errorf("target required").Status(http.StatusBadRequest)
+	}
+	var target datasize.ByteSize
+	if err := target.UnmarshalText([]byte(t)); err != nil {
+		return 0, handler.Errorf("parse target: %s", err).Status(http.StatusBadRequest)
+	}
+	return int64(target.Bytes()), nil
+}
+
 func parseDigest(r *http.Request) (core.Digest, error) {
 	raw, err := httputil.ParseParam(r, "digest")
 	if err != nil {
@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"testing"
 	"time"
@@ -28,13 +29,17 @@ import (
 	"github.com/uber/kraken/build-index/tagclient"
 	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/store/metadata"
 	"github.com/uber/kraken/lib/torrent/scheduler"
+	"github.com/uber/kraken/lib/torrent/scheduler/conn"
 	"github.com/uber/kraken/lib/torrent/scheduler/connstate"
 	mocktagclient "github.com/uber/kraken/mocks/build-index/tagclient"
 	mockcontainerruntime "github.com/uber/kraken/mocks/lib/containerruntime"
 	mockcontainerd "github.com/uber/kraken/mocks/lib/containerruntime/containerd"
 	mockdockerdaemon "github.com/uber/kraken/mocks/lib/containerruntime/dockerdaemon"
 	mockscheduler "github.com/uber/kraken/mocks/lib/torrent/scheduler"
+	"github.com/uber/kraken/utils/bandwidth"
+	"github.com/uber/kraken/utils/dockerutil"
 	"github.com/uber/kraken/utils/httputil"
 	"github.com/uber/kraken/utils/testutil"
 
@@ -75,7 +80,11 @@ func newServerMocks(t *testing.T) (*serverMocks, func()) {
 }
 
 func (m *serverMocks) startServer() string {
-	s := New(Config{}, tally.NoopScope, m.cads, m.sched, m.tags, m.containerRuntime)
+	return m.startServerWithConfig(Config{})
+}
+
+func (m *serverMocks) startServerWithConfig(config Config) string {
+	s := New(config, tally.NoopScope, m.cads, m.sched, m.tags, m.containerRuntime)
 	addr, stop := testutil.StartServer(s.Handler())
 	m.cleanup.Add(stop)
 	return addr
@@ -125,8 +134,8 @@ func TestDownload(t *testing.T) {
 	namespace := core.TagFixture()
 	blob := core.NewBlobFixture()
 
-	mocks.sched.EXPECT().Download(namespace, blob.Digest).DoAndReturn(
-		func(namespace string, d core.Digest) error {
+	mocks.sched.EXPECT().DownloadWithPriority(namespace, blob.Digest, scheduler.PriorityNormal).DoAndReturn(
+		func(namespace string, d core.Digest, p scheduler.Priority) error {
 			return store.RunDownload(mocks.cads, d, blob.Content)
 		})
 
@@ -140,6 +149,64 @@ func TestDownload(t *testing.T) {
 	require.Equal(string(blob.Content), string(result))
 }
 
+func TestDownloadRange(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	namespace := core.TagFixture()
+	blob := core.SizedBlobFixture(256, 8)
+
+	require.NoError(store.RunDownload(mocks.cads, blob.Digest, blob.Content))
+
+	addr := mocks.startServer()
+
+	resp, err := httputil.Get(
+		fmt.Sprintf("http://%s/namespace/%s/blobs/%s", addr, url.PathEscape(namespace), blob.Digest.Hex()),
+		httputil.SendHeaders(map[string]string{
+			"Range":    "bytes=128-",
+			"If-Range": fmt.Sprintf("%q", blob.Digest.Hex()),
+		}),
+		httputil.SendAcceptedCodes(http.StatusPartialContent))
+	require.NoError(err)
+	defer resp.Body.Close()
+	result, err := ioutil.ReadAll(resp.Body)
+	require.NoError(err)
+	require.Equal(blob.Content[128:], result)
+}
+
+func TestDownloadPriority(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	namespace := core.TagFixture()
+	blob := core.NewBlobFixture()
+
+	mocks.sched.EXPECT().DownloadWithPriority(namespace, blob.Digest, scheduler.PriorityHigh).DoAndReturn(
+		func(namespace string, d core.Digest, p scheduler.Priority) error {
+			return store.RunDownload(mocks.cads, d, blob.Content)
+		})
+
+	addr := mocks.startServer()
+
+	resp, err := httputil.Get(fmt.Sprintf(
+		"http://%s/namespace/%s/blobs/%s?priority=high",
+		addr, url.PathEscape(namespace), blob.Digest))
+	require.NoError(err)
+	defer resp.Body.Close()
+	result, err := ioutil.ReadAll(resp.Body)
+	require.NoError(err)
+	require.Equal(string(blob.Content), string(result))
+
+	_, err = httputil.Get(fmt.Sprintf(
+		"http://%s/namespace/%s/blobs/%s?priority=urgent",
+		addr, url.PathEscape(namespace), blob.Digest))
+	require.True(httputil.IsStatus(err, 400))
+}
+
 func TestDownloadNotFound(t *testing.T) {
 	require := require.New(t)
 
@@ -149,7 +216,7 @@ func TestDownloadNotFound(t *testing.T) {
 	namespace := core.TagFixture()
 	blob := core.NewBlobFixture()
 
-	mocks.sched.EXPECT().Download(namespace, blob.Digest).Return(scheduler.ErrTorrentNotFound)
+	mocks.sched.EXPECT().DownloadWithPriority(namespace, blob.Digest, scheduler.PriorityNormal).Return(scheduler.ErrTorrentNotFound)
 
 	addr := mocks.startServer()
 	c := agentclient.New(addr)
@@ -168,7 +235,7 @@ func TestDownloadUnknownError(t *testing.T) {
 	namespace := core.TagFixture()
 	blob := core.NewBlobFixture()
 
-	mocks.sched.EXPECT().Download(namespace, blob.Digest).Return(fmt.Errorf("test error"))
+	mocks.sched.EXPECT().DownloadWithPriority(namespace, blob.Digest, scheduler.PriorityNormal).Return(fmt.Errorf("test error"))
 
 	addr := mocks.startServer()
 	c := agentclient.New(addr)
@@ -252,6 +319,75 @@ func TestGetBlacklistHandler(t *testing.T) {
 	require.Equal(blacklist, result)
 }
 
+func TestGetBandwidthHandler(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	limits := conn.BandwidthLimits{
+		Global: bandwidth.Config{EgressBitsPerSec: 800, IngressBitsPerSec: 800, Enable: true},
+	}
+	mocks.sched.EXPECT().BandwidthLimits().Return(limits)
+
+	addr := mocks.startServer()
+
+	resp, err := httputil.Get(fmt.Sprintf("http://%s/x/bandwidth", addr))
+	require.NoError(err)
+
+	var result conn.BandwidthLimits
+	require.NoError(json.NewDecoder(resp.Body).Decode(&result))
+	require.Equal(limits, result)
+}
+
+func TestPatchBandwidthHandler(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	global := bandwidth.Config{EgressBitsPerSec: 800, IngressBitsPerSec: 800, Enable: true}
+	peer := bandwidth.Config{EgressBitsPerSec: 80, IngressBitsPerSec: 80, Enable: true}
+
+	// Fields omitted from the body are unchanged.
+	mocks.sched.EXPECT().BandwidthLimits().Return(conn.BandwidthLimits{Global: global})
+	mocks.sched.EXPECT().SetBandwidthLimits(conn.BandwidthLimits{Global: global, Peer: peer}).Return(nil)
+
+	addr := mocks.startServerWithConfig(Config{DebugToken: "secret"})
+	auth := httputil.SendHeaders(map[string]string{"Authorization": "Bearer secret"})
+
+	b, err := json.Marshal(map[string]interface{}{"Peer": peer})
+	require.NoError(err)
+
+	_, err = httputil.Patch(
+		fmt.Sprintf("http://%s/x/bandwidth", addr),
+		httputil.SendBody(bytes.NewReader(b)))
+	require.True(httputil.IsStatus(err, http.StatusUnauthorized))
+
+	_, err = httputil.Patch(
+		fmt.Sprintf("http://%s/x/bandwidth", addr),
+		httputil.SendBody(bytes.NewReader(b)), auth)
+	require.NoError(err)
+}
+
+func TestPatchBandwidthHandlerInvalidLimits(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	mocks.sched.EXPECT().BandwidthLimits().Return(conn.BandwidthLimits{})
+	mocks.sched.EXPECT().SetBandwidthLimits(gomock.Any()).Return(errors.New("some error"))
+
+	addr := mocks.startServerWithConfig(Config{DebugToken: "secret"})
+
+	_, err := httputil.Patch(
+		fmt.Sprintf("http://%s/x/bandwidth", addr),
+		httputil.SendBody(bytes.NewReader([]byte("{}"))),
+		httputil.SendHeaders(map[string]string{"Authorization": "Bearer secret"}))
+	require.True(httputil.IsStatus(err, 400))
+}
+
 func TestDeleteBlobHandler(t *testing.T) {
 	require := require.New(t)
 
@@ -324,3 +460,201 @@ func TestPreloadHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestCacheUsageAndEvictHandlers(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	for i := 0; i < 2; i++ {
+		blob := core.SizedBlobFixture(100, 1)
+		require.NoError(store.RunDownload(mocks.cads, blob.Digest, blob.Content))
+	}
+
+	addr := mocks.startServerWithConfig(Config{DebugToken: "secret"})
+	auth := httputil.SendHeaders(map[string]string{"Authorization": "Bearer secret"})
+	evictURL := fmt.Sprintf("http://%s/x/cache/evict", addr)
+
+	resp, err := httputil.Get(fmt.Sprintf("http://%s/x/cache", addr))
+	require.NoError(err)
+	var usage store.CacheUsage
+	require.NoError(json.NewDecoder(resp.Body).Decode(&usage))
+	require.Equal(int64(200), usage.Size)
+	require.Equal(2, usage.Files)
+
+	_, err = httputil.Post(evictURL)
+	require.True(httputil.IsStatus(err, http.StatusUnauthorized))
+
+	// Quotas are not configured, so nothing is evicted.
+	resp, err = httputil.Post(evictURL, auth)
+	require.NoError(err)
+	var result store.EvictionResult
+	require.NoError(json.NewDecoder(resp.Body).Decode(&result))
+	require.Equal(store.EvictionResult{}, result)
+
+	for _, query := range []string{
+		"target=foo", "namespace=foo", "namespace=&target=0B", "target=0B&force=true", "target=",
+	} {
+		_, err = httputil.Post(evictURL+"?"+query, auth)
+		require.True(httputil.IsStatus(err, http.StatusBadRequest), query)
+	}
+
+	resp, err = httputil.Post(evictURL+"?target=100B", auth)
+	require.NoError(err)
+	require.NoError(json.NewDecoder(resp.Body).Decode(&result))
+	require.Equal(store.EvictionResult{Files: 1, Size: 100}, result)
+}
+
+func TestEvictCacheHandlerDisabledWithoutToken(t *testing.T) {
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr := mocks.startServer()
+
+	_, err := httputil.Post(fmt.Sprintf("http://%s/x/cache/evict?target=0B", addr))
+	require.True(t, httputil.IsNotFound(err))
+}
+
+func TestPinHandlers(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	blobs := make(map[core.Digest][]byte)
+	newBlob := func() core.Digest {
+		blob := core.NewBlobFixture()
+		blobs[blob.Digest] = blob.Content
+		return blob.Digest
+	}
+	newManifest := func(config, layer1, layer2 core.Digest) core.Digest {
+		d, raw := dockerutil.ManifestFixture(config, layer1, layer2)
+		blobs[d] = raw
+		return d
+	}
+	shared := newBlob()
+	config1, layer1 := newBlob(), newBlob()
+	config2, layer2 := newBlob(), newBlob()
+	manifest1 := newManifest(config1, shared, layer1)
+	manifest2 := newManifest(config2, shared, layer2)
+
+	mocks.tags.EXPECT().Get("repo:tag1").Return(manifest1, nil)
+	mocks.tags.EXPECT().Get("repo:tag2").Return(manifest2, nil)
+	mocks.tags.EXPECT().Get("repo:unknown").Return(core.Digest{}, tagclient.ErrTagNotFound)
+	mocks.sched.EXPECT().Download("repo", gomock.Any()).DoAndReturn(
+		func(namespace string, d core.Digest) error {
+			return store.RunDownload(mocks.cads, d, blobs[d])
+		}).AnyTimes()
+
+	unpinned := newBlob()
+	require.NoError(store.RunDownload(mocks.cads, unpinned, blobs[unpinned]))
+
+	addr := mocks.startServerWithConfig(Config{DebugToken: "secret"})
+	pinURL := func(tag string) string {
+		return fmt.Sprintf("http://%s/pin/%s", addr, url.PathEscape(tag))
+	}
+
+	resp, err := httputil.Post(pinURL("repo:tag1"))
+	require.NoError(err)
+	var pin Pin
+	require.NoError(json.NewDecoder(resp.Body).Decode(&pin))
+	require.Equal("repo:tag1", pin.Tag)
+	require.Equal(manifest1, *pin.Digest)
+	require.ElementsMatch([]core.Digest{manifest1, config1, shared, layer1}, pin.Blobs)
+
+	_, err = httputil.Post(pinURL("repo:tag2"))
+	require.NoError(err)
+
+	_, err = httputil.Post(pinURL("repo:unknown"))
+	require.True(httputil.IsNotFound(err))
+
+	resp, err = httputil.Get(fmt.Sprintf("http://%s/pin", addr))
+	require.NoError(err)
+	var pins []Pin
+	require.NoError(json.NewDecoder(resp.Body).Decode(&pins))
+	require.Len(pins, 2)
+	require.Equal("repo:tag1", pins[0].Tag)
+	require.ElementsMatch([]core.Digest{manifest1, config1, shared, layer1}, pins[0].Blobs)
+	require.Equal("repo:tag2", pins[1].Tag)
+
+	// Only the unpinned blob can be evicted.
+	resp, err = httputil.Post(
+		fmt.Sprintf("http://%s/x/cache/evict?target=0B", addr),
+		httputil.SendHeaders(map[string]string{"Authorization": "Bearer secret"}))
+	require.NoError(err)
+	var result store.EvictionResult
+	require.NoError(json.NewDecoder(resp.Body).Decode(&result))
+	require.Equal(1, result.Files)
+
+	_, err = httputil.Delete(pinURL("repo:tag1"))
+	require.NoError(err)
+	_, err = httputil.Delete(pinURL("repo:tag1"))
+	require.True(httputil.IsNotFound(err))
+
+	// Blobs shared with the remaining pin stay pinned.
+	isPersisted := func(d core.Digest) bool {
+		var persist metadata.Persist
+		require.NoError(mocks.cads.Cache().GetMetadata(d.Hex(), &persist))
+		return persist.Value
+	}
+	require.True(isPersisted(shared))
+	require.True(isPersisted(manifest2))
+	require.False(isPersisted(layer1))
+	require.False(isPersisted(manifest1))
+}
+
+func TestTorrentDebugHandlers(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	s := New(
+		Config{DebugToken: "secret"}, tally.NoopScope, mocks.cads, mocks.sched, mocks.tags, mocks.containerRuntime)
+	addr, stop := testutil.StartServer(s.Handler())
+	defer stop()
+
+	auth := httputil.SendHeaders(map[string]string{"Authorization": "Bearer secret"})
+
+	_, err := httputil.Get(fmt.Sprintf("http://%s/x/torrents", addr))
+	require.True(httputil.IsStatus(err, http.StatusUnauthorized))
+
+	torrents := []scheduler.TorrentStatus{{
+		Namespace: core.TagFixture(),
+		Digest:    core.DigestFixture(),
+		InfoHash:  core.InfoHashFixture(),
+		Priority:  "normal",
+		Bitfield:  "0110",
+	}}
+	mocks.sched.EXPECT().TorrentStatuses().Return(torrents, nil)
+	mocks.sched.EXPECT().BandwidthLimits().Return(conn.BandwidthLimits{})
+
+	resp, err := httputil.Get(fmt.Sprintf("http://%s/x/torrents", addr), auth)
+	require.NoError(err)
+	var result struct {
+		Torrents []scheduler.TorrentStatus `json:"torrents"`
+	}
+	require.NoError(json.NewDecoder(resp.Body).Decode(&result))
+	require.Equal(torrents, result.Torrents)
+
+	d := core.DigestFixture()
+
+	mocks.sched.EXPECT().CancelTorrent(d).Return(nil)
+	_, err = httputil.Delete(fmt.Sprintf("http://%s/x/torrents/%s", addr, d), auth)
+	require.NoError(err)
+
+	mocks.sched.EXPECT().Reannounce(d).Return(scheduler.ErrTorrentNotFound)
+	_, err = httputil.Post(fmt.Sprintf("http://%s/x/torrents/%s/announce", addr, d), auth)
+	require.True(httputil.IsNotFound(err))
+}
+
+func TestTorrentDebugHandlersDisabledWithoutToken(t *testing.T) {
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr := mocks.startServer()
+
+	_, err := httputil.Get(fmt.Sprintf("http://%s/x/torrents", addr))
+	require.True(t, httputil.IsNotFound(err))
+}
@@ -23,17 +23,31 @@ import (
 	"github.com/uber/kraken/build-index/tagclient"
 	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/lib/containerruntime"
+	"github.com/uber/kraken/lib/containerruntime/containerd"
 	"github.com/uber/kraken/lib/containerruntime/dockerdaemon"
 	"github.com/uber/kraken/lib/dockerregistry/transfer"
+	"github.com/uber/kraken/lib/middleware"
+	"github.com/uber/kraken/lib/originfetch"
+	"github.com/uber/kraken/lib/registryauth"
 	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/torrent/gossip"
 	"github.com/uber/kraken/lib/torrent/networkevent"
 	"github.com/uber/kraken/lib/torrent/scheduler"
 	"github.com/uber/kraken/metrics"
 	"github.com/uber/kraken/nginx"
+	"github.com/uber/kraken/origin/blobclient"
+	"github.com/uber/kraken/proxy/registryoverride"
+	"github.com/uber/kraken/tracing"
+	"github.com/uber/kraken/tracker/announceclient"
+	"github.com/uber/kraken/tracker/metainfoclient"
+	"github.com/uber/kraken/tracker/originstore"
 	"github.com/uber/kraken/utils/configutil"
+	"github.com/uber/kraken/utils/listener"
 	"github.com/uber/kraken/utils/log"
 	"github.com/uber/kraken/utils/netutil"
 
+	"github.com/andres-erbsen/clock"
+	"github.com/go-chi/chi"
 	"github.com/uber-go/tally"
 	"go.uber.org/zap"
 )
@@ -148,8 +162,14 @@ func Run(flags *Flags, opts ...Option) {
 
 	go metrics.EmitVersion(stats)
 
+	tracingCloser, err := tracing.Init(config.Tracing, "kraken-agent")
+	if err != nil {
+		log.Fatalf("Failed to init tracing: %s", err)
+	}
+	defer tracingCloser.Close()
+
 	if flags.PeerIP == "" {
-		localIP, err := netutil.GetLocalIP()
+		localIP, err := netutil.GetLocalIP(netutil.WithIPv6Fallback())
 		if err != nil {
 			log.Fatalf("Error getting local ip: %s", err)
 		}
@@ -172,23 +192,71 @@ func Run(flags *Flags, opts ...Option) {
 		log.Fatalf("Failed to create network event producer: %s", err)
 	}
 
-	trackers, err := config.Tracker.Build()
-	if err != nil {
-		log.Fatalf("Error building tracker upstream: %s", err)
-	}
-	go trackers.Monitor(nil)
-
 	tls, err := config.TLS.BuildClient()
 	if err != nil {
 		log.Fatalf("Error building client tls config: %s", err)
 	}
 
-	sched, err := scheduler.NewAgentScheduler(
-		config.Scheduler, stats, pctx, cads, netevents, trackers, tls)
+	var announceClient announceclient.Client
+	var metaInfoClient metainfoclient.Client
+	var gossipNode *gossip.Node
+	if config.Gossip.Enabled {
+		log.Info("Gossip peer discovery enabled, tracker config is ignored")
+		origins, err := config.Origin.Build()
+		if err != nil {
+			log.Fatalf("Error building origin host list: %s", err)
+		}
+		peers, err := config.Gossip.Peers.Build()
+		if err != nil {
+			log.Fatalf("Error building gossip peers upstream: %s", err)
+		}
+		go peers.Monitor(nil)
+
+		provider := blobclient.NewProvider(blobclient.WithTLS(tls))
+		gossipNode, err = gossip.New(
+			config.Gossip,
+			stats,
+			pctx,
+			peers,
+			originstore.New(config.Gossip.OriginStore, clock.New(), origins, provider),
+			tls)
+		if err != nil {
+			log.Fatalf("Error creating gossip node: %s", err)
+		}
+		announceClient = gossipNode
+		metaInfoClient = gossip.NewMetaInfoClient(blobclient.NewClientResolver(provider, origins))
+	} else {
+		trackers, err := config.Tracker.Build()
+		if err != nil {
+			log.Fatalf("Error building tracker upstream: %s", err)
+		}
+		go trackers.Monitor(nil)
+
+		announceClient = announceclient.New(pctx, trackers, tls)
+		metaInfoClient = metainfoclient.New(trackers, tls)
+	}
+
+	sched, err := scheduler.NewAgentSchedulerWithClients(
+		config.Scheduler, stats, pctx, cads, netevents, announceClient, metaInfoClient)
 	if err != nil {
 		log.Fatalf("Error creating scheduler: %s", err)
 	}
 
+	if config.OriginFetch.Enabled {
+		origins, err := config.Origin.Build()
+		if err != nil {
+			log.Fatalf("Error building origin host list: %s", err)
+		}
+		fetcher := originfetch.New(
+			config.OriginFetch,
+			stats,
+			cads,
+			metaInfoClient,
+			announceClient,
+			blobclient.NewClientResolver(blobclient.NewProvider(blobclient.WithTLS(tls)), origins))
+		sched = originfetch.WrapScheduler(sched, fetcher)
+	}
+
 	buildIndexes, err := config.BuildIndex.Build()
 	if err != nil {
 		log.Fatalf("Error building build-index upstream: %s", err)
@@ -198,6 +266,12 @@ func Run(flags *Flags, opts ...Option) {
 
 	transferer := transfer.NewReadOnlyTransferer(stats, cads, tagClient, sched)
 
+	authorizer, err := registryauth.New(config.RegistryAuth, stats, clock.New())
+	if err != nil {
+		log.Fatalf("Failed to init registry authorizer: %s", err)
+	}
+	authorizer.ConfigureRegistry(&config.Registry.Docker)
+
 	registry, err := config.Registry.Build(config.Registry.ReadOnlyParameters(transferer, cads, stats))
 	if err != nil {
 		log.Fatalf("Failed to init registry: %s", err)
@@ -214,13 +288,47 @@ func Run(flags *Flags, opts ...Option) {
 	if err != nil {
 		log.Fatalf("Failed to create container runtime factory: %s", err)
 	}
+	if p := containerRuntimeCfg.Containerd.HostsConfigPath; p != "" && config.Registry.Mirror.Enabled() {
+		upstreams := config.Registry.Mirror.UpstreamHosts()
+		if err := containerd.WriteHosts(p, upstreams, "http://"+registryAddr); err != nil {
+			log.Fatalf("Failed to write containerd hosts config: %s", err)
+		}
+		log.Infof("Configured containerd to pull %v through agent registry mirror", upstreams)
+	}
 
 	agentServer := agentserver.New(
 		config.AgentServer, stats, cads, sched, tagClient, containerRuntimeFactory)
 	addr := fmt.Sprintf(":%d", flags.AgentServerPort)
+	serverHandler := agentServer.Handler()
+	if gossipNode != nil {
+		r := chi.NewRouter()
+		r.Mount("/x/gossip", gossipNode.Handler())
+		r.Mount("/", serverHandler)
+		serverHandler = r
+	}
+	// Unlike the registry, the agent server is not behind nginx, so it
+	// terminates TLS itself when mutual TLS is enabled. Like nginx, it exempts
+	// local clients, including nginx itself, from client certificates.
+	server := &http.Server{Addr: addr}
+	agentServerScheme := "http"
+	if config.TLS.MutualTLS {
+		if config.TLS.Server.Disabled {
+			log.Fatal("Mutual TLS requires server TLS")
+		}
+		server.TLSConfig, err = config.TLS.BuildServer()
+		if err != nil {
+			log.Fatalf("Error building server tls config: %s", err)
+		}
+		serverHandler = middleware.RequireClientCert()(serverHandler)
+		agentServerScheme = "https"
+	}
+	server.Handler = serverHandler
 	log.Infof("Starting agent server on %s", addr)
 	go func() {
-		log.Fatal(http.ListenAndServe(addr, agentServer.Handler()))
+		if server.TLSConfig != nil {
+			log.Fatal(server.ListenAndServeTLS("", ""))
+		}
+		log.Fatal(server.ListenAndServe())
 	}()
 
 	log.Info("Starting registry...")
@@ -228,6 +336,22 @@ func Run(flags *Flags, opts ...Option) {
 		log.Fatal(registry.ListenAndServe())
 	}()
 
+	// nginx routes mirror requests to registry override, which passes them on
+	// to the registry once rewritten.
+	var registryProxy http.Handler
+	if config.Registry.Mirror.Enabled() {
+		registryProxy = config.Registry.Proxy()
+	}
+	ros := registryoverride.NewServer(
+		config.RegistryOverride, tagClient, transferer, authorizer, registryProxy)
+	go func() {
+		// Mirrored upstreams are rewritten for the override endpoints too,
+		// since nginx routes e.g. tag listing to them.
+		log.Infof("Starting registry override server on %s", config.RegistryOverride.Listener)
+		log.Fatal(listener.Serve(
+			config.RegistryOverride.Listener, config.Registry.Mirror.Handler(ros.Handler())))
+	}()
+
 	go heartbeat(stats)
 
 	log.Fatal(nginx.Run(config.Nginx, map[string]interface{}{
@@ -235,8 +359,12 @@ func Run(flags *Flags, opts ...Option) {
 		"port":          flags.AgentRegistryPort,
 		"registry_server": nginx.GetServer(
 			config.Registry.Docker.HTTP.Net, config.Registry.Docker.HTTP.Addr),
-		"agent_server":    fmt.Sprintf("127.0.0.1:%d", flags.AgentServerPort),
-		"registry_backup": config.RegistryBackup},
+		"registry_override_server": nginx.GetServer(
+			config.RegistryOverride.Listener.Net, config.RegistryOverride.Listener.Addr),
+		"registry_mirror":     config.Registry.Mirror.Enabled(),
+		"agent_server":        fmt.Sprintf("127.0.0.1:%d", flags.AgentServerPort),
+		"agent_server_scheme": agentServerScheme,
+		"registry_backup":     config.RegistryBackup},
 		nginx.WithTLS(config.TLS)))
 }
 
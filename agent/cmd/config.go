@@ -19,12 +19,17 @@ import (
 	"github.com/uber/kraken/lib/containerruntime"
 	"github.com/uber/kraken/lib/containerruntime/dockerdaemon"
 	"github.com/uber/kraken/lib/dockerregistry"
+	"github.com/uber/kraken/lib/originfetch"
+	"github.com/uber/kraken/lib/registryauth"
 	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/torrent/gossip"
 	"github.com/uber/kraken/lib/torrent/networkevent"
 	"github.com/uber/kraken/lib/torrent/scheduler"
 	"github.com/uber/kraken/lib/upstream"
 	"github.com/uber/kraken/metrics"
 	"github.com/uber/kraken/nginx"
+	"github.com/uber/kraken/proxy/registryoverride"
+	"github.com/uber/kraken/tracing"
 	"github.com/uber/kraken/utils/httputil"
 
 	"go.uber.org/zap"
@@ -34,14 +39,20 @@ import (
 type Config struct {
 	ZapLogging       zap.Config                     `yaml:"zap"`
 	Metrics          metrics.Config                 `yaml:"metrics"`
+	Tracing          tracing.Config                 `yaml:"tracing"`
 	CADownloadStore  store.CADownloadStoreConfig    `yaml:"store"`
 	Registry         dockerregistry.Config          `yaml:"registry"`
+	RegistryAuth     registryauth.Config            `yaml:"registry_auth"`
 	Scheduler        scheduler.Config               `yaml:"scheduler"`
 	PeerIDFactory    core.PeerIDFactory             `yaml:"peer_id_factory"`
 	NetworkEvent     networkevent.Config            `yaml:"network_event"`
 	Tracker          upstream.PassiveHashRingConfig `yaml:"tracker"`
+	Gossip           gossip.Config                  `yaml:"gossip"`
 	BuildIndex       upstream.PassiveConfig         `yaml:"build_index"`
+	Origin           upstream.PassiveConfig         `yaml:"origin"`
+	OriginFetch      originfetch.Config             `yaml:"origin_fetch"`
 	AgentServer      agentserver.Config             `yaml:"agentserver"`
+	RegistryOverride registryoverride.Config        `yaml:"registryoverride"`
 	RegistryBackup   string                         `yaml:"registry_backup"`
 	Nginx            nginx.Config                   `yaml:"nginx"`
 	TLS              httputil.TLSConfig             `yaml:"tls"`
@@ -0,0 +1,256 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package blobgc
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/uber/kraken/build-index/tagstore"
+	"github.com/uber/kraken/build-index/tagtype"
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/backend"
+	"github.com/uber/kraken/origin/blobclient"
+	"github.com/uber/kraken/utils/log"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/uber-go/tally"
+)
+
+// Collector garbage collects blobs which are no longer referenced by any tag.
+type Collector interface {
+	AddCandidates(namespace string, digests core.DigestList) error
+	Start()
+	Stop()
+	Collect() error
+}
+
+type collector struct {
+	config       Config
+	stats        tally.Scope
+	clk          clock.Clock
+	store        *Store
+	backends     *backend.Manager
+	tagStore     tagstore.Store
+	depResolver  tagtype.DependencyResolver
+	originClient blobclient.ClusterClient
+
+	stopOnce sync.Once
+	stopc    chan struct{}
+}
+
+// NewCollector creates a new Collector. Unless enabled, candidates are neither
+// recorded nor collected.
+func NewCollector(
+	config Config,
+	stats tally.Scope,
+	clk clock.Clock,
+	store *Store,
+	backends *backend.Manager,
+	tagStore tagstore.Store,
+	depResolver tagtype.DependencyResolver,
+	originClient blobclient.ClusterClient) (Collector, error) {
+
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %s", err)
+	}
+
+	stats = stats.Tagged(map[string]string{
+		"module": "blobgc",
+	})
+
+	return &collector{
+		config:       config.applyDefaults(),
+		stats:        stats,
+		clk:          clk,
+		store:        store,
+		backends:     backends,
+		tagStore:     tagStore,
+		depResolver:  depResolver,
+		originClient: originClient,
+		stopc:        make(chan struct{}),
+	}, nil
+}
+
+// AddCandidates records digests of namespace as possibly unreferenced, such
+// that they are checked on future collections.
+func (c *collector) AddCandidates(namespace string, digests core.DigestList) error {
+	if !c.config.Enabled {
+		return nil
+	}
+	now := c.clk.Now()
+	for _, d := range digests {
+		if err := c.store.Add(&Candidate{namespace, d, now}); err != nil {
+			return fmt.Errorf("add candidate %s: %s", d, err)
+		}
+	}
+	return nil
+}
+
+// Start runs collection in the background every configured interval.
+func (c *collector) Start() {
+	if !c.config.Enabled {
+		log.Info("Blob garbage collection disabled")
+		return
+	}
+	ticker := c.clk.Ticker(c.config.Interval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := c.Collect(); err != nil {
+					log.Errorf("Error collecting unreferenced blobs: %s", err)
+				}
+			case <-c.stopc:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops background collection.
+func (c *collector) Stop() {
+	c.stopOnce.Do(func() { close(c.stopc) })
+}
+
+// Collect purges every candidate older than the grace period which is not
+// referenced by any tag. Referenced candidates are dropped, since they will
+// be re-added if their tags are deleted.
+func (c *collector) Collect() error {
+	timer := c.stats.Timer("collect").Start()
+	defer timer.Stop()
+
+	candidates, err := c.store.GetAll()
+	if err != nil {
+		return fmt.Errorf("get candidates: %s", err)
+	}
+	c.stats.Gauge("candidates").Update(float64(len(candidates)))
+
+	cutoff := c.clk.Now().Add(-c.config.GracePeriod)
+	var expired []*Candidate
+	for _, cand := range candidates {
+		if !cand.CreatedAt.After(cutoff) {
+			expired = append(expired, cand)
+		}
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+
+	// The references must be computed after the candidates are read, such
+	// that tags which re-reference a candidate are never missed.
+	refs := newReferences()
+	if err := c.updateReferences(refs); err != nil {
+		return fmt.Errorf("compute referenced blobs: %s", err)
+	}
+
+	// Referenced candidates are dropped right away, the rest are re-checked
+	// in a single pass before purging.
+	var unreferenced []*Candidate
+	for _, cand := range expired {
+		if !refs.has(cand.Digest) {
+			unreferenced = append(unreferenced, cand)
+			continue
+		}
+		c.stats.Counter("retained").Inc(1)
+		if err := c.store.Remove(cand); err != nil {
+			return fmt.Errorf("remove candidate: %s", err)
+		}
+	}
+	if len(unreferenced) == 0 {
+		return nil
+	}
+
+	// Tags may have been put since the references were computed, so the
+	// unreferenced candidates are re-checked right before they are purged.
+	if err := c.updateReferences(refs); err != nil {
+		return fmt.Errorf("re-check referenced blobs: %s", err)
+	}
+
+	for _, cand := range unreferenced {
+		if refs.has(cand.Digest) {
+			c.stats.Counter("retained").Inc(1)
+		} else {
+			if err := c.originClient.PurgeBlob(cand.Namespace, cand.Digest); err != nil {
+				c.stats.Counter("purge_errors").Inc(1)
+				log.With("namespace", cand.Namespace, "digest", cand.Digest).Errorf(
+					"Error purging unreferenced blob: %s", err)
+				continue
+			}
+			c.stats.Counter("collected").Inc(1)
+		}
+		if err := c.store.Remove(cand); err != nil {
+			return fmt.Errorf("remove candidate: %s", err)
+		}
+	}
+	return nil
+}
+
+// references tracks the blobs referenced by tags, along with the manifest each
+// tag pointed to when its dependencies were resolved.
+type references struct {
+	tags  map[string]core.Digest
+	blobs map[core.Digest]struct{}
+}
+
+func newReferences() *references {
+	return &references{
+		tags:  make(map[string]core.Digest),
+		blobs: make(map[core.Digest]struct{}),
+	}
+}
+
+func (r *references) has(d core.Digest) bool {
+	_, ok := r.blobs[d]
+	return ok
+}
+
+// updateReferences adds the blobs referenced by every tag under the configured
+// prefixes to refs. Only tags which are new or point to a different manifest
+// since the last update are resolved. Blobs of tags which were deleted since
+// are kept, which errs on the side of retaining them.
+func (c *collector) updateReferences(refs *references) error {
+	for _, prefix := range c.config.Prefixes {
+		client, err := c.backends.GetClient(prefix)
+		if err != nil {
+			return fmt.Errorf("backend manager: %s", err)
+		}
+		result, err := client.List(prefix)
+		if err != nil {
+			return fmt.Errorf("list %q: %s", prefix, err)
+		}
+		for _, tag := range result.Names {
+			d, err := c.tagStore.Get(tag)
+			if err == tagstore.ErrTagNotFound {
+				// Deleted since listing.
+				continue
+			} else if err != nil {
+				return fmt.Errorf("get tag %s: %s", tag, err)
+			}
+			if prev, ok := refs.tags[tag]; ok && prev == d {
+				continue
+			}
+			deps, err := c.depResolver.Resolve(tag, d)
+			if err != nil {
+				return fmt.Errorf("resolve dependencies of %s: %s", tag, err)
+			}
+			for _, dep := range deps {
+				refs.blobs[dep] = struct{}{}
+			}
+			refs.tags[tag] = d
+		}
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package blobgc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/build-index/tagstore"
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/backend"
+	"github.com/uber/kraken/localdb"
+	"github.com/uber/kraken/mocks/build-index/tagstore"
+	"github.com/uber/kraken/mocks/build-index/tagtype"
+	"github.com/uber/kraken/mocks/lib/backend"
+	"github.com/uber/kraken/mocks/origin/blobclient"
+	"github.com/uber/kraken/utils/testutil"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+type collectorMocks struct {
+	ctrl          *gomock.Controller
+	clk           *clock.Mock
+	store         *Store
+	backends      *backend.Manager
+	backendClient *mockbackend.MockClient
+	tagStore      *mocktagstore.MockStore
+	depResolver   *mocktagtype.MockDependencyResolver
+	originClient  *mockblobclient.MockClusterClient
+}
+
+func newCollectorMocks(t *testing.T) (*collectorMocks, func()) {
+	var cleanup testutil.Cleanup
+	defer cleanup.Recover()
+
+	ctrl := gomock.NewController(t)
+	cleanup.Add(ctrl.Finish)
+
+	db, c := localdb.Fixture()
+	cleanup.Add(c)
+
+	backends := backend.ManagerFixture()
+	backendClient := mockbackend.NewMockClient(ctrl)
+	require.NoError(t, backends.Register(".*", backendClient))
+
+	return &collectorMocks{
+		ctrl:          ctrl,
+		clk:           clock.NewMock(),
+		store:         NewStore(db),
+		backends:      backends,
+		backendClient: backendClient,
+		tagStore:      mocktagstore.NewMockStore(ctrl),
+		depResolver:   mocktagtype.NewMockDependencyResolver(ctrl),
+		originClient:  mockblobclient.NewMockClusterClient(ctrl),
+	}, cleanup.Run
+}
+
+func (m *collectorMocks) new(t *testing.T, config Config) Collector {
+	c, err := NewCollector(
+		config, tally.NoopScope, m.clk, m.store, m.backends, m.tagStore, m.depResolver, m.originClient)
+	require.NoError(t, err)
+	return c
+}
+
+func testConfig() Config {
+	return Config{Enabled: true, GracePeriod: time.Hour, Prefixes: []string{""}}
+}
+
+func (m *collectorMocks) candidates(t *testing.T) core.DigestList {
+	cs, err := m.store.GetAll()
+	require.NoError(t, err)
+	var digests core.DigestList
+	for _, c := range cs {
+		digests = append(digests, c.Digest)
+	}
+	return digests
+}
+
+func TestCollectPurgesUnreferencedBlobs(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newCollectorMocks(t)
+	defer cleanup()
+
+	collector := mocks.new(t, testConfig())
+
+	namespace := "foo/bar"
+	shared := core.DigestFixture()
+	unreferenced := core.DigestFixture()
+
+	require.NoError(collector.AddCandidates(namespace, core.DigestList{shared, unreferenced}))
+
+	mocks.clk.Add(time.Hour)
+
+	tag := "foo/bar:latest"
+	manifest := core.DigestFixture()
+
+	// Tags are listed again to re-check the unreferenced candidate before
+	// purging it, but unchanged tags are not resolved again.
+	mocks.backendClient.EXPECT().List("").Return(&backend.ListResult{
+		Names: []string{tag, "foo/bar:deleted"},
+	}, nil).Times(2)
+	mocks.tagStore.EXPECT().Get(tag).Return(manifest, nil).Times(2)
+	mocks.tagStore.EXPECT().Get("foo/bar:deleted").Return(core.Digest{}, tagstore.ErrTagNotFound).Times(2)
+	mocks.depResolver.EXPECT().Resolve(tag, manifest).Return(core.DigestList{shared, manifest}, nil)
+	mocks.originClient.EXPECT().PurgeBlob(namespace, unreferenced).Return(nil)
+
+	require.NoError(collector.Collect())
+	require.Empty(mocks.candidates(t))
+}
+
+func TestCollectSkipsCandidatesWithinGracePeriod(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newCollectorMocks(t)
+	defer cleanup()
+
+	collector := mocks.new(t, testConfig())
+
+	d := core.DigestFixture()
+	require.NoError(collector.AddCandidates("foo", core.DigestList{d}))
+
+	mocks.clk.Add(30 * time.Minute)
+
+	require.NoError(collector.Collect())
+	require.Equal(core.DigestList{d}, mocks.candidates(t))
+}
+
+func TestCollectKeepsCandidateOnPurgeError(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newCollectorMocks(t)
+	defer cleanup()
+
+	collector := mocks.new(t, testConfig())
+
+	d := core.DigestFixture()
+	require.NoError(collector.AddCandidates("foo", core.DigestList{d}))
+
+	mocks.clk.Add(time.Hour)
+
+	mocks.backendClient.EXPECT().List("").Return(&backend.ListResult{}, nil).Times(2)
+	mocks.originClient.EXPECT().PurgeBlob("foo", d).Return(errors.New("some error"))
+
+	require.NoError(collector.Collect())
+	require.Equal(core.DigestList{d}, mocks.candidates(t))
+}
+
+func TestCollectAbortsOnResolveError(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newCollectorMocks(t)
+	defer cleanup()
+
+	collector := mocks.new(t, testConfig())
+
+	d := core.DigestFixture()
+	require.NoError(collector.AddCandidates("foo", core.DigestList{d}))
+
+	mocks.clk.Add(time.Hour)
+
+	tag := "foo:latest"
+	manifest := core.DigestFixture()
+
+	mocks.backendClient.EXPECT().List("").Return(&backend.ListResult{Names: []string{tag}}, nil)
+	mocks.tagStore.EXPECT().Get(tag).Return(manifest, nil)
+	mocks.depResolver.EXPECT().Resolve(tag, manifest).Return(nil, errors.New("some error"))
+
+	require.Error(collector.Collect())
+	require.Equal(core.DigestList{d}, mocks.candidates(t))
+}
+
+func TestCollectRechecksCandidateBeforePurge(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newCollectorMocks(t)
+	defer cleanup()
+
+	collector := mocks.new(t, testConfig())
+
+	d := core.DigestFixture()
+	require.NoError(collector.AddCandidates("foo", core.DigestList{d}))
+
+	mocks.clk.Add(time.Hour)
+
+	tag := "foo:latest"
+	manifest := core.DigestFixture()
+
+	// The tag is put after the references are first computed.
+	gomock.InOrder(
+		mocks.backendClient.EXPECT().List("").Return(&backend.ListResult{}, nil),
+		mocks.backendClient.EXPECT().List("").Return(&backend.ListResult{Names: []string{tag}}, nil),
+	)
+	mocks.tagStore.EXPECT().Get(tag).Return(manifest, nil)
+	mocks.depResolver.EXPECT().Resolve(tag, manifest).Return(core.DigestList{d, manifest}, nil)
+
+	require.NoError(collector.Collect())
+	require.Empty(mocks.candidates(t))
+}
+
+func TestCollectRechecksUnreferencedCandidatesOnce(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newCollectorMocks(t)
+	defer cleanup()
+
+	collector := mocks.new(t, testConfig())
+
+	layers := core.DigestListFixture(3)
+	require.NoError(collector.AddCandidates("foo", layers))
+
+	mocks.clk.Add(time.Hour)
+
+	// Tags are listed once to compute the references, and once more to
+	// re-check all unreferenced candidates.
+	mocks.backendClient.EXPECT().List("").Return(&backend.ListResult{}, nil).Times(2)
+	for _, d := range layers {
+		mocks.originClient.EXPECT().PurgeBlob("foo", d).Return(nil)
+	}
+
+	require.NoError(collector.Collect())
+	require.Empty(mocks.candidates(t))
+}
+
+func TestCollectorDisabled(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newCollectorMocks(t)
+	defer cleanup()
+
+	collector := mocks.new(t, Config{})
+	collector.Start()
+	defer collector.Stop()
+
+	require.NoError(collector.AddCandidates("foo", core.DigestList{core.DigestFixture()}))
+	require.Empty(mocks.candidates(t))
+}
+
+func TestNewCollectorRequiresPrefixes(t *testing.T) {
+	mocks, cleanup := newCollectorMocks(t)
+	defer cleanup()
+
+	_, err := NewCollector(
+		Config{Enabled: true}, tally.NoopScope, mocks.clk, mocks.store, mocks.backends,
+		mocks.tagStore, mocks.depResolver, mocks.originClient)
+	require.Error(t, err)
+}
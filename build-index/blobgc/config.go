@@ -0,0 +1,56 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package blobgc
+
+import (
+	"errors"
+	"time"
+)
+
+// Config defines garbage collection of blobs which are no longer referenced
+// by any tag.
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Interval is how often collection runs.
+	Interval time.Duration `yaml:"interval"`
+
+	// GracePeriod is how long a blob must have been unreferenced before it is
+	// collected. This protects blobs which are re-tagged shortly after their
+	// previous tag was deleted.
+	GracePeriod time.Duration `yaml:"grace_period"`
+
+	// Prefixes are the tag prefixes which are listed to determine which blobs
+	// are still referenced. Blobs referenced by tags outside of these prefixes
+	// may be collected, so the prefixes must cover every tag namespace.
+	// Required if enabled, use "" to list every tag.
+	Prefixes []string `yaml:"prefixes"`
+}
+
+func (c Config) validate() error {
+	if c.Enabled && len(c.Prefixes) == 0 {
+		return errors.New("prefixes required when enabled")
+	}
+	return nil
+}
+
+func (c Config) applyDefaults() Config {
+	if c.Interval == 0 {
+		c.Interval = time.Hour
+	}
+	if c.GracePeriod == 0 {
+		c.GracePeriod = 24 * time.Hour
+	}
+	return c
+}
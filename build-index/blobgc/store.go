@@ -0,0 +1,70 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package blobgc
+
+import (
+	"time"
+
+	"github.com/uber/kraken/core"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Candidate is a blob which may no longer be referenced by any tag.
+type Candidate struct {
+	Namespace string      `db:"namespace"`
+	Digest    core.Digest `db:"digest"`
+	CreatedAt time.Time   `db:"created_at"`
+}
+
+// Store stores garbage collection candidates.
+type Store struct {
+	db *sqlx.DB
+}
+
+// NewStore creates a new Store.
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{db}
+}
+
+// Add adds c. If c already exists, its creation time is left unchanged.
+func (s *Store) Add(c *Candidate) error {
+	_, err := s.db.NamedExec(`
+		INSERT OR IGNORE INTO gc_candidate (
+			namespace,
+			digest,
+			created_at
+		) VALUES (
+			:namespace,
+			:digest,
+			:created_at
+		)
+	`, c)
+	return err
+}
+
+// GetAll returns all candidates.
+func (s *Store) GetAll() ([]*Candidate, error) {
+	var cs []*Candidate
+	err := s.db.Select(&cs, `SELECT namespace, digest, created_at FROM gc_candidate`)
+	return cs, err
+}
+
+// Remove removes c.
+func (s *Store) Remove(c *Candidate) error {
+	_, err := s.db.NamedExec(`
+		DELETE FROM gc_candidate
+		WHERE namespace=:namespace AND digest=:digest`, c)
+	return err
+}
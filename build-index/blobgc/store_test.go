@@ -0,0 +1,51 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package blobgc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/localdb"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreAddIgnoresDuplicates(t *testing.T) {
+	require := require.New(t)
+
+	db, cleanup := localdb.Fixture()
+	defer cleanup()
+
+	store := NewStore(db)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	c := &Candidate{"foo", core.DigestFixture(), now}
+
+	require.NoError(store.Add(c))
+	require.NoError(store.Add(&Candidate{c.Namespace, c.Digest, now.Add(time.Hour)}))
+
+	cs, err := store.GetAll()
+	require.NoError(err)
+	require.Len(cs, 1)
+	require.Equal(c.Digest, cs[0].Digest)
+	require.True(now.Equal(cs[0].CreatedAt))
+
+	require.NoError(store.Remove(c))
+
+	cs, err = store.GetAll()
+	require.NoError(err)
+	require.Empty(cs)
+}
@@ -16,6 +16,7 @@ package cmd
 import (
 	"flag"
 
+	"github.com/uber/kraken/build-index/blobgc"
 	"github.com/uber/kraken/build-index/tagclient"
 	"github.com/uber/kraken/build-index/tagserver"
 	"github.com/uber/kraken/build-index/tagstore"
@@ -24,17 +25,21 @@ import (
 	"github.com/uber/kraken/lib/healthcheck"
 	"github.com/uber/kraken/lib/hostlist"
 	"github.com/uber/kraken/lib/persistedretry"
+	"github.com/uber/kraken/lib/persistedretry/notification"
 	"github.com/uber/kraken/lib/persistedretry/tagreplication"
 	"github.com/uber/kraken/lib/persistedretry/writeback"
+	"github.com/uber/kraken/lib/signature"
 	"github.com/uber/kraken/lib/store"
 	"github.com/uber/kraken/lib/upstream"
 	"github.com/uber/kraken/localdb"
 	"github.com/uber/kraken/metrics"
 	"github.com/uber/kraken/nginx"
 	"github.com/uber/kraken/origin/blobclient"
+	"github.com/uber/kraken/tracing"
 	"github.com/uber/kraken/utils/configutil"
 	"github.com/uber/kraken/utils/log"
 
+	"github.com/andres-erbsen/clock"
 	"github.com/uber-go/tally"
 	"go.uber.org/zap"
 )
@@ -131,12 +136,18 @@ func Run(flags *Flags, opts ...Option) {
 
 	go metrics.EmitVersion(stats)
 
+	tracingCloser, err := tracing.Init(config.Tracing, "kraken-build-index")
+	if err != nil {
+		log.Fatalf("Failed to init tracing: %s", err)
+	}
+	defer tracingCloser.Close()
+
 	ss, err := store.NewSimpleStore(config.Store, stats)
 	if err != nil {
 		log.Fatalf("Error creating simple store: %s", err)
 	}
 
-	backends, err := backend.NewManager(config.Backends, config.Auth)
+	backends, err := backend.NewManager(config.Backends, config.Auth, backend.WithStats(stats))
 	if err != nil {
 		log.Fatalf("Error creating backend manager: %s", err)
 	}
@@ -146,7 +157,9 @@ func Run(flags *Flags, opts ...Option) {
 		log.Fatalf("Error building client tls config: %s", err)
 	}
 
-	origins, err := config.Origin.Build(upstream.WithHealthCheck(healthcheck.Default(tls)))
+	origins, err := config.Origin.Build(
+		upstream.WithHealthCheck(healthcheck.Default(tls)),
+		upstream.WithStats(stats.SubScope("origin")))
 	if err != nil {
 		log.Fatalf("Error building origin host list: %s", err)
 	}
@@ -164,11 +177,17 @@ func Run(flags *Flags, opts ...Option) {
 		log.Fatalf("Error creating local db: %s", err)
 	}
 
-	cluster, err := config.Cluster.Build(upstream.WithHealthCheck(healthcheck.Default(tls)))
+	cluster, err := config.Cluster.Build(
+		upstream.WithHealthCheck(healthcheck.Default(tls)),
+		upstream.WithStats(stats.SubScope("cluster")))
 	if err != nil {
 		log.Fatalf("Error building cluster host list: %s", err)
 	}
-	neighbors, err := hostlist.StripLocal(cluster, flags.Port)
+	stripOpts := []hostlist.StripOption{hostlist.WithStripStats(stats)}
+	if config.DevMode {
+		stripOpts = append(stripOpts, hostlist.WithDevMode())
+	}
+	neighbors, err := hostlist.StripLocal(cluster, flags.Port, stripOpts...)
 	if err != nil {
 		log.Fatalf("Error stripping local machine from cluster list: %s", err)
 	}
@@ -178,10 +197,33 @@ func Run(flags *Flags, opts ...Option) {
 		log.Fatalf("Error building remotes from configuration: %s", err)
 	}
 
+	notifier, err := notification.New(config.Notification, stats, localDB)
+	if err != nil {
+		log.Fatalf("Error creating notifier: %s", err)
+	}
+
+	writeBackManager, err := persistedretry.NewManager(
+		config.WriteBack,
+		stats,
+		writeback.NewStore(localDB),
+		writeback.NewExecutor(stats, ss, backends))
+	if err != nil {
+		log.Fatalf("Error creating write-back manager: %s", err)
+	}
+
+	tagStore := tagstore.New(config.TagStore, stats, ss, backends, writeBackManager)
+
+	verifier, err := signature.New(config.Signature, stats, tagStore, originClient)
+	if err != nil {
+		log.Fatalf("Error creating signature verifier: %s", err)
+	}
+
 	tagReplicationExecutor := tagreplication.NewExecutor(
 		stats,
 		originClient,
-		tagclient.NewProvider(tls))
+		tagclient.NewProvider(tls),
+		notifier,
+		verifier)
 	tagReplicationStore, err := tagreplication.NewStore(localDB, remotes)
 	if err != nil {
 		log.Fatalf("Error creating tag replication store: %s", err)
@@ -195,21 +237,25 @@ func Run(flags *Flags, opts ...Option) {
 		log.Fatalf("Error creating tag replication manager: %s", err)
 	}
 
-	writeBackManager, err := persistedretry.NewManager(
-		config.WriteBack,
-		stats,
-		writeback.NewStore(localDB),
-		writeback.NewExecutor(stats, ss, backends))
+	depResolver, err := tagtype.NewMap(config.TagTypes, originClient)
 	if err != nil {
-		log.Fatalf("Error creating write-back manager: %s", err)
+		log.Fatalf("Error creating tag type manager: %s", err)
 	}
 
-	tagStore := tagstore.New(config.TagStore, stats, ss, backends, writeBackManager)
-
-	depResolver, err := tagtype.NewMap(config.TagTypes, originClient)
+	collector, err := blobgc.NewCollector(
+		config.BlobGC,
+		stats,
+		clock.New(),
+		blobgc.NewStore(localDB),
+		backends,
+		tagStore,
+		depResolver,
+		originClient)
 	if err != nil {
-		log.Fatalf("Error creating tag type manager: %s", err)
+		log.Fatalf("Error creating blob garbage collector: %s", err)
 	}
+	collector.Start()
+	defer collector.Stop()
 
 	server := tagserver.New(
 		config.TagServer,
@@ -222,7 +268,9 @@ func Run(flags *Flags, opts ...Option) {
 		remotes,
 		tagReplicationManager,
 		tagclient.NewProvider(tls),
-		depResolver)
+		depResolver,
+		collector,
+		verifier)
 	go func() {
 		log.Fatal(server.ListenAndServe())
 	}()
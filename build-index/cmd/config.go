@@ -14,17 +14,21 @@
 package cmd
 
 import (
+	"github.com/uber/kraken/build-index/blobgc"
 	"github.com/uber/kraken/build-index/tagserver"
 	"github.com/uber/kraken/build-index/tagstore"
 	"github.com/uber/kraken/build-index/tagtype"
 	"github.com/uber/kraken/lib/backend"
 	"github.com/uber/kraken/lib/persistedretry"
+	"github.com/uber/kraken/lib/persistedretry/notification"
 	"github.com/uber/kraken/lib/persistedretry/tagreplication"
+	"github.com/uber/kraken/lib/signature"
 	"github.com/uber/kraken/lib/store"
 	"github.com/uber/kraken/lib/upstream"
 	"github.com/uber/kraken/localdb"
 	"github.com/uber/kraken/metrics"
 	"github.com/uber/kraken/nginx"
+	"github.com/uber/kraken/tracing"
 	"github.com/uber/kraken/utils/httputil"
 
 	"go.uber.org/zap"
@@ -34,6 +38,7 @@ import (
 type Config struct {
 	ZapLogging     zap.Config                   `yaml:"zap"`
 	Metrics        metrics.Config               `yaml:"metrics"`
+	Tracing        tracing.Config               `yaml:"tracing"`
 	Backends       []backend.Config             `yaml:"backends"`
 	Auth           backend.AuthConfig           `yaml:"auth"`
 	TagServer      tagserver.Config             `yaml:"tagserver"`
@@ -48,4 +53,12 @@ type Config struct {
 	WriteBack      persistedretry.Config        `yaml:"writeback"`
 	Nginx          nginx.Config                 `yaml:"nginx"`
 	TLS            httputil.TLSConfig           `yaml:"tls"`
+	BlobGC         blobgc.Config                `yaml:"blob_gc"`
+	Notification   notification.Config          `yaml:"notification"`
+	Signature      signature.Config             `yaml:"signature"`
+
+	// DevMode keeps the local machine in the cluster list if it is the only
+	// member, so a single node can run locally. Off by default; never enable
+	// in production.
+	DevMode bool `yaml:"dev_mode"`
 }
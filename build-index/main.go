@@ -17,6 +17,7 @@ import (
 	"github.com/uber/kraken/build-index/cmd"
 
 	// Import all backend client packages to register them with backend manager.
+	_ "github.com/uber/kraken/lib/backend/azurebackend"
 	_ "github.com/uber/kraken/lib/backend/gcsbackend"
 	_ "github.com/uber/kraken/lib/backend/hdfsbackend"
 	_ "github.com/uber/kraken/lib/backend/httpbackend"
@@ -42,6 +42,8 @@ type Client interface {
 	PutAndReplicate(tag string, d core.Digest) error
 	Get(tag string) (core.Digest, error)
 	Has(tag string) (bool, error)
+	Delete(tag string) error
+	DeleteAndReplicate(tag string) error
 	List(prefix string) ([]string, error)
 	ListWithPagination(prefix string, filter ListFilter) (tagmodels.ListResponse, error)
 	ListRepository(repo string) ([]string, error)
@@ -52,6 +54,7 @@ type Client interface {
 	DuplicateReplicate(
 		tag string, d core.Digest, dependencies core.DigestList, delay time.Duration) error
 	DuplicatePut(tag string, d core.Digest, delay time.Duration) error
+	DuplicateDelete(tag string) error
 }
 
 type singleClient struct {
@@ -123,6 +126,28 @@ func (c *singleClient) Has(tag string) (bool, error) {
 	return true, nil
 }
 
+func (c *singleClient) Delete(tag string) error {
+	_, err := httputil.Delete(
+		fmt.Sprintf("http://%s/tags/%s", c.addr, url.PathEscape(tag)),
+		httputil.SendTimeout(30*time.Second),
+		httputil.SendTLS(c.tls))
+	if httputil.IsNotFound(err) {
+		return ErrTagNotFound
+	}
+	return err
+}
+
+func (c *singleClient) DeleteAndReplicate(tag string) error {
+	_, err := httputil.Delete(
+		fmt.Sprintf("http://%s/tags/%s?replicate=true", c.addr, url.PathEscape(tag)),
+		httputil.SendTimeout(30*time.Second),
+		httputil.SendTLS(c.tls))
+	if httputil.IsNotFound(err) {
+		return ErrTagNotFound
+	}
+	return err
+}
+
 func (c *singleClient) doListPaginated(urlFormat string, pathSub string,
 	filter ListFilter) (tagmodels.ListResponse, error) {
 
@@ -267,6 +292,18 @@ func (c *singleClient) DuplicatePut(tag string, d core.Digest, delay time.Durati
 	return err
 }
 
+func (c *singleClient) DuplicateDelete(tag string) error {
+	_, err := httputil.Delete(
+		fmt.Sprintf("http://%s/internal/duplicate/tags/%s", c.addr, url.PathEscape(tag)),
+		httputil.SendTimeout(10*time.Second),
+		httputil.SendRetry(),
+		httputil.SendTLS(c.tls))
+	if httputil.IsNotFound(err) {
+		return ErrTagNotFound
+	}
+	return err
+}
+
 func (c *singleClient) Origin() (string, error) {
 	resp, err := httputil.Get(
 		fmt.Sprintf("http://%s/origin", c.addr),
@@ -335,6 +372,14 @@ func (cc *clusterClient) Has(tag string) (ok bool, err error) {
 	return
 }
 
+func (cc *clusterClient) Delete(tag string) error {
+	return cc.do(func(c Client) error { return c.Delete(tag) })
+}
+
+func (cc *clusterClient) DeleteAndReplicate(tag string) error {
+	return cc.do(func(c Client) error { return c.DeleteAndReplicate(tag) })
+}
+
 func (cc *clusterClient) List(prefix string) (tags []string, err error) {
 	err = cc.do(func(c Client) error {
 		tags, err = c.List(prefix)
@@ -392,3 +437,7 @@ func (cc *clusterClient) DuplicateReplicate(
 func (cc *clusterClient) DuplicatePut(tag string, d core.Digest, delay time.Duration) error {
 	return errors.New("duplicate put not supported on cluster client")
 }
+
+func (cc *clusterClient) DuplicateDelete(tag string) error {
+	return errors.New("duplicate delete not supported on cluster client")
+}
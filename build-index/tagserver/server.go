@@ -24,6 +24,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/uber/kraken/build-index/blobgc"
 	"github.com/uber/kraken/build-index/tagclient"
 	"github.com/uber/kraken/build-index/tagmodels"
 	"github.com/uber/kraken/build-index/tagstore"
@@ -35,7 +36,10 @@ import (
 	"github.com/uber/kraken/lib/middleware"
 	"github.com/uber/kraken/lib/persistedretry"
 	"github.com/uber/kraken/lib/persistedretry/tagreplication"
+	"github.com/uber/kraken/lib/signature"
+	"github.com/uber/kraken/metrics"
 	"github.com/uber/kraken/origin/blobclient"
+	"github.com/uber/kraken/utils/errutil"
 	"github.com/uber/kraken/utils/handler"
 	"github.com/uber/kraken/utils/httputil"
 	"github.com/uber/kraken/utils/listener"
@@ -63,6 +67,12 @@ type Server struct {
 
 	// For checking if a tag has all dependent blobs.
 	depResolver tagtype.DependencyResolver
+
+	// For collecting blobs of deleted tags.
+	collector blobgc.Collector
+
+	// For gating tags on image signatures.
+	verifier signature.Verifier
 }
 
 // New creates a new Server.
@@ -77,7 +87,9 @@ func New(
 	remotes tagreplication.Remotes,
 	tagReplicationManager persistedretry.Manager,
 	provider tagclient.Provider,
-	depResolver tagtype.DependencyResolver) *Server {
+	depResolver tagtype.DependencyResolver,
+	collector blobgc.Collector,
+	verifier signature.Verifier) *Server {
 
 	config = config.applyDefaults()
 
@@ -97,6 +109,8 @@ func New(
 		tagReplicationManager: tagReplicationManager,
 		provider:              provider,
 		depResolver:           depResolver,
+		collector:             collector,
+		verifier:              verifier,
 	}
 }
 
@@ -104,6 +118,7 @@ func New(
 func (s *Server) Handler() http.Handler {
 	r := chi.NewRouter()
 
+	r.Use(middleware.Tracing())
 	r.Use(middleware.StatusCounter(s.stats))
 	r.Use(middleware.LatencyTimer(s.stats))
 
@@ -112,6 +127,7 @@ func (s *Server) Handler() http.Handler {
 	r.Put("/tags/{tag}/digest/{digest}", handler.Wrap(s.putTagHandler))
 	r.Head("/tags/{tag}", handler.Wrap(s.hasTagHandler))
 	r.Get("/tags/{tag}", handler.Wrap(s.getTagHandler))
+	r.Delete("/tags/{tag}", handler.Wrap(s.deleteTagHandler))
 
 	r.Get("/repositories/{repo}/tags", handler.Wrap(s.listRepositoryHandler))
 
@@ -129,8 +145,12 @@ func (s *Server) Handler() http.Handler {
 		"/internal/duplicate/tags/{tag}/digest/{digest}",
 		handler.Wrap(s.duplicatePutTagHandler))
 
+	r.Delete("/internal/duplicate/tags/{tag}", handler.Wrap(s.duplicateDeleteTagHandler))
+
 	r.Mount("/debug", chimiddleware.Profiler())
 
+	r.Handle(metrics.PrometheusHandlerPath, metrics.PrometheusHandler())
+
 	return r
 }
 
@@ -163,9 +183,21 @@ func (s *Server) putTagHandler(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return fmt.Errorf("resolve dependencies: %s", err)
 	}
+	// Like deletes, overwrites may leave the blobs of the previous manifest
+	// unreferenced.
+	prevDeps, err := s.overwrittenDeps(tag, d)
+	if err != nil {
+		return err
+	}
 	if err := s.putTag(tag, d, deps); err != nil {
 		return err
 	}
+	if len(prevDeps) > 0 {
+		if err := s.collector.AddCandidates(tag, prevDeps); err != nil {
+			s.stats.Counter("add_gc_candidate_failures").Inc(1)
+			log.With("tag", tag).Errorf("Error adding gc candidates: %s", err)
+		}
+	}
 
 	if replicate {
 		if err := s.replicateTag(tag, d, deps); err != nil {
@@ -214,12 +246,70 @@ func (s *Server) getTagHandler(w http.ResponseWriter, r *http.Request) error {
 		return handler.Errorf("storage: %s", err)
 	}
 
+	if err := s.verifier.Verify(tag, d, signature.OpServe); err != nil {
+		if signature.IsRejected(err) {
+			return handler.Errorf("%s", err).Status(http.StatusForbidden)
+		}
+		return handler.Errorf("verify signature: %s", err)
+	}
+
 	if _, err := io.WriteString(w, d.String()); err != nil {
 		return handler.Errorf("write digest: %s", err)
 	}
 	return nil
 }
 
+// deleteTagHandler deletes a tag from the storage backend and from every
+// build-index in the cluster. If replicate is set, the deletion is also
+// propagated to remote build-indexes. The blobs referenced by the tag are
+// garbage collected once they are no longer referenced by any other tag.
+func (s *Server) deleteTagHandler(w http.ResponseWriter, r *http.Request) error {
+	tag, err := httputil.ParseParam(r, "tag")
+	if err != nil {
+		return err
+	}
+	replicate, err := strconv.ParseBool(httputil.GetQueryArg(r, "replicate", "false"))
+	if err != nil {
+		return handler.Errorf("parse query arg `replicate`: %s", err)
+	}
+
+	if err := s.deleteTag(tag); err != nil {
+		if err != tagstore.ErrTagNotFound {
+			return err
+		}
+		// Remotes may still have the tag, even if it was already deleted
+		// locally by a previous attempt.
+		if !replicate {
+			return handler.ErrorStatus(http.StatusNotFound)
+		}
+	}
+
+	if replicate {
+		if err := s.replicateDelete(tag); err != nil {
+			return err
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *Server) duplicateDeleteTagHandler(w http.ResponseWriter, r *http.Request) error {
+	tag, err := httputil.ParseParam(r, "tag")
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteLocal(tag); err != nil {
+		if err == tagstore.ErrTagNotFound {
+			return handler.ErrorStatus(http.StatusNotFound)
+		}
+		return handler.Errorf("storage: %s", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
 func (s *Server) hasTagHandler(w http.ResponseWriter, r *http.Request) error {
 	tag, err := httputil.ParseParam(r, "tag")
 	if err != nil {
@@ -436,6 +526,90 @@ func (s *Server) replicateTag(tag string, d core.Digest, deps core.DigestList) e
 	return nil
 }
 
+// deleteTag deletes tag from the storage backend and local disk, evicts it
+// from neighbors, and records its dependencies as garbage collection
+// candidates. Returns tagstore.ErrTagNotFound if tag does not exist.
+// overwrittenDeps returns the dependencies of the manifest tag currently
+// points to, if tag exists and does not already point to d.
+func (s *Server) overwrittenDeps(tag string, d core.Digest) (core.DigestList, error) {
+	prev, err := s.store.Get(tag)
+	if err == tagstore.ErrTagNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, handler.Errorf("storage: %s", err)
+	}
+	if prev == d {
+		return nil, nil
+	}
+	deps, err := s.depResolver.Resolve(tag, prev)
+	if err != nil {
+		log.With("tag", tag).Errorf("Error resolving dependencies of overwritten tag: %s", err)
+		deps = core.DigestList{prev}
+	}
+	return deps, nil
+}
+
+func (s *Server) deleteTag(tag string) error {
+	d, err := s.store.Get(tag)
+	if err != nil {
+		if err == tagstore.ErrTagNotFound {
+			return err
+		}
+		return handler.Errorf("storage: %s", err)
+	}
+	// Dependencies must be resolved before deleting the tag, since they may
+	// become unavailable afterwards.
+	deps, err := s.depResolver.Resolve(tag, d)
+	if err != nil {
+		log.With("tag", tag).Errorf("Error resolving dependencies of deleted tag: %s", err)
+		deps = core.DigestList{d}
+	}
+
+	if err := s.store.Delete(tag); err != nil {
+		if err == tagstore.ErrTagNotFound {
+			return err
+		}
+		return handler.Errorf("storage: %s", err)
+	}
+
+	if err := s.collector.AddCandidates(tag, deps); err != nil {
+		s.stats.Counter("add_gc_candidate_failures").Inc(1)
+		log.With("tag", tag).Errorf("Error adding gc candidates: %s", err)
+	}
+
+	neighbors := s.neighbors.Resolve()
+
+	var successes int
+	for addr := range neighbors {
+		client := s.provider.Provide(addr)
+		if err := client.DuplicateDelete(tag); err != nil && err != tagclient.ErrTagNotFound {
+			log.Errorf("Error duplicating delete to %s: %s", addr, err)
+		} else {
+			successes++
+		}
+	}
+	if len(neighbors) != 0 && successes == 0 {
+		s.stats.Counter("duplicate_delete_failures").Inc(1)
+	}
+	return nil
+}
+
+// replicateDelete synchronously deletes tag from every remote it replicates
+// to. Unlike puts, deletes are not retried in the background, so the caller
+// may safely retry on failure.
+func (s *Server) replicateDelete(tag string) error {
+	var errs []error
+	for _, dest := range s.remotes.Match(tag) {
+		if err := s.provider.Provide(dest).Delete(tag); err != nil && err != tagclient.ErrTagNotFound {
+			errs = append(errs, fmt.Errorf("remote %s: %s", dest, err))
+		}
+	}
+	if err := errutil.Join(errs); err != nil {
+		return handler.Errorf("replicate delete: %s", err)
+	}
+	return nil
+}
+
 func buildPaginationOptions(u *url.URL) ([]backend.ListOption, error) {
 	var opts []backend.ListOption
 	q := u.Query()
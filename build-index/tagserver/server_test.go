@@ -14,6 +14,7 @@
 package tagserver
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -30,11 +31,14 @@ import (
 	"github.com/uber/kraken/lib/healthcheck"
 	"github.com/uber/kraken/lib/hostlist"
 	"github.com/uber/kraken/lib/persistedretry/tagreplication"
+	"github.com/uber/kraken/lib/signature"
+	"github.com/uber/kraken/mocks/build-index/blobgc"
 	"github.com/uber/kraken/mocks/build-index/tagclient"
 	"github.com/uber/kraken/mocks/build-index/tagstore"
 	"github.com/uber/kraken/mocks/build-index/tagtype"
 	"github.com/uber/kraken/mocks/lib/backend"
 	"github.com/uber/kraken/mocks/lib/persistedretry"
+	"github.com/uber/kraken/mocks/lib/signature"
 	"github.com/uber/kraken/mocks/origin/blobclient"
 	"github.com/uber/kraken/utils/httputil"
 	"github.com/uber/kraken/utils/testutil"
@@ -63,6 +67,8 @@ type serverMocks struct {
 	originClient          *mockblobclient.MockClusterClient
 	store                 *mocktagstore.MockStore
 	neighbors             hostlist.List
+	collector             *mockblobgc.MockCollector
+	verifier              signature.Verifier
 }
 
 func newServerMocks(t *testing.T) (*serverMocks, func()) {
@@ -93,6 +99,8 @@ func newServerMocks(t *testing.T) (*serverMocks, func()) {
 
 	store := mocktagstore.NewMockStore(ctrl)
 
+	collector := mockblobgc.NewMockCollector(ctrl)
+
 	return &serverMocks{
 		ctrl:                  ctrl,
 		config:                Config{DuplicateReplicateStagger: 20 * time.Minute},
@@ -105,6 +113,8 @@ func newServerMocks(t *testing.T) (*serverMocks, func()) {
 		depResolver:           depResolver,
 		store:                 store,
 		neighbors:             hostlist.Fixture(_testNeighbor),
+		collector:             collector,
+		verifier:              signature.Disabled(),
 	}, cleanup.Run
 }
 
@@ -124,7 +134,9 @@ func (m *serverMocks) handler() http.Handler {
 		m.remotes,
 		m.tagReplicationManager,
 		m.provider,
-		m.depResolver).Handler()
+		m.depResolver,
+		m.collector,
+		m.verifier).Handler()
 }
 
 func newClusterClient(addr string) tagclient.Client {
@@ -165,6 +177,7 @@ func TestPut(t *testing.T) {
 	neighborClient := mocktagclient.NewMockClient(mocks.ctrl)
 
 	mocks.depResolver.EXPECT().Resolve(tag, digest).Return(core.DigestList{digest}, nil)
+	mocks.store.EXPECT().Get(tag).Return(core.Digest{}, tagstore.ErrTagNotFound)
 	mocks.originClient.EXPECT().Stat(tag, digest).Return(core.NewBlobInfo(256), nil)
 	mocks.store.EXPECT().Put(tag, digest, time.Duration(0)).Return(nil)
 	mocks.provider.EXPECT().Provide(_testNeighbor).Return(neighborClient)
@@ -174,6 +187,38 @@ func TestPut(t *testing.T) {
 	require.NoError(client.Put(tag, digest))
 }
 
+func TestPutOverwriteAddsGCCandidates(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr, stop := testutil.StartServer(mocks.handler())
+	defer stop()
+
+	client := newClusterClient(addr)
+
+	tag := core.TagFixture()
+	digest := core.DigestFixture()
+	prev := core.DigestFixture()
+	prevDeps := core.DigestList{core.DigestFixture(), prev}
+	neighborClient := mocktagclient.NewMockClient(mocks.ctrl)
+
+	gomock.InOrder(
+		mocks.depResolver.EXPECT().Resolve(tag, digest).Return(core.DigestList{digest}, nil),
+		mocks.store.EXPECT().Get(tag).Return(prev, nil),
+		mocks.depResolver.EXPECT().Resolve(tag, prev).Return(prevDeps, nil),
+		mocks.originClient.EXPECT().Stat(tag, digest).Return(core.NewBlobInfo(256), nil),
+		mocks.store.EXPECT().Put(tag, digest, time.Duration(0)).Return(nil),
+		mocks.provider.EXPECT().Provide(_testNeighbor).Return(neighborClient),
+		neighborClient.EXPECT().DuplicatePut(
+			tag, digest, mocks.config.DuplicateReplicateStagger).Return(nil),
+		mocks.collector.EXPECT().AddCandidates(tag, prevDeps).Return(nil),
+	)
+
+	require.NoError(client.Put(tag, digest))
+}
+
 func TestPutInvalidParam(t *testing.T) {
 	tag := core.TagFixture()
 	digest := core.DigestFixture()
@@ -310,6 +355,149 @@ func TestGetTagNotFound(t *testing.T) {
 	require.Equal(tagclient.ErrTagNotFound, err)
 }
 
+func TestGetRejectsUnsignedTag(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	verifier := mocksignature.NewMockVerifier(mocks.ctrl)
+	mocks.verifier = verifier
+
+	addr, stop := testutil.StartServer(mocks.handler())
+	defer stop()
+
+	client := newClusterClient(addr)
+
+	tag := core.TagFixture()
+	digest := core.DigestFixture()
+
+	mocks.store.EXPECT().Get(tag).Return(digest, nil)
+	verifier.EXPECT().Verify(tag, digest, signature.OpServe).Return(
+		signature.RejectedError{Digest: digest, Reason: errors.New("some error")})
+
+	_, err := client.Get(tag)
+	require.True(httputil.IsForbidden(err))
+}
+
+func TestDelete(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr, stop := testutil.StartServer(mocks.handler())
+	defer stop()
+
+	client := newClusterClient(addr)
+
+	tag := core.TagFixture()
+	digest := core.DigestFixture()
+	deps := core.DigestList{core.DigestFixture(), digest}
+	neighborClient := mocks.client()
+
+	gomock.InOrder(
+		mocks.store.EXPECT().Get(tag).Return(digest, nil),
+		mocks.depResolver.EXPECT().Resolve(tag, digest).Return(deps, nil),
+		mocks.store.EXPECT().Delete(tag).Return(nil),
+		mocks.collector.EXPECT().AddCandidates(tag, deps).Return(nil),
+		mocks.provider.EXPECT().Provide(_testNeighbor).Return(neighborClient),
+		neighborClient.EXPECT().DuplicateDelete(tag).Return(nil),
+	)
+
+	require.NoError(client.Delete(tag))
+}
+
+func TestDeleteNotFound(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr, stop := testutil.StartServer(mocks.handler())
+	defer stop()
+
+	client := newClusterClient(addr)
+
+	tag := core.TagFixture()
+
+	mocks.store.EXPECT().Get(tag).Return(core.Digest{}, tagstore.ErrTagNotFound)
+
+	require.Equal(tagclient.ErrTagNotFound, client.Delete(tag))
+}
+
+func TestDeleteAndReplicate(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr, stop := testutil.StartServer(mocks.handler())
+	defer stop()
+
+	client := newClusterClient(addr)
+
+	tag := core.TagFixture()
+	digest := core.DigestFixture()
+	deps := core.DigestList{digest}
+	neighborClient := mocks.client()
+	remoteClient := mocks.client()
+
+	gomock.InOrder(
+		mocks.store.EXPECT().Get(tag).Return(digest, nil),
+		mocks.depResolver.EXPECT().Resolve(tag, digest).Return(deps, nil),
+		mocks.store.EXPECT().Delete(tag).Return(nil),
+		mocks.collector.EXPECT().AddCandidates(tag, deps).Return(nil),
+		mocks.provider.EXPECT().Provide(_testNeighbor).Return(neighborClient),
+		neighborClient.EXPECT().DuplicateDelete(tag).Return(nil),
+		mocks.provider.EXPECT().Provide(_testRemote).Return(remoteClient),
+		remoteClient.EXPECT().Delete(tag).Return(nil),
+	)
+
+	require.NoError(client.DeleteAndReplicate(tag))
+}
+
+func TestDeleteAndReplicateRetriesRemotesOfDeletedTag(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr, stop := testutil.StartServer(mocks.handler())
+	defer stop()
+
+	client := newClusterClient(addr)
+
+	tag := core.TagFixture()
+	remoteClient := mocks.client()
+
+	gomock.InOrder(
+		mocks.store.EXPECT().Get(tag).Return(core.Digest{}, tagstore.ErrTagNotFound),
+		mocks.provider.EXPECT().Provide(_testRemote).Return(remoteClient),
+		remoteClient.EXPECT().Delete(tag).Return(tagclient.ErrTagNotFound),
+	)
+
+	require.NoError(client.DeleteAndReplicate(tag))
+}
+
+func TestDuplicateDelete(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr, stop := testutil.StartServer(mocks.handler())
+	defer stop()
+
+	client := tagclient.NewSingleClient(addr, nil)
+
+	tag := core.TagFixture()
+
+	mocks.store.EXPECT().DeleteLocal(tag).Return(nil)
+
+	require.NoError(client.DuplicateDelete(tag))
+}
+
 func TestHas(t *testing.T) {
 	require := require.New(t)
 
@@ -475,6 +663,7 @@ func TestPutAndReplicate(t *testing.T) {
 
 	gomock.InOrder(
 		mocks.depResolver.EXPECT().Resolve(tag, digest).Return(core.DigestList{digest}, nil),
+		mocks.store.EXPECT().Get(tag).Return(digest, nil),
 		mocks.originClient.EXPECT().Stat(tag, digest).Return(core.NewBlobInfo(256), nil),
 		mocks.store.EXPECT().Put(tag, digest, time.Duration(0)).Return(nil),
 		mocks.provider.EXPECT().Provide(_testNeighbor).Return(neighborClient),
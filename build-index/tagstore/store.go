@@ -42,12 +42,16 @@ type FileStore interface {
 	CreateCacheFile(name string, r io.Reader) error
 	SetCacheFileMetadata(name string, md metadata.Metadata) (bool, error)
 	GetCacheFileReader(name string) (store.FileReader, error)
+	DeleteCacheFile(name string) error
+	DeleteCacheFileMetadata(name string, md metadata.Metadata) error
 }
 
 // Store defines tag storage operations.
 type Store interface {
 	Put(tag string, d core.Digest, writeBackDelay time.Duration) error
 	Get(tag string) (core.Digest, error)
+	Delete(tag string) error
+	DeleteLocal(tag string) error
 }
 
 // tagStore encapsulates two-level tag storage:
@@ -115,6 +119,49 @@ func (s *tagStore) Get(tag string) (d core.Digest, err error) {
 	return d, err
 }
 
+// Delete deletes tag from remote storage and from disk. Returns ErrTagNotFound
+// if tag was in neither.
+func (s *tagStore) Delete(tag string) error {
+	backendClient, err := s.backends.GetClient(tag)
+	if err != nil {
+		return fmt.Errorf("backend manager: %s", err)
+	}
+	// Delete from remote storage first, such that backends which do not
+	// support deletion leave the tag intact.
+	var found bool
+	if err := backend.Delete(backendClient, tag, tag); err == nil {
+		found = true
+	} else if err != backenderrors.ErrBlobNotFound {
+		return fmt.Errorf("backend client: %s", err)
+	}
+	if err := s.DeleteLocal(tag); err == nil {
+		found = true
+	} else if err != ErrTagNotFound {
+		return err
+	}
+	if !found {
+		return ErrTagNotFound
+	}
+	return nil
+}
+
+// DeleteLocal deletes tag from disk only. Any pending write-back of tag is
+// dropped. Returns ErrTagNotFound if tag was not on disk.
+func (s *tagStore) DeleteLocal(tag string) error {
+	// Persisted files cannot be deleted.
+	err := s.fs.DeleteCacheFileMetadata(tag, &metadata.Persist{})
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("delete persist metadata: %s", err)
+	}
+	if err := s.fs.DeleteCacheFile(tag); err != nil {
+		if os.IsNotExist(err) {
+			return ErrTagNotFound
+		}
+		return fmt.Errorf("fs: %s", err)
+	}
+	return nil
+}
+
 func (s *tagStore) writeTagToDisk(tag string, d core.Digest) error {
 	buf := bytes.NewBufferString(d.String())
 	if err := s.fs.CreateCacheFile(tag, buf); err != nil && !os.IsExist(err) {
@@ -14,6 +14,7 @@
 package tagstore_test
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"sync"
@@ -42,9 +43,16 @@ type storeMocks struct {
 	ss               *store.SimpleStore
 	backends         *backend.Manager
 	backendClient    *mockbackend.MockClient
+	backendDeleter   *mockbackend.MockDeleter
 	writeBackManager *mockpersistedretry.MockManager
 }
 
+// deletableClient is a backend client which supports deletion.
+type deletableClient struct {
+	*mockbackend.MockClient
+	*mockbackend.MockDeleter
+}
+
 func newStoreMocks(t *testing.T) (*storeMocks, func()) {
 	var cleanup testutil.Cleanup
 	defer cleanup.Recover()
@@ -57,11 +65,13 @@ func newStoreMocks(t *testing.T) (*storeMocks, func()) {
 
 	backends := backend.ManagerFixture()
 	backendClient := mockbackend.NewMockClient(ctrl)
-	require.NoError(t, backends.Register(_testNamespace, backendClient))
+	backendDeleter := mockbackend.NewMockDeleter(ctrl)
+	require.NoError(t, backends.Register(_testNamespace, deletableClient{backendClient, backendDeleter}))
 
 	writeBackManager := mockpersistedretry.NewMockManager(ctrl)
 
-	return &storeMocks{ctrl, ss, backends, backendClient, writeBackManager}, cleanup.Run
+	return &storeMocks{
+		ctrl, ss, backends, backendClient, backendDeleter, writeBackManager}, cleanup.Run
 }
 
 func (m *storeMocks) new(config Config) Store {
@@ -182,3 +192,73 @@ func TestGetFromBackendInvalidValue(t *testing.T) {
 	_, err := store.Get(tag)
 	require.Error(err)
 }
+
+func TestDelete(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newStoreMocks(t)
+	defer cleanup()
+
+	store := mocks.new(Config{})
+
+	tag := core.TagFixture()
+	digest := core.DigestFixture()
+
+	mocks.writeBackManager.EXPECT().Add(
+		writeback.MatchTask(writeback.NewTask(tag, tag, 0))).Return(nil)
+	require.NoError(store.Put(tag, digest, 0))
+
+	mocks.backendDeleter.EXPECT().Delete(tag, tag).Return(nil)
+	require.NoError(store.Delete(tag))
+
+	mocks.backendClient.EXPECT().Download(tag, tag, gomock.Any()).Return(backenderrors.ErrBlobNotFound)
+	_, err := store.Get(tag)
+	require.Equal(ErrTagNotFound, err)
+
+	mocks.backendDeleter.EXPECT().Delete(tag, tag).Return(backenderrors.ErrBlobNotFound)
+	require.Equal(ErrTagNotFound, store.Delete(tag))
+}
+
+func TestDeleteBeforeWriteBack(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newStoreMocks(t)
+	defer cleanup()
+
+	store := mocks.new(Config{})
+
+	tag := core.TagFixture()
+
+	mocks.writeBackManager.EXPECT().Add(
+		writeback.MatchTask(writeback.NewTask(tag, tag, 0))).Return(nil)
+	require.NoError(store.Put(tag, core.DigestFixture(), 0))
+
+	// The tag only exists on disk.
+	mocks.backendDeleter.EXPECT().Delete(tag, tag).Return(backenderrors.ErrBlobNotFound)
+	require.NoError(store.Delete(tag))
+
+	require.Equal(ErrTagNotFound, store.DeleteLocal(tag))
+}
+
+func TestDeleteBackendErrorKeepsTagOnDisk(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newStoreMocks(t)
+	defer cleanup()
+
+	store := mocks.new(Config{})
+
+	tag := core.TagFixture()
+	digest := core.DigestFixture()
+
+	mocks.writeBackManager.EXPECT().Add(
+		writeback.MatchTask(writeback.NewTask(tag, tag, 0))).Return(nil)
+	require.NoError(store.Put(tag, digest, 0))
+
+	mocks.backendDeleter.EXPECT().Delete(tag, tag).Return(errors.New("some error"))
+	require.Error(store.Delete(tag))
+
+	result, err := store.Get(tag)
+	require.NoError(err)
+	require.Equal(digest, result)
+}
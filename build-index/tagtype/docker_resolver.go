@@ -27,13 +27,16 @@ type dockerResolver struct {
 	originClient blobclient.ClusterClient
 }
 
-// Resolve returns all layers + manifest of given tag as its dependencies.
+// Resolve returns all layers + manifest of given tag as its dependencies. For
+// manifest lists, the layers and manifests of every platform are included.
 func (r *dockerResolver) Resolve(tag string, d core.Digest) (core.DigestList, error) {
 	m, err := r.downloadManifest(tag, d)
 	if err != nil {
 		return nil, err
 	}
-	deps, err := dockerutil.GetManifestReferences(m)
+	deps, err := dockerutil.GetAllManifestReferences(m, func(child core.Digest) (distribution.Manifest, error) {
+		return r.downloadManifest(tag, child)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("get manifest references: %s", err)
 	}
@@ -54,6 +54,34 @@ func TestMapResolveDocker(t *testing.T) {
 	require.Equal(core.DigestList(append(layers, manifest)), deps)
 }
 
+func TestMapResolveDockerImageIndex(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	originClient := mockblobclient.NewMockClusterClient(ctrl)
+
+	m, err := NewMap(testConfigs(), originClient)
+	require.NoError(err)
+
+	tag := "namespace-foo/repo-bar:0001"
+	layers := core.DigestListFixture(4)
+	amd64, amd64Bytes := dockerutil.ManifestFixture(layers[0], layers[1], layers[2])
+	arm64, arm64Bytes := dockerutil.ManifestFixture(layers[0], layers[1], layers[3])
+	index, indexBytes := dockerutil.ImageIndexFixture(amd64, arm64)
+
+	originClient.EXPECT().DownloadBlob(tag, index, mockutil.MatchWriter(indexBytes)).Return(nil)
+	originClient.EXPECT().DownloadBlob(tag, amd64, mockutil.MatchWriter(amd64Bytes)).Return(nil)
+	originClient.EXPECT().DownloadBlob(tag, arm64, mockutil.MatchWriter(arm64Bytes)).Return(nil)
+
+	deps, err := m.Resolve(tag, index)
+	require.NoError(err)
+	require.Equal(core.DigestList{
+		layers[0], layers[1], layers[2], amd64, layers[3], arm64, index,
+	}, deps)
+}
+
 func TestMapResolveDefault(t *testing.T) {
 	require := require.New(t)
 
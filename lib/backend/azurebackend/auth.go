@@ -0,0 +1,127 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package azurebackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber/kraken/utils/httputil"
+
+	"github.com/andres-erbsen/clock"
+)
+
+const (
+	_imdsEndpoint    = "http://169.254.169.254/metadata/identity/oauth2/token"
+	_storageResource = "https://storage.azure.com/"
+
+	// _tokenRefreshSlack is how long before expiry a managed identity token
+	// is refreshed.
+	_tokenRefreshSlack = 5 * time.Minute
+)
+
+// authorizer authorizes requests to the blob service.
+type authorizer interface {
+	// authorize adds credentials to the query or headers of a request.
+	authorize(q url.Values, headers map[string]string) error
+}
+
+// sasAuthorizer authorizes requests with a shared access signature.
+type sasAuthorizer struct {
+	token url.Values
+}
+
+func newSASAuthorizer(token string) (*sasAuthorizer, error) {
+	v, err := url.ParseQuery(strings.TrimPrefix(token, "?"))
+	if err != nil {
+		return nil, fmt.Errorf("parse sas token: %s", err)
+	}
+	if v.Get("sig") == "" {
+		return nil, fmt.Errorf("sas token has no signature")
+	}
+	return &sasAuthorizer{v}, nil
+}
+
+func (a *sasAuthorizer) authorize(q url.Values, headers map[string]string) error {
+	for k, vs := range a.token {
+		q[k] = vs
+	}
+	return nil
+}
+
+// managedIdentityAuthorizer authorizes requests with bearer tokens of the
+// managed identity of the host, fetched from the instance metadata service and
+// cached until shortly before they expire.
+type managedIdentityAuthorizer struct {
+	endpoint string
+	clientID string
+	clk      clock.Clock
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func (a *managedIdentityAuthorizer) authorize(q url.Values, headers map[string]string) error {
+	token, err := a.getToken()
+	if err != nil {
+		return fmt.Errorf("managed identity token: %s", err)
+	}
+	headers["Authorization"] = "Bearer " + token
+	return nil
+}
+
+func (a *managedIdentityAuthorizer) getToken() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && a.clk.Now().Before(a.expires.Add(-_tokenRefreshSlack)) {
+		return a.token, nil
+	}
+	q := url.Values{}
+	q.Set("api-version", "2018-02-01")
+	q.Set("resource", _storageResource)
+	if a.clientID != "" {
+		q.Set("client_id", a.clientID)
+	}
+	resp, err := httputil.Get(
+		a.endpoint+"?"+q.Encode(),
+		httputil.SendHeaders(map[string]string{"Metadata": "true"}),
+		httputil.SendTimeout(10*time.Second),
+		httputil.SendRetry())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresOn   string `json:"expires_on"` // Unix seconds.
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode: %s", err)
+	}
+	expiresOn, err := strconv.ParseInt(body.ExpiresOn, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("parse expires_on: %s", err)
+	}
+	a.token = body.AccessToken
+	a.expires = time.Unix(expiresOn, 0)
+	return a.token, nil
+}
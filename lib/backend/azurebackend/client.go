@@ -0,0 +1,373 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package azurebackend
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/backend"
+	"github.com/uber/kraken/lib/backend/backenderrors"
+	"github.com/uber/kraken/lib/backend/namepath"
+	"github.com/uber/kraken/utils/httputil"
+	"github.com/uber/kraken/utils/log"
+
+	"github.com/andres-erbsen/clock"
+	"gopkg.in/yaml.v2"
+)
+
+const _azure = "azure"
+
+// _apiVersion is the blob service REST API version requests are made against.
+const _apiVersion = "2019-12-12"
+
+func init() {
+	backend.Register(_azure, &factory{})
+}
+
+type factory struct{}
+
+func (f *factory) Create(
+	confRaw interface{}, authConfRaw interface{}) (backend.Client, error) {
+
+	confBytes, err := yaml.Marshal(confRaw)
+	if err != nil {
+		return nil, errors.New("marshal azure config")
+	}
+	authConfBytes, err := yaml.Marshal(authConfRaw)
+	if err != nil {
+		return nil, errors.New("marshal azure auth config")
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(confBytes, &config); err != nil {
+		return nil, errors.New("unmarshal azure config")
+	}
+	var userAuth UserAuthConfig
+	if err := yaml.Unmarshal(authConfBytes, &userAuth); err != nil {
+		return nil, errors.New("unmarshal azure auth config")
+	}
+
+	return NewClient(config, userAuth)
+}
+
+// Client implements a backend.Client for Azure Blob Storage, using the blob
+// service REST API.
+type Client struct {
+	config Config
+	pather namepath.Pather
+	auth   authorizer
+}
+
+// NewClient creates a new Client for Azure Blob Storage.
+func NewClient(config Config, userAuth UserAuthConfig) (*Client, error) {
+	if config.Account == "" && config.Endpoint == "" {
+		return nil, errors.New("invalid config: account required")
+	}
+	config.applyDefaults()
+	if config.Container == "" {
+		return nil, errors.New("invalid config: container required")
+	}
+	if !path.IsAbs(config.RootDirectory) {
+		return nil, errors.New("invalid config: root_directory must be absolute path")
+	}
+
+	pather, err := namepath.New(config.RootDirectory, config.NamePath)
+	if err != nil {
+		return nil, fmt.Errorf("namepath: %s", err)
+	}
+
+	var auth authorizer
+	if config.UseManagedIdentity {
+		auth = &managedIdentityAuthorizer{
+			endpoint: _imdsEndpoint,
+			clientID: config.ManagedIdentityClientID,
+			clk:      clock.New(),
+		}
+	} else {
+		if config.Username == "" {
+			return nil, errors.New("invalid config: username required")
+		}
+		a, ok := userAuth[config.Username]
+		if !ok {
+			return nil, errors.New("auth not configured for username")
+		}
+		auth, err = newSASAuthorizer(a.Azure.SASToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	log.Infof("Initalized Azure backend with config: %+v", config)
+	return &Client{config, pather, auth}, nil
+}
+
+// Stat returns blob info for name.
+func (c *Client) Stat(namespace, name string) (*core.BlobInfo, error) {
+	blob, err := c.blobName(name)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.send("HEAD", blob, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	size, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse content length: %s", err)
+	}
+	return core.NewBlobInfo(size), nil
+}
+
+// Download downloads the content from a configured container and writes the
+// data to dst.
+func (c *Client) Download(namespace, name string, dst io.Writer) error {
+	blob, err := c.blobName(name)
+	if err != nil {
+		return err
+	}
+	resp, err := c.send("GET", blob, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(dst, resp.Body); err != nil {
+		return fmt.Errorf("copy: %s", err)
+	}
+	return nil
+}
+
+// Upload uploads src to a configured container. Blobs which fit in a single
+// block are uploaded with one request, while larger blobs are uploaded block by
+// block and then committed.
+func (c *Client) Upload(namespace, name string, src io.Reader) error {
+	blob, err := c.blobName(name)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, c.config.UploadBlockSize)
+	var ids []string
+	for {
+		n, err := io.ReadFull(src, buf)
+		if err == io.EOF {
+			break
+		} else if err != nil && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("read: %s", err)
+		}
+		if len(ids) == 0 && n < len(buf) {
+			return c.putBlob(blob, buf[:n])
+		}
+		id := blockID(len(ids))
+		if err := c.putBlock(blob, id, buf[:n]); err != nil {
+			return fmt.Errorf("put block %d: %s", len(ids), err)
+		}
+		ids = append(ids, id)
+		if n < len(buf) {
+			break
+		}
+	}
+	if len(ids) == 0 {
+		// Empty blob.
+		return c.putBlob(blob, nil)
+	}
+	if err := c.putBlockList(blob, ids); err != nil {
+		return fmt.Errorf("put block list: %s", err)
+	}
+	return nil
+}
+
+func (c *Client) putBlob(blob string, b []byte) error {
+	resp, err := c.send("PUT", blob, nil, b, withHeader("x-ms-blob-type", "BlockBlob"))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// blockID returns the id of the i-th block of a blob. All block ids of a blob
+// must have the same length.
+func blockID(i int) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%08d", i)))
+}
+
+func (c *Client) putBlock(blob, id string, b []byte) error {
+	q := url.Values{}
+	q.Set("comp", "block")
+	q.Set("blockid", id)
+	resp, err := c.send("PUT", blob, q, b)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+type blockList struct {
+	XMLName xml.Name `xml:"BlockList"`
+	Latest  []string `xml:"Latest"`
+}
+
+func (c *Client) putBlockList(blob string, ids []string) error {
+	b, err := xml.Marshal(blockList{Latest: ids})
+	if err != nil {
+		return fmt.Errorf("marshal: %s", err)
+	}
+	q := url.Values{}
+	q.Set("comp", "blocklist")
+	resp, err := c.send("PUT", blob, q, b)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+type enumerationResults struct {
+	Blobs struct {
+		Blob []struct {
+			Name string `xml:"Name"`
+		} `xml:"Blob"`
+	} `xml:"Blobs"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// List lists names that start with prefix. If paginated, a single page is
+// returned along with the continuation token of the next page.
+func (c *Client) List(prefix string, opts ...backend.ListOption) (*backend.ListResult, error) {
+	options := backend.DefaultListOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	maxKeys := c.config.ListMaxKeys
+	marker := ""
+	if options.Paginated {
+		maxKeys = options.MaxKeys
+		marker = options.ContinuationToken
+	}
+
+	var names []string
+	for {
+		q := url.Values{}
+		q.Set("restype", "container")
+		q.Set("comp", "list")
+		q.Set("prefix", path.Join(c.pather.BasePath(), prefix)[1:])
+		q.Set("maxresults", strconv.Itoa(maxKeys))
+		if marker != "" {
+			q.Set("marker", marker)
+		}
+		page, err := c.listPage(q)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range page.Blobs.Blob {
+			name, err := c.pather.NameFromBlobPath(path.Join("/", b.Name))
+			if err != nil {
+				log.With("blob", b.Name).Errorf("Error converting blob path into name: %s", err)
+				continue
+			}
+			names = append(names, name)
+		}
+		marker = page.NextMarker
+		if options.Paginated || marker == "" {
+			break
+		}
+	}
+	return &backend.ListResult{
+		Names:             names,
+		ContinuationToken: marker,
+	}, nil
+}
+
+func (c *Client) listPage(q url.Values) (*enumerationResults, error) {
+	resp, err := c.send("GET", "", q, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var page enumerationResults
+	if err := xml.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("decode list: %s", err)
+	}
+	return &page, nil
+}
+
+// blobName returns the name of the blob which stores name within the
+// container.
+func (c *Client) blobName(name string) (string, error) {
+	p, err := c.pather.BlobPath(name)
+	if err != nil {
+		return "", fmt.Errorf("blob path: %s", err)
+	}
+	return strings.TrimPrefix(p, "/"), nil
+}
+
+type requestOption func(headers map[string]string)
+
+func withHeader(k, v string) requestOption {
+	return func(headers map[string]string) { headers[k] = v }
+}
+
+// send sends an authorized request against blob in the configured container,
+// or against the container itself if blob is empty.
+func (c *Client) send(
+	method, blob string, q url.Values, body []byte, opts ...requestOption) (*http.Response, error) {
+
+	u, err := url.Parse(c.config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parse endpoint: %s", err)
+	}
+	u.Path = path.Join("/", c.config.Container, blob)
+	if q == nil {
+		q = url.Values{}
+	}
+	headers := map[string]string{"x-ms-version": _apiVersion}
+	for _, opt := range opts {
+		opt(headers)
+	}
+	if err := c.auth.authorize(q, headers); err != nil {
+		return nil, err
+	}
+	u.RawQuery = q.Encode()
+
+	sendOpts := []httputil.SendOption{
+		httputil.SendHeaders(headers),
+		httputil.SendTimeout(c.config.Timeout),
+		httputil.SendAcceptedCodes(http.StatusOK, http.StatusCreated),
+		httputil.SendRetry(),
+	}
+	if body != nil {
+		sendOpts = append(sendOpts, httputil.SendBody(bytes.NewReader(body)))
+	}
+	resp, err := httputil.Send(method, u.String(), sendOpts...)
+	if err != nil {
+		if httputil.IsNotFound(err) && blob != "" {
+			return nil, backenderrors.ErrBlobNotFound
+		}
+		return nil, err
+	}
+	return resp, nil
+}
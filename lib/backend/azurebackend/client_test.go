@@ -0,0 +1,391 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package azurebackend
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/backend"
+	"github.com/uber/kraken/lib/backend/backenderrors"
+	"github.com/uber/kraken/lib/backend/namepath"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+)
+
+const _testSAS = "sv=2019-12-12&sig=abc"
+
+// fakeBlobService is an in-memory implementation of the subset of the blob
+// service REST API used by Client.
+type fakeBlobService struct {
+	t         *testing.T
+	container string
+
+	mu     sync.Mutex
+	blobs  map[string][]byte
+	blocks map[string][]byte
+	puts   int
+}
+
+func newFakeBlobService(t *testing.T, container string) *fakeBlobService {
+	return &fakeBlobService{
+		t:         t,
+		container: container,
+		blobs:     make(map[string][]byte),
+		blocks:    make(map[string][]byte),
+	}
+}
+
+func (s *fakeBlobService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q := r.URL.Query()
+	if q.Get("sig") == "" && r.Header.Get("Authorization") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if r.Header.Get("x-ms-version") == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if parts[0] != s.container {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if len(parts) == 1 {
+		s.list(w, r)
+		return
+	}
+	blob := parts[1]
+	body, err := ioutil.ReadAll(r.Body)
+	require.NoError(s.t, err)
+
+	switch {
+	case r.Method == "HEAD" || r.Method == "GET":
+		b, ok := s.blobs[blob]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+		if r.Method == "GET" {
+			w.Write(b)
+		}
+	case r.Method == "PUT" && q.Get("comp") == "block":
+		s.blocks[blob+"/"+q.Get("blockid")] = body
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "PUT" && q.Get("comp") == "blocklist":
+		var l blockList
+		require.NoError(s.t, xml.Unmarshal(body, &l))
+		var b []byte
+		for _, id := range l.Latest {
+			block, ok := s.blocks[blob+"/"+id]
+			if !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			b = append(b, block...)
+		}
+		s.blobs[blob] = b
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "PUT":
+		require.Equal(s.t, "BlockBlob", r.Header.Get("x-ms-blob-type"))
+		s.blobs[blob] = body
+		s.puts++
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *fakeBlobService) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	require.Equal(s.t, "container", q.Get("restype"))
+	require.Equal(s.t, "list", q.Get("comp"))
+	max, err := strconv.Atoi(q.Get("maxresults"))
+	require.NoError(s.t, err)
+
+	var names []string
+	for name := range s.blobs {
+		if strings.HasPrefix(name, q.Get("prefix")) && name > q.Get("marker") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var result enumerationResults
+	if len(names) > max {
+		names = names[:max]
+		result.NextMarker = names[max-1]
+	}
+	for _, name := range names {
+		result.Blobs.Blob = append(result.Blobs.Blob, struct {
+			Name string `xml:"Name"`
+		}{name})
+	}
+	b, err := xml.Marshal(result)
+	require.NoError(s.t, err)
+	w.Write(b)
+}
+
+func newTestClient(t *testing.T, config Config) (*Client, *fakeBlobService, func()) {
+	s := newFakeBlobService(t, "test-container")
+	server := httptest.NewServer(s)
+
+	config.Username = "test-user"
+	config.Container = "test-container"
+	config.Endpoint = server.URL
+	config.RootDirectory = "/root"
+	config.NamePath = namepath.Identity
+	auth := UserAuthConfig{"test-user": AuthConfig{}}
+	a := auth["test-user"]
+	a.Azure.SASToken = _testSAS
+	auth["test-user"] = a
+
+	client, err := NewClient(config, auth)
+	require.NoError(t, err)
+	return client, s, server.Close
+}
+
+func TestClientFactory(t *testing.T) {
+	require := require.New(t)
+
+	config := Config{
+		Username:      "test-user",
+		Account:       "account",
+		Container:     "test-container",
+		RootDirectory: "/root",
+		NamePath:      namepath.Identity,
+	}
+	auth := map[string]interface{}{
+		"test-user": map[string]interface{}{
+			"azure": map[string]interface{}{"sas_token": _testSAS},
+		},
+	}
+	f := factory{}
+	c, err := f.Create(config, auth)
+	require.NoError(err)
+	require.Equal("https://account.blob.core.windows.net", c.(*Client).config.Endpoint)
+}
+
+func TestNewClientInvalidConfig(t *testing.T) {
+	valid := func() Config {
+		return Config{
+			Username:      "test-user",
+			Account:       "account",
+			Container:     "test-container",
+			RootDirectory: "/root",
+			NamePath:      namepath.Identity,
+		}
+	}
+	auth := UserAuthConfig{"test-user": AuthConfig{}}
+	a := auth["test-user"]
+	a.Azure.SASToken = _testSAS
+	auth["test-user"] = a
+
+	tests := []struct {
+		desc   string
+		modify func(*Config)
+		auth   UserAuthConfig
+	}{
+		{"no account", func(c *Config) { c.Account = "" }, auth},
+		{"no container", func(c *Config) { c.Container = "" }, auth},
+		{"relative root directory", func(c *Config) { c.RootDirectory = "root" }, auth},
+		{"no username", func(c *Config) { c.Username = "" }, auth},
+		{"no auth for username", func(c *Config) {}, UserAuthConfig{}},
+		{"sas token without signature", func(c *Config) {}, UserAuthConfig{"test-user": AuthConfig{}}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			config := valid()
+			test.modify(&config)
+			_, err := NewClient(config, test.auth)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestClientUploadDownloadStat(t *testing.T) {
+	tests := []struct {
+		desc       string
+		size       int
+		puts       int
+		blockCount int
+	}{
+		{"empty", 0, 1, 0},
+		{"single block", 10, 1, 0},
+		{"exactly one block", 64, 0, 1},
+		{"many blocks", 200, 0, 4},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			client, s, cleanup := newTestClient(t, Config{UploadBlockSize: 64})
+			defer cleanup()
+
+			data := core.NewBlobFixture().Content
+			for len(data) < test.size {
+				data = append(data, data...)
+			}
+			data = data[:test.size]
+
+			require.NoError(client.Upload("", "a/b", bytes.NewReader(data)))
+			require.Equal(test.puts, s.puts)
+			require.Len(s.blocks, test.blockCount)
+
+			info, err := client.Stat("", "a/b")
+			require.NoError(err)
+			require.Equal(core.NewBlobInfo(int64(test.size)), info)
+
+			var b bytes.Buffer
+			require.NoError(client.Download("", "a/b", &b))
+			require.Equal(data, b.Bytes())
+		})
+	}
+}
+
+func TestClientBlobNotFound(t *testing.T) {
+	require := require.New(t)
+
+	client, _, cleanup := newTestClient(t, Config{})
+	defer cleanup()
+
+	_, err := client.Stat("", "a/b")
+	require.Equal(backenderrors.ErrBlobNotFound, err)
+
+	require.Equal(backenderrors.ErrBlobNotFound, client.Download("", "a/b", ioutil.Discard))
+}
+
+func TestClientList(t *testing.T) {
+	require := require.New(t)
+
+	client, _, cleanup := newTestClient(t, Config{ListMaxKeys: 2})
+	defer cleanup()
+
+	var expected []string
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("a/%d", i)
+		require.NoError(client.Upload("", name, bytes.NewReader([]byte("x"))))
+		expected = append(expected, name)
+	}
+	require.NoError(client.Upload("", "b/0", bytes.NewReader([]byte("x"))))
+
+	result, err := client.List("a")
+	require.NoError(err)
+	require.Equal(expected, result.Names)
+	require.Empty(result.ContinuationToken)
+
+	var names []string
+	var token string
+	var pages int
+	for {
+		result, err := client.List("a",
+			backend.ListWithPagination(),
+			backend.ListWithMaxKeys(3),
+			backend.ListWithContinuationToken(token))
+		require.NoError(err)
+		names = append(names, result.Names...)
+		pages++
+		token = result.ContinuationToken
+		if token == "" {
+			break
+		}
+	}
+	require.Equal(expected, names)
+	require.Equal(2, pages)
+}
+
+func TestManagedIdentityAuthorizerCachesToken(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	clk.Set(time.Unix(1000, 0))
+
+	var fetches int
+	imds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal("true", r.Header.Get("Metadata"))
+		require.Equal(_storageResource, r.URL.Query().Get("resource"))
+		require.Equal("some-client", r.URL.Query().Get("client_id"))
+		fetches++
+		fmt.Fprintf(w, `{"access_token": "token-%d", "expires_on": "%d"}`,
+			fetches, clk.Now().Add(time.Hour).Unix())
+	}))
+	defer imds.Close()
+
+	a := &managedIdentityAuthorizer{endpoint: imds.URL, clientID: "some-client", clk: clk}
+
+	headers := make(map[string]string)
+	require.NoError(a.authorize(nil, headers))
+	require.Equal("Bearer token-1", headers["Authorization"])
+
+	clk.Add(50 * time.Minute)
+	require.NoError(a.authorize(nil, headers))
+	require.Equal("Bearer token-1", headers["Authorization"])
+
+	// Tokens are refreshed shortly before they expire.
+	clk.Add(6 * time.Minute)
+	require.NoError(a.authorize(nil, headers))
+	require.Equal("Bearer token-2", headers["Authorization"])
+	require.Equal(2, fetches)
+}
+
+func TestClientManagedIdentity(t *testing.T) {
+	require := require.New(t)
+
+	imds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"access_token": "token", "expires_on": "%d"}`,
+			time.Now().Add(time.Hour).Unix())
+	}))
+	defer imds.Close()
+
+	client, _, cleanup := newTestClient(t, Config{UseManagedIdentity: true})
+	defer cleanup()
+	client.auth.(*managedIdentityAuthorizer).endpoint = imds.URL
+
+	require.NoError(client.Upload("", "a/b", bytes.NewReader([]byte("x"))))
+}
+
+func TestSASAuthorizerAddsToken(t *testing.T) {
+	require := require.New(t)
+
+	a, err := newSASAuthorizer("?" + _testSAS)
+	require.NoError(err)
+
+	q := make(map[string][]string)
+	require.NoError(a.authorize(q, nil))
+	require.Equal([]string{"abc"}, q["sig"])
+	require.Equal([]string{"2019-12-12"}, q["sv"])
+}
+
+func TestBlockIDsHaveEqualLength(t *testing.T) {
+	require.Equal(t, len(blockID(0)), len(blockID(12345)))
+	b, err := base64.StdEncoding.DecodeString(blockID(7))
+	require.NoError(t, err)
+	require.Equal(t, "00000007", string(b))
+}
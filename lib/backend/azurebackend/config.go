@@ -0,0 +1,82 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package azurebackend
+
+import (
+	"time"
+
+	"github.com/uber/kraken/lib/backend"
+)
+
+// Config defines azure connection specific
+// parameters and authetication credentials
+type Config struct {
+	Username  string `yaml:"username"`  // Username for selecting credentials.
+	Account   string `yaml:"account"`   // Storage account name
+	Container string `yaml:"container"` // Blob container
+
+	// Endpoint overrides the blob service endpoint of Account, which defaults
+	// to https://<account>.blob.core.windows.net.
+	Endpoint string `yaml:"endpoint"`
+
+	RootDirectory string `yaml:"root_directory"` // Azure root directory for docker images
+
+	// UploadBlockSize is the size of each block of a block blob upload. Blobs
+	// larger than a single block are uploaded block by block and committed
+	// with a block list, such that only one block is buffered in memory.
+	UploadBlockSize int64 `yaml:"upload_block_size"`
+
+	// ListMaxKeys sets the max keys returned per page.
+	ListMaxKeys int `yaml:"list_max_keys"`
+
+	// Timeout of each request to the blob service, including the transfer of a
+	// block or a whole download.
+	Timeout time.Duration `yaml:"timeout"`
+
+	// UseManagedIdentity authenticates with the managed identity of the host,
+	// via the instance metadata service, instead of the SAS token configured
+	// for Username. ManagedIdentityClientID optionally selects a user-assigned
+	// identity.
+	UseManagedIdentity      bool   `yaml:"use_managed_identity"`
+	ManagedIdentityClientID string `yaml:"managed_identity_client_id"`
+
+	// NamePath identifies which namepath.Pather to use.
+	NamePath string `yaml:"name_path"`
+}
+
+// UserAuthConfig defines authentication configuration overlayed by Langley.
+// Each key is the username of the credentials.
+type UserAuthConfig map[string]AuthConfig
+
+// AuthConfig matches Langley format.
+type AuthConfig struct {
+	Azure struct {
+		SASToken string `yaml:"sas_token"`
+	} `yaml:"azure"`
+}
+
+func (c *Config) applyDefaults() {
+	if c.UploadBlockSize == 0 {
+		c.UploadBlockSize = backend.DefaultPartSize
+	}
+	if c.ListMaxKeys == 0 {
+		c.ListMaxKeys = backend.DefaultListMaxKeys
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 15 * time.Minute
+	}
+	if c.Endpoint == "" {
+		c.Endpoint = "https://" + c.Account + ".blob.core.windows.net"
+	}
+}
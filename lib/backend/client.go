@@ -14,6 +14,7 @@
 package backend
 
 import (
+	"errors"
 	"fmt"
 	"io"
 
@@ -22,6 +23,9 @@ import (
 
 var _factories = make(map[string]ClientFactory)
 
+// ErrDeleteNotSupported is returned by Delete when a client cannot delete blobs.
+var ErrDeleteNotSupported = errors.New("backend does not support delete")
+
 // ClientFactory creates backend client given name.
 type ClientFactory interface {
 	Create(config interface{}, authConfig interface{}) (Client, error)
@@ -64,3 +68,20 @@ type Client interface {
 	// List lists entries whose names start with prefix.
 	List(prefix string, opts ...ListOption) (*ListResult, error)
 }
+
+// Deleter is implemented by clients which support deleting blobs. All
+// implementations should return backenderrors.ErrBlobNotFound when the blob was
+// not found.
+type Deleter interface {
+	Delete(namespace, name string) error
+}
+
+// Delete deletes name from client. Returns ErrDeleteNotSupported if client
+// does not implement Deleter.
+func Delete(client Client, namespace, name string) error {
+	d, ok := client.(Deleter)
+	if !ok {
+		return ErrDeleteNotSupported
+	}
+	return d.Delete(namespace, name)
+}
@@ -24,25 +24,41 @@ type Config struct {
 	Namespace string                 `yaml:"namespace"`
 	Backend   map[string]interface{} `yaml:"backend"`
 
+	// Fallbacks are additional backends for Namespace, in order of preference.
+	// Reads fall back to them when Backend fails or is missing the blob, and
+	// writes are mirrored to Backend and all fallbacks.
+	Fallbacks []map[string]interface{} `yaml:"fallbacks"`
+
 	// If enabled, throttles upload / download bandwidth.
 	Bandwidth bandwidth.Config `yaml:"bandwidth"`
+
+	// If enabled, retries transient errors and optionally trips a circuit
+	// breaker once the backend keeps failing.
+	Retry RetryConfig `yaml:"retry"`
 }
 
 func (c Config) applyDefaults() Config {
-	for k := range c.Backend {
-		// TODO: don't hard code backend client name
-		if k == "s3" {
-			if c.Bandwidth.IngressBitsPerSec == 0 {
-				c.Bandwidth.IngressBitsPerSec = 10 * 8 * memsize.Gbit
-			}
-			if c.Bandwidth.EgressBitsPerSec == 0 {
-				c.Bandwidth.EgressBitsPerSec = 8 * memsize.Gbit
+	for _, backend := range c.backends() {
+		for k := range backend {
+			// TODO: don't hard code backend client name
+			if k == "s3" {
+				if c.Bandwidth.IngressBitsPerSec == 0 {
+					c.Bandwidth.IngressBitsPerSec = 10 * 8 * memsize.Gbit
+				}
+				if c.Bandwidth.EgressBitsPerSec == 0 {
+					c.Bandwidth.EgressBitsPerSec = 8 * memsize.Gbit
+				}
 			}
 		}
 	}
 	return c
 }
 
+// backends returns Backend followed by Fallbacks.
+func (c Config) backends() []map[string]interface{} {
+	return append([]map[string]interface{}{c.Backend}, c.Fallbacks...)
+}
+
 // Auth defines auth credentials for corresponding namespaces.
 // It has to be different due to langley secrets overlay structure.
 type Auth map[string]AuthConfig
@@ -0,0 +1,169 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/backend/backenderrors"
+	"github.com/uber/kraken/utils/errutil"
+	"github.com/uber/kraken/utils/log"
+
+	"github.com/uber-go/tally"
+)
+
+// FailoverClient is a backend client over an ordered chain of backends, e.g.
+// a primary and its fallbacks. Reads are served by the first backend which
+// succeeds, falling back to the next one on errors and missing blobs, while
+// writes are mirrored to every backend.
+type FailoverClient struct {
+	clients []Client
+	stats   tally.Scope
+}
+
+// failover chains clients, in order of preference.
+func failover(clients []Client, stats tally.Scope) *FailoverClient {
+	return &FailoverClient{clients, stats}
+}
+
+// Clients returns the chained clients, in order of preference.
+func (c *FailoverClient) Clients() []Client {
+	return c.clients
+}
+
+func (c *FailoverClient) fellBack(op string, i int) {
+	if i > 0 {
+		c.stats.Tagged(map[string]string{"operation": op}).Counter("fallbacks").Inc(1)
+	}
+}
+
+// readError returns ErrBlobNotFound if every backend was missing the blob, and
+// the first other error otherwise.
+func readError(errs []error) error {
+	for _, err := range errs {
+		if err != backenderrors.ErrBlobNotFound {
+			return err
+		}
+	}
+	return backenderrors.ErrBlobNotFound
+}
+
+// Stat returns blob info for name from the first backend which has it.
+func (c *FailoverClient) Stat(namespace, name string) (*core.BlobInfo, error) {
+	var errs []error
+	for i, client := range c.clients {
+		info, err := client.Stat(namespace, name)
+		if err == nil {
+			c.fellBack("stat", i)
+			return info, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, readError(errs)
+}
+
+// Download downloads name into dst from the first backend which has it. A
+// failed download only falls back to the next backend if dst can be rewound or
+// nothing has been written to it yet.
+func (c *FailoverClient) Download(namespace, name string, dst io.Writer) error {
+	rewind, ok := seekRewinder(dst)
+	if !ok {
+		w := &countingWriter{w: dst}
+		dst = w
+		rewind = func() bool { return w.n == 0 }
+	}
+	var errs []error
+	for i, client := range c.clients {
+		err := client.Download(namespace, name, dst)
+		if err == nil {
+			c.fellBack("download", i)
+			return nil
+		}
+		errs = append(errs, err)
+		if i < len(c.clients)-1 && !rewind() {
+			log.With("namespace", namespace, "name", name).Errorf(
+				"Cannot fall back after partial download: %s", err)
+			return err
+		}
+	}
+	return readError(errs)
+}
+
+// Upload uploads src into name on every backend. src must be seekable, since
+// it is rewound before each upload. All backends are attempted even if some
+// fail, so a failed upload may leave name on a subset of them.
+func (c *FailoverClient) Upload(namespace, name string, src io.Reader) error {
+	rewind, ok := seekRewinder(src)
+	if !ok {
+		return errors.New("refusing mirrored upload: src does not implement io.Seeker")
+	}
+	var errs []error
+	for i, client := range c.clients {
+		if i > 0 && !rewind() {
+			errs = append(errs, errors.New("rewind src"))
+			break
+		}
+		if err := client.Upload(namespace, name, src); err != nil {
+			errs = append(errs, fmt.Errorf("backend %d: %w", i, err))
+		}
+	}
+	return errutil.Join(errs)
+}
+
+// List lists entries whose names start with prefix from the first backend
+// which succeeds.
+func (c *FailoverClient) List(prefix string, opts ...ListOption) (*ListResult, error) {
+	var errs []error
+	for i, client := range c.clients {
+		result, err := client.List(prefix, opts...)
+		if err == nil {
+			c.fellBack("list", i)
+			return result, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, readError(errs)
+}
+
+// Delete deletes name from every backend which supports deletion. Returns
+// ErrBlobNotFound only if name was missing from all of them.
+func (c *FailoverClient) Delete(namespace, name string) error {
+	var supported, notFound int
+	var errs []error
+	for i, client := range c.clients {
+		err := Delete(client, namespace, name)
+		if err == ErrDeleteNotSupported {
+			continue
+		}
+		supported++
+		if err == backenderrors.ErrBlobNotFound {
+			notFound++
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("backend %d: %w", i, err))
+		}
+	}
+	if supported == 0 {
+		return ErrDeleteNotSupported
+	}
+	if err := errutil.Join(errs); err != nil {
+		return err
+	}
+	if notFound == supported {
+		return backenderrors.ErrBlobNotFound
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package backend
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/backend/backenderrors"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+// memClient stores blobs in memory, failing every operation with err if set.
+type memClient struct {
+	blobs map[string]string
+	err   error
+}
+
+func newMemClient(blobs ...string) *memClient {
+	c := &memClient{blobs: make(map[string]string)}
+	for _, b := range blobs {
+		c.blobs[b] = b
+	}
+	return c
+}
+
+func (c *memClient) Stat(namespace, name string) (*core.BlobInfo, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	b, ok := c.blobs[name]
+	if !ok {
+		return nil, backenderrors.ErrBlobNotFound
+	}
+	return core.NewBlobInfo(int64(len(b))), nil
+}
+
+func (c *memClient) Upload(namespace, name string, src io.Reader) error {
+	if c.err != nil {
+		return c.err
+	}
+	b, err := ioutil.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	c.blobs[name] = string(b)
+	return nil
+}
+
+func (c *memClient) Download(namespace, name string, dst io.Writer) error {
+	if c.err != nil {
+		return c.err
+	}
+	b, ok := c.blobs[name]
+	if !ok {
+		return backenderrors.ErrBlobNotFound
+	}
+	_, err := io.WriteString(dst, b)
+	return err
+}
+
+func (c *memClient) List(prefix string, opts ...ListOption) (*ListResult, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	var names []string
+	for name := range c.blobs {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	return &ListResult{Names: names}, nil
+}
+
+func (c *memClient) Delete(namespace, name string) error {
+	if c.err != nil {
+		return c.err
+	}
+	if _, ok := c.blobs[name]; !ok {
+		return backenderrors.ErrBlobNotFound
+	}
+	delete(c.blobs, name)
+	return nil
+}
+
+func TestFailoverClientReadsFallBack(t *testing.T) {
+	require := require.New(t)
+
+	primary := newMemClient("a")
+	secondary := newMemClient("a", "b")
+	stats := tally.NewTestScope("", nil)
+	c := failover([]Client{primary, secondary}, stats)
+
+	var buf bytes.Buffer
+	require.NoError(c.Download("namespace", "a", &buf))
+	require.Equal("a", buf.String())
+
+	// Missing from primary.
+	buf.Reset()
+	require.NoError(c.Download("namespace", "b", &buf))
+	require.Equal("b", buf.String())
+	_, err := c.Stat("namespace", "b")
+	require.NoError(err)
+
+	// Primary unavailable.
+	primary.err = errors.New("some error")
+	_, err = c.Stat("namespace", "a")
+	require.NoError(err)
+	result, err := c.List("")
+	require.NoError(err)
+	require.ElementsMatch([]string{"a", "b"}, result.Names)
+
+	counters := stats.Snapshot().Counters()
+	require.Equal(int64(1), counters["fallbacks+operation=download"].Value())
+	require.Equal(int64(2), counters["fallbacks+operation=stat"].Value())
+	require.Equal(int64(1), counters["fallbacks+operation=list"].Value())
+}
+
+func TestFailoverClientReadErrors(t *testing.T) {
+	require := require.New(t)
+
+	primary := newMemClient()
+	secondary := newMemClient()
+	c := failover([]Client{primary, secondary}, tally.NoopScope)
+
+	_, err := c.Stat("namespace", "a")
+	require.Equal(backenderrors.ErrBlobNotFound, err)
+	require.Equal(backenderrors.ErrBlobNotFound, c.Download("namespace", "a", ioutil.Discard))
+
+	someErr := errors.New("some error")
+	secondary.err = someErr
+	_, err = c.Stat("namespace", "a")
+	require.Equal(someErr, err)
+}
+
+func TestFailoverClientDownloadDoesNotFallBackAfterPartialWrite(t *testing.T) {
+	require := require.New(t)
+
+	primary := &flakyClient{failures: 1, err: errors.New("some error"), content: "abc"}
+	secondary := newMemClient("a")
+	c := failover([]Client{primary, secondary}, tally.NoopScope)
+
+	var buf bytes.Buffer
+	require.Error(c.Download("namespace", "a", &buf))
+	require.Equal("a", buf.String())
+}
+
+func TestFailoverClientMirrorsWrites(t *testing.T) {
+	require := require.New(t)
+
+	primary := newMemClient()
+	secondary := newMemClient()
+	c := failover([]Client{primary, secondary}, tally.NoopScope)
+
+	require.NoError(c.Upload("namespace", "a", strings.NewReader("content")))
+	require.Equal("content", primary.blobs["a"])
+	require.Equal("content", secondary.blobs["a"])
+
+	require.Error(c.Upload("namespace", "b", ioutil.NopCloser(strings.NewReader("content"))))
+
+	// Failed mirrors fail the upload, but healthy backends still get the blob.
+	primary.err = errors.New("some error")
+	require.Error(c.Upload("namespace", "c", strings.NewReader("content")))
+	require.Equal("content", secondary.blobs["c"])
+	primary.err = nil
+
+	require.NoError(c.Delete("namespace", "a"))
+	require.Empty(primary.blobs["a"])
+	require.Empty(secondary.blobs["a"])
+	require.NoError(c.Delete("namespace", "c"))
+	require.Equal(backenderrors.ErrBlobNotFound, c.Delete("namespace", "c"))
+}
+
+func TestFailoverClientDeleteNotSupported(t *testing.T) {
+	// Hides NoopClient's Delete method.
+	c := failover([]Client{struct{ Client }{NoopClient{}}, struct{ Client }{NoopClient{}}}, tally.NoopScope)
+	require.Equal(t, ErrDeleteNotSupported, c.Delete("namespace", "name"))
+}
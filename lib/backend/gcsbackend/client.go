@@ -84,7 +84,7 @@ func NewClient(
 	config Config, userAuth UserAuthConfig, opts ...Option) (*Client, error) {
 
 	config.applyDefaults()
-	if config.Username == "" {
+	if config.Username == "" && !config.UseDefaultCredentials {
 		return nil, errors.New("invalid config: username required")
 	}
 	if config.Bucket == "" {
@@ -99,9 +99,13 @@ func NewClient(
 		return nil, fmt.Errorf("namepath: %s", err)
 	}
 
-	auth, ok := userAuth[config.Username]
-	if !ok {
-		return nil, errors.New("auth not configured for username")
+	var credentials []option.ClientOption
+	if !config.UseDefaultCredentials {
+		auth, ok := userAuth[config.Username]
+		if !ok {
+			return nil, errors.New("auth not configured for username")
+		}
+		credentials = append(credentials, option.WithCredentialsJSON([]byte(auth.GCS.AccessBlob)))
 	}
 
 	if len(opts) > 0 {
@@ -114,8 +118,7 @@ func NewClient(
 	}
 
 	ctx := context.Background()
-	sClient, err := storage.NewClient(ctx,
-		option.WithCredentialsJSON([]byte(auth.GCS.AccessBlob)))
+	sClient, err := storage.NewClient(ctx, credentials...)
 	if err != nil {
 		return nil, fmt.Errorf("invalid gcs credentials: %s", err)
 	}
@@ -244,20 +247,19 @@ func (g *GCSImpl) Download(objectName string, w io.Writer) (int64, error) {
 	}
 	defer rc.Close()
 
-	r, err := io.CopyN(w, rc, int64(g.config.BufferGuard))
-	if err != nil && err != io.EOF {
-		return 0, err
-	}
-
-	return r, nil
+	return io.Copy(w, rc)
 }
 
 func (g *GCSImpl) Upload(objectName string, r io.Reader) (int64, error) {
 	wc := g.bucket.Object(objectName).NewWriter(g.ctx)
+	// A non-zero chunk size makes the writer use a resumable upload session,
+	// where each chunk is retried individually on transient errors.
 	wc.ChunkSize = int(g.config.UploadChunkSize)
 
-	w, err := io.CopyN(wc, r, int64(g.config.UploadChunkSize))
-	if err != nil && err != io.EOF {
+	w, err := io.Copy(wc, r)
+	if err != nil {
+		// Closing the writer would commit the partial object.
+		wc.CloseWithError(err)
 		return 0, err
 	}
 
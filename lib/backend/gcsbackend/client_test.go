@@ -88,6 +88,30 @@ func TestClientFactory(t *testing.T) {
 	require.True(strings.Contains(err.Error(), "invalid gcs credentials"))
 }
 
+func TestNewClientUsernameRequiresAuth(t *testing.T) {
+	mocks, cleanup := newClientMocks(t)
+	defer cleanup()
+
+	_, err := NewClient(mocks.config, UserAuthConfig{}, WithGCS(mocks.gcs))
+	require.Error(t, err)
+
+	mocks.config.Username = ""
+	_, err = NewClient(mocks.config, mocks.userAuth, WithGCS(mocks.gcs))
+	require.Error(t, err)
+}
+
+func TestNewClientDefaultCredentials(t *testing.T) {
+	mocks, cleanup := newClientMocks(t)
+	defer cleanup()
+
+	// Neither a username nor auth is required.
+	mocks.config.Username = ""
+	mocks.config.UseDefaultCredentials = true
+
+	_, err := NewClient(mocks.config, nil, WithGCS(mocks.gcs))
+	require.NoError(t, err)
+}
+
 func TestClientStat(t *testing.T) {
 	require := require.New(t)
 
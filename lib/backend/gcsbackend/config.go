@@ -26,14 +26,23 @@ type Config struct {
 	Location string `yaml:"location"` // Location of the bucket. Defautls to "US".
 	Bucket   string `yaml:"bucket"`   // GCS bucket
 
-	RootDirectory   string `yaml:"root_directory"`   // GCS root directory for docker images
-	UploadChunkSize int64  `yaml:"upload_part_size"` // part size gcs manager uses for upload
+	RootDirectory string `yaml:"root_directory"` // GCS root directory for docker images
+
+	// UploadChunkSize is the size of each chunk of a resumable upload. Larger
+	// chunks need fewer requests, but buffer more memory per upload.
+	UploadChunkSize int64 `yaml:"upload_part_size"`
+
+	// UseDefaultCredentials authenticates with Application Default
+	// Credentials, e.g. the service account attached to the instance or the
+	// key file in GOOGLE_APPLICATION_CREDENTIALS, instead of the access blob
+	// configured for Username.
+	UseDefaultCredentials bool `yaml:"use_default_credentials"`
 
 	// ListMaxKeys sets the max keys returned per page.
 	ListMaxKeys int `yaml:"list_max_keys"`
 
-	// BufferGuard protects download from downloading into an oversized buffer
-	// when io.WriterAt is not implemented.
+	// BufferGuard is unused, since downloads are streamed. Kept for config
+	// compatibility with the s3 backend.
 	BufferGuard datasize.ByteSize `yaml:"buffer_guard"`
 
 	// NamePath identifies which namepath.Pather to use.
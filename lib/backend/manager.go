@@ -18,8 +18,12 @@ import (
 	"fmt"
 	"regexp"
 
+	"github.com/uber/kraken/tracing"
 	"github.com/uber/kraken/utils/bandwidth"
 	"github.com/uber/kraken/utils/log"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/uber-go/tally"
 )
 
 // Manager errors.
@@ -48,35 +52,44 @@ type Manager struct {
 	backends []*backend
 }
 
+// ManagerOption configures a Manager.
+type ManagerOption func(*managerOptions)
+
+type managerOptions struct {
+	stats tally.Scope
+}
+
+// WithStats configures the scope of backend client metrics, e.g. retries.
+func WithStats(stats tally.Scope) ManagerOption {
+	return func(o *managerOptions) { o.stats = stats }
+}
+
 // NewManager creates a new backend Manager.
-func NewManager(configs []Config, auth AuthConfig) (*Manager, error) {
+func NewManager(configs []Config, auth AuthConfig, opts ...ManagerOption) (*Manager, error) {
+	o := managerOptions{stats: tally.NoopScope}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	stats := o.stats.Tagged(map[string]string{
+		"module": "backend",
+	})
+
 	var backends []*backend
 	for _, config := range configs {
 		config = config.applyDefaults()
-		var c Client
-
-		if len(config.Backend) != 1 {
-			return nil, fmt.Errorf("no backend or more than one backend configured")
-		}
-		var name string
-		var backendConfig interface{}
-		for name, backendConfig = range config.Backend { // Pull the only key/value out of map
-		}
-		factory, err := getFactory(name)
-		if err != nil {
-			return nil, fmt.Errorf("get backend client factory: %s", err)
-		}
-		c, err = factory.Create(backendConfig, auth[name])
-		if err != nil {
-			return nil, fmt.Errorf("create backend client: %s", err)
-		}
-
-		if config.Bandwidth.Enable {
-			l, err := bandwidth.NewLimiter(config.Bandwidth)
+		var clients []Client
+		for _, backendConfig := range config.backends() {
+			c, err := newClient(backendConfig, config, auth, stats)
 			if err != nil {
-				return nil, fmt.Errorf("bandwidth: %s", err)
+				return nil, err
 			}
-			c = throttle(c, l)
+			clients = append(clients, c)
+		}
+		c := clients[0]
+		if len(clients) > 1 {
+			c = failover(clients, stats.Tagged(map[string]string{
+				"namespace": config.Namespace,
+			}))
 		}
 		b, err := newBackend(config.Namespace, c)
 		if err != nil {
@@ -87,22 +100,71 @@ func NewManager(configs []Config, auth AuthConfig) (*Manager, error) {
 	return &Manager{backends}, nil
 }
 
+// newClient creates the client of a single backend of config, i.e. either its
+// primary backend or one of its fallbacks.
+func newClient(
+	backendConfig map[string]interface{},
+	config Config,
+	auth AuthConfig,
+	stats tally.Scope) (Client, error) {
+
+	if len(backendConfig) != 1 {
+		return nil, fmt.Errorf("no backend or more than one backend configured")
+	}
+	var name string
+	var raw interface{}
+	for name, raw = range backendConfig { // Pull the only key/value out of map
+	}
+	factory, err := getFactory(name)
+	if err != nil {
+		return nil, fmt.Errorf("get backend client factory: %s", err)
+	}
+	c, err := factory.Create(raw, auth[name])
+	if err != nil {
+		return nil, fmt.Errorf("create backend client: %s", err)
+	}
+	if tracing.Enabled() {
+		c = trace(c, name)
+	}
+
+	if config.Retry.Enable {
+		c = retry(c, config.Retry, stats.Tagged(map[string]string{
+			"backend":   name,
+			"namespace": config.Namespace,
+		}), clock.New())
+	}
+	if config.Bandwidth.Enable {
+		l, err := bandwidth.NewLimiter(config.Bandwidth)
+		if err != nil {
+			return nil, fmt.Errorf("bandwidth: %s", err)
+		}
+		c = throttle(c, l)
+	}
+	return c, nil
+}
+
 // AdjustBandwidth adjusts bandwidth limits across all throttled clients to the
 // originally configured bandwidth divided by denominator.
 func (m *Manager) AdjustBandwidth(denominator int) error {
 	for _, b := range m.backends {
-		tc, ok := b.client.(*ThrottledClient)
-		if !ok {
-			continue
+		clients := []Client{b.client}
+		if fc, ok := b.client.(*FailoverClient); ok {
+			clients = fc.Clients()
 		}
-		if err := tc.adjustBandwidth(denominator); err != nil {
-			return err
+		for _, c := range clients {
+			tc, ok := c.(*ThrottledClient)
+			if !ok {
+				continue
+			}
+			if err := tc.adjustBandwidth(denominator); err != nil {
+				return err
+			}
+			log.With(
+				"namespace", b.regexp.String(),
+				"ingress", tc.IngressLimit(),
+				"egress", tc.EgressLimit(),
+				"denominator", denominator).Info("Adjusted backend bandwidth")
 		}
-		log.With(
-			"namespace", b.regexp.String(),
-			"ingress", tc.IngressLimit(),
-			"egress", tc.EgressLimit(),
-			"denominator", denominator).Info("Adjusted backend bandwidth")
 	}
 	return nil
 }
@@ -25,6 +25,7 @@ import (
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
 	"gopkg.in/yaml.v2"
 )
 
@@ -154,3 +155,93 @@ func TestManagerBandwidth(t *testing.T) {
 
 	checkBandwidth(5, 25)
 }
+
+func TestManagerRetry(t *testing.T) {
+	require := require.New(t)
+
+	m, err := NewManager([]Config{{
+		Namespace: ".*",
+		Bandwidth: bandwidth.Config{
+			EgressBitsPerSec:  10,
+			IngressBitsPerSec: 50,
+			TokenSize:         1,
+			Enable:            true,
+		},
+		Retry: RetryConfig{Enable: true},
+		Backend: map[string]interface{}{
+			"testfs": testfs.Config{Addr: "test-addr", NamePath: namepath.Identity},
+		},
+	}}, AuthConfig{}, WithStats(tally.NoopScope))
+	require.NoError(err)
+
+	c, err := m.GetClient("foo")
+	require.NoError(err)
+	tc, ok := c.(*ThrottledClient)
+	require.True(ok)
+	_, ok = tc.Client.(*RetryClient)
+	require.True(ok)
+}
+
+func TestManagerFallbacks(t *testing.T) {
+	require := require.New(t)
+
+	configStr := `
+- namespace: team-a/.*
+  bandwidth:
+      enable: true
+      egress_bits_per_sec: 10
+      ingress_bits_per_sec: 50
+      token_size: 1
+  backend:
+      testfs:
+          addr: testfs-primary
+          name_path: identity
+  fallbacks:
+      - testfs:
+          addr: testfs-secondary
+          name_path: identity
+- namespace: .*
+  backend:
+      testfs:
+          addr: testfs-default
+          name_path: identity
+`
+	var configs []Config
+	require.NoError(yaml.Unmarshal([]byte(configStr), &configs))
+
+	m, err := NewManager(configs, AuthConfig{})
+	require.NoError(err)
+
+	c, err := m.GetClient("team-a/foo")
+	require.NoError(err)
+	fc, ok := c.(*FailoverClient)
+	require.True(ok)
+
+	var addrs []string
+	for _, c := range fc.Clients() {
+		tc, ok := c.(*ThrottledClient)
+		require.True(ok)
+		addrs = append(addrs, tc.Client.(*testfs.Client).Addr())
+	}
+	require.Equal([]string{"testfs-primary", "testfs-secondary"}, addrs)
+
+	require.NoError(m.AdjustBandwidth(2))
+	for _, c := range fc.Clients() {
+		require.Equal(int64(5), c.(*ThrottledClient).EgressLimit())
+	}
+
+	c, err = m.GetClient("team-b/foo")
+	require.NoError(err)
+	require.Equal("testfs-default", c.(*testfs.Client).Addr())
+}
+
+func TestManagerInvalidFallback(t *testing.T) {
+	_, err := NewManager([]Config{{
+		Namespace: ".*",
+		Backend: map[string]interface{}{
+			"testfs": testfs.Config{Addr: "test-addr", NamePath: namepath.Identity},
+		},
+		Fallbacks: []map[string]interface{}{{}},
+	}}, AuthConfig{})
+	require.Error(t, err)
+}
@@ -48,3 +48,8 @@ func (c NoopClient) Download(namespace, name string, dst io.Writer) error {
 func (c NoopClient) List(prefix string, opts ...ListOption) (*ListResult, error) {
 	return nil, nil
 }
+
+// Delete always returns nil.
+func (c NoopClient) Delete(namespace, name string) error {
+	return nil
+}
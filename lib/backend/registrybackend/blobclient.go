@@ -75,6 +75,7 @@ func NewBlobClient(config Config) (*BlobClient, error) {
 
 // Stat sends a HEAD request to registry for a blob and returns the blob size.
 func (c *BlobClient) Stat(namespace, name string) (*core.BlobInfo, error) {
+	namespace = c.config.upstreamRepo(namespace)
 	opts, err := c.authenticator.Authenticate(namespace)
 	if err != nil {
 		return nil, fmt.Errorf("get security opt: %s", err)
@@ -91,6 +92,7 @@ func (c *BlobClient) Stat(namespace, name string) (*core.BlobInfo, error) {
 
 // Download gets a blob from registry.
 func (c *BlobClient) Download(namespace, name string, dst io.Writer) error {
+	namespace = c.config.upstreamRepo(namespace)
 	opts, err := c.authenticator.Authenticate(namespace)
 	if err != nil {
 		return fmt.Errorf("get security opt: %s", err)
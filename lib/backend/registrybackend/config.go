@@ -14,6 +14,7 @@
 package registrybackend
 
 import (
+	"strings"
 	"time"
 
 	"github.com/uber/kraken/lib/backend/registrybackend/security"
@@ -24,6 +25,11 @@ type Config struct {
 	Address  string          `yaml:"address"`
 	Timeout  time.Duration   `yaml:"timeout"`
 	Security security.Config `yaml:"security"`
+
+	// RepositoryPrefix is stripped from repositories before querying the
+	// registry, e.g. when agents mirror several upstream registries under
+	// prefixed repositories.
+	RepositoryPrefix string `yaml:"repository_prefix"`
 }
 
 // Set default configuration
@@ -33,3 +39,8 @@ func (c Config) applyDefaults() Config {
 	}
 	return c
 }
+
+// upstreamRepo returns the registry repository of Kraken repository repo.
+func (c Config) upstreamRepo(repo string) string {
+	return strings.TrimPrefix(repo, c.RepositoryPrefix)
+}
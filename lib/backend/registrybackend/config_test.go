@@ -14,7 +14,13 @@
 
 package registrybackend
 
-import "github.com/uber/kraken/lib/backend/registrybackend/security"
+import (
+	"testing"
+
+	"github.com/uber/kraken/lib/backend/registrybackend/security"
+
+	"github.com/stretchr/testify/require"
+)
 
 func newTestConfig(addr string) Config {
 	return Config{
@@ -24,3 +30,10 @@ func newTestConfig(addr string) Config {
 		},
 	}
 }
+
+func TestConfigUpstreamRepo(t *testing.T) {
+	c := Config{RepositoryPrefix: "gcr.io/"}
+	require.Equal(t, "foo/bar", c.upstreamRepo("gcr.io/foo/bar"))
+	require.Equal(t, "foo/bar", c.upstreamRepo("foo/bar"))
+	require.Equal(t, "foo/bar", Config{}.upstreamRepo("foo/bar"))
+}
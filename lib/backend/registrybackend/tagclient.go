@@ -79,7 +79,7 @@ func (c *TagClient) Stat(namespace, name string) (*core.BlobInfo, error) {
 	if len(tokens) != 2 {
 		return nil, fmt.Errorf("invald name %s: must be repo:tag", name)
 	}
-	repo, tag := tokens[0], tokens[1]
+	repo, tag := c.config.upstreamRepo(tokens[0]), tokens[1]
 
 	opts, err := c.authenticator.Authenticate(repo)
 	if err != nil {
@@ -115,7 +115,7 @@ func (c *TagClient) Download(namespace, name string, dst io.Writer) error {
 	if len(tokens) != 2 {
 		return fmt.Errorf("invald name %s: must be repo:tag", name)
 	}
-	repo, tag := tokens[0], tokens[1]
+	repo, tag := c.config.upstreamRepo(tokens[0]), tokens[1]
 
 	opts, err := c.authenticator.Authenticate(repo)
 	if err != nil {
@@ -0,0 +1,345 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package backend
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/backend/backenderrors"
+	"github.com/uber/kraken/utils/httputil"
+	"github.com/uber/kraken/utils/log"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/cenkalti/backoff"
+	"github.com/uber-go/tally"
+)
+
+// ErrCircuitOpen is returned by a RetryClient while its circuit breaker is
+// open, i.e. while the backend is considered unavailable.
+var ErrCircuitOpen = errors.New("backend circuit breaker open")
+
+// RetryConfig configures retries of transient backend errors.
+type RetryConfig struct {
+	Enable bool `yaml:"enable"`
+
+	// MaxAttempts is the max number of attempts per operation, including the
+	// first one.
+	MaxAttempts int `yaml:"max_attempts"`
+
+	// Backoff between attempts grows exponentially from InitialBackoff by
+	// Multiplier up to MaxBackoff, randomized by +/- Jitter.
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	Multiplier     float64       `yaml:"multiplier"`
+	Jitter         float64       `yaml:"jitter"`
+
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"`
+}
+
+// CircuitBreakerConfig configures failing fast on backends which keep failing.
+type CircuitBreakerConfig struct {
+	Enable bool `yaml:"enable"`
+
+	// FailureThreshold is the number of consecutive failed operations after
+	// which the breaker opens.
+	FailureThreshold int `yaml:"failure_threshold"`
+
+	// ResetTimeout is how long the breaker stays open before letting a single
+	// operation through to probe the backend.
+	ResetTimeout time.Duration `yaml:"reset_timeout"`
+}
+
+func (c RetryConfig) applyDefaults() RetryConfig {
+	if c.MaxAttempts == 0 {
+		c.MaxAttempts = 3
+	}
+	if c.InitialBackoff == 0 {
+		c.InitialBackoff = 500 * time.Millisecond
+	}
+	if c.MaxBackoff == 0 {
+		c.MaxBackoff = 10 * time.Second
+	}
+	if c.Multiplier == 0 {
+		c.Multiplier = 2
+	}
+	if c.Jitter == 0 {
+		c.Jitter = 0.2
+	}
+	if c.CircuitBreaker.FailureThreshold == 0 {
+		c.CircuitBreaker.FailureThreshold = 5
+	}
+	if c.CircuitBreaker.ResetTimeout == 0 {
+		c.CircuitBreaker.ResetTimeout = 30 * time.Second
+	}
+	return c
+}
+
+// IsRetryable returns true if err is a transient backend error. Missing blobs
+// and client errors are never retried.
+func IsRetryable(err error) bool {
+	if err == nil ||
+		err == backenderrors.ErrBlobNotFound ||
+		err == ErrCircuitOpen ||
+		err == ErrDeleteNotSupported {
+		return false
+	}
+	if statusErr, ok := err.(httputil.StatusError); ok {
+		return statusErr.Status >= 500 ||
+			statusErr.Status == http.StatusTooManyRequests ||
+			statusErr.Status == http.StatusRequestTimeout
+	}
+	return true
+}
+
+// RetryClient is a backend client which retries transient errors with
+// exponential backoff, and optionally fails fast via a circuit breaker once
+// the backend keeps failing.
+type RetryClient struct {
+	Client
+	config  RetryConfig
+	breaker *circuitBreaker
+	stats   tally.Scope
+	sleep   func(time.Duration)
+}
+
+// retry wraps client with retries.
+func retry(client Client, config RetryConfig, stats tally.Scope, clk clock.Clock) *RetryClient {
+	config = config.applyDefaults()
+	var breaker *circuitBreaker
+	if config.CircuitBreaker.Enable {
+		breaker = newCircuitBreaker(config.CircuitBreaker, stats, clk)
+	}
+	return &RetryClient{client, config, breaker, stats, clk.Sleep}
+}
+
+// Stat returns blob info for name.
+func (c *RetryClient) Stat(namespace, name string) (*core.BlobInfo, error) {
+	var info *core.BlobInfo
+	err := c.do("stat", nil, func() error {
+		var err error
+		info, err = c.Client.Stat(namespace, name)
+		return err
+	})
+	return info, err
+}
+
+// Upload uploads src into name. Failed uploads are only retried if src can be
+// rewound or has not been read yet.
+func (c *RetryClient) Upload(namespace, name string, src io.Reader) error {
+	rewind, ok := seekRewinder(src)
+	if !ok {
+		r := &countingReader{r: src}
+		src = r
+		rewind = func() bool { return r.n == 0 }
+	}
+	return c.do("upload", rewind, func() error {
+		return c.Client.Upload(namespace, name, src)
+	})
+}
+
+// Download downloads name into dst. Failed downloads are only retried if dst
+// can be rewound or nothing has been written to it yet.
+func (c *RetryClient) Download(namespace, name string, dst io.Writer) error {
+	rewind, ok := seekRewinder(dst)
+	if !ok {
+		w := &countingWriter{w: dst}
+		dst = w
+		rewind = func() bool { return w.n == 0 }
+	}
+	return c.do("download", rewind, func() error {
+		return c.Client.Download(namespace, name, dst)
+	})
+}
+
+// List lists entries whose names start with prefix.
+func (c *RetryClient) List(prefix string, opts ...ListOption) (*ListResult, error) {
+	var result *ListResult
+	err := c.do("list", nil, func() error {
+		var err error
+		result, err = c.Client.List(prefix, opts...)
+		return err
+	})
+	return result, err
+}
+
+// Delete deletes name, if the underlying client supports deletion.
+func (c *RetryClient) Delete(namespace, name string) error {
+	if _, ok := c.Client.(Deleter); !ok {
+		return ErrDeleteNotSupported
+	}
+	return c.do("delete", nil, func() error {
+		return Delete(c.Client, namespace, name)
+	})
+}
+
+// do runs f until it succeeds, fails with a non-retryable error or runs out of
+// attempts. If non-nil, rewind is called before each retry and returns false
+// if f cannot be safely retried.
+func (c *RetryClient) do(op string, rewind func() bool, f func() error) error {
+	if c.breaker != nil && !c.breaker.allow() {
+		return ErrCircuitOpen
+	}
+	stats := c.stats.Tagged(map[string]string{"operation": op})
+
+	b := &backoff.ExponentialBackOff{
+		InitialInterval:     c.config.InitialBackoff,
+		RandomizationFactor: c.config.Jitter,
+		Multiplier:          c.config.Multiplier,
+		MaxInterval:         c.config.MaxBackoff,
+		Clock:               backoff.SystemClock,
+	}
+	b.Reset()
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = f()
+		if !IsRetryable(err) {
+			break
+		}
+		if attempt == c.config.MaxAttempts {
+			stats.Counter("retries_exhausted").Inc(1)
+			break
+		}
+		if rewind != nil && !rewind() {
+			break
+		}
+		d := b.NextBackOff()
+		log.With("operation", op, "attempt", attempt, "backoff", d).Infof(
+			"Retrying backend error: %s", err)
+		stats.Counter("retries").Inc(1)
+		c.sleep(d)
+	}
+	if c.breaker != nil {
+		c.breaker.record(!IsRetryable(err))
+	}
+	return err
+}
+
+// seekRewinder returns a function which rewinds x to its current offset, if x
+// is seekable. Seekable readers and writers are passed through unwrapped, since
+// drivers may rely on them for concurrent transfers.
+func seekRewinder(x interface{}) (func() bool, bool) {
+	s, ok := x.(io.Seeker)
+	if !ok {
+		return nil, false
+	}
+	offset, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		// Not actually seekable, e.g. a pipe.
+		return nil, false
+	}
+	return func() bool {
+		_, err := s.Seek(offset, io.SeekStart)
+		return err == nil
+	}, true
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// Circuit breaker states, as emitted by the circuit_breaker_state gauge.
+const (
+	_breakerClosed   = 0
+	_breakerOpen     = 1
+	_breakerHalfOpen = 2
+)
+
+// circuitBreaker opens after a number of consecutive failures, rejecting all
+// operations until a reset timeout passes. A single probe is then let through,
+// which either closes the breaker again or re-opens it.
+type circuitBreaker struct {
+	config CircuitBreakerConfig
+	stats  tally.Scope
+	clk    clock.Clock
+
+	mu       sync.Mutex
+	state    int
+	failures int
+	openedAt time.Time
+}
+
+func newCircuitBreaker(
+	config CircuitBreakerConfig, stats tally.Scope, clk clock.Clock) *circuitBreaker {
+
+	b := &circuitBreaker{config: config, stats: stats, clk: clk}
+	b.stats.Gauge("circuit_breaker_state").Update(_breakerClosed)
+	return b
+}
+
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case _breakerOpen:
+		if b.clk.Now().Sub(b.openedAt) < b.config.ResetTimeout {
+			return false
+		}
+		b.setState(_breakerHalfOpen)
+		return true
+	case _breakerHalfOpen:
+		// Probe in flight.
+		return false
+	default:
+		return true
+	}
+}
+
+func (b *circuitBreaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		b.failures = 0
+		b.setState(_breakerClosed)
+		return
+	}
+	b.failures++
+	if b.state == _breakerHalfOpen || b.failures >= b.config.FailureThreshold {
+		if b.state != _breakerOpen {
+			b.stats.Counter("circuit_breaker_trips").Inc(1)
+		}
+		b.openedAt = b.clk.Now()
+		b.setState(_breakerOpen)
+	}
+}
+
+func (b *circuitBreaker) setState(s int) {
+	b.state = s
+	b.stats.Gauge("circuit_breaker_state").Update(float64(s))
+}
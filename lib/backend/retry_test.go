@@ -0,0 +1,279 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package backend
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/backend/backenderrors"
+	"github.com/uber/kraken/utils/httputil"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+// flakyClient fails the first failures calls of each operation with err.
+type flakyClient struct {
+	NoopClient
+	failures int
+	err      error
+	calls    int
+	content  string
+}
+
+func (c *flakyClient) fail() error {
+	c.calls++
+	if c.calls <= c.failures {
+		return c.err
+	}
+	return nil
+}
+
+func (c *flakyClient) Stat(namespace, name string) (*core.BlobInfo, error) {
+	if err := c.fail(); err != nil {
+		return nil, err
+	}
+	return core.NewBlobInfo(int64(len(c.content))), nil
+}
+
+func (c *flakyClient) Upload(namespace, name string, src io.Reader) error {
+	b, err := ioutil.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	if err := c.fail(); err != nil {
+		return err
+	}
+	c.content = string(b)
+	return nil
+}
+
+func (c *flakyClient) Download(namespace, name string, dst io.Writer) error {
+	// Write partial content before failing.
+	if _, err := io.WriteString(dst, c.content[:1]); err != nil {
+		return err
+	}
+	if err := c.fail(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(dst, c.content[1:])
+	return err
+}
+
+func newTestRetryClient(
+	c Client, config RetryConfig, clk clock.Clock) (*RetryClient, tally.TestScope, *[]time.Duration) {
+
+	stats := tally.NewTestScope("", nil)
+	rc := retry(c, config, stats, clk)
+	var sleeps []time.Duration
+	rc.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	return rc, stats, &sleeps
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		desc     string
+		err      error
+		expected bool
+	}{
+		{"nil", nil, false},
+		{"blob not found", backenderrors.ErrBlobNotFound, false},
+		{"circuit open", ErrCircuitOpen, false},
+		{"delete not supported", ErrDeleteNotSupported, false},
+		{"bad request", httputil.StatusError{Status: http.StatusBadRequest}, false},
+		{"forbidden", httputil.StatusError{Status: http.StatusForbidden}, false},
+		{"too many requests", httputil.StatusError{Status: http.StatusTooManyRequests}, true},
+		{"internal error", httputil.StatusError{Status: http.StatusInternalServerError}, true},
+		{"other error", errors.New("connection reset"), true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			require.Equal(t, test.expected, IsRetryable(test.err))
+		})
+	}
+}
+
+func TestDeleteNotSupported(t *testing.T) {
+	require := require.New(t)
+
+	// Hides NoopClient's Delete method.
+	c := struct{ Client }{NoopClient{}}
+
+	require.Equal(ErrDeleteNotSupported, Delete(c, "namespace", "name"))
+
+	rc, _, _ := newTestRetryClient(c, RetryConfig{}, clock.New())
+	require.Equal(ErrDeleteNotSupported, rc.Delete("namespace", "name"))
+
+	require.NoError(Delete(NoopClient{}, "namespace", "name"))
+}
+
+func TestRetryClientRetriesTransientErrors(t *testing.T) {
+	require := require.New(t)
+
+	c := &flakyClient{failures: 2, err: errors.New("some error"), content: "abc"}
+	rc, stats, sleeps := newTestRetryClient(c, RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: time.Second,
+		Multiplier:     2,
+		Jitter:         0.1,
+	}, clock.New())
+
+	info, err := rc.Stat("", "a")
+	require.NoError(err)
+	require.Equal(core.NewBlobInfo(3), info)
+	require.Equal(3, c.calls)
+
+	require.Len(*sleeps, 2)
+	require.InDelta(time.Second, (*sleeps)[0], float64(100*time.Millisecond))
+	require.InDelta(2*time.Second, (*sleeps)[1], float64(200*time.Millisecond))
+
+	counters := stats.Snapshot().Counters()
+	require.Equal(int64(2), counters["retries+operation=stat"].Value())
+}
+
+func TestRetryClientGivesUp(t *testing.T) {
+	tests := []struct {
+		desc          string
+		err           error
+		expectedCalls int
+	}{
+		{"retryable", errors.New("some error"), 3},
+		{"not retryable", httputil.StatusError{Status: http.StatusForbidden}, 1},
+		{"not found", backenderrors.ErrBlobNotFound, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			c := &flakyClient{failures: 5, err: test.err}
+			rc, _, _ := newTestRetryClient(c, RetryConfig{MaxAttempts: 3}, clock.New())
+
+			_, err := rc.Stat("", "a")
+			require.Equal(test.err, err)
+			require.Equal(test.expectedCalls, c.calls)
+		})
+	}
+}
+
+func TestRetryClientUploadRewindsSeekableSource(t *testing.T) {
+	require := require.New(t)
+
+	c := &flakyClient{failures: 1, err: errors.New("some error")}
+	rc, _, _ := newTestRetryClient(c, RetryConfig{}, clock.New())
+
+	src := strings.NewReader("xabc")
+	_, err := src.Seek(1, io.SeekStart)
+	require.NoError(err)
+
+	require.NoError(rc.Upload("", "a", src))
+	require.Equal("abc", c.content)
+	require.Equal(2, c.calls)
+}
+
+func TestRetryClientUploadDoesNotRetryConsumedSource(t *testing.T) {
+	require := require.New(t)
+
+	c := &flakyClient{failures: 1, err: errors.New("some error")}
+	rc, _, _ := newTestRetryClient(c, RetryConfig{}, clock.New())
+
+	// Hide the io.Seeker implementation.
+	src := struct{ io.Reader }{strings.NewReader("abc")}
+
+	require.Error(rc.Upload("", "a", src))
+	require.Equal(1, c.calls)
+}
+
+func TestRetryClientDownloadDoesNotRetryPartialWrites(t *testing.T) {
+	require := require.New(t)
+
+	c := &flakyClient{failures: 1, err: errors.New("some error"), content: "abc"}
+	rc, _, _ := newTestRetryClient(c, RetryConfig{}, clock.New())
+
+	var b bytes.Buffer
+	require.Error(rc.Download("", "a", &b))
+	require.Equal(1, c.calls)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	c := &flakyClient{failures: 4, err: errors.New("some error")}
+	rc, stats, _ := newTestRetryClient(c, RetryConfig{
+		MaxAttempts: 1,
+		CircuitBreaker: CircuitBreakerConfig{
+			Enable:           true,
+			FailureThreshold: 2,
+			ResetTimeout:     time.Minute,
+		},
+	}, clk)
+
+	state := func() float64 {
+		return stats.Snapshot().Gauges()["circuit_breaker_state+"].Value()
+	}
+
+	_, err := rc.Stat("", "a")
+	require.Error(err)
+	require.Equal(float64(_breakerClosed), state())
+	_, err = rc.Stat("", "a")
+	require.Error(err)
+	require.Equal(float64(_breakerOpen), state())
+
+	// Fails fast without calling the backend.
+	_, err = rc.Stat("", "a")
+	require.Equal(ErrCircuitOpen, err)
+	require.Equal(2, c.calls)
+
+	// A failed probe re-opens the breaker.
+	clk.Add(time.Minute)
+	_, err = rc.Stat("", "a")
+	require.Error(err)
+	require.NotEqual(ErrCircuitOpen, err)
+	_, err = rc.Stat("", "a")
+	require.Equal(ErrCircuitOpen, err)
+
+	// A successful probe closes it.
+	c.failures = 3
+	clk.Add(time.Minute)
+	_, err = rc.Stat("", "a")
+	require.NoError(err)
+	require.Equal(float64(_breakerClosed), state())
+	_, err = rc.Stat("", "a")
+	require.NoError(err)
+
+	require.Equal(int64(2), stats.Snapshot().Counters()["circuit_breaker_trips+"].Value())
+}
+
+func TestCircuitBreakerIgnoresNonRetryableErrors(t *testing.T) {
+	require := require.New(t)
+
+	c := &flakyClient{failures: 5, err: backenderrors.ErrBlobNotFound}
+	rc, _, _ := newTestRetryClient(c, RetryConfig{
+		CircuitBreaker: CircuitBreakerConfig{Enable: true, FailureThreshold: 1},
+	}, clock.NewMock())
+
+	for i := 0; i < 5; i++ {
+		_, err := rc.Stat("", "a")
+		require.Equal(backenderrors.ErrBlobNotFound, err)
+	}
+}
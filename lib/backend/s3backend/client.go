@@ -168,6 +168,23 @@ func (c *Client) Stat(namespace, name string) (*core.BlobInfo, error) {
 	return core.NewBlobInfo(size), nil
 }
 
+// Delete deletes name from the configured bucket.
+func (c *Client) Delete(namespace, name string) error {
+	path, err := c.pather.BlobPath(name)
+	if err != nil {
+		return fmt.Errorf("blob path: %s", err)
+	}
+	// DeleteObject succeeds for missing keys, so check existence first.
+	if _, err := c.Stat(namespace, name); err != nil {
+		return err
+	}
+	_, err = c.s3.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(c.config.Bucket),
+		Key:    aws.String(path),
+	})
+	return err
+}
+
 // Download downloads the content from a configured bucket and writes the
 // data to dst.
 func (c *Client) Download(namespace, name string, dst io.Writer) error {
@@ -204,12 +221,19 @@ func (c *Client) Download(namespace, name string, dst io.Writer) error {
 	return nil
 }
 
-// Upload uploads src to a configured bucket.
+// Upload uploads src to a configured bucket. Seekable sources larger than a
+// single part are uploaded with resumable multipart uploads.
 func (c *Client) Upload(namespace, name string, src io.Reader) error {
 	path, err := c.pather.BlobPath(name)
 	if err != nil {
 		return fmt.Errorf("blob path: %s", err)
 	}
+	if r, ok := src.(resumableSource); ok && !c.config.DisableUploadResume {
+		s, err := section(r)
+		if err == nil && s.Size() > c.config.UploadPartSize {
+			return c.multipartUpload(path, s)
+		}
+	}
 	input := &s3manager.UploadInput{
 		Bucket: aws.String(c.config.Bucket),
 		Key:    aws.String(path),
@@ -15,7 +15,13 @@ package s3backend
 
 import (
 	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/lib/backend"
@@ -104,6 +110,30 @@ func TestClientStat(t *testing.T) {
 	require.Equal(core.NewBlobInfo(100), info)
 }
 
+func TestClientDelete(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newClientMocks(t)
+	defer cleanup()
+
+	client := mocks.new()
+
+	var length int64 = 100
+
+	gomock.InOrder(
+		mocks.s3.EXPECT().HeadObject(&s3.HeadObjectInput{
+			Bucket: aws.String("test-bucket"),
+			Key:    aws.String("/root/test"),
+		}).Return(&s3.HeadObjectOutput{ContentLength: &length}, nil),
+		mocks.s3.EXPECT().DeleteObject(&s3.DeleteObjectInput{
+			Bucket: aws.String("test-bucket"),
+			Key:    aws.String("/root/test"),
+		}).Return(&s3.DeleteObjectOutput{}, nil),
+	)
+
+	require.NoError(client.Delete(core.NamespaceFixture(), "test"))
+}
+
 func TestClientDownload(t *testing.T) {
 	require := require.New(t)
 
@@ -287,3 +317,204 @@ func TestClientListPaginated(t *testing.T) {
 	require.Equal([]string{"test/c", "test/d"}, result.Names)
 	require.Equal("", result.ContinuationToken)
 }
+
+func md5ETag(b []byte) string {
+	sum := md5.Sum(b)
+	return fmt.Sprintf("%q", hex.EncodeToString(sum[:]))
+}
+
+// expectUploadParts expects n parts to be uploaded, and returns the uploaded
+// content of each part number.
+func (m *clientMocks) expectUploadParts(n int) map[int64][]byte {
+	var mu sync.Mutex
+	uploaded := make(map[int64][]byte)
+	for i := 0; i < n; i++ {
+		m.s3.EXPECT().UploadPart(gomock.Any()).DoAndReturn(
+			func(input *s3.UploadPartInput) (*s3.UploadPartOutput, error) {
+				b, err := ioutil.ReadAll(input.Body)
+				if err != nil {
+					return nil, err
+				}
+				mu.Lock()
+				uploaded[*input.PartNumber] = b
+				mu.Unlock()
+				return &s3.UploadPartOutput{ETag: aws.String(md5ETag(b))}, nil
+			})
+	}
+	return uploaded
+}
+
+func expectedCompletedParts(data []byte, partSize int) []*s3.CompletedPart {
+	var parts []*s3.CompletedPart
+	for i := 0; i*partSize < len(data); i++ {
+		end := (i + 1) * partSize
+		if end > len(data) {
+			end = len(data)
+		}
+		parts = append(parts, &s3.CompletedPart{
+			ETag:       aws.String(md5ETag(data[i*partSize : end])),
+			PartNumber: aws.Int64(int64(i + 1)),
+		})
+	}
+	return parts
+}
+
+func TestClientUploadMultipart(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newClientMocks(t)
+	defer cleanup()
+
+	mocks.config.UploadPartSize = 4
+	mocks.config.UploadConcurrency = 2
+	client := mocks.new()
+
+	data := randutil.Text(10)
+
+	mocks.s3.EXPECT().ListMultipartUploadsPages(
+		&s3.ListMultipartUploadsInput{
+			Bucket: aws.String("test-bucket"),
+			Prefix: aws.String("/root/test"),
+		},
+		gomock.Any(),
+	).Return(nil)
+
+	mocks.s3.EXPECT().CreateMultipartUpload(&s3.CreateMultipartUploadInput{
+		Bucket: aws.String("test-bucket"),
+		Key:    aws.String("/root/test"),
+	}).Return(&s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-id")}, nil)
+
+	uploaded := mocks.expectUploadParts(3)
+
+	mocks.s3.EXPECT().CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String("test-bucket"),
+		Key:             aws.String("/root/test"),
+		UploadId:        aws.String("upload-id"),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: expectedCompletedParts(data, 4)},
+	}).Return(&s3.CompleteMultipartUploadOutput{}, nil)
+
+	require.NoError(client.Upload(core.NamespaceFixture(), "test", bytes.NewReader(data)))
+	require.Equal(map[int64][]byte{1: data[:4], 2: data[4:8], 3: data[8:]}, uploaded)
+}
+
+func TestClientUploadMultipartResumes(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newClientMocks(t)
+	defer cleanup()
+
+	mocks.config.UploadPartSize = 4
+	client := mocks.new()
+
+	data := randutil.Text(10)
+
+	now := time.Now()
+	mocks.s3.EXPECT().ListMultipartUploadsPages(gomock.Any(), gomock.Any()).DoAndReturn(func(
+		input *s3.ListMultipartUploadsInput,
+		f func(*s3.ListMultipartUploadsOutput, bool) bool) error {
+
+		f(&s3.ListMultipartUploadsOutput{
+			Uploads: []*s3.MultipartUpload{{
+				Key:       aws.String("/root/test"),
+				UploadId:  aws.String("old-upload-id"),
+				Initiated: aws.Time(now.Add(-time.Hour)),
+			}, {
+				Key:       aws.String("/root/test"),
+				UploadId:  aws.String("upload-id"),
+				Initiated: aws.Time(now),
+			}, {
+				Key:       aws.String("/root/test-other"),
+				UploadId:  aws.String("other-upload-id"),
+				Initiated: aws.Time(now.Add(time.Hour)),
+			}},
+		}, true)
+		return nil
+	})
+
+	mocks.s3.EXPECT().ListPartsPages(
+		&s3.ListPartsInput{
+			Bucket:   aws.String("test-bucket"),
+			Key:      aws.String("/root/test"),
+			UploadId: aws.String("upload-id"),
+		},
+		gomock.Any(),
+	).DoAndReturn(func(
+		input *s3.ListPartsInput, f func(*s3.ListPartsOutput, bool) bool) error {
+
+		f(&s3.ListPartsOutput{
+			Parts: []*s3.Part{{
+				// Matches, so it is not uploaded again.
+				PartNumber: aws.Int64(1),
+				ETag:       aws.String(md5ETag(data[:4])),
+				Size:       aws.Int64(4),
+			}, {
+				// Different content.
+				PartNumber: aws.Int64(2),
+				ETag:       aws.String(md5ETag([]byte("abcd"))),
+				Size:       aws.Int64(4),
+			}},
+		}, true)
+		return nil
+	})
+
+	uploaded := mocks.expectUploadParts(2)
+
+	mocks.s3.EXPECT().CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String("test-bucket"),
+		Key:             aws.String("/root/test"),
+		UploadId:        aws.String("upload-id"),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: expectedCompletedParts(data, 4)},
+	}).Return(&s3.CompleteMultipartUploadOutput{}, nil)
+
+	require.NoError(client.Upload(core.NamespaceFixture(), "test", bytes.NewReader(data)))
+	require.Equal(map[int64][]byte{2: data[4:8], 3: data[8:]}, uploaded)
+}
+
+func TestClientUploadMultipartFailureKeepsParts(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newClientMocks(t)
+	defer cleanup()
+
+	mocks.config.UploadPartSize = 4
+	mocks.config.UploadConcurrency = 1
+	client := mocks.new()
+
+	data := randutil.Text(8)
+
+	mocks.s3.EXPECT().ListMultipartUploadsPages(gomock.Any(), gomock.Any()).Return(nil)
+	mocks.s3.EXPECT().CreateMultipartUpload(gomock.Any()).Return(
+		&s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-id")}, nil)
+	gomock.InOrder(
+		mocks.s3.EXPECT().UploadPart(gomock.Any()).Return(
+			&s3.UploadPartOutput{ETag: aws.String(md5ETag(data[:4]))}, nil),
+		mocks.s3.EXPECT().UploadPart(gomock.Any()).Return(nil, fmt.Errorf("some error")),
+	)
+
+	// No complete or abort calls.
+	require.Error(client.Upload(core.NamespaceFixture(), "test", bytes.NewReader(data)))
+}
+
+func TestClientUploadResumeDisabled(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newClientMocks(t)
+	defer cleanup()
+
+	mocks.config.UploadPartSize = 4
+	mocks.config.DisableUploadResume = true
+	client := mocks.new()
+
+	data := bytes.NewReader(randutil.Text(10))
+
+	mocks.s3.EXPECT().Upload(
+		&s3manager.UploadInput{
+			Bucket: aws.String("test-bucket"),
+			Key:    aws.String("/root/test"),
+			Body:   data,
+		},
+		gomock.Any(),
+	).Return(nil, nil)
+
+	require.NoError(client.Upload(core.NamespaceFixture(), "test", data))
+}
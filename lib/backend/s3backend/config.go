@@ -36,6 +36,13 @@ type Config struct {
 	UploadConcurrency   int `yaml:"upload_concurrency"`   // # of concurrent go-routines s3 manager uses for upload
 	DownloadConcurrency int `yaml:"download_concurrency"` // # of concurrent go-routines s3 manager uses for download
 
+	// DisableUploadResume disables resuming interrupted multipart uploads.
+	// By default, uploads of seekable sources larger than UploadPartSize are
+	// left incomplete on failure, such that a later upload of the same blob
+	// only uploads the parts which are missing. Buckets should then expire
+	// incomplete multipart uploads via a lifecycle rule.
+	DisableUploadResume bool `yaml:"disable_upload_resume"`
+
 	// ListMaxKeys sets the max keys returned per page.
 	ListMaxKeys int `yaml:"list_max_keys"`
 
@@ -0,0 +1,214 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package s3backend
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/uber/kraken/utils/errutil"
+	"github.com/uber/kraken/utils/log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// resumableSource is an upload source which can be read concurrently at
+// arbitrary offsets, and thus uploaded part by part.
+type resumableSource interface {
+	io.ReaderAt
+	io.Seeker
+}
+
+// section returns the unread remainder of src, leaving its offset untouched.
+func section(src resumableSource) (*io.SectionReader, error) {
+	offset, err := src.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	end, err := src.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := src.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.NewSectionReader(src, offset, end-offset), nil
+}
+
+// multipartUpload uploads src to key in parts of UploadPartSize, with up to
+// UploadConcurrency parts in flight. If an earlier upload of key was
+// interrupted, it is resumed: parts which were already uploaded with the same
+// content are not uploaded again. On failure, the upload is left incomplete so
+// it may be resumed later.
+func (c *Client) multipartUpload(key string, src *io.SectionReader) error {
+	partSize := c.config.UploadPartSize
+	if src.Size()/partSize >= s3manager.MaxUploadParts {
+		partSize = src.Size()/s3manager.MaxUploadParts + 1
+	}
+
+	uploadID, uploaded, err := c.findUpload(key)
+	if err != nil {
+		log.With("key", key).Errorf("Error finding upload to resume, starting over: %s", err)
+	}
+	if uploadID == "" {
+		output, err := c.s3.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
+			Bucket: aws.String(c.config.Bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return fmt.Errorf("create multipart upload: %s", err)
+		}
+		uploadID = *output.UploadId
+	} else {
+		log.With("key", key, "upload_id", uploadID, "parts", len(uploaded)).Info(
+			"Resuming multipart upload")
+	}
+
+	numParts := (src.Size() + partSize - 1) / partSize
+	parts := make([]*s3.CompletedPart, numParts)
+
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, c.config.UploadConcurrency)
+	for i := int64(0); i < numParts; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int64) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			num := i + 1
+			offset := i * partSize
+			size := partSize
+			if offset+size > src.Size() {
+				size = src.Size() - offset
+			}
+			r := io.NewSectionReader(src, offset, size)
+			etag, err := c.uploadPart(key, uploadID, num, r, uploaded[num])
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("part %d: %s", num, err))
+				mu.Unlock()
+				return
+			}
+			parts[i] = &s3.CompletedPart{ETag: aws.String(etag), PartNumber: aws.Int64(num)}
+		}(i)
+	}
+	wg.Wait()
+	if err := errutil.Join(errs); err != nil {
+		return fmt.Errorf("upload parts: %s", err)
+	}
+
+	_, err = c.s3.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(c.config.Bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		return fmt.Errorf("complete multipart upload: %s", err)
+	}
+	return nil
+}
+
+// uploadPart uploads r as part num of uploadID, unless existing, a part
+// uploaded previously, has the same content. Returns the etag of the part.
+func (c *Client) uploadPart(
+	key, uploadID string, num int64, r *io.SectionReader, existing *s3.Part) (string, error) {
+
+	h := md5.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", fmt.Errorf("hash: %s", err)
+	}
+	sum := h.Sum(nil)
+	etag := fmt.Sprintf("%q", hex.EncodeToString(sum))
+	if existing != nil && existing.ETag != nil && *existing.ETag == etag &&
+		existing.Size != nil && *existing.Size == r.Size() {
+		return etag, nil
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("seek: %s", err)
+	}
+	output, err := c.s3.UploadPart(&s3.UploadPartInput{
+		Bucket:        aws.String(c.config.Bucket),
+		Key:           aws.String(key),
+		UploadId:      aws.String(uploadID),
+		PartNumber:    aws.Int64(num),
+		Body:          r,
+		ContentLength: aws.Int64(r.Size()),
+		ContentMD5:    aws.String(base64.StdEncoding.EncodeToString(sum)),
+	})
+	if err != nil {
+		return "", err
+	}
+	if output.ETag != nil {
+		etag = *output.ETag
+	}
+	return etag, nil
+}
+
+// findUpload returns the id and uploaded parts of the most recently initiated
+// incomplete multipart upload of key, if any.
+func (c *Client) findUpload(key string) (string, map[int64]*s3.Part, error) {
+	var uploads []*s3.MultipartUpload
+	err := c.s3.ListMultipartUploadsPages(&s3.ListMultipartUploadsInput{
+		Bucket: aws.String(c.config.Bucket),
+		Prefix: aws.String(key),
+	}, func(page *s3.ListMultipartUploadsOutput, last bool) bool {
+		for _, u := range page.Uploads {
+			// Prefix also matches longer keys.
+			if u.Key != nil && *u.Key == key && u.UploadId != nil && u.Initiated != nil {
+				uploads = append(uploads, u)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("list multipart uploads: %s", err)
+	}
+	if len(uploads) == 0 {
+		return "", nil, nil
+	}
+	sort.Slice(uploads, func(i, j int) bool {
+		return uploads[i].Initiated.After(*uploads[j].Initiated)
+	})
+	uploadID := *uploads[0].UploadId
+
+	parts := make(map[int64]*s3.Part)
+	err = c.s3.ListPartsPages(&s3.ListPartsInput{
+		Bucket:   aws.String(c.config.Bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	}, func(page *s3.ListPartsOutput, last bool) bool {
+		for _, p := range page.Parts {
+			if p.PartNumber != nil {
+				parts[*p.PartNumber] = p
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("list parts: %s", err)
+	}
+	return uploadID, parts, nil
+}
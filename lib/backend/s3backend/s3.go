@@ -25,6 +25,8 @@ import (
 type S3 interface {
 	HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error)
 
+	DeleteObject(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error)
+
 	Download(
 		w io.WriterAt,
 		input *s3.GetObjectInput,
@@ -35,6 +37,18 @@ type S3 interface {
 		options ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error)
 
 	ListObjectsV2Pages(input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool) error
+
+	CreateMultipartUpload(input *s3.CreateMultipartUploadInput) (*s3.CreateMultipartUploadOutput, error)
+
+	UploadPart(input *s3.UploadPartInput) (*s3.UploadPartOutput, error)
+
+	CompleteMultipartUpload(
+		input *s3.CompleteMultipartUploadInput) (*s3.CompleteMultipartUploadOutput, error)
+
+	ListMultipartUploadsPages(
+		input *s3.ListMultipartUploadsInput, fn func(*s3.ListMultipartUploadsOutput, bool) bool) error
+
+	ListPartsPages(input *s3.ListPartsInput, fn func(*s3.ListPartsOutput, bool) bool) error
 }
 
 type join struct {
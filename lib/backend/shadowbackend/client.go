@@ -214,6 +214,24 @@ func (c *Client) Upload(namespace string, name string, src io.Reader) error {
 	return nil
 }
 
+// Delete deletes the data from both backends. Data missing from only one of
+// the backends is not an error.
+func (c *Client) Delete(namespace string, name string) error {
+	errA := backend.Delete(c.active, namespace, name)
+	errS := backend.Delete(c.shadow, namespace, name)
+
+	if isNotFoundErr(errA) && isNotFoundErr(errS) {
+		return backenderrors.ErrBlobNotFound
+	}
+	if errA != nil && !isNotFoundErr(errA) {
+		return errA
+	}
+	if errS != nil && !isNotFoundErr(errS) {
+		return errS
+	}
+	return nil
+}
+
 // List lists names with start with prefix.
 func (c *Client) List(prefix string, opts ...backend.ListOption) (*backend.ListResult, error) {
 	res, err := c.active.List(prefix, opts...)
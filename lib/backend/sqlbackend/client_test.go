@@ -142,6 +142,20 @@ func TestDownloadBadTagName(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestDelete(t *testing.T) {
+	sqlClient := newClient()
+	tag := generateSingleTag(sqlClient, "thor", "mjolnir")
+	name := fmt.Sprintf("%s:%s", tag.Repository, tag.Tag)
+
+	assert.NoError(t, sqlClient.Delete("", name))
+
+	_, err := sqlClient.Stat("", name)
+	assert.EqualError(t, err, backenderrors.ErrBlobNotFound.Error())
+
+	err = sqlClient.Delete("", name)
+	assert.EqualError(t, err, backenderrors.ErrBlobNotFound.Error())
+}
+
 func TestUploadNewAndUpdateTag(t *testing.T) {
 	sqlClient := newClient()
 	newRepoTag := "new-repo:new-tag"
@@ -52,6 +52,7 @@ func (s *Server) Handler() http.Handler {
 	r.Head("/files/*", handler.Wrap(s.statHandler))
 	r.Get("/files/*", handler.Wrap(s.downloadHandler))
 	r.Post("/files/*", handler.Wrap(s.uploadHandler))
+	r.Delete("/files/*", handler.Wrap(s.deleteHandler))
 	r.Get("/list/*", handler.Wrap(s.listHandler))
 	return r
 }
@@ -123,6 +124,21 @@ func (s *Server) uploadHandler(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+func (s *Server) deleteHandler(w http.ResponseWriter, r *http.Request) error {
+	s.Lock()
+	defer s.Unlock()
+
+	name := r.URL.Path[len("/files/"):]
+
+	if err := os.Remove(s.path(name)); err != nil {
+		if os.IsNotExist(err) {
+			return handler.ErrorStatus(http.StatusNotFound)
+		}
+		return handler.Errorf("remove: %s", err)
+	}
+	return nil
+}
+
 func (s *Server) listHandler(w http.ResponseWriter, r *http.Request) error {
 	s.RLock()
 	defer s.RUnlock()
@@ -65,6 +65,11 @@ func (c *ThrottledClient) Download(namespace, name string, dst io.Writer) error
 	return c.Client.Download(namespace, name, dst)
 }
 
+// Delete deletes name, if the underlying client supports deletion.
+func (c *ThrottledClient) Delete(namespace, name string) error {
+	return Delete(c.Client, namespace, name)
+}
+
 func (c *ThrottledClient) adjustBandwidth(denominator int) error {
 	return c.bandwidth.Adjust(denominator)
 }
@@ -0,0 +1,90 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package backend
+
+import (
+	"context"
+	"io"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/tracing"
+)
+
+// TracedClient is a backend client which records a span per operation.
+type TracedClient struct {
+	Client
+	backend string
+}
+
+// trace wraps client with tracing. backend is the name of the backend client,
+// e.g. "s3".
+func trace(client Client, backend string) *TracedClient {
+	return &TracedClient{client, backend}
+}
+
+func (c *TracedClient) start(op, namespace, name string) *tracing.Span {
+	_, span := tracing.Start(
+		context.Background(),
+		"backend."+op,
+		tracing.WithKind(tracing.KindClient),
+		tracing.WithAttribute("backend", c.backend),
+		tracing.WithAttribute("namespace", namespace),
+		tracing.WithAttribute("name", name))
+	return span
+}
+
+func end(span *tracing.Span, err error) {
+	span.SetError(err)
+	span.End()
+}
+
+// Stat returns blob info for name.
+func (c *TracedClient) Stat(namespace, name string) (info *core.BlobInfo, err error) {
+	span := c.start("stat", namespace, name)
+	defer func() { end(span, err) }()
+
+	return c.Client.Stat(namespace, name)
+}
+
+// Upload uploads src into name.
+func (c *TracedClient) Upload(namespace, name string, src io.Reader) (err error) {
+	span := c.start("upload", namespace, name)
+	defer func() { end(span, err) }()
+
+	return c.Client.Upload(namespace, name, src)
+}
+
+// Download downloads name into dst.
+func (c *TracedClient) Download(namespace, name string, dst io.Writer) (err error) {
+	span := c.start("download", namespace, name)
+	defer func() { end(span, err) }()
+
+	return c.Client.Download(namespace, name, dst)
+}
+
+// List lists entries whose names start with prefix.
+func (c *TracedClient) List(prefix string, opts ...ListOption) (result *ListResult, err error) {
+	span := c.start("list", "", prefix)
+	defer func() { end(span, err) }()
+
+	return c.Client.List(prefix, opts...)
+}
+
+// Delete deletes name, if the underlying client supports deletion.
+func (c *TracedClient) Delete(namespace, name string) (err error) {
+	span := c.start("delete", namespace, name)
+	defer func() { end(span, err) }()
+
+	return Delete(c.Client, namespace, name)
+}
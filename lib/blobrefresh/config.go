@@ -20,4 +20,15 @@ type Config struct {
 	// Limits the size of blobs which origin will accept. A 0 size limit means
 	// blob size is unbounded.
 	SizeLimit datasize.ByteSize `yaml:"size_limit"`
+
+	// CorruptRetries is the number of times a download which does not match its
+	// digest is retried before failing.
+	CorruptRetries int `yaml:"corrupt_retries"`
+}
+
+func (c Config) applyDefaults() Config {
+	if c.CorruptRetries == 0 {
+		c.CorruptRetries = 2
+	}
+	return c
 }
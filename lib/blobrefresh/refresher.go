@@ -64,6 +64,7 @@ func New(
 	backends *backend.Manager,
 	metaInfoGenerator *metainfogen.Generator) *Refresher {
 
+	config = config.applyDefaults()
 	stats = stats.Tagged(map[string]string{
 		"module": "blobrefresh",
 	})
@@ -134,9 +135,22 @@ func (r *Refresher) Refresh(namespace string, d core.Digest, hooks ...PostHook)
 	}
 }
 
+// download downloads d into the cache. Downloads are verified against d before
+// being committed, and corrupt downloads are retried.
 func (r *Refresher) download(client backend.Client, namespace string, d core.Digest) error {
 	name := d.Hex()
-	return r.cas.WriteCacheFile(name, func(w store.FileReadWriter) error {
-		return client.Download(namespace, name, w)
-	})
+	for attempt := 0; ; attempt++ {
+		err := r.cas.WriteCacheFile(name, func(w store.FileReadWriter) error {
+			return client.Download(namespace, name, w)
+		})
+		if !store.IsDigestMismatch(err) {
+			return err
+		}
+		r.stats.Tagged(map[string]string{"source": "backend"}).Counter("corrupt_blobs").Inc(1)
+		log.With("namespace", namespace, "name", name, "attempt", attempt).Errorf(
+			"Downloaded corrupt blob from backend: %s", err)
+		if attempt >= r.config.CorruptRetries {
+			return err
+		}
+	}
 }
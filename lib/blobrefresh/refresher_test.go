@@ -145,3 +145,68 @@ func TestRefreshSizeLimitWithValidSize(t *testing.T) {
 		return !os.IsNotExist(err)
 	}))
 }
+
+func TestRefreshRetriesCorruptDownloads(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newRefresherMocks(t)
+	defer cleanup()
+
+	stats := tally.NewTestScope("", nil)
+	refresher := New(
+		mocks.config, stats, mocks.cas, mocks.backends, metainfogen.Fixture(mocks.cas, _testPieceLength))
+
+	namespace := core.TagFixture()
+	client := mocks.newClient(namespace)
+
+	blob := core.SizedBlobFixture(100, uint64(_testPieceLength))
+
+	client.EXPECT().Stat(namespace, blob.Digest.Hex()).Return(core.NewBlobInfo(int64(len(blob.Content))), nil)
+	gomock.InOrder(
+		client.EXPECT().Download(
+			namespace, blob.Digest.Hex(), mockutil.MatchWriter([]byte("corrupt"))).Return(nil),
+		client.EXPECT().Download(
+			namespace, blob.Digest.Hex(), mockutil.MatchWriter(blob.Content)).Return(nil),
+	)
+
+	require.NoError(refresher.Refresh(namespace, blob.Digest))
+
+	require.NoError(testutil.PollUntilTrue(5*time.Second, func() bool {
+		_, err := mocks.cas.GetCacheFileStat(blob.Digest.Hex())
+		return !os.IsNotExist(err)
+	}))
+
+	f, err := mocks.cas.GetCacheFileReader(blob.Digest.Hex())
+	require.NoError(err)
+	result, err := ioutil.ReadAll(f)
+	require.NoError(err)
+	require.Equal(string(blob.Content), string(result))
+
+	require.Equal(
+		int64(1),
+		stats.Snapshot().Counters()["corrupt_blobs+module=blobrefresh,source=backend"].Value())
+}
+
+func TestRefreshGivesUpOnCorruptDownloads(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newRefresherMocks(t)
+	defer cleanup()
+
+	mocks.config.CorruptRetries = 1
+	refresher := mocks.new()
+
+	namespace := core.TagFixture()
+	client := mocks.newClient(namespace)
+
+	blob := core.SizedBlobFixture(100, uint64(_testPieceLength))
+
+	client.EXPECT().Download(
+		namespace, blob.Digest.Hex(), mockutil.MatchWriter([]byte("corrupt"))).Return(nil).Times(2)
+
+	err := refresher.download(client, namespace, blob.Digest)
+	require.True(store.IsDigestMismatch(err))
+
+	_, err = mocks.cas.GetCacheFileStat(blob.Digest.Hex())
+	require.True(os.IsNotExist(err))
+}
@@ -17,6 +17,11 @@ package containerd
 
 type Config struct {
 	Address string `yaml:"address"`
+
+	// HostsConfigPath is containerd's registry config_path, e.g.
+	// /etc/containerd/certs.d. If set, the agent writes a hosts.toml for each
+	// upstream of its registry mirror there on startup.
+	HostsConfigPath string `yaml:"hosts_config_path"`
 }
 
 func (c Config) applyDefaults() Config {
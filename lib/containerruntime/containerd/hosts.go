@@ -0,0 +1,72 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package containerd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// _dockerHubServer is the registry endpoint of docker.io, which unlike other
+// upstreams is not served from its own host name.
+const _dockerHubServer = "https://registry-1.docker.io"
+
+var _hostsTemplate = template.Must(template.New("hosts").Parse(
+	`# Generated by kraken-agent, do not edit.
+server = "{{.Server}}"
+
+[host."{{.Mirror}}"]
+  capabilities = ["pull", "resolve"]
+`))
+
+// HostsTOML renders a containerd hosts.toml which pulls images of upstream
+// through mirror, e.g. "http://127.0.0.1:16000", and falls back to upstream
+// itself if the mirror fails.
+func HostsTOML(upstream, mirror string) ([]byte, error) {
+	server := "https://" + upstream
+	if upstream == "docker.io" {
+		server = _dockerHubServer
+	}
+	var b bytes.Buffer
+	if err := _hostsTemplate.Execute(&b, struct {
+		Server string
+		Mirror string
+	}{server, mirror}); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// WriteHosts writes a hosts.toml for each upstream under containerd's registry
+// config path, such that containerd pulls them through mirror.
+func WriteHosts(configPath string, upstreams []string, mirror string) error {
+	for _, upstream := range upstreams {
+		b, err := HostsTOML(upstream, mirror)
+		if err != nil {
+			return fmt.Errorf("render hosts for %s: %s", upstream, err)
+		}
+		dir := filepath.Join(configPath, upstream)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("mkdir: %s", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "hosts.toml"), b, 0644); err != nil {
+			return fmt.Errorf("write hosts for %s: %s", upstream, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package containerd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteHosts(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "containerd-hosts")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(WriteHosts(dir, []string{"docker.io", "gcr.io"}, "http://127.0.0.1:16000"))
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "docker.io", "hosts.toml"))
+	require.NoError(err)
+	require.Equal(`# Generated by kraken-agent, do not edit.
+server = "https://registry-1.docker.io"
+
+[host."http://127.0.0.1:16000"]
+  capabilities = ["pull", "resolve"]
+`, string(b))
+
+	b, err = ioutil.ReadFile(filepath.Join(dir, "gcr.io", "hosts.toml"))
+	require.NoError(err)
+	require.Contains(string(b), `server = "https://gcr.io"`)
+}
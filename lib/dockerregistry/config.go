@@ -14,10 +14,17 @@
 package dockerregistry
 
 import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
 	"github.com/uber/kraken/lib/dockerregistry/transfer"
 	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/utils/log"
+
 	"github.com/docker/distribution/configuration"
-	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/registry"
 	"github.com/uber-go/tally"
 )
@@ -30,6 +37,9 @@ const (
 // Config defines registry configuration.
 type Config struct {
 	Docker configuration.Configuration `yaml:"docker"`
+
+	// Mirror serves the registry as pull-through mirror of upstream registries.
+	Mirror MirrorConfig `yaml:"mirror"`
 }
 
 // ReadWriteParameters builds parameters for a read-write driver.
@@ -74,3 +84,34 @@ func (c Config) Build(parameters configuration.Parameters) (*registry.Registry,
 	}
 	return registry.NewRegistry(context.Background(), &c.Docker)
 }
+
+// Proxy returns a reverse proxy to the registry listening on c.Docker.HTTP,
+// for handlers which must run in front of the registry. The Host header is
+// passed through unchanged, since the registry builds upload urls from it.
+func (c Config) Proxy() http.Handler {
+	network, addr := c.Docker.HTTP.Net, c.Docker.HTTP.Addr
+	if network == "" {
+		network = "tcp"
+	}
+	p := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: "registry"})
+	p.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+	return p
+}
+
+// ResumableUploads returns a proxy to the registry which resumes blob uploads
+// from the upload sessions in cas, see resumableUploads.
+//
+// The upload state is re-signed with c.Docker.HTTP.Secret, so uploads are only
+// resumable if the registry is configured with a fixed http secret.
+func (c Config) ResumableUploads(cas *store.CAStore) http.Handler {
+	if c.Docker.HTTP.Secret == "" {
+		log.Warn("Registry http secret not configured, blob uploads are not resumable")
+		return c.Proxy()
+	}
+	return newResumableUploads(c.Proxy(), cas, c.Docker.HTTP.Secret)
+}
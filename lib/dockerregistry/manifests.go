@@ -14,13 +14,17 @@
 package dockerregistry
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"strings"
 	"time"
 
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
 	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/lib/dockerregistry/transfer"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/utils/dockerutil"
 	"github.com/uber/kraken/utils/log"
 )
 
@@ -104,11 +108,87 @@ func (t *manifests) putContent(path string, subtype PathSubType) error {
 			return fmt.Errorf("post tag: %w", err)
 		}
 		return nil
+	case _revisions:
+		repo, err := GetRepo(path)
+		if err != nil {
+			return fmt.Errorf("get repo: %s", err)
+		}
+		digest, err := GetManifestDigest(path)
+		if err != nil {
+			return fmt.Errorf("get manifest digest: %s", err)
+		}
+		if err := t.addReferrer(repo, digest); err != nil {
+			return fmt.Errorf("add referrer: %w", err)
+		}
+		return nil
 	}
 	// Intentional no-op.
 	return nil
 }
 
+// addReferrer adds the manifest digest of repo to the referrers index of its
+// subject, if it has one. Referrers indexes are stored as regular tags under
+// the OCI referrers tag schema, such that signatures, SBOMs and other
+// artifacts are discoverable and distributed like any other manifest.
+//
+// Note: concurrent pushes of referrers to the same subject may race, in which
+// case the last write wins.
+func (t *manifests) addReferrer(repo string, digest core.Digest) error {
+	b, err := t.download(repo, digest)
+	if err != nil {
+		return fmt.Errorf("download manifest: %w", err)
+	}
+	subject, desc, ok, err := dockerutil.ParseReferrer(b)
+	if err != nil {
+		return fmt.Errorf("parse manifest: %s", err)
+	}
+	if !ok {
+		return nil
+	}
+
+	tag := fmt.Sprintf("%s:%s", repo, dockerutil.ReferrersTag(subject))
+	index := dockerutil.NewReferrersIndex()
+	if d, err := t.transferer.GetTag(tag); err == nil {
+		b, err := t.download(repo, d)
+		if err != nil {
+			return fmt.Errorf("download referrers index: %w", err)
+		}
+		if err := json.Unmarshal(b, index); err != nil {
+			return fmt.Errorf("unmarshal referrers index: %s", err)
+		}
+	} else if err != transfer.ErrTagNotFound {
+		return fmt.Errorf("get referrers tag: %w", err)
+	}
+	if !index.Add(desc) {
+		return nil
+	}
+
+	b, err = json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("marshal referrers index: %s", err)
+	}
+	d, err := core.NewDigester().FromBytes(b)
+	if err != nil {
+		return fmt.Errorf("digest referrers index: %s", err)
+	}
+	if err := t.transferer.Upload(repo, d, store.NewBufferFileReader(b)); err != nil {
+		return fmt.Errorf("upload referrers index: %w", err)
+	}
+	if err := t.transferer.PutTag(tag, d); err != nil {
+		return fmt.Errorf("put referrers tag: %w", err)
+	}
+	return nil
+}
+
+func (t *manifests) download(repo string, d core.Digest) ([]byte, error) {
+	blob, err := t.transferer.Download(repo, d)
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+	return ioutil.ReadAll(blob)
+}
+
 func (t *manifests) stat(path string) (storagedriver.FileInfo, error) {
 	repo, err := GetRepo(path)
 	if err != nil {
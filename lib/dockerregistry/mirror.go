@@ -0,0 +1,87 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package dockerregistry
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+)
+
+// _mirrorNamespaceQuery is the query parameter containerd sets to the upstream
+// registry host when pulling from a mirror.
+const _mirrorNamespaceQuery = "ns"
+
+// MirrorConfig configures the registry as a transparent pull-through mirror for
+// upstream registries, e.g. via containerd's registry hosts configuration.
+type MirrorConfig struct {
+	// Upstreams maps upstream registry hosts to the prefix of their
+	// repositories in Kraken. E.g. with {"docker.io": "", "gcr.io": "gcr.io/"},
+	// gcr.io/foo/bar is served from Kraken repository gcr.io/foo/bar, while
+	// docker.io/library/alpine is served from library/alpine.
+	Upstreams map[string]string `yaml:"upstreams"`
+}
+
+// Enabled returns true if any upstreams are mirrored.
+func (c MirrorConfig) Enabled() bool {
+	return len(c.Upstreams) > 0
+}
+
+// UpstreamHosts returns the sorted hosts of all mirrored upstreams.
+func (c MirrorConfig) UpstreamHosts() []string {
+	var hosts []string
+	for host := range c.Upstreams {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// Handler wraps next such that requests for mirrored upstreams, identified by
+// the ns query parameter, are rewritten to the Kraken repositories of the
+// upstream. Requests without ns are passed through, and requests for other
+// upstreams are rejected so the client falls back to the upstream itself.
+func (c MirrorConfig) Handler(next http.Handler) http.Handler {
+	if !c.Enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ns := r.URL.Query().Get(_mirrorNamespaceQuery)
+		if ns == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		prefix, ok := c.Upstreams[ns]
+		if !ok {
+			errcode.ServeJSON(w, v2.ErrorCodeNameUnknown.WithDetail("upstream not mirrored: "+ns))
+			return
+		}
+		q := r.URL.Query()
+		q.Del(_mirrorNamespaceQuery)
+		r.URL.RawQuery = q.Encode()
+
+		repoPath := strings.TrimPrefix(r.URL.Path, "/v2/")
+		if prefix != "" && repoPath != r.URL.Path && repoPath != "" && repoPath != "_catalog" {
+			if !strings.HasSuffix(prefix, "/") {
+				prefix += "/"
+			}
+			r.URL.Path = "/v2/" + prefix + repoPath
+			r.URL.RawPath = ""
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package dockerregistry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirrorHandler(t *testing.T) {
+	config := MirrorConfig{Upstreams: map[string]string{
+		"docker.io": "",
+		"gcr.io":    "gcr.io",
+	}}
+	tests := []struct {
+		desc         string
+		url          string
+		expectedPath string
+		expectedCode int
+	}{
+		{"no namespace", "/v2/foo/bar/manifests/latest", "/v2/foo/bar/manifests/latest", http.StatusOK},
+		{"no prefix", "/v2/library/alpine/manifests/latest?ns=docker.io", "/v2/library/alpine/manifests/latest", http.StatusOK},
+		{"prefix", "/v2/foo/bar/blobs/sha256:abc?ns=gcr.io", "/v2/gcr.io/foo/bar/blobs/sha256:abc", http.StatusOK},
+		{"ping", "/v2/?ns=gcr.io", "/v2/", http.StatusOK},
+		{"unknown upstream", "/v2/foo/bar/manifests/latest?ns=quay.io", "", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			var path string
+			h := config.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				require.Empty(r.URL.Query().Get("ns"))
+			}))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", test.url, nil))
+			require.Equal(test.expectedCode, w.Code)
+			require.Equal(test.expectedPath, path)
+		})
+	}
+}
+
+func TestMirrorHandlerDisabled(t *testing.T) {
+	var query string
+	h := MirrorConfig{}.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v2/foo/manifests/latest?ns=gcr.io", nil))
+	require.Equal(t, "ns=gcr.io", query)
+}
+
+func TestMirrorConfigUpstreamHosts(t *testing.T) {
+	config := MirrorConfig{Upstreams: map[string]string{"gcr.io": "gcr.io/", "docker.io": ""}}
+	require.True(t, config.Enabled())
+	require.Equal(t, []string{"docker.io", "gcr.io"}, config.UpstreamHosts())
+	require.False(t, MirrorConfig{}.Enabled())
+}
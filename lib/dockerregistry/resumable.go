@@ -0,0 +1,189 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package dockerregistry
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/utils/log"
+
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+)
+
+var _uploadURLRegexp = regexp.MustCompile(`^/v2/(.+)/blobs/uploads/([a-zA-Z0-9-_.=]+)$`)
+
+// uploadState mirrors the state docker distribution signs into the _state
+// parameter of upload urls.
+type uploadState struct {
+	Name      string
+	UUID      string
+	Offset    int64
+	StartedAt time.Time
+}
+
+// packUploadState signs s in the same format as docker distribution.
+func packUploadState(secret string, s uploadState) (string, error) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(b)
+	return base64.URLEncoding.EncodeToString(append(mac.Sum(nil), b...)), nil
+}
+
+// resumableUploads implements resumable blob uploads in front of the docker
+// registry handlers, which otherwise always report uploads as starting from
+// zero, ignore Content-Range, and cancel any upload whose _state token does
+// not match the data on disk.
+//
+// Upload sessions are already persisted in the CAStore upload directory, so
+// rather than trusting _state, the upload state is rebuilt from the store on
+// every request and re-signed before reaching the registry. Sessions therefore
+// survive dropped connections and proxy restarts.
+type resumableUploads struct {
+	next   http.Handler
+	cas    *store.CAStore
+	secret string
+}
+
+func newResumableUploads(next http.Handler, cas *store.CAStore, secret string) *resumableUploads {
+	return &resumableUploads{next, cas, secret}
+}
+
+func (h *resumableUploads) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m := _uploadURLRegexp.FindStringSubmatch(r.URL.Path)
+	if m == nil {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	name, uuid := m[1], m[2]
+
+	state, err := h.loadState(name, uuid)
+	if err != nil {
+		// Unknown uploads are left for the registry to reject.
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	token, err := packUploadState(h.secret, state)
+	if err != nil {
+		log.Errorf("Error packing state of upload %s: %s", uuid, err)
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		w = &uploadStatusWriter{w, state.Offset}
+	case http.MethodPatch:
+		if cr := r.Header.Get("Content-Range"); cr != "" {
+			if err := checkContentRange(cr, r.ContentLength, state.Offset); err != nil {
+				w.Header().Set("Location", r.URL.Path+"?_state="+token)
+				w.Header().Set("Range", uploadRange(state.Offset))
+				w.Header().Set("Docker-Upload-UUID", uuid)
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+				json.NewEncoder(w).Encode(errcode.Errors{
+					v2.ErrorCodeBlobUploadInvalid.WithDetail(err.Error()),
+				})
+				return
+			}
+		}
+	}
+
+	q := r.URL.Query()
+	q.Set("_state", token)
+	r.URL.RawQuery = q.Encode()
+	h.next.ServeHTTP(w, r)
+}
+
+func (h *resumableUploads) loadState(name, uuid string) (uploadState, error) {
+	info, err := h.cas.GetUploadFileStat(uuid)
+	if err != nil {
+		return uploadState{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return uploadState{}, fmt.Errorf("upload %s is not a file", uuid)
+	}
+	var s startedAtMetadata
+	if err := h.cas.GetUploadFileMetadata(uuid, &s); err != nil {
+		return uploadState{}, fmt.Errorf("get started at: %s", err)
+	}
+	return uploadState{
+		Name:      name,
+		UUID:      uuid,
+		Offset:    info.Size(),
+		StartedAt: s.time,
+	}, nil
+}
+
+// checkContentRange validates that a chunk described by the Content-Range
+// header cr starts exactly where the upload currently ends.
+func checkContentRange(cr string, length, offset int64) error {
+	parts := strings.SplitN(strings.TrimPrefix(cr, "bytes "), "-", 2)
+	if len(parts) != 2 {
+		return errors.New("invalid content range")
+	}
+	start, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return errors.New("invalid content range start")
+	}
+	end, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || end < start {
+		return errors.New("invalid content range end")
+	}
+	if start != offset {
+		return fmt.Errorf("content range starts at %d, upload is at %d", start, offset)
+	}
+	if length >= 0 && end-start+1 != length {
+		return fmt.Errorf("content range covers %d bytes, body has %d", end-start+1, length)
+	}
+	return nil
+}
+
+// uploadRange formats the Range header for an upload of size bytes, following
+// docker distribution's convention of reporting empty uploads as 0-0.
+func uploadRange(size int64) string {
+	end := size
+	if end > 0 {
+		end--
+	}
+	return fmt.Sprintf("0-%d", end)
+}
+
+// uploadStatusWriter replaces the Range header of upload status responses,
+// which docker distribution always reports as 0-0, with the actual progress.
+type uploadStatusWriter struct {
+	http.ResponseWriter
+	size int64
+}
+
+func (w *uploadStatusWriter) WriteHeader(code int) {
+	if code == http.StatusNoContent {
+		w.Header().Set("Range", uploadRange(w.size))
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,220 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package dockerregistry
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/dockerregistry/transfer"
+	"github.com/uber/kraken/lib/store"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/handlers"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+// testRegistryServer serves a docker registry behind ResumableUploads, as the
+// proxy does through registry override.
+type testRegistryServer struct {
+	*httptest.Server
+	registry *httptest.Server
+}
+
+func newTestRegistryServer(t *testing.T, cas *store.CAStore, secret string) *testRegistryServer {
+	var config Config
+	config.Docker.Log.AccessLog.Disabled = true
+	config.Docker.HTTP.Secret = secret
+	config.Docker.Storage = configuration.Storage{
+		Name: config.ReadWriteParameters(transfer.NewTestTransferer(cas), cas, tally.NoopScope),
+		"redirect": configuration.Parameters{
+			"disable": true,
+		},
+	}
+	registry := httptest.NewServer(handlers.NewApp(context.Background(), &config.Docker))
+	config.Docker.HTTP.Addr = registry.Listener.Addr().String()
+	return &testRegistryServer{httptest.NewServer(config.ResumableUploads(cas)), registry}
+}
+
+func (s *testRegistryServer) Close() {
+	s.Server.Close()
+	s.registry.Close()
+}
+
+func sendUploadRequest(
+	t *testing.T, method, url string, body []byte, header map[string]string) *http.Response {
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp
+}
+
+func startUpload(t *testing.T, server *testRegistryServer) (uuid, location string) {
+	resp := sendUploadRequest(t, "POST", server.URL+"/v2/repo/blobs/uploads/", nil, nil)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	return resp.Header.Get("Docker-Upload-UUID"), resp.Header.Get("Location")
+}
+
+func absURL(server *testRegistryServer, location string) string {
+	if location != "" && location[0] == '/' {
+		return server.URL + location
+	}
+	return location
+}
+
+func TestResumableUploadAfterDroppedChunk(t *testing.T) {
+	require := require.New(t)
+
+	cas, cleanup := store.CAStoreFixture()
+	defer cleanup()
+
+	server := newTestRegistryServer(t, cas, "secret")
+	defer server.Close()
+
+	blob := core.SizedBlobFixture(64, 8)
+
+	uuid, location := startUpload(t, server)
+
+	resp := sendUploadRequest(t, "PATCH", location, blob.Content[:16],
+		map[string]string{"Content-Range": "0-15"})
+	require.Equal(http.StatusAccepted, resp.StatusCode)
+	require.Equal("0-15", resp.Header.Get("Range"))
+	staleLocation := resp.Header.Get("Location")
+
+	// Simulate a chunk which dropped mid-stream after 8 bytes were written,
+	// leaving the client holding a _state token behind the data on disk.
+	f, err := cas.GetUploadFileReadWriter(uuid)
+	require.NoError(err)
+	_, err = f.Seek(0, 2)
+	require.NoError(err)
+	_, err = f.Write(blob.Content[16:24])
+	require.NoError(err)
+	require.NoError(f.Close())
+
+	resp = sendUploadRequest(t, "GET", staleLocation, nil, nil)
+	require.Equal(http.StatusNoContent, resp.StatusCode)
+	require.Equal("0-23", resp.Header.Get("Range"))
+	location = resp.Header.Get("Location")
+
+	// Chunks which do not start at the current offset are rejected.
+	resp = sendUploadRequest(t, "PATCH", staleLocation, blob.Content[16:],
+		map[string]string{"Content-Range": "16-63"})
+	require.Equal(http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+	require.Equal("0-23", resp.Header.Get("Range"))
+
+	resp = sendUploadRequest(t, "PATCH", location, blob.Content[24:],
+		map[string]string{"Content-Range": "24-63"})
+	require.Equal(http.StatusAccepted, resp.StatusCode)
+	require.Equal("0-63", resp.Header.Get("Range"))
+
+	resp = sendUploadRequest(t, "PUT",
+		fmt.Sprintf("%s&digest=%s", resp.Header.Get("Location"), blob.Digest), nil, nil)
+	require.Equal(http.StatusCreated, resp.StatusCode)
+
+	r, err := cas.GetCacheFileReader(blob.Digest.Hex())
+	require.NoError(err)
+	defer r.Close()
+	result, err := ioutil.ReadAll(r)
+	require.NoError(err)
+	require.Equal(blob.Content, result)
+}
+
+func TestResumableUploadSurvivesRestart(t *testing.T) {
+	require := require.New(t)
+
+	cas, cleanup := store.CAStoreFixture()
+	defer cleanup()
+
+	blob := core.SizedBlobFixture(32, 8)
+
+	server := newTestRegistryServer(t, cas, "secret")
+	_, location := startUpload(t, server)
+	resp := sendUploadRequest(t, "PATCH", location, blob.Content[:16], nil)
+	require.Equal(http.StatusAccepted, resp.StatusCode)
+	location = resp.Header.Get("Location")
+	server.Close()
+
+	// The restarted registry uses a different secret, so the upload state
+	// cannot be recovered from the _state token.
+	restarted := newTestRegistryServer(t, cas, "rotated")
+	defer restarted.Close()
+	location = restarted.URL + location[len(server.URL):]
+
+	resp = sendUploadRequest(t, "HEAD", location, nil, nil)
+	require.Equal(http.StatusNoContent, resp.StatusCode)
+	require.Equal("0-15", resp.Header.Get("Range"))
+
+	resp = sendUploadRequest(t, "PATCH", absURL(restarted, resp.Header.Get("Location")),
+		blob.Content[16:], map[string]string{"Content-Range": "16-31"})
+	require.Equal(http.StatusAccepted, resp.StatusCode)
+
+	resp = sendUploadRequest(t, "PUT",
+		fmt.Sprintf("%s&digest=%s", absURL(restarted, resp.Header.Get("Location")), blob.Digest), nil, nil)
+	require.Equal(http.StatusCreated, resp.StatusCode)
+}
+
+func TestResumableUploadUnknownUpload(t *testing.T) {
+	cas, cleanup := store.CAStoreFixture()
+	defer cleanup()
+
+	server := newTestRegistryServer(t, cas, "secret")
+	defer server.Close()
+
+	resp := sendUploadRequest(t, "GET", server.URL+"/v2/repo/blobs/uploads/..", nil, nil)
+	require.NotEqual(t, http.StatusNoContent, resp.StatusCode)
+
+	resp = sendUploadRequest(t, "GET", server.URL+"/v2/repo/blobs/uploads/missing", nil, nil)
+	require.NotEqual(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestCheckContentRange(t *testing.T) {
+	tests := []struct {
+		desc   string
+		cr     string
+		length int64
+		valid  bool
+	}{
+		{"valid", "10-19", 10, true},
+		{"bytes prefix", "bytes 10-19", 10, true},
+		{"unknown length", "10-19", -1, true},
+		{"wrong start", "0-19", 20, false},
+		{"wrong length", "10-19", 5, false},
+		{"end before start", "10-9", -1, false},
+		{"malformed", "10", -1, false},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := checkContentRange(test.cr, test.length, 10)
+			if test.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
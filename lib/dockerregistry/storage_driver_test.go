@@ -15,6 +15,7 @@ package dockerregistry
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -26,6 +27,8 @@ import (
 	"github.com/docker/distribution/uuid"
 	"github.com/stretchr/testify/require"
 	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/utils/dockerutil"
 	"github.com/uber/kraken/utils/randutil"
 )
 
@@ -140,6 +143,42 @@ func TestStorageDriverPutContent(t *testing.T) {
 	// TODO (@evelynl): check content written
 }
 
+func TestStorageDriverPutContentAddsReferrer(t *testing.T) {
+	require := require.New(t)
+
+	td, cleanup := newTestDriver()
+	defer cleanup()
+
+	sd, testImage := td.setup()
+
+	subject, err := core.ParseSHA256Digest("sha256:" + testImage.manifest)
+	require.NoError(err)
+
+	var referrers []core.Digest
+	for _, artifactType := range []string{"sig", "sbom"} {
+		d, b := dockerutil.ReferrerManifestFixture(subject, artifactType, testImage.layer1.Digest)
+		require.NoError(td.transferer.Upload(testImage.repo, d, store.NewBufferFileReader(b)))
+		require.NoError(sd.PutContent(contextFixture(), genManifestRevisionLinkPath(testImage.repo, d.Hex()), nil))
+		referrers = append(referrers, d)
+	}
+	// Re-pushing a referrer is a no-op.
+	require.NoError(sd.PutContent(contextFixture(), genManifestRevisionLinkPath(testImage.repo, referrers[0].Hex()), nil))
+
+	indexDigest, err := td.transferer.GetTag(
+		fmt.Sprintf("%s:%s", testImage.repo, dockerutil.ReferrersTag(subject)))
+	require.NoError(err)
+	blob, err := td.transferer.Download(testImage.repo, indexDigest)
+	require.NoError(err)
+	defer blob.Close()
+
+	var index dockerutil.ReferrersIndex
+	require.NoError(json.NewDecoder(blob).Decode(&index))
+	require.Len(index.Manifests, 2)
+	for i, m := range index.Manifests {
+		require.Equal(referrers[i], m.Digest)
+	}
+}
+
 func TestStorageDriverWriter(t *testing.T) {
 	td, cleanup := newTestDriver()
 	defer cleanup()
@@ -14,13 +14,18 @@
 package transfer
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
 
 	"github.com/uber/kraken/build-index/tagclient"
 	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/persistedretry/notification"
+	"github.com/uber/kraken/lib/signature"
 	"github.com/uber/kraken/lib/store"
 	"github.com/uber/kraken/origin/blobclient"
+	"github.com/uber/kraken/utils/dockerutil"
 	"github.com/uber/kraken/utils/log"
 
 	"github.com/docker/distribution/uuid"
@@ -34,6 +39,8 @@ type ReadWriteTransferer struct {
 	tags          tagclient.Client
 	originCluster blobclient.ClusterClient
 	cas           *store.CAStore
+	notifier      notification.Notifier
+	verifier      signature.Verifier
 }
 
 // NewReadWriteTransferer creates a new ReadWriteTransferer.
@@ -41,13 +48,15 @@ func NewReadWriteTransferer(
 	stats tally.Scope,
 	tags tagclient.Client,
 	originCluster blobclient.ClusterClient,
-	cas *store.CAStore) *ReadWriteTransferer {
+	cas *store.CAStore,
+	notifier notification.Notifier,
+	verifier signature.Verifier) *ReadWriteTransferer {
 
 	stats = stats.Tagged(map[string]string{
 		"module": "rwtransferer",
 	})
 
-	return &ReadWriteTransferer{stats, tags, originCluster, cas}
+	return &ReadWriteTransferer{stats, tags, originCluster, cas, notifier, verifier}
 }
 
 // Stat returns blob info from origin cluster or local cache.
@@ -136,15 +145,41 @@ func (t *ReadWriteTransferer) GetTag(tag string) (core.Digest, error) {
 	return d, nil
 }
 
-// PutTag uploads d as the manifest digest for tag.
+// PutTag uploads d as the manifest digest for tag, if its signature passes
+// verification.
 func (t *ReadWriteTransferer) PutTag(tag string, d core.Digest) error {
+	if err := t.verifier.Verify(tag, d, signature.OpPush); err != nil {
+		return fmt.Errorf("verify signature: %s", err)
+	}
 	if err := t.tags.PutAndReplicate(tag, d); err != nil {
 		t.stats.Counter("put_tag_error").Inc(1)
 		return fmt.Errorf("put and replicate tag: %s", err)
 	}
+	mediaType, size := t.manifestInfo(d)
+	t.notifier.TagPushed(tag, d, mediaType, size)
 	return nil
 }
 
+// manifestInfo returns the media type and size of manifest d, if it is in the
+// local cache. Manifests pushed through the registry always are.
+func (t *ReadWriteTransferer) manifestInfo(d core.Digest) (mediaType string, size int64) {
+	f, err := t.cas.GetCacheFileReader(d.Hex())
+	if err != nil {
+		return "", 0
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", 0
+	}
+	manifest, _, err := dockerutil.ParseManifest(bytes.NewReader(b))
+	if err != nil {
+		return "", int64(len(b))
+	}
+	mediaType, _, _ = manifest.Payload()
+	return mediaType, int64(len(b))
+}
+
 // ListTags lists all tags with prefix.
 func (t *ReadWriteTransferer) ListTags(prefix string) ([]string, error) {
 	return t.tags.List(prefix)
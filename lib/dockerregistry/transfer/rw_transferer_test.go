@@ -21,13 +21,17 @@ import (
 
 	"github.com/uber/kraken/build-index/tagclient"
 	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/signature"
 	"github.com/uber/kraken/lib/store"
 	"github.com/uber/kraken/mocks/build-index/tagclient"
+	"github.com/uber/kraken/mocks/lib/persistedretry/notification"
+	"github.com/uber/kraken/mocks/lib/signature"
 	"github.com/uber/kraken/mocks/origin/blobclient"
 	"github.com/uber/kraken/utils/dockerutil"
 	"github.com/uber/kraken/utils/mockutil"
 	"github.com/uber/kraken/utils/testutil"
 
+	"github.com/docker/distribution/manifest/schema2"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
 	"github.com/uber-go/tally"
@@ -37,6 +41,9 @@ type proxyTransfererMocks struct {
 	tags          *mocktagclient.MockClient
 	originCluster *mockblobclient.MockClusterClient
 	cas           *store.CAStore
+	notifier      *mocknotification.MockNotifier
+	verifier      signature.Verifier
+	ctrl          *gomock.Controller
 }
 
 func newReadWriteTransfererMocks(t *testing.T) (*proxyTransfererMocks, func()) {
@@ -52,11 +59,15 @@ func newReadWriteTransfererMocks(t *testing.T) (*proxyTransfererMocks, func()) {
 	cas, c := store.CAStoreFixture()
 	cleanup.Add(c)
 
-	return &proxyTransfererMocks{tags, originCluster, cas}, cleanup.Run
+	notifier := mocknotification.NewMockNotifier(ctrl)
+
+	return &proxyTransfererMocks{
+		tags, originCluster, cas, notifier, signature.Disabled(), ctrl}, cleanup.Run
 }
 
 func (m *proxyTransfererMocks) new() *ReadWriteTransferer {
-	return NewReadWriteTransferer(tally.NoopScope, m.tags, m.originCluster, m.cas)
+	return NewReadWriteTransferer(
+		tally.NoopScope, m.tags, m.originCluster, m.cas, m.notifier, m.verifier)
 }
 
 func TestReadWriteTransfererDownloadCachesBlob(t *testing.T) {
@@ -137,10 +148,48 @@ func TestReadWriteTransfererPutTag(t *testing.T) {
 	tag := "docker/some-tag"
 
 	mocks.tags.EXPECT().PutAndReplicate(tag, manifestDigest).Return(nil)
+	mocks.notifier.EXPECT().TagPushed(
+		tag, manifestDigest, schema2.MediaTypeManifest, int64(len(rawManifest)))
 
 	require.NoError(transferer.PutTag(tag, manifestDigest))
 }
 
+func TestReadWriteTransfererPutTagErrorDoesNotNotify(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadWriteTransfererMocks(t)
+	defer cleanup()
+
+	transferer := mocks.new()
+
+	tag := "docker/some-tag"
+	d := core.DigestFixture()
+
+	mocks.tags.EXPECT().PutAndReplicate(tag, d).Return(errors.New("some error"))
+
+	require.Error(transferer.PutTag(tag, d))
+}
+
+func TestReadWriteTransfererPutTagRejectsUnsignedTag(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newReadWriteTransfererMocks(t)
+	defer cleanup()
+
+	verifier := mocksignature.NewMockVerifier(mocks.ctrl)
+	mocks.verifier = verifier
+
+	transferer := mocks.new()
+
+	tag := "docker/some-tag"
+	d := core.DigestFixture()
+
+	verifier.EXPECT().Verify(tag, d, signature.OpPush).Return(
+		signature.RejectedError{Digest: d, Reason: errors.New("some error")})
+
+	require.Error(transferer.PutTag(tag, d))
+}
+
 func TestReadWriteTransfererStatLocalBlob(t *testing.T) {
 	require := require.New(t)
 
@@ -25,6 +25,12 @@ type Config struct {
 	// RefreshInterval is the interval at which membership / health information
 	// is refreshed during monitoring.
 	RefreshInterval time.Duration `yaml:"refresh_interval"`
+
+	// Weights optionally sets the weight of addresses in the ring, such that
+	// heavier addresses own proportionally more digests, e.g. 400 for a host
+	// with 4x the disk of hosts with the default weight of 100. Addresses not
+	// present get the default weight. WithWeights takes precedence.
+	Weights map[string]int `yaml:"weights"`
 }
 
 func (c *Config) applyDefaults() {
@@ -0,0 +1,149 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hashring
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/uber/kraken/core"
+
+	"github.com/go-chi/chi"
+)
+
+// debugMember is a member of the ring as rendered by DebugHandler.
+type debugMember struct {
+	Addr   string `json:"addr"`
+	Weight int    `json:"weight"`
+
+	// Share is the fraction of digests for which the member is the first
+	// location. Since the ring uses weighted rendezvous hashing, this is the
+	// member's weight over the total weight.
+	Share   float64 `json:"share"`
+	Healthy bool    `json:"healthy"`
+	Drained bool    `json:"drained"`
+}
+
+type debugState struct {
+	MaxReplica int           `json:"max_replica"`
+	Members    []debugMember `json:"members"`
+}
+
+func (r *ring) debugState() debugState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var total int
+	for _, w := range r.weights {
+		total += w
+	}
+	s := debugState{MaxReplica: r.config.MaxReplica}
+	for addr := range r.addrs {
+		w := r.weights[addr]
+		s.Members = append(s.Members, debugMember{
+			Addr:    addr,
+			Weight:  w,
+			Share:   float64(w) / float64(total),
+			Healthy: r.healthy.Has(addr),
+			Drained: r.drained.Has(addr),
+		})
+	}
+	sort.Slice(s.Members, func(i, j int) bool { return s.Members[i].Addr < s.Members[j].Addr })
+	return s
+}
+
+// debugOwner is a member of the replica set of a digest as rendered by
+// DebugHandler.
+type debugOwner struct {
+	Addr    string `json:"addr"`
+	Healthy bool   `json:"healthy"`
+}
+
+type debugOwnership struct {
+	Digest     string `json:"digest"`
+	MaxReplica int    `json:"max_replica"`
+
+	// Owners is the replica set of the digest, i.e. the first MaxReplica
+	// members in hash order, regardless of health. Drained members are
+	// skipped, since they give up their position on the ring.
+	Owners []debugOwner `json:"owners"`
+
+	// Locations are the addresses the digest is currently served from, which
+	// exclude unhealthy owners (see Locations).
+	Locations []string `json:"locations"`
+
+	// Satisfied is true if HealthyReplicas reaches MaxReplica, or the number
+	// of members if the ring is smaller.
+	HealthyReplicas int  `json:"healthy_replicas"`
+	Satisfied       bool `json:"satisfied"`
+}
+
+func (r *ring) debugOwnership(d core.Digest) debugOwnership {
+	r.mu.RLock()
+	nodes := r.hash.GetOrderedNodes(d.ShardID(), len(r.addrs))
+	o := debugOwnership{Digest: d.String(), MaxReplica: r.config.MaxReplica}
+	var members int
+	for _, n := range nodes {
+		if r.drained.Has(n.Label) {
+			continue
+		}
+		members++
+		if len(o.Owners) == r.config.MaxReplica {
+			continue
+		}
+		healthy := r.healthy.Has(n.Label)
+		o.Owners = append(o.Owners, debugOwner{Addr: n.Label, Healthy: healthy})
+		if healthy {
+			o.HealthyReplicas++
+		}
+	}
+	r.mu.RUnlock()
+
+	required := r.config.MaxReplica
+	if members < required {
+		required = members
+	}
+	o.Satisfied = o.HealthyReplicas >= required
+	o.Locations = r.Locations(d)
+	return o
+}
+
+func writeDebugJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (r *ring) DebugHandler() http.Handler {
+	m := chi.NewRouter()
+	m.Get("/", func(w http.ResponseWriter, req *http.Request) {
+		writeDebugJSON(w, r.debugState())
+	})
+	m.Get("/{digest}", func(w http.ResponseWriter, req *http.Request) {
+		raw := chi.URLParam(req, "digest")
+		if !strings.Contains(raw, ":") {
+			raw = core.SHA256 + ":" + raw
+		}
+		d, err := core.ParseSHA256Digest(raw)
+		if err != nil {
+			http.Error(w, "invalid digest: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeDebugJSON(w, r.debugOwnership(d))
+	})
+	return m
+}
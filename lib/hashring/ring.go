@@ -15,6 +15,7 @@ package hashring
 
 import (
 	"log"
+	"net/http"
 	"sync"
 	"time"
 
@@ -48,6 +49,16 @@ type Ring interface {
 	Contains(addr string) bool
 	Monitor(stop <-chan struct{})
 	Refresh()
+
+	// Drain excludes addr from the locations of every digest. Unlike
+	// unhealthy addresses, drained addresses give up their position on the
+	// ring, such that the next addresses take over their digests.
+	Drain(addr string)
+
+	// DebugHandler returns a read-only http.Handler which renders the
+	// current members of the ring with their effective weights as JSON at /,
+	// and the replica set of a digest with its health at /{digest}.
+	DebugHandler() http.Handler
 }
 
 type ring struct {
@@ -57,10 +68,13 @@ type ring struct {
 
 	mu      sync.RWMutex // Protects the following fields:
 	addrs   stringset.Set
+	weights map[string]int
 	hash    *hrw.RendezvousHash
 	healthy stringset.Set
+	drained stringset.Set
 
 	watchers []Watcher
+	weight   func(addr string) int
 }
 
 // Option allows setting custom parameters for ring.
@@ -71,6 +85,15 @@ func WithWatcher(w Watcher) Option {
 	return func(r *ring) { r.watchers = append(r.watchers, w) }
 }
 
+// WithWeights sets the weight of each address in the ring, e.g. derived from
+// SRV weights or hostlist.Host capacity hints, such that heavier addresses own
+// proportionally more digests. Addresses for which weight returns a
+// non-positive value get the weight from Config.Weights, if any, else the
+// default weight. Weights are re-evaluated on every Refresh.
+func WithWeights(weight func(addr string) int) Option {
+	return func(r *ring) { r.weight = weight }
+}
+
 // New creates a new Ring whose members are defined by cluster.
 func New(
 	config Config, cluster hostlist.List, filter healthcheck.Filter, opts ...Option) Ring {
@@ -80,6 +103,7 @@ func New(
 		config:  config,
 		cluster: cluster,
 		filter:  filter,
+		drained: stringset.New(),
 	}
 	for _, opt := range opts {
 		opt(r)
@@ -92,7 +116,8 @@ func New(
 // If all addresses in the replica set are unhealthy, then returns the next
 // healthy address. If all addresses in the ring are unhealthy, then returns
 // the first address which owns d (regardless of health). As such, Locations
-// always returns a non-empty list.
+// always returns a non-empty list. Drained addresses are never returned,
+// unless every address is drained.
 func (r *ring) Locations(d core.Digest) []string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -102,6 +127,17 @@ func (r *ring) Locations(d core.Digest) []string {
 		// This should never happen.
 		log.Fatal("invariant violation: ordered hash nodes not equal to cluster size")
 	}
+	if len(r.drained) > 0 {
+		var remaining []*hrw.RendezvousHashNode
+		for _, n := range nodes {
+			if !r.drained.Has(n.Label) {
+				remaining = append(remaining, n)
+			}
+		}
+		if len(remaining) > 0 {
+			nodes = remaining
+		}
+	}
 
 	if len(r.healthy) == 0 {
 		return []string{nodes[0].Label}
@@ -125,13 +161,22 @@ func (r *ring) Contains(addr string) bool {
 	return r.addrs.Has(addr)
 }
 
-// Monitor refreshes the ring at the configured interval. Blocks until the
-// provided stop channel is closed.
+// Monitor refreshes the ring at the configured interval. If the cluster is a
+// hostlist.Subscriber, the ring is additionally refreshed as soon as membership
+// changes. Blocks until the provided stop channel is closed.
 func (r *ring) Monitor(stop <-chan struct{}) {
+	var changes <-chan stringset.Set
+	if s, ok := r.cluster.(hostlist.Subscriber); ok {
+		var unsubscribe func()
+		changes, unsubscribe = s.Subscribe()
+		defer unsubscribe()
+	}
 	for {
 		select {
 		case <-stop:
 			return
+		case <-changes:
+			r.Refresh()
 		case <-time.After(r.config.RefreshInterval):
 			r.Refresh()
 		}
@@ -144,13 +189,18 @@ func (r *ring) Refresh() {
 
 	healthy := r.filter.Run(latest)
 
+	weights := r.getWeights(latest)
+
 	hash := r.hash
-	if !stringset.Equal(r.addrs, latest) {
-		// Membership has changed -- update hash nodes.
+	membershipChanged := !stringset.Equal(r.addrs, latest)
+	if membershipChanged || !equalWeights(r.weights, weights) {
+		// Membership or weights have changed -- update hash nodes.
 		hash = hrw.NewRendezvousHash(hrw.Murmur3Hash, hrw.UInt64ToFloat64)
 		for addr := range latest {
-			hash.AddNode(addr, _defaultWeight)
+			hash.AddNode(addr, weights[addr])
 		}
+	}
+	if membershipChanged {
 		// Notify watchers.
 		for _, w := range r.watchers {
 			w.Notify(latest.Copy())
@@ -159,7 +209,44 @@ func (r *ring) Refresh() {
 
 	r.mu.Lock()
 	r.addrs = latest
+	r.weights = weights
 	r.hash = hash
 	r.healthy = healthy
 	r.mu.Unlock()
 }
+
+func (r *ring) Drain(addr string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.drained.Add(addr)
+}
+
+func (r *ring) getWeights(addrs stringset.Set) map[string]int {
+	weights := make(map[string]int, len(addrs))
+	for addr := range addrs {
+		w := _defaultWeight
+		if configured := r.config.Weights[addr]; configured > 0 {
+			w = configured
+		}
+		if r.weight != nil {
+			if custom := r.weight(addr); custom > 0 {
+				w = custom
+			}
+		}
+		weights[addr] = w
+	}
+	return weights
+}
+
+func equalWeights(a, b map[string]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
@@ -14,6 +14,9 @@
 package hashring
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -99,6 +102,32 @@ func TestRingLocationsFiltersOutUnhealthyHosts(t *testing.T) {
 	require.Equal(replicas[1:], result)
 }
 
+func TestRingDrainHandsOverToNextHosts(t *testing.T) {
+	require := require.New(t)
+
+	addrs := addrsFixture(10)
+
+	r := New(
+		Config{MaxReplica: 3},
+		hostlist.Fixture(addrs...),
+		healthcheck.IdentityFilter{})
+
+	d := core.DigestFixture()
+
+	all := New(
+		Config{MaxReplica: len(addrs)},
+		hostlist.Fixture(addrs...),
+		healthcheck.IdentityFilter{}).Locations(d)
+
+	r.Drain(all[0])
+	require.Equal(all[1:4], r.Locations(d))
+	require.True(r.Contains(all[0]))
+
+	// Drains survive refreshes.
+	r.Refresh()
+	require.Equal(all[1:4], r.Locations(d))
+}
+
 func TestRingLocationsReturnsNextHealthyHostWhenReplicaSetUnhealthy(t *testing.T) {
 	require := require.New(t)
 
@@ -273,3 +302,158 @@ func TestRingNotifiesWatchersOnMembershipChanges(t *testing.T) {
 	r.Refresh()
 	r.Refresh()
 }
+
+func TestRingLocationsWeightedDistribution(t *testing.T) {
+	require := require.New(t)
+
+	addrs := addrsFixture(2)
+	heavy, light := addrs[0], addrs[1]
+
+	weights := map[string]int{heavy: 300}
+	r := New(
+		Config{MaxReplica: 1},
+		hostlist.Fixture(addrs...),
+		healthcheck.IdentityFilter{},
+		WithWeights(func(addr string) int { return weights[addr] }))
+
+	sampleSize := 4000
+
+	counts := make(map[string]int)
+	for i := 0; i < sampleSize; i++ {
+		counts[r.Locations(core.DigestFixture())[0]]++
+	}
+	// Light host has the default weight of 100, i.e. a third of heavy.
+	require.InDelta(0.75, float64(counts[heavy])/float64(sampleSize), 0.05)
+	require.InDelta(0.25, float64(counts[light])/float64(sampleSize), 0.05)
+
+	// Weight changes apply on refresh, even if membership is unchanged.
+	weights[light] = 300
+	r.Refresh()
+
+	counts = make(map[string]int)
+	for i := 0; i < sampleSize; i++ {
+		counts[r.Locations(core.DigestFixture())[0]]++
+	}
+	require.InDelta(0.5, float64(counts[heavy])/float64(sampleSize), 0.05)
+}
+
+func TestRingConfigWeights(t *testing.T) {
+	require := require.New(t)
+
+	addrs := addrsFixture(3)
+
+	r := New(
+		Config{MaxReplica: 1, Weights: map[string]int{addrs[0]: 200, addrs[1]: 400}},
+		hostlist.Fixture(addrs...),
+		healthcheck.IdentityFilter{},
+		WithWeights(func(addr string) int {
+			if addr == addrs[1] {
+				return 300
+			}
+			return 0
+		}))
+
+	rec := httptest.NewRecorder()
+	r.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	require.Equal(http.StatusOK, rec.Code)
+
+	var state debugState
+	require.NoError(json.Unmarshal(rec.Body.Bytes(), &state))
+	require.Equal(1, state.MaxReplica)
+
+	weights := make(map[string]int)
+	shares := make(map[string]float64)
+	for _, m := range state.Members {
+		require.True(m.Healthy)
+		weights[m.Addr] = m.Weight
+		shares[m.Addr] = m.Share
+	}
+	// The callback takes precedence over config, which takes precedence over
+	// the default weight.
+	require.Equal(map[string]int{addrs[0]: 200, addrs[1]: 300, addrs[2]: 100}, weights)
+	require.InDelta(1.0/3, shares[addrs[0]], 0.001)
+	require.InDelta(0.5, shares[addrs[1]], 0.001)
+	require.InDelta(1.0/6, shares[addrs[2]], 0.001)
+}
+
+func TestRingMonitorSubscribesToMembershipChanges(t *testing.T) {
+	require := require.New(t)
+
+	cluster, err := hostlist.New(hostlist.Config{Static: []string{"x:80"}})
+	require.NoError(err)
+
+	r := New(
+		Config{RefreshInterval: time.Hour},
+		cluster,
+		healthcheck.IdentityFilter{})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go r.Monitor(stop)
+
+	d := core.DigestFixture()
+	require.Equal([]string{"x:80"}, r.Locations(d))
+
+	require.NoError(cluster.SetStatic([]string{"y:80"}))
+	require.NoError(cluster.Refresh())
+
+	require.Eventually(func() bool {
+		locs := r.Locations(d)
+		return len(locs) == 1 && locs[0] == "y:80"
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRingDebugOwnership(t *testing.T) {
+	require := require.New(t)
+
+	addrs := addrsFixture(4)
+	filter := healthcheck.NewManualFilter()
+
+	r := New(Config{MaxReplica: 2}, hostlist.Fixture(addrs...), filter)
+
+	d := core.DigestFixture()
+	ordered := New(Config{MaxReplica: 4}, hostlist.Fixture(addrs...), healthcheck.IdentityFilter{}).Locations(d)
+
+	getOwnership := func(path string) debugOwnership {
+		rec := httptest.NewRecorder()
+		r.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		require.Equal(http.StatusOK, rec.Code)
+		var o debugOwnership
+		require.NoError(json.Unmarshal(rec.Body.Bytes(), &o))
+		return o
+	}
+
+	o := getOwnership("/" + d.Hex())
+	require.Equal(d.String(), o.Digest)
+	require.Equal([]debugOwner{{ordered[0], true}, {ordered[1], true}}, o.Owners)
+	require.Equal(ordered[:2], o.Locations)
+	require.Equal(2, o.HealthyReplicas)
+	require.True(o.Satisfied)
+
+	// Unhealthy owners stay in the replica set, but are not locations.
+	filter.Unhealthy.Add(ordered[0])
+	r.Refresh()
+	o = getOwnership("/" + d.String())
+	require.Equal([]debugOwner{{ordered[0], false}, {ordered[1], true}}, o.Owners)
+	require.Equal([]string{ordered[1]}, o.Locations)
+	require.Equal(1, o.HealthyReplicas)
+	require.False(o.Satisfied)
+
+	// Drained owners give up their position.
+	r.Drain(ordered[0])
+	o = getOwnership("/" + d.Hex())
+	require.Equal([]debugOwner{{ordered[1], true}, {ordered[2], true}}, o.Owners)
+	require.True(o.Satisfied)
+
+	rec := httptest.NewRecorder()
+	r.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	var state debugState
+	require.NoError(json.Unmarshal(rec.Body.Bytes(), &state))
+	for _, m := range state.Members {
+		require.Equal(m.Addr == ordered[0], m.Drained)
+	}
+
+	rec = httptest.NewRecorder()
+	r.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/foo", nil))
+	require.Equal(http.StatusBadRequest, rec.Code)
+}
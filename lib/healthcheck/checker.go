@@ -17,6 +17,7 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"net"
 
 	"github.com/uber/kraken/utils/httputil"
 )
@@ -40,3 +41,20 @@ func (c defaultChecker) Check(ctx context.Context, addr string) error {
 		httputil.SendTLS(c.tls))
 	return err
 }
+
+// Dial returns a Checker which considers an address healthy if a TCP connection
+// can be established to it.
+func Dial() Checker {
+	return dialChecker{}
+}
+
+type dialChecker struct{}
+
+func (c dialChecker) Check(ctx context.Context, addr string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -63,8 +63,13 @@ type PassiveFilterConfig struct {
 	// FailTimeout is the window of time during which Fails must occur for a host
 	// to be marked as unhealthy.
 	//
-	// FailTimeout is also the time for which a server is marked unhealthy.
+	// FailTimeout is also the time for which a server is marked unhealthy,
+	// unless RecoveryWindow is set.
 	FailTimeout time.Duration `yaml:"fail_timeout"`
+
+	// RecoveryWindow is the time for which a host is marked unhealthy before
+	// it is re-added to the resolved set. Defaults to FailTimeout.
+	RecoveryWindow time.Duration `yaml:"recovery_window"`
 }
 
 func (c *PassiveFilterConfig) applyDefaults() {
@@ -74,4 +79,7 @@ func (c *PassiveFilterConfig) applyDefaults() {
 	if c.FailTimeout == 0 {
 		c.FailTimeout = 5 * time.Minute
 	}
+	if c.RecoveryWindow == 0 {
+		c.RecoveryWindow = c.FailTimeout
+	}
 }
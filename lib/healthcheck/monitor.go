@@ -19,6 +19,8 @@ import (
 
 	"github.com/uber/kraken/lib/hostlist"
 	"github.com/uber/kraken/utils/stringset"
+
+	"github.com/uber-go/tally"
 )
 
 // Monitor performs active health checks asynchronously. Can be used in
@@ -27,6 +29,7 @@ type Monitor struct {
 	config MonitorConfig
 	hosts  hostlist.List
 	filter Filter
+	stats  tally.Scope
 
 	mu      sync.RWMutex
 	healthy stringset.Set
@@ -37,12 +40,14 @@ type Monitor struct {
 var _ hostlist.List = (*Monitor)(nil)
 
 // NewMonitor monitors the health of hosts using filter.
-func NewMonitor(config MonitorConfig, hosts hostlist.List, filter Filter) *Monitor {
+func NewMonitor(config MonitorConfig, hosts hostlist.List, filter Filter, opts ...Option) *Monitor {
 	config.applyDefaults()
+	o := applyOptions(opts)
 	m := &Monitor{
 		config:  config,
 		hosts:   hosts,
 		filter:  filter,
+		stats:   o.stats.Tagged(map[string]string{"check": "active"}),
 		healthy: hosts.Resolve(),
 		stop:    make(chan struct{}),
 	}
@@ -58,6 +63,13 @@ func (m *Monitor) Resolve() stringset.Set {
 	return m.healthy
 }
 
+// All returns the latest hosts being monitored, regardless of their health.
+// Unhealthy hosts are only removed from All once they are removed from the
+// underlying host list.
+func (m *Monitor) All() stringset.Set {
+	return m.hosts.Resolve()
+}
+
 // Stop stops the monitor.
 func (m *Monitor) Stop() {
 	close(m.stop)
@@ -69,7 +81,9 @@ func (m *Monitor) loop() {
 		case <-m.stop:
 			return
 		case <-time.After(m.config.Interval):
-			healthy := m.filter.Run(m.hosts.Resolve())
+			all := m.hosts.Resolve()
+			healthy := m.filter.Run(all)
+			m.stats.Gauge("ejected_hosts").Update(float64(len(all) - len(healthy)))
 			m.mu.Lock()
 			m.healthy = healthy
 			m.mu.Unlock()
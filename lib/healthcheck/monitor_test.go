@@ -49,4 +49,5 @@ func TestActiveMonitor(t *testing.T) {
 	time.Sleep(1250 * time.Millisecond)
 
 	require.Equal(stringset.New(x), m.Resolve())
+	require.Equal(stringset.New(x, y), m.All())
 }
@@ -0,0 +1,43 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package healthcheck
+
+import (
+	"github.com/uber-go/tally"
+)
+
+type options struct {
+	stats tally.Scope
+}
+
+// Option configures Monitor and Passive.
+type Option func(*options)
+
+// WithStats configures Monitor and Passive to report the number of hosts they
+// currently filter out as unhealthy.
+func WithStats(stats tally.Scope) Option {
+	return func(o *options) {
+		o.stats = stats.Tagged(map[string]string{
+			"module": "healthcheck",
+		})
+	}
+}
+
+func applyOptions(opts []Option) options {
+	o := options{stats: tally.NoopScope}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
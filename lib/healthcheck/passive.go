@@ -16,6 +16,8 @@ package healthcheck
 import (
 	"github.com/uber/kraken/lib/hostlist"
 	"github.com/uber/kraken/utils/stringset"
+
+	"github.com/uber-go/tally"
 )
 
 // Passive wraps a passive health check and can be used as a hostlist.List. See
@@ -23,11 +25,13 @@ import (
 type Passive struct {
 	hosts  hostlist.List
 	filter PassiveFilter
+	stats  tally.Scope
 }
 
 // NewPassive returns a new Passive.
-func NewPassive(hosts hostlist.List, filter PassiveFilter) *Passive {
-	return &Passive{hosts, filter}
+func NewPassive(hosts hostlist.List, filter PassiveFilter, opts ...Option) *Passive {
+	o := applyOptions(opts)
+	return &Passive{hosts, filter, o.stats.Tagged(map[string]string{"check": "passive"})}
 }
 
 // Resolve returns the latest healthy hosts. If all hosts are unhealthy, returns
@@ -35,6 +39,7 @@ func NewPassive(hosts hostlist.List, filter PassiveFilter) *Passive {
 func (p *Passive) Resolve() stringset.Set {
 	all := p.hosts.Resolve()
 	healthy := p.filter.Run(all)
+	p.stats.Gauge("ejected_hosts").Update(float64(len(all) - len(healthy)))
 	if len(healthy) == 0 {
 		return all
 	}
@@ -58,8 +58,11 @@ func (f *passiveFilter) Run(addrs stringset.Set) stringset.Set {
 	healthy := addrs.Copy()
 
 	for addr, t := range f.unhealthy {
-		if f.clk.Now().Sub(t) > f.config.FailTimeout {
+		if f.clk.Now().Sub(t) > f.config.RecoveryWindow {
+			// Recovered hosts start with a clean slate, such that a single
+			// failure does not immediately mark them unhealthy again.
 			delete(f.unhealthy, addr)
+			delete(f.failures, addr)
 		} else {
 			healthy.Remove(addr)
 		}
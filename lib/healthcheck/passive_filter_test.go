@@ -97,3 +97,35 @@ func TestPassiveFilterFailTimeoutAfterUnhealthy(t *testing.T) {
 	// Timeout has now elapsed, host is healthy again.
 	require.Equal(stringset.New(x, y), f.Run(s))
 }
+
+func TestPassiveFilterRecoveryWindow(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+
+	f := NewPassiveFilter(
+		PassiveFilterConfig{Fails: 3, FailTimeout: 10 * time.Second, RecoveryWindow: 30 * time.Second},
+		clk)
+
+	x := "x:80"
+	y := "y:80"
+	s := stringset.New(x, y)
+
+	for i := 0; i < 3; i++ {
+		f.Failed(x)
+	}
+
+	clk.Add(11 * time.Second)
+
+	// Fail timeout has elapsed, but host is still within its recovery window.
+	require.Equal(stringset.New(y), f.Run(s))
+
+	clk.Add(20 * time.Second)
+
+	require.Equal(stringset.New(x, y), f.Run(s))
+
+	// Failures from before the ejection do not count against the recovered
+	// host.
+	f.Failed(x)
+	require.Equal(stringset.New(x, y), f.Run(s))
+}
@@ -18,6 +18,7 @@ import (
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
 
 	"github.com/uber/kraken/lib/hostlist"
 	"github.com/uber/kraken/mocks/lib/healthcheck"
@@ -75,3 +76,31 @@ func TestPassiveFailed(t *testing.T) {
 
 	p.Failed(x)
 }
+
+func TestPassiveEjectedHostsStats(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	filter := mockhealthcheck.NewMockPassiveFilter(ctrl)
+
+	x := "x:80"
+	y := "y:80"
+
+	stats := tally.NewTestScope("", nil)
+
+	p := NewPassive(hostlist.Fixture(x, y), filter, WithStats(stats))
+
+	filter.EXPECT().Run(stringset.New(x, y)).Return(stringset.New(x))
+
+	require.Equal(stringset.New(x), p.Resolve())
+
+	gauges := stats.Snapshot().Gauges()
+	require.Len(gauges, 1)
+	for _, g := range gauges {
+		require.Equal("ejected_hosts", g.Name())
+		require.Equal(map[string]string{"module": "healthcheck", "check": "passive"}, g.Tags())
+		require.Equal(float64(1), g.Value())
+	}
+}
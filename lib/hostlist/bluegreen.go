@@ -0,0 +1,106 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/uber/kraken/utils/stringset"
+)
+
+// Pool names of a BlueGreenList.
+const (
+	PoolBlue  = "blue"
+	PoolGreen = "green"
+)
+
+// BlueGreenConfig defines two fully separate host pools, of which only the
+// Active pool is resolved by a BlueGreenList.
+type BlueGreenConfig struct {
+	Blue  Config `yaml:"blue"`
+	Green Config `yaml:"green"`
+
+	// Active is the pool initially resolved, either "blue" or "green".
+	// Defaults to "blue".
+	Active string `yaml:"active"`
+}
+
+// BlueGreenList is a List which resolves one of two pools, and can atomically
+// switch between them, e.g. to cut over a blue/green deploy.
+type BlueGreenList struct {
+	pools map[string]List
+
+	mu     sync.RWMutex
+	active string
+}
+
+// NewBlueGreen creates a new BlueGreenList. Both pools are resolved up front,
+// and an error is returned if either fails.
+func NewBlueGreen(config BlueGreenConfig) (*BlueGreenList, error) {
+	if config.Active == "" {
+		config.Active = PoolBlue
+	}
+	blue, err := New(config.Blue)
+	if err != nil {
+		return nil, fmt.Errorf("blue: %s", err)
+	}
+	green, err := New(config.Green)
+	if err != nil {
+		return nil, fmt.Errorf("green: %s", err)
+	}
+	l := &BlueGreenList{pools: map[string]List{PoolBlue: blue, PoolGreen: green}}
+	if err := l.Activate(config.Active); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Resolve returns the addresses of the active pool. The inactive pool is
+// refreshed alongside it, such that it is warm when activated.
+func (l *BlueGreenList) Resolve() stringset.Set {
+	l.mu.RLock()
+	active := l.active
+	l.mu.RUnlock()
+
+	var result stringset.Set
+	for name, pool := range l.pools {
+		addrs := pool.Resolve()
+		if name == active {
+			result = addrs
+		}
+	}
+	return result
+}
+
+// Activate atomically switches Resolve to the given pool, either "blue" or
+// "green".
+func (l *BlueGreenList) Activate(pool string) error {
+	if _, ok := l.pools[pool]; !ok {
+		return fmt.Errorf("unknown pool %q, expected %q or %q", pool, PoolBlue, PoolGreen)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.active = pool
+	return nil
+}
+
+// Active returns the name of the active pool.
+func (l *BlueGreenList) Active() string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.active
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"testing"
+
+	"github.com/uber/kraken/utils/stringset"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlueGreenList(t *testing.T) {
+	require := require.New(t)
+
+	l, err := NewBlueGreen(BlueGreenConfig{
+		Blue:  Config{Static: []string{"blue:80"}},
+		Green: Config{Static: []string{"green:80"}},
+	})
+	require.NoError(err)
+	require.Equal(PoolBlue, l.Active())
+	require.Equal(stringset.New("blue:80"), l.Resolve())
+
+	require.NoError(l.Activate(PoolGreen))
+	require.Equal(stringset.New("green:80"), l.Resolve())
+
+	require.NoError(l.Activate(PoolBlue))
+	require.Equal(stringset.New("blue:80"), l.Resolve())
+
+	require.Error(l.Activate("red"))
+	require.Equal(PoolBlue, l.Active())
+}
+
+func TestNewBlueGreenErrors(t *testing.T) {
+	valid := Config{Static: []string{"a:80"}}
+	tests := []struct {
+		desc   string
+		config BlueGreenConfig
+	}{
+		{"invalid blue", BlueGreenConfig{Blue: Config{}, Green: valid}},
+		{"invalid green", BlueGreenConfig{Blue: valid, Green: Config{}}},
+		{"invalid active", BlueGreenConfig{Blue: valid, Green: valid, Active: "red"}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := NewBlueGreen(test.config)
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"time"
+
+	"github.com/uber/kraken/utils/log"
+	"github.com/uber/kraken/utils/stringset"
+)
+
+// withBudget bounds ctx by the resolution budget, if any.
+func withBudget(ctx context.Context, budget time.Duration) (context.Context, context.CancelFunc) {
+	if budget == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, budget)
+}
+
+// resolveWithBudget resolves r within budget.
+func resolveWithBudget(ctx context.Context, r resolver, budget time.Duration) (stringset.Set, error) {
+	ctx, cancel := withBudget(ctx, budget)
+	defer cancel()
+
+	addrs, err := r.resolve(ctx)
+	warnIfBudgetExpired(ctx, r, err)
+	return addrs, err
+}
+
+// warnIfBudgetExpired warns if r resolved successfully despite ctx expiring,
+// i.e. if the result is the partial result gathered before the deadline.
+func warnIfBudgetExpired(ctx context.Context, r resolver, err error) {
+	if err == nil && ctx.Err() == context.DeadlineExceeded {
+		log.With("source", r).Warn("Resolve budget expired, using partial result")
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/utils/stringset"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingResolver blocks until its context is done.
+type blockingResolver struct{}
+
+func (blockingResolver) resolve(ctx context.Context) (stringset.Set, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestResolveBudgetReturnsPartialResult(t *testing.T) {
+	require := require.New(t)
+
+	fast := &dnsResolver{
+		dns:  "fast-dns",
+		port: 80,
+		clk:  clock.New(),
+		lookup: func(ctx context.Context, host string) ([]string, error) {
+			return []string{"10.0.0.1"}, nil
+		},
+	}
+	slow := &dnsResolver{
+		dns:  "slow-dns",
+		port: 80,
+		clk:  clock.New(),
+		lookup: func(ctx context.Context, host string) ([]string, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	r := &multiDNSResolver{resolvers: []*dnsResolver{fast, slow}}
+
+	start := time.Now()
+	addrs, err := resolveWithBudget(context.Background(), r, 50*time.Millisecond)
+	require.NoError(err)
+	require.Equal(stringset.New("10.0.0.1:80"), addrs)
+	require.True(time.Since(start) < 5*time.Second)
+}
+
+func TestResolveBudgetComposesWithContextDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := resolveWithBudget(ctx, blockingResolver{}, time.Hour)
+	require.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestListResolveBudget(t *testing.T) {
+	require := require.New(t)
+
+	l, err := New(Config{Static: []string{"a:80"}, ResolveBudget: 10 * time.Millisecond})
+	require.NoError(err)
+
+	l.(*list).resolver = blockingResolver{}
+	require.Error(l.Refresh())
+	require.Equal(stringset.New("a:80"), l.Resolve())
+}
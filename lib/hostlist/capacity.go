@@ -0,0 +1,73 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/uber/kraken/utils/log"
+)
+
+// DefaultCapacity is the weight of hosts which do not publish a capacity hint.
+const DefaultCapacity = 100
+
+// _capacityPrefix prefixes the capacity hint within a host's TXT records.
+const _capacityPrefix = "kraken-capacity="
+
+type lookupTXTFunc func(ctx context.Context, name string) ([]string, error)
+
+// AttachCapacity sets the Capacity of each host from the
+// "kraken-capacity=<n>" TXT record published under its Name. Hosts which are
+// ip literals, or which publish no valid hint, are left with zero Capacity and
+// thus weigh DefaultCapacity. Lookup failures are logged and never fatal.
+func AttachCapacity(ctx context.Context, hosts []Host) []Host {
+	var nr net.Resolver
+	return attachCapacity(ctx, nr.LookupTXT, hosts)
+}
+
+func attachCapacity(ctx context.Context, lookup lookupTXTFunc, hosts []Host) []Host {
+	result := make([]Host, len(hosts))
+	for i, h := range hosts {
+		result[i] = h
+		if isIPLiteral(h.Name) {
+			continue
+		}
+		records, err := lookup(ctx, h.Name)
+		if err != nil {
+			log.With("host", h.Name).Debugf("Error looking up capacity hint: %s", err)
+			continue
+		}
+		result[i].Capacity = parseCapacity(records)
+	}
+	return result
+}
+
+// parseCapacity returns the first valid capacity hint within records, or zero
+// if there is none.
+func parseCapacity(records []string) int {
+	for _, r := range records {
+		if !strings.HasPrefix(r, _capacityPrefix) {
+			continue
+		}
+		c, err := strconv.Atoi(strings.TrimPrefix(r, _capacityPrefix))
+		if err != nil || c <= 0 {
+			continue
+		}
+		return c
+	}
+	return 0
+}
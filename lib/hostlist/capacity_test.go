@@ -0,0 +1,56 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachCapacity(t *testing.T) {
+	records := map[string][]string{
+		"big":     {"v=spf1 -all", "kraken-capacity=400"},
+		"invalid": {"kraken-capacity=lots", "kraken-capacity=-1"},
+		"none":    {"v=spf1 -all"},
+	}
+	lookup := func(ctx context.Context, name string) ([]string, error) {
+		r, ok := records[name]
+		if !ok {
+			return nil, errors.New("no such host")
+		}
+		return r, nil
+	}
+	hosts := []Host{
+		{Name: "big", Port: 80},
+		{Name: "invalid", Port: 80},
+		{Name: "none", Port: 80},
+		{Name: "missing", Port: 80},
+		{Name: "10.0.0.1", Port: 80},
+	}
+
+	result := attachCapacity(context.Background(), lookup, hosts)
+
+	var weights []int
+	for _, h := range result {
+		weights = append(weights, h.Weight())
+	}
+	require.Equal(t, 400, result[0].Capacity)
+	require.Equal(t, []int{400, 100, 100, 100, 100}, weights)
+
+	// Input is unaffected.
+	require.Equal(t, 0, hosts[0].Capacity)
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/uber/kraken/utils/stringset"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// _defaultMaxCNAMEChain is the default maximum number of CNAMEs which may be
+// followed from a DNS record before reaching its A records.
+const _defaultMaxCNAMEChain = 8
+
+// cnameChainError is returned when a CNAME chain is invalid, as opposed to
+// when it simply could not be looked up.
+type cnameChainError struct {
+	chain []string
+	msg   string
+}
+
+func (e cnameChainError) Error() string {
+	return fmt.Sprintf("cname chain %s: %s", strings.Join(e.chain, " -> "), e.msg)
+}
+
+// lookupCNAMEChain queries the system nameservers for the chain of CNAMEs
+// between name and its A records. The first element of the chain is name, and
+// the last is its canonical name.
+func lookupCNAMEChain(ctx context.Context, name string, max int) ([]string, error) {
+	servers, err := readNameservers(_resolvConf)
+	if err != nil {
+		return nil, fmt.Errorf("read nameservers: %s", err)
+	}
+	var errs []string
+	for _, server := range servers {
+		chain, err := queryCNAMEChain(ctx, net.JoinHostPort(server, "53"), name, max)
+		if err == nil {
+			return chain, nil
+		}
+		if _, ok := err.(cnameChainError); ok {
+			return nil, err
+		}
+		errs = append(errs, fmt.Sprintf("%s: %s", server, err))
+	}
+	return nil, fmt.Errorf("all nameservers failed: %s", strings.Join(errs, ", "))
+}
+
+// queryCNAMEChain traces the CNAME chain of name against server. Servers
+// usually answer with the full chain at once, but if the answer stops short of
+// the A records, the last CNAME target is queried in turn.
+func queryCNAMEChain(ctx context.Context, server, name string, max int) ([]string, error) {
+	chain := []string{canonicalName(name)}
+	for {
+		answers, err := queryA(ctx, server, chain[len(chain)-1])
+		if err != nil {
+			return nil, err
+		}
+		prev := len(chain)
+		var resolved bool
+		chain, resolved, err = followCNAMEs(chain, answers, max)
+		if err != nil {
+			return nil, err
+		}
+		if resolved {
+			return chain, nil
+		}
+		if len(chain) == prev {
+			return nil, fmt.Errorf("no records for %s", chain[len(chain)-1])
+		}
+	}
+}
+
+// followCNAMEs extends chain by following the CNAME answers from its last
+// name, until reaching a name with A records (resolved) or a name for which
+// answers has no records. Returns an error if the chain loops or grows beyond
+// max CNAMEs.
+func followCNAMEs(
+	chain []string, answers []dnsmessage.Resource, max int) ([]string, bool, error) {
+
+	cnames := make(map[string]string)
+	hasA := make(stringset.Set)
+	for _, a := range answers {
+		owner := canonicalName(a.Header.Name.String())
+		switch body := a.Body.(type) {
+		case *dnsmessage.CNAMEResource:
+			cnames[owner] = canonicalName(body.CNAME.String())
+		case *dnsmessage.AResource:
+			hasA.Add(owner)
+		}
+	}
+	seen := stringset.FromSlice(chain)
+	for {
+		current := chain[len(chain)-1]
+		if hasA.Has(current) {
+			return chain, true, nil
+		}
+		next, ok := cnames[current]
+		if !ok {
+			if len(answers) == 0 {
+				return nil, false, errors.New("no answers")
+			}
+			// Chain may continue beyond what the server answered with.
+			return chain, false, nil
+		}
+		chain = append(chain, next)
+		if seen.Has(next) {
+			return nil, false, cnameChainError{chain, "loop detected"}
+		}
+		seen.Add(next)
+		if len(chain)-1 > max {
+			return nil, false, cnameChainError{chain, fmt.Sprintf("exceeds %d cnames", max)}
+		}
+	}
+}
+
+func canonicalName(name string) string {
+	name = strings.ToLower(name)
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	return name
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func cnameAnswer(owner, target string) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(owner), Type: dnsmessage.TypeCNAME},
+		Body:   &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName(target)},
+	}
+}
+
+func aAnswer(owner string) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(owner), Type: dnsmessage.TypeA},
+		Body:   &dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}},
+	}
+}
+
+func TestFollowCNAMEs(t *testing.T) {
+	tests := []struct {
+		desc     string
+		answers  []dnsmessage.Resource
+		max      int
+		chain    []string
+		resolved bool
+	}{
+		{
+			"no cnames",
+			[]dnsmessage.Resource{aAnswer("a.")},
+			8,
+			[]string{"a."},
+			true,
+		}, {
+			"chain",
+			[]dnsmessage.Resource{cnameAnswer("a.", "b."), cnameAnswer("b.", "C."), aAnswer("c.")},
+			8,
+			[]string{"a.", "b.", "c."},
+			true,
+		}, {
+			"chain at max",
+			[]dnsmessage.Resource{cnameAnswer("a.", "b."), cnameAnswer("b.", "c."), aAnswer("c.")},
+			2,
+			[]string{"a.", "b.", "c."},
+			true,
+		}, {
+			"partial chain",
+			[]dnsmessage.Resource{cnameAnswer("a.", "b.")},
+			8,
+			[]string{"a.", "b."},
+			false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			chain, resolved, err := followCNAMEs([]string{"a."}, test.answers, test.max)
+			require.NoError(t, err)
+			require.Equal(t, test.chain, chain)
+			require.Equal(t, test.resolved, resolved)
+		})
+	}
+}
+
+func TestFollowCNAMEsErrors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		answers []dnsmessage.Resource
+		max     int
+	}{
+		{"loop", []dnsmessage.Resource{cnameAnswer("a.", "b."), cnameAnswer("b.", "a.")}, 8},
+		{"too long", []dnsmessage.Resource{cnameAnswer("a.", "b."), cnameAnswer("b.", "c."), aAnswer("c.")}, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, _, err := followCNAMEs([]string{"a."}, test.answers, test.max)
+			require.Error(t, err)
+			_, ok := err.(cnameChainError)
+			require.True(t, ok)
+		})
+	}
+}
+
+func TestDNSResolverTraceCNAMEChain(t *testing.T) {
+	tests := []struct {
+		desc    string
+		err     error
+		wantErr bool
+	}{
+		{"valid chain", nil, false},
+		{"invalid chain", cnameChainError{[]string{"a.", "a."}, "loop detected"}, true},
+		{"lookup failure is ignored", errors.New("timeout"), false},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			r := &dnsResolver{
+				dns:  "some-dns",
+				port: 80,
+				clk:  clock.New(),
+				lookup: func(ctx context.Context, host string) ([]string, error) {
+					return []string{"10.0.0.1"}, nil
+				},
+				maxCNAMEChain: 8,
+				lookupCNAMEChain: func(ctx context.Context, name string, max int) ([]string, error) {
+					if test.err != nil {
+						return nil, test.err
+					}
+					return []string{"some-dns.", "canonical."}, nil
+				},
+			}
+			_, err := r.resolve(context.Background())
+			if test.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
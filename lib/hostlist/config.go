@@ -18,101 +18,963 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/uber/kraken/utils/errutil"
+	"github.com/uber/kraken/utils/log"
 	"github.com/uber/kraken/utils/stringset"
+
+	"github.com/andres-erbsen/clock"
 )
 
-// Config defines a list of hosts using either a DNS record or a static list of
-// addresses. If present, a DNS record always takes precedence over a static
-// list.
+// Config defines a list of hosts using either a DNS record, an SRV record or a
+// static list of addresses. Multiple sources may only be supplied together if
+// Chain defines the order in which they are tried, except that a static list
+// may back an SRV record (see SRV).
 type Config struct {
 	// DNS record from which to resolve host names. Must include port suffix,
 	// which will be attached to each host within the record.
 	DNS string `yaml:"dns"`
 
-	// Statically configured addresses. Must be in 'host:port' format.
+	// DNSRecords optionally supplies multiple DNS records (e.g. one per zone)
+	// in place of DNS, in the same format. Hosts of every record are merged.
+	// Records which fail to resolve or are empty are skipped, unless
+	// RequireAllDNS is set, in which case any such record fails resolution.
+	DNSRecords    []string `yaml:"dns_records"`
+	RequireAllDNS bool     `yaml:"require_all_dns"`
+
+	// SRV optionally supplies a DNS SRV record (e.g.
+	// "_kraken-origin._tcp.example.com") whose target:port pairs are resolved
+	// as addresses, such that each host may publish its own port. If Static is
+	// supplied as well, it is used as a fallback whenever the SRV record fails
+	// to resolve or is empty.
+	SRV string `yaml:"srv"`
+
+	// Statically configured addresses. Must be in 'host:port' format, where
+	// host may be a CIDR block which expands in ascending order (see
+	// ParseEntry), and may be annotated with a '|insecure' suffix to mark hosts
+	// whose TLS certificates should not be verified (see InsecureAddrs).
+	// Entries may end in a '#' comment, e.g. "10.0.0.5:7000 # rack-a origin".
 	Static []string `yaml:"static"`
 
+	// StaticDelimiter splits each Static entry into multiple addresses, e.g. for
+	// templated entries like "a:80,b:80". Set to "whitespace" to split on any
+	// whitespace. Defaults to ",".
+	StaticDelimiter string `yaml:"static_delimiter"`
+
+	// StaticFile optionally supplies the static addresses from a file instead,
+	// one 'host:port' address per line. Blank lines and lines starting with
+	// '#' are ignored. The file is re-read on every refresh.
+	//
+	// If StaticFilePublicKey (a base64 encoded ed25519 public key) is set, the
+	// file must be accompanied by a detached signature at StaticFileSignature
+	// (base64 encoded), and is refused if the signature does not verify.
+	StaticFile          string `yaml:"static_file"`
+	StaticFilePublicKey string `yaml:"static_file_public_key"`
+	StaticFileSignature string `yaml:"static_file_signature"`
+
+	// Source optionally selects a SourceProvider registered via RegisterSource,
+	// configured by SourceConfig. Built-in sources are "dns", "static", "file"
+	// (see fileSourceFactory), "consul" (see ConsulConfig) and "etcd" (see
+	// EtcdConfig).
+	Source       string                 `yaml:"source"`
+	SourceConfig map[string]interface{} `yaml:"source_config"`
+
+	// Views optionally defines alternative sources per view of a split-horizon
+	// deployment, e.g. "internal" and "external" DNS records for the same
+	// logical hosts. See ForView.
+	Views map[string]View `yaml:"views"`
+
+	// Chain optionally defines an ordered list of sources (e.g. ["dns",
+	// "static"], or the name of Source) to resolve from. Each source is tried
+	// in turn, and the first one which resolves to a non-empty set of
+	// addresses is used. Sources are never merged, so ["dns", "static"] only
+	// uses static hosts while DNS resolves no addresses.
+	Chain []string `yaml:"chain"`
+
+	// TakeFirst and TakeLast optionally truncate the addresses resolved by
+	// BuildOrdered to the first or last n addresses, respectively. At most
+	// one may be set. Ignored by List, since its addresses are unordered.
+	TakeFirst int `yaml:"take_first"`
+	TakeLast  int `yaml:"take_last"`
+
+	// ResolveBudget, if set, caps the total time of each resolution (i.e. each
+	// refresh, or BuildOrdered) across all sources, records and lookups. It
+	// composes with the timeouts of individual lookups, whichever expires
+	// first. Sources which tolerate partial failure, such as DNSRecords without
+	// RequireAllDNS, return what they resolved before the budget expired, with
+	// a warning.
+	ResolveBudget time.Duration `yaml:"resolve_budget"`
+
+	// NegativeTTL, if set, defines how long a DNS record which does not exist
+	// (i.e. NXDOMAIN) is remembered before being looked up again. Temporary
+	// lookup failures are not cached. See DynamicList.Refresh to bypass.
+	NegativeTTL time.Duration `yaml:"negative_ttl"`
+
+	// ForcePort, if set, makes DynamicList.SetPort replace the port of every
+	// resolved address, including addresses with explicit ports.
+	ForcePort bool `yaml:"force_port"`
+
 	// TTL defines how long resolved host lists are cached for.
 	TTL time.Duration `yaml:"ttl"`
+
+	// RefreshInterval, if set, refreshes the list in the background at this
+	// interval, such that subscribers and OnRefresh callbacks are notified of
+	// changes even if the list is never resolved. See WithBackgroundRefresh.
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+
+	// DNSRecordTTL, if set, caches hosts resolved from DNS for as long as the
+	// TTL of the DNS record itself, clamped to [MinTTL, MaxTTL]. TTL is used
+	// whenever the record TTL cannot be determined.
+	DNSRecordTTL bool          `yaml:"dns_record_ttl"`
+	MinTTL       time.Duration `yaml:"min_ttl"`
+	MaxTTL       time.Duration `yaml:"max_ttl"`
+
+	// SecondaryDNSServer optionally defines a nameserver ('host:port') which
+	// DNS is also resolved against, purely to detect inconsistencies such as
+	// a stale secondary or split-horizon records. A warning is logged when the
+	// answers differ by more than DNSMismatchTolerance names. The primary
+	// result is always used, and secondary failures are ignored.
+	SecondaryDNSServer   string `yaml:"secondary_dns_server"`
+	DNSMismatchTolerance int    `yaml:"dns_mismatch_tolerance"`
+
+	// TraceCNAME, if set, additionally traces the CNAME chain of DNS on every
+	// resolution, and records it in the resolution trace (see Tracer) and the
+	// debug log. Resolution fails if the chain loops or is longer than
+	// MaxCNAMEChain, which defaults to 8. Off by default, since it costs an
+	// extra query.
+	TraceCNAME    bool `yaml:"trace_cname"`
+	MaxCNAMEChain int  `yaml:"max_cname_chain"`
+
+	// RequireFCrDNS, if set, drops every address resolved from DNS which is not
+	// forward-confirmed, i.e. whose reverse (PTR) names do not resolve back to
+	// the address. Dropped addresses are logged. This guards against poisoned
+	// records in untrusted networks, but costs two extra lookups per address,
+	// each bounded by a timeout, so it is off by default.
+	RequireFCrDNS bool `yaml:"require_fcrdns"`
+
+	// AddressFamily optionally restricts resolved ip addresses to FamilyIPv4
+	// or FamilyIPv6. Host names are never filtered. FamilyBoth keeps every
+	// address, and signals that Hosts should be expanded with SplitDualStack.
+	// DNSAddressFamily and StaticAddressFamily override AddressFamily for
+	// their respective source.
+	AddressFamily       string `yaml:"address_family"`
+	DNSAddressFamily    string `yaml:"dns_address_family"`
+	StaticAddressFamily string `yaml:"static_address_family"`
 }
 
 func (c *Config) applyDefaults() {
 	if c.TTL == 0 {
 		c.TTL = 5 * time.Second
 	}
+	if c.MinTTL == 0 {
+		c.MinTTL = time.Second
+	}
+	if c.MaxTTL == 0 {
+		c.MaxTTL = 5 * time.Minute
+	}
+	if c.MaxCNAMEChain == 0 {
+		c.MaxCNAMEChain = _defaultMaxCNAMEChain
+	}
 }
 
+// Source names which may be used in Config.Chain.
+const (
+	SourceDNS    = "dns"
+	SourceSRV    = "srv"
+	SourceStatic = "static"
+)
+
 // getResolver parses the configuration for which resolver to use.
 func (c *Config) getResolver() (resolver, error) {
-	if c.DNS == "" && len(c.Static) == 0 {
+	if len(c.Chain) > 0 {
+		return c.getChainResolver()
+	}
+	if c.Source != "" {
+		if c.hasDNS() || c.hasStatic() || c.SRV != "" {
+			return nil, errors.New("both source and dns record / srv record / static list supplied")
+		}
+		return c.getSourceResolver()
+	}
+	if c.SRV != "" {
+		if c.hasDNS() {
+			return nil, errors.New("both dns record and srv record supplied")
+		}
+		if c.hasStatic() {
+			// Static list is a fallback.
+			fallback := *c
+			fallback.Chain = []string{SourceSRV, SourceStatic}
+			return fallback.getChainResolver()
+		}
+		return c.getSRVResolver()
+	}
+	if !c.hasDNS() && !c.hasStatic() {
 		return nil, errors.New("no dns record or static list supplied")
 	}
-	if c.DNS != "" && len(c.Static) > 0 {
+	if c.hasDNS() && c.hasStatic() {
 		return nil, errors.New("both dns record and static list supplied")
 	}
+	if c.hasStatic() {
+		return c.getStaticResolver()
+	}
+	return c.getDNSResolver()
+}
+
+// sourceName names the configured source, if not a Chain.
+func (c *Config) sourceName() string {
+	switch {
+	case c.Source != "":
+		return c.Source
+	case c.SRV != "":
+		return SourceSRV
+	case c.hasDNS():
+		return SourceDNS
+	default:
+		return SourceStatic
+	}
+}
+
+func (c *Config) getChainResolver() (resolver, error) {
+	var resolvers []resolver
+	seen := make(stringset.Set)
+	for _, source := range c.Chain {
+		if seen.Has(source) {
+			return nil, fmt.Errorf("duplicate source in chain: %s", source)
+		}
+		seen.Add(source)
+
+		var r resolver
+		var err error
+		switch source {
+		case SourceDNS:
+			if !c.hasDNS() {
+				return nil, errors.New("dns in chain but no dns record supplied")
+			}
+			r, err = c.getDNSResolver()
+		case SourceStatic:
+			if !c.hasStatic() {
+				return nil, errors.New("static in chain but no static list supplied")
+			}
+			r, err = c.getStaticResolver()
+		case SourceSRV:
+			if c.SRV == "" {
+				return nil, errors.New("srv in chain but no srv record supplied")
+			}
+			r, err = c.getSRVResolver()
+		case c.Source:
+			r, err = c.getSourceResolver()
+		default:
+			return nil, fmt.Errorf("unknown source in chain: %s", source)
+		}
+		if err != nil {
+			return nil, err
+		}
+		resolvers = append(resolvers, r)
+	}
+	return &chainResolver{resolvers: resolvers, names: c.Chain}, nil
+}
+
+func (c *Config) getSourceResolver() (resolver, error) {
+	factory, err := getSourceFactory(c.Source)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := factory.Create(c.SourceConfig)
+	if err != nil {
+		return nil, fmt.Errorf("create source %s: %s", c.Source, err)
+	}
+	if r, ok := provider.(resolver); ok {
+		// Built-in sources can be used directly.
+		return r, nil
+	}
+	return &sourceResolver{c.Source, provider}, nil
+}
+
+// hasStatic returns true if static addresses are supplied, either inline or
+// via StaticFile.
+func (c *Config) hasStatic() bool {
+	return len(c.Static) > 0 || c.StaticFile != ""
+}
+
+func (c *Config) getStaticResolver() (resolver, error) {
+	family, err := c.getAddressFamily(c.StaticAddressFamily)
+	if err != nil {
+		return nil, fmt.Errorf("static: %s", err)
+	}
+	if c.StaticFile != "" {
+		if len(c.Static) > 0 {
+			return nil, errors.New("both static list and static file supplied")
+		}
+		return c.getStaticFileResolver(family)
+	}
+	entries, err := parseStatic(c.staticEntries())
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("static list has no addresses")
+	}
+	warnConflictingPorts(entries)
+	return &staticResolver{family: family, entries: entries}, nil
+}
+
+// StaticDelimiterWhitespace splits Static entries on any whitespace.
+const StaticDelimiterWhitespace = "whitespace"
+
+// staticEntries splits each Static entry by StaticDelimiter, ignoring empty
+// addresses.
+func (c *Config) staticEntries() []string {
+	var result []string
+	for _, entry := range c.Static {
+		// Comments may contain delimiters, so strip them first.
+		entry = stripComment(entry)
+		var parts []string
+		switch c.StaticDelimiter {
+		case "":
+			parts = strings.Split(entry, ",")
+		case StaticDelimiterWhitespace:
+			parts = strings.Fields(entry)
+		default:
+			parts = strings.Split(entry, c.StaticDelimiter)
+		}
+		for _, p := range parts {
+			if p = strings.TrimSpace(p); p != "" {
+				result = append(result, p)
+			}
+		}
+	}
+	return result
+}
+
+// _insecureAnnotation marks a static address whose TLS certificate should not
+// be verified.
+const _insecureAnnotation = "insecure"
 
-	if len(c.Static) > 0 {
-		for _, addr := range c.Static {
-			if _, _, err := net.SplitHostPort(addr); err != nil {
-				return nil, fmt.Errorf("invalid static addr: %s", err)
+// InsecureAddrs returns the static addresses annotated with '|insecure'.
+func (c *Config) InsecureAddrs() (stringset.Set, error) {
+	result := make(stringset.Set)
+	for _, entry := range c.staticEntries() {
+		hosts, err := ParseEntry(entry, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range hosts {
+			if h.Insecure {
+				result.Add(h.Addr())
 			}
 		}
-		return &staticResolver{stringset.FromSlice(c.Static)}, nil
 	}
+	return result, nil
+}
+
+// splitAnnotation splits an optional '|insecure' annotation from entry.
+func splitAnnotation(entry string) (addr string, insecure bool, err error) {
+	parts := strings.SplitN(entry, "|", 2)
+	if len(parts) == 1 {
+		return entry, false, nil
+	}
+	if parts[1] != _insecureAnnotation {
+		return "", false, fmt.Errorf("invalid annotation %q of static addr %s", parts[1], parts[0])
+	}
+	return parts[0], true, nil
+}
+
+// parseStatic validates that each entry is in 'host:port' format, removing any
+// annotations. Returns the entries with duplicates removed, preserving order.
+func parseStatic(entries []string) ([]string, error) {
+	var addrs []string
+	var errs []error
+	for _, entry := range entries {
+		hosts, err := ParseEntry(entry, 0)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("static: %s", err))
+			continue
+		}
+		for _, h := range hosts {
+			addrs = append(addrs, h.Addr())
+		}
+	}
+	if err := errutil.Join(errs); err != nil {
+		return nil, err
+	}
+	return dedupOrdered(addrs), nil
+}
+
+// conflictingPorts returns the hosts which appear in addrs with more than one
+// port, mapped to their sorted ports.
+func conflictingPorts(addrs []string) map[string][]string {
+	ports := make(map[string]stringset.Set)
+	for _, addr := range addrs {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			continue
+		}
+		if _, ok := ports[host]; !ok {
+			ports[host] = make(stringset.Set)
+		}
+		ports[host].Add(port)
+	}
+	result := make(map[string][]string)
+	for host, ps := range ports {
+		if len(ps) > 1 {
+			sorted := ps.ToSlice()
+			sort.Strings(sorted)
+			result[host] = sorted
+		}
+	}
+	return result
+}
+
+// warnConflictingPorts logs a warning for each host which appears in addrs
+// with more than one port. This is usually a typo in the static list, but may
+// be intentional, so it is not an error.
+func warnConflictingPorts(addrs []string) {
+	for host, ports := range conflictingPorts(addrs) {
+		log.With("host", host, "ports", ports).Warn(
+			"Host appears in static list with multiple ports, check this is intentional")
+	}
+}
+
+// canonicalIPs applies canonicalIP to each name.
+func canonicalIPs(names []string) []string {
+	result := make([]string, len(names))
+	for i, name := range names {
+		result[i] = canonicalIP(name)
+	}
+	return result
+}
+
+// dedupOrdered removes duplicates from xs, keeping the first occurrence of
+// each element.
+func dedupOrdered(xs []string) []string {
+	seen := make(stringset.Set, len(xs))
+	var result []string
+	for _, x := range xs {
+		if seen.Has(x) {
+			continue
+		}
+		seen.Add(x)
+		result = append(result, x)
+	}
+	return result
+}
+
+// hasDNS returns true if a DNS record is supplied, either via DNS or
+// DNSRecords.
+func (c *Config) hasDNS() bool {
+	return c.DNS != "" || len(c.DNSRecords) > 0
+}
+
+func (c *Config) getDNSResolver() (resolver, error) {
+	if len(c.DNSRecords) == 0 {
+		return c.getDNSRecordResolver(c.DNS)
+	}
+	if c.DNS != "" {
+		return nil, errors.New("both dns and dns records supplied")
+	}
+	r := &multiDNSResolver{requireAll: c.RequireAllDNS}
+	seen := make(stringset.Set)
+	for _, record := range c.DNSRecords {
+		if seen.Has(record) {
+			return nil, fmt.Errorf("duplicate dns record: %s", record)
+		}
+		seen.Add(record)
+		rr, err := c.getDNSRecordResolver(record)
+		if err != nil {
+			return nil, err
+		}
+		r.resolvers = append(r.resolvers, rr)
+	}
+	return r, nil
+}
 
-	dns, rawport, err := net.SplitHostPort(c.DNS)
+func (c *Config) getDNSRecordResolver(record string) (*dnsResolver, error) {
+	dns, rawport, err := net.SplitHostPort(record)
 	if err != nil {
 		return nil, fmt.Errorf("invalid dns: %s", err)
 	}
+	if err := validateHostname(dns); err != nil {
+		return nil, fmt.Errorf("invalid dns: %s", err)
+	}
+	family, err := c.getAddressFamily(c.DNSAddressFamily)
+	if err != nil {
+		return nil, fmt.Errorf("dns: %s", err)
+	}
 	port, err := strconv.Atoi(rawport)
 	if err != nil {
 		return nil, fmt.Errorf("invalid dns port: %s", err)
 	}
-	return &dnsResolver{dns, port}, nil
+	r := &dnsResolver{
+		dns:         dns,
+		port:        port,
+		recordTTL:   c.DNSRecordTTL,
+		negativeTTL: c.NegativeTTL,
+		lookup:      new(net.Resolver).LookupHost,
+		clk:         clock.New(),
+		family:      family,
+		tolerance:   c.DNSMismatchTolerance,
+	}
+	if c.RequireFCrDNS {
+		r.lookupAddr = new(net.Resolver).LookupAddr
+	}
+	if c.TraceCNAME {
+		r.maxCNAMEChain = c.MaxCNAMEChain
+		r.lookupCNAMEChain = lookupCNAMEChain
+	}
+	if c.SecondaryDNSServer != "" {
+		if _, _, err := net.SplitHostPort(c.SecondaryDNSServer); err != nil {
+			return nil, fmt.Errorf("invalid secondary dns server: %s", err)
+		}
+		r.secondary = serverLookup(c.SecondaryDNSServer)
+	}
+	return r, nil
+}
+
+// serverLookup returns a lookupHostFunc which queries server directly instead
+// of the system nameservers.
+func serverLookup(server string) lookupHostFunc {
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+	return r.LookupHost
 }
 
 // resolver resolves parsed configuration into a list of addresses.
 type resolver interface {
-	resolve() (stringset.Set, error)
+	resolve(ctx context.Context) (stringset.Set, error)
+}
+
+// orderedResolver is a resolver which can preserve the order of resolved
+// addresses, e.g. as configured or as returned by a DNS record.
+type orderedResolver interface {
+	resolver
+	resolveOrdered(ctx context.Context) ([]string, error)
+}
+
+// resolveOrdered resolves r in order if r supports it, else in sorted order.
+func resolveOrdered(ctx context.Context, r resolver) ([]string, error) {
+	if or, ok := r.(orderedResolver); ok {
+		return or.resolveOrdered(ctx)
+	}
+	addrs, err := r.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := addrs.ToSlice()
+	sort.Strings(result)
+	return result, nil
+}
+
+// portResolver is a resolver which attaches a default port to the names it
+// resolves.
+type portResolver interface {
+	resolver
+	setPort(port int)
+}
+
+// negativeCacher is a resolver which caches failed resolutions.
+type negativeCacher interface {
+	resolver
+	clearNegativeCache()
+}
+
+// ttlResolver is a resolver which knows how long its results are valid for.
+type ttlResolver interface {
+	resolver
+	ttl() (time.Duration, bool)
+}
+
+// provenanceResolver is a resolver composed of multiple named sources, which
+// knows how many addresses each source contributed to the latest result.
+type provenanceResolver interface {
+	resolver
+	provenance() map[string]int
+}
+
+// clockSetter is a resolver which depends on time.
+type clockSetter interface {
+	resolver
+	setClock(clk clock.Clock)
+}
+
+// staticSetter is a resolver whose static addresses can be replaced.
+type staticSetter interface {
+	resolver
+	// setStatic replaces the static addresses. Returns false if there were no
+	// static addresses to replace.
+	setStatic(entries []string) bool
 }
 
 type staticResolver struct {
-	set stringset.Set
+	family addressFamily
+
+	mu      sync.RWMutex
+	entries []string
 }
 
-func (r *staticResolver) resolve() (stringset.Set, error) {
-	return r.set, nil
+func (r *staticResolver) resolve(ctx context.Context) (stringset.Set, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return stringset.FromSlice(r.family.filterAddrs(r.entries)), nil
+}
+
+// Resolve implements SourceProvider.
+func (r *staticResolver) Resolve(ctx context.Context) ([]string, error) {
+	return r.resolveOrdered(ctx)
+}
+
+func (r *staticResolver) resolveOrdered(ctx context.Context) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]string(nil), r.family.filterAddrs(r.entries)...), nil
+}
+
+func (r *staticResolver) setStatic(entries []string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = entries
+	return true
 }
 
 func (r *staticResolver) String() string {
-	return strings.Join(r.set.ToSlice(), ",")
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return strings.Join(r.entries, ",")
+}
+
+type chainResolver struct {
+	resolvers []resolver
+	names     []string // Source names of resolvers, for provenance.
+
+	mu        sync.Mutex
+	last      resolver // Resolver which produced the latest result.
+	lastIdx   int
+	lastCount int
+}
+
+func (r *chainResolver) resolve(ctx context.Context) (stringset.Set, error) {
+	addrs, err := r.resolveOrdered(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return stringset.FromSlice(addrs), nil
+}
+
+func (r *chainResolver) resolveOrdered(ctx context.Context) ([]string, error) {
+	var errs []error
+	for i, rr := range r.resolvers {
+		addrs, err := resolveOrdered(ctx, rr)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", rr, err))
+			continue
+		}
+		if len(addrs) == 0 {
+			errs = append(errs, fmt.Errorf("%s: empty", rr))
+			continue
+		}
+		r.mu.Lock()
+		r.last = rr
+		r.lastIdx = i
+		r.lastCount = len(addrs)
+		r.mu.Unlock()
+		return addrs, nil
+	}
+	return nil, fmt.Errorf("all sources in chain failed: %s", errutil.Join(errs))
+}
+
+// ttl defers to the resolver which produced the latest result, if said
+// resolver is aware of ttls.
+func (r *chainResolver) ttl() (time.Duration, bool) {
+	r.mu.Lock()
+	last := r.last
+	r.mu.Unlock()
+
+	if tr, ok := last.(ttlResolver); ok {
+		return tr.ttl()
+	}
+	return 0, false
+}
+
+func (r *chainResolver) setClock(clk clock.Clock) {
+	for _, rr := range r.resolvers {
+		if cs, ok := rr.(clockSetter); ok {
+			cs.setClock(clk)
+		}
+	}
+}
+
+// provenance attributes the latest result to the source which produced it.
+// All other sources contributed nothing.
+func (r *chainResolver) provenance() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := make(map[string]int, len(r.resolvers))
+	for i, rr := range r.resolvers {
+		name := fmt.Sprint(rr)
+		if i < len(r.names) {
+			name = r.names[i]
+		}
+		counts[name] = 0
+		if r.last != nil && i == r.lastIdx {
+			counts[name] = r.lastCount
+		}
+	}
+	return counts
+}
+
+// setPort sets the port of every resolver in the chain which attaches ports.
+func (r *chainResolver) setPort(port int) {
+	for _, rr := range r.resolvers {
+		if pr, ok := rr.(portResolver); ok {
+			pr.setPort(port)
+		}
+	}
+}
+
+func (r *chainResolver) clearNegativeCache() {
+	for _, rr := range r.resolvers {
+		if nc, ok := rr.(negativeCacher); ok {
+			nc.clearNegativeCache()
+		}
+	}
+}
+
+// setStatic replaces the addresses of every static resolver in the chain.
+func (r *chainResolver) setStatic(entries []string) bool {
+	var ok bool
+	for _, rr := range r.resolvers {
+		if ss, isStatic := rr.(staticSetter); isStatic {
+			ok = ss.setStatic(entries) || ok
+		}
+	}
+	return ok
+}
+
+func (r *chainResolver) String() string {
+	var names []string
+	for _, rr := range r.resolvers {
+		names = append(names, fmt.Sprint(rr))
+	}
+	return strings.Join(names, " -> ")
 }
 
 type dnsResolver struct {
-	dns  string
-	port int
+	dns         string
+	recordTTL   bool
+	negativeTTL time.Duration
+	lookup      lookupHostFunc
+	clk         clock.Clock
+	family      addressFamily
+	secondary   lookupHostFunc // Optional, only compared against lookup.
+	tolerance   int
+
+	// Optional, only set if TraceCNAME is configured.
+	lookupCNAMEChain func(ctx context.Context, name string, max int) ([]string, error)
+	maxCNAMEChain    int
+
+	// Optional, only set if RequireFCrDNS is configured.
+	lookupAddr func(ctx context.Context, addr string) ([]string, error)
+
+	mu            sync.Mutex
+	port          int
+	notFoundErr   error // Cached error of the last not found lookup.
+	notFoundUntil time.Time
 }
 
-func (r *dnsResolver) resolve() (stringset.Set, error) {
-	var nr net.Resolver
-	names, err := nr.LookupHost(context.Background(), r.dns)
+// Resolve implements SourceProvider.
+func (r *dnsResolver) Resolve(ctx context.Context) ([]string, error) {
+	return r.resolveOrdered(ctx)
+}
+
+func (r *dnsResolver) resolve(ctx context.Context) (stringset.Set, error) {
+	addrs, err := r.resolveOrdered(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return stringset.FromSlice(addrs), nil
+}
+
+// resolveOrdered resolves addresses in the order returned by the record.
+func (r *dnsResolver) resolveOrdered(ctx context.Context) ([]string, error) {
+	if err := r.cachedNotFound(); err != nil {
+		return nil, err
+	}
+	names, err := r.lookup(ctx, r.dns)
 	if err != nil {
-		return nil, fmt.Errorf("resolve dns: %s", err)
+		resolveErr := fmt.Errorf("resolve dns: %s", err)
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			r.cacheNotFound(resolveErr)
+		}
+		return nil, resolveErr
 	}
 	if len(names) == 0 {
 		return nil, errors.New("dns record empty")
 	}
-	addrs, err := attachPortIfMissing(stringset.FromSlice(names), r.port)
+	// Dedup up front, such that duplicate answers are neither checked nor
+	// counted twice.
+	names = dedupOrdered(canonicalIPs(names))
+	if r.secondary != nil {
+		r.compareSecondary(ctx, names)
+	}
+	if r.lookupCNAMEChain != nil {
+		if err := r.traceCNAMEChain(ctx); err != nil {
+			return nil, err
+		}
+	}
+	names = r.family.filterNames(names)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("dns record has no %s addresses", r.family)
+	}
+	if r.lookupAddr != nil {
+		names = r.filterFCrDNS(ctx, names)
+		if len(names) == 0 {
+			return nil, errors.New("dns record has no forward-confirmed addresses")
+		}
+	}
+	addrs, err := attachPortIfMissingOrdered(names, r.getPort())
 	if err != nil {
 		return nil, fmt.Errorf("attach port to dns contents: %s", err)
 	}
 	return addrs, nil
 }
 
+// cachedNotFound returns the error of a recent lookup which failed because the
+// record does not exist, if said error is still within the negative ttl.
+func (r *dnsResolver) cachedNotFound() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.notFoundErr != nil && r.clk.Now().Before(r.notFoundUntil) {
+		return r.notFoundErr
+	}
+	return nil
+}
+
+// cacheNotFound caches err, the result of looking up a record which does not
+// exist (i.e. NXDOMAIN). Temporary failures are never cached, so they are
+// retried on the next refresh.
+func (r *dnsResolver) cacheNotFound(err error) {
+	if r.negativeTTL == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.notFoundErr = err
+	r.notFoundUntil = r.clk.Now().Add(r.negativeTTL)
+}
+
+func (r *dnsResolver) clearNegativeCache() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.notFoundErr = nil
+}
+
+func (r *dnsResolver) setClock(clk clock.Clock) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.clk = clk
+}
+
+func (r *dnsResolver) getPort() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.port
+}
+
+func (r *dnsResolver) setPort(port int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.port = port
+}
+
+// ttl looks up the TTL of the DNS record. Returns false if the record TTL is
+// not used or cannot be determined.
+func (r *dnsResolver) ttl() (time.Duration, bool) {
+	if !r.recordTTL {
+		return 0, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), _ttlLookupTimeout)
+	defer cancel()
+	ttl, err := lookupTTL(ctx, r.dns)
+	if err != nil {
+		log.With("dns", r.dns).Warnf("Error looking up dns record ttl: %s", err)
+		return 0, false
+	}
+	return ttl, true
+}
+
+// traceCNAMEChain records the CNAME chain of the record. Only invalid chains
+// are returned as errors; failures to look up the chain are logged.
+func (r *dnsResolver) traceCNAMEChain(ctx context.Context) error {
+	ctx, span := startSpan(ctx, "hostlist.cname_chain")
+	ctx, cancel := context.WithTimeout(ctx, _ttlLookupTimeout)
+	defer cancel()
+
+	chain, err := r.lookupCNAMEChain(ctx, r.dns, r.maxCNAMEChain)
+	if err != nil {
+		span.Finish(err)
+		if _, ok := err.(cnameChainError); ok {
+			return fmt.Errorf("resolve dns: %s", err)
+		}
+		log.With("dns", r.dns).Warnf("Error tracing dns cname chain: %s", err)
+		return nil
+	}
+	span.SetTag("chain", chain)
+	span.SetTag("canonical_name", chain[len(chain)-1])
+	span.Finish(nil)
+	log.With("dns", r.dns, "chain", chain).Debug("Traced dns cname chain")
+	return nil
+}
+
+// compareSecondary resolves the record against the secondary nameserver and
+// logs a warning if its answer differs from names by more than the tolerance.
+func (r *dnsResolver) compareSecondary(ctx context.Context, names []string) {
+	ctx, cancel := context.WithTimeout(ctx, _secondaryLookupTimeout)
+	defer cancel()
+
+	secondary, err := r.secondary(ctx, r.dns)
+	if err != nil {
+		log.With("dns", r.dns).Warnf("Error resolving dns against secondary: %s", err)
+		return
+	}
+	missing, extra := diffAnswers(names, secondary)
+	if len(missing)+len(extra) > r.tolerance {
+		log.With(
+			"dns", r.dns,
+			"missing_from_secondary", missing.ToSlice(),
+			"only_in_secondary", extra.ToSlice(),
+		).Warn("Primary and secondary dns answers diverge")
+	}
+}
+
+// diffAnswers returns the addresses of primary missing from secondary, and the
+// addresses only in secondary. Both answers are canonicalized first, so the
+// same address spelled differently (e.g. "::ffff:10.0.0.1") does not diverge.
+func diffAnswers(primary, secondary []string) (missing, extra stringset.Set) {
+	primarySet := stringset.FromSlice(canonicalIPs(primary))
+	secondarySet := stringset.FromSlice(canonicalIPs(secondary))
+	return primarySet.Sub(secondarySet), secondarySet.Sub(primarySet)
+}
+
 func (r *dnsResolver) String() string {
-	return fmt.Sprintf("%s:%d", r.dns, r.port)
+	return fmt.Sprintf("%s:%d", r.dns, r.getPort())
 }
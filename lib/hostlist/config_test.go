@@ -0,0 +1,331 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/utils/stringset"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeResolver struct {
+	addrs stringset.Set
+	err   error
+}
+
+func (r fakeResolver) resolve(ctx context.Context) (stringset.Set, error) {
+	return r.addrs, r.err
+}
+
+func TestChainResolverFallsBack(t *testing.T) {
+	tests := []struct {
+		desc      string
+		resolvers []resolver
+		expected  stringset.Set
+	}{
+		{
+			"first source succeeds",
+			[]resolver{fakeResolver{addrs: stringset.New("a:80")}, fakeResolver{addrs: stringset.New("b:80")}},
+			stringset.New("a:80"),
+		}, {
+			"first source errors",
+			[]resolver{fakeResolver{err: errors.New("some error")}, fakeResolver{addrs: stringset.New("b:80")}},
+			stringset.New("b:80"),
+		}, {
+			"first source empty",
+			[]resolver{fakeResolver{addrs: stringset.New()}, fakeResolver{addrs: stringset.New("b:80")}},
+			stringset.New("b:80"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			r := &chainResolver{resolvers: test.resolvers}
+			addrs, err := r.resolve(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, test.expected, addrs)
+		})
+	}
+}
+
+func TestChainResolverAllSourcesFail(t *testing.T) {
+	r := &chainResolver{resolvers: []resolver{
+		fakeResolver{err: errors.New("some error")},
+		fakeResolver{addrs: stringset.New()},
+	}}
+	_, err := r.resolve(context.Background())
+	require.Error(t, err)
+}
+
+func TestChainConfig(t *testing.T) {
+	require := require.New(t)
+
+	l, err := New(Config{
+		Chain:  []string{SourceStatic, SourceDNS},
+		DNS:    "some-dns:80",
+		Static: []string{"a:80", "b:80"},
+	})
+	require.NoError(err)
+	require.Equal(stringset.New("a:80", "b:80"), l.Resolve())
+}
+
+func TestInvalidChainConfig(t *testing.T) {
+	tests := []struct {
+		desc   string
+		config Config
+	}{
+		{"unknown source", Config{Chain: []string{"foo"}, Static: []string{"a:80"}}},
+		{"duplicate source", Config{Chain: []string{SourceStatic, SourceStatic}, Static: []string{"a:80"}}},
+		{"dns without record", Config{Chain: []string{SourceDNS, SourceStatic}, Static: []string{"a:80"}}},
+		{"static without list", Config{Chain: []string{SourceStatic}, DNS: "some-dns:80"}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := New(test.config)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestDNSResolverNegativeCache(t *testing.T) {
+	require := require.New(t)
+
+	var lookups int
+	var lookupErr error
+	clk := clock.NewMock()
+	r := &dnsResolver{
+		dns:         "some-dns",
+		port:        80,
+		negativeTTL: time.Minute,
+		clk:         clk,
+		lookup: func(ctx context.Context, host string) ([]string, error) {
+			lookups++
+			if lookupErr != nil {
+				return nil, lookupErr
+			}
+			return []string{"10.0.0.1"}, nil
+		},
+	}
+
+	// Temporary failures are not cached.
+	lookupErr = &net.DNSError{Err: "timeout", Name: "some-dns", IsTemporary: true}
+	_, err := r.resolve(context.Background())
+	require.Error(err)
+	_, err = r.resolve(context.Background())
+	require.Error(err)
+	require.Equal(2, lookups)
+
+	// Not found failures are cached for the negative ttl.
+	lookupErr = &net.DNSError{Err: "no such host", Name: "some-dns", IsNotFound: true}
+	_, err = r.resolve(context.Background())
+	require.Error(err)
+	lookupErr = nil
+	_, err = r.resolve(context.Background())
+	require.Error(err)
+	require.Equal(3, lookups)
+
+	clk.Add(time.Minute + 1)
+	addrs, err := r.resolve(context.Background())
+	require.NoError(err)
+	require.Equal(stringset.New("10.0.0.1:80"), addrs)
+	require.Equal(4, lookups)
+
+	// The cache can be bypassed.
+	lookupErr = &net.DNSError{Err: "no such host", Name: "some-dns", IsNotFound: true}
+	_, err = r.resolve(context.Background())
+	require.Error(err)
+	lookupErr = nil
+	r.clearNegativeCache()
+	_, err = r.resolve(context.Background())
+	require.NoError(err)
+}
+
+func TestDNSResolverPreservesRecordOrder(t *testing.T) {
+	r := &dnsResolver{
+		dns:  "some-dns",
+		port: 80,
+		clk:  clock.New(),
+		lookup: func(ctx context.Context, host string) ([]string, error) {
+			return []string{"10.0.0.3", "10.0.0.1", "10.0.0.3", "10.0.0.2"}, nil
+		},
+	}
+	addrs, err := r.resolveOrdered(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.3:80", "10.0.0.1:80", "10.0.0.2:80"}, addrs)
+}
+
+func TestDiffAnswersCanonicalizesBothSides(t *testing.T) {
+	require := require.New(t)
+
+	missing, extra := diffAnswers(
+		[]string{"10.0.0.1", "fd00::1", "10.0.0.2"},
+		[]string{"::ffff:10.0.0.1", "fd00:0:0::0001", "10.0.0.3"})
+	require.Equal(stringset.New("10.0.0.2"), missing)
+	require.Equal(stringset.New("10.0.0.3"), extra)
+}
+
+func TestDNSResolverSecondaryNeverFailsResolution(t *testing.T) {
+	primary := func(ctx context.Context, host string) ([]string, error) {
+		return []string{"10.0.0.1", "10.0.0.2"}, nil
+	}
+	tests := []struct {
+		desc      string
+		secondary lookupHostFunc
+	}{
+		{
+			"diverged",
+			func(ctx context.Context, host string) ([]string, error) {
+				return []string{"10.0.0.3"}, nil
+			},
+		}, {
+			"error",
+			func(ctx context.Context, host string) ([]string, error) {
+				return nil, errors.New("some error")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var secondaryLookups int
+			r := &dnsResolver{
+				dns:    "some-dns",
+				port:   80,
+				clk:    clock.New(),
+				lookup: primary,
+				secondary: func(ctx context.Context, host string) ([]string, error) {
+					secondaryLookups++
+					return test.secondary(ctx, host)
+				},
+			}
+			addrs, err := r.resolve(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, stringset.New("10.0.0.1:80", "10.0.0.2:80"), addrs)
+			require.Equal(t, 1, secondaryLookups)
+		})
+	}
+}
+
+func TestInvalidSecondaryDNSServer(t *testing.T) {
+	_, err := New(Config{DNS: "some-dns:80", SecondaryDNSServer: "10.0.0.53"})
+	require.Error(t, err)
+}
+
+func TestInsecureAnnotation(t *testing.T) {
+	require := require.New(t)
+
+	config := Config{Static: []string{"a:80|insecure", "b:80", "10.0.0.0/31:80|insecure"}}
+
+	l, err := New(config)
+	require.NoError(err)
+	require.Equal(stringset.New("a:80", "b:80", "10.0.0.0:80", "10.0.0.1:80"), l.Resolve())
+
+	insecure, err := config.InsecureAddrs()
+	require.NoError(err)
+	require.Equal(stringset.New("a:80", "10.0.0.0:80", "10.0.0.1:80"), insecure)
+
+	hosts := MarkInsecure([]Host{{Name: "a", Port: 80}, {Name: "b", Port: 80}}, insecure)
+	require.True(hosts[0].Insecure)
+	require.False(hosts[1].Insecure)
+}
+
+func TestInvalidAnnotation(t *testing.T) {
+	config := Config{Static: []string{"a:80|skip-verify"}}
+
+	_, err := New(config)
+	require.Error(t, err)
+
+	_, err = config.InsecureAddrs()
+	require.Error(t, err)
+}
+
+func TestStaticDelimiter(t *testing.T) {
+	expected := stringset.New("a:80", "b:80", "c:80")
+	tests := []struct {
+		desc      string
+		static    []string
+		delimiter string
+	}{
+		{"none", []string{"a:80", "b:80", "c:80"}, ""},
+		{"default comma", []string{"a:80, b:80", "c:80"}, ""},
+		{"semicolon", []string{"a:80;b:80;c:80;"}, ";"},
+		{"whitespace", []string{"a:80 b:80\n\tc:80"}, StaticDelimiterWhitespace},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			l, err := New(Config{Static: test.static, StaticDelimiter: test.delimiter})
+			require.NoError(t, err)
+			require.Equal(t, expected, l.Resolve())
+		})
+	}
+}
+
+func TestStaticComments(t *testing.T) {
+	l, err := New(Config{Static: []string{
+		"a:80 # rack-a origin, primary",
+		"# c:80 is decommissioned",
+		"b:80",
+	}})
+	require.NoError(t, err)
+	require.Equal(t, stringset.New("a:80", "b:80"), l.Resolve())
+}
+
+func TestConflictingPorts(t *testing.T) {
+	addrs := []string{"a:80", "a:81", "b:80", "10.0.0.1:80", "10.0.0.1:8080", "a:80"}
+	require.Equal(t, map[string][]string{
+		"a":        {"80", "81"},
+		"10.0.0.1": {"80", "8080"},
+	}, conflictingPorts(addrs))
+	require.Empty(t, conflictingPorts([]string{"a:80", "b:80"}))
+}
+
+func TestDNSResolverDedupsAnswers(t *testing.T) {
+	require := require.New(t)
+
+	var secondary []string
+	var checked []string
+	var mu sync.Mutex
+	r := &dnsResolver{
+		dns:  "some-dns",
+		port: 80,
+		clk:  clock.New(),
+		lookup: func(ctx context.Context, host string) ([]string, error) {
+			if host == "some-dns" {
+				return []string{"10.0.0.1", "fd00:0::1", "10.0.0.1", "fd00::1"}, nil
+			}
+			return []string{"10.0.0.1", "fd00::1"}, nil
+		},
+		secondary: func(ctx context.Context, host string) ([]string, error) {
+			secondary = append(secondary, host)
+			return []string{"10.0.0.1", "fd00::1"}, nil
+		},
+		lookupAddr: func(ctx context.Context, addr string) ([]string, error) {
+			mu.Lock()
+			checked = append(checked, addr)
+			mu.Unlock()
+			return []string{"a.example."}, nil
+		},
+	}
+	addrs, err := r.resolveOrdered(context.Background())
+	require.NoError(err)
+	require.Equal([]string{"10.0.0.1:80", "[fd00::1]:80"}, addrs)
+	require.ElementsMatch([]string{"10.0.0.1", "fd00::1"}, checked)
+	require.Len(secondary, 1)
+}
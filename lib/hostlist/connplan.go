@@ -0,0 +1,31 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"sort"
+
+	"github.com/uber/kraken/utils/stringset"
+)
+
+// ConnectionPlan returns the connections a client holding one connection per
+// host must open and close to move from the old to the new set of addresses,
+// e.g. on each refresh of a List. Both are sorted.
+func ConnectionPlan(old, new stringset.Set) (open, close []string) {
+	open = new.Sub(old).ToSlice()
+	close = old.Sub(new).ToSlice()
+	sort.Strings(open)
+	sort.Strings(close)
+	return open, close
+}
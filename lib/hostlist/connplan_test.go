@@ -0,0 +1,52 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"testing"
+
+	"github.com/uber/kraken/utils/stringset"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionPlan(t *testing.T) {
+	tests := []struct {
+		desc  string
+		old   stringset.Set
+		new   stringset.Set
+		open  []string
+		close []string
+	}{
+		{"unchanged", stringset.New("a:80", "b:80"), stringset.New("a:80", "b:80"), nil, nil},
+		{"initial", stringset.New(), stringset.New("b:80", "a:80"), []string{"a:80", "b:80"}, nil},
+		{"drained", stringset.New("b:80", "a:80"), stringset.New(), nil, []string{"a:80", "b:80"}},
+		{
+			"churn",
+			stringset.New("a:80", "b:80", "c:80"),
+			stringset.New("b:80", "d:80", "e:80"),
+			[]string{"d:80", "e:80"},
+			[]string{"a:80", "c:80"},
+		},
+		{"port change", stringset.New("a:80"), stringset.New("a:81"), []string{"a:81"}, []string{"a:80"}},
+		{"full replacement", stringset.New("a:80"), stringset.New("b:80"), []string{"b:80"}, []string{"a:80"}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			open, close := ConnectionPlan(test.old, test.new)
+			require.Equal(t, test.open, open)
+			require.Equal(t, test.close, close)
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/uber/kraken/utils/httputil"
+)
+
+// ConsulConfig configures the "consul" source, which resolves the instances of
+// a service registered in Consul via its HTTP catalog API.
+type ConsulConfig struct {
+	// Address of the Consul agent, e.g. "http://localhost:8500".
+	Address string `yaml:"address"`
+
+	// Service is the name of the service whose instances are resolved, and Tag
+	// optionally restricts instances to those carrying the tag.
+	Service string `yaml:"service"`
+	Tag     string `yaml:"tag"`
+
+	// Datacenter optionally queries a datacenter other than the agent's.
+	Datacenter string `yaml:"datacenter"`
+
+	// Token is an optional ACL token.
+	Token string `yaml:"token"`
+
+	// IncludeUnhealthy includes instances which fail their Consul health
+	// checks. By default, only passing instances are resolved.
+	IncludeUnhealthy bool `yaml:"include_unhealthy"`
+}
+
+type consulSourceFactory struct{}
+
+// Create creates a SourceProvider from a ConsulConfig.
+func (consulSourceFactory) Create(raw interface{}) (SourceProvider, error) {
+	var c ConsulConfig
+	if err := unmarshalSourceConfig(raw, &c); err != nil {
+		return nil, fmt.Errorf("consul source config: %s", err)
+	}
+	if c.Address == "" {
+		return nil, errors.New("no consul address supplied")
+	}
+	if c.Service == "" {
+		return nil, errors.New("no consul service supplied")
+	}
+	return &consulProvider{c}, nil
+}
+
+type consulProvider struct {
+	config ConsulConfig
+}
+
+// consulServiceEntry is the subset of a /v1/health/service entry which is used
+// to build addresses.
+type consulServiceEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		Address string `json:"Address"`
+		Port    int    `json:"Port"`
+	} `json:"Service"`
+}
+
+func (p *consulProvider) Resolve(ctx context.Context) ([]string, error) {
+	q := url.Values{}
+	if !p.config.IncludeUnhealthy {
+		q.Set("passing", "true")
+	}
+	if p.config.Tag != "" {
+		q.Set("tag", p.config.Tag)
+	}
+	if p.config.Datacenter != "" {
+		q.Set("dc", p.config.Datacenter)
+	}
+	u := fmt.Sprintf("%s/v1/health/service/%s?%s",
+		strings.TrimSuffix(p.config.Address, "/"), url.PathEscape(p.config.Service), q.Encode())
+
+	headers := map[string]string{}
+	if p.config.Token != "" {
+		headers["X-Consul-Token"] = p.config.Token
+	}
+	resp, err := httputil.Get(u, httputil.SendContext(ctx), httputil.SendHeaders(headers))
+	if err != nil {
+		return nil, fmt.Errorf("consul: %s", err)
+	}
+	defer resp.Body.Close()
+
+	var entries []consulServiceEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("consul: decode: %s", err)
+	}
+	var addrs []string
+	for _, e := range entries {
+		// Instances without their own address use the address of their node.
+		host := e.Service.Address
+		if host == "" {
+			host = e.Node.Address
+		}
+		addrs = append(addrs, net.JoinHostPort(host, strconv.Itoa(e.Service.Port)))
+	}
+	return addrs, nil
+}
+
+func (p *consulProvider) String() string {
+	return fmt.Sprintf("consul %s/%s", p.config.Address, p.config.Service)
+}
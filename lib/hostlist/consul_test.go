@@ -0,0 +1,74 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/uber/kraken/utils/stringset"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsulSource(t *testing.T) {
+	require := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal("/v1/health/service/origin", r.URL.Path)
+		require.Equal("true", r.URL.Query().Get("passing"))
+		require.Equal("prod", r.URL.Query().Get("tag"))
+		require.Equal("some-token", r.Header.Get("X-Consul-Token"))
+		w.Write([]byte(`[
+			{"Node": {"Address": "10.0.0.1"}, "Service": {"Address": "", "Port": 80}},
+			{"Node": {"Address": "10.0.0.2"}, "Service": {"Address": "fd00::2", "Port": 81}}
+		]`))
+	}))
+	defer server.Close()
+
+	l, err := New(Config{
+		Source: SourceConsul,
+		SourceConfig: map[string]interface{}{
+			"address": server.URL,
+			"service": "origin",
+			"tag":     "prod",
+			"token":   "some-token",
+		},
+	})
+	require.NoError(err)
+	require.Equal(stringset.New("10.0.0.1:80", "[fd00::2]:81"), l.Resolve())
+}
+
+func TestConsulSourceErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		desc   string
+		config map[string]interface{}
+	}{
+		{"no address", map[string]interface{}{"service": "origin"}},
+		{"no service", map[string]interface{}{"address": server.URL}},
+		{"server error", map[string]interface{}{"address": server.URL, "service": "origin"}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := New(Config{Source: SourceConsul, SourceConfig: test.config})
+			require.Error(t, err)
+		})
+	}
+}
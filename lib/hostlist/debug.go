@@ -0,0 +1,90 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/uber/kraken/utils/stringset"
+)
+
+// ListState is a point in time view of the full state of a DynamicList, e.g.
+// for debugging dumps or periodic structured logging. See DynamicList.Snapshot.
+type ListState struct {
+	// Source describes the configured source.
+	Source string `json:"source"`
+
+	// Snapshot is the latest snapshot of the configured source, and
+	// SnapshotHash its Hash.
+	Snapshot     stringset.Set `json:"snapshot"`
+	SnapshotHash string        `json:"snapshot_hash"`
+
+	// Override is the set forced by Override, or nil if there is none.
+	Override stringset.Set `json:"override,omitempty"`
+
+	// LastRefresh is when the source was last resolved, and LastLatency how
+	// long it took. LastError is the error of the last resolution, if it
+	// failed, in which case Snapshot is the latest successful one, taken at
+	// LastSuccess.
+	LastRefresh time.Time     `json:"last_refresh"`
+	LastSuccess time.Time     `json:"last_success"`
+	LastLatency time.Duration `json:"last_latency"`
+	LastError   string        `json:"last_error,omitempty"`
+
+	// Provenance is the number of hosts each source contributed to Snapshot.
+	Provenance map[string]int `json:"provenance,omitempty"`
+}
+
+func (l *list) Snapshot() ListState {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	s := ListState{
+		Source:       fmt.Sprint(l.resolver),
+		Snapshot:     l.snapshot.Copy(),
+		SnapshotHash: Hash(l.snapshot),
+		LastRefresh:  l.lastRefresh,
+		LastSuccess:  l.lastSuccess,
+		LastLatency:  l.lastLatency,
+	}
+	if l.override != nil {
+		s.Override = l.override.Copy()
+	}
+	if l.lastErr != nil {
+		s.LastError = l.lastErr.Error()
+	}
+	if len(l.provenance) > 0 {
+		s.Provenance = make(map[string]int, len(l.provenance))
+		for k, v := range l.provenance {
+			s.Provenance[k] = v
+		}
+	}
+	return s
+}
+
+func (l *list) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(l.Snapshot()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
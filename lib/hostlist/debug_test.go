@@ -0,0 +1,91 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/utils/stringset"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugHandler(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	clk.Set(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	l, err := New(Config{Static: []string{"a:80", "b:80"}}, WithClock(clk))
+	require.NoError(err)
+	require.NoError(l.Override(stringset.New("c:80")))
+
+	w := httptest.NewRecorder()
+	l.DebugHandler().ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	require.Equal(http.StatusOK, w.Code)
+
+	var state struct {
+		Source      string         `json:"source"`
+		Snapshot    []string       `json:"snapshot"`
+		Override    []string       `json:"override"`
+		LastRefresh time.Time      `json:"last_refresh"`
+		LastError   string         `json:"last_error"`
+		Provenance  map[string]int `json:"provenance"`
+	}
+	require.NoError(json.Unmarshal(w.Body.Bytes(), &state))
+	require.Equal("a:80,b:80", state.Source)
+	require.Equal([]string{"a:80", "b:80"}, state.Snapshot)
+	require.Equal([]string{"c:80"}, state.Override)
+	require.True(clk.Now().Equal(state.LastRefresh))
+	require.Empty(state.LastError)
+	require.Equal(map[string]int{SourceStatic: 2}, state.Provenance)
+}
+
+func TestDebugHandlerIsReadOnly(t *testing.T) {
+	l, err := New(Config{Static: []string{"a:80"}})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	l.DebugHandler().ServeHTTP(w, httptest.NewRequest("POST", "/", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestSnapshot(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	l, err := New(Config{Static: []string{"a:80", "b:80"}}, WithClock(clk))
+	require.NoError(err)
+
+	s := l.Snapshot()
+	require.Equal(stringset.New("a:80", "b:80"), s.Snapshot)
+	require.Nil(s.Override)
+	require.True(clk.Now().Equal(s.LastRefresh))
+	require.Empty(s.LastError)
+	require.Equal(map[string]int{SourceStatic: 2}, s.Provenance)
+
+	// The state is a copy.
+	s.Snapshot.Add("c:80")
+	s.Provenance[SourceStatic] = 0
+	require.Equal(stringset.New("a:80", "b:80"), l.Snapshot().Snapshot)
+	require.Equal(map[string]int{SourceStatic: 2}, l.Snapshot().Provenance)
+
+	require.NoError(l.Override(stringset.New("c:80")))
+	require.Equal(stringset.New("c:80"), l.Snapshot().Override)
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	_resolvConf       = "/etc/resolv.conf"
+	_ttlLookupTimeout = 2 * time.Second
+	_maxUDPMessage    = 512
+
+	_secondaryLookupTimeout = 2 * time.Second
+)
+
+// lookupTTL queries the system nameservers for the A records of name and
+// returns the smallest TTL among all answers, including any CNAMEs followed
+// along the way. The standard library resolver does not expose TTLs, hence the
+// raw query.
+func lookupTTL(ctx context.Context, name string) (time.Duration, error) {
+	servers, err := readNameservers(_resolvConf)
+	if err != nil {
+		return 0, fmt.Errorf("read nameservers: %s", err)
+	}
+	var errs []string
+	for _, server := range servers {
+		ttl, err := queryTTL(ctx, net.JoinHostPort(server, "53"), name)
+		if err == nil {
+			return ttl, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %s", server, err))
+	}
+	return 0, fmt.Errorf("all nameservers failed: %s", strings.Join(errs, ", "))
+}
+
+// readNameservers parses the nameserver entries of a resolv.conf file.
+func readNameservers(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var servers []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(servers) == 0 {
+		return nil, errors.New("no nameservers found")
+	}
+	return servers, nil
+}
+
+// queryTTL sends a single A record query for name to server over UDP.
+func queryTTL(ctx context.Context, server, name string) (time.Duration, error) {
+	answers, err := queryA(ctx, server, name)
+	if err != nil {
+		return 0, err
+	}
+	if len(answers) == 0 {
+		return 0, errors.New("no answers")
+	}
+	min := answers[0].Header.TTL
+	for _, a := range answers[1:] {
+		if a.Header.TTL < min {
+			min = a.Header.TTL
+		}
+	}
+	return time.Duration(min) * time.Second, nil
+}
+
+// queryA sends a single A record query for name to server over UDP, and
+// returns all answers, including any CNAMEs followed by the server.
+func queryA(ctx context.Context, server, name string) ([]dnsmessage.Resource, error) {
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, fmt.Errorf("name: %s", err)
+	}
+	id := uint16(rand.Intn(1 << 16))
+	query := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  qname,
+			Type:  dnsmessage.TypeA,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	b, err := query.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("pack query: %s", err)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, fmt.Errorf("dial: %s", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write(b); err != nil {
+		return nil, fmt.Errorf("write query: %s", err)
+	}
+	buf := make([]byte, _maxUDPMessage)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %s", err)
+	}
+
+	var p dnsmessage.Parser
+	h, err := p.Start(buf[:n])
+	if err != nil {
+		return nil, fmt.Errorf("parse response: %s", err)
+	}
+	if h.ID != id {
+		return nil, errors.New("response id mismatch")
+	}
+	if h.RCode != dnsmessage.RCodeSuccess {
+		return nil, fmt.Errorf("rcode %s", h.RCode)
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, fmt.Errorf("skip questions: %s", err)
+	}
+	answers, err := p.AllAnswers()
+	if err != nil {
+		return nil, fmt.Errorf("parse answers: %s", err)
+	}
+	return answers, nil
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// startDNSServer starts a UDP server which answers every query with the given
+// A record TTLs.
+func startDNSServer(t *testing.T, ttls ...uint32) (addr string, cleanup func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		buf := make([]byte, _maxUDPMessage)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil {
+				return
+			}
+			resp := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true},
+				Questions: query.Questions,
+			}
+			for i, ttl := range ttls {
+				resp.Answers = append(resp.Answers, dnsmessage.Resource{
+					Header: dnsmessage.ResourceHeader{
+						Name:  query.Questions[0].Name,
+						Type:  dnsmessage.TypeA,
+						Class: dnsmessage.ClassINET,
+						TTL:   ttl,
+					},
+					Body: &dnsmessage.AResource{A: [4]byte{10, 0, 0, byte(i)}},
+				})
+			}
+			b, err := resp.Pack()
+			if err != nil {
+				return
+			}
+			conn.WriteTo(b, addr)
+		}
+	}()
+	return conn.LocalAddr().String(), func() { conn.Close() }
+}
+
+func TestQueryTTL(t *testing.T) {
+	server, cleanup := startDNSServer(t, 30, 10, 60)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ttl, err := queryTTL(ctx, server, "example.com")
+	require.NoError(t, err)
+	require.Equal(t, 10*time.Second, ttl)
+}
+
+func TestQueryTTLNoAnswers(t *testing.T) {
+	server, cleanup := startDNSServer(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := queryTTL(ctx, server, "example.com")
+	require.Error(t, err)
+}
+
+func TestReadNameservers(t *testing.T) {
+	require := require.New(t)
+
+	f, err := ioutil.TempFile("", "resolv.conf")
+	require.NoError(err)
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString("# comment\nsearch example.com\nnameserver 10.0.0.1\nnameserver 10.0.0.2\n")
+	require.NoError(err)
+	require.NoError(f.Close())
+
+	servers, err := readNameservers(f.Name())
+	require.NoError(err)
+	require.Equal([]string{"10.0.0.1", "10.0.0.2"}, servers)
+}
+
+func TestClampTTL(t *testing.T) {
+	min, max := time.Second, time.Minute
+
+	require.Equal(t, min, clampTTL(0, min, max))
+	require.Equal(t, 30*time.Second, clampTTL(30*time.Second, min, max))
+	require.Equal(t, max, clampTTL(time.Hour, min, max))
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"net"
+	"sort"
+)
+
+// SplitDualStack expands each host into one Host per resolved ip, such that
+// hosts resolving to both ipv4 and ipv6 addresses yield an entry per family.
+// The expanded hosts keep their Name and Port, and therefore their Addr, so
+// selection (which operates on Addr) still treats them as one logical host
+// while dialers may race its ips happy-eyeballs style. Within each host, ipv6
+// entries are ordered before ipv4 entries, per RFC 8305.
+func SplitDualStack(hosts []Host) []Host {
+	var result []Host
+	for _, h := range hosts {
+		ips := append([]string(nil), h.ResolvedIPs...)
+		sort.SliceStable(ips, func(i, j int) bool { return isIPv6(ips[i]) && !isIPv6(ips[j]) })
+		for _, ip := range ips {
+			e := h
+			e.ResolvedIPs = []string{ip}
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// GroupByAddr groups hosts by their logical address, e.g. to collect the
+// per-family entries of SplitDualStack back into a single host. Entries
+// within each group preserve their order in hosts.
+func GroupByAddr(hosts []Host) map[string][]Host {
+	groups := make(map[string][]Host)
+	for _, h := range hosts {
+		groups[h.Addr()] = append(groups[h.Addr()], h)
+	}
+	return groups
+}
+
+func isIPv6(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && parsed.To4() == nil
+}
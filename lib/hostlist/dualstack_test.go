@@ -0,0 +1,50 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitDualStack(t *testing.T) {
+	require := require.New(t)
+
+	lookup := func(ctx context.Context, host string) ([]string, error) {
+		return []string{"10.0.0.1", "fd00::1"}, nil
+	}
+	dual, err := buildHost(context.Background(), lookup, "some-host:80")
+	require.NoError(err)
+	single := Host{Name: "10.0.0.2", Port: 80, ResolvedIPs: []string{"10.0.0.2"}}
+
+	hosts := SplitDualStack([]Host{dual, single})
+	require.Equal([]Host{
+		{Name: "some-host", Port: 80, ResolvedIPs: []string{"fd00::1"}},
+		{Name: "some-host", Port: 80, ResolvedIPs: []string{"10.0.0.1"}},
+		single,
+	}, hosts)
+
+	groups := GroupByAddr(hosts)
+	require.Len(groups, 2)
+	require.Equal(hosts[:2], groups["some-host:80"])
+	require.Equal([]Host{single}, groups["10.0.0.2:80"])
+}
+
+func TestAddressFamilyBothKeepsAllAddresses(t *testing.T) {
+	l, err := New(Config{Static: []string{"10.0.0.1:80", "[::1]:80"}, AddressFamily: FamilyBoth})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(l.Resolve()))
+}
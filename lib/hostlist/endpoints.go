@@ -0,0 +1,49 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"fmt"
+)
+
+// ToEndpoints resolves c once into a flat list of 'host:port' endpoints, e.g.
+// to exchange membership over an admin API. If port is non-zero, it is
+// attached to resolved names which lack one, as with DynamicList.SetPort.
+// Endpoints are ordered as by BuildOrdered. Annotations such as '|insecure'
+// are not included, since endpoints only describe membership.
+func (c Config) ToEndpoints(port int) ([]string, error) {
+	if port < 0 || port > 65535 {
+		return nil, fmt.Errorf("port out of range: %d", port)
+	}
+	c.applyDefaults()
+	r, err := c.getResolver()
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %s", err)
+	}
+	if pr, ok := r.(portResolver); ok && port != 0 {
+		pr.setPort(port)
+	}
+	ctx, cancel := withBudget(context.Background(), c.ResolveBudget)
+	defer cancel()
+	addrs, err := resolveOrdered(ctx, r)
+	warnIfBudgetExpired(ctx, r, err)
+	return addrs, err
+}
+
+// ConfigFromEndpoints returns a static Config of endpoints, the inverse of
+// Config.ToEndpoints. For static configs, the round trip preserves membership.
+func ConfigFromEndpoints(endpoints []string) Config {
+	return Config{Static: append([]string(nil), endpoints...)}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointsRoundTrip(t *testing.T) {
+	tests := []struct {
+		desc   string
+		config Config
+	}{
+		{"plain", Config{Static: []string{"a:80", "b:81"}}},
+		{"annotated and commented", Config{Static: []string{"a:80|insecure # self-signed", "b:80"}}},
+		{"delimited", Config{Static: []string{"a:80 b:80"}, StaticDelimiter: StaticDelimiterWhitespace}},
+		{"cidr", Config{Static: []string{"10.0.0.0/30:80", "[fd00:0::1]:80"}}},
+		{"duplicates", Config{Static: []string{"a:80", "a:80", "b:80"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			endpoints, err := test.config.ToEndpoints(0)
+			require.NoError(t, err)
+
+			expected, err := New(test.config)
+			require.NoError(t, err)
+			result, err := New(ConfigFromEndpoints(endpoints))
+			require.NoError(t, err)
+			require.Equal(t, expected.Resolve(), result.Resolve())
+		})
+	}
+}
+
+func TestToEndpointsPreservesOrder(t *testing.T) {
+	endpoints, err := Config{Static: []string{"b:80", "a:80"}}.ToEndpoints(90)
+	require.NoError(t, err)
+	require.Equal(t, []string{"b:80", "a:80"}, endpoints)
+}
+
+func TestToEndpointsErrors(t *testing.T) {
+	_, err := Config{Static: []string{"a:80"}}.ToEndpoints(70000)
+	require.Error(t, err)
+
+	_, err = Config{}.ToEndpoints(80)
+	require.Error(t, err)
+}
@@ -0,0 +1,201 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// _maxCIDRExpansion bounds the number of addresses a single CIDR entry may
+// expand into, such that a mistyped prefix length cannot blow up the list.
+const _maxCIDRExpansion = 1024
+
+// ParseEntry parses a single configured host entry into the Hosts it denotes.
+// Supported forms are 'host:port', '[ipv6]:port', and, if defaultPort is
+// non-zero, bare 'host' and 'ipv6' entries which are given defaultPort. The
+// host may also be a CIDR block (e.g. '10.0.0.0/29:80' or '[fd00::/125]:80'),
+// which expands into one Host per usable address of the block in ascending
+// order (see expandCIDR). Any entry may carry an
+// '|insecure' annotation (see Config.InsecureAddrs), followed by a trailing
+// comment (see stripComment). Ip literals are canonicalized and have
+// ResolvedIPs set to themselves, while hostnames are not looked up.
+//
+// Every path which turns configuration into addresses goes through
+// ParseEntry, so it must never panic regardless of input.
+func ParseEntry(s string, defaultPort int) ([]Host, error) {
+	h, err := parseEntry(s, defaultPort)
+	if err != nil {
+		return nil, fmt.Errorf("invalid entry %q: %s", s, err)
+	}
+	if !strings.Contains(h.Name, "/") {
+		return []Host{h}, nil
+	}
+	hosts, err := expandCIDR(h)
+	if err != nil {
+		return nil, fmt.Errorf("invalid entry %q: %s", s, err)
+	}
+	return hosts, nil
+}
+
+func parseEntry(s string, defaultPort int) (Host, error) {
+	s = stripComment(s)
+	if s == "" {
+		return Host{}, errors.New("empty entry")
+	}
+	addr, insecure, err := splitAnnotation(s)
+	if err != nil {
+		return Host{}, err
+	}
+	var name string
+	var port int
+	if !strings.Contains(addr, ":") || isBareIPv6(addr) || isBareIPv6CIDR(addr) {
+		if defaultPort == 0 {
+			return Host{}, errors.New("missing port")
+		}
+		name, port = addr, defaultPort
+	} else {
+		var rawport string
+		name, rawport, err = net.SplitHostPort(addr)
+		if err != nil {
+			return Host{}, err
+		}
+		port, err = parsePort(rawport)
+		if err != nil {
+			return Host{}, err
+		}
+	}
+	if strings.Contains(name, "/") {
+		// CIDR block, validated when expanded.
+		return Host{Name: name, Port: port, Insecure: insecure}, nil
+	}
+	if err := validateHostname(name); err != nil {
+		return Host{}, err
+	}
+	name = canonicalIP(name)
+	h := Host{Name: name, Port: port, Insecure: insecure}
+	if isIPLiteral(name) {
+		h.ResolvedIPs = []string{name}
+	}
+	return h, nil
+}
+
+// canonicalIP returns the canonical form of name if it is an ip literal (e.g.
+// "fd00::1" for "fd00:0::1"), preserving any zone, such that the same address
+// is always represented by the same string. Other names are returned as is.
+func canonicalIP(name string) string {
+	ip, zone := name, ""
+	if i := strings.LastIndex(name, "%"); i != -1 {
+		ip, zone = name[:i], name[i:]
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return name
+	}
+	return parsed.String() + zone
+}
+
+// stripComment removes a trailing '#' comment from s, e.g. for
+// "10.0.0.5:7000 # rack-a origin", and trims surrounding whitespace. A comment
+// must begin the entry or follow whitespace, such that '#' within an address
+// (e.g. a URL fragment) is preserved.
+func stripComment(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t') {
+			s = s[:i]
+			break
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+// isBareIPv6 returns true if addr is an unbracketed ipv6 literal, which
+// cannot carry a port.
+func isBareIPv6(addr string) bool {
+	ip := net.ParseIP(addr)
+	return ip != nil && ip.To4() == nil
+}
+
+// isBareIPv6CIDR returns true if addr is an unbracketed ipv6 CIDR block, which
+// cannot carry a port.
+func isBareIPv6CIDR(addr string) bool {
+	ip, _, err := net.ParseCIDR(addr)
+	return err == nil && ip.To4() == nil
+}
+
+// expandCIDR expands h, whose Name is a CIDR block, into a Host per usable
+// address of the block in ascending order. The network and broadcast addresses
+// of ipv4 blocks, and the subnet-router anycast address of ipv6 blocks, are
+// not hosts and are skipped, except in point-to-point (/31, /127) and single
+// address blocks. Blocks larger than _maxCIDRExpansion are rejected.
+func expandCIDR(h Host) ([]Host, error) {
+	_, block, err := net.ParseCIDR(h.Name)
+	if err != nil {
+		return nil, err
+	}
+	ones, bits := block.Mask.Size()
+	if bits-ones > 30 || 1<<uint(bits-ones) > _maxCIDRExpansion {
+		return nil, fmt.Errorf("cidr block exceeds limit of %d addresses", _maxCIDRExpansion)
+	}
+	var hosts []Host
+	ip := append(net.IP(nil), block.IP...)
+	for block.Contains(ip) {
+		e := h
+		e.Name = ip.String()
+		e.ResolvedIPs = []string{e.Name}
+		hosts = append(hosts, e)
+		if !incrementIP(ip) {
+			break
+		}
+	}
+	if bits-ones >= 2 {
+		hosts = hosts[1:]
+		if bits == 8*net.IPv4len {
+			hosts = hosts[:len(hosts)-1]
+		}
+	}
+	return hosts, nil
+}
+
+// incrementIP increments ip in place. Returns false if ip overflowed.
+func incrementIP(ip net.IP) bool {
+	for i := len(ip) - 1; i >= 0; i-- {
+		ip[i]++
+		if ip[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePort parses a decimal port in the range [1, 65535]. Unlike
+// strconv.Atoi, signs are rejected.
+func parsePort(s string) (int, error) {
+	if s == "" {
+		return 0, errors.New("empty port")
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid port %q", s)
+		}
+	}
+	port, err := strconv.Atoi(s)
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, fmt.Errorf("port out of range: %s", s)
+	}
+	return port, nil
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// _entryCorpus holds well-formed and malformed entries used to seed
+// TestParseEntryStress.
+var _entryCorpus = []string{
+	"a:80", "a.b.c:80", " a:80 ", "a", "a.", "10.0.0.1", "10.0.0.1:80", "::1", "[::1]:80",
+	"fe80::1%eth0", "[fe80::1%eth0]:80", "a:80|insecure", "[::1]:80|insecure",
+	"", ":", ":80", "a:", "a:0", "a:65536", "a:-1", "a:+80", "a:b:c", "[::1", "::1]:80",
+	"[a]:80", "a|", "a:80|", "a:80|skip-verify", "a:80|insecure|insecure", "-a:80", "a_b:80",
+	"a..b:80", "a b:80", "[]:80", "[[::1]]:80", "a:99999999999999999999",
+	"10.0.0.0/30:80", "10.0.0.0/30", "[fd00::/126]:80", "fd00::/127", "10.0.0.0/8:80", "10.0.0.0/33:80",
+	"10.0.0.1/32:80|insecure", "/30:80", "a/30:80", "a:80 # comment", "# a:80", "a:80#", "a:80 #",
+}
+
+func TestParseEntry(t *testing.T) {
+	tests := []struct {
+		entry    string
+		expected Host
+	}{
+		{"a:80", Host{Name: "a", Port: 80}},
+		{" a.b:80 ", Host{Name: "a.b", Port: 80}},
+		{"a", Host{Name: "a", Port: 7}},
+		{"10.0.0.1", Host{Name: "10.0.0.1", Port: 7, ResolvedIPs: []string{"10.0.0.1"}}},
+		{"10.0.0.1:80", Host{Name: "10.0.0.1", Port: 80, ResolvedIPs: []string{"10.0.0.1"}}},
+		{"::1", Host{Name: "::1", Port: 7, ResolvedIPs: []string{"::1"}}},
+		{"[::1]:80", Host{Name: "::1", Port: 80, ResolvedIPs: []string{"::1"}}},
+		{"[fe80::1%eth0]:80", Host{Name: "fe80::1%eth0", Port: 80, ResolvedIPs: []string{"fe80::1%eth0"}}},
+		{"a:80|insecure", Host{Name: "a", Port: 80, Insecure: true}},
+		{"a:80 # rack-a origin", Host{Name: "a", Port: 80}},
+		{"a:80|insecure\t# self-signed", Host{Name: "a", Port: 80, Insecure: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.entry, func(t *testing.T) {
+			hosts, err := ParseEntry(test.entry, 7)
+			require.NoError(t, err)
+			require.Equal(t, []Host{test.expected}, hosts)
+		})
+	}
+}
+
+func TestParseEntryErrors(t *testing.T) {
+	tests := []struct {
+		entry       string
+		defaultPort int
+	}{
+		{"", 7},
+		{"a", 0},
+		{"::1", 0},
+		{":80", 7},
+		{"a:", 7},
+		{"a:0", 7},
+		{"a:65536", 7},
+		{"a:+80", 7},
+		{"a:b:c", 7},
+		{"[::1", 7},
+		{"a:80|skip-verify", 7},
+		{"-a:80", 7},
+		{"a b:80", 7},
+		{"# a:80", 7},
+		{"a:80#comment", 7},
+	}
+	for _, test := range tests {
+		t.Run(test.entry, func(t *testing.T) {
+			_, err := ParseEntry(test.entry, test.defaultPort)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestParseEntryExpandsCIDRInOrder(t *testing.T) {
+	tests := []struct {
+		entry    string
+		expected []string
+	}{
+		{"10.0.0.0/30:80", []string{"10.0.0.1:80", "10.0.0.2:80"}},
+		{"10.0.0.5/29", []string{
+			"10.0.0.1:7", "10.0.0.2:7", "10.0.0.3:7", "10.0.0.4:7", "10.0.0.5:7", "10.0.0.6:7",
+		}},
+		{"10.0.0.255/31:80", []string{"10.0.0.254:80", "10.0.0.255:80"}},
+		{"[fd00::/126]:80", []string{"[fd00::1]:80", "[fd00::2]:80", "[fd00::3]:80"}},
+		{"[fd00::/127]:80", []string{"[fd00::]:80", "[fd00::1]:80"}},
+		{"fd00::/127", []string{"[fd00::]:7", "[fd00::1]:7"}},
+		{"255.255.255.255/32:80", []string{"255.255.255.255:80"}},
+	}
+	for _, test := range tests {
+		t.Run(test.entry, func(t *testing.T) {
+			hosts, err := ParseEntry(test.entry, 7)
+			require.NoError(t, err)
+			var addrs []string
+			for _, h := range hosts {
+				require.Equal(t, []string{h.Name}, h.ResolvedIPs)
+				addrs = append(addrs, h.Addr())
+			}
+			require.Equal(t, test.expected, addrs)
+		})
+	}
+}
+
+func TestParseEntryCIDRErrors(t *testing.T) {
+	for _, entry := range []string{
+		"10.0.0.0/8:80", "10.0.0.0/21:80", "[fd00::/64]:80", "10.0.0.0/33:80", "a/30:80",
+	} {
+		t.Run(entry, func(t *testing.T) {
+			_, err := ParseEntry(entry, 7)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestStaticCIDRPreservesOrder(t *testing.T) {
+	addrs, err := BuildOrdered(context.Background(), Config{Static: []string{"b:80", "10.0.0.0/31:80", "a:80"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"b:80", "10.0.0.0:80", "10.0.0.1:80", "a:80"}, addrs)
+}
+
+// TestParseEntryStress mutates the corpus to check that ParseEntry never
+// panics, and that every entry it accepts round trips through Host.Addr.
+func TestParseEntryStress(t *testing.T) {
+	const alphabet = "ab1.:[]%|-_ \t\x00\xff"
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		b := []byte(_entryCorpus[rng.Intn(len(_entryCorpus))])
+		for j := rng.Intn(4); j > 0; j-- {
+			c := alphabet[rng.Intn(len(alphabet))]
+			switch pos := rng.Intn(len(b) + 1); rng.Intn(3) {
+			case 0:
+				b = append(b[:pos], append([]byte{c}, b[pos:]...)...)
+			case 1:
+				if pos < len(b) {
+					b = append(b[:pos], b[pos+1:]...)
+				}
+			case 2:
+				if pos < len(b) {
+					b[pos] = c
+				}
+			}
+		}
+		entry := string(b)
+		hosts, err := ParseEntry(entry, rng.Intn(2)*80)
+		if err != nil {
+			continue
+		}
+		for _, h := range hosts {
+			result, err := ParseEntry(h.Addr(), 0)
+			require.NoError(t, err, "entry %q", entry)
+			h.Insecure = false
+			require.Equal(t, []Host{h}, result, "entry %q", entry)
+		}
+	}
+}
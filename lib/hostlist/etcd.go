@@ -0,0 +1,132 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/uber/kraken/utils/errutil"
+	"github.com/uber/kraken/utils/httputil"
+)
+
+// EtcdConfig configures the "etcd" source, which resolves addresses stored as
+// the values of all keys under a prefix in etcd, e.g. one key per registered
+// host, via the etcd v3 HTTP gateway.
+type EtcdConfig struct {
+	// Endpoints of the etcd cluster, e.g. "http://etcd-1:2379". Endpoints are
+	// tried in order until one responds.
+	Endpoints []string `yaml:"endpoints"`
+
+	// Prefix of the keys whose values are 'host:port' addresses.
+	Prefix string `yaml:"prefix"`
+}
+
+type etcdSourceFactory struct{}
+
+// Create creates a SourceProvider from an EtcdConfig.
+func (etcdSourceFactory) Create(raw interface{}) (SourceProvider, error) {
+	var c EtcdConfig
+	if err := unmarshalSourceConfig(raw, &c); err != nil {
+		return nil, fmt.Errorf("etcd source config: %s", err)
+	}
+	if len(c.Endpoints) == 0 {
+		return nil, errors.New("no etcd endpoints supplied")
+	}
+	if c.Prefix == "" {
+		return nil, errors.New("no etcd prefix supplied")
+	}
+	return &etcdProvider{c}, nil
+}
+
+type etcdProvider struct {
+	config EtcdConfig
+}
+
+// etcdRangeRequest and etcdRangeResponse are the subsets of the v3 KV range
+// API which are used. Keys and values are base64 encoded, which encoding/json
+// does for []byte.
+type etcdRangeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end"`
+}
+
+type etcdRangeResponse struct {
+	KVs []struct {
+		Value []byte `json:"value"`
+	} `json:"kvs"`
+}
+
+func (p *etcdProvider) Resolve(ctx context.Context) ([]string, error) {
+	body, err := json.Marshal(etcdRangeRequest{
+		Key:      []byte(p.config.Prefix),
+		RangeEnd: prefixRangeEnd([]byte(p.config.Prefix)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal range request: %s", err)
+	}
+	var errs []error
+	for _, endpoint := range p.config.Endpoints {
+		addrs, err := p.rangeAddrs(ctx, endpoint, body)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", endpoint, err))
+			continue
+		}
+		return addrs, nil
+	}
+	return nil, fmt.Errorf("etcd: all endpoints failed: %s", errutil.Join(errs))
+}
+
+func (p *etcdProvider) rangeAddrs(ctx context.Context, endpoint string, body []byte) ([]string, error) {
+	resp, err := httputil.Post(
+		strings.TrimSuffix(endpoint, "/")+"/v3/kv/range",
+		httputil.SendContext(ctx),
+		httputil.SendBody(bytes.NewReader(body)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var r etcdRangeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("decode: %s", err)
+	}
+	var addrs []string
+	for _, kv := range r.KVs {
+		addrs = append(addrs, strings.TrimSpace(string(kv.Value)))
+	}
+	return addrs, nil
+}
+
+func (p *etcdProvider) String() string {
+	return fmt.Sprintf("etcd %s", p.config.Prefix)
+}
+
+// prefixRangeEnd returns the smallest key greater than every key with prefix,
+// per the etcd convention for prefix range requests.
+func prefixRangeEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// Prefix is all 0xff, so range to the end of the keyspace.
+	return []byte{0}
+}
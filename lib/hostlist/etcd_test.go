@@ -0,0 +1,76 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/uber/kraken/utils/stringset"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEtcdSource(t *testing.T) {
+	require := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal("/v3/kv/range", r.URL.Path)
+		var req etcdRangeRequest
+		require.NoError(json.NewDecoder(r.Body).Decode(&req))
+		require.Equal("/kraken/origin/", string(req.Key))
+		require.Equal("/kraken/origin0", string(req.RangeEnd))
+		w.Write([]byte(`{"kvs": [{"value": "YTo4MA=="}, {"value": "Yjo4MA=="}]}`))
+	}))
+	defer server.Close()
+
+	l, err := New(Config{
+		Source: SourceEtcd,
+		SourceConfig: map[string]interface{}{
+			// The first endpoint is unreachable, so the second is used.
+			"endpoints": []string{"http://127.0.0.1:0", server.URL},
+			"prefix":    "/kraken/origin/",
+		},
+	})
+	require.NoError(err)
+	require.Equal(stringset.New("a:80", "b:80"), l.Resolve())
+}
+
+func TestEtcdSourceErrors(t *testing.T) {
+	tests := []struct {
+		desc   string
+		config map[string]interface{}
+	}{
+		{"no endpoints", map[string]interface{}{"prefix": "/kraken/"}},
+		{"no prefix", map[string]interface{}{"endpoints": []string{"http://127.0.0.1:0"}}},
+		{"all endpoints fail", map[string]interface{}{
+			"endpoints": []string{"http://127.0.0.1:0"},
+			"prefix":    "/kraken/",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := New(Config{Source: SourceEtcd, SourceConfig: test.config})
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestPrefixRangeEnd(t *testing.T) {
+	require.Equal(t, []byte("b"), prefixRangeEnd([]byte("a")))
+	require.Equal(t, []byte("b"), prefixRangeEnd([]byte{'a', 0xff}))
+	require.Equal(t, []byte{0}, prefixRangeEnd([]byte{0xff}))
+}
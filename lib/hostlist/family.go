@@ -0,0 +1,97 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"fmt"
+	"net"
+)
+
+// Address families which may be used in Config.AddressFamily.
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+
+	// FamilyBoth allows any ip version, like leaving the family unset, but
+	// signals that clients want an entry per address of dual-stack hosts. See
+	// SplitDualStack.
+	FamilyBoth = "both"
+)
+
+// addressFamily restricts ip addresses to a single ip version. The zero value
+// and FamilyBoth allow any version.
+type addressFamily string
+
+func parseAddressFamily(s string) (addressFamily, error) {
+	switch s {
+	case "", FamilyIPv4, FamilyIPv6, FamilyBoth:
+		return addressFamily(s), nil
+	default:
+		return "", fmt.Errorf(
+			"invalid address family %q, expected %q, %q or %q", s, FamilyIPv4, FamilyIPv6, FamilyBoth)
+	}
+}
+
+// allows returns true if host is within the family. Host names are always
+// allowed, since their ip version is unknown until they are resolved.
+func (f addressFamily) allows(host string) bool {
+	if f == "" || f == FamilyBoth {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return true
+	}
+	isIPv4 := ip.To4() != nil
+	return isIPv4 == (f == FamilyIPv4)
+}
+
+// filterNames returns the names within the family, preserving order.
+func (f addressFamily) filterNames(names []string) []string {
+	if f == "" || f == FamilyBoth {
+		return names
+	}
+	var result []string
+	for _, name := range names {
+		if f.allows(name) {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// filterAddrs returns the 'host:port' addresses within the family, preserving
+// order.
+func (f addressFamily) filterAddrs(addrs []string) []string {
+	if f == "" || f == FamilyBoth {
+		return addrs
+	}
+	var result []string
+	for _, addr := range addrs {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil || f.allows(host) {
+			result = append(result, addr)
+		}
+	}
+	return result
+}
+
+// getAddressFamily returns the family of a source, which defaults to the
+// global family if no override is set.
+func (c *Config) getAddressFamily(override string) (addressFamily, error) {
+	if override != "" {
+		return parseAddressFamily(override)
+	}
+	return parseAddressFamily(c.AddressFamily)
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uber/kraken/utils/stringset"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticAddressFamily(t *testing.T) {
+	static := []string{"10.0.0.1:80", "[::1]:80", "some-host:80"}
+	tests := []struct {
+		desc     string
+		config   Config
+		expected stringset.Set
+	}{
+		{
+			"any",
+			Config{Static: static},
+			stringset.New("10.0.0.1:80", "[::1]:80", "some-host:80"),
+		}, {
+			"global ipv4",
+			Config{Static: static, AddressFamily: FamilyIPv4},
+			stringset.New("10.0.0.1:80", "some-host:80"),
+		}, {
+			"static override",
+			Config{Static: static, AddressFamily: FamilyIPv4, StaticAddressFamily: FamilyIPv6},
+			stringset.New("[::1]:80", "some-host:80"),
+		}, {
+			"dns override does not apply",
+			Config{Static: static, DNSAddressFamily: FamilyIPv6},
+			stringset.New("10.0.0.1:80", "[::1]:80", "some-host:80"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			l, err := New(test.config)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, l.Resolve())
+		})
+	}
+}
+
+func TestDNSAddressFamily(t *testing.T) {
+	lookup := func(ctx context.Context, host string) ([]string, error) {
+		return []string{"10.0.0.1", "fd00::1", "10.0.0.2"}, nil
+	}
+	tests := []struct {
+		desc     string
+		family   addressFamily
+		expected []string
+	}{
+		{"any", "", []string{"10.0.0.1:80", "[fd00::1]:80", "10.0.0.2:80"}},
+		{"ipv4", FamilyIPv4, []string{"10.0.0.1:80", "10.0.0.2:80"}},
+		{"ipv6", FamilyIPv6, []string{"[fd00::1]:80"}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			r := &dnsResolver{
+				dns:    "some-dns",
+				port:   80,
+				clk:    clock.New(),
+				lookup: lookup,
+				family: test.family,
+			}
+			addrs, err := r.resolveOrdered(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, test.expected, addrs)
+		})
+	}
+}
+
+func TestDNSAddressFamilyNoMatchingAddresses(t *testing.T) {
+	r := &dnsResolver{
+		dns:  "some-dns",
+		port: 80,
+		clk:  clock.New(),
+		lookup: func(ctx context.Context, host string) ([]string, error) {
+			return []string{"10.0.0.1"}, nil
+		},
+		family: FamilyIPv6,
+	}
+	_, err := r.resolve(context.Background())
+	require.Error(t, err)
+}
+
+func TestInvalidAddressFamily(t *testing.T) {
+	_, err := New(Config{Static: []string{"a:80"}, AddressFamily: "ipv5"})
+	require.Error(t, err)
+}
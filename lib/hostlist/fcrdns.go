@@ -0,0 +1,91 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/uber/kraken/utils/log"
+)
+
+// _fcrdnsTimeout bounds the reverse and forward lookups of each address
+// checked for forward-confirmed reverse DNS.
+const _fcrdnsTimeout = 2 * time.Second
+
+// filterFCrDNS returns the names which pass forward-confirmed reverse DNS,
+// preserving order. Addresses are checked concurrently, and each failure is
+// logged.
+func (r *dnsResolver) filterFCrDNS(ctx context.Context, names []string) []string {
+	ctx, span := startSpan(ctx, "hostlist.fcrdns")
+	defer span.Finish(nil)
+
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			errs[i] = r.checkFCrDNS(ctx, name)
+		}(i, name)
+	}
+	wg.Wait()
+
+	var result []string
+	for i, name := range names {
+		if errs[i] != nil {
+			log.With("dns", r.dns, "addr", name).Warnf("Dropping address which failed fcrdns: %s", errs[i])
+			continue
+		}
+		result = append(result, name)
+	}
+	span.SetTag("dropped", len(names)-len(result))
+	return result
+}
+
+// checkFCrDNS returns an error unless some PTR name of ip resolves back to ip.
+func (r *dnsResolver) checkFCrDNS(ctx context.Context, ip string) error {
+	if net.ParseIP(ip) == nil {
+		return errors.New("not an ip address")
+	}
+	ctx, cancel := context.WithTimeout(ctx, _fcrdnsTimeout)
+	defer cancel()
+
+	ptrs, err := r.lookupAddr(ctx, ip)
+	if err != nil {
+		return fmt.Errorf("reverse lookup: %s", err)
+	}
+	if len(ptrs) == 0 {
+		return errors.New("no ptr records")
+	}
+	var errs []string
+	for _, ptr := range ptrs {
+		ips, err := r.lookup(ctx, ptr)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", ptr, err))
+			continue
+		}
+		for _, forward := range ips {
+			if net.ParseIP(forward).Equal(net.ParseIP(ip)) {
+				return nil
+			}
+		}
+		errs = append(errs, fmt.Sprintf("%s: does not resolve to %s", ptr, ip))
+	}
+	return fmt.Errorf("no ptr name confirms address: %v", errs)
+}
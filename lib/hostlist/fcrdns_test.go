@@ -0,0 +1,69 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func newFCrDNSTestResolver(ptrs map[string][]string, forward map[string][]string) *dnsResolver {
+	return &dnsResolver{
+		dns:  "some-dns",
+		port: 80,
+		clk:  clock.New(),
+		lookup: func(ctx context.Context, host string) ([]string, error) {
+			ips, ok := forward[host]
+			if !ok {
+				return nil, errors.New("no such host")
+			}
+			return ips, nil
+		},
+		lookupAddr: func(ctx context.Context, addr string) ([]string, error) {
+			names, ok := ptrs[addr]
+			if !ok {
+				return nil, errors.New("no such host")
+			}
+			return names, nil
+		},
+	}
+}
+
+func TestDNSResolverRequireFCrDNS(t *testing.T) {
+	r := newFCrDNSTestResolver(map[string][]string{
+		"10.0.0.1": {"a.example."},
+		"10.0.0.2": {"evil.example."},
+		"10.0.0.4": {"missing.example.", "d.example."},
+	}, map[string][]string{
+		"some-dns":      {"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"},
+		"a.example.":    {"10.0.0.1"},
+		"evil.example.": {"192.0.2.1"},
+		"d.example.":    {"10.0.0.5", "10.0.0.4"},
+	})
+	addrs, err := r.resolveOrdered(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.1:80", "10.0.0.4:80"}, addrs)
+}
+
+func TestDNSResolverRequireFCrDNSNoConfirmedAddresses(t *testing.T) {
+	r := newFCrDNSTestResolver(nil, map[string][]string{
+		"some-dns": {"10.0.0.1"},
+	})
+	_, err := r.resolve(context.Background())
+	require.Error(t, err)
+}
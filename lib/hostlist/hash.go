@@ -0,0 +1,33 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/uber/kraken/utils/stringset"
+)
+
+// Hash returns a stable hash of the membership of addrs, such that processes
+// can cheaply compare whether their lists agree without exchanging the full
+// lists. The hash is independent of the order in which addresses were
+// resolved, since it is computed over the sorted addresses.
+func Hash(addrs stringset.Set) string {
+	// The binary encoding is sorted and length prefixed, so distinct sets
+	// never encode the same.
+	b, _ := addrs.MarshalBinary()
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"testing"
+
+	"github.com/uber/kraken/utils/stringset"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHash(t *testing.T) {
+	require := require.New(t)
+
+	h := Hash(stringset.New("a:80", "b:80", "c:80"))
+	require.Len(h, 64)
+
+	// Order independent.
+	require.Equal(h, Hash(stringset.FromSlice([]string{"c:80", "a:80", "b:80"})))
+
+	// Membership dependent, including across element boundaries.
+	require.NotEqual(h, Hash(stringset.New("a:80", "b:80")))
+	require.NotEqual(Hash(stringset.New("ab", "c")), Hash(stringset.New("a", "bc")))
+
+	require.Equal(Hash(nil), Hash(stringset.New()))
+}
+
+func TestSnapshotHash(t *testing.T) {
+	l, err := New(Config{Static: []string{"a:80", "b:80"}})
+	require.NoError(t, err)
+	require.Equal(t, Hash(stringset.New("a:80", "b:80")), l.Snapshot().SnapshotHash)
+}
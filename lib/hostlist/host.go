@@ -0,0 +1,180 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/uber/kraken/utils/errutil"
+	"github.com/uber/kraken/utils/stringset"
+)
+
+// Host is an address annotated with the ips it resolved to. Clients can dial
+// ResolvedIPs directly while still using Name for e.g. TLS server names.
+type Host struct {
+	// Name is the host portion of the address as originally configured, which
+	// may be either a hostname or an ip literal.
+	Name string
+
+	// Port is the port portion of the address.
+	Port int
+
+	// ResolvedIPs are the ips which Name resolved to. If Name is an ip literal,
+	// ResolvedIPs only contains Name.
+	ResolvedIPs []string
+
+	// Capacity is an optional relative capacity hint, e.g. as published by
+	// the host via DNS (see AttachCapacity). Zero if unknown. Use Weight for
+	// weighted selection.
+	Capacity int
+
+	// Insecure marks hosts whose TLS certificates should not be verified, e.g.
+	// origins still using self-signed certificates. See MarkInsecure.
+	Insecure bool
+
+	// Zone is the datacenter or availability zone of the host, if known. See
+	// ZoneExtractor.
+	Zone string
+
+	// Ports optionally maps port roles (e.g. "data" and "control") to ports,
+	// for hosts exposing several ports. See BuildSRVHosts.
+	Ports map[string]int
+}
+
+// PortFor returns the port of h with the given role, falling back to Port if h
+// has no role-specific ports.
+func (h Host) PortFor(role string) (int, bool) {
+	if len(h.Ports) == 0 {
+		return h.Port, true
+	}
+	port, ok := h.Ports[role]
+	return port, ok
+}
+
+// Weight returns the weight of h for weighted selection, which is its
+// Capacity if known, else DefaultCapacity.
+func (h Host) Weight() int {
+	if h.Capacity > 0 {
+		return h.Capacity
+	}
+	return DefaultCapacity
+}
+
+// Addr returns h in 'host:port' format.
+func (h Host) Addr() string {
+	return net.JoinHostPort(h.Name, strconv.Itoa(h.Port))
+}
+
+// MarkInsecure sets Insecure on each host whose address is in insecure, such as
+// the result of Config.InsecureAddrs.
+func MarkInsecure(hosts []Host, insecure stringset.Set) []Host {
+	result := make([]Host, len(hosts))
+	for i, h := range hosts {
+		result[i] = h
+		result[i].Insecure = insecure.Has(h.Addr())
+	}
+	return result
+}
+
+// DroppedHost is an address which could not be built into a Host.
+type DroppedHost struct {
+	Addr   string
+	Reason string
+}
+
+// BuildHosts resolves each 'host:port' address in addrs into a Host, sorted by
+// address. Names which are ip literals are not looked up. Returns an error
+// listing every address which could not be resolved.
+func BuildHosts(ctx context.Context, addrs stringset.Set) ([]Host, error) {
+	hosts, dropped := BuildHostsPartial(ctx, addrs)
+	var errs []error
+	for _, d := range dropped {
+		errs = append(errs, fmt.Errorf("%s: %s", d.Addr, d.Reason))
+	}
+	if err := errutil.Join(errs); err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
+
+// BuildHostsPartial is like BuildHosts, except addresses which cannot be
+// resolved are skipped and reported as dropped (sorted by address) rather than
+// failing the whole build.
+func BuildHostsPartial(ctx context.Context, addrs stringset.Set) ([]Host, []DroppedHost) {
+	ctx, span := startSpan(ctx, "hostlist.build_hosts")
+	defer span.Finish(nil)
+
+	var nr net.Resolver
+	var hosts []Host
+	var dropped []DroppedHost
+	for addr := range addrs {
+		h, err := buildHost(ctx, nr.LookupHost, addr)
+		if err != nil {
+			dropped = append(dropped, DroppedHost{addr, err.Error()})
+			continue
+		}
+		hosts = append(hosts, h)
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Addr() < hosts[j].Addr() })
+	sort.Slice(dropped, func(i, j int) bool { return dropped[i].Addr < dropped[j].Addr })
+	span.SetTag("hosts", len(hosts))
+	span.SetTag("dropped", len(dropped))
+	return hosts, dropped
+}
+
+type lookupHostFunc func(ctx context.Context, host string) ([]string, error)
+
+func buildHost(ctx context.Context, lookup lookupHostFunc, addr string) (Host, error) {
+	name, rawport, err := net.SplitHostPort(addr)
+	if err != nil {
+		return Host{}, fmt.Errorf("invalid addr: %s", err)
+	}
+	port, err := strconv.Atoi(rawport)
+	if err != nil {
+		return Host{}, fmt.Errorf("invalid port: %s", err)
+	}
+	if port <= 0 || port > 65535 {
+		return Host{}, fmt.Errorf("port out of range: %d", port)
+	}
+	if isIPLiteral(name) {
+		// Already an address, regardless of whether it is currently routable.
+		name = canonicalIP(name)
+		return Host{Name: name, Port: port, ResolvedIPs: []string{name}}, nil
+	}
+	ips, err := lookup(ctx, name)
+	if err != nil {
+		return Host{}, fmt.Errorf("lookup: %s", err)
+	}
+	if len(ips) == 0 {
+		return Host{}, errors.New("no ip records")
+	}
+	ips = dedupOrdered(canonicalIPs(ips))
+	sort.Strings(ips)
+	return Host{Name: name, Port: port, ResolvedIPs: ips}, nil
+}
+
+// isIPLiteral returns true if name is an ipv4 or ipv6 literal, including ipv6
+// literals with a zone (e.g. "fe80::1%eth0").
+func isIPLiteral(name string) bool {
+	if i := strings.LastIndex(name, "%"); i != -1 {
+		name = name[:i]
+	}
+	return net.ParseIP(name) != nil
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uber/kraken/utils/stringset"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildHosts(t *testing.T) {
+	require := require.New(t)
+
+	hosts, err := BuildHosts(context.Background(), stringset.New("10.0.0.1:80", "localhost:81"))
+	require.NoError(err)
+	require.Len(hosts, 2)
+
+	require.Equal(Host{Name: "10.0.0.1", Port: 80, ResolvedIPs: []string{"10.0.0.1"}}, hosts[0])
+
+	require.Equal("localhost", hosts[1].Name)
+	require.Equal(81, hosts[1].Port)
+	require.NotEmpty(hosts[1].ResolvedIPs)
+	require.Equal("localhost:81", hosts[1].Addr())
+}
+
+func TestBuildHostsErrors(t *testing.T) {
+	_, err := BuildHosts(context.Background(), stringset.New("a", "b:c"))
+	require.Error(t, err)
+}
+
+func TestBuildHostsPartial(t *testing.T) {
+	require := require.New(t)
+
+	hosts, dropped := BuildHostsPartial(
+		context.Background(), stringset.New("10.0.0.1:80", "10.0.0.2:99999", "a", "b:c"))
+
+	require.Equal([]Host{{Name: "10.0.0.1", Port: 80, ResolvedIPs: []string{"10.0.0.1"}}}, hosts)
+
+	var droppedAddrs []string
+	for _, d := range dropped {
+		droppedAddrs = append(droppedAddrs, d.Addr)
+		require.NotEmpty(d.Reason)
+	}
+	require.Equal([]string{"10.0.0.2:99999", "a", "b:c"}, droppedAddrs)
+	require.Contains(dropped[0].Reason, "port out of range")
+}
+
+func TestBuildHostOnlyLooksUpHostnames(t *testing.T) {
+	var lookups []string
+	lookup := func(ctx context.Context, host string) ([]string, error) {
+		lookups = append(lookups, host)
+		return []string{"10.0.0.9"}, nil
+	}
+
+	tests := []struct {
+		addr     string
+		expected Host
+	}{
+		{"10.255.255.1:80", Host{Name: "10.255.255.1", Port: 80, ResolvedIPs: []string{"10.255.255.1"}}},
+		{"[2001:db8::1]:80", Host{Name: "2001:db8::1", Port: 80, ResolvedIPs: []string{"2001:db8::1"}}},
+		{"[fe80::1%eth0]:80", Host{Name: "fe80::1%eth0", Port: 80, ResolvedIPs: []string{"fe80::1%eth0"}}},
+		{"some-host:80", Host{Name: "some-host", Port: 80, ResolvedIPs: []string{"10.0.0.9"}}},
+	}
+	for _, test := range tests {
+		h, err := buildHost(context.Background(), lookup, test.addr)
+		require.NoError(t, err)
+		require.Equal(t, test.expected, h)
+	}
+	require.Equal(t, []string{"some-host"}, lookups)
+}
+
+func TestBuildHostDedupsResolvedIPs(t *testing.T) {
+	lookup := func(ctx context.Context, host string) ([]string, error) {
+		return []string{"10.0.0.2", "fd00:0::1", "10.0.0.2", "fd00::1"}, nil
+	}
+	h, err := buildHost(context.Background(), lookup, "some-host:80")
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.2", "fd00::1"}, h.ResolvedIPs)
+	require.Len(t, SplitDualStack([]Host{h}), 2)
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	_maxHostnameLength = 253
+	_maxLabelLength    = 63
+)
+
+// validateHostname checks that name is a syntactically valid hostname or an
+// ip literal, so malformed configuration fails up front with a precise error
+// instead of an opaque resolver error at runtime. Underscores are permitted
+// since they are commonly used in internal DNS records.
+func validateHostname(name string) error {
+	if isIPLiteral(name) {
+		return nil
+	}
+	name = strings.TrimSuffix(name, ".")
+	if name == "" {
+		return errors.New("empty hostname")
+	}
+	if len(name) > _maxHostnameLength {
+		return fmt.Errorf(
+			"hostname is %d characters, exceeds limit of %d", len(name), _maxHostnameLength)
+	}
+	for i, label := range strings.Split(name, ".") {
+		if err := validateLabel(label); err != nil {
+			return fmt.Errorf("label %d of %q: %s", i, name, err)
+		}
+	}
+	return nil
+}
+
+func validateLabel(label string) error {
+	if label == "" {
+		return errors.New("empty label")
+	}
+	if len(label) > _maxLabelLength {
+		return fmt.Errorf(
+			"label is %d characters, exceeds limit of %d", len(label), _maxLabelLength)
+	}
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return errors.New("label may not begin or end with a hyphen")
+	}
+	for _, c := range label {
+		if !isHostnameChar(c) {
+			return fmt.Errorf("invalid character %q", c)
+		}
+	}
+	return nil
+}
+
+func isHostnameChar(c rune) bool {
+	return (c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9') ||
+		c == '-' || c == '_'
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateHostname(t *testing.T) {
+	tests := []struct {
+		desc  string
+		name  string
+		valid bool
+	}{
+		{"simple", "localhost", true},
+		{"fqdn", "origin-1.kraken.example.com", true},
+		{"trailing dot", "origin.example.com.", true},
+		{"underscore", "_kraken._tcp.example.com", true},
+		{"ipv4", "10.0.0.1", true},
+		{"ipv6", "::1", true},
+		{"max label", strings.Repeat("a", 63) + ".com", true},
+		{"empty", "", false},
+		{"empty label", "origin..example.com", false},
+		{"leading dot", ".example.com", false},
+		{"300 chars", strings.Repeat("abcdefghi.", 30), false},
+		{"long label", strings.Repeat("a", 64) + ".com", false},
+		{"leading hyphen", "-origin.example.com", false},
+		{"trailing hyphen", "origin-.example.com", false},
+		{"invalid char", "origin!.example.com", false},
+		{"space", "origin 1", false},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := validateHostname(test.name)
+			if test.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestNewRejectsInvalidHostnames(t *testing.T) {
+	long := strings.Repeat("a", 300)
+	tests := []struct {
+		desc   string
+		config Config
+	}{
+		{"static too long", Config{Static: []string{long + ":80"}}},
+		{"static empty label", Config{Static: []string{"a..b:80"}}},
+		{"dns too long", Config{DNS: long + ":80"}},
+		{"dns empty label", Config{DNS: "a..b:80"}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := New(test.config)
+			require.Error(t, err)
+		})
+	}
+}
@@ -14,17 +14,25 @@
 package hostlist
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/uber/kraken/utils/dedup"
+	"github.com/uber/kraken/utils/errutil"
 	"github.com/uber/kraken/utils/log"
 	"github.com/uber/kraken/utils/stringset"
 
 	"github.com/andres-erbsen/clock"
+	"github.com/uber-go/tally"
 )
 
 // List defines a list of addresses which is subject to change.
@@ -32,13 +40,110 @@ type List interface {
 	Resolve() stringset.Set
 }
 
+// DynamicList is a List backed by a configured source, whose resolved
+// addresses can be manually overridden at runtime (e.g. during DNS incidents).
+type DynamicList interface {
+	Subscriber
+
+	// ResolveFresh is like Resolve, except it fails closed if the latest
+	// successful snapshot is older than maxAge, e.g. because refreshes have been
+	// failing or are wedged. A stale snapshot is refreshed synchronously before
+	// giving up. Overrides are always considered fresh.
+	ResolveFresh(maxAge time.Duration) (stringset.Set, error)
+
+	// ResolveFrozen is like Resolve, except the returned set is read-only and
+	// shared between callers instead of copied, making it cheap for consumers
+	// which only read the set.
+	ResolveFrozen() stringset.FrozenSet
+
+	// Override forces Resolve to return set, regardless of what the configured
+	// source resolves to, until ClearOverride is called. Snapshots of the
+	// configured source continue to be refreshed in the meantime.
+	Override(set stringset.Set) error
+
+	// ClearOverride releases a previous Override, such that Resolve returns
+	// the latest snapshot of the configured source again.
+	ClearOverride()
+
+	// SetPort changes the port attached to resolved names which lack one (e.g.
+	// hosts within a DNS record), starting from the next refresh. If ForcePort
+	// is configured, port replaces the port of every resolved address instead.
+	SetPort(port int) error
+
+	// SetStatic validates and replaces the statically configured addresses the
+	// list resolves from, starting from the next refresh. Returns an error if
+	// the list has no static source.
+	SetStatic(entries []string) error
+
+	// Refresh synchronously takes a new snapshot of the configured source,
+	// bypassing both the refresh interval and any negatively cached lookups.
+	Refresh() error
+
+	// OnRefresh registers fn to be called with the new snapshot, and the
+	// addresses added and removed since the previous one, after each refresh.
+	// fn is immediately called once with the current snapshot, all of which
+	// counts as added. Callbacks are called in registration order, without
+	// holding the list lock. Since they run as part of the refresh itself,
+	// callbacks must not call back into the list, and should not block.
+	//
+	// By default fn is called after every refresh, even if nothing changed.
+	// See WithMinChange and WithMinChangeFraction to dampen churn.
+	OnRefresh(fn RefreshFunc, opts ...RefreshOption)
+
+	// Snapshot returns the full state of the list, captured under a single
+	// lock such that all of it is mutually consistent. The returned sets are
+	// copies, and may be modified freely.
+	Snapshot() ListState
+
+	// DebugHandler returns a read-only http.Handler which renders the current
+	// state of the list as JSON, e.g. to be mounted on an admin mux.
+	DebugHandler() http.Handler
+
+	// Shutdown marks the list as shutting down, after which errors refreshing
+	// the list are only logged at debug level, since e.g. the network may
+	// already be torn down. The list otherwise keeps working as usual.
+	Shutdown()
+	// Stop stops the background refreshes started by WithBackgroundRefresh, and
+	// waits for any refresh in progress to finish. The list keeps working, and
+	// still refreshes on Resolve. Safe to call multiple times.
+	Stop()
+}
+
 type list struct {
-	resolver resolver
+	resolver  resolver
+	source    string // Name of the configured source, for stats.
+	ttl       time.Duration
+	minTTL    time.Duration
+	maxTTL    time.Duration
+	forcePort bool
+	budget    time.Duration
+	stats     tally.Scope
+	clk       clock.Clock
 
 	snapshotTrap *dedup.IntervalTrap
 
-	mu       sync.RWMutex
-	snapshot stringset.Set
+	refreshInterval time.Duration // Background refreshes, if non-zero.
+	stopOnce        sync.Once
+	stopc           chan struct{}
+	wg              sync.WaitGroup
+
+	// Serializes refresh callbacks, such that they observe snapshots in order.
+	callbackMu    sync.Mutex
+	callbacks     []*refreshCallback
+	subscriptions map[*subscription]struct{}
+
+	shuttingDown int32 // Accessed atomically.
+
+	mu          sync.RWMutex
+	snapshot    stringset.Set
+	override    stringset.Set
+	frozen      stringset.FrozenSet // Frozen copy of override, else snapshot.
+	port        int                 // Only set once SetPort is called.
+	lastRefresh time.Time
+	lastSuccess time.Time
+	lastLatency time.Duration
+	lastErr     error
+	provenance  map[string]int
 }
 
 // New creates a new List.
@@ -47,10 +152,46 @@ type list struct {
 // of addresses.
 //
 // If List is backed by DNS, it will be periodically refreshed (defined by TTL
-// in config). If, after construction, there is an error resolving DNS, the
-// latest successful snapshot is used. As such, Resolve never returns an empty
-// set.
-func New(config Config) (List, error) {
+// in config, or by the record TTL if DNSRecordTTL is set). If, after
+// construction, there is an error resolving DNS, the latest successful snapshot
+// is used. As such, Resolve never returns an empty set.
+func New(config Config, opts ...Option) (DynamicList, error) {
+	return NewContext(context.Background(), config, opts...)
+}
+
+// Option allows setting custom parameters for New.
+type Option func(*list)
+
+// WithClock configures New to use clk for all timing, i.e. refresh intervals
+// and negative caching. Intended for tests which need to control time.
+func WithClock(clk clock.Clock) Option {
+	return func(l *list) { l.clk = clk }
+}
+
+// WithBackgroundRefresh configures New to refresh the list every interval in
+// the background, such that subscribers and OnRefresh callbacks are notified
+// of changes even if Resolve is never called. Overrides Config.RefreshInterval.
+// Call Stop to stop refreshing.
+func WithBackgroundRefresh(interval time.Duration) Option {
+	return func(l *list) { l.refreshInterval = interval }
+}
+
+// WithStats configures New to report how many hosts each source contributed to
+// the latest snapshot, as "source_hosts" gauges tagged by source. With a Chain,
+// sources which were not used report zero, which helps spot e.g. an empty DNS
+// record silently masked by a static fallback.
+func WithStats(stats tally.Scope) Option {
+	return func(l *list) {
+		l.stats = stats.Tagged(map[string]string{
+			"module": "hostlist",
+		})
+	}
+}
+
+// NewContext is like New, except the initial snapshot is resolved using ctx,
+// e.g. to bound it with a deadline or to trace it with a Tracer (see
+// ContextWithTracer). Periodic refreshes do not use ctx.
+func NewContext(ctx context.Context, config Config, opts ...Option) (DynamicList, error) {
 	config.applyDefaults()
 
 	resolver, err := config.getResolver()
@@ -58,77 +199,434 @@ func New(config Config) (List, error) {
 		return nil, fmt.Errorf("invalid config: %s", err)
 	}
 
-	l := &list{resolver: resolver}
-	l.snapshotTrap = dedup.NewIntervalTrap(config.TTL, clock.New(), &snapshotTask{l})
+	l := &list{
+		resolver:  resolver,
+		source:    config.sourceName(),
+		ttl:       config.TTL,
+		minTTL:    config.MinTTL,
+		maxTTL:    config.MaxTTL,
+		forcePort: config.ForcePort,
+		budget:    config.ResolveBudget,
+		stats:     tally.NoopScope,
+		clk:       clock.New(),
+		stopc:     make(chan struct{}),
 
-	if err := l.takeSnapshot(); err != nil {
+		refreshInterval: config.RefreshInterval,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if r, ok := resolver.(clockSetter); ok {
+		r.setClock(l.clk)
+	}
+	l.snapshotTrap = dedup.NewIntervalTrap(config.TTL, l.clk, &snapshotTask{l})
+
+	if err := l.takeSnapshot(ctx); err != nil {
 		// Fail fast if a snapshot cannot be initialized.
 		return nil, err
 	}
+	if l.refreshInterval > 0 {
+		// The ticker is created before returning, such that tests advancing a
+		// mock clock never miss a tick.
+		ticker := l.clk.Ticker(l.refreshInterval)
+		l.wg.Add(1)
+		go l.refreshLoop(ticker)
+	}
 	return l, nil
 }
 
+// refreshLoop refreshes l on every tick until l is stopped.
+func (l *list) refreshLoop(ticker *clock.Ticker) {
+	defer l.wg.Done()
+	defer ticker.Stop()
+
+	task := &snapshotTask{l}
+	for {
+		select {
+		case <-ticker.C:
+			task.Run()
+		case <-l.stopc:
+			return
+		}
+	}
+}
+
 func (l *list) Resolve() stringset.Set {
 	l.snapshotTrap.Trap()
 
 	l.mu.RLock()
 	defer l.mu.RUnlock()
 
+	if l.override != nil {
+		return l.override.Copy()
+	}
 	return l.snapshot.Copy()
 }
 
+func (l *list) ResolveFresh(maxAge time.Duration) (stringset.Set, error) {
+	if set, ok := l.resolveIfFresh(maxAge); ok {
+		return set, nil
+	}
+	if err := l.takeSnapshot(context.Background()); err != nil {
+		return nil, fmt.Errorf("snapshot older than %s, refresh failed: %s", maxAge, err)
+	}
+	if set, ok := l.resolveIfFresh(maxAge); ok {
+		return set, nil
+	}
+	return nil, fmt.Errorf("snapshot older than %s", maxAge)
+}
+
+// resolveIfFresh returns the current set, unless it is a snapshot which is
+// older than maxAge.
+func (l *list) resolveIfFresh(maxAge time.Duration) (stringset.Set, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.override != nil {
+		return l.override.Copy(), true
+	}
+	if l.clk.Now().Sub(l.lastSuccess) > maxAge {
+		return nil, false
+	}
+	return l.snapshot.Copy(), true
+}
+
+func (l *list) ResolveFrozen() stringset.FrozenSet {
+	l.snapshotTrap.Trap()
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.frozen
+}
+
+func (l *list) Override(set stringset.Set) error {
+	if len(set) == 0 {
+		return errors.New("override set is empty")
+	}
+	l.callbackMu.Lock()
+	defer l.callbackMu.Unlock()
+
+	override := set.Copy()
+	l.mu.Lock()
+	l.override = override
+	l.frozen = set.Freeze()
+	l.mu.Unlock()
+
+	for s := range l.subscriptions {
+		s.deliver(override)
+	}
+	return nil
+}
+
+func (l *list) ClearOverride() {
+	l.callbackMu.Lock()
+	defer l.callbackMu.Unlock()
+
+	l.mu.Lock()
+	l.override = nil
+	l.frozen = l.snapshot.Freeze()
+	snapshot := l.snapshot
+	l.mu.Unlock()
+
+	for s := range l.subscriptions {
+		s.deliver(snapshot)
+	}
+}
+
+func (l *list) SetPort(port int) error {
+	if port <= 0 || port > 65535 {
+		return fmt.Errorf("port out of range: %d", port)
+	}
+	if r, ok := l.resolver.(portResolver); ok {
+		r.setPort(port)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.port = port
+	return nil
+}
+
+func (l *list) SetStatic(entries []string) error {
+	if len(entries) == 0 {
+		return errors.New("no static entries supplied")
+	}
+	entries, err := parseStatic(entries)
+	if err != nil {
+		return err
+	}
+	warnConflictingPorts(entries)
+	r, ok := l.resolver.(staticSetter)
+	if !ok || !r.setStatic(entries) {
+		return errors.New("list has no static source")
+	}
+	return nil
+}
+
+func (l *list) Refresh() error {
+	if r, ok := l.resolver.(negativeCacher); ok {
+		r.clearNegativeCache()
+	}
+	return l.takeSnapshot(context.Background())
+}
+
+func (l *list) OnRefresh(fn RefreshFunc, opts ...RefreshOption) {
+	l.callbackMu.Lock()
+	defer l.callbackMu.Unlock()
+
+	l.mu.RLock()
+	snapshot := l.snapshot.Copy()
+	l.mu.RUnlock()
+
+	cb := newRefreshCallback(fn, opts...)
+	l.callbacks = append(l.callbacks, cb)
+	cb.deliverInitial(snapshot)
+}
+
+func (l *list) Shutdown() {
+	atomic.StoreInt32(&l.shuttingDown, 1)
+}
+
+func (l *list) Stop() {
+	l.stopOnce.Do(func() { close(l.stopc) })
+	l.wg.Wait()
+}
+
 type snapshotTask struct {
 	list *list
 }
 
 func (t *snapshotTask) Run() {
-	if err := t.list.takeSnapshot(); err != nil {
-		log.With("source", t.list.resolver).Errorf("Error taking hostlist snapshot: %s", err)
+	if err := t.list.takeSnapshot(context.Background()); err != nil {
+		logger := log.With("source", t.list.resolver)
+		if atomic.LoadInt32(&t.list.shuttingDown) == 1 {
+			logger.Debugf("Error taking hostlist snapshot during shutdown: %s", err)
+			return
+		}
+		logger.Errorf("Error taking hostlist snapshot: %s", err)
 	}
 }
 
-func (l *list) takeSnapshot() error {
-	snapshot, err := l.resolver.resolve()
+func (l *list) takeSnapshot(ctx context.Context) error {
+	ctx, span := startSpan(ctx, "hostlist.resolve")
+	span.SetTag("source", fmt.Sprint(l.resolver))
+	start := l.clk.Now()
+	snapshot, err := resolveWithBudget(ctx, l.resolver, l.budget)
+	span.SetTag("hosts", len(snapshot))
+	span.Finish(err)
+
+	l.mu.Lock()
+	l.lastRefresh = l.clk.Now()
+	l.lastLatency = l.lastRefresh.Sub(start)
+	l.lastErr = err
+	l.mu.Unlock()
+
 	if err != nil {
 		return err
 	}
+	// The record TTL may be queried from the nameservers, so it is looked up
+	// before blocking other refreshes. Falls back to the configured TTL, e.g.
+	// when a chain falls back from DNS to a static source.
+	interval := l.ttl
+	if r, ok := l.resolver.(ttlResolver); ok {
+		if ttl, ok := r.ttl(); ok {
+			interval = clampTTL(ttl, l.minTTL, l.maxTTL)
+		}
+	}
+
+	l.callbackMu.Lock()
+	defer l.callbackMu.Unlock()
+
 	l.mu.Lock()
+	if l.forcePort && l.port != 0 {
+		snapshot = replacePort(snapshot, l.port)
+	}
 	l.snapshot = snapshot
+	l.lastSuccess = l.lastRefresh
+	// Subscribers follow what Resolve returns, i.e. the override if set.
+	current := snapshot
+	if l.override == nil {
+		l.frozen = snapshot.Freeze()
+	} else {
+		current = l.override
+	}
+	l.mu.Unlock()
+
+	provenance := l.reportProvenance(len(snapshot))
+	l.mu.Lock()
+	l.provenance = provenance
 	l.mu.Unlock()
+
+	for _, cb := range l.callbacks {
+		cb.deliver(snapshot)
+	}
+	for s := range l.subscriptions {
+		s.deliver(current)
+	}
+
+	l.snapshotTrap.SetInterval(interval)
 	return nil
 }
 
+// reportProvenance updates the per source host gauges, given the size of the
+// latest snapshot. Returns the host count per source.
+func (l *list) reportProvenance(n int) map[string]int {
+	counts := map[string]int{l.source: n}
+	if r, ok := l.resolver.(provenanceResolver); ok {
+		counts = r.provenance()
+	}
+	for source, count := range counts {
+		l.stats.Tagged(map[string]string{
+			"source": source,
+		}).Gauge("source_hosts").Update(float64(count))
+	}
+	return counts
+}
+
+// replacePort replaces the port of every address in addrs with port.
+func replacePort(addrs stringset.Set, port int) stringset.Set {
+	return addrs.Map(func(addr string) string {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return addr
+		}
+		return net.JoinHostPort(host, strconv.Itoa(port))
+	})
+}
+
+func clampTTL(ttl, min, max time.Duration) time.Duration {
+	if ttl < min {
+		return min
+	}
+	if ttl > max {
+		return max
+	}
+	return ttl
+}
+
 type nonLocalList struct {
 	list       List
 	localAddrs stringset.Set
+	stats      tally.Scope
+	devMode    bool
+}
+
+// StripOption allows setting custom parameters for StripLocal.
+type StripOption func(*stripOptions)
+
+type stripOptions struct {
+	localIPs bool
+	strict   bool
+	stats    tally.Scope
+	devMode  bool
+}
+
+// WithLocalIPs configures StripLocal to also filter out addresses which match
+// one of the local machine's ip addresses and the given port, e.g. for lists
+// which resolve to ips rather than hostnames.
+func WithLocalIPs() StripOption {
+	return func(o *stripOptions) { o.localIPs = true }
 }
 
-// StripLocal wraps a List and filters out the local machine, if present. The
-// local machine is identified by both its hostname and ip address, concatenated
-// with port.
+// WithStrictStripping configures StripLocal to only filter out addresses which
+// exactly match one of the local machine's ip addresses and the given port. The
+// local hostname is not considered, which prevents accidentally stripping a peer
+// which shares a name with the local machine (e.g. behind NAT). Implies
+// WithLocalIPs.
+func WithStrictStripping() StripOption {
+	return func(o *stripOptions) { o.strict = true }
+}
+
+// WithDevMode configures StripLocal to not strip anything if every resolved
+// address is the local machine, e.g. in a single node developer setup where
+// everything runs on localhost. Must never be used in production, where it
+// would make a node treat itself as its own peer.
+func WithDevMode() StripOption {
+	return func(o *stripOptions) { o.devMode = true }
+}
+
+// WithStripStats configures StripLocal to report how many addresses are
+// stripped on each Resolve. An unexpectedly high count usually means a peer is
+// being mistaken for the local machine.
+func WithStripStats(stats tally.Scope) StripOption {
+	return func(o *stripOptions) {
+		o.stats = stats.Tagged(map[string]string{
+			"module": "hostlist",
+		})
+	}
+}
+
+// StripLocal wraps a List and filters out the local machine, if present. By
+// default, the local machine is identified by its hostname concatenated with
+// port. See WithLocalIPs and WithStrictStripping to identify it by its ip
+// addresses instead or as well.
 //
 // If the local machine is the only member of list, then Resolve returns an empty
-// set.
-func StripLocal(list List, port int) (List, error) {
-	localNames, err := getLocalNames()
-	if err != nil {
-		return nil, fmt.Errorf("get local names: %s", err)
+// set, unless WithDevMode is supplied.
+func StripLocal(list List, port int, opts ...StripOption) (List, error) {
+	o := stripOptions{stats: tally.NoopScope}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	localNames := make(stringset.Set)
+	if o.localIPs || o.strict {
+		ips, err := getLocalIPs()
+		if err != nil {
+			return nil, fmt.Errorf("get local ips: %s", err)
+		}
+		localNames = ips
+	}
+	if !o.strict {
+		// Add local hostname just to be safe.
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("hostname: %s", err)
+		}
+		if isGenericHostname(hostname) {
+			// A generic hostname does not identify this machine, and would
+			// strip any peer which happens to be named the same.
+			log.With("hostname", hostname).Info("Not stripping generic local hostname from hostlist")
+		} else {
+			localNames.Add(hostname)
+		}
 	}
 	localAddrs, err := attachPortIfMissing(localNames, port)
 	if err != nil {
 		return nil, fmt.Errorf("attach port to local names: %s", err)
 	}
-	return &nonLocalList{list, localAddrs}, nil
+	return &nonLocalList{list, localAddrs, o.stats, o.devMode}, nil
 }
 
 func (l *nonLocalList) Resolve() stringset.Set {
-	return l.list.Resolve().Sub(l.localAddrs)
+	addrs := l.list.Resolve()
+	result := addrs.Sub(l.localAddrs)
+	if l.devMode && len(result) == 0 {
+		return addrs
+	}
+
+	stripped := len(addrs) - len(result)
+	l.stats.Gauge("stripped_local_addrs").Update(float64(stripped))
+	if stripped > 0 {
+		log.Debugf("Stripped local addrs from hostlist: %v", addrs.Sub(result).ToSlice())
+	}
+	return result
+}
+
+// isGenericHostname returns true if hostname is a well-known placeholder which
+// many machines share.
+func isGenericHostname(hostname string) bool {
+	switch strings.ToLower(strings.TrimSuffix(hostname, ".")) {
+	case "", "localhost", "localhost.localdomain", "localhost6", "localhost6.localdomain6":
+		return true
+	}
+	return false
 }
 
-func getLocalNames() (stringset.Set, error) {
+// getLocalIPs returns all local non-loopback ipv4 and global unicast ipv6 ips.
+func getLocalIPs() (stringset.Set, error) {
 	result := make(stringset.Set)
 
-	// Add all local non-loopback ips.
 	ifaces, err := net.Interfaces()
 	if err != nil {
 		return nil, fmt.Errorf("interfaces: %s", err)
@@ -139,38 +637,54 @@ func getLocalNames() (stringset.Set, error) {
 			return nil, fmt.Errorf("addrs of %v: %s", i, err)
 		}
 		for _, addr := range addrs {
-			ip := net.ParseIP(addr.String()).To4()
-			if ip == nil {
+			var ip net.IP
+			switch a := addr.(type) {
+			case *net.IPNet:
+				ip = a.IP
+			case *net.IPAddr:
+				ip = a.IP
+			}
+			if ip == nil || ip.IsLoopback() {
+				continue
+			}
+			if ip.To4() == nil && !ip.IsGlobalUnicast() {
+				// Link-local ipv6 addresses are only reachable with a zone,
+				// so never appear in a hostlist.
 				continue
 			}
 			result.Add(ip.String())
 		}
 	}
+	return result, nil
+}
 
-	// Add local hostname just to be safe.
-	hostname, err := os.Hostname()
+// attachPortIfMissing attaches port to each name in names which does not
+// already have one. Every malformed name is reported in the returned error.
+func attachPortIfMissing(names stringset.Set, port int) (stringset.Set, error) {
+	addrs, err := attachPortIfMissingOrdered(names.ToSlice(), port)
 	if err != nil {
-		return nil, fmt.Errorf("hostname: %s", err)
+		return nil, err
 	}
-	result.Add(hostname)
-
-	return result, nil
+	return stringset.FromSlice(addrs), nil
 }
 
-func attachPortIfMissing(names stringset.Set, port int) (stringset.Set, error) {
-	result := make(stringset.Set)
-	for name := range names {
-		parts := strings.Split(name, ":")
-		switch len(parts) {
-		case 1:
-			// Name is in 'host' format -- attach port.
-			name = fmt.Sprintf("%s:%d", parts[0], port)
-		case 2:
-			// No-op, name is already in "ip:port" format.
-		default:
-			return nil, fmt.Errorf("invalid name format: %s, expected 'host' or 'ip:port'", name)
+// attachPortIfMissingOrdered is like attachPortIfMissing, but preserves the
+// order of names.
+func attachPortIfMissingOrdered(names []string, port int) ([]string, error) {
+	var result []string
+	var errs []error
+	for _, name := range names {
+		hosts, err := ParseEntry(name, port)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, h := range hosts {
+			result = append(result, h.Addr())
 		}
-		result.Add(name)
+	}
+	if err := errutil.Join(errs); err != nil {
+		return nil, err
 	}
 	return result, nil
 }
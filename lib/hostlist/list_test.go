@@ -14,11 +14,19 @@
 package hostlist
 
 import (
+	"context"
+	"errors"
+	"net"
+	"os"
 	"testing"
+	"time"
 
+	"github.com/uber/kraken/utils/errutil"
 	"github.com/uber/kraken/utils/stringset"
 
+	"github.com/andres-erbsen/clock"
 	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
 )
 
 func TestListResolve(t *testing.T) {
@@ -32,17 +40,147 @@ func TestListResolve(t *testing.T) {
 	require.ElementsMatch(addrs, l.Resolve().ToSlice())
 }
 
+func TestListOverride(t *testing.T) {
+	require := require.New(t)
+
+	l, err := New(Config{Static: []string{"a:80", "b:80"}})
+	require.NoError(err)
+
+	require.Error(l.Override(stringset.New()))
+	require.Equal(stringset.New("a:80", "b:80"), l.Resolve())
+
+	require.NoError(l.Override(stringset.New("x:80")))
+	require.Equal(stringset.New("x:80"), l.Resolve())
+
+	l.ClearOverride()
+	require.Equal(stringset.New("a:80", "b:80"), l.Resolve())
+}
+
+func TestListResolveFrozen(t *testing.T) {
+	require := require.New(t)
+
+	l, err := New(Config{Static: []string{"a:80", "b:80"}})
+	require.NoError(err)
+	require.Equal(stringset.New("a:80", "b:80"), l.ResolveFrozen().Thaw())
+
+	// Frozen snapshots are unaffected by later snapshots.
+	f := l.ResolveFrozen()
+	require.NoError(l.SetStatic([]string{"c:80"}))
+	require.NoError(l.Refresh())
+	require.Equal(stringset.New("a:80", "b:80"), f.Thaw())
+	require.Equal(stringset.New("c:80"), l.ResolveFrozen().Thaw())
+
+	require.NoError(l.Override(stringset.New("x:80")))
+	require.Equal(stringset.New("x:80"), l.ResolveFrozen().Thaw())
+
+	// Refreshes do not replace the override.
+	require.NoError(l.Refresh())
+	require.Equal(stringset.New("x:80"), l.ResolveFrozen().Thaw())
+
+	l.ClearOverride()
+	require.Equal(stringset.New("c:80"), l.ResolveFrozen().Thaw())
+}
+
+func TestListSetPort(t *testing.T) {
+	require := require.New(t)
+
+	// Static entries have explicit ports, so SetPort is a no-op without ForcePort.
+	l, err := New(Config{Static: []string{"a:80", "b:80"}, TTL: time.Nanosecond})
+	require.NoError(err)
+	require.NoError(l.SetPort(90))
+	require.Equal(stringset.New("a:80", "b:80"), l.Resolve())
+
+	l, err = New(Config{Static: []string{"a:80", "b:81"}, ForcePort: true, TTL: time.Nanosecond})
+	require.NoError(err)
+	require.Equal(stringset.New("a:80", "b:81"), l.Resolve())
+	require.NoError(l.SetPort(90))
+	l.Resolve() // Triggers refresh.
+	require.Equal(stringset.New("a:90", "b:90"), l.Resolve())
+
+	require.Error(l.SetPort(0))
+	require.Error(l.SetPort(70000))
+}
+
+func TestListSetStatic(t *testing.T) {
+	require := require.New(t)
+
+	l, err := New(Config{Static: []string{"a:80", "b:80"}, TTL: time.Nanosecond})
+	require.NoError(err)
+
+	require.Error(l.SetStatic(nil))
+	require.Error(l.SetStatic([]string{"c:80", "d"}))
+
+	require.NoError(l.SetStatic([]string{"c:80", "d:80"}))
+	l.Resolve() // Triggers refresh.
+	require.Equal(stringset.New("c:80", "d:80"), l.Resolve())
+}
+
+func TestListSetStaticInChain(t *testing.T) {
+	require := require.New(t)
+
+	l, err := New(Config{
+		Chain:  []string{SourceStatic, SourceDNS},
+		Static: []string{"a:80"},
+		DNS:    "some-dns:80",
+		TTL:    time.Nanosecond,
+	})
+	require.NoError(err)
+
+	require.NoError(l.SetStatic([]string{"b:80"}))
+	l.Resolve() // Triggers refresh.
+	require.Equal(stringset.New("b:80"), l.Resolve())
+}
+
+func TestListRefresh(t *testing.T) {
+	require := require.New(t)
+
+	l, err := New(Config{Static: []string{"a:80"}, TTL: time.Hour})
+	require.NoError(err)
+
+	require.NoError(l.SetStatic([]string{"b:80"}))
+	require.Equal(stringset.New("a:80"), l.Resolve())
+
+	require.NoError(l.Refresh())
+	require.Equal(stringset.New("b:80"), l.Resolve())
+}
+
+func TestDNSResolverSetPort(t *testing.T) {
+	c := Config{DNS: "some-dns:80"}
+	r, err := c.getResolver()
+	require.NoError(t, err)
+
+	r.(portResolver).setPort(90)
+	require.Equal(t, "some-dns:90", r.(*dnsResolver).String())
+}
+
 func TestAttachPortIfMissing(t *testing.T) {
 	addrs, err := attachPortIfMissing(stringset.New("x", "y:5", "z"), 7)
 	require.NoError(t, err)
 	require.Equal(t, stringset.New("x:7", "y:5", "z:7"), addrs)
 }
 
+func TestAttachPortIfMissingIPv6(t *testing.T) {
+	addrs, err := attachPortIfMissing(stringset.New("::1", "[fd00::2]:5", "fd00::3"), 7)
+	require.NoError(t, err)
+	require.Equal(t, stringset.New("[::1]:7", "[fd00::2]:5", "[fd00::3]:7"), addrs)
+}
+
 func TestAttachPortIfMissingError(t *testing.T) {
 	_, err := attachPortIfMissing(stringset.New("a:b:c"), 7)
 	require.Error(t, err)
 }
 
+func TestAttachPortIfMissingReportsAllErrors(t *testing.T) {
+	_, err := attachPortIfMissing(stringset.New("a:b:c", "x", "d:e:f"), 7)
+	require.Error(t, err)
+
+	var merr errutil.MultiError
+	require.True(t, errors.As(err, &merr))
+	require.Len(t, merr, 2)
+	require.Contains(t, err.Error(), "a:b:c")
+	require.Contains(t, err.Error(), "d:e:f")
+}
+
 func TestInvalidConfig(t *testing.T) {
 	tests := []struct {
 		desc   string
@@ -50,6 +188,7 @@ func TestInvalidConfig(t *testing.T) {
 	}{
 		{"dns missing port", Config{DNS: "some-dns"}},
 		{"static missing port", Config{Static: []string{"a:80", "b"}}},
+		{"static many missing ports", Config{Static: []string{"a", "b:80", "c"}}},
 	}
 	for _, test := range tests {
 		t.Run(test.desc, func(t *testing.T) {
@@ -58,3 +197,391 @@ func TestInvalidConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestStripLocal(t *testing.T) {
+	ips, err := getLocalIPs()
+	require.NoError(t, err)
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+
+	var local []string
+	for ip := range ips {
+		local = append(local, net.JoinHostPort(ip, "80"))
+	}
+	addrs := append([]string{hostname + ":80", "x:80"}, local...)
+
+	tests := []struct {
+		desc     string
+		opts     []StripOption
+		expected []string
+	}{
+		{"default", nil, append([]string{"x:80"}, local...)},
+		{"local ips", []StripOption{WithLocalIPs()}, []string{"x:80"}},
+		{"strict", []StripOption{WithStrictStripping()}, []string{hostname + ":80", "x:80"}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			l, err := StripLocal(Fixture(addrs...), 80, test.opts...)
+			require.NoError(t, err)
+			require.ElementsMatch(t, test.expected, l.Resolve().ToSlice())
+		})
+	}
+}
+
+func TestStripLocalStats(t *testing.T) {
+	require := require.New(t)
+
+	hostname, err := os.Hostname()
+	require.NoError(err)
+
+	stats := tally.NewTestScope("", nil)
+
+	l, err := StripLocal(Fixture(hostname+":80", "x:80"), 80, WithStripStats(stats))
+	require.NoError(err)
+	require.Equal(stringset.New("x:80"), l.Resolve())
+
+	gauges := stats.Snapshot().Gauges()
+	require.Len(gauges, 1)
+	for _, g := range gauges {
+		require.Equal("stripped_local_addrs", g.Name())
+		require.Equal(float64(1), g.Value())
+	}
+}
+
+func TestStripLocalDevMode(t *testing.T) {
+	require := require.New(t)
+
+	hostname, err := os.Hostname()
+	require.NoError(err)
+
+	// Only the local machine: nothing is stripped.
+	l, err := StripLocal(Fixture(hostname+":80"), 80, WithDevMode())
+	require.NoError(err)
+	require.Equal(stringset.New(hostname+":80"), l.Resolve())
+
+	// Remote peers present: local machine is still stripped.
+	l, err = StripLocal(Fixture(hostname+":80", "x:80"), 80, WithDevMode())
+	require.NoError(err)
+	require.Equal(stringset.New("x:80"), l.Resolve())
+}
+
+func TestListStatsProvenance(t *testing.T) {
+	require := require.New(t)
+
+	stats := tally.NewTestScope("", nil)
+
+	_, err := New(Config{
+		Chain:        []string{"fake", SourceStatic},
+		Source:       "fake",
+		SourceConfig: map[string]interface{}{"addrs": []string{}},
+		Static:       []string{"a:80", "b:80"},
+	}, WithStats(stats))
+	require.NoError(err)
+
+	counts := make(map[string]float64)
+	for _, g := range stats.Snapshot().Gauges() {
+		require.Equal("source_hosts", g.Name())
+		counts[g.Tags()["source"]] = g.Value()
+	}
+	require.Equal(map[string]float64{"fake": 0, SourceStatic: 2}, counts)
+}
+
+func TestIsGenericHostname(t *testing.T) {
+	for _, h := range []string{"", "localhost", "LOCALHOST", "localhost.", "localhost.localdomain"} {
+		require.True(t, isGenericHostname(h), h)
+	}
+	for _, h := range []string{"kraken-agent-1", "localhost-1", "my.localhost.example.com"} {
+		require.False(t, isGenericHostname(h), h)
+	}
+}
+
+func TestListOnRefresh(t *testing.T) {
+	require := require.New(t)
+
+	l, err := New(Config{Static: []string{"a:80", "b:80"}, TTL: time.Hour})
+	require.NoError(err)
+
+	type update struct {
+		set, added, removed stringset.Set
+	}
+	var updates1, updates2 []update
+	l.OnRefresh(func(set, added, removed stringset.Set) {
+		updates1 = append(updates1, update{set, added, removed})
+	})
+	l.OnRefresh(func(set, added, removed stringset.Set) {
+		updates2 = append(updates2, update{set, added, removed})
+	})
+
+	require.NoError(l.SetStatic([]string{"b:80", "c:80"}))
+	require.NoError(l.Refresh())
+
+	expected := []update{
+		{stringset.New("a:80", "b:80"), stringset.New("a:80", "b:80"), stringset.New()},
+		{stringset.New("b:80", "c:80"), stringset.New("c:80"), stringset.New("a:80")},
+	}
+	require.Equal(expected, updates1)
+	require.Equal(expected, updates2)
+}
+
+func TestListWithClock(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	l, err := New(Config{Static: []string{"a:80"}, TTL: time.Minute}, WithClock(clk))
+	require.NoError(err)
+
+	require.NoError(l.SetStatic([]string{"b:80"}))
+	require.Equal(stringset.New("a:80"), l.Resolve())
+
+	clk.Add(time.Minute + time.Second)
+	require.Equal(stringset.New("b:80"), l.Resolve())
+}
+
+type fakeTTLResolver struct {
+	addrs stringset.Set
+	value time.Duration
+	ok    bool
+}
+
+func (r *fakeTTLResolver) resolve(ctx context.Context) (stringset.Set, error) {
+	return r.addrs, nil
+}
+
+func (r *fakeTTLResolver) ttl() (time.Duration, bool) {
+	return r.value, r.ok
+}
+
+func TestListRecordTTLResetsOnFailure(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	l, err := New(Config{Static: []string{"a:80"}, TTL: time.Minute}, WithClock(clk))
+	require.NoError(err)
+
+	r := &fakeTTLResolver{addrs: stringset.New("a:80"), value: 10 * time.Minute, ok: true}
+	l.(*list).resolver = r
+	require.NoError(l.Refresh())
+
+	// The record TTL applies, clamped to MaxTTL.
+	r.addrs = stringset.New("b:80")
+	clk.Add(2 * time.Minute)
+	require.Equal(stringset.New("a:80"), l.Resolve())
+
+	// Once the record TTL cannot be looked up, the configured TTL applies.
+	r.ok = false
+	require.NoError(l.Refresh())
+	r.addrs = stringset.New("c:80")
+	clk.Add(2 * time.Minute)
+	require.Equal(stringset.New("c:80"), l.Resolve())
+}
+
+func TestListOnRefreshMinChange(t *testing.T) {
+	require := require.New(t)
+
+	l, err := New(Config{Static: []string{"a:80", "b:80", "c:80", "d:80"}, TTL: time.Hour})
+	require.NoError(err)
+
+	var count, fractionCount int
+	var last stringset.Set
+	l.OnRefresh(func(set, added, removed stringset.Set) {
+		count++
+		last = added
+	}, WithMinChange(2))
+	l.OnRefresh(func(set, added, removed stringset.Set) {
+		fractionCount++
+	}, WithMinChangeFraction(0.5))
+	require.Equal(1, count)
+	require.Equal(1, fractionCount)
+
+	// Single host change is suppressed.
+	require.NoError(l.SetStatic([]string{"a:80", "b:80", "c:80", "d:80", "e:80"}))
+	require.NoError(l.Refresh())
+	require.Equal(1, count)
+	require.Equal(1, fractionCount)
+
+	// Which then accumulates with the next change.
+	require.NoError(l.SetStatic([]string{"a:80", "b:80", "c:80", "d:80", "e:80", "f:80"}))
+	require.NoError(l.Refresh())
+	require.Equal(2, count)
+	require.Equal(stringset.New("e:80", "f:80"), last)
+	require.Equal(2, fractionCount)
+
+	// No change is never delivered.
+	require.NoError(l.Refresh())
+	require.Equal(2, count)
+	require.Equal(2, fractionCount)
+}
+
+func TestListResolveFresh(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	l, err := New(Config{Static: []string{"a:80"}, TTL: time.Hour}, WithClock(clk))
+	require.NoError(err)
+
+	clk.Add(time.Minute)
+	addrs, err := l.ResolveFresh(2 * time.Minute)
+	require.NoError(err)
+	require.Equal(stringset.New("a:80"), addrs)
+
+	// Stale snapshots are refreshed synchronously.
+	clk.Add(2 * time.Minute)
+	addrs, err = l.ResolveFresh(2 * time.Minute)
+	require.NoError(err)
+	require.Equal(stringset.New("a:80"), addrs)
+	require.True(clk.Now().Equal(l.Snapshot().LastSuccess))
+
+	// If refreshing fails, the list fails closed.
+	l.(*list).resolver = fakeResolver{err: errors.New("some error")}
+	clk.Add(3 * time.Minute)
+	_, err = l.ResolveFresh(2 * time.Minute)
+	require.Error(err)
+	require.Equal(stringset.New("a:80"), l.Resolve())
+
+	// Overrides are always fresh.
+	require.NoError(l.Override(stringset.New("x:80")))
+	addrs, err = l.ResolveFresh(2 * time.Minute)
+	require.NoError(err)
+	require.Equal(stringset.New("x:80"), addrs)
+}
+
+func TestListCountsDistinctAddresses(t *testing.T) {
+	require := require.New(t)
+
+	stats := tally.NewTestScope("", nil)
+
+	static := []string{"[fd00:0::1]:80", "[fd00::1]:80", "10.0.0.0/31:80", "10.0.0.1:80", "10.0.0.0:80"}
+	l, err := New(Config{Static: static}, WithStats(stats))
+	require.NoError(err)
+	require.Equal(stringset.New("[fd00::1]:80", "10.0.0.0:80", "10.0.0.1:80"), l.Resolve())
+
+	gauges := stats.Snapshot().Gauges()
+	require.Len(gauges, 1)
+	for _, g := range gauges {
+		require.Equal(float64(3), g.Value())
+	}
+
+	addrs, err := BuildOrdered(context.Background(), Config{Static: static})
+	require.NoError(err)
+	require.Equal([]string{"[fd00::1]:80", "10.0.0.0:80", "10.0.0.1:80"}, addrs)
+}
+
+func TestListSubscribe(t *testing.T) {
+	require := require.New(t)
+
+	l, err := New(Config{Static: []string{"a:80"}})
+	require.NoError(err)
+
+	c, unsubscribe := l.Subscribe()
+	require.Equal(stringset.New("a:80"), <-c)
+
+	// Refreshes without membership changes are not delivered.
+	require.NoError(l.Refresh())
+	select {
+	case s := <-c:
+		require.FailNow("unexpected delivery", "%v", s)
+	default:
+	}
+
+	// Slow subscribers only receive the latest snapshot.
+	require.NoError(l.SetStatic([]string{"b:80"}))
+	require.NoError(l.Refresh())
+	require.NoError(l.SetStatic([]string{"c:80"}))
+	require.NoError(l.Refresh())
+	require.Equal(stringset.New("c:80"), <-c)
+
+	unsubscribe()
+	_, ok := <-c
+	require.False(ok)
+
+	// Unsubscribing is idempotent, and the list keeps refreshing.
+	unsubscribe()
+	require.NoError(l.Refresh())
+}
+
+func TestListSubscribeOverride(t *testing.T) {
+	require := require.New(t)
+
+	l, err := New(Config{Static: []string{"a:80"}})
+	require.NoError(err)
+
+	c, unsubscribe := l.Subscribe()
+	defer unsubscribe()
+	require.Equal(stringset.New("a:80"), <-c)
+
+	require.NoError(l.Override(stringset.New("x:80")))
+	require.Equal(stringset.New("x:80"), <-c)
+
+	// Refreshes of the source are not delivered while overridden.
+	require.NoError(l.SetStatic([]string{"b:80"}))
+	require.NoError(l.Refresh())
+	select {
+	case s := <-c:
+		require.FailNow("unexpected delivery", "%v", s)
+	default:
+	}
+
+	l.ClearOverride()
+	require.Equal(stringset.New("b:80"), <-c)
+}
+
+func TestListBackgroundRefresh(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	l, err := New(
+		Config{Static: []string{"a:80"}, TTL: time.Hour},
+		WithClock(clk), WithBackgroundRefresh(time.Minute))
+	require.NoError(err)
+	defer l.Stop()
+
+	c, unsubscribe := l.Subscribe()
+	defer unsubscribe()
+	require.Equal(stringset.New("a:80"), <-c)
+
+	// Subscribers are notified without any call to Resolve.
+	require.NoError(l.SetStatic([]string{"b:80"}))
+	clk.Add(time.Minute)
+	select {
+	case s := <-c:
+		require.Equal(stringset.New("b:80"), s)
+	case <-time.After(5 * time.Second):
+		require.FailNow("background refresh not delivered")
+	}
+
+	l.Stop()
+	require.NoError(l.SetStatic([]string{"c:80"}))
+	clk.Add(time.Minute)
+	select {
+	case s := <-c:
+		require.FailNow("unexpected delivery after stop", "%v", s)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Stop is idempotent.
+	l.Stop()
+}
+
+func TestListRefreshIntervalConfig(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	l, err := New(
+		Config{Static: []string{"a:80"}, TTL: time.Hour, RefreshInterval: time.Minute},
+		WithClock(clk))
+	require.NoError(err)
+	defer l.Stop()
+
+	c, unsubscribe := l.Subscribe()
+	defer unsubscribe()
+	require.Equal(stringset.New("a:80"), <-c)
+
+	require.NoError(l.SetStatic([]string{"b:80"}))
+	clk.Add(time.Minute)
+	select {
+	case s := <-c:
+		require.Equal(stringset.New("b:80"), s)
+	case <-time.After(5 * time.Second):
+		require.FailNow("background refresh not delivered")
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+// Append merges the host lists of other into c, e.g. to assemble a Config from
+// conf.d style drop-in fragments, each contributing hosts. Static entries are
+// concatenated. DNS records are accumulated as well: if the merged fragments
+// name a single distinct DNS record, it remains DNS, otherwise all of them are
+// moved to DNSRecords, in order of appearance. Every other field is taken from
+// c, so fragments should only contribute hosts.
+func (c Config) Append(other Config) Config {
+	c.Static = append(append([]string(nil), c.Static...), other.Static...)
+
+	var records []string
+	for _, cfg := range []Config{c, other} {
+		if cfg.DNS != "" {
+			records = append(records, cfg.DNS)
+		}
+		records = append(records, cfg.DNSRecords...)
+	}
+	records = dedupOrdered(records)
+
+	c.DNS = ""
+	c.DNSRecords = nil
+	switch len(records) {
+	case 0:
+	case 1:
+		c.DNS = records[0]
+	default:
+		c.DNSRecords = records
+	}
+	return c
+}
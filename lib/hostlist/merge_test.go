@@ -0,0 +1,67 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigAppend(t *testing.T) {
+	tests := []struct {
+		desc      string
+		fragments []Config
+		expected  Config
+	}{
+		{
+			"static",
+			[]Config{{Static: []string{"a:80"}}, {Static: []string{"b:80", "c:80"}}},
+			Config{Static: []string{"a:80", "b:80", "c:80"}},
+		}, {
+			"same dns",
+			[]Config{{DNS: "d:80"}, {DNS: "d:80"}},
+			Config{DNS: "d:80"},
+		}, {
+			"multiple dns",
+			[]Config{{DNS: "d1:80"}, {DNS: "d2:80"}, {DNSRecords: []string{"d1:80", "d3:80"}}},
+			Config{DNSRecords: []string{"d1:80", "d2:80", "d3:80"}},
+		}, {
+			"other fields from first fragment",
+			[]Config{{Static: []string{"a:80"}, TTL: time.Minute}, {Static: []string{"b:80"}, TTL: time.Hour}},
+			Config{Static: []string{"a:80", "b:80"}, TTL: time.Minute},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			result := test.fragments[0]
+			for _, f := range test.fragments[1:] {
+				result = result.Append(f)
+			}
+			require.Equal(t, test.expected, result)
+		})
+	}
+}
+
+func TestConfigAppendDoesNotAlias(t *testing.T) {
+	base := Config{Static: make([]string, 1, 10)}
+	base.Static[0] = "a:80"
+
+	c1 := base.Append(Config{Static: []string{"b:80"}})
+	c2 := base.Append(Config{Static: []string{"c:80"}})
+
+	require.Equal(t, []string{"a:80", "b:80"}, c1.Static)
+	require.Equal(t, []string{"a:80", "c:80"}, c2.Static)
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/uber/kraken/utils/errutil"
+	"github.com/uber/kraken/utils/log"
+	"github.com/uber/kraken/utils/stringset"
+
+	"github.com/andres-erbsen/clock"
+)
+
+// multiDNSResolver merges the hosts of multiple DNS records.
+type multiDNSResolver struct {
+	resolvers  []*dnsResolver
+	requireAll bool
+}
+
+func (r *multiDNSResolver) resolve(ctx context.Context) (stringset.Set, error) {
+	addrs, err := r.resolveOrdered(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return stringset.FromSlice(addrs), nil
+}
+
+// Resolve implements SourceProvider.
+func (r *multiDNSResolver) Resolve(ctx context.Context) ([]string, error) {
+	return r.resolveOrdered(ctx)
+}
+
+// resolveOrdered resolves every record in order, concatenating their hosts.
+func (r *multiDNSResolver) resolveOrdered(ctx context.Context) ([]string, error) {
+	var addrs []string
+	var errs []error
+	for _, rr := range r.resolvers {
+		result, err := rr.resolveOrdered(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", rr, err))
+			continue
+		}
+		addrs = append(addrs, result...)
+	}
+	if err := errutil.Join(errs); err != nil {
+		if r.requireAll {
+			return nil, fmt.Errorf("not all dns records resolved: %s", err)
+		}
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("all dns records failed: %s", err)
+		}
+		log.With("dns", r).Warnf("Some dns records failed to resolve: %s", err)
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("dns records empty")
+	}
+	return dedupOrdered(addrs), nil
+}
+
+// ttl returns the smallest record ttl, such that no record is cached for
+// longer than its own ttl.
+func (r *multiDNSResolver) ttl() (time.Duration, bool) {
+	var min time.Duration
+	var found bool
+	for _, rr := range r.resolvers {
+		if ttl, ok := rr.ttl(); ok && (!found || ttl < min) {
+			min = ttl
+			found = true
+		}
+	}
+	return min, found
+}
+
+func (r *multiDNSResolver) setPort(port int) {
+	for _, rr := range r.resolvers {
+		rr.setPort(port)
+	}
+}
+
+func (r *multiDNSResolver) clearNegativeCache() {
+	for _, rr := range r.resolvers {
+		rr.clearNegativeCache()
+	}
+}
+
+func (r *multiDNSResolver) setClock(clk clock.Clock) {
+	for _, rr := range r.resolvers {
+		rr.setClock(clk)
+	}
+}
+
+func (r *multiDNSResolver) String() string {
+	var names []string
+	for _, rr := range r.resolvers {
+		names = append(names, rr.String())
+	}
+	return strings.Join(names, ",")
+}
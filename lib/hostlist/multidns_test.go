@@ -0,0 +1,102 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/uber/kraken/utils/stringset"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMultiDNSResolver(requireAll bool) *multiDNSResolver {
+	records := map[string][]string{
+		"zone1": {"10.0.0.1", "10.0.0.2"},
+		"zone2": {"10.0.0.2", "10.0.0.3"},
+		"empty": {},
+	}
+	lookup := func(ctx context.Context, host string) ([]string, error) {
+		names, ok := records[host]
+		if !ok {
+			return nil, errors.New("no such host")
+		}
+		return names, nil
+	}
+	r := &multiDNSResolver{requireAll: requireAll}
+	for _, dns := range []string{"zone1", "zone2", "empty", "dead"} {
+		r.resolvers = append(r.resolvers, &dnsResolver{
+			dns:    dns,
+			port:   80,
+			clk:    clock.New(),
+			lookup: lookup,
+		})
+	}
+	return r
+}
+
+func TestMultiDNSResolverMergesRecords(t *testing.T) {
+	r := newTestMultiDNSResolver(false)
+	r.resolvers = r.resolvers[:2]
+
+	addrs, err := r.resolveOrdered(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.1:80", "10.0.0.2:80", "10.0.0.3:80"}, addrs)
+}
+
+func TestMultiDNSResolverToleratesFailures(t *testing.T) {
+	r := newTestMultiDNSResolver(false)
+
+	addrs, err := r.resolve(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, stringset.New("10.0.0.1:80", "10.0.0.2:80", "10.0.0.3:80"), addrs)
+}
+
+func TestMultiDNSResolverRequireAll(t *testing.T) {
+	r := newTestMultiDNSResolver(true)
+
+	_, err := r.resolve(context.Background())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "empty:80")
+	require.Contains(t, err.Error(), "dead:80")
+}
+
+func TestMultiDNSResolverAllFail(t *testing.T) {
+	r := newTestMultiDNSResolver(false)
+	r.resolvers = r.resolvers[2:]
+
+	_, err := r.resolve(context.Background())
+	require.Error(t, err)
+}
+
+func TestInvalidDNSRecordsConfig(t *testing.T) {
+	tests := []struct {
+		desc   string
+		config Config
+	}{
+		{"dns and records", Config{DNS: "a:80", DNSRecords: []string{"b:80"}}},
+		{"duplicate record", Config{DNSRecords: []string{"b:80", "b:80"}}},
+		{"invalid record", Config{DNSRecords: []string{"b"}}},
+		{"records and static", Config{DNSRecords: []string{"b:80"}, Static: []string{"a:80"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := New(test.config)
+			require.Error(t, err)
+		})
+	}
+}
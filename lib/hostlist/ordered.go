@@ -0,0 +1,53 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// BuildOrdered resolves config once, preserving the order in which addresses
+// are configured (for static lists) or returned by the source (e.g. DNS record
+// order). Duplicate addresses only appear once, at their first position. The
+// result is truncated according to TakeFirst / TakeLast.
+func BuildOrdered(ctx context.Context, config Config) ([]string, error) {
+	config.applyDefaults()
+
+	if config.TakeFirst < 0 || config.TakeLast < 0 {
+		return nil, errors.New("take_first and take_last must not be negative")
+	}
+	if config.TakeFirst > 0 && config.TakeLast > 0 {
+		return nil, errors.New("both take_first and take_last supplied")
+	}
+	r, err := config.getResolver()
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %s", err)
+	}
+	ctx, cancel := withBudget(ctx, config.ResolveBudget)
+	defer cancel()
+	addrs, err := resolveOrdered(ctx, r)
+	warnIfBudgetExpired(ctx, r, err)
+	if err != nil {
+		return nil, err
+	}
+	if n := config.TakeFirst; n > 0 && n < len(addrs) {
+		addrs = addrs[:n]
+	}
+	if n := config.TakeLast; n > 0 && n < len(addrs) {
+		addrs = addrs[len(addrs)-n:]
+	}
+	return addrs, nil
+}
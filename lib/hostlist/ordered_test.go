@@ -0,0 +1,60 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildOrdered(t *testing.T) {
+	static := []string{"c:80", "a:80", "b:80", "a:80", "d:80"}
+
+	tests := []struct {
+		desc     string
+		config   Config
+		expected []string
+	}{
+		{"all", Config{Static: static}, []string{"c:80", "a:80", "b:80", "d:80"}},
+		{"take first", Config{Static: static, TakeFirst: 2}, []string{"c:80", "a:80"}},
+		{"take last", Config{Static: static, TakeLast: 2}, []string{"b:80", "d:80"}},
+		{"take more than available", Config{Static: static, TakeFirst: 10}, []string{"c:80", "a:80", "b:80", "d:80"}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			addrs, err := BuildOrdered(context.Background(), test.config)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, addrs)
+		})
+	}
+}
+
+func TestBuildOrderedInvalidConfig(t *testing.T) {
+	tests := []struct {
+		desc   string
+		config Config
+	}{
+		{"take first and last", Config{Static: []string{"a:80"}, TakeFirst: 1, TakeLast: 1}},
+		{"negative take", Config{Static: []string{"a:80"}, TakeFirst: -1}},
+		{"no source", Config{}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := BuildOrdered(context.Background(), test.config)
+			require.Error(t, err)
+		})
+	}
+}
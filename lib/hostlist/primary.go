@@ -0,0 +1,40 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"github.com/uber/kraken/utils/stringset"
+
+	"github.com/spaolacci/murmur3"
+)
+
+// Primary deterministically selects a single address from addrs, such that
+// every node resolving the same set independently agrees on it without any
+// coordination. The primary is the address with the smallest murmur3 hash,
+// with ties broken by the address itself. Returns false if addrs is empty.
+//
+// Primary is only a cheap, consistent hint: when addrs change, the primary may
+// change too.
+func Primary(addrs stringset.Set) (string, bool) {
+	var primary string
+	var min uint64
+	for addr := range addrs {
+		h := murmur3.Sum64([]byte(addr))
+		if primary == "" || h < min || (h == min && addr < primary) {
+			primary = addr
+			min = h
+		}
+	}
+	return primary, primary != ""
+}
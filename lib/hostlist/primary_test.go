@@ -0,0 +1,53 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"testing"
+
+	"github.com/uber/kraken/utils/stringset"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrimaryIsConsistent(t *testing.T) {
+	require := require.New(t)
+
+	addrs := stringset.New("10.0.0.1:80", "10.0.0.2:80", "10.0.0.3:80", "10.0.0.4:80")
+	primary, ok := Primary(addrs)
+	require.True(ok)
+	require.True(addrs.Has(primary))
+
+	for i := 0; i < 10; i++ {
+		p, ok := Primary(addrs.Copy())
+		require.True(ok)
+		require.Equal(primary, p)
+	}
+
+	// Removing a non-primary address does not change the primary.
+	for addr := range addrs {
+		if addr == primary {
+			continue
+		}
+		rest := addrs.Copy()
+		rest.Remove(addr)
+		p, _ := Primary(rest)
+		require.Equal(primary, p)
+	}
+}
+
+func TestPrimaryEmpty(t *testing.T) {
+	_, ok := Primary(stringset.New())
+	require.False(t, ok)
+}
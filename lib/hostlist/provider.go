@@ -0,0 +1,187 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/uber/kraken/utils/errutil"
+	"github.com/uber/kraken/utils/stringset"
+
+	"gopkg.in/yaml.v2"
+)
+
+const _sourceResolveTimeout = 10 * time.Second
+
+var _sources = make(map[string]SourceFactory)
+
+// Names of built-in sources which may only be selected via Config.Source.
+const (
+	SourceFile   = "file"
+	SourceConsul = "consul"
+	SourceEtcd   = "etcd"
+)
+
+func init() {
+	RegisterSource(SourceDNS, dnsSourceFactory{})
+	RegisterSource(SourceStatic, staticSourceFactory{})
+	RegisterSource(SourceFile, fileSourceFactory{})
+	RegisterSource(SourceConsul, consulSourceFactory{})
+	RegisterSource(SourceEtcd, etcdSourceFactory{})
+}
+
+// SourceProvider resolves addresses from some service discovery system, such
+// as DNS or a key-value store. Each resolved address must be in 'host:port'
+// format.
+type SourceProvider interface {
+	Resolve(ctx context.Context) ([]string, error)
+}
+
+// SourceFactory creates a SourceProvider from its raw configuration.
+type SourceFactory interface {
+	Create(config interface{}) (SourceProvider, error)
+}
+
+// RegisterSource registers a SourceFactory under name, such that it can be
+// selected via Config.Source.
+func RegisterSource(name string, factory SourceFactory) {
+	_sources[name] = factory
+}
+
+// getSourceFactory returns the source factory registered under name.
+func getSourceFactory(name string) (SourceFactory, error) {
+	factory, ok := _sources[name]
+	if !ok {
+		return nil, fmt.Errorf("no source defined with name %s", name)
+	}
+	return factory, nil
+}
+
+// unmarshalSourceConfig converts the raw source configuration into c.
+func unmarshalSourceConfig(raw interface{}, c interface{}) error {
+	b, err := yaml.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("marshal: %s", err)
+	}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return fmt.Errorf("unmarshal: %s", err)
+	}
+	return nil
+}
+
+type dnsSourceFactory struct{}
+
+// Create creates a SourceProvider from a config of the form {dns: "host:port"}.
+func (dnsSourceFactory) Create(raw interface{}) (SourceProvider, error) {
+	var c Config
+	if err := unmarshalSourceConfig(raw, &c); err != nil {
+		return nil, fmt.Errorf("dns source config: %s", err)
+	}
+	if !c.hasDNS() {
+		return nil, errors.New("no dns record supplied")
+	}
+	r, err := c.getDNSResolver()
+	if err != nil {
+		return nil, err
+	}
+	return r.(SourceProvider), nil
+}
+
+type staticSourceFactory struct{}
+
+// Create creates a SourceProvider from a config of the form
+// {static: ["host:port", ...]} or {static_file: path}.
+func (staticSourceFactory) Create(raw interface{}) (SourceProvider, error) {
+	var c Config
+	if err := unmarshalSourceConfig(raw, &c); err != nil {
+		return nil, fmt.Errorf("static source config: %s", err)
+	}
+	if !c.hasStatic() {
+		return nil, errors.New("no static list supplied")
+	}
+	r, err := c.getStaticResolver()
+	if err != nil {
+		return nil, err
+	}
+	return r.(SourceProvider), nil
+}
+
+type fileSourceFactory struct{}
+
+// Create creates a SourceProvider from a config of the form {path: path}. The
+// file holds one address per line and is re-read on every refresh, so edits to
+// it are picked up without restarts.
+func (fileSourceFactory) Create(raw interface{}) (SourceProvider, error) {
+	var c struct {
+		Path string `yaml:"path"`
+	}
+	if err := unmarshalSourceConfig(raw, &c); err != nil {
+		return nil, fmt.Errorf("file source config: %s", err)
+	}
+	if c.Path == "" {
+		return nil, errors.New("no file path supplied")
+	}
+	r, err := (&Config{StaticFile: c.Path}).getStaticFileResolver("")
+	if err != nil {
+		return nil, err
+	}
+	return r.(SourceProvider), nil
+}
+
+// sourceResolver adapts a registered SourceProvider into a resolver.
+type sourceResolver struct {
+	name     string
+	provider SourceProvider
+}
+
+func (r *sourceResolver) resolve(ctx context.Context) (stringset.Set, error) {
+	addrs, err := r.resolveOrdered(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return stringset.FromSlice(addrs), nil
+}
+
+func (r *sourceResolver) resolveOrdered(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, _sourceResolveTimeout)
+	defer cancel()
+
+	addrs, err := r.provider.Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	var errs []error
+	for _, addr := range addrs {
+		hosts, err := ParseEntry(addr, 0)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, h := range hosts {
+			result = append(result, h.Addr())
+		}
+	}
+	if err := errutil.Join(errs); err != nil {
+		return nil, err
+	}
+	return dedupOrdered(result), nil
+}
+
+func (r *sourceResolver) String() string {
+	return fmt.Sprintf("%s: %s", r.name, r.provider)
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/uber/kraken/utils/stringset"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSource struct {
+	addrs []string
+	err   error
+}
+
+func (s fakeSource) Resolve(ctx context.Context) ([]string, error) {
+	return s.addrs, s.err
+}
+
+type fakeSourceFactory struct{}
+
+func (fakeSourceFactory) Create(raw interface{}) (SourceProvider, error) {
+	var c struct {
+		Addrs []string `yaml:"addrs"`
+		Fail  bool     `yaml:"fail"`
+	}
+	if err := unmarshalSourceConfig(raw, &c); err != nil {
+		return nil, err
+	}
+	if c.Fail {
+		return fakeSource{err: errors.New("some error")}, nil
+	}
+	return fakeSource{addrs: c.Addrs}, nil
+}
+
+func init() {
+	RegisterSource("fake", fakeSourceFactory{})
+}
+
+func TestSourceProvider(t *testing.T) {
+	require := require.New(t)
+
+	l, err := New(Config{
+		Source:       "fake",
+		SourceConfig: map[string]interface{}{"addrs": []string{"a:80", "b:80"}},
+	})
+	require.NoError(err)
+	require.Equal(stringset.New("a:80", "b:80"), l.Resolve())
+}
+
+func TestSourceProviderInChain(t *testing.T) {
+	require := require.New(t)
+
+	l, err := New(Config{
+		Chain:        []string{"fake", SourceStatic},
+		Source:       "fake",
+		SourceConfig: map[string]interface{}{"fail": true},
+		Static:       []string{"c:80"},
+	})
+	require.NoError(err)
+	require.Equal(stringset.New("c:80"), l.Resolve())
+}
+
+func TestBuiltInSources(t *testing.T) {
+	require := require.New(t)
+
+	l, err := New(Config{
+		Source:       SourceStatic,
+		SourceConfig: map[string]interface{}{"static": []string{"a:80"}},
+	})
+	require.NoError(err)
+	require.Equal(stringset.New("a:80"), l.Resolve())
+
+	p, err := dnsSourceFactory{}.Create(map[string]interface{}{"dns": "some-dns:80"})
+	require.NoError(err)
+	require.Equal("some-dns:80", p.(*dnsResolver).String())
+}
+
+func TestFileSource(t *testing.T) {
+	require := require.New(t)
+
+	f, err := ioutil.TempFile("", "hosts")
+	require.NoError(err)
+	defer os.Remove(f.Name())
+	require.NoError(ioutil.WriteFile(f.Name(), []byte("a:80\n"), 0644))
+
+	l, err := New(Config{
+		Source:       SourceFile,
+		SourceConfig: map[string]interface{}{"path": f.Name()},
+	})
+	require.NoError(err)
+	require.Equal(stringset.New("a:80"), l.Resolve())
+
+	// Edits are picked up on the next refresh.
+	require.NoError(ioutil.WriteFile(f.Name(), []byte("a:80\nb:80\n"), 0644))
+	require.NoError(l.Refresh())
+	require.Equal(stringset.New("a:80", "b:80"), l.Resolve())
+}
+
+func TestInvalidSourceConfig(t *testing.T) {
+	tests := []struct {
+		desc   string
+		config Config
+	}{
+		{"unknown source", Config{Source: "foo"}},
+		{"source with static", Config{Source: "fake", Static: []string{"a:80"}}},
+		{"invalid addrs", Config{
+			Source:       "fake",
+			SourceConfig: map[string]interface{}{"addrs": []string{"a"}},
+		}},
+		{"source fails", Config{
+			Source:       "fake",
+			SourceConfig: map[string]interface{}{"fail": true},
+		}},
+		{"built-in source missing config", Config{Source: SourceDNS}},
+		{"file source missing path", Config{Source: SourceFile}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := New(test.config)
+			require.Error(t, err)
+		})
+	}
+}
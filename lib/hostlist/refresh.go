@@ -0,0 +1,81 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import "github.com/uber/kraken/utils/stringset"
+
+// RefreshFunc is called by DynamicList.OnRefresh.
+type RefreshFunc func(set, added, removed stringset.Set)
+
+// RefreshOption allows setting custom parameters for DynamicList.OnRefresh.
+type RefreshOption func(*refreshCallback)
+
+// WithMinChange suppresses refresh callbacks until at least n addresses were
+// added or removed since the last delivered snapshot. Smaller changes
+// accumulate until they cross the threshold.
+func WithMinChange(n int) RefreshOption {
+	return func(cb *refreshCallback) { cb.minChange = n }
+}
+
+// WithMinChangeFraction is like WithMinChange, except the threshold is a
+// fraction of the size of the last delivered snapshot. If both are set, a
+// change crossing either threshold is delivered.
+func WithMinChangeFraction(f float64) RefreshOption {
+	return func(cb *refreshCallback) { cb.minFraction = f }
+}
+
+// refreshCallback tracks the last snapshot delivered to fn, such that deltas
+// are always relative to what fn has seen.
+type refreshCallback struct {
+	fn          RefreshFunc
+	minChange   int
+	minFraction float64
+	last        stringset.Set
+}
+
+func newRefreshCallback(fn RefreshFunc, opts ...RefreshOption) *refreshCallback {
+	cb := &refreshCallback{fn: fn}
+	for _, opt := range opts {
+		opt(cb)
+	}
+	return cb
+}
+
+func (cb *refreshCallback) deliverInitial(snapshot stringset.Set) {
+	cb.last = snapshot.Copy()
+	cb.fn(snapshot.Copy(), snapshot.Copy(), make(stringset.Set))
+}
+
+func (cb *refreshCallback) deliver(snapshot stringset.Set) {
+	added := snapshot.Sub(cb.last)
+	removed := cb.last.Sub(snapshot)
+	if !cb.exceedsThreshold(len(added) + len(removed)) {
+		return
+	}
+	cb.last = snapshot.Copy()
+	cb.fn(snapshot.Copy(), added, removed)
+}
+
+func (cb *refreshCallback) exceedsThreshold(changed int) bool {
+	if cb.minChange == 0 && cb.minFraction == 0 {
+		return true
+	}
+	if cb.minChange > 0 && changed >= cb.minChange {
+		return true
+	}
+	if cb.minFraction > 0 && float64(changed) >= cb.minFraction*float64(len(cb.last)) {
+		return changed > 0
+	}
+	return false
+}
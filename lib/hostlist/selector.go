@@ -0,0 +1,253 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/andres-erbsen/clock"
+)
+
+// Selector orders the addresses of a List by preference, for clients choosing
+// which host to send a request to. Hosts are preferred by lowest smoothed
+// latency, as reported by ReportLatency. Hosts reported as saturated via
+// ReportSaturated are skipped until they free up.
+type Selector struct {
+	list    List
+	decay   float64
+	explore float64
+	clk     clock.Clock
+
+	isWarm     func(addr string) bool // Nil if warm preference is disabled.
+	zones      *ZoneExtractor         // Nil if zone affinity is disabled.
+	localZone  string
+	strictZone bool
+
+	mu        sync.Mutex
+	latency   map[string]float64   // EWMA of latency in nanoseconds, per addr.
+	saturated map[string]time.Time // Time until which each addr is excluded.
+}
+
+// SelectorOption allows setting custom parameters for NewSelector.
+type SelectorOption func(*Selector)
+
+// WithLatencyDecay sets the weight in (0, 1] which each new latency sample is
+// given in the smoothed latency of a host. Higher values react faster to
+// change. Defaults to 0.3.
+func WithLatencyDecay(decay float64) SelectorOption {
+	return func(s *Selector) { s.decay = decay }
+}
+
+// WithExploreProbability sets the probability in [0, 1] that Select promotes a
+// random host to the front, such that slower hosts keep being probed and can
+// recover. Defaults to 0.05.
+func WithExploreProbability(p float64) SelectorOption {
+	return func(s *Selector) { s.explore = p }
+}
+
+// WithSelectorClock configures NewSelector to use clk for expiring saturation
+// reports. Intended for tests which need to control time.
+func WithSelectorClock(clk clock.Clock) SelectorOption {
+	return func(s *Selector) { s.clk = clk }
+}
+
+// PreferWarm configures Select to rank hosts for which isWarm returns true,
+// e.g. hosts a connection pool already has open connections to, ahead of other
+// hosts, to amortize connection setup. Warm hosts are still ordered by latency
+// among themselves, and never take precedence over zone affinity. isWarm is
+// called without holding any selector lock.
+func PreferWarm(isWarm func(addr string) bool) SelectorOption {
+	return func(s *Selector) { s.isWarm = isWarm }
+}
+
+// WithZoneAffinity configures Select to prefer hosts in localZone (e.g. as
+// returned by ZoneExtractor.LocalZone), where the zone of each host is
+// extracted from its address by zones. Hosts in other zones are only ordered
+// after all local hosts, or, if strict is set, are never selected at all.
+func WithZoneAffinity(zones *ZoneExtractor, localZone string, strict bool) SelectorOption {
+	return func(s *Selector) {
+		s.zones = zones
+		s.localZone = localZone
+		s.strictZone = strict
+	}
+}
+
+// NewSelector creates a new Selector over list.
+func NewSelector(list List, opts ...SelectorOption) *Selector {
+	s := &Selector{
+		list:      list,
+		decay:     0.3,
+		explore:   0.05,
+		clk:       clock.New(),
+		latency:   make(map[string]float64),
+		saturated: make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ReportLatency records the latency of a request sent to addr.
+func (s *Selector) ReportLatency(addr string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, ok := s.latency[addr]
+	if !ok {
+		s.latency[addr] = float64(d)
+		return
+	}
+	s.latency[addr] = s.decay*float64(d) + (1-s.decay)*prev
+}
+
+// ReportSaturated records that addr is at its connection limit, excluding it
+// from selection until the given time. Later reports for the same addr replace
+// earlier ones.
+func (s *Selector) ReportSaturated(addr string, until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.saturated[addr] = until
+}
+
+// Select returns the current addresses of the list, most preferred first.
+// Hosts without any reported latency are treated as neutral, i.e. as having the
+// average latency of all hosts with reports. If zone affinity is configured,
+// hosts in the local zone come first. If warm preference is configured, warm
+// hosts come first within each zone. Saturated hosts are omitted, unless every
+// host is saturated, in which case all of them are returned such that callers
+// always have some host to try.
+func (s *Selector) Select() []string {
+	addrs := s.list.Resolve().ToSlice()
+	// Sort first so ties are broken consistently.
+	sort.Strings(addrs)
+
+	s.mu.Lock()
+	// Forget hosts which have left the list. Saturated hosts are still in the
+	// list, so keep their latency for when they free up.
+	current := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		current[addr] = true
+	}
+	for addr := range s.latency {
+		if !current[addr] {
+			delete(s.latency, addr)
+		}
+	}
+	addrs = s.skipSaturated(addrs, current)
+	scores := make(map[string]float64, len(addrs))
+	var sum float64
+	var n int
+	for _, addr := range addrs {
+		if l, ok := s.latency[addr]; ok {
+			scores[addr] = l
+			sum += l
+			n++
+		}
+	}
+	s.mu.Unlock()
+
+	var neutral float64
+	if n > 0 {
+		neutral = sum / float64(n)
+	}
+	for _, addr := range addrs {
+		if _, ok := scores[addr]; !ok {
+			scores[addr] = neutral
+		}
+	}
+	sort.SliceStable(addrs, func(i, j int) bool { return scores[addrs[i]] < scores[addrs[j]] })
+
+	if s.isWarm != nil {
+		var warm, cold []string
+		for _, addr := range addrs {
+			if s.isWarm(addr) {
+				warm = append(warm, addr)
+			} else {
+				cold = append(cold, addr)
+			}
+		}
+		addrs = append(warm, cold...)
+	}
+
+	preferred := addrs
+	if s.zones != nil {
+		var local, other []string
+		for _, addr := range addrs {
+			if s.zoneOf(addr) == s.localZone {
+				local = append(local, addr)
+			} else {
+				other = append(other, addr)
+			}
+		}
+		if s.strictZone {
+			addrs = local
+		} else {
+			addrs = append(local, other...)
+		}
+		preferred = addrs[:len(local)]
+		if len(local) == 0 {
+			preferred = addrs
+		}
+	}
+	// Only explore within the preferred hosts, such that exploring never
+	// crosses zones unnecessarily.
+	if len(preferred) > 1 && rand.Float64() < s.explore {
+		i := 1 + rand.Intn(len(preferred)-1)
+		preferred[0], preferred[i] = preferred[i], preferred[0]
+	}
+	return addrs
+}
+
+// skipSaturated returns the addrs which are not currently saturated, or all of
+// addrs if every one of them is. Expired reports, and reports of hosts which
+// are not in current, are forgotten. Must be called with mu held.
+func (s *Selector) skipSaturated(addrs []string, current map[string]bool) []string {
+	if len(s.saturated) == 0 {
+		return addrs
+	}
+	now := s.clk.Now()
+	var available []string
+	for _, addr := range addrs {
+		if until, ok := s.saturated[addr]; ok && now.Before(until) {
+			continue
+		}
+		available = append(available, addr)
+	}
+	for addr, until := range s.saturated {
+		if !current[addr] || !now.Before(until) {
+			delete(s.saturated, addr)
+		}
+	}
+	if len(available) == 0 {
+		return addrs
+	}
+	return available
+}
+
+func (s *Selector) zoneOf(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if isIPLiteral(host) {
+		return ""
+	}
+	return s.zones.Zone(host)
+}
@@ -0,0 +1,206 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectorPrefersLowestLatency(t *testing.T) {
+	require := require.New(t)
+
+	s := NewSelector(Fixture("a:80", "b:80", "c:80"), WithExploreProbability(0))
+
+	s.ReportLatency("a:80", 300*time.Millisecond)
+	s.ReportLatency("b:80", 100*time.Millisecond)
+
+	// c has no data, so it is neutral -- the average of a and b.
+	require.Equal([]string{"b:80", "c:80", "a:80"}, s.Select())
+
+	// a recovers.
+	for i := 0; i < 10; i++ {
+		s.ReportLatency("a:80", 10*time.Millisecond)
+	}
+	require.Equal("a:80", s.Select()[0])
+}
+
+func TestSelectorLatencyDecay(t *testing.T) {
+	s := NewSelector(Fixture("a:80"), WithLatencyDecay(0.5))
+
+	s.ReportLatency("a:80", 100*time.Millisecond)
+	s.ReportLatency("a:80", 200*time.Millisecond)
+
+	require.Equal(t, float64(150*time.Millisecond), s.latency["a:80"])
+}
+
+func TestSelectorExplores(t *testing.T) {
+	s := NewSelector(Fixture("a:80", "b:80"), WithExploreProbability(1))
+
+	s.ReportLatency("a:80", time.Millisecond)
+	s.ReportLatency("b:80", time.Second)
+
+	require.Equal(t, []string{"b:80", "a:80"}, s.Select())
+}
+
+func TestSelectorForgetsRemovedHosts(t *testing.T) {
+	s := NewSelector(Fixture("a:80"))
+
+	s.ReportLatency("b:80", time.Millisecond)
+	s.Select()
+
+	require.NotContains(t, s.latency, "b:80")
+}
+
+func TestSelectorZoneAffinity(t *testing.T) {
+	zones, err := NewZoneExtractor(`^[^.]+\.([a-z0-9-]+)$`)
+	require.NoError(t, err)
+
+	tests := []struct {
+		desc     string
+		addrs    []string
+		strict   bool
+		expected []string
+	}{
+		{
+			"prefer",
+			[]string{"a.zone1:80", "b.zone2:80", "c.zone1:80"},
+			false,
+			[]string{"a.zone1:80", "c.zone1:80", "b.zone2:80"},
+		}, {
+			"strict",
+			[]string{"a.zone1:80", "b.zone2:80", "c.zone1:80"},
+			true,
+			[]string{"a.zone1:80", "c.zone1:80"},
+		}, {
+			"prefer falls back",
+			[]string{"b.zone2:80", "d.zone3:80"},
+			false,
+			[]string{"b.zone2:80", "d.zone3:80"},
+		}, {
+			"strict does not fall back",
+			[]string{"b.zone2:80", "d.zone3:80"},
+			true,
+			nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			s := NewSelector(
+				Fixture(test.addrs...),
+				WithExploreProbability(0),
+				WithZoneAffinity(zones, "zone1", test.strict))
+			require.Equal(t, test.expected, s.Select())
+		})
+	}
+}
+
+func TestSelectorZoneAffinityOrdersByLatencyWithinZone(t *testing.T) {
+	zones, err := NewZoneExtractor(`^[^.]+\.([a-z0-9-]+)$`)
+	require.NoError(t, err)
+
+	s := NewSelector(
+		Fixture("a.zone1:80", "b.zone2:80", "c.zone1:80"),
+		WithExploreProbability(0),
+		WithZoneAffinity(zones, "zone1", false))
+
+	s.ReportLatency("a.zone1:80", time.Second)
+	s.ReportLatency("b.zone2:80", time.Millisecond)
+	s.ReportLatency("c.zone1:80", 100*time.Millisecond)
+
+	require.Equal(t, []string{"c.zone1:80", "a.zone1:80", "b.zone2:80"}, s.Select())
+}
+
+func TestSelectorSkipsSaturatedHosts(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	s := NewSelector(
+		Fixture("a:80", "b:80", "c:80"), WithExploreProbability(0), WithSelectorClock(clk))
+
+	s.ReportSaturated("a:80", clk.Now().Add(time.Minute))
+	s.ReportSaturated("b:80", clk.Now().Add(2*time.Minute))
+	require.Equal([]string{"c:80"}, s.Select())
+
+	// Excluded hosts rejoin once their saturation expires.
+	clk.Add(time.Minute)
+	require.Equal([]string{"a:80", "c:80"}, s.Select())
+	require.NotContains(s.saturated, "a:80")
+
+	clk.Add(time.Minute)
+	require.Equal([]string{"a:80", "b:80", "c:80"}, s.Select())
+	require.Empty(s.saturated)
+}
+
+func TestSelectorKeepsLatencyOfSaturatedHosts(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	s := NewSelector(
+		Fixture("a:80", "b:80"), WithExploreProbability(0), WithSelectorClock(clk))
+
+	s.ReportLatency("a:80", time.Millisecond)
+	s.ReportLatency("b:80", time.Second)
+	s.ReportSaturated("a:80", clk.Now().Add(time.Minute))
+	require.Equal([]string{"b:80"}, s.Select())
+	require.Contains(s.latency, "a:80")
+
+	clk.Add(time.Minute)
+	require.Equal([]string{"a:80", "b:80"}, s.Select())
+}
+
+func TestSelectorAllHostsSaturated(t *testing.T) {
+	clk := clock.NewMock()
+	s := NewSelector(Fixture("a:80", "b:80"), WithExploreProbability(0), WithSelectorClock(clk))
+
+	s.ReportSaturated("a:80", clk.Now().Add(time.Minute))
+	s.ReportSaturated("b:80", clk.Now().Add(time.Minute))
+
+	require.Equal(t, []string{"a:80", "b:80"}, s.Select())
+}
+
+func TestSelectorPreferWarm(t *testing.T) {
+	warm := map[string]bool{"c:80": true, "b:80": true}
+	s := NewSelector(
+		Fixture("a:80", "b:80", "c:80"),
+		WithExploreProbability(0),
+		PreferWarm(func(addr string) bool { return warm[addr] }))
+
+	s.ReportLatency("a:80", time.Millisecond)
+	s.ReportLatency("b:80", 3*time.Millisecond)
+	s.ReportLatency("c:80", 2*time.Millisecond)
+
+	// Warm hosts first, each group ordered by latency.
+	require.Equal(t, []string{"c:80", "b:80", "a:80"}, s.Select())
+
+	// Without warm hosts, selection falls back to latency.
+	warm = nil
+	require.Equal(t, []string{"a:80", "c:80", "b:80"}, s.Select())
+}
+
+func TestSelectorPreferWarmWithinZone(t *testing.T) {
+	zones, err := NewZoneExtractor(`^[^.]+\.([a-z0-9-]+)$`)
+	require.NoError(t, err)
+
+	s := NewSelector(
+		Fixture("a.dca:80", "b.dca:80", "c.sjc:80"),
+		WithExploreProbability(0),
+		WithZoneAffinity(zones, "dca", false),
+		PreferWarm(func(addr string) bool { return addr != "a.dca:80" }))
+
+	require.Equal(t, []string{"b.dca:80", "a.dca:80", "c.sjc:80"}, s.Select())
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/uber/kraken/utils/errutil"
+	"github.com/uber/kraken/utils/log"
+	"github.com/uber/kraken/utils/stringset"
+)
+
+type lookupSRVFunc func(ctx context.Context, name string) ([]*net.SRV, error)
+
+// lookupSRVRecord looks up the SRV record name directly, e.g.
+// "_data._tcp.origin.example.com", rather than by service and proto.
+func lookupSRVRecord(ctx context.Context, name string) ([]*net.SRV, error) {
+	_, srvs, err := new(net.Resolver).LookupSRV(ctx, "", "", name)
+	return srvs, err
+}
+
+// srvResolver resolves the target:port pairs of an SRV record.
+type srvResolver struct {
+	name   string
+	lookup lookupSRVFunc
+	family addressFamily
+}
+
+func (c *Config) getSRVResolver() (resolver, error) {
+	if err := validateHostname(c.SRV); err != nil {
+		return nil, fmt.Errorf("invalid srv: %s", err)
+	}
+	family, err := c.getAddressFamily(c.DNSAddressFamily)
+	if err != nil {
+		return nil, fmt.Errorf("srv: %s", err)
+	}
+	return &srvResolver{name: c.SRV, lookup: lookupSRVRecord, family: family}, nil
+}
+
+func (r *srvResolver) resolve(ctx context.Context) (stringset.Set, error) {
+	addrs, err := r.resolveOrdered(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return stringset.FromSlice(addrs), nil
+}
+
+// resolveOrdered resolves addresses by priority, then by descending weight.
+func (r *srvResolver) resolveOrdered(ctx context.Context) ([]string, error) {
+	srvs, err := r.lookup(ctx, r.name)
+	if err != nil {
+		return nil, fmt.Errorf("resolve srv: %s", err)
+	}
+	var addrs []string
+	var errs []error
+	for _, srv := range sortSRV(srvs) {
+		hosts, err := ParseEntry(srvAddr(srv), 0)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, h := range hosts {
+			addrs = append(addrs, h.Addr())
+		}
+	}
+	if err := errutil.Join(errs); err != nil {
+		return nil, fmt.Errorf("srv targets: %s", err)
+	}
+	addrs = r.family.filterAddrs(dedupOrdered(addrs))
+	if len(addrs) == 0 {
+		return nil, errors.New("srv record empty")
+	}
+	return addrs, nil
+}
+
+// sortSRV returns srvs sorted by priority, then by descending weight. Targets
+// of equal priority and weight are ordered by a hash of their address, rather
+// than shuffled by weight like net.LookupSRV does, such that the order is
+// stable across lookups and consumers of the order do not churn.
+func sortSRV(srvs []*net.SRV) []*net.SRV {
+	type keyed struct {
+		srv  *net.SRV
+		hash uint64
+	}
+	ks := make([]keyed, len(srvs))
+	for i, srv := range srvs {
+		h := fnv.New64a()
+		h.Write([]byte(srvAddr(srv)))
+		ks[i] = keyed{srv, h.Sum64()}
+	}
+	sort.SliceStable(ks, func(i, j int) bool {
+		a, b := ks[i], ks[j]
+		if a.srv.Priority != b.srv.Priority {
+			return a.srv.Priority < b.srv.Priority
+		}
+		if a.srv.Weight != b.srv.Weight {
+			return a.srv.Weight > b.srv.Weight
+		}
+		return a.hash < b.hash
+	})
+	sorted := make([]*net.SRV, len(ks))
+	for i, k := range ks {
+		sorted[i] = k.srv
+	}
+	return sorted
+}
+
+// srvAddr returns the target:port of srv.
+func srvAddr(srv *net.SRV) string {
+	return net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port)))
+}
+
+// Resolve implements SourceProvider.
+func (r *srvResolver) Resolve(ctx context.Context) ([]string, error) {
+	return r.resolveOrdered(ctx)
+}
+
+func (r *srvResolver) String() string {
+	return "srv:" + r.name
+}
+
+// BuildSRVHosts discovers hosts exposing several named ports, where each port
+// role (e.g. "data" and "control") is published as its own SRV record, mapped
+// by role in services. Targets are correlated across records by hostname, such
+// that each Host carries the port of every role it publishes in Ports, and the
+// port of the primary role as Port. Hosts which do not publish the primary role
+// are skipped with a warning. Hosts are sorted by address.
+func BuildSRVHosts(ctx context.Context, services map[string]string, primary string) ([]Host, error) {
+	var nr net.Resolver
+	return buildSRVHosts(ctx, lookupSRVRecord, nr.LookupHost, services, primary)
+}
+
+func buildSRVHosts(
+	ctx context.Context,
+	lookupSRV lookupSRVFunc,
+	lookup lookupHostFunc,
+	services map[string]string,
+	primary string) ([]Host, error) {
+
+	if _, ok := services[primary]; !ok {
+		return nil, fmt.Errorf("no service defined for primary role %q", primary)
+	}
+	ports := make(map[string]map[string]int) // Target to role to port.
+	for role, service := range services {
+		srvs, err := lookupSRV(ctx, service)
+		if err != nil {
+			return nil, fmt.Errorf("lookup srv %s: %s", service, err)
+		}
+		for _, srv := range srvs {
+			target := strings.TrimSuffix(srv.Target, ".")
+			if _, ok := ports[target]; !ok {
+				ports[target] = make(map[string]int)
+			}
+			ports[target][role] = int(srv.Port)
+		}
+	}
+	var hosts []Host
+	for target, rolePorts := range ports {
+		port, ok := rolePorts[primary]
+		if !ok {
+			log.With("target", target, "primary", primary).Warn("Skipping srv target without primary role")
+			continue
+		}
+		h, err := buildHost(ctx, lookup, net.JoinHostPort(target, strconv.Itoa(port)))
+		if err != nil {
+			return nil, fmt.Errorf("srv target %s: %s", target, err)
+		}
+		h.Ports = rolePorts
+		hosts = append(hosts, h)
+	}
+	if len(hosts) == 0 {
+		return nil, errors.New("no srv targets publish the primary role")
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Addr() < hosts[j].Addr() })
+	return hosts, nil
+}
@@ -0,0 +1,215 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"testing"
+
+	"github.com/uber/kraken/utils/stringset"
+
+	"github.com/stretchr/testify/require"
+)
+
+func fakeSRVLookup(records map[string][]*net.SRV) lookupSRVFunc {
+	return func(ctx context.Context, name string) ([]*net.SRV, error) {
+		srvs, ok := records[name]
+		if !ok {
+			return nil, errors.New("no such host")
+		}
+		return srvs, nil
+	}
+}
+
+func fakeHostLookup(ctx context.Context, host string) ([]string, error) {
+	return []string{"10.0.0.1"}, nil
+}
+
+func TestBuildSRVHostsPairsPortsByTarget(t *testing.T) {
+	require := require.New(t)
+
+	lookupSRV := fakeSRVLookup(map[string][]*net.SRV{
+		"_data._tcp.origin": {
+			{Target: "a.origin.", Port: 15002},
+			{Target: "b.origin.", Port: 15002},
+			{Target: "c.origin.", Port: 15002},
+		},
+		"_control._tcp.origin": {
+			{Target: "b.origin.", Port: 15003},
+			{Target: "a.origin.", Port: 15004},
+			{Target: "d.origin.", Port: 15003},
+		},
+	})
+	hosts, err := buildSRVHosts(
+		context.Background(),
+		lookupSRV,
+		fakeHostLookup,
+		map[string]string{"data": "_data._tcp.origin", "control": "_control._tcp.origin"},
+		"data")
+	require.NoError(err)
+
+	// d does not publish the primary role, so it is skipped.
+	require.Len(hosts, 3)
+	require.Equal("a.origin:15002", hosts[0].Addr())
+	require.Equal(map[string]int{"data": 15002, "control": 15004}, hosts[0].Ports)
+	require.Equal("b.origin:15002", hosts[1].Addr())
+	require.Equal(map[string]int{"data": 15002, "control": 15003}, hosts[1].Ports)
+	require.Equal("c.origin:15002", hosts[2].Addr())
+	require.Equal([]string{"10.0.0.1"}, hosts[2].ResolvedIPs)
+
+	port, ok := hosts[1].PortFor("control")
+	require.True(ok)
+	require.Equal(15003, port)
+	_, ok = hosts[2].PortFor("control")
+	require.False(ok)
+}
+
+func TestBuildSRVHostsErrors(t *testing.T) {
+	lookupSRV := fakeSRVLookup(map[string][]*net.SRV{
+		"_data._tcp.origin":    {{Target: "a.origin.", Port: 15002}},
+		"_control._tcp.origin": {{Target: "b.origin.", Port: 15003}},
+		"_empty._tcp.origin":   {},
+	})
+	tests := []struct {
+		desc     string
+		services map[string]string
+		primary  string
+	}{
+		{"undefined primary", map[string]string{"data": "_data._tcp.origin"}, "control"},
+		{"lookup failure", map[string]string{"data": "_data._tcp.origin", "x": "_x._tcp.origin"}, "data"},
+		{"no target with primary", map[string]string{"data": "_empty._tcp.origin", "control": "_control._tcp.origin"}, "data"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := buildSRVHosts(context.Background(), lookupSRV, fakeHostLookup, test.services, test.primary)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestHostPortForWithoutRoles(t *testing.T) {
+	port, ok := Host{Name: "a", Port: 80}.PortFor("data")
+	require.True(t, ok)
+	require.Equal(t, 80, port)
+}
+
+func TestSRVResolver(t *testing.T) {
+	r := &srvResolver{
+		name: "_origin._tcp.example.com",
+		lookup: fakeSRVLookup(map[string][]*net.SRV{
+			"_origin._tcp.example.com": {
+				{Target: "c.example.com.", Port: 15002, Priority: 20},
+				{Target: "b.example.com.", Port: 15002, Priority: 10, Weight: 5},
+				{Target: "a.example.com.", Port: 15003, Priority: 10, Weight: 10},
+				{Target: "b.example.com.", Port: 15002, Priority: 10, Weight: 5},
+			},
+		}),
+	}
+	addrs, err := r.resolveOrdered(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"a.example.com:15003", "b.example.com:15002", "c.example.com:15002",
+	}, addrs)
+}
+
+func TestSRVResolverOrderIsStable(t *testing.T) {
+	require := require.New(t)
+
+	srvs := []*net.SRV{
+		{Target: "a.example.com.", Port: 15002},
+		{Target: "b.example.com.", Port: 15002},
+		{Target: "c.example.com.", Port: 15002},
+		{Target: "d.example.com.", Port: 15002},
+		{Target: "a.example.com.", Port: 15003},
+	}
+	var expected []string
+	for i := 0; i < 20; i++ {
+		// Equal priority and weight targets come back in a different order on
+		// every lookup.
+		shuffled := make([]*net.SRV, len(srvs))
+		for j, k := range rand.Perm(len(srvs)) {
+			shuffled[j] = srvs[k]
+		}
+		r := &srvResolver{
+			name:   "_origin._tcp.example.com",
+			lookup: fakeSRVLookup(map[string][]*net.SRV{"_origin._tcp.example.com": shuffled}),
+		}
+		addrs, err := r.resolveOrdered(context.Background())
+		require.NoError(err)
+		require.Len(addrs, len(srvs))
+		if expected == nil {
+			expected = addrs
+		}
+		require.Equal(expected, addrs)
+	}
+}
+
+func TestSRVResolverErrors(t *testing.T) {
+	lookup := fakeSRVLookup(map[string][]*net.SRV{
+		"empty":   {},
+		"invalid": {{Target: "a b.", Port: 80}},
+	})
+	for _, name := range []string{"missing", "empty", "invalid"} {
+		t.Run(name, func(t *testing.T) {
+			r := &srvResolver{name: name, lookup: lookup}
+			_, err := r.resolve(context.Background())
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestSRVConfigFallsBackToStatic(t *testing.T) {
+	require := require.New(t)
+
+	c := Config{SRV: "_origin._tcp.example.com", Static: []string{"c:80"}}
+	r, err := c.getResolver()
+	require.NoError(err)
+	chain, ok := r.(*chainResolver)
+	require.True(ok)
+	require.Equal([]string{SourceSRV, SourceStatic}, chain.names)
+
+	srv := chain.resolvers[0].(*srvResolver)
+	srv.lookup = fakeSRVLookup(map[string][]*net.SRV{"_origin._tcp.example.com": {}})
+	addrs, err := r.resolve(context.Background())
+	require.NoError(err)
+	require.Equal(stringset.New("c:80"), addrs)
+
+	srv.lookup = fakeSRVLookup(map[string][]*net.SRV{
+		"_origin._tcp.example.com": {{Target: "a.example.com.", Port: 15002}},
+	})
+	addrs, err = r.resolve(context.Background())
+	require.NoError(err)
+	require.Equal(stringset.New("a.example.com:15002"), addrs)
+}
+
+func TestInvalidSRVConfig(t *testing.T) {
+	tests := []struct {
+		desc   string
+		config Config
+	}{
+		{"srv with dns", Config{SRV: "_origin._tcp.example.com", DNS: "some-dns:80"}},
+		{"srv with source", Config{SRV: "_origin._tcp.example.com", Source: "fake"}},
+		{"invalid srv", Config{SRV: "a b"}},
+		{"srv in chain without record", Config{Chain: []string{SourceSRV, SourceStatic}, Static: []string{"a:80"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := test.config.getResolver()
+			require.Error(t, err)
+		})
+	}
+}
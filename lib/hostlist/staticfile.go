@@ -0,0 +1,132 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/uber/kraken/utils/stringset"
+)
+
+func (c *Config) getStaticFileResolver(family addressFamily) (resolver, error) {
+	r := &staticFileResolver{
+		path:   c.StaticFile,
+		family: family,
+	}
+	if c.StaticFilePublicKey != "" {
+		key, err := base64.StdEncoding.DecodeString(c.StaticFilePublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("decode static file public key: %s", err)
+		}
+		if len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf(
+				"static file public key is %d bytes, expected %d", len(key), ed25519.PublicKeySize)
+		}
+		if c.StaticFileSignature == "" {
+			return nil, errors.New("static file public key supplied without signature")
+		}
+		r.publicKey = ed25519.PublicKey(key)
+		r.signaturePath = c.StaticFileSignature
+	} else if c.StaticFileSignature != "" {
+		return nil, errors.New("static file signature supplied without public key")
+	}
+	return r, nil
+}
+
+// staticFileResolver resolves static addresses from a file, optionally
+// verifying its detached signature on every read.
+type staticFileResolver struct {
+	path          string
+	signaturePath string
+	publicKey     ed25519.PublicKey // Nil if signatures are not verified.
+	family        addressFamily
+}
+
+func (r *staticFileResolver) resolve(ctx context.Context) (stringset.Set, error) {
+	addrs, err := r.resolveOrdered(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return stringset.FromSlice(addrs), nil
+}
+
+// Resolve implements SourceProvider.
+func (r *staticFileResolver) Resolve(ctx context.Context) ([]string, error) {
+	return r.resolveOrdered(ctx)
+}
+
+func (r *staticFileResolver) resolveOrdered(ctx context.Context) ([]string, error) {
+	b, err := ioutil.ReadFile(r.path)
+	if err != nil {
+		return nil, fmt.Errorf("read static file: %s", err)
+	}
+	if r.publicKey != nil {
+		if err := r.verify(b); err != nil {
+			return nil, fmt.Errorf("refusing static file %s: %s", r.path, err)
+		}
+	}
+	entries, err := parseStaticFile(b)
+	if err != nil {
+		return nil, fmt.Errorf("static file %s: %s", r.path, err)
+	}
+	return r.family.filterAddrs(entries), nil
+}
+
+func (r *staticFileResolver) verify(b []byte) error {
+	rawsig, err := ioutil.ReadFile(r.signaturePath)
+	if err != nil {
+		return fmt.Errorf("read signature: %s", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(rawsig)))
+	if err != nil {
+		return fmt.Errorf("decode signature: %s", err)
+	}
+	if !ed25519.Verify(r.publicKey, b, sig) {
+		return errors.New("signature verification failed")
+	}
+	return nil
+}
+
+func (r *staticFileResolver) String() string {
+	return r.path
+}
+
+// parseStaticFile parses one address per line, skipping blank lines and
+// comments.
+func parseStaticFile(b []byte) ([]string, error) {
+	var entries []string
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("no addresses")
+	}
+	return parseStatic(entries)
+}
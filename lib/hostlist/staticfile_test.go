@@ -0,0 +1,101 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/uber/kraken/utils/stringset"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeStaticFile(t *testing.T, contents string) (dir string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "hostlist")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "hosts"), []byte(contents), 0644))
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestStaticFile(t *testing.T) {
+	require := require.New(t)
+
+	dir, cleanup := writeStaticFile(t, "# origins\na:80\n\nb:80\n")
+	defer cleanup()
+
+	l, err := New(Config{StaticFile: filepath.Join(dir, "hosts")})
+	require.NoError(err)
+	require.Equal(stringset.New("a:80", "b:80"), l.Resolve())
+}
+
+func TestSignedStaticFile(t *testing.T) {
+	require := require.New(t)
+
+	contents := "a:80\nb:80\n"
+	dir, cleanup := writeStaticFile(t, contents)
+	defer cleanup()
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(err)
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(contents)))
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, "hosts.sig"), []byte(sig+"\n"), 0644))
+
+	config := Config{
+		StaticFile:          filepath.Join(dir, "hosts"),
+		StaticFilePublicKey: base64.StdEncoding.EncodeToString(pub),
+		StaticFileSignature: filepath.Join(dir, "hosts.sig"),
+	}
+	l, err := New(config)
+	require.NoError(err)
+	require.Equal(stringset.New("a:80", "b:80"), l.Resolve())
+
+	// Tamper with membership.
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, "hosts"), []byte("evil:80\n"), 0644))
+	_, err = New(config)
+	require.Error(err)
+	require.Contains(err.Error(), "signature verification failed")
+}
+
+func TestInvalidStaticFileConfig(t *testing.T) {
+	dir, cleanup := writeStaticFile(t, "a:80\n")
+	defer cleanup()
+
+	path := filepath.Join(dir, "hosts")
+	pub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	key := base64.StdEncoding.EncodeToString(pub)
+
+	tests := []struct {
+		desc   string
+		config Config
+	}{
+		{"missing file", Config{StaticFile: filepath.Join(dir, "missing")}},
+		{"both static and file", Config{Static: []string{"a:80"}, StaticFile: path}},
+		{"key without signature", Config{StaticFile: path, StaticFilePublicKey: key}},
+		{"signature without key", Config{StaticFile: path, StaticFileSignature: path + ".sig"}},
+		{"invalid key", Config{StaticFile: path, StaticFilePublicKey: "abc", StaticFileSignature: path + ".sig"}},
+		{"missing signature", Config{StaticFile: path, StaticFilePublicKey: key, StaticFileSignature: path + ".sig"}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := New(test.config)
+			require.Error(t, err)
+		})
+	}
+}
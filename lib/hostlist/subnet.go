@@ -0,0 +1,116 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"math/rand"
+	"net"
+
+	"github.com/uber/kraken/utils/stringset"
+
+	"github.com/uber-go/tally"
+)
+
+// subnetKey returns the /24 (ipv4) or /64 (ipv6) subnet which the host of addr
+// belongs to. Returns the empty string if addr does not contain an ip literal.
+func subnetKey(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ""
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return ip.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
+
+// groupBySubnet groups addrs by subnetKey.
+func groupBySubnet(addrs stringset.Set) map[string][]string {
+	groups := make(map[string][]string)
+	for addr := range addrs {
+		k := subnetKey(addr)
+		groups[k] = append(groups[k], addr)
+	}
+	return groups
+}
+
+// SubnetDiversity returns the number of distinct subnets (see subnetKey) which
+// addrs span. Addresses which are not ip literals are not counted.
+func SubnetDiversity(addrs stringset.Set) int {
+	subnets := make(stringset.Set)
+	for addr := range addrs {
+		if k := subnetKey(addr); k != "" {
+			subnets.Add(k)
+		}
+	}
+	return len(subnets)
+}
+
+type diversityList struct {
+	list  List
+	stats tally.Scope
+}
+
+// WithSubnetDiversityStats wraps list such that every Resolve reports the
+// SubnetDiversity of the resolved addresses as a gauge. A sudden drop, e.g.
+// all hosts collapsing into a single subnet, usually signals a rack affinity
+// misconfiguration.
+func WithSubnetDiversityStats(list List, stats tally.Scope) List {
+	return &diversityList{list, stats.Tagged(map[string]string{
+		"module": "hostlist",
+	})}
+}
+
+func (l *diversityList) Resolve() stringset.Set {
+	addrs := l.list.Resolve()
+	l.stats.Gauge("subnet_diversity").Update(float64(SubnetDiversity(addrs)))
+	return addrs
+}
+
+// SampleDiverse randomly samples n addresses from addrs, such that the sample
+// spans at least minSubnets distinct subnets (see subnetKey) when possible.
+// If fewer subnets are available, the sample spans as many as it can. Addresses
+// which are not ip literals are treated as belonging to a single unknown subnet.
+func SampleDiverse(addrs stringset.Set, n, minSubnets int) stringset.Set {
+	if n >= len(addrs) {
+		return addrs.Copy()
+	}
+	groups := groupBySubnet(addrs)
+	var subnets []string
+	for k := range groups {
+		subnets = append(subnets, k)
+	}
+	rand.Shuffle(len(subnets), func(i, j int) { subnets[i], subnets[j] = subnets[j], subnets[i] })
+
+	result := make(stringset.Set, n)
+	for _, k := range subnets {
+		if len(result) == n || len(result) == minSubnets {
+			break
+		}
+		g := groups[k]
+		result.Add(g[rand.Intn(len(g))])
+	}
+	// Fill up the remainder of the sample regardless of subnet.
+	for addr := range addrs.Sub(result) {
+		if len(result) == n {
+			break
+		}
+		result.Add(addr)
+	}
+	return result
+}
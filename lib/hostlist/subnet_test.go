@@ -0,0 +1,91 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"testing"
+
+	"github.com/uber/kraken/utils/stringset"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+func TestSubnetKey(t *testing.T) {
+	tests := []struct {
+		addr     string
+		expected string
+	}{
+		{"10.0.1.5:80", "10.0.1.0/24"},
+		{"10.0.1.200", "10.0.1.0/24"},
+		{"[2001:db8:1:2:3::1]:80", "2001:db8:1:2::/64"},
+		{"some-host:80", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.addr, func(t *testing.T) {
+			require.Equal(t, test.expected, subnetKey(test.addr))
+		})
+	}
+}
+
+func TestSampleDiverse(t *testing.T) {
+	addrs := stringset.New(
+		"10.0.1.1:80", "10.0.1.2:80", "10.0.1.3:80", "10.0.1.4:80",
+		"10.0.2.1:80",
+		"10.0.3.1:80")
+
+	for i := 0; i < 50; i++ {
+		sample := SampleDiverse(addrs, 3, 3)
+		require.Len(t, sample, 3)
+		require.Len(t, groupBySubnet(sample), 3)
+	}
+}
+
+func TestSampleDiverseNotAchievable(t *testing.T) {
+	addrs := stringset.New("10.0.1.1:80", "10.0.1.2:80", "10.0.1.3:80", "10.0.2.1:80")
+
+	for i := 0; i < 50; i++ {
+		sample := SampleDiverse(addrs, 3, 3)
+		require.Len(t, sample, 3)
+		require.Len(t, groupBySubnet(sample), 2)
+	}
+}
+
+func TestSampleDiverseSmallSet(t *testing.T) {
+	addrs := stringset.New("10.0.1.1:80", "10.0.2.1:80")
+
+	require.Equal(t, addrs, SampleDiverse(addrs, 3, 2))
+}
+
+func TestSubnetDiversity(t *testing.T) {
+	addrs := stringset.New(
+		"10.0.1.1:80", "10.0.1.2:80", "10.0.2.1:80", "[2001:db8::1]:80", "some-host:80")
+	require.Equal(t, 3, SubnetDiversity(addrs))
+	require.Equal(t, 0, SubnetDiversity(stringset.New()))
+}
+
+func TestWithSubnetDiversityStats(t *testing.T) {
+	require := require.New(t)
+
+	stats := tally.NewTestScope("", nil)
+	l := WithSubnetDiversityStats(Fixture("10.0.1.1:80", "10.0.1.2:80", "10.0.2.1:80"), stats)
+	require.Len(l.Resolve(), 3)
+
+	gauges := stats.Snapshot().Gauges()
+	require.Len(gauges, 1)
+	for _, g := range gauges {
+		require.Equal("subnet_diversity", g.Name())
+		require.Equal(2.0, g.Value())
+	}
+}
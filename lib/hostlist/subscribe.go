@@ -0,0 +1,84 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import "github.com/uber/kraken/utils/stringset"
+
+// Subscriber is a List which pushes membership changes to its subscribers,
+// e.g. such that a hash ring can react to changes immediately instead of
+// polling Resolve.
+type Subscriber interface {
+	List
+
+	// Subscribe returns a channel which receives the current snapshot, and
+	// then every snapshot whose membership differs from the previous one.
+	// While the list is overridden, the override is delivered instead, like
+	// Resolve returns it.
+	// Slow subscribers never block refreshes: if a subscriber has not yet
+	// received the previous snapshot, it is replaced by the latest one.
+	// Call the returned func to unsubscribe, which closes the channel.
+	Subscribe() (<-chan stringset.Set, func())
+}
+
+// subscription tracks the last snapshot delivered to a subscriber, such that
+// only membership changes are delivered.
+type subscription struct {
+	c    chan stringset.Set
+	last stringset.Set
+}
+
+// deliver sends snapshot to s if its membership changed, replacing any
+// snapshot s has not received yet. Must be called with the list callbackMu
+// held, such that deliveries and closing the channel never race.
+func (s *subscription) deliver(snapshot stringset.Set) {
+	if s.last != nil && stringset.Equal(s.last, snapshot) {
+		return
+	}
+	s.last = snapshot
+	select {
+	case <-s.c:
+	default:
+	}
+	s.c <- snapshot.Copy()
+}
+
+func (l *list) Subscribe() (<-chan stringset.Set, func()) {
+	l.callbackMu.Lock()
+	defer l.callbackMu.Unlock()
+
+	l.mu.RLock()
+	snapshot := l.snapshot
+	if l.override != nil {
+		snapshot = l.override
+	}
+	l.mu.RUnlock()
+
+	s := &subscription{c: make(chan stringset.Set, 1)}
+	if l.subscriptions == nil {
+		l.subscriptions = make(map[*subscription]struct{})
+	}
+	l.subscriptions[s] = struct{}{}
+	s.deliver(snapshot)
+
+	unsubscribe := func() {
+		l.callbackMu.Lock()
+		defer l.callbackMu.Unlock()
+
+		if _, ok := l.subscriptions[s]; ok {
+			delete(l.subscriptions, s)
+			close(s.c)
+		}
+	}
+	return s.c, unsubscribe
+}
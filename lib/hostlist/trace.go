@@ -0,0 +1,56 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import "context"
+
+// Tracer starts spans around host resolution. It decouples hostlist from any
+// particular tracing library; implementations typically adapt an OpenTelemetry
+// or Jaeger tracer.
+type Tracer interface {
+	// StartSpan starts a span named operation as a child of whatever span ctx
+	// carries, and returns a context carrying the new span.
+	StartSpan(ctx context.Context, operation string) (context.Context, Span)
+}
+
+// Span is a single traced operation.
+type Span interface {
+	SetTag(key string, value interface{})
+
+	// Finish ends the span, recording err if non-nil.
+	Finish(err error)
+}
+
+type tracerKey struct{}
+
+// ContextWithTracer returns a copy of ctx which traces host resolution using t.
+func ContextWithTracer(ctx context.Context, t Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, t)
+}
+
+// startSpan starts a span using the Tracer carried by ctx. If ctx does not carry
+// a Tracer, then a no-op span is returned.
+func startSpan(ctx context.Context, operation string) (context.Context, Span) {
+	t, ok := ctx.Value(tracerKey{}).(Tracer)
+	if !ok {
+		return ctx, noopSpan{}
+	}
+	return t.StartSpan(ctx, operation)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetTag(key string, value interface{}) {}
+
+func (noopSpan) Finish(err error) {}
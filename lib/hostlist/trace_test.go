@@ -0,0 +1,71 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordedSpan struct {
+	operation string
+	tags      map[string]interface{}
+	finished  bool
+	err       error
+}
+
+func (s *recordedSpan) SetTag(key string, value interface{}) {
+	s.tags[key] = value
+}
+
+func (s *recordedSpan) Finish(err error) {
+	s.finished = true
+	s.err = err
+}
+
+type recordingTracer struct {
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) StartSpan(
+	ctx context.Context, operation string) (context.Context, Span) {
+
+	s := &recordedSpan{operation: operation, tags: make(map[string]interface{})}
+	t.spans = append(t.spans, s)
+	return ctx, s
+}
+
+func TestNewContextTracesResolution(t *testing.T) {
+	require := require.New(t)
+
+	tracer := &recordingTracer{}
+	ctx := ContextWithTracer(context.Background(), tracer)
+
+	_, err := NewContext(ctx, Config{Static: []string{"a:80", "b:80"}})
+	require.NoError(err)
+
+	require.Len(tracer.spans, 1)
+	s := tracer.spans[0]
+	require.Equal("hostlist.resolve", s.operation)
+	require.Equal(2, s.tags["hosts"])
+	require.True(s.finished)
+	require.NoError(s.err)
+}
+
+func TestNewWithoutTracer(t *testing.T) {
+	_, err := NewContext(context.Background(), Config{Static: []string{"a:80"}})
+	require.NoError(t, err)
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import "fmt"
+
+// View overrides the sources of a Config for one perspective of a
+// split-horizon deployment. For example, agents may resolve origins via an
+// "internal" view while a controller outside the network uses an "external"
+// view of the same hosts. Empty fields are inherited from the Config.
+type View struct {
+	DNS        string   `yaml:"dns"`
+	DNSRecords []string `yaml:"dns_records"`
+	Static     []string `yaml:"static"`
+}
+
+// ForView returns a copy of c whose sources are replaced by those of the named
+// view. The empty view returns c's own sources. Returns an error if the view
+// is not defined.
+//
+// When stripping local addresses, strip the list built from the active view
+// (e.g. StripLocal(NewView(config, "internal"))), such that the local
+// addresses are matched against the addresses of that view rather than those
+// of the others.
+func (c Config) ForView(name string) (Config, error) {
+	views := c.Views
+	c.Views = nil
+	if name == "" {
+		return c, nil
+	}
+	v, ok := views[name]
+	if !ok {
+		return Config{}, fmt.Errorf("no view defined with name %s", name)
+	}
+	if v.DNS != "" || len(v.DNSRecords) > 0 {
+		c.DNS = v.DNS
+		c.DNSRecords = v.DNSRecords
+	}
+	if len(v.Static) > 0 {
+		c.Static = v.Static
+	}
+	return c, nil
+}
+
+// NewView is like New, except the list resolves the sources of the named view.
+// See Config.ForView.
+func NewView(config Config, view string, opts ...Option) (DynamicList, error) {
+	config, err := config.ForView(view)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %s", err)
+	}
+	return New(config, opts...)
+}
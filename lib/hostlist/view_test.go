@@ -0,0 +1,70 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"testing"
+
+	"github.com/uber/kraken/utils/stringset"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewView(t *testing.T) {
+	config := Config{
+		Static: []string{"a:80"},
+		Views: map[string]View{
+			"internal": {Static: []string{"10.0.0.1:80", "10.0.0.2:80"}},
+			"external": {Static: []string{"203.0.113.1:80"}},
+		},
+	}
+	tests := []struct {
+		view     string
+		expected stringset.Set
+	}{
+		{"", stringset.New("a:80")},
+		{"internal", stringset.New("10.0.0.1:80", "10.0.0.2:80")},
+		{"external", stringset.New("203.0.113.1:80")},
+	}
+	for _, test := range tests {
+		t.Run(test.view, func(t *testing.T) {
+			l, err := NewView(config, test.view)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, l.Resolve())
+		})
+	}
+}
+
+func TestForViewInheritsUnsetSources(t *testing.T) {
+	require := require.New(t)
+
+	config := Config{
+		DNS:    "some-dns:80",
+		Static: []string{"a:80"},
+		Chain:  []string{SourceDNS, SourceStatic},
+		Views:  map[string]View{"external": {DNS: "some-external-dns:80"}},
+	}
+	result, err := config.ForView("external")
+	require.NoError(err)
+	require.Equal(Config{
+		DNS:    "some-external-dns:80",
+		Static: []string{"a:80"},
+		Chain:  []string{SourceDNS, SourceStatic},
+	}, result)
+}
+
+func TestForViewUnknownView(t *testing.T) {
+	_, err := Config{Static: []string{"a:80"}}.ForView("internal")
+	require.Error(t, err)
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"math"
+	"sort"
+
+	"github.com/spaolacci/murmur3"
+)
+
+// WeightedOrder returns the addresses of all hosts in a weighted shuffled
+// order, such that heavier hosts (see Host.Weight) tend to come first. Clients
+// can fail over down the list, e.g. trying each host in turn on failure.
+//
+// The shuffle is seeded by seed (e.g. the local hostname), so a node always
+// gets the same order for the same hosts, while different nodes spread their
+// load across different orders. Duplicate addresses only appear once, with the
+// weight of their first occurrence.
+func WeightedOrder(hosts []Host, seed string) []string {
+	type keyed struct {
+		addr string
+		key  float64
+	}
+	keys := make([]keyed, 0, len(hosts))
+	seen := make(map[string]bool, len(hosts))
+	for _, h := range hosts {
+		addr := h.Addr()
+		if seen[addr] {
+			// Duplicates would otherwise get several chances to come first.
+			continue
+		}
+		seen[addr] = true
+		// Weighted sampling without replacement (Efraimidis-Spirakis): sort by
+		// u^(1/w) for uniform u in (0, 1), here derived from the seed.
+		hash := murmur3.Sum64([]byte(seed + "/" + addr))
+		u := (float64(hash>>11) + 0.5) / (1 << 53)
+		keys = append(keys, keyed{addr, math.Pow(u, 1/float64(h.Weight()))})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].key != keys[j].key {
+			return keys[i].key > keys[j].key
+		}
+		return keys[i].addr < keys[j].addr
+	})
+	result := make([]string, len(keys))
+	for i, k := range keys {
+		result[i] = k.addr
+	}
+	return result
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeightedOrderIsDeterministicPerSeed(t *testing.T) {
+	require := require.New(t)
+
+	hosts := []Host{
+		{Name: "a", Port: 80},
+		{Name: "b", Port: 80},
+		{Name: "c", Port: 80},
+		{Name: "d", Port: 80},
+	}
+	order := WeightedOrder(hosts, "node-1")
+	require.ElementsMatch([]string{"a:80", "b:80", "c:80", "d:80"}, order)
+
+	reversed := []Host{hosts[3], hosts[2], hosts[1], hosts[0]}
+	require.Equal(order, WeightedOrder(reversed, "node-1"))
+}
+
+func TestWeightedOrderPrefersHeavierHosts(t *testing.T) {
+	hosts := []Host{
+		{Name: "light", Port: 80, Capacity: 1},
+		{Name: "heavy", Port: 80, Capacity: 1000},
+	}
+	var heavyFirst int
+	for i := 0; i < 100; i++ {
+		if WeightedOrder(hosts, fmt.Sprintf("node-%d", i))[0] == "heavy:80" {
+			heavyFirst++
+		}
+	}
+	require.True(t, heavyFirst > 90, "heavy first %d times", heavyFirst)
+}
+
+func TestWeightedOrderSpreadsAcrossSeeds(t *testing.T) {
+	hosts := []Host{{Name: "a", Port: 80}, {Name: "b", Port: 80}, {Name: "c", Port: 80}}
+	first := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		first[WeightedOrder(hosts, fmt.Sprintf("node-%d", i))[0]]++
+	}
+	require.Len(t, first, 3)
+	for addr, n := range first {
+		require.True(t, n > 50, "%s first %d times", addr, n)
+	}
+}
+
+func TestWeightedOrderDedupsHosts(t *testing.T) {
+	hosts := []Host{{Name: "a", Port: 80}, {Name: "b", Port: 80}, {Name: "a", Port: 80}}
+	require.ElementsMatch(t, []string{"a:80", "b:80"}, WeightedOrder(hosts, "seed"))
+}
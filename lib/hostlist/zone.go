@@ -0,0 +1,74 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// ZoneExtractor derives the zone (e.g. datacenter or availability zone) of a
+// host from its name, using a regular expression whose first capture group is
+// the zone. For example, `^[^.]+\.([a-z0-9-]+)\.` extracts "zone1" from
+// "origin-1.zone1.example.com".
+type ZoneExtractor struct {
+	re *regexp.Regexp
+}
+
+// NewZoneExtractor compiles pattern into a ZoneExtractor. pattern must contain
+// at least one capture group.
+func NewZoneExtractor(pattern string) (*ZoneExtractor, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("compile zone pattern: %s", err)
+	}
+	if re.NumSubexp() < 1 {
+		return nil, fmt.Errorf("zone pattern %q has no capture group", pattern)
+	}
+	return &ZoneExtractor{re}, nil
+}
+
+// Zone returns the zone of name, or the empty string if name does not match.
+func (e *ZoneExtractor) Zone(name string) string {
+	m := e.re.FindStringSubmatch(name)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// LocalZone returns the zone of the local machine, extracted from its
+// hostname.
+func (e *ZoneExtractor) LocalZone() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("hostname: %s", err)
+	}
+	return e.Zone(hostname), nil
+}
+
+// AttachZones sets the Zone of each host from its Name. Hosts which are ip
+// literals get an empty zone.
+func (e *ZoneExtractor) AttachZones(hosts []Host) []Host {
+	result := make([]Host, len(hosts))
+	for i, h := range hosts {
+		result[i] = h
+		result[i].Zone = ""
+		if !isIPLiteral(h.Name) {
+			result[i].Zone = e.Zone(h.Name)
+		}
+	}
+	return result
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hostlist
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestZoneExtractor(t *testing.T) {
+	require := require.New(t)
+
+	e, err := NewZoneExtractor(`^[^.]+\.([a-z0-9-]+)\.`)
+	require.NoError(err)
+
+	hosts := e.AttachZones([]Host{
+		{Name: "origin-1.zone1.example.com", Port: 80},
+		{Name: "origin-2.zone2.example.com", Port: 80},
+		{Name: "10.0.0.1", Port: 80},
+	})
+	var zones []string
+	for _, h := range hosts {
+		zones = append(zones, h.Zone)
+	}
+	require.Equal([]string{"zone1", "zone2", ""}, zones)
+}
+
+func TestNewZoneExtractorErrors(t *testing.T) {
+	for _, pattern := range []string{`(`, `^origin`} {
+		_, err := NewZoneExtractor(pattern)
+		require.Error(t, err, pattern)
+	}
+}
@@ -14,11 +14,17 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/uber/kraken/tracing"
+
 	"github.com/go-chi/chi"
 	"github.com/uber-go/tally"
 )
@@ -89,6 +95,13 @@ func (w *recordStatusWriter) Write(b []byte) (int, error) {
 	return w.ResponseWriter.Write(b)
 }
 
+// ReadFrom forwards to the wrapped writer, such that files copied into w are
+// still transferred via sendfile.
+func (w *recordStatusWriter) ReadFrom(r io.Reader) (int64, error) {
+	w.WriteHeader(http.StatusOK)
+	return io.Copy(w.ResponseWriter, r)
+}
+
 // StatusCounter measures endpoint status count.
 func StatusCounter(stats tally.Scope) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -99,3 +112,76 @@ func StatusCounter(stats tally.Scope) func(next http.Handler) http.Handler {
 		})
 	}
 }
+
+// Tracing traces requests with server spans, which continue the trace of the
+// caller if the request carries a traceparent header. Spans are named after
+// the matched route, e.g. "GET /namespace/{namespace}/blobs/{digest}".
+func Tracing() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := tracing.Start(
+				tracing.Extract(r.Context(), r.Header),
+				r.Method,
+				tracing.WithKind(tracing.KindServer),
+				tracing.WithAttribute("http.method", r.Method),
+				tracing.WithAttribute("http.target", r.URL.Path))
+			if span == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			recordw := &recordStatusWriter{w, false, http.StatusOK}
+			next.ServeHTTP(recordw, r.WithContext(ctx))
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				span.SetName(r.Method + " " + rctx.RoutePattern())
+			}
+			span.SetAttribute("http.status_code", recordw.code)
+			if recordw.code >= 500 {
+				span.SetError(fmt.Errorf("server error %d", recordw.code))
+			}
+			span.End()
+		})
+	}
+}
+
+// RequireToken rejects requests with 401 unless they carry token as bearer
+// token in their Authorization header.
+func RequireToken(token string) func(next http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			actual := []byte(r.Header.Get("Authorization"))
+			if subtle.ConstantTimeCompare(actual, expected) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "invalid or missing token", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireClientCert rejects requests with 403 unless they present a client
+// certificate verified by the server, or come from a loopback address. This
+// mirrors the client verification nginx applies with mutual TLS, for servers
+// which terminate TLS themselves.
+func RequireClientCert() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			verified := r.TLS != nil && len(r.TLS.VerifiedChains) > 0
+			if !verified && !isLoopback(r.RemoteAddr) {
+				http.Error(w, "verified client certificate required", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
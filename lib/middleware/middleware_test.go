@@ -14,12 +14,18 @@
 package middleware
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/uber/kraken/tracing"
 	"github.com/uber/kraken/utils/httputil"
 	"github.com/uber/kraken/utils/testutil"
 
@@ -115,6 +121,10 @@ func TestStatusCounter(t *testing.T) {
 			"writes count 200",
 			func(w http.ResponseWriter, _ *http.Request) { io.WriteString(w, "OK") },
 			"200",
+		}, {
+			"read from counts 200",
+			func(w http.ResponseWriter, _ *http.Request) { w.(io.ReaderFrom).ReadFrom(strings.NewReader("OK")) },
+			"200",
 		}, {
 			"write header",
 			func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(500) },
@@ -155,3 +165,115 @@ func TestStatusCounter(t *testing.T) {
 		})
 	}
 }
+
+func TestTracing(t *testing.T) {
+	require := require.New(t)
+
+	tracer, recorder := tracing.TracerFixture()
+	tracing.SetGlobal(tracer)
+	defer tracing.SetGlobal(nil)
+
+	r := chi.NewRouter()
+	r.Use(Tracing())
+	r.Get("/foo/{foo}", func(w http.ResponseWriter, r *http.Request) {
+		// Handlers continue the trace of the server span.
+		_, span := tracing.Start(r.Context(), "handler")
+		span.End()
+	})
+	r.Get("/error", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	addr, stop := testutil.StartServer(r)
+	defer stop()
+
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	_, err := httputil.Get(fmt.Sprintf("http://%s/foo/x", addr), httputil.SendContext(ctx))
+	require.NoError(err)
+	parent.End()
+
+	spans := recorder.Spans()
+	require.Len(spans, 4)
+	handler, server, client := spans[0], spans[1], spans[2]
+
+	require.Equal("handler", handler.Name())
+	require.Equal(server.Context().SpanID, handler.Parent())
+
+	require.Equal("GET /foo/{foo}", server.Name())
+	require.Equal(client.Context().SpanID, server.Parent())
+	require.Equal(parent.Context().TraceID, server.Context().TraceID)
+	require.Equal("/foo/x", server.Attribute("http.target"))
+	require.Equal("200", server.Attribute("http.status_code"))
+	require.NoError(server.Err())
+
+	require.Equal("GET", client.Name())
+	require.Equal(parent.Context().SpanID, client.Parent())
+	require.Equal("200", client.Attribute("http.status_code"))
+
+	// Requests without a traceparent start new traces, and 5XX are errors.
+	_, err = httputil.Get(fmt.Sprintf("http://%s/error", addr))
+	require.Error(err)
+
+	spans = recorder.Spans()
+	require.Len(spans, 5)
+	server = spans[4]
+	require.Equal("GET /error", server.Name())
+	require.Equal(tracing.SpanID{}, server.Parent())
+	require.Equal("500", server.Attribute("http.status_code"))
+	require.Error(server.Err())
+}
+
+func TestRequireToken(t *testing.T) {
+	tests := []struct {
+		desc           string
+		headers        map[string]string
+		expectedStatus int
+	}{
+		{"valid token", map[string]string{"Authorization": "Bearer secret"}, http.StatusOK},
+		{"missing token", nil, http.StatusUnauthorized},
+		{"wrong token", map[string]string{"Authorization": "Bearer foo"}, http.StatusUnauthorized},
+		{"wrong scheme", map[string]string{"Authorization": "Basic secret"}, http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			r := chi.NewRouter()
+			r.Use(RequireToken("secret"))
+			r.Get("/foo", func(http.ResponseWriter, *http.Request) {})
+			addr, stop := testutil.StartServer(r)
+			defer stop()
+
+			_, err := httputil.Get(
+				fmt.Sprintf("http://%s/foo", addr),
+				httputil.SendHeaders(test.headers),
+				httputil.SendAcceptedCodes(test.expectedStatus))
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestRequireClientCert(t *testing.T) {
+	tests := []struct {
+		desc           string
+		remoteAddr     string
+		tls            *tls.ConnectionState
+		expectedStatus int
+	}{
+		{"loopback", "127.0.0.1:5000", &tls.ConnectionState{}, http.StatusOK},
+		{"loopback ipv6", "[::1]:5000", &tls.ConnectionState{}, http.StatusOK},
+		{"verified", "10.0.0.1:5000", &tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{{}}},
+		}, http.StatusOK},
+		{"unverified", "10.0.0.1:5000", &tls.ConnectionState{}, http.StatusForbidden},
+		{"plain http", "10.0.0.1:5000", nil, http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			h := RequireClientCert()(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+			r := httptest.NewRequest("GET", "/foo", nil)
+			r.RemoteAddr = test.remoteAddr
+			r.TLS = test.tls
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			require.Equal(t, test.expectedStatus, w.Code)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package originfetch
+
+import (
+	"github.com/c2h5oh/datasize"
+)
+
+// Config defines Fetcher configuration.
+type Config struct {
+	// Enabled turns on fetching cold blobs from origins.
+	Enabled bool `yaml:"enabled"`
+
+	// ChunkSize is the size of the ranges blobs are split into.
+	ChunkSize datasize.ByteSize `yaml:"chunk_size"`
+
+	// MaxSources is the maximum number of origins ranges are fetched from in
+	// parallel, one range per origin at a time.
+	MaxSources int `yaml:"max_sources"`
+
+	// MinSize is the size below which blobs are left to the scheduler, since
+	// splitting them gains little.
+	MinSize datasize.ByteSize `yaml:"min_size"`
+}
+
+func (c Config) applyDefaults() Config {
+	if c.ChunkSize == 0 {
+		c.ChunkSize = 16 * datasize.MB
+	}
+	if c.MaxSources == 0 {
+		c.MaxSources = 3
+	}
+	if c.MinSize == 0 {
+		c.MinSize = 2 * c.ChunkSize
+	}
+	return c
+}
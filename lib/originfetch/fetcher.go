@@ -0,0 +1,266 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package originfetch
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/store/metadata"
+	"github.com/uber/kraken/origin/blobclient"
+	"github.com/uber/kraken/tracker/announceclient"
+	"github.com/uber/kraken/tracker/metainfoclient"
+	"github.com/uber/kraken/utils/errutil"
+	"github.com/uber/kraken/utils/httputil"
+	"github.com/uber/kraken/utils/log"
+
+	"github.com/uber-go/tally"
+)
+
+// errSkipped is returned when a blob is left to the scheduler.
+var errSkipped = errors.New("skipped")
+
+// Fetcher downloads cold blobs, i.e. blobs which no other agent has yet,
+// directly from the origins owning them. Blobs are split into ranges which are
+// fetched from several origins in parallel, instead of being served by a
+// single origin as the first peer of the torrent.
+type Fetcher struct {
+	config   Config
+	stats    tally.Scope
+	cads     *store.CADownloadStore
+	metaInfo metainfoclient.Client
+	announce announceclient.Client
+	origins  blobclient.ClientResolver
+}
+
+// New creates a new Fetcher.
+func New(
+	config Config,
+	stats tally.Scope,
+	cads *store.CADownloadStore,
+	metaInfo metainfoclient.Client,
+	announce announceclient.Client,
+	origins blobclient.ClientResolver) *Fetcher {
+
+	stats = stats.Tagged(map[string]string{
+		"module": "originfetch",
+	})
+	return &Fetcher{config.applyDefaults(), stats, cads, metaInfo, announce, origins}
+}
+
+// Fetch downloads the blob of d into the cache if it is cold. Returns
+// errSkipped if the blob is left to the scheduler, either because it is
+// already cached or downloading, it is small, other agents have it, or the
+// origins have not cached it yet.
+func (f *Fetcher) Fetch(namespace string, d core.Digest) error {
+	if _, err := f.cads.Cache().GetFileStat(d.Hex()); err == nil {
+		return errSkipped
+	}
+	mi, err := f.metaInfo.Download(namespace, d)
+	if err != nil {
+		if err == metainfoclient.ErrNotFound {
+			return errSkipped
+		}
+		return fmt.Errorf("download metainfo: %s", err)
+	}
+	if mi.Length() < int64(f.config.MinSize) {
+		return errSkipped
+	}
+	// Probes rather than announces, since this agent must not be handed out
+	// to other agents before the scheduler has taken over the torrent.
+	peers, err := f.announce.Probe(d, mi.InfoHash())
+	if err != nil {
+		return fmt.Errorf("probe peers: %s", err)
+	}
+	for _, p := range peers {
+		if !p.Origin {
+			return errSkipped
+		}
+	}
+	clients, err := f.origins.Resolve(d)
+	if err != nil {
+		return fmt.Errorf("resolve origins: %s", err)
+	}
+	if len(clients) > f.config.MaxSources {
+		clients = clients[:f.config.MaxSources]
+	}
+	if err := f.cads.CreateDownloadFile(d.Hex(), mi.Length()); err != nil {
+		if f.cads.InDownloadError(err) || f.cads.InCacheError(err) {
+			return errSkipped
+		}
+		return fmt.Errorf("create download file: %s", err)
+	}
+	if err := f.download(namespace, mi, clients); err != nil {
+		if err := f.cads.Download().DeleteFile(d.Hex()); err != nil {
+			log.With("digest", d).Errorf("Error deleting failed origin fetch: %s", err)
+		}
+		return err
+	}
+	return nil
+}
+
+type chunk struct {
+	offset int64
+	length int64
+}
+
+func (f *Fetcher) chunks(size int64) []chunk {
+	var chunks []chunk
+	for offset := int64(0); offset < size; offset += int64(f.config.ChunkSize) {
+		length := int64(f.config.ChunkSize)
+		if offset+length > size {
+			length = size - offset
+		}
+		chunks = append(chunks, chunk{offset, length})
+	}
+	return chunks
+}
+
+// download fetches the ranges of mi into its download file, with one worker
+// per client, verifies the file and moves it to the cache.
+func (f *Fetcher) download(
+	namespace string, mi *core.MetaInfo, clients []blobclient.Client) error {
+
+	d := mi.Digest()
+	timer := f.stats.Timer("download_time").Start()
+	defer timer.Stop()
+
+	all := f.chunks(mi.Length())
+	chunks := make(chan chunk, len(all))
+	for _, c := range all {
+		chunks <- c
+	}
+	close(chunks)
+
+	done := make(chan struct{})
+	var once sync.Once
+	var mu sync.Mutex
+	var errs []error
+	var skipped bool
+	var wg sync.WaitGroup
+	for i := range clients {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for c := range chunks {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if err := f.fetchChunk(namespace, d, c, clients, i); err != nil {
+					mu.Lock()
+					if err == errSkipped {
+						skipped = true
+					} else {
+						errs = append(errs, err)
+					}
+					mu.Unlock()
+					once.Do(func() { close(done) })
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if skipped {
+		// The origins are still pulling the blob from the backend, which the
+		// scheduler waits for as usual.
+		f.stats.Counter("not_cached").Inc(1)
+		return errSkipped
+	}
+	if err := errutil.Join(errs); err != nil {
+		f.stats.Counter("download_failures").Inc(1)
+		return err
+	}
+
+	r, err := f.cads.Download().GetFileReader(d.Hex())
+	if err != nil {
+		return fmt.Errorf("get download file: %s", err)
+	}
+	actual, err := core.NewDigester().FromReader(r)
+	r.Close()
+	if err != nil {
+		return fmt.Errorf("compute digest: %s", err)
+	}
+	if actual != d {
+		f.stats.Counter("digest_mismatches").Inc(1)
+		return fmt.Errorf("digest mismatch: got %s", actual)
+	}
+
+	// Record the namespace and metainfo like the scheduler does, such that the
+	// blob is accounted for by quotas and can be seeded.
+	if _, err := f.cads.Download().SetMetadata(d.Hex(), metadata.NewNamespace(namespace)); err != nil {
+		return fmt.Errorf("set namespace: %s", err)
+	}
+	if _, err := f.cads.Download().SetMetadata(d.Hex(), metadata.NewTorrentMeta(mi)); err != nil {
+		return fmt.Errorf("set metainfo: %s", err)
+	}
+	if err := f.cads.MoveDownloadFileToCache(d.Hex()); err != nil {
+		return fmt.Errorf("move to cache: %s", err)
+	}
+	f.stats.Counter("downloads").Inc(1)
+	f.stats.Counter("download_bytes").Inc(mi.Length())
+	return nil
+}
+
+// fetchChunk fetches c from clients, starting with the i-th client and
+// trying the others in order if it fails. Returns errSkipped if no client has
+// the blob cached yet.
+func (f *Fetcher) fetchChunk(
+	namespace string, d core.Digest, c chunk, clients []blobclient.Client, i int) error {
+
+	w, err := f.cads.GetDownloadFileReadWriter(d.Hex())
+	if err != nil {
+		return fmt.Errorf("get download file: %s", err)
+	}
+	defer w.Close()
+
+	var errs []error
+	var accepted int
+	for j := 0; j < len(clients); j++ {
+		client := clients[(i+j)%len(clients)]
+		dst := &offsetWriter{w, c.offset}
+		err := client.DownloadBlobRange(namespace, d, c.offset, c.length, dst)
+		if err == nil {
+			return nil
+		}
+		if httputil.IsAccepted(err) {
+			accepted++
+		} else {
+			f.stats.Counter("range_failures").Inc(1)
+		}
+		errs = append(errs, fmt.Errorf("origin %s: %s", client.Addr(), err))
+	}
+	if accepted == len(clients) {
+		return errSkipped
+	}
+	return fmt.Errorf("fetch range %d-%d: %s", c.offset, c.offset+c.length-1, errutil.Join(errs))
+}
+
+// offsetWriter writes sequentially to w starting at off.
+type offsetWriter struct {
+	w   io.WriterAt
+	off int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.w.WriteAt(p, w.off)
+	w.off += int64(n)
+	return n, err
+}
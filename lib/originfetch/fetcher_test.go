@@ -0,0 +1,257 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package originfetch
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/store/metadata"
+	mockblobclient "github.com/uber/kraken/mocks/origin/blobclient"
+	mockannounceclient "github.com/uber/kraken/mocks/tracker/announceclient"
+	"github.com/uber/kraken/origin/blobclient"
+	"github.com/uber/kraken/tracker/metainfoclient"
+	"github.com/uber/kraken/utils/httputil"
+	"github.com/uber/kraken/utils/testutil"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+const _namespace = "some/namespace"
+
+type fetcherMocks struct {
+	ctrl     *gomock.Controller
+	cads     *store.CADownloadStore
+	metaInfo *metainfoclient.TestClient
+	announce *mockannounceclient.MockClient
+	resolver *mockblobclient.MockClientResolver
+}
+
+func newFetcherMocks(t *testing.T) (*fetcherMocks, func()) {
+	var cleanup testutil.Cleanup
+
+	ctrl := gomock.NewController(t)
+	cleanup.Add(ctrl.Finish)
+
+	cads, c := store.CADownloadStoreFixture()
+	cleanup.Add(c)
+
+	return &fetcherMocks{
+		ctrl:     ctrl,
+		cads:     cads,
+		metaInfo: metainfoclient.NewTestClient(),
+		announce: mockannounceclient.NewMockClient(ctrl),
+		resolver: mockblobclient.NewMockClientResolver(ctrl),
+	}, cleanup.Run
+}
+
+func (m *fetcherMocks) new() *Fetcher {
+	config := Config{
+		ChunkSize: 10 * datasize.B,
+		MinSize:   1,
+	}
+	return New(config, tally.NoopScope, m.cads, m.metaInfo, m.announce, m.resolver)
+}
+
+// origin returns a mock origin client which serves ranges of content, or err.
+func (m *fetcherMocks) origin(addr string, content []byte, err error) *mockblobclient.MockClient {
+	c := mockblobclient.NewMockClient(m.ctrl)
+	c.EXPECT().Addr().Return(addr).AnyTimes()
+	c.EXPECT().DownloadBlobRange(_namespace, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(namespace string, d core.Digest, offset, length int64, dst io.Writer) error {
+			if err != nil {
+				return err
+			}
+			_, werr := dst.Write(content[offset : offset+length])
+			return werr
+		}).AnyTimes()
+	return c
+}
+
+func readCache(t *testing.T, cads *store.CADownloadStore, d core.Digest) []byte {
+	r, err := cads.Cache().GetFileReader(d.Hex())
+	require.NoError(t, err)
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	return b
+}
+
+func TestFetchSplitsRangesAcrossOrigins(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newFetcherMocks(t)
+	defer cleanup()
+
+	blob := core.SizedBlobFixture(95, 10)
+	require.NoError(mocks.metaInfo.Upload(blob.MetaInfo))
+
+	mocks.announce.EXPECT().Probe(blob.Digest, blob.MetaInfo.InfoHash()).Return(
+		[]*core.PeerInfo{core.OriginPeerInfoFixture()}, nil)
+	mocks.resolver.EXPECT().Resolve(blob.Digest).Return([]blobclient.Client{
+		mocks.origin("o1", blob.Content, nil),
+		mocks.origin("o2", blob.Content, nil),
+	}, nil)
+
+	require.NoError(mocks.new().Fetch(_namespace, blob.Digest))
+	require.Equal(blob.Content, readCache(t, mocks.cads, blob.Digest))
+
+	var tm metadata.TorrentMeta
+	require.NoError(mocks.cads.Cache().GetMetadata(blob.Digest.Hex(), &tm))
+	require.Equal(blob.MetaInfo, tm.MetaInfo)
+	var ns metadata.Namespace
+	require.NoError(mocks.cads.Cache().GetMetadata(blob.Digest.Hex(), &ns))
+	require.Equal(_namespace, ns.Value)
+}
+
+func TestFetchRetriesRangesOnOtherOrigins(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newFetcherMocks(t)
+	defer cleanup()
+
+	blob := core.SizedBlobFixture(95, 10)
+	require.NoError(mocks.metaInfo.Upload(blob.MetaInfo))
+
+	mocks.announce.EXPECT().Probe(blob.Digest, blob.MetaInfo.InfoHash()).Return(nil, nil)
+	mocks.resolver.EXPECT().Resolve(blob.Digest).Return([]blobclient.Client{
+		mocks.origin("o1", nil, errors.New("some error")),
+		mocks.origin("o2", blob.Content, nil),
+	}, nil)
+
+	require.NoError(mocks.new().Fetch(_namespace, blob.Digest))
+	require.Equal(blob.Content, readCache(t, mocks.cads, blob.Digest))
+}
+
+func TestFetchFailsIfAllOriginsFail(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newFetcherMocks(t)
+	defer cleanup()
+
+	blob := core.SizedBlobFixture(95, 10)
+	require.NoError(mocks.metaInfo.Upload(blob.MetaInfo))
+
+	mocks.announce.EXPECT().Probe(blob.Digest, blob.MetaInfo.InfoHash()).Return(nil, nil)
+	mocks.resolver.EXPECT().Resolve(blob.Digest).Return([]blobclient.Client{
+		mocks.origin("o1", nil, errors.New("some error")),
+	}, nil)
+
+	err := mocks.new().Fetch(_namespace, blob.Digest)
+	require.Error(err)
+	require.NotEqual(errSkipped, err)
+
+	// The partial download is removed, such that the scheduler starts over.
+	_, err = mocks.cads.Any().GetFileStat(blob.Digest.Hex())
+	require.True(os.IsNotExist(err))
+}
+
+func TestFetchSkipsBlobsNotCachedOnOrigins(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newFetcherMocks(t)
+	defer cleanup()
+
+	blob := core.SizedBlobFixture(95, 10)
+	require.NoError(mocks.metaInfo.Upload(blob.MetaInfo))
+
+	mocks.announce.EXPECT().Probe(blob.Digest, blob.MetaInfo.InfoHash()).Return(nil, nil)
+	mocks.resolver.EXPECT().Resolve(blob.Digest).Return([]blobclient.Client{
+		mocks.origin("o1", nil, httputil.StatusError{Status: http.StatusAccepted}),
+		mocks.origin("o2", nil, httputil.StatusError{Status: http.StatusAccepted}),
+	}, nil)
+
+	require.Equal(errSkipped, mocks.new().Fetch(_namespace, blob.Digest))
+
+	_, err := mocks.cads.Any().GetFileStat(blob.Digest.Hex())
+	require.True(os.IsNotExist(err))
+}
+
+func TestFetchDigestMismatch(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newFetcherMocks(t)
+	defer cleanup()
+
+	blob := core.SizedBlobFixture(95, 10)
+	require.NoError(mocks.metaInfo.Upload(blob.MetaInfo))
+
+	mocks.announce.EXPECT().Probe(blob.Digest, blob.MetaInfo.InfoHash()).Return(nil, nil)
+	mocks.resolver.EXPECT().Resolve(blob.Digest).Return([]blobclient.Client{
+		mocks.origin("o1", make([]byte, 95), nil),
+	}, nil)
+
+	err := mocks.new().Fetch(_namespace, blob.Digest)
+	require.Error(err)
+	require.Contains(err.Error(), "digest mismatch")
+
+	_, err = mocks.cads.Any().GetFileStat(blob.Digest.Hex())
+	require.True(os.IsNotExist(err))
+}
+
+func TestFetchSkipped(t *testing.T) {
+	blob := core.SizedBlobFixture(95, 10)
+
+	tests := []struct {
+		desc  string
+		setup func(*fetcherMocks)
+	}{
+		{
+			"agent peers exist",
+			func(mocks *fetcherMocks) {
+				require.NoError(t, mocks.metaInfo.Upload(blob.MetaInfo))
+				mocks.announce.EXPECT().Probe(blob.Digest, blob.MetaInfo.InfoHash()).Return(
+					[]*core.PeerInfo{core.OriginPeerInfoFixture(), core.PeerInfoFixture()}, nil)
+			},
+		}, {
+			"already cached",
+			func(mocks *fetcherMocks) {
+				require.NoError(t, store.RunDownload(mocks.cads, blob.Digest, blob.Content))
+			},
+		}, {
+			"metainfo not found",
+			func(mocks *fetcherMocks) {},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			mocks, cleanup := newFetcherMocks(t)
+			defer cleanup()
+
+			test.setup(mocks)
+
+			require.Equal(t, errSkipped, mocks.new().Fetch(_namespace, blob.Digest))
+		})
+	}
+}
+
+func TestFetchSkipsSmallBlobs(t *testing.T) {
+	mocks, cleanup := newFetcherMocks(t)
+	defer cleanup()
+
+	blob := core.SizedBlobFixture(95, 10)
+	require.NoError(t, mocks.metaInfo.Upload(blob.MetaInfo))
+
+	f := New(Config{MinSize: datasize.KB}, tally.NoopScope, mocks.cads, mocks.metaInfo, mocks.announce, mocks.resolver)
+	require.Equal(t, errSkipped, f.Fetch(_namespace, blob.Digest))
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package originfetch
+
+import (
+	"sync"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/torrent/scheduler"
+	"github.com/uber/kraken/utils/log"
+)
+
+type fetchingScheduler struct {
+	scheduler.ReloadableScheduler
+	fetcher *Fetcher
+
+	mu       sync.Mutex // Protects inflight.
+	inflight map[core.Digest]chan struct{}
+}
+
+// WrapScheduler returns a scheduler which fetches cold blobs from origins with
+// f before handing them to s, which then seeds them. Blobs which are skipped by
+// f, or fail to be fetched, are downloaded by s as usual.
+func WrapScheduler(s scheduler.ReloadableScheduler, f *Fetcher) scheduler.ReloadableScheduler {
+	return &fetchingScheduler{
+		ReloadableScheduler: s,
+		fetcher:             f,
+		inflight:            make(map[core.Digest]chan struct{}),
+	}
+}
+
+func (s *fetchingScheduler) Download(namespace string, d core.Digest) error {
+	return s.DownloadWithPriority(namespace, d, scheduler.PriorityNormal)
+}
+
+func (s *fetchingScheduler) DownloadWithPriority(
+	namespace string, d core.Digest, p scheduler.Priority) error {
+
+	if err := s.fetch(namespace, d); err != nil && err != errSkipped {
+		s.fetcher.stats.Counter("fallbacks").Inc(1)
+		log.With("namespace", namespace, "digest", d).Errorf(
+			"Error fetching blob from origins, falling back to p2p: %s", err)
+	}
+	return s.ReloadableScheduler.DownloadWithPriority(namespace, d, p)
+}
+
+// fetch fetches d with the fetcher. If d is already being fetched, it instead
+// waits until that fetch has either moved the blob to the cache or deleted its
+// download file, such that the scheduler never opens a torrent on a file the
+// fetcher is still writing.
+func (s *fetchingScheduler) fetch(namespace string, d core.Digest) error {
+	s.mu.Lock()
+	if done, ok := s.inflight[d]; ok {
+		s.mu.Unlock()
+		<-done
+		return errSkipped
+	}
+	done := make(chan struct{})
+	s.inflight[d] = done
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.inflight, d)
+		s.mu.Unlock()
+		close(done)
+	}()
+	return s.fetcher.Fetch(namespace, d)
+}
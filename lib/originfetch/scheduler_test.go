@@ -0,0 +1,87 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package originfetch
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/torrent/scheduler"
+	mockscheduler "github.com/uber/kraken/mocks/lib/torrent/scheduler"
+	mockblobclient "github.com/uber/kraken/mocks/origin/blobclient"
+	"github.com/uber/kraken/origin/blobclient"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedulerWaitsForInflightFetch(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newFetcherMocks(t)
+	defer cleanup()
+
+	blob := core.SizedBlobFixture(95, 10)
+	require.NoError(mocks.metaInfo.Upload(blob.MetaInfo))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	origin := mockblobclient.NewMockClient(mocks.ctrl)
+	origin.EXPECT().Addr().Return("o1").AnyTimes()
+	origin.EXPECT().DownloadBlobRange(_namespace, blob.Digest, gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(namespace string, d core.Digest, offset, length int64, dst io.Writer) error {
+			once.Do(func() {
+				close(started)
+				<-release
+			})
+			_, err := dst.Write(blob.Content[offset : offset+length])
+			return err
+		}).AnyTimes()
+
+	// Only the first download fetches from origins.
+	mocks.announce.EXPECT().Probe(blob.Digest, blob.MetaInfo.InfoHash()).Return(nil, nil)
+	mocks.resolver.EXPECT().Resolve(blob.Digest).Return([]blobclient.Client{origin}, nil)
+
+	// Both downloads only reach the scheduler once the blob is cached.
+	sched := mockscheduler.NewMockReloadableScheduler(mocks.ctrl)
+	sched.EXPECT().DownloadWithPriority(_namespace, blob.Digest, scheduler.PriorityNormal).DoAndReturn(
+		func(namespace string, d core.Digest, p scheduler.Priority) error {
+			require.Equal(blob.Content, readCache(t, mocks.cads, d))
+			return nil
+		}).Times(2)
+
+	s := WrapScheduler(sched, mocks.new())
+
+	errc := make(chan error, 2)
+	go func() { errc <- s.Download(_namespace, blob.Digest) }()
+	<-started
+	go func() { errc <- s.Download(_namespace, blob.Digest) }()
+
+	// Gives the second download time to reach the scheduler, if it would.
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errc:
+			require.NoError(err)
+		case <-time.After(5 * time.Second):
+			require.FailNow("download timed out")
+		}
+	}
+}
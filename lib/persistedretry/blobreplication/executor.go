@@ -0,0 +1,137 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package blobreplication
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/uber/kraken/lib/persistedretry"
+	"github.com/uber/kraken/origin/blobclient"
+
+	"github.com/uber-go/tally"
+)
+
+// Executor executes blob replication tasks.
+type Executor struct {
+	stats           tally.Scope
+	originCluster   blobclient.ClusterClient
+	clusterProvider blobclient.ClusterProvider
+}
+
+// NewExecutor creates a new Executor, where originCluster is the local origin
+// cluster and clusterProvider provides clients for remote origin clusters.
+func NewExecutor(
+	stats tally.Scope,
+	originCluster blobclient.ClusterClient,
+	clusterProvider blobclient.ClusterProvider) *Executor {
+
+	stats = stats.Tagged(map[string]string{
+		"module": "blobreplicationexecutor",
+	})
+
+	return &Executor{stats, originCluster, clusterProvider}
+}
+
+// Name returns the executor name.
+func (e *Executor) Name() string {
+	return "blobreplication"
+}
+
+// Exec replicates the task's blob between the local and remote origin
+// clusters, in the direction given by the task's policy.
+func (e *Executor) Exec(r persistedretry.Task) error {
+	t := r.(*Task)
+	start := time.Now()
+	remote, err := e.clusterProvider.Provide(t.Remote)
+	if err != nil {
+		return fmt.Errorf("provide remote cluster: %s", err)
+	}
+	var replicated bool
+	switch t.Policy {
+	case PolicyPush:
+		replicated, err = e.push(t, remote)
+	case PolicyPull:
+		replicated, err = e.pull(t, remote)
+	default:
+		return fmt.Errorf("unknown policy %q", t.Policy)
+	}
+	if err != nil {
+		return err
+	}
+
+	stats := e.stats.Tagged(map[string]string{
+		"remote": t.Remote,
+		"policy": t.Policy,
+	})
+	if !replicated {
+		stats.Counter("noops").Inc(1)
+		return nil
+	}
+	// We don't want to time noops nor errors.
+	stats.Counter("replicated").Inc(1)
+	stats.Timer("replicate").Record(time.Since(start))
+	stats.Timer("lifetime").Record(time.Since(t.CreatedAt))
+
+	return nil
+}
+
+// push replicates the blob from the local cluster to remote. Returns false if
+// remote already has the blob.
+func (e *Executor) push(t *Task, remote blobclient.ClusterClient) (bool, error) {
+	if _, err := remote.Stat(t.Namespace, t.Digest); err == nil {
+		return false, nil
+	} else if err != blobclient.ErrBlobNotFound {
+		return false, fmt.Errorf("stat remote: %s", err)
+	}
+	if err := e.originCluster.ReplicateToRemote(t.Namespace, t.Digest, t.Remote); err != nil {
+		return false, fmt.Errorf("origin cluster replicate: %s", err)
+	}
+	return true, nil
+}
+
+// pull replicates the blob from remote to the local cluster, which writes it
+// back to the local storage backends. Returns false if the local cluster
+// already has the blob, or if remote no longer has it.
+func (e *Executor) pull(t *Task, remote blobclient.ClusterClient) (bool, error) {
+	if _, err := e.originCluster.Stat(t.Namespace, t.Digest); err == nil {
+		return false, nil
+	} else if err != blobclient.ErrBlobNotFound {
+		return false, fmt.Errorf("stat origin cluster: %s", err)
+	}
+	f, err := ioutil.TempFile("", "blobreplication")
+	if err != nil {
+		return false, fmt.Errorf("create tmp file: %s", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := remote.DownloadBlob(t.Namespace, t.Digest, f); err != nil {
+		if err == blobclient.ErrBlobNotFound {
+			// Nothing to pull. Retrying won't help.
+			return false, nil
+		}
+		return false, fmt.Errorf("download from remote: %s", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return false, fmt.Errorf("seek: %s", err)
+	}
+	if err := e.originCluster.UploadBlob(t.Namespace, t.Digest, f); err != nil {
+		return false, fmt.Errorf("origin cluster upload: %s", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package blobreplication
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/mocks/origin/blobclient"
+	"github.com/uber/kraken/origin/blobclient"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+type executorMocks struct {
+	ctrl            *gomock.Controller
+	originCluster   *mockblobclient.MockClusterClient
+	remoteCluster   *mockblobclient.MockClusterClient
+	clusterProvider *mockblobclient.MockClusterProvider
+}
+
+func newExecutorMocks(t *testing.T) (*executorMocks, func()) {
+	ctrl := gomock.NewController(t)
+	return &executorMocks{
+		ctrl:            ctrl,
+		originCluster:   mockblobclient.NewMockClusterClient(ctrl),
+		remoteCluster:   mockblobclient.NewMockClusterClient(ctrl),
+		clusterProvider: mockblobclient.NewMockClusterProvider(ctrl),
+	}, ctrl.Finish
+}
+
+func (m *executorMocks) new() *Executor {
+	return NewExecutor(tally.NoopScope, m.originCluster, m.clusterProvider)
+}
+
+func TestExecutorPush(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newExecutorMocks(t)
+	defer cleanup()
+
+	task := TaskFixture()
+
+	gomock.InOrder(
+		mocks.clusterProvider.EXPECT().Provide(task.Remote).Return(mocks.remoteCluster, nil),
+		mocks.remoteCluster.EXPECT().Stat(task.Namespace, task.Digest).Return(nil, blobclient.ErrBlobNotFound),
+		mocks.originCluster.EXPECT().ReplicateToRemote(task.Namespace, task.Digest, task.Remote).Return(nil),
+	)
+
+	require.NoError(mocks.new().Exec(task))
+}
+
+func TestExecutorPushNoopsWhenRemoteHasBlob(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newExecutorMocks(t)
+	defer cleanup()
+
+	task := TaskFixture()
+
+	gomock.InOrder(
+		mocks.clusterProvider.EXPECT().Provide(task.Remote).Return(mocks.remoteCluster, nil),
+		mocks.remoteCluster.EXPECT().Stat(task.Namespace, task.Digest).Return(core.NewBlobInfo(1), nil),
+	)
+
+	require.NoError(mocks.new().Exec(task))
+}
+
+func TestExecutorPull(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newExecutorMocks(t)
+	defer cleanup()
+
+	blob := core.SizedBlobFixture(64, 8)
+	task := NewTask(core.NamespaceFixture(), blob.Digest, "some-remote", PolicyPull, 0)
+
+	gomock.InOrder(
+		mocks.clusterProvider.EXPECT().Provide(task.Remote).Return(mocks.remoteCluster, nil),
+		mocks.originCluster.EXPECT().Stat(task.Namespace, task.Digest).Return(nil, blobclient.ErrBlobNotFound),
+		mocks.remoteCluster.EXPECT().DownloadBlob(task.Namespace, task.Digest, gomock.Any()).DoAndReturn(
+			func(namespace string, d core.Digest, dst io.Writer) error {
+				_, err := io.Copy(dst, bytes.NewReader(blob.Content))
+				return err
+			}),
+		mocks.originCluster.EXPECT().UploadBlob(task.Namespace, task.Digest, gomock.Any()).DoAndReturn(
+			func(namespace string, d core.Digest, src io.Reader) error {
+				b, err := ioutil.ReadAll(src)
+				require.NoError(err)
+				require.Equal(blob.Content, b)
+				return nil
+			}),
+	)
+
+	require.NoError(mocks.new().Exec(task))
+}
+
+func TestExecutorPullNoopsWhenRemoteMissingBlob(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newExecutorMocks(t)
+	defer cleanup()
+
+	task := NewTask(core.NamespaceFixture(), core.DigestFixture(), "some-remote", PolicyPull, 0)
+
+	gomock.InOrder(
+		mocks.clusterProvider.EXPECT().Provide(task.Remote).Return(mocks.remoteCluster, nil),
+		mocks.originCluster.EXPECT().Stat(task.Namespace, task.Digest).Return(nil, blobclient.ErrBlobNotFound),
+		mocks.remoteCluster.EXPECT().DownloadBlob(
+			task.Namespace, task.Digest, gomock.Any()).Return(blobclient.ErrBlobNotFound),
+	)
+
+	require.NoError(mocks.new().Exec(task))
+}
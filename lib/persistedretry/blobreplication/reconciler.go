@@ -0,0 +1,203 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package blobreplication
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/persistedretry"
+	"github.com/uber/kraken/lib/store/metadata"
+	"github.com/uber/kraken/origin/blobclient"
+	"github.com/uber/kraken/utils/log"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/uber-go/tally"
+)
+
+// ReconcilerConfig defines configuration for periodically detecting blobs
+// which are missing from push remotes.
+type ReconcilerConfig struct {
+	Disabled bool          `yaml:"disabled"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+func (c ReconcilerConfig) applyDefaults() ReconcilerConfig {
+	if c.Interval == 0 {
+		c.Interval = time.Hour
+	}
+	return c
+}
+
+// FileStore defines store operations required for reconciliation.
+type FileStore interface {
+	ListCacheFiles() ([]string, error)
+	GetCacheFileMetadata(name string, md metadata.Metadata) error
+}
+
+// Locator resolves the origins which own a blob.
+type Locator interface {
+	Locations(d core.Digest) []string
+}
+
+// Reconciler periodically scans the blobs owned by the local origin and adds
+// push tasks for any which are missing from their push remotes. This catches
+// blobs whose replication tasks were never added, e.g. because the remote was
+// configured after upload.
+type Reconciler struct {
+	config          ReconcilerConfig
+	stats           tally.Scope
+	clk             clock.Clock
+	addr            string
+	fs              FileStore
+	locator         Locator
+	remotes         Remotes
+	clusterProvider blobclient.ClusterProvider
+	manager         persistedretry.Manager
+
+	stopOnce sync.Once
+	stopc    chan struct{}
+}
+
+// NewReconciler creates a new Reconciler for the origin at addr. Only blobs
+// for which addr is the first location are reconciled, such that each blob
+// is checked by a single origin.
+func NewReconciler(
+	config ReconcilerConfig,
+	stats tally.Scope,
+	clk clock.Clock,
+	addr string,
+	fs FileStore,
+	locator Locator,
+	remotes Remotes,
+	clusterProvider blobclient.ClusterProvider,
+	manager persistedretry.Manager) *Reconciler {
+
+	stats = stats.Tagged(map[string]string{
+		"module": "blobreplicationreconciler",
+	})
+
+	return &Reconciler{
+		config:          config.applyDefaults(),
+		stats:           stats,
+		clk:             clk,
+		addr:            addr,
+		fs:              fs,
+		locator:         locator,
+		remotes:         remotes,
+		clusterProvider: clusterProvider,
+		manager:         manager,
+		stopc:           make(chan struct{}),
+	}
+}
+
+// Start runs reconciliation in the background every configured interval.
+func (r *Reconciler) Start() {
+	if r.config.Disabled {
+		log.Warn("Blob replication reconciliation disabled")
+		return
+	}
+	ticker := r.clk.Ticker(r.config.Interval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := r.Reconcile(); err != nil {
+					log.Errorf("Error reconciling blob replication: %s", err)
+				}
+			case <-r.stopc:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops background reconciliation.
+func (r *Reconciler) Stop() {
+	r.stopOnce.Do(func() { close(r.stopc) })
+}
+
+// Reconcile adds push tasks for every owned blob which is missing from a push
+// remote. The number of missing blobs per remote is emitted as a gauge.
+func (r *Reconciler) Reconcile() error {
+	timer := r.stats.Timer("reconcile").Start()
+	defer timer.Stop()
+
+	names, err := r.fs.ListCacheFiles()
+	if err != nil {
+		return fmt.Errorf("list cache files: %s", err)
+	}
+	missing := make(map[string]int)
+	for _, addr := range r.pushRemotes() {
+		missing[addr] = 0
+	}
+	remotes := make(map[string]blobclient.ClusterClient)
+	for _, name := range names {
+		d, err := core.NewSHA256DigestFromHex(name)
+		if err != nil {
+			continue
+		}
+		if locs := r.locator.Locations(d); len(locs) == 0 || locs[0] != r.addr {
+			continue
+		}
+		var ns metadata.Namespace
+		if err := r.fs.GetCacheFileMetadata(name, &ns); err != nil {
+			if !os.IsNotExist(err) {
+				log.With("blob", name).Errorf("Error getting namespace metadata: %s", err)
+			}
+			continue
+		}
+		for _, addr := range r.remotes.Match(ns.Value, PolicyPush) {
+			remote, ok := remotes[addr]
+			if !ok {
+				remote, err = r.clusterProvider.Provide(addr)
+				if err != nil {
+					return fmt.Errorf("provide remote cluster %s: %s", addr, err)
+				}
+				remotes[addr] = remote
+			}
+			if _, err := remote.Stat(ns.Value, d); err != blobclient.ErrBlobNotFound {
+				if err != nil {
+					r.stats.Counter("remote_stat_errors").Inc(1)
+				}
+				continue
+			}
+			missing[addr]++
+			if err := r.manager.Add(NewTask(ns.Value, d, addr, PolicyPush, 0)); err != nil {
+				log.With("blob", name, "remote", addr).Errorf(
+					"Error adding blob replication task: %s", err)
+			}
+		}
+	}
+	for addr, n := range missing {
+		r.stats.Tagged(map[string]string{"remote": addr}).Gauge("missing_remote_blobs").Update(float64(n))
+	}
+	return nil
+}
+
+func (r *Reconciler) pushRemotes() []string {
+	var addrs []string
+	seen := make(map[string]bool)
+	for _, rm := range r.remotes {
+		if rm.policy == PolicyPush && !seen[rm.addr] {
+			seen[rm.addr] = true
+			addrs = append(addrs, rm.addr)
+		}
+	}
+	return addrs
+}
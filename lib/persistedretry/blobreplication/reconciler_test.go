@@ -0,0 +1,92 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package blobreplication
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/lib/store/metadata"
+	"github.com/uber/kraken/mocks/lib/persistedretry"
+	"github.com/uber/kraken/mocks/origin/blobclient"
+	"github.com/uber/kraken/origin/blobclient"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+type locatorFunc func(d core.Digest) []string
+
+func (f locatorFunc) Locations(d core.Digest) []string { return f(d) }
+
+func TestReconcilerAddsTasksForMissingRemoteBlobs(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cas, cleanup := store.CAStoreFixture()
+	defer cleanup()
+
+	remotes, err := RemotesConfig{
+		"remote-a": {Policy: PolicyPush, Namespaces: []string{"foo/.*"}},
+		"remote-b": {Policy: PolicyPull, Namespaces: []string{"foo/.*"}},
+	}.Build()
+	require.NoError(err)
+
+	putBlob := func(namespace string) core.Digest {
+		blob := core.SizedBlobFixture(32, 8)
+		require.NoError(cas.CreateCacheFile(blob.Digest.Hex(), bytes.NewReader(blob.Content)))
+		if namespace != "" {
+			_, err := cas.SetCacheFileMetadata(blob.Digest.Hex(), metadata.NewNamespace(namespace))
+			require.NoError(err)
+		}
+		return blob.Digest
+	}
+	missing := putBlob("foo/bar")
+	replicated := putBlob("foo/bar")
+	notOwned := putBlob("foo/bar")
+	putBlob("baz/bar")
+	putBlob("")
+
+	locator := locatorFunc(func(d core.Digest) []string {
+		if d == notOwned {
+			return []string{"other-origin", "local-origin"}
+		}
+		return []string{"local-origin", "other-origin"}
+	})
+
+	stats := tally.NewTestScope("", nil)
+	remote := mockblobclient.NewMockClusterClient(ctrl)
+	provider := mockblobclient.NewMockClusterProvider(ctrl)
+	manager := mockpersistedretry.NewMockManager(ctrl)
+
+	provider.EXPECT().Provide("remote-a").Return(remote, nil)
+	remote.EXPECT().Stat("foo/bar", missing).Return(nil, blobclient.ErrBlobNotFound)
+	remote.EXPECT().Stat("foo/bar", replicated).Return(core.NewBlobInfo(32), nil)
+	manager.EXPECT().Add(MatchTask(NewTask("foo/bar", missing, "remote-a", PolicyPush, 0))).Return(nil)
+
+	r := NewReconciler(
+		ReconcilerConfig{}, stats, clock.New(), "local-origin", cas, locator, remotes, provider, manager)
+	require.NoError(r.Reconcile())
+
+	gauges := stats.Snapshot().Gauges()
+	g, ok := gauges["missing_remote_blobs+module=blobreplicationreconciler,remote=remote-a"]
+	require.True(ok)
+	require.Equal(float64(1), g.Value())
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package blobreplication
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// Replication policies.
+const (
+	// PolicyPush replicates blobs uploaded to the local origin cluster to the
+	// remote origin cluster.
+	PolicyPush = "push"
+
+	// PolicyPull replicates blobs which are missing from the local storage
+	// backends from the remote origin cluster on demand.
+	PolicyPull = "pull"
+)
+
+// RemoteValidator validates remotes.
+type RemoteValidator interface {
+	Valid(namespace, addr, policy string) bool
+}
+
+// Remote represents a remote origin cluster.
+type Remote struct {
+	regexp *regexp.Regexp
+	addr   string
+	policy string
+}
+
+// Remotes represents all namespaces and their configured remote origin
+// clusters.
+type Remotes []*Remote
+
+// Match returns all remotes which replicate namespace under policy.
+func (rs Remotes) Match(namespace, policy string) (addrs []string) {
+	seen := make(map[string]bool)
+	for _, r := range rs {
+		if r.policy != policy || seen[r.addr] {
+			continue
+		}
+		if r.regexp.MatchString(namespace) {
+			seen[r.addr] = true
+			addrs = append(addrs, r.addr)
+		}
+	}
+	return addrs
+}
+
+// Valid returns true if namespace is replicated with addr under policy.
+func (rs Remotes) Valid(namespace, addr, policy string) bool {
+	for _, a := range rs.Match(namespace, policy) {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoteConfig defines how blobs are replicated with a single remote origin
+// cluster.
+type RemoteConfig struct {
+	// Policy is either "push" or "pull".
+	Policy string `yaml:"policy"`
+
+	// Namespaces is a list of regular expressions of namespaces to replicate.
+	Namespaces []string `yaml:"namespaces"`
+}
+
+// RemotesConfig defines remote replication configuration which specifies which
+// namespaces should be replicated with certain origin clusters.
+//
+// For example, given the configuration:
+//
+//   origin-zone2:
+//     policy: push
+//     namespaces:
+//     - namespace_foo/.*
+//
+//   origin-zone3:
+//     policy: pull
+//     namespaces:
+//     - namespace_bar/.*
+//
+// Blobs uploaded under namespace_foo/.* are pushed to the zone2 origin cluster,
+// and blobs under namespace_bar/.* which are missing from the local storage
+// backends are pulled from the zone3 origin cluster.
+type RemotesConfig map[string]RemoteConfig
+
+// Build builds configuration into Remotes.
+func (c RemotesConfig) Build() (Remotes, error) {
+	var addrs []string
+	for addr := range c {
+		addrs = append(addrs, addr)
+	}
+	// Sort so that pull remotes are always tried in the same order.
+	sort.Strings(addrs)
+
+	var remotes Remotes
+	for _, addr := range addrs {
+		rc := c[addr]
+		if rc.Policy != PolicyPush && rc.Policy != PolicyPull {
+			return nil, fmt.Errorf("remote %s: invalid policy %q", addr, rc.Policy)
+		}
+		for _, ns := range rc.Namespaces {
+			re, err := regexp.Compile(ns)
+			if err != nil {
+				return nil, fmt.Errorf("regexp compile namespace %s: %s", ns, err)
+			}
+			remotes = append(remotes, &Remote{re, addr, rc.Policy})
+		}
+	}
+	return remotes, nil
+}
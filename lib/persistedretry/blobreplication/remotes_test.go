@@ -0,0 +1,80 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package blobreplication
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemotesMatch(t *testing.T) {
+	require := require.New(t)
+
+	remotes, err := RemotesConfig{
+		"a": {Policy: PolicyPush, Namespaces: []string{"foo/.*", "foo/bar.*"}},
+		"b": {Policy: PolicyPush, Namespaces: []string{"foo/.*"}},
+		"c": {Policy: PolicyPull, Namespaces: []string{"foo/.*", "bar/.*"}},
+	}.Build()
+	require.NoError(err)
+
+	tests := []struct {
+		namespace string
+		policy    string
+		expected  []string
+	}{
+		{"foo/bar", PolicyPush, []string{"a", "b"}},
+		{"foo/bar", PolicyPull, []string{"c"}},
+		{"bar/baz", PolicyPush, nil},
+		{"bar/baz", PolicyPull, []string{"c"}},
+		{"xxx", PolicyPush, nil},
+	}
+	for _, test := range tests {
+		require.Equal(
+			test.expected, remotes.Match(test.namespace, test.policy),
+			"Namespace: %s, Policy: %s", test.namespace, test.policy)
+	}
+}
+
+func TestRemotesValid(t *testing.T) {
+	require := require.New(t)
+
+	remotes, err := RemotesConfig{
+		"a": {Policy: PolicyPush, Namespaces: []string{"foo/.*"}},
+		"b": {Policy: PolicyPull, Namespaces: []string{"foo/.*"}},
+	}.Build()
+	require.NoError(err)
+
+	require.True(remotes.Valid("foo/123", "a", PolicyPush))
+	require.False(remotes.Valid("foo/123", "a", PolicyPull))
+	require.True(remotes.Valid("foo/123", "b", PolicyPull))
+	require.False(remotes.Valid("bar/123", "a", PolicyPush))
+	require.False(remotes.Valid("foo/123", "x", PolicyPush))
+}
+
+func TestRemotesConfigBuildErrors(t *testing.T) {
+	tests := []struct {
+		desc   string
+		config RemotesConfig
+	}{
+		{"invalid policy", RemotesConfig{"a": {Policy: "sync", Namespaces: []string{".*"}}}},
+		{"invalid namespace", RemotesConfig{"a": {Policy: PolicyPush, Namespaces: []string{"("}}}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := test.config.Build()
+			require.Error(t, err)
+		})
+	}
+}
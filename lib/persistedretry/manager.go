@@ -79,6 +79,8 @@ func NewManager(
 	return m, nil
 }
 
+// markPendingTasksAsFailed moves tasks which were interrupted by a restart to
+// the failed state, from which they are replayed by the retry poll.
 func (m *manager) markPendingTasksAsFailed() error {
 	tasks, err := m.store.GetPending()
 	if err != nil {
@@ -89,6 +91,10 @@ func (m *manager) markPendingTasksAsFailed() error {
 			return fmt.Errorf("mark task as failed: %s", err)
 		}
 	}
+	if len(tasks) > 0 {
+		log.Infof("Marked %d interrupted pending tasks as failed for retry", len(tasks))
+	}
+	m.stats.Counter("interrupted_tasks").Inc(int64(len(tasks)))
 	return nil
 }
 
@@ -223,6 +229,7 @@ func (m *manager) pollRetries() {
 		log.Errorf("Error getting failed tasks: %s", err)
 		return
 	}
+	m.stats.Gauge("failed_tasks").Update(float64(len(tasks)))
 	for _, t := range tasks {
 		if t.Ready() && time.Since(t.GetLastAttempt()) > m.config.RetryInterval {
 			if err := m.retry(t); err != nil {
@@ -0,0 +1,101 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/uber/kraken/lib/persistedretry"
+)
+
+// Config defines notification configuration.
+type Config struct {
+	Endpoints []EndpointConfig      `yaml:"endpoints"`
+	Retry     persistedretry.Config `yaml:"retry"`
+}
+
+// EndpointConfig defines a single webhook which receives notifications.
+//
+// For example, given the configuration:
+//
+//   - name: ci
+//     url: https://ci.example.com/kraken/events
+//     headers:
+//       Authorization: Bearer some-token
+//     namespaces:
+//     - namespace_foo/.*
+//
+// Events for repositories matching namespace_foo/.* are posted to the ci
+// endpoint.
+type EndpointConfig struct {
+	// Name uniquely identifies the endpoint. Undelivered notifications are
+	// dropped if the endpoint of the same name is removed from configuration.
+	Name string `yaml:"name"`
+
+	URL string `yaml:"url"`
+
+	// Headers are added to every request sent to the endpoint.
+	Headers map[string]string `yaml:"headers"`
+
+	Timeout time.Duration `yaml:"timeout"`
+
+	// Namespaces is a list of regular expressions of repositories to notify
+	// the endpoint of. If empty, the endpoint is notified of all repositories.
+	Namespaces []string `yaml:"namespaces"`
+}
+
+func (c EndpointConfig) applyDefaults() EndpointConfig {
+	if c.Timeout == 0 {
+		c.Timeout = 5 * time.Second
+	}
+	return c
+}
+
+// Build builds configuration into Endpoints.
+func (c Config) Build() (Endpoints, error) {
+	var endpoints Endpoints
+	names := make(map[string]bool)
+	for _, ec := range c.Endpoints {
+		ec = ec.applyDefaults()
+		if ec.Name == "" {
+			return nil, errors.New("endpoint name required")
+		}
+		if names[ec.Name] {
+			return nil, fmt.Errorf("duplicate endpoint %s", ec.Name)
+		}
+		names[ec.Name] = true
+		if ec.URL == "" {
+			return nil, fmt.Errorf("endpoint %s: url required", ec.Name)
+		}
+		e := &Endpoint{
+			name:    ec.Name,
+			url:     ec.URL,
+			headers: ec.Headers,
+			timeout: ec.Timeout,
+		}
+		for _, ns := range ec.Namespaces {
+			re, err := regexp.Compile(ns)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"endpoint %s: regexp compile namespace %s: %s", ec.Name, ns, err)
+			}
+			e.namespaces = append(e.namespaces, re)
+		}
+		endpoints = append(endpoints, e)
+	}
+	return endpoints, nil
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package notification
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointsMatch(t *testing.T) {
+	require := require.New(t)
+
+	endpoints, err := Config{Endpoints: []EndpointConfig{
+		{Name: "a", URL: "http://a", Namespaces: []string{"foo/.*", "bar/.*"}},
+		{Name: "b", URL: "http://b", Namespaces: []string{"foo/.*"}},
+		{Name: "c", URL: "http://c"},
+	}}.Build()
+	require.NoError(err)
+
+	for repo, expected := range map[string][]string{
+		"foo/123": {"a", "b", "c"},
+		"bar/abc": {"a", "c"},
+		"baz/456": {"c"},
+	} {
+		var names []string
+		for _, e := range endpoints.Match(repo) {
+			names = append(names, e.Name())
+		}
+		require.ElementsMatch(expected, names, "Repo: %s", repo)
+	}
+
+	require.True(endpoints.Valid("b", "foo/123"))
+	require.False(endpoints.Valid("b", "bar/abc"))
+	require.False(endpoints.Valid("d", "foo/123"))
+}
+
+func TestConfigBuildErrors(t *testing.T) {
+	tests := []struct {
+		desc      string
+		endpoints []EndpointConfig
+	}{
+		{"missing name", []EndpointConfig{{URL: "http://a"}}},
+		{"missing url", []EndpointConfig{{Name: "a"}}},
+		{"duplicate name", []EndpointConfig{{Name: "a", URL: "http://a"}, {Name: "a", URL: "http://b"}}},
+		{"invalid namespace", []EndpointConfig{{Name: "a", URL: "http://a", Namespaces: []string{"("}}}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := Config{Endpoints: test.endpoints}.Build()
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestSplitTag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		repo string
+		name string
+	}{
+		{"foo/bar:latest", "foo/bar", "latest"},
+		{"localhost:5000/foo:v1", "localhost:5000/foo", "v1"},
+		{"localhost:5000/foo", "localhost:5000/foo", ""},
+		{"foo", "foo", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.tag, func(t *testing.T) {
+			repo, name := splitTag(test.tag)
+			require.Equal(t, test.repo, repo)
+			require.Equal(t, test.name, name)
+		})
+	}
+}
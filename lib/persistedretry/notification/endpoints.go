@@ -0,0 +1,82 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package notification
+
+import (
+	"regexp"
+	"time"
+)
+
+// EndpointValidator validates endpoints.
+type EndpointValidator interface {
+	Valid(name, repo string) bool
+}
+
+// Endpoint represents a webhook which receives notifications.
+type Endpoint struct {
+	name       string
+	url        string
+	headers    map[string]string
+	timeout    time.Duration
+	namespaces []*regexp.Regexp
+}
+
+// Name returns the name of the endpoint.
+func (e *Endpoint) Name() string {
+	return e.name
+}
+
+// Accepts returns true if e should be notified of events for repo.
+func (e *Endpoint) Accepts(repo string) bool {
+	if len(e.namespaces) == 0 {
+		return true
+	}
+	for _, re := range e.namespaces {
+		if re.MatchString(repo) {
+			return true
+		}
+	}
+	return false
+}
+
+// Endpoints represents all configured endpoints.
+type Endpoints []*Endpoint
+
+// Match returns all endpoints which should be notified of events for repo.
+func (es Endpoints) Match(repo string) []*Endpoint {
+	var result []*Endpoint
+	for _, e := range es {
+		if e.Accepts(repo) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Get returns the endpoint named name.
+func (es Endpoints) Get(name string) (*Endpoint, bool) {
+	for _, e := range es {
+		if e.name == name {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// Valid returns true if an endpoint named name is configured and accepts
+// events for repo.
+func (es Endpoints) Valid(name, repo string) bool {
+	e, ok := es.Get(name)
+	return ok && e.Accepts(repo)
+}
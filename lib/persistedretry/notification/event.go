@@ -0,0 +1,100 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package notification
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// EventsMediaType is the content type of notification requests, which follows
+// the Docker Registry v2 notification format. See
+// https://docs.docker.com/registry/notifications/.
+const EventsMediaType = "application/vnd.docker.distribution.events.v1+json"
+
+// Event actions.
+const (
+	// ActionPush is sent when a tag is pushed through the proxy, or when a blob
+	// upload to the origin cluster is committed.
+	ActionPush = "push"
+
+	// ActionReplicate is sent when build-index finishes replicating a tag to a
+	// remote build-index. It is not part of the Docker Registry v2 format, and
+	// is expected to be ignored by receivers which do not understand it.
+	ActionReplicate = "replicate"
+)
+
+// Media types of event targets.
+const (
+	// MediaTypeBlob is the target media type of origin blob upload events.
+	MediaTypeBlob = "application/octet-stream"
+)
+
+// Envelope is the body of a notification request.
+type Envelope struct {
+	Events []Event `json:"events"`
+}
+
+// Event describes an action taken against a repository.
+type Event struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Action    string    `json:"action"`
+	Target    Target    `json:"target"`
+	Source    Source    `json:"source"`
+
+	// Destination is the remote build-index of replicate events.
+	Destination string `json:"destination,omitempty"`
+}
+
+// Target describes the content an event acted upon.
+type Target struct {
+	MediaType  string `json:"mediaType,omitempty"`
+	Size       int64  `json:"size,omitempty"`
+	Digest     string `json:"digest,omitempty"`
+	Length     int64  `json:"length,omitempty"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag,omitempty"`
+}
+
+// Source describes the Kraken node which generated an event.
+type Source struct {
+	Addr       string `json:"addr"`
+	InstanceID string `json:"instanceID"`
+}
+
+// Value marshals an event and returns []byte as driver.Value.
+func (e Event) Value() (driver.Value, error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return driver.Value([]byte{}), err
+	}
+	return driver.Value(b), nil
+}
+
+// Scan unmarshals []byte to an Event.
+func (e *Event) Scan(src interface{}) error {
+	return json.Unmarshal(src.([]byte), e)
+}
+
+// splitTag splits a "repo:tag" formatted tag into its repository and tag.
+func splitTag(tag string) (repo, name string) {
+	i := strings.LastIndex(tag, ":")
+	if i == -1 || strings.Contains(tag[i:], "/") {
+		return tag, ""
+	}
+	return tag[:i], tag[i+1:]
+}
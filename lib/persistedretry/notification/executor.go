@@ -0,0 +1,86 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/uber/kraken/lib/persistedretry"
+	"github.com/uber/kraken/utils/httputil"
+	"github.com/uber/kraken/utils/log"
+
+	"github.com/uber-go/tally"
+)
+
+// Executor executes notification tasks.
+type Executor struct {
+	stats     tally.Scope
+	endpoints Endpoints
+}
+
+// NewExecutor creates a new Executor.
+func NewExecutor(stats tally.Scope, endpoints Endpoints) *Executor {
+	stats = stats.Tagged(map[string]string{
+		"module": "notificationexecutor",
+	})
+
+	return &Executor{stats, endpoints}
+}
+
+// Name returns the executor name.
+func (e *Executor) Name() string {
+	return "notification"
+}
+
+// Exec posts the task's event to its endpoint.
+func (e *Executor) Exec(r persistedretry.Task) error {
+	t := r.(*Task)
+	endpoint, ok := e.endpoints.Get(t.Endpoint)
+	if !ok {
+		// Endpoint was removed from configuration while the task was queued.
+		log.With("endpoint", t.Endpoint).Info("Dropping notification for unknown endpoint")
+		return nil
+	}
+	body, err := json.Marshal(Envelope{Events: []Event{t.Event}})
+	if err != nil {
+		return fmt.Errorf("json marshal: %s", err)
+	}
+	headers := map[string]string{"Content-Type": EventsMediaType}
+	for k, v := range endpoint.headers {
+		headers[k] = v
+	}
+	resp, err := httputil.Post(
+		endpoint.url,
+		httputil.SendBody(bytes.NewReader(body)),
+		httputil.SendHeaders(headers),
+		httputil.SendTimeout(endpoint.timeout),
+		httputil.SendAcceptedCodes(
+			http.StatusOK, http.StatusCreated, http.StatusAccepted, http.StatusNoContent))
+	if err != nil {
+		return fmt.Errorf("post %s: %s", endpoint.name, err)
+	}
+	resp.Body.Close()
+
+	stats := e.stats.Tagged(map[string]string{
+		"endpoint": t.Endpoint,
+	})
+	stats.Counter("delivered").Inc(1)
+	stats.Timer("lifetime").Record(time.Since(t.CreatedAt))
+
+	return nil
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package notification
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+func newTestExecutor(t *testing.T, url string) *Executor {
+	endpoints, err := Config{Endpoints: []EndpointConfig{{
+		Name:    "a",
+		URL:     url,
+		Headers: map[string]string{"Authorization": "Bearer some-token"},
+	}}}.Build()
+	require.NoError(t, err)
+	return NewExecutor(tally.NoopScope, endpoints)
+}
+
+func TestExecutorPostsEnvelope(t *testing.T) {
+	require := require.New(t)
+
+	var received Envelope
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		require.NoError(json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	executor := newTestExecutor(t, server.URL)
+
+	task := NewTask("a", EventFixture())
+	require.NoError(executor.Exec(task))
+
+	require.Equal(Envelope{Events: []Event{task.Event}}, received)
+	require.Equal(EventsMediaType, header.Get("Content-Type"))
+	require.Equal("Bearer some-token", header.Get("Authorization"))
+}
+
+func TestExecutorEndpointError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	executor := newTestExecutor(t, server.URL)
+
+	require.Error(t, executor.Exec(NewTask("a", EventFixture())))
+}
+
+func TestExecutorNoopsOnUnknownEndpoint(t *testing.T) {
+	executor := newTestExecutor(t, "http://localhost:0")
+
+	require.NoError(t, executor.Exec(NewTask("b", EventFixture())))
+}
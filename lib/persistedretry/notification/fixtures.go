@@ -0,0 +1,44 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package notification
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/utils/randutil"
+)
+
+// EventFixture creates a fixture of notification.Event.
+func EventFixture() Event {
+	tag := core.TagFixture()
+	repo, name := splitTag(tag)
+	return Event{
+		ID:        randutil.Hex(16),
+		Timestamp: time.Now().UTC(),
+		Action:    ActionPush,
+		Target: Target{
+			Digest:     core.DigestFixture().String(),
+			Repository: repo,
+			Tag:        name,
+		},
+	}
+}
+
+// TaskFixture creates a fixture of notification.Task.
+func TaskFixture() *Task {
+	endpoint := fmt.Sprintf("endpoint-%s", randutil.Hex(8))
+	return NewTask(endpoint, EventFixture())
+}
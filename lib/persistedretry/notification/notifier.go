@@ -0,0 +1,164 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package notification
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/persistedretry"
+	"github.com/uber/kraken/utils/log"
+
+	"github.com/docker/distribution/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/uber-go/tally"
+)
+
+// Notifier notifies configured endpoints of events. Notifications are
+// delivered asynchronously and retried until they succeed, hence Notifier
+// methods never block on nor fail because of endpoints.
+type Notifier interface {
+	// TagPushed notifies that tag was pushed through the proxy, where d is the
+	// digest of the tag's manifest. mediaType and size are optional.
+	TagPushed(tag string, d core.Digest, mediaType string, size int64)
+
+	// TagReplicated notifies that tag was replicated to the remote build-index
+	// destination.
+	TagReplicated(tag string, d core.Digest, destination string)
+
+	// BlobUploaded notifies that the upload of blob d under namespace to the
+	// origin cluster was committed.
+	BlobUploaded(namespace string, d core.Digest, size int64)
+}
+
+type notifier struct {
+	stats     tally.Scope
+	endpoints Endpoints
+	manager   persistedretry.Manager
+	source    Source
+}
+
+// New creates a new Notifier, which persists undelivered notifications in db.
+// If no endpoints are configured, the returned Notifier drops all events and
+// db is not used.
+func New(config Config, stats tally.Scope, db *sqlx.DB) (Notifier, error) {
+	endpoints, err := config.Build()
+	if err != nil {
+		return nil, fmt.Errorf("build endpoints: %s", err)
+	}
+	if len(endpoints) == 0 {
+		return NewNoopNotifier(), nil
+	}
+	store, err := NewStore(db, endpoints)
+	if err != nil {
+		return nil, fmt.Errorf("new store: %s", err)
+	}
+	manager, err := persistedretry.NewManager(
+		config.Retry, stats, store, NewExecutor(stats, endpoints))
+	if err != nil {
+		return nil, fmt.Errorf("new manager: %s", err)
+	}
+	return newNotifier(stats, endpoints, manager), nil
+}
+
+func newNotifier(
+	stats tally.Scope, endpoints Endpoints, manager persistedretry.Manager) *notifier {
+
+	stats = stats.Tagged(map[string]string{
+		"module": "notification",
+	})
+
+	addr, err := os.Hostname()
+	if err != nil {
+		log.Errorf("Error getting hostname for notification source: %s", err)
+	}
+	source := Source{
+		Addr:       addr,
+		InstanceID: uuid.Generate().String(),
+	}
+	return &notifier{stats, endpoints, manager, source}
+}
+
+func (n *notifier) TagPushed(tag string, d core.Digest, mediaType string, size int64) {
+	repo, name := splitTag(tag)
+	n.notify(ActionPush, Target{
+		MediaType:  mediaType,
+		Size:       size,
+		Digest:     d.String(),
+		Length:     size,
+		Repository: repo,
+		Tag:        name,
+	}, "")
+}
+
+func (n *notifier) TagReplicated(tag string, d core.Digest, destination string) {
+	repo, name := splitTag(tag)
+	n.notify(ActionReplicate, Target{
+		Digest:     d.String(),
+		Repository: repo,
+		Tag:        name,
+	}, destination)
+}
+
+func (n *notifier) BlobUploaded(namespace string, d core.Digest, size int64) {
+	n.notify(ActionPush, Target{
+		MediaType:  MediaTypeBlob,
+		Size:       size,
+		Digest:     d.String(),
+		Length:     size,
+		Repository: namespace,
+	}, "")
+}
+
+func (n *notifier) notify(action string, target Target, destination string) {
+	endpoints := n.endpoints.Match(target.Repository)
+	if len(endpoints) == 0 {
+		return
+	}
+	e := Event{
+		ID:          uuid.Generate().String(),
+		Timestamp:   time.Now().UTC(),
+		Action:      action,
+		Target:      target,
+		Source:      n.source,
+		Destination: destination,
+	}
+	for _, endpoint := range endpoints {
+		if err := n.manager.Add(NewTask(endpoint.name, e)); err != nil {
+			n.stats.Counter("add_task_errors").Inc(1)
+			log.With("endpoint", endpoint.name, "event", e.ID).Errorf(
+				"Error adding notification task: %s", err)
+			continue
+		}
+		n.stats.Tagged(map[string]string{
+			"endpoint": endpoint.name,
+			"action":   action,
+		}).Counter("events").Inc(1)
+	}
+}
+
+type noopNotifier struct{}
+
+// NewNoopNotifier returns a Notifier which drops all events.
+func NewNoopNotifier() Notifier {
+	return noopNotifier{}
+}
+
+func (noopNotifier) TagPushed(string, core.Digest, string, int64) {}
+
+func (noopNotifier) TagReplicated(string, core.Digest, string) {}
+
+func (noopNotifier) BlobUploaded(string, core.Digest, int64) {}
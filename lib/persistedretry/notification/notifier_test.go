@@ -0,0 +1,148 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package notification
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/persistedretry"
+	"github.com/uber/kraken/localdb"
+	mockpersistedretry "github.com/uber/kraken/mocks/lib/persistedretry"
+	"github.com/uber/kraken/utils/testutil"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+type taskRecorder struct {
+	tasks []*Task
+}
+
+func (r *taskRecorder) record(t persistedretry.Task) error {
+	r.tasks = append(r.tasks, t.(*Task))
+	return nil
+}
+
+func newTestNotifier(
+	t *testing.T, manager persistedretry.Manager, endpoints ...EndpointConfig) *notifier {
+
+	es, err := Config{Endpoints: endpoints}.Build()
+	require.NoError(t, err)
+	return newNotifier(tally.NoopScope, es, manager)
+}
+
+func TestNotifierFiltersEndpointsByNamespace(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	manager := mockpersistedretry.NewMockManager(ctrl)
+	recorder := &taskRecorder{}
+	manager.EXPECT().Add(gomock.Any()).DoAndReturn(recorder.record).AnyTimes()
+
+	n := newTestNotifier(t, manager,
+		EndpointConfig{Name: "foo", URL: "http://foo", Namespaces: []string{"foo/.*"}},
+		EndpointConfig{Name: "all", URL: "http://all"})
+
+	d := core.DigestFixture()
+
+	n.TagPushed("foo/bar:latest", d, "application/vnd.oci.image.manifest.v1+json", 100)
+	require.Len(recorder.tasks, 2)
+	require.ElementsMatch(
+		[]string{"foo", "all"}, []string{recorder.tasks[0].Endpoint, recorder.tasks[1].Endpoint})
+	e := recorder.tasks[0].Event
+	require.Equal(e, recorder.tasks[1].Event)
+	require.NotEmpty(e.ID)
+	require.Equal(ActionPush, e.Action)
+	require.Equal(Target{
+		MediaType:  "application/vnd.oci.image.manifest.v1+json",
+		Size:       100,
+		Digest:     d.String(),
+		Length:     100,
+		Repository: "foo/bar",
+		Tag:        "latest",
+	}, e.Target)
+	require.NotEmpty(e.Source.InstanceID)
+
+	recorder.tasks = nil
+	n.TagReplicated("baz/bar:latest", d, "build-index-zone2")
+	require.Len(recorder.tasks, 1)
+	require.Equal("all", recorder.tasks[0].Endpoint)
+	require.Equal(ActionReplicate, recorder.tasks[0].Event.Action)
+	require.Equal("build-index-zone2", recorder.tasks[0].Event.Destination)
+
+	recorder.tasks = nil
+	n.BlobUploaded("foo/baz", d, 5)
+	require.Len(recorder.tasks, 2)
+	require.Equal(MediaTypeBlob, recorder.tasks[0].Event.Target.MediaType)
+	require.Equal("foo/baz", recorder.tasks[0].Event.Target.Repository)
+}
+
+func TestNotifierRetriesFailedDeliveries(t *testing.T) {
+	require := require.New(t)
+
+	var mu sync.Mutex
+	var attempts int
+	var received []Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var env Envelope
+		require.NoError(json.NewDecoder(r.Body).Decode(&env))
+		received = append(received, env.Events...)
+	}))
+	defer server.Close()
+
+	db, cleanup := localdb.Fixture()
+	defer cleanup()
+
+	n, err := New(Config{
+		Endpoints: []EndpointConfig{{Name: "a", URL: server.URL}},
+		Retry: persistedretry.Config{
+			RetryInterval:       100 * time.Millisecond,
+			PollRetriesInterval: 100 * time.Millisecond,
+		},
+	}, tally.NoopScope, db)
+	require.NoError(err)
+
+	d := core.DigestFixture()
+	n.BlobUploaded("foo/bar", d, 5)
+
+	require.NoError(testutil.PollUntilTrue(5*time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 1
+	}))
+	require.Equal(d.String(), received[0].Target.Digest)
+	require.Equal(2, attempts)
+}
+
+func TestNewWithoutEndpointsIsNoop(t *testing.T) {
+	n, err := New(Config{}, tally.NoopScope, nil)
+	require.NoError(t, err)
+	n.TagPushed("foo/bar:latest", core.DigestFixture(), "", 0)
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package notification
+
+import (
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/lib/persistedretry"
+	"github.com/uber/kraken/localdb"
+
+	"github.com/stretchr/testify/require"
+)
+
+type endpointValidatorFunc func(name, repo string) bool
+
+func (f endpointValidatorFunc) Valid(name, repo string) bool {
+	return f(name, repo)
+}
+
+var _allValid = endpointValidatorFunc(func(string, string) bool { return true })
+
+func checkTasks(t *testing.T, expected []*Task, result []persistedretry.Task) {
+	t.Helper()
+
+	require.Equal(t, len(expected), len(result))
+	for i := range expected {
+		e := *expected[i]
+		r := *(result[i].(*Task))
+
+		require.InDelta(t, e.CreatedAt.Unix(), r.CreatedAt.Unix(), 1)
+		require.InDelta(t, e.LastAttempt.Unix(), r.LastAttempt.Unix(), 1)
+		e.CreatedAt, r.CreatedAt = time.Time{}, time.Time{}
+		e.LastAttempt, r.LastAttempt = time.Time{}, time.Time{}
+
+		require.Equal(t, e, r)
+	}
+}
+
+func TestStoreStateTransitions(t *testing.T) {
+	require := require.New(t)
+
+	db, cleanup := localdb.Fixture()
+	defer cleanup()
+
+	store, err := NewStore(db, _allValid)
+	require.NoError(err)
+
+	task := TaskFixture()
+	require.NoError(store.AddPending(task))
+	require.Equal(persistedretry.ErrTaskExists, store.AddPending(task))
+
+	result, err := store.GetPending()
+	require.NoError(err)
+	checkTasks(t, []*Task{task}, result)
+
+	require.NoError(store.MarkFailed(task))
+	require.Equal(1, task.Failures)
+	result, err = store.GetFailed()
+	require.NoError(err)
+	checkTasks(t, []*Task{task}, result)
+
+	require.NoError(store.MarkPending(task))
+	result, err = store.GetPending()
+	require.NoError(err)
+	checkTasks(t, []*Task{task}, result)
+
+	require.NoError(store.Remove(task))
+	result, err = store.GetPending()
+	require.NoError(err)
+	require.Empty(result)
+	require.Equal(persistedretry.ErrTaskNotFound, store.MarkFailed(task))
+}
+
+func TestStoreSameEventDifferentEndpoints(t *testing.T) {
+	require := require.New(t)
+
+	db, cleanup := localdb.Fixture()
+	defer cleanup()
+
+	store, err := NewStore(db, _allValid)
+	require.NoError(err)
+
+	e := EventFixture()
+	a := NewTask("a", e)
+	b := NewTask("b", e)
+	require.NoError(store.AddPending(a))
+	require.NoError(store.AddFailed(b))
+
+	result, err := store.GetPending()
+	require.NoError(err)
+	checkTasks(t, []*Task{a}, result)
+
+	result, err = store.GetFailed()
+	require.NoError(err)
+	checkTasks(t, []*Task{b}, result)
+}
+
+func TestStoreDeletesInvalidTasks(t *testing.T) {
+	require := require.New(t)
+
+	db, cleanup := localdb.Fixture()
+	defer cleanup()
+
+	store, err := NewStore(db, _allValid)
+	require.NoError(err)
+
+	valid := TaskFixture()
+	invalid := TaskFixture()
+	require.NoError(store.AddPending(valid))
+	require.NoError(store.AddFailed(invalid))
+
+	store, err = NewStore(db, endpointValidatorFunc(func(name, repo string) bool {
+		return name == valid.Endpoint && repo == valid.Event.Target.Repository
+	}))
+	require.NoError(err)
+
+	result, err := store.GetPending()
+	require.NoError(err)
+	checkTasks(t, []*Task{valid}, result)
+
+	result, err = store.GetFailed()
+	require.NoError(err)
+	require.Empty(result)
+}
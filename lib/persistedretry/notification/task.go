@@ -0,0 +1,69 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package notification
+
+import (
+	"fmt"
+	"time"
+)
+
+// Task contains an event to be delivered to an endpoint.
+type Task struct {
+	Endpoint    string        `db:"endpoint"`
+	EventID     string        `db:"event_id"`
+	Event       Event         `db:"event"`
+	CreatedAt   time.Time     `db:"created_at"`
+	LastAttempt time.Time     `db:"last_attempt"`
+	Failures    int           `db:"failures"`
+	Delay       time.Duration `db:"delay"`
+}
+
+// NewTask creates a new Task.
+func NewTask(endpoint string, e Event) *Task {
+	return &Task{
+		Endpoint:  endpoint,
+		EventID:   e.ID,
+		Event:     e,
+		CreatedAt: time.Now(),
+	}
+}
+
+func (t *Task) String() string {
+	return fmt.Sprintf(
+		"notification.Task(endpoint=%s, event=%s, action=%s)",
+		t.Endpoint, t.EventID, t.Event.Action)
+}
+
+// GetLastAttempt returns when t was last attempted.
+func (t *Task) GetLastAttempt() time.Time {
+	return t.LastAttempt
+}
+
+// GetFailures returns the number of times t has failed.
+func (t *Task) GetFailures() int {
+	return t.Failures
+}
+
+// Ready returns whether t is ready to run.
+func (t *Task) Ready() bool {
+	return time.Since(t.CreatedAt) >= t.Delay
+}
+
+// Tags returns the notification endpoint and action.
+func (t *Task) Tags() map[string]string {
+	return map[string]string{
+		"endpoint": t.Endpoint,
+		"action":   t.Event.Action,
+	}
+}
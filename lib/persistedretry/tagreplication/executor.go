@@ -19,6 +19,8 @@ import (
 
 	"github.com/uber/kraken/build-index/tagclient"
 	"github.com/uber/kraken/lib/persistedretry"
+	"github.com/uber/kraken/lib/persistedretry/notification"
+	"github.com/uber/kraken/lib/signature"
 	"github.com/uber/kraken/origin/blobclient"
 
 	"github.com/uber-go/tally"
@@ -29,19 +31,23 @@ type Executor struct {
 	stats             tally.Scope
 	originCluster     blobclient.ClusterClient
 	tagClientProvider tagclient.Provider
+	notifier          notification.Notifier
+	verifier          signature.Verifier
 }
 
 // NewExecutor creates a new Executor.
 func NewExecutor(
 	stats tally.Scope,
 	originCluster blobclient.ClusterClient,
-	tagClientProvider tagclient.Provider) *Executor {
+	tagClientProvider tagclient.Provider,
+	notifier notification.Notifier,
+	verifier signature.Verifier) *Executor {
 
 	stats = stats.Tagged(map[string]string{
 		"module": "tagreplicationexecutor",
 	})
 
-	return &Executor{stats, originCluster, tagClientProvider}
+	return &Executor{stats, originCluster, tagClientProvider, notifier, verifier}
 }
 
 // Name returns the executor name.
@@ -50,7 +56,8 @@ func (e *Executor) Name() string {
 }
 
 // Exec replicates a tag's blob dependencies to the task's remote origin
-// cluster, then replicates the tag to the remote build-index.
+// cluster, then replicates the tag to the remote build-index. Tags failing
+// signature verification are not replicated.
 func (e *Executor) Exec(r persistedretry.Task) error {
 	t := r.(*Task)
 	start := time.Now()
@@ -62,6 +69,12 @@ func (e *Executor) Exec(r persistedretry.Task) error {
 		return nil
 	}
 
+	// Tags are usually signed after they are pushed, so the task is retried
+	// until the signature arrives if signatures are enforced.
+	if err := e.verifier.Verify(t.Tag, t.Digest, signature.OpReplicate); err != nil {
+		return fmt.Errorf("verify signature: %s", err)
+	}
+
 	remoteOrigin, err := remoteTagClient.Origin()
 	if err != nil {
 		return fmt.Errorf("lookup remote origin cluster: %s", err)
@@ -78,6 +91,7 @@ func (e *Executor) Exec(r persistedretry.Task) error {
 	if err := remoteTagClient.PutAndReplicate(t.Tag, t.Digest); err != nil {
 		return fmt.Errorf("put and replicate tag: %s", err)
 	}
+	e.notifier.TagReplicated(t.Tag, t.Digest, t.Destination)
 
 	// We don't want to time noops nor errors.
 	e.stats.Timer("replicate").Record(time.Since(start))
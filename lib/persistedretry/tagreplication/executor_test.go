@@ -14,9 +14,13 @@
 package tagreplication
 
 import (
+	"errors"
 	"testing"
 
+	"github.com/uber/kraken/lib/signature"
 	"github.com/uber/kraken/mocks/build-index/tagclient"
+	"github.com/uber/kraken/mocks/lib/persistedretry/notification"
+	"github.com/uber/kraken/mocks/lib/signature"
 	"github.com/uber/kraken/mocks/origin/blobclient"
 
 	"github.com/golang/mock/gomock"
@@ -32,6 +36,8 @@ type executorMocks struct {
 	ctrl              *gomock.Controller
 	originCluster     *mockblobclient.MockClusterClient
 	tagClientProvider *mocktagclient.MockProvider
+	notifier          *mocknotification.MockNotifier
+	verifier          *mocksignature.MockVerifier
 }
 
 func newExecutorMocks(t *testing.T) (*executorMocks, func()) {
@@ -40,11 +46,14 @@ func newExecutorMocks(t *testing.T) (*executorMocks, func()) {
 		ctrl:              ctrl,
 		originCluster:     mockblobclient.NewMockClusterClient(ctrl),
 		tagClientProvider: mocktagclient.NewMockProvider(ctrl),
+		notifier:          mocknotification.NewMockNotifier(ctrl),
+		verifier:          mocksignature.NewMockVerifier(ctrl),
 	}, ctrl.Finish
 }
 
 func (m *executorMocks) new() *Executor {
-	return NewExecutor(tally.NoopScope, m.originCluster, m.tagClientProvider)
+	return NewExecutor(
+		tally.NoopScope, m.originCluster, m.tagClientProvider, m.notifier, m.verifier)
 }
 
 func (m *executorMocks) newTagClient() *mocktagclient.MockClient {
@@ -64,6 +73,7 @@ func TestExecutor(t *testing.T) {
 	gomock.InOrder(
 		mocks.tagClientProvider.EXPECT().Provide(task.Destination).Return(tagClient),
 		tagClient.EXPECT().Has(task.Tag).Return(false, nil),
+		mocks.verifier.EXPECT().Verify(task.Tag, task.Digest, signature.OpReplicate).Return(nil),
 		tagClient.EXPECT().Origin().Return(_testRemoteOrigin, nil),
 		mocks.originCluster.EXPECT().ReplicateToRemote(
 			task.Tag, task.Dependencies[0], _testRemoteOrigin).Return(nil),
@@ -72,6 +82,7 @@ func TestExecutor(t *testing.T) {
 		mocks.originCluster.EXPECT().ReplicateToRemote(
 			task.Tag, task.Dependencies[2], _testRemoteOrigin).Return(nil),
 		tagClient.EXPECT().PutAndReplicate(task.Tag, task.Digest).Return(nil),
+		mocks.notifier.EXPECT().TagReplicated(task.Tag, task.Digest, task.Destination),
 	)
 
 	require.NoError(executor.Exec(task))
@@ -94,3 +105,23 @@ func TestExecutorNoopsWhenTagAlreadyReplicated(t *testing.T) {
 
 	require.NoError(executor.Exec(task))
 }
+
+func TestExecutorRetriesUnsignedTag(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newExecutorMocks(t)
+	defer cleanup()
+
+	executor := mocks.new()
+	tagClient := mocks.newTagClient()
+	task := TaskFixture()
+
+	gomock.InOrder(
+		mocks.tagClientProvider.EXPECT().Provide(task.Destination).Return(tagClient),
+		tagClient.EXPECT().Has(task.Tag).Return(false, nil),
+		mocks.verifier.EXPECT().Verify(task.Tag, task.Digest, signature.OpReplicate).Return(
+			signature.RejectedError{Digest: task.Digest, Reason: errors.New("some error")}),
+	)
+
+	require.Error(executor.Exec(task))
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package writeback
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/uber/kraken/utils/handler"
+
+	"github.com/go-chi/chi"
+)
+
+// adminTask is a task as rendered by AdminHandler.
+type adminTask struct {
+	Namespace   string    `json:"namespace"`
+	Name        string    `json:"name"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastAttempt time.Time `json:"last_attempt"`
+	Failures    int       `json:"failures"`
+	Delay       string    `json:"delay"`
+}
+
+// AdminHandler returns an http.Handler which exposes the write-back backlog in
+// s and allows operators to act on it in bulk, e.g. once a backend recovers
+// from an outage. All endpoints accept an optional namespace query argument,
+// which limits them to the tasks of that namespace:
+//
+//   GET /          counts pending and failed tasks per namespace.
+//   GET /tasks     lists pending and failed tasks, or only those with the
+//                  status query argument.
+//   POST /retry    retries failed tasks on the next poll (see RetryFailed).
+//   POST /purge    removes failed tasks (see PurgeFailed).
+func AdminHandler(s *Store) http.Handler {
+	r := chi.NewRouter()
+
+	r.Get("/", handler.Wrap(func(w http.ResponseWriter, r *http.Request) error {
+		counts, err := s.Counts()
+		if err != nil {
+			return handler.Errorf("counts: %s", err)
+		}
+		if ns := r.URL.Query().Get("namespace"); ns != "" {
+			counts = map[string]NamespaceCounts{ns: counts[ns]}
+		}
+		return writeAdminJSON(w, map[string]interface{}{"namespaces": counts})
+	}))
+
+	r.Get("/tasks", handler.Wrap(func(w http.ResponseWriter, r *http.Request) error {
+		ns := r.URL.Query().Get("namespace")
+		statuses := []string{"pending", "failed"}
+		if status := r.URL.Query().Get("status"); status != "" {
+			if status != "pending" && status != "failed" {
+				return handler.Errorf("invalid status %q", status).Status(http.StatusBadRequest)
+			}
+			statuses = []string{status}
+		}
+		result := make(map[string][]adminTask)
+		for _, status := range statuses {
+			tasks, err := s.Find(NewStatusQuery(ns, status))
+			if err != nil {
+				return handler.Errorf("find %s tasks: %s", status, err)
+			}
+			result[status] = []adminTask{}
+			for _, t := range tasks {
+				t := t.(*Task)
+				result[status] = append(result[status], adminTask{
+					Namespace:   t.Namespace,
+					Name:        t.Name,
+					CreatedAt:   t.CreatedAt,
+					LastAttempt: t.LastAttempt,
+					Failures:    t.Failures,
+					Delay:       t.Delay.String(),
+				})
+			}
+		}
+		return writeAdminJSON(w, result)
+	}))
+
+	r.Post("/retry", handler.Wrap(func(w http.ResponseWriter, r *http.Request) error {
+		n, err := s.RetryFailed(r.URL.Query().Get("namespace"))
+		if err != nil {
+			return handler.Errorf("retry failed: %s", err)
+		}
+		return writeAdminJSON(w, map[string]int{"retried": n})
+	}))
+
+	r.Post("/purge", handler.Wrap(func(w http.ResponseWriter, r *http.Request) error {
+		n, err := s.PurgeFailed(r.URL.Query().Get("namespace"))
+		if err != nil {
+			return handler.Errorf("purge failed: %s", err)
+		}
+		return writeAdminJSON(w, map[string]int{"purged": n})
+	}))
+
+	return r
+}
+
+func writeAdminJSON(w http.ResponseWriter, v interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		return handler.Errorf("json encode: %s", err)
+	}
+	return nil
+}
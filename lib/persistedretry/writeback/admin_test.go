@@ -0,0 +1,86 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package writeback
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/uber/kraken/localdb"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminHandler(t *testing.T) {
+	require := require.New(t)
+
+	db, cleanup := localdb.Fixture()
+	defer cleanup()
+
+	store := NewStore(db)
+
+	require.NoError(store.AddFailed(NewTask("ns-a", "a", 0)))
+	require.NoError(store.AddFailed(NewTask("ns-b", "b", 0)))
+	require.NoError(store.AddPending(NewTask("ns-a", "c", 0)))
+
+	server := httptest.NewServer(AdminHandler(store))
+	defer server.Close()
+
+	do := func(method, path string, v interface{}) {
+		req, err := http.NewRequest(method, server.URL+path, nil)
+		require.NoError(err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(err)
+		defer resp.Body.Close()
+		require.Equal(http.StatusOK, resp.StatusCode)
+		require.NoError(json.NewDecoder(resp.Body).Decode(v))
+	}
+
+	var counts struct {
+		Namespaces map[string]NamespaceCounts `json:"namespaces"`
+	}
+	do("GET", "/", &counts)
+	require.Equal(map[string]NamespaceCounts{
+		"ns-a": {Pending: 1, Failed: 1},
+		"ns-b": {Failed: 1},
+	}, counts.Namespaces)
+
+	var tasks map[string][]adminTask
+	do("GET", "/tasks?namespace=ns-a", &tasks)
+	require.Len(tasks["pending"], 1)
+	require.Equal("c", tasks["pending"][0].Name)
+	require.Len(tasks["failed"], 1)
+	require.Equal("a", tasks["failed"][0].Name)
+
+	var retried map[string]int
+	do("POST", "/retry?namespace=ns-b", &retried)
+	require.Equal(map[string]int{"retried": 1}, retried)
+
+	var purged map[string]int
+	do("POST", "/purge?namespace=ns-a", &purged)
+	require.Equal(map[string]int{"purged": 1}, purged)
+
+	do("GET", "/", &counts)
+	require.Equal(map[string]NamespaceCounts{
+		"ns-a": {Pending: 1},
+		"ns-b": {Failed: 1},
+	}, counts.Namespaces)
+
+	resp, err := http.Get(server.URL + "/tasks?status=running")
+	require.NoError(err)
+	resp.Body.Close()
+	require.Equal(http.StatusBadRequest, resp.StatusCode)
+}
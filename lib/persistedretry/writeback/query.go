@@ -22,3 +22,16 @@ type NameQuery struct {
 func NewNameQuery(name string) *NameQuery {
 	return &NameQuery{name}
 }
+
+// StatusQuery queries writeback tasks with a status, i.e. "pending" or
+// "failed", in the order they were created.
+type StatusQuery struct {
+	namespace string
+	status    string
+}
+
+// NewStatusQuery returns a new StatusQuery. If namespace is empty, tasks of
+// all namespaces are matched.
+func NewStatusQuery(namespace, status string) *StatusQuery {
+	return &StatusQuery{namespace, status}
+}
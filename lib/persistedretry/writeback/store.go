@@ -14,6 +14,7 @@
 package writeback
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"time"
@@ -115,6 +116,13 @@ func (s *Store) Find(query interface{}) ([]persistedretry.Task, error) {
 			FROM writeback_task
 			WHERE name=?
 		`, q.name)
+	case *StatusQuery:
+		err = s.db.Select(&tasks, `
+			SELECT namespace, name, created_at, last_attempt, failures, delay
+			FROM writeback_task
+			WHERE status=? AND (?='' OR namespace=?)
+			ORDER BY created_at
+		`, q.status, q.namespace, q.namespace)
 	default:
 		return nil, errors.New("unknown query type")
 	}
@@ -124,6 +132,80 @@ func (s *Store) Find(query interface{}) ([]persistedretry.Task, error) {
 	return convert(tasks), nil
 }
 
+// NamespaceCounts is the number of pending and failed tasks of a namespace.
+type NamespaceCounts struct {
+	Pending int `json:"pending"`
+	Failed  int `json:"failed"`
+}
+
+// Counts returns the number of pending and failed tasks of every namespace
+// with tasks.
+func (s *Store) Counts() (map[string]NamespaceCounts, error) {
+	var rows []struct {
+		Namespace string `db:"namespace"`
+		Status    string `db:"status"`
+		Count     int    `db:"count"`
+	}
+	err := s.db.Select(&rows, `
+		SELECT namespace, status, COUNT(*) AS count
+		FROM writeback_task
+		GROUP BY namespace, status
+	`)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]NamespaceCounts)
+	for _, r := range rows {
+		c := counts[r.Namespace]
+		switch r.Status {
+		case "pending":
+			c.Pending = r.Count
+		case "failed":
+			c.Failed = r.Count
+		}
+		counts[r.Namespace] = c
+	}
+	return counts, nil
+}
+
+// RetryFailed clears the delay and last attempt of all failed tasks in
+// namespace, or in every namespace if namespace is empty, such that the next
+// retry poll executes them regardless of their backoff. Returns the number of
+// tasks affected.
+func (s *Store) RetryFailed(namespace string) (int, error) {
+	res, err := s.db.Exec(`
+		UPDATE writeback_task
+		SET last_attempt = ?, delay = 0
+		WHERE status = "failed" AND (?='' OR namespace=?)
+	`, time.Time{}, namespace, namespace)
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected(res), nil
+}
+
+// PurgeFailed removes all failed tasks in namespace, or in every namespace if
+// namespace is empty. Pending tasks are left alone, since they may be executing.
+// Returns the number of tasks removed.
+func (s *Store) PurgeFailed(namespace string) (int, error) {
+	res, err := s.db.Exec(`
+		DELETE FROM writeback_task
+		WHERE status = "failed" AND (?='' OR namespace=?)
+	`, namespace, namespace)
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected(res), nil
+}
+
+func rowsAffected(res sql.Result) int {
+	n, err := res.RowsAffected()
+	if err != nil {
+		panic("driver does not support RowsAffected")
+	}
+	return int(n)
+}
+
 func (s *Store) addWithStatus(r persistedretry.Task, status string) error {
 	query := fmt.Sprintf(`
 		INSERT INTO writeback_task (
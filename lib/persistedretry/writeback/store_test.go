@@ -260,3 +260,124 @@ func TestFindEmpty(t *testing.T) {
 	require.NoError(err)
 	require.Empty(result)
 }
+
+func TestFindStatus(t *testing.T) {
+	require := require.New(t)
+
+	db, cleanup := localdb.Fixture()
+	defer cleanup()
+
+	store := NewStore(db)
+
+	task1 := TaskFixture()
+	task2 := TaskFixture()
+	task3 := TaskFixture()
+	task3.Namespace = task2.Namespace
+
+	require.NoError(store.AddPending(task1))
+	require.NoError(store.AddFailed(task2))
+	require.NoError(store.AddPending(task3))
+
+	result, err := store.Find(NewStatusQuery("", "pending"))
+	require.NoError(err)
+	require.Len(result, 2)
+
+	result, err = store.Find(NewStatusQuery(task2.Namespace, "pending"))
+	require.NoError(err)
+	checkTasks(t, []*Task{task3}, result)
+
+	result, err = store.Find(NewStatusQuery(task1.Namespace, "failed"))
+	require.NoError(err)
+	require.Empty(result)
+}
+
+func TestCounts(t *testing.T) {
+	require := require.New(t)
+
+	db, cleanup := localdb.Fixture()
+	defer cleanup()
+
+	store := NewStore(db)
+
+	task1 := TaskFixture()
+	task2 := TaskFixture()
+	task3 := TaskFixture()
+	task3.Namespace = task2.Namespace
+
+	require.NoError(store.AddPending(task1))
+	require.NoError(store.AddFailed(task2))
+	require.NoError(store.AddPending(task3))
+
+	counts, err := store.Counts()
+	require.NoError(err)
+	require.Equal(map[string]NamespaceCounts{
+		task1.Namespace: {Pending: 1},
+		task2.Namespace: {Pending: 1, Failed: 1},
+	}, counts)
+}
+
+func TestRetryFailed(t *testing.T) {
+	require := require.New(t)
+
+	db, cleanup := localdb.Fixture()
+	defer cleanup()
+
+	store := NewStore(db)
+
+	task1 := NewTask("ns-a", "a", time.Hour)
+	task2 := NewTask("ns-b", "b", time.Hour)
+	task3 := NewTask("ns-a", "c", 0)
+
+	require.NoError(store.AddFailed(task1))
+	require.NoError(store.AddFailed(task2))
+	require.NoError(store.AddPending(task3))
+	require.NoError(store.MarkFailed(task1))
+
+	n, err := store.RetryFailed("ns-a")
+	require.NoError(err)
+	require.Equal(1, n)
+
+	result, err := store.Find(NewNameQuery("a"))
+	require.NoError(err)
+	require.Len(result, 1)
+	require.True(result[0].Ready())
+	require.True(result[0].GetLastAttempt().IsZero())
+	require.Equal(1, result[0].GetFailures())
+
+	result, err = store.Find(NewNameQuery("b"))
+	require.NoError(err)
+	require.False(result[0].Ready())
+
+	n, err = store.RetryFailed("")
+	require.NoError(err)
+	require.Equal(2, n)
+}
+
+func TestPurgeFailed(t *testing.T) {
+	require := require.New(t)
+
+	db, cleanup := localdb.Fixture()
+	defer cleanup()
+
+	store := NewStore(db)
+
+	task1 := NewTask("ns-a", "a", 0)
+	task2 := NewTask("ns-b", "b", 0)
+	task3 := NewTask("ns-a", "c", 0)
+
+	require.NoError(store.AddFailed(task1))
+	require.NoError(store.AddFailed(task2))
+	require.NoError(store.AddPending(task3))
+
+	n, err := store.PurgeFailed("ns-a")
+	require.NoError(err)
+	require.Equal(1, n)
+	checkFailed(t, store, task2)
+	checkPending(t, store, task3)
+
+	n, err = store.PurgeFailed("")
+	require.NoError(err)
+	require.Equal(1, n)
+	checkFailed(t, store)
+	checkPending(t, store, task3)
+}
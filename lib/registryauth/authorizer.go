@@ -0,0 +1,234 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package registryauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/uber/kraken/utils/log"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/docker/distribution/configuration"
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/uber-go/tally"
+)
+
+// _accessControllerName is the docker registry access controller backed by
+// an Authorizer.
+const _accessControllerName = "kraken"
+
+func init() {
+	auth.Register(_accessControllerName, auth.InitFunc(newAccessController))
+}
+
+// Challenge is returned when a request is not authorized. It carries the
+// WWW-Authenticate challenge of the Docker registry token auth flow.
+type Challenge struct {
+	realm   string
+	service string
+	scopes  []string
+	code    string
+	err     error
+}
+
+func (c *Challenge) Error() string {
+	return c.err.Error()
+}
+
+// Header returns the WWW-Authenticate header value of c.
+func (c *Challenge) Header() string {
+	h := fmt.Sprintf("Bearer realm=%q,service=%q", c.realm, c.service)
+	if len(c.scopes) > 0 {
+		h += fmt.Sprintf(",scope=%q", strings.Join(c.scopes, " "))
+	}
+	if c.code != "" {
+		h += fmt.Sprintf(",error=%q", c.code)
+	}
+	return h
+}
+
+// SetHeaders sets the challenge header on w.
+func (c *Challenge) SetHeaders(r *http.Request, w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", c.Header())
+}
+
+// Authorizer authorizes registry requests which carry bearer tokens issued by
+// a Docker registry token service or an OIDC provider.
+type Authorizer struct {
+	config    Config
+	stats     tally.Scope
+	clk       clock.Clock
+	verifier  *verifier
+	anonymous []*regexp.Regexp
+}
+
+// New creates a new Authorizer. If config is not enabled, the Authorizer
+// allows all requests.
+func New(config Config, stats tally.Scope, clk clock.Clock) (*Authorizer, error) {
+	config = config.applyDefaults()
+
+	stats = stats.Tagged(map[string]string{
+		"module": "registryauth",
+	})
+
+	a := &Authorizer{config: config, stats: stats, clk: clk}
+	if !config.Enabled {
+		return a, nil
+	}
+	if config.Realm == "" {
+		return nil, errors.New("no realm configured")
+	}
+	if config.Issuer == "" {
+		return nil, errors.New("no issuer configured")
+	}
+	for _, s := range config.AnonymousRepositories {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("anonymous repository %q: %s", s, err)
+		}
+		a.anonymous = append(a.anonymous, re)
+	}
+	a.verifier = &verifier{
+		issuer:   config.Issuer,
+		audience: config.Audience,
+		leeway:   config.Leeway,
+		keys:     newKeySet(config, clk),
+	}
+	return a, nil
+}
+
+// ConfigureRegistry configures the docker registry c to authorize requests
+// with a. It is a no-op if a is not enabled.
+func (a *Authorizer) ConfigureRegistry(c *configuration.Configuration) {
+	if !a.config.Enabled {
+		return
+	}
+	c.Auth = configuration.Auth{
+		_accessControllerName: configuration.Parameters{"authorizer": a},
+	}
+}
+
+// Authorize checks that r carries a token granting all of access. Returns the
+// subject of the token, which is empty for anonymous requests. Errors are of
+// type *Challenge.
+func (a *Authorizer) Authorize(r *http.Request, access ...auth.Access) (string, error) {
+	if !a.config.Enabled {
+		return "", nil
+	}
+	if a.isAnonymous(access) {
+		a.stats.Counter("anonymous").Inc(1)
+		return "", nil
+	}
+	subject, err := a.authorize(r, access)
+	if err != nil {
+		a.stats.Counter("unauthorized").Inc(1)
+		return "", err
+	}
+	a.stats.Counter("authorized").Inc(1)
+	return subject, nil
+}
+
+func (a *Authorizer) authorize(r *http.Request, access []auth.Access) (string, error) {
+	challenge := &Challenge{
+		realm:   a.config.Realm,
+		service: a.config.Service,
+	}
+	for _, x := range access {
+		challenge.scopes = append(challenge.scopes, formatScope(x))
+	}
+
+	h := r.Header.Get("Authorization")
+	if h == "" {
+		challenge.err = errors.New("no token")
+		return "", challenge
+	}
+	parts := strings.SplitN(h, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
+		challenge.code = "invalid_token"
+		challenge.err = errors.New("expected bearer token")
+		return "", challenge
+	}
+	c, err := a.verifier.verify(strings.TrimSpace(parts[1]), a.clk.Now())
+	if err != nil {
+		log.With("path", r.URL.Path).Infof("Rejecting registry token: %s", err)
+		challenge.code = "invalid_token"
+		challenge.err = fmt.Errorf("invalid token: %s", err)
+		return "", challenge
+	}
+	for _, x := range access {
+		if !c.grants(x) {
+			challenge.code = "insufficient_scope"
+			challenge.err = fmt.Errorf("token does not grant %s", formatScope(x))
+			return "", challenge
+		}
+	}
+	return c.Subject, nil
+}
+
+// isAnonymous returns true if every resource of access is a repository which
+// may be accessed without a token.
+func (a *Authorizer) isAnonymous(access []auth.Access) bool {
+	if len(access) == 0 || len(a.anonymous) == 0 {
+		return false
+	}
+	for _, x := range access {
+		if x.Type != "repository" || !a.matchesAnonymous(x.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *Authorizer) matchesAnonymous(repo string) bool {
+	for _, re := range a.anonymous {
+		if re.MatchString(repo) {
+			return true
+		}
+	}
+	return false
+}
+
+// accessController adapts an Authorizer into a docker registry access
+// controller.
+type accessController struct {
+	authorizer *Authorizer
+}
+
+func newAccessController(options map[string]interface{}) (auth.AccessController, error) {
+	a, ok := options["authorizer"].(*Authorizer)
+	if !ok {
+		return nil, errors.New("authorizer not provided")
+	}
+	return accessController{a}, nil
+}
+
+func (c accessController) Authorized(
+	ctx context.Context, access ...auth.Access) (context.Context, error) {
+
+	r, err := dcontext.GetRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	subject, err := c.authorizer.Authorize(r, access...)
+	if err != nil {
+		return nil, err
+	}
+	return auth.WithUser(ctx, auth.UserInfo{Name: subject}), nil
+}
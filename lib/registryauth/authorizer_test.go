@@ -0,0 +1,380 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package registryauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/utils/testutil"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/docker/distribution/configuration"
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+func pull(repo string) auth.Access {
+	return auth.Access{
+		Resource: auth.Resource{Type: "repository", Name: repo},
+		Action:   "pull",
+	}
+}
+
+func push(repo string) auth.Access {
+	return auth.Access{
+		Resource: auth.Resource{Type: "repository", Name: repo},
+		Action:   "push",
+	}
+}
+
+func requestFixture(token string) *http.Request {
+	r, err := http.NewRequest("GET", "/v2/", nil)
+	if err != nil {
+		panic(err)
+	}
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+func TestAuthorizeDisabled(t *testing.T) {
+	require := require.New(t)
+
+	a, err := New(Config{}, tally.NoopScope, clock.New())
+	require.NoError(err)
+
+	subject, err := a.Authorize(requestFixture(""), pull("foo/bar"))
+	require.NoError(err)
+	require.Empty(subject)
+
+	var c configuration.Configuration
+	a.ConfigureRegistry(&c)
+	require.Empty(c.Auth)
+}
+
+func TestAuthorizeValidToken(t *testing.T) {
+	require := require.New(t)
+
+	issuer, cleanup := NewIssuerFixture()
+	defer cleanup()
+
+	a, err := New(issuer.Config(), tally.NoopScope, clock.New())
+	require.NoError(err)
+
+	token := issuer.Token("some-user", pull("foo/bar"), push("foo/bar"))
+
+	subject, err := a.Authorize(requestFixture(token), pull("foo/bar"), push("foo/bar"))
+	require.NoError(err)
+	require.Equal("some-user", subject)
+}
+
+func TestAuthorizeChallenges(t *testing.T) {
+	issuer, cleanup := NewIssuerFixture()
+	defer cleanup()
+
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":    "test-issuer",
+			"sub":    "some-user",
+			"aud":    "kraken",
+			"exp":    time.Now().Add(time.Hour).Unix(),
+			"access": []resourceActions{{"repository", "foo/bar", []string{"pull"}}},
+		}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+	valid := issuer.Sign(claims(nil))
+
+	tests := []struct {
+		desc     string
+		header   string
+		access   []auth.Access
+		expected string
+	}{
+		{
+			"no token",
+			"",
+			[]auth.Access{pull("foo/bar")},
+			`Bearer realm="https://auth.example.com/token",service="kraken",scope="repository:foo/bar:pull"`,
+		}, {
+			"no token on base route",
+			"",
+			nil,
+			`Bearer realm="https://auth.example.com/token",service="kraken"`,
+		}, {
+			"basic auth",
+			"Basic dXNlcjpwYXNz",
+			[]auth.Access{pull("foo/bar")},
+			`Bearer realm="https://auth.example.com/token",service="kraken",scope="repository:foo/bar:pull",error="invalid_token"`,
+		}, {
+			"insufficient scope",
+			"Bearer " + valid,
+			[]auth.Access{pull("foo/bar"), push("foo/bar")},
+			`Bearer realm="https://auth.example.com/token",service="kraken",scope="repository:foo/bar:pull repository:foo/bar:push",error="insufficient_scope"`,
+		}, {
+			"other repository",
+			"Bearer " + valid,
+			[]auth.Access{pull("foo/baz")},
+			`Bearer realm="https://auth.example.com/token",service="kraken",scope="repository:foo/baz:pull",error="insufficient_scope"`,
+		}, {
+			"expired",
+			"Bearer " + issuer.Sign(claims(map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})),
+			[]auth.Access{pull("foo/bar")},
+			`Bearer realm="https://auth.example.com/token",service="kraken",scope="repository:foo/bar:pull",error="invalid_token"`,
+		}, {
+			"wrong issuer",
+			"Bearer " + issuer.Sign(claims(map[string]interface{}{"iss": "other-issuer"})),
+			[]auth.Access{pull("foo/bar")},
+			`Bearer realm="https://auth.example.com/token",service="kraken",scope="repository:foo/bar:pull",error="invalid_token"`,
+		}, {
+			"wrong audience",
+			"Bearer " + issuer.Sign(claims(map[string]interface{}{"aud": []string{"other"}})),
+			[]auth.Access{pull("foo/bar")},
+			`Bearer realm="https://auth.example.com/token",service="kraken",scope="repository:foo/bar:pull",error="invalid_token"`,
+		}, {
+			"tampered",
+			"Bearer " + valid[:len(valid)-4] + "AAAA",
+			[]auth.Access{pull("foo/bar")},
+			`Bearer realm="https://auth.example.com/token",service="kraken",scope="repository:foo/bar:pull",error="invalid_token"`,
+		}, {
+			"malformed",
+			"Bearer foo",
+			[]auth.Access{pull("foo/bar")},
+			`Bearer realm="https://auth.example.com/token",service="kraken",scope="repository:foo/bar:pull",error="invalid_token"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			a, err := New(issuer.Config(), tally.NoopScope, clock.New())
+			require.NoError(err)
+
+			r := requestFixture("")
+			if test.header != "" {
+				r.Header.Set("Authorization", test.header)
+			}
+			_, err = a.Authorize(r, test.access...)
+			require.Error(err)
+			c, ok := err.(*Challenge)
+			require.True(ok)
+			require.Equal(test.expected, c.Header())
+		})
+	}
+}
+
+func TestAuthorizeScopeClaim(t *testing.T) {
+	require := require.New(t)
+
+	issuer, cleanup := NewIssuerFixture()
+	defer cleanup()
+
+	a, err := New(issuer.Config(), tally.NoopScope, clock.New())
+	require.NoError(err)
+
+	token := issuer.Sign(map[string]interface{}{
+		"iss":   "test-issuer",
+		"sub":   "some-user",
+		"aud":   "kraken",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "openid repository:registry:5000/foo/bar:pull,push registry:catalog:*",
+	})
+
+	_, err = a.Authorize(requestFixture(token), pull("registry:5000/foo/bar"), push("registry:5000/foo/bar"))
+	require.NoError(err)
+
+	_, err = a.Authorize(requestFixture(token), auth.Access{
+		Resource: auth.Resource{Type: "registry", Name: "catalog"},
+		Action:   "*",
+	})
+	require.NoError(err)
+
+	_, err = a.Authorize(requestFixture(token), pull("foo/bar"))
+	require.Error(err)
+}
+
+func TestAuthorizeAnonymousRepositories(t *testing.T) {
+	require := require.New(t)
+
+	issuer, cleanup := NewIssuerFixture()
+	defer cleanup()
+
+	config := issuer.Config()
+	config.AnonymousRepositories = []string{"^infra/"}
+	a, err := New(config, tally.NoopScope, clock.New())
+	require.NoError(err)
+
+	_, err = a.Authorize(requestFixture(""), pull("infra/base"), push("infra/base"))
+	require.NoError(err)
+
+	// Every requested resource must be anonymous.
+	_, err = a.Authorize(requestFixture(""), pull("infra/base"), pull("foo/bar"))
+	require.Error(err)
+
+	// The base route is never anonymous, so clients discover the realm.
+	_, err = a.Authorize(requestFixture(""))
+	require.Error(err)
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	tests := []struct {
+		desc   string
+		config Config
+	}{
+		{"no realm", Config{Enabled: true, Issuer: "test-issuer"}},
+		{"no issuer", Config{Enabled: true, Realm: "https://auth.example.com/token"}},
+		{"invalid anonymous repository", Config{
+			Enabled:               true,
+			Realm:                 "https://auth.example.com/token",
+			Issuer:                "test-issuer",
+			AnonymousRepositories: []string{"("},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := New(test.config, tally.NoopScope, clock.New())
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestOIDCDiscoveryWithECKey(t *testing.T) {
+	require := require.New(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(err)
+
+	var issuerURL string
+	addr, stop := testutil.StartServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{"jwks_uri": issuerURL + "/keys"})
+		case "/keys":
+			json.NewEncoder(w).Encode(jwkSet{Keys: []jwk{{
+				Kty: "EC",
+				Kid: "ec-key",
+				Crv: "P-256",
+				X:   base64.RawURLEncoding.EncodeToString(key.X.Bytes()),
+				Y:   base64.RawURLEncoding.EncodeToString(key.Y.Bytes()),
+			}}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer stop()
+	issuerURL = fmt.Sprintf("http://%s", addr)
+
+	a, err := New(Config{
+		Enabled:  true,
+		Realm:    "https://auth.example.com/token",
+		Issuer:   issuerURL,
+		Audience: "some-client",
+	}, tally.NoopScope, clock.New())
+	require.NoError(err)
+
+	h, err := json.Marshal(header{Alg: "ES256", Kid: "ec-key"})
+	require.NoError(err)
+	c, err := json.Marshal(map[string]interface{}{
+		"iss":   issuerURL,
+		"sub":   "some-user",
+		"aud":   "some-client",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "repository:foo/bar:pull",
+	})
+	require.NoError(err)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	require.NoError(err)
+	sig := make([]byte, 64)
+	rb, sb := r.Bytes(), s.Bytes()
+	copy(sig[32-len(rb):32], rb)
+	copy(sig[64-len(sb):], sb)
+	token := signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+
+	subject, err := a.Authorize(requestFixture(token), pull("foo/bar"))
+	require.NoError(err)
+	require.Equal("some-user", subject)
+}
+
+func TestKeySetRefreshesOnUnknownKey(t *testing.T) {
+	require := require.New(t)
+
+	issuer, cleanup := NewIssuerFixture()
+	defer cleanup()
+
+	clk := clock.NewMock()
+	clk.Set(time.Now())
+	a, err := New(issuer.Config(), tally.NoopScope, clk)
+	require.NoError(err)
+
+	// Simulate a stale key set, fetched before the issuer rotated keys.
+	ks := a.verifier.keys
+	ks.keys = map[string]crypto.PublicKey{}
+	ks.lastFetch = clk.Now()
+	ks.lastAttempt = clk.Now()
+
+	token := issuer.Token("some-user", pull("foo/bar"))
+
+	// Refreshes are rate limited.
+	_, err = a.Authorize(requestFixture(token), pull("foo/bar"))
+	require.Error(err)
+
+	clk.Add(_minRefreshInterval)
+	_, err = a.Authorize(requestFixture(token), pull("foo/bar"))
+	require.NoError(err)
+}
+
+func TestAccessController(t *testing.T) {
+	require := require.New(t)
+
+	issuer, cleanup := NewIssuerFixture()
+	defer cleanup()
+
+	a, err := New(issuer.Config(), tally.NoopScope, clock.New())
+	require.NoError(err)
+
+	var config configuration.Configuration
+	a.ConfigureRegistry(&config)
+	for name, params := range config.Auth {
+		ac, err := auth.GetAccessController(name, params)
+		require.NoError(err)
+
+		r := requestFixture(issuer.Token("some-user", pull("foo/bar")))
+		ctx, err := ac.Authorized(dcontext.WithRequest(context.Background(), r), pull("foo/bar"))
+		require.NoError(err)
+		require.Equal("some-user", ctx.Value(auth.UserNameKey))
+
+		_, err = ac.Authorized(dcontext.WithRequest(context.Background(), r), push("foo/bar"))
+		_, ok := err.(auth.Challenge)
+		require.True(ok)
+	}
+	require.Len(config.Auth, 1)
+}
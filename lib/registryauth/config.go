@@ -0,0 +1,63 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package registryauth
+
+import "time"
+
+// Config defines Authorizer configuration.
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Realm is the token endpoint clients are sent to in the WWW-Authenticate
+	// challenge.
+	Realm string `yaml:"realm"`
+
+	// Service identifies this registry in challenges and token requests.
+	Service string `yaml:"service"`
+
+	// Issuer is the required "iss" claim of tokens.
+	Issuer string `yaml:"issuer"`
+
+	// Audience is the required "aud" claim of tokens. Defaults to Service.
+	Audience string `yaml:"audience"`
+
+	// JWKSURL serves the keys which tokens are signed with. If empty, it is
+	// discovered from the OpenID configuration of Issuer.
+	JWKSURL string `yaml:"jwks_url"`
+
+	// JWKSRefreshInterval is how often signing keys are re-fetched. Tokens
+	// signed by an unknown key trigger an early refresh.
+	JWKSRefreshInterval time.Duration `yaml:"jwks_refresh_interval"`
+
+	// Leeway is the clock skew tolerated when checking token expiry.
+	Leeway time.Duration `yaml:"leeway"`
+
+	// AnonymousRepositories is a list of regexps matching repositories which
+	// may be accessed without a token, e.g. "^infra/.*". This allows
+	// namespaces to be migrated to authenticated access gradually.
+	AnonymousRepositories []string `yaml:"anonymous_repositories"`
+}
+
+func (c Config) applyDefaults() Config {
+	if c.Audience == "" {
+		c.Audience = c.Service
+	}
+	if c.JWKSRefreshInterval == 0 {
+		c.JWKSRefreshInterval = 15 * time.Minute
+	}
+	if c.Leeway == 0 {
+		c.Leeway = time.Minute
+	}
+	return c
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package registryauth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/uber/kraken/utils/testutil"
+
+	"github.com/docker/distribution/registry/auth"
+)
+
+// IssuerFixture issues signed tokens for testing purposes.
+type IssuerFixture struct {
+	key    *rsa.PrivateKey
+	config Config
+}
+
+// NewIssuerFixture creates a new IssuerFixture which serves its signing key
+// over JWKS.
+func NewIssuerFixture() (*IssuerFixture, func()) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	set := jwkSet{Keys: []jwk{{
+		Kty: "RSA",
+		Kid: "test-key",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	addr, stop := testutil.StartServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(set)
+	}))
+	return &IssuerFixture{
+		key: key,
+		config: Config{
+			Enabled: true,
+			Realm:   "https://auth.example.com/token",
+			Service: "kraken",
+			Issuer:  "test-issuer",
+			JWKSURL: fmt.Sprintf("http://%s/jwks", addr),
+		},
+	}, stop
+}
+
+// Config returns configuration which trusts tokens issued by f.
+func (f *IssuerFixture) Config() Config {
+	return f.config
+}
+
+// Token issues a token for subject which grants access.
+func (f *IssuerFixture) Token(subject string, access ...auth.Access) string {
+	var ras []resourceActions
+	for _, a := range access {
+		ras = append(ras, resourceActions{
+			Type:    a.Type,
+			Name:    a.Name,
+			Actions: []string{a.Action},
+		})
+	}
+	return f.Sign(map[string]interface{}{
+		"iss":    f.config.Issuer,
+		"sub":    subject,
+		"aud":    f.config.Service,
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"access": ras,
+	})
+}
+
+// Sign signs arbitrary claims with the key of f.
+func (f *IssuerFixture) Sign(claims map[string]interface{}) string {
+	h, err := json.Marshal(header{Alg: "RS256", Kid: "test-key"})
+	if err != nil {
+		panic(err)
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		panic(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." +
+		base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, f.key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
@@ -0,0 +1,226 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package registryauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber/kraken/utils/httputil"
+	"github.com/uber/kraken/utils/log"
+
+	"github.com/andres-erbsen/clock"
+)
+
+// _minRefreshInterval rate limits refreshes triggered by unknown key ids.
+const _minRefreshInterval = 30 * time.Second
+
+const _fetchTimeout = 10 * time.Second
+
+var errUnknownKey = errors.New("unknown signing key")
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// publicKey parses k into an *rsa.PublicKey or *ecdsa.PublicKey.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("n: %s", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("e: %s", err)
+		}
+		if !e.IsInt64() {
+			return nil, errors.New("e: too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("x: %s", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("y: %s", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// keySet caches the signing keys served by a JWKS endpoint.
+type keySet struct {
+	issuer          string
+	url             string
+	refreshInterval time.Duration
+	clk             clock.Clock
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	lastFetch   time.Time
+	lastAttempt time.Time
+}
+
+func newKeySet(config Config, clk clock.Clock) *keySet {
+	return &keySet{
+		issuer:          config.Issuer,
+		url:             config.JWKSURL,
+		refreshInterval: config.JWKSRefreshInterval,
+		clk:             clk,
+	}
+}
+
+// get returns the key identified by kid. An empty kid matches any key if the
+// set contains exactly one.
+func (s *keySet) get(kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clk.Now()
+	if s.keys == nil || now.Sub(s.lastFetch) >= s.refreshInterval {
+		if err := s.refresh(now); err != nil {
+			if s.keys == nil {
+				return nil, err
+			}
+			// Keep serving stale keys until the endpoint recovers.
+			log.With("url", s.url).Errorf("Error refreshing JWKS: %s", err)
+		}
+	}
+	if k, ok := s.lookup(kid); ok {
+		return k, nil
+	}
+	// The issuer may have rotated keys since the last refresh.
+	if now.Sub(s.lastAttempt) >= _minRefreshInterval {
+		if err := s.refresh(now); err != nil {
+			return nil, err
+		}
+		if k, ok := s.lookup(kid); ok {
+			return k, nil
+		}
+	}
+	return nil, errUnknownKey
+}
+
+func (s *keySet) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, k := range s.keys {
+			return k, true
+		}
+	}
+	k, ok := s.keys[kid]
+	return k, ok
+}
+
+func (s *keySet) refresh(now time.Time) error {
+	s.lastAttempt = now
+	if s.url == "" {
+		u, err := discoverJWKSURL(s.issuer)
+		if err != nil {
+			return fmt.Errorf("discover jwks url: %s", err)
+		}
+		s.url = u
+	}
+	var set jwkSet
+	if err := getJSON(s.url, &set); err != nil {
+		return fmt.Errorf("fetch jwks: %s", err)
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		pub, err := k.publicKey()
+		if err != nil {
+			// Unsupported keys may be served for other consumers.
+			log.With("kid", k.Kid).Infof("Skipping JWKS key: %s", err)
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	s.keys = keys
+	s.lastFetch = now
+	return nil
+}
+
+// discoverJWKSURL reads the jwks_uri from the OpenID configuration of issuer.
+func discoverJWKSURL(issuer string) (string, error) {
+	var c struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	u := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	if err := getJSON(u, &c); err != nil {
+		return "", err
+	}
+	if c.JWKSURI == "" {
+		return "", errors.New("no jwks_uri in openid configuration")
+	}
+	return c.JWKSURI, nil
+}
+
+func getJSON(u string, v interface{}) error {
+	resp, err := httputil.Get(u, httputil.SendTimeout(_fetchTimeout))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package registryauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/registry/auth"
+)
+
+// audience is the "aud" claim, which may be either a string or a list.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	var l []string
+	if err := json.Unmarshal(b, &l); err != nil {
+		return err
+	}
+	*a = l
+	return nil
+}
+
+func (a audience) contains(s string) bool {
+	for _, x := range a {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceActions is an entry of the Docker token "access" claim.
+type resourceActions struct {
+	Type    string   `json:"type"`
+	Name    string   `json:"name"`
+	Actions []string `json:"actions"`
+}
+
+type claims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+
+	// Access holds the scopes granted by Docker registry tokens.
+	Access []resourceActions `json:"access"`
+
+	// Scope holds OAuth2 scopes, which may also carry Docker resource scopes
+	// such as "repository:foo/bar:pull".
+	Scope string `json:"scope"`
+}
+
+// grants returns true if c grants the requested access.
+func (c *claims) grants(a auth.Access) bool {
+	for _, ra := range c.Access {
+		if ra.Type == a.Type && ra.Name == a.Name && hasAction(ra.Actions, a.Action) {
+			return true
+		}
+	}
+	for _, s := range strings.Fields(c.Scope) {
+		ra, ok := parseScope(s)
+		if ok && ra.Type == a.Type && ra.Name == a.Name && hasAction(ra.Actions, a.Action) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAction(actions []string, action string) bool {
+	for _, x := range actions {
+		if x == action || x == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseScope parses a scope of the form "type:name:action1,action2". Names
+// may contain colons, e.g. when prefixed with a registry host and port.
+func parseScope(s string) (resourceActions, bool) {
+	i := strings.Index(s, ":")
+	j := strings.LastIndex(s, ":")
+	if i < 0 || i == j {
+		return resourceActions{}, false
+	}
+	return resourceActions{
+		Type:    s[:i],
+		Name:    s[i+1 : j],
+		Actions: strings.Split(s[j+1:], ","),
+	}, true
+}
+
+// formatScope formats a as a scope of the form "type:name:action".
+func formatScope(a auth.Access) string {
+	return fmt.Sprintf("%s:%s:%s", a.Type, a.Name, a.Action)
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// verifier verifies the signatures and standard claims of JWTs.
+type verifier struct {
+	issuer   string
+	audience string
+	leeway   time.Duration
+	keys     *keySet
+}
+
+// verify parses and verifies the compact serialized JWT raw.
+func (v *verifier) verify(raw string, now time.Time) (*claims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, fmt.Errorf("header: %s", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("signature: %s", err)
+	}
+	key, err := v.keys.get(h.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(h.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, fmt.Errorf("claims: %s", err)
+	}
+	if c.Issuer != v.issuer {
+		return nil, fmt.Errorf("invalid issuer %q", c.Issuer)
+	}
+	if v.audience != "" && !c.Audience.contains(v.audience) {
+		return nil, fmt.Errorf("invalid audience %q", c.Audience)
+	}
+	if c.ExpiresAt == 0 {
+		return nil, errors.New("no expiry")
+	}
+	if now.After(time.Unix(c.ExpiresAt, 0).Add(v.leeway)) {
+		return nil, errors.New("token expired")
+	}
+	if c.NotBefore != 0 && now.Add(v.leeway).Before(time.Unix(c.NotBefore, 0)) {
+		return nil, errors.New("token not yet valid")
+	}
+	return &c, nil
+}
+
+func decodeSegment(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// verifySignature verifies sig over signed with key, using the JWS algorithm
+// alg. Only asymmetric algorithms are supported, since keys are public.
+func verifySignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported alg %q", alg)
+	}
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported alg %q", alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			if err := rsa.VerifyPKCS1v15(k, hash, digest, sig); err != nil {
+				return errors.New("invalid signature")
+			}
+			return nil
+		case "PS":
+			opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}
+			if err := rsa.VerifyPSS(k, hash, digest, sig, opts); err != nil {
+				return errors.New("invalid signature")
+			}
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		if alg[:2] == "ES" {
+			n := (k.Curve.Params().BitSize + 7) / 8
+			if len(sig) != 2*n {
+				return errors.New("invalid signature")
+			}
+			r := new(big.Int).SetBytes(sig[:n])
+			s := new(big.Int).SetBytes(sig[n:])
+			if !ecdsa.Verify(k, digest, r, s) {
+				return errors.New("invalid signature")
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("alg %q does not match key type", alg)
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package signature
+
+import "time"
+
+// Mode defines how tags without a valid signature are handled.
+type Mode string
+
+// Modes.
+const (
+	// ModeOff skips verification.
+	ModeOff Mode = "off"
+
+	// ModeWarn verifies signatures, but only logs and counts tags without a
+	// valid signature.
+	ModeWarn Mode = "warn"
+
+	// ModeEnforce rejects tags without a valid signature.
+	ModeEnforce Mode = "enforce"
+)
+
+// Config defines Verifier configuration.
+type Config struct {
+	// Keys are PEM encoded public keys. Tags must be signed by any of them.
+	Keys []string `yaml:"keys"`
+
+	// KeyFiles are paths of files holding PEM encoded public keys, in addition
+	// to Keys.
+	KeyFiles []string `yaml:"key_files"`
+
+	// Policies set the Mode of namespaces. The first policy whose namespace
+	// regexp matches the repository of a tag applies.
+	Policies []PolicyConfig `yaml:"policies"`
+
+	// DefaultMode applies to namespaces no policy matches. Defaults to off.
+	DefaultMode Mode `yaml:"default_mode"`
+
+	// CacheTTL is the duration verification results of a digest are cached.
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+
+	// ErrorTTL is the duration failed verifications of a digest are cached,
+	// such that newly pushed signatures are picked up quickly.
+	ErrorTTL time.Duration `yaml:"error_ttl"`
+}
+
+// PolicyConfig defines the Mode of namespaces matching a regexp.
+type PolicyConfig struct {
+	Namespace string `yaml:"namespace"`
+	Mode      Mode   `yaml:"mode"`
+}
+
+func (c Config) applyDefaults() Config {
+	if c.DefaultMode == "" {
+		c.DefaultMode = ModeOff
+	}
+	if c.CacheTTL == 0 {
+		c.CacheTTL = 5 * time.Minute
+	}
+	if c.ErrorTTL == 0 {
+		c.ErrorTTL = 10 * time.Second
+	}
+	return c
+}
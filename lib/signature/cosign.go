@@ -0,0 +1,158 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/uber/kraken/core"
+)
+
+// Cosign stores the signatures of a manifest as layers of another manifest,
+// tagged sha256-<hex>.sig in the same repository. Each layer is a payload
+// naming the signed manifest digest, and carries the signature of that
+// payload in an annotation.
+const _cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+// _cosignTagRegexp matches the tags cosign attaches signatures, attestations
+// and SBOMs under, which are not signed themselves.
+var _cosignTagRegexp = regexp.MustCompile(`^sha256-[0-9a-f]{64}\.(sig|att|sbom)$`)
+
+// _cosignMediaTypes are the layer media types of cosign signatures,
+// attestations and SBOMs.
+var _cosignMediaTypes = map[string]bool{
+	"application/vnd.dev.cosign.simplesigning.v1+json": true,
+	"application/vnd.dsse.envelope.v1+json":            true,
+	"application/vnd.in-toto+json":                     true,
+	"text/spdx":                                        true,
+	"text/spdx+json":                                   true,
+	"application/vnd.cyclonedx":                        true,
+	"application/vnd.cyclonedx+json":                   true,
+	"application/vnd.syft+json":                        true,
+}
+
+// signatureTag returns the tag cosign stores the signatures of d in repo
+// under.
+func signatureTag(repo string, d core.Digest) string {
+	return fmt.Sprintf("%s:%s-%s.sig", repo, d.Algo(), d.Hex())
+}
+
+// splitTag splits tag into its repository and tag name.
+func splitTag(tag string) (repo, name string, err error) {
+	i := strings.LastIndex(tag, ":")
+	if i == -1 || strings.Contains(tag[i:], "/") {
+		return "", "", fmt.Errorf("invalid tag %q", tag)
+	}
+	return tag[:i], tag[i+1:], nil
+}
+
+type cosignManifest struct {
+	Layers []struct {
+		MediaType   string            `json:"mediaType"`
+		Digest      string            `json:"digest"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"layers"`
+}
+
+type cosignPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+// checkPayload checks that payload names d as the signed manifest.
+func checkPayload(payload []byte, d core.Digest) error {
+	var p cosignPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return fmt.Errorf("unmarshal payload: %s", err)
+	}
+	if p.Critical.Image.DockerManifestDigest != d.String() {
+		return fmt.Errorf(
+			"payload signs %q, not %s", p.Critical.Image.DockerManifestDigest, d)
+	}
+	return nil
+}
+
+// parseKeys parses the PEM encoded public keys in b.
+func parseKeys(b []byte) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse public key: %s", err)
+		}
+		switch key.(type) {
+		case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		default:
+			return nil, fmt.Errorf("unsupported public key type %T", key)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no PEM encoded public key found")
+	}
+	return keys, nil
+}
+
+// verifySignature verifies the base64 encoded sig of payload against any of
+// keys, as produced by cosign for the respective key type.
+func verifySignature(keys []crypto.PublicKey, payload []byte, sig string) error {
+	b, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		return fmt.Errorf("decode signature: %s", err)
+	}
+	h := sha256.Sum256(payload)
+	for _, key := range keys {
+		switch k := key.(type) {
+		case *ecdsa.PublicKey:
+			var es struct{ R, S *big.Int }
+			if rest, err := asn1.Unmarshal(b, &es); err != nil || len(rest) != 0 {
+				continue
+			}
+			if ecdsa.Verify(k, h[:], es.R, es.S) {
+				return nil
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(k, crypto.SHA256, h[:], b) == nil {
+				return nil
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(k, payload, b) {
+				return nil
+			}
+		}
+	}
+	return errors.New("signature does not match any key")
+}
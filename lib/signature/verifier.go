@@ -0,0 +1,333 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package signature
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"time"
+
+	"github.com/uber/kraken/build-index/tagclient"
+	"github.com/uber/kraken/build-index/tagstore"
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/origin/blobclient"
+	"github.com/uber/kraken/utils/dedup"
+	"github.com/uber/kraken/utils/errutil"
+	"github.com/uber/kraken/utils/log"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/uber-go/tally"
+)
+
+// Operations tags are verified for.
+const (
+	OpPush      = "push"
+	OpServe     = "serve"
+	OpReplicate = "replicate"
+)
+
+// Verifier gates tags on image signatures.
+type Verifier interface {
+	// Verify verifies that d, the manifest tag points to, is signed if the
+	// policy of the repository of tag requires it. Only returns errors if the
+	// policy is enforced, in which case tags without a valid signature fail
+	// with a RejectedError.
+	Verify(tag string, d core.Digest, op string) error
+}
+
+// RejectedError is returned when a manifest has no valid signature.
+type RejectedError struct {
+	Digest core.Digest
+	Reason error
+}
+
+func (e RejectedError) Error() string {
+	return fmt.Sprintf("no valid signature of %s: %s", e.Digest, e.Reason)
+}
+
+// IsRejected returns true if err is a RejectedError.
+func IsRejected(err error) bool {
+	_, ok := err.(RejectedError)
+	return ok
+}
+
+// TagResolver resolves tags to manifest digests.
+type TagResolver interface {
+	Get(tag string) (core.Digest, error)
+}
+
+// BlobDownloader downloads blobs.
+type BlobDownloader interface {
+	DownloadBlob(namespace string, d core.Digest, dst io.Writer) error
+}
+
+type policy struct {
+	namespace *regexp.Regexp
+	mode      Mode
+}
+
+type verifier struct {
+	config   Config
+	stats    tally.Scope
+	keys     []crypto.PublicKey
+	policies []policy
+	tags     TagResolver
+	blobs    BlobDownloader
+	results  *dedup.Limiter
+}
+
+// New creates a new Verifier, which looks up signatures via tags and
+// downloads them via blobs.
+func New(
+	config Config,
+	stats tally.Scope,
+	tags TagResolver,
+	blobs BlobDownloader) (Verifier, error) {
+
+	config = config.applyDefaults()
+
+	stats = stats.Tagged(map[string]string{
+		"module": "signature",
+	})
+
+	modes := []Mode{config.DefaultMode}
+	var policies []policy
+	for _, p := range config.Policies {
+		re, err := regexp.Compile(p.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace regexp %q: %s", p.Namespace, err)
+		}
+		policies = append(policies, policy{re, p.Mode})
+		modes = append(modes, p.Mode)
+	}
+	var verifies bool
+	for _, m := range modes {
+		switch m {
+		case ModeOff:
+		case ModeWarn, ModeEnforce:
+			verifies = true
+		default:
+			return nil, fmt.Errorf("invalid mode %q", m)
+		}
+	}
+
+	var keys []crypto.PublicKey
+	for _, k := range config.Keys {
+		ks, err := parseKeys([]byte(k))
+		if err != nil {
+			return nil, fmt.Errorf("keys: %s", err)
+		}
+		keys = append(keys, ks...)
+	}
+	for _, path := range config.KeyFiles {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read key file: %s", err)
+		}
+		ks, err := parseKeys(b)
+		if err != nil {
+			return nil, fmt.Errorf("key file %s: %s", path, err)
+		}
+		keys = append(keys, ks...)
+	}
+	if verifies && len(keys) == 0 {
+		return nil, errors.New("no public keys configured")
+	}
+
+	v := &verifier{
+		config:   config,
+		stats:    stats,
+		keys:     keys,
+		policies: policies,
+		tags:     tags,
+		blobs:    blobs,
+	}
+	v.results = dedup.NewLimiter(clock.New(), &verifications{v})
+	return v, nil
+}
+
+func (v *verifier) mode(repo string) Mode {
+	for _, p := range v.policies {
+		if p.namespace.MatchString(repo) {
+			return p.mode
+		}
+	}
+	return v.config.DefaultMode
+}
+
+func (v *verifier) Verify(tag string, d core.Digest, op string) error {
+	repo, name, err := splitTag(tag)
+	if err != nil {
+		repo = tag
+	}
+	mode := v.mode(repo)
+	if mode == ModeOff {
+		return nil
+	}
+	if _cosignTagRegexp.MatchString(name) && v.isCosignArtifact(repo, d) {
+		// Signatures are verified when the tags they sign are.
+		return nil
+	}
+	stats := v.stats.Tagged(map[string]string{
+		"op":   op,
+		"mode": string(mode),
+	})
+	err = v.results.Run(verification{repo, d}).(*verificationResult).err
+	if err == nil {
+		stats.Counter("verified").Inc(1)
+		return nil
+	}
+	l := log.With("tag", tag, "digest", d, "op", op)
+	if mode == ModeWarn {
+		stats.Counter("unsigned").Inc(1)
+		l.Warnf("Signature verification failed: %s", err)
+		return nil
+	}
+	if IsRejected(err) {
+		stats.Counter("rejected").Inc(1)
+		l.Infof("Rejected tag: %s", err)
+	} else {
+		stats.Counter("verify_errors").Inc(1)
+		l.Errorf("Error verifying signature: %s", err)
+	}
+	return err
+}
+
+// isCosignArtifact returns true if manifest d in repo is a cosign signature,
+// attestation or SBOM, i.e. all of its layers are cosign artifacts. Images
+// pushed under cosign tags are verified like any other.
+func (v *verifier) isCosignArtifact(repo string, d core.Digest) bool {
+	b, err := v.download(repo, d)
+	if err != nil {
+		return false
+	}
+	var m cosignManifest
+	if err := json.Unmarshal(b, &m); err != nil || len(m.Layers) == 0 {
+		return false
+	}
+	for _, layer := range m.Layers {
+		if !_cosignMediaTypes[layer.MediaType] {
+			return false
+		}
+	}
+	return true
+}
+
+// verify verifies the cosign signatures of manifest d in repo. Returns
+// RejectedError if none of them is valid, and plain errors if they could not
+// be downloaded.
+func (v *verifier) verify(repo string, d core.Digest) error {
+	sigTag := signatureTag(repo, d)
+	sd, err := v.tags.Get(sigTag)
+	if err != nil {
+		if err == tagstore.ErrTagNotFound || err == tagclient.ErrTagNotFound {
+			return RejectedError{d, fmt.Errorf("signature tag %s not found", sigTag)}
+		}
+		return fmt.Errorf("resolve signature tag %s: %s", sigTag, err)
+	}
+	b, err := v.download(repo, sd)
+	if err != nil {
+		if err == blobclient.ErrBlobNotFound {
+			return RejectedError{d, fmt.Errorf("signature manifest %s not found", sd)}
+		}
+		return fmt.Errorf("download signature manifest %s: %s", sd, err)
+	}
+	var m cosignManifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return RejectedError{d, fmt.Errorf("unmarshal signature manifest %s: %s", sd, err)}
+	}
+	var errs []error
+	for _, layer := range m.Layers {
+		sig, ok := layer.Annotations[_cosignSignatureAnnotation]
+		if !ok {
+			continue
+		}
+		pd, err := core.ParseSHA256Digest(layer.Digest)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("parse payload digest: %s", err))
+			continue
+		}
+		payload, err := v.download(repo, pd)
+		if err != nil {
+			if err == blobclient.ErrBlobNotFound {
+				errs = append(errs, fmt.Errorf("payload %s not found", pd))
+				continue
+			}
+			return fmt.Errorf("download payload %s: %s", pd, err)
+		}
+		if err := verifySignature(v.keys, payload, sig); err != nil {
+			errs = append(errs, fmt.Errorf("payload %s: %s", pd, err))
+			continue
+		}
+		if err := checkPayload(payload, d); err != nil {
+			errs = append(errs, fmt.Errorf("payload %s: %s", pd, err))
+			continue
+		}
+		return nil
+	}
+	if len(errs) == 0 {
+		return RejectedError{d, fmt.Errorf("no signatures in %s", sd)}
+	}
+	return RejectedError{d, errutil.Join(errs)}
+}
+
+func (v *verifier) download(namespace string, d core.Digest) ([]byte, error) {
+	var b bytes.Buffer
+	if err := v.blobs.DownloadBlob(namespace, d, &b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+type verification struct {
+	repo   string
+	digest core.Digest
+}
+
+type verificationResult struct {
+	err error
+}
+
+// verifications caches verification results, such that serving popular tags
+// does not download their signatures on every request.
+type verifications struct {
+	verifier *verifier
+}
+
+func (s *verifications) Run(input interface{}) (interface{}, time.Duration) {
+	in := input.(verification)
+	err := s.verifier.verify(in.repo, in.digest)
+	ttl := s.verifier.config.CacheTTL
+	if err != nil {
+		ttl = s.verifier.config.ErrorTTL
+	}
+	return &verificationResult{err}, ttl
+}
+
+type disabledVerifier struct{}
+
+// Disabled returns a Verifier which accepts all tags.
+func Disabled() Verifier {
+	return disabledVerifier{}
+}
+
+func (disabledVerifier) Verify(tag string, d core.Digest, op string) error {
+	return nil
+}
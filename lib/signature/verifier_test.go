@@ -0,0 +1,288 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package signature
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/uber/kraken/build-index/tagstore"
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/origin/blobclient"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+const _repo = "some/repo"
+
+type fakeRegistry struct {
+	tags  map[string]core.Digest
+	blobs map[core.Digest][]byte
+	err   error
+}
+
+func newFakeRegistry() *fakeRegistry {
+	return &fakeRegistry{
+		tags:  make(map[string]core.Digest),
+		blobs: make(map[core.Digest][]byte),
+	}
+}
+
+func (r *fakeRegistry) Get(tag string) (core.Digest, error) {
+	if r.err != nil {
+		return core.Digest{}, r.err
+	}
+	d, ok := r.tags[tag]
+	if !ok {
+		return core.Digest{}, tagstore.ErrTagNotFound
+	}
+	return d, nil
+}
+
+func (r *fakeRegistry) DownloadBlob(namespace string, d core.Digest, dst io.Writer) error {
+	b, ok := r.blobs[d]
+	if !ok {
+		return blobclient.ErrBlobNotFound
+	}
+	_, err := dst.Write(b)
+	return err
+}
+
+func (r *fakeRegistry) put(b []byte) core.Digest {
+	d, err := core.NewDigester().FromBytes(b)
+	if err != nil {
+		panic(err)
+	}
+	r.blobs[d] = b
+	return d
+}
+
+type keyFixture struct {
+	key *ecdsa.PrivateKey
+	pem string
+}
+
+func newKeyFixture(t *testing.T) *keyFixture {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	b, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	return &keyFixture{
+		key: key,
+		pem: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: b})),
+	}
+}
+
+// sign pushes a cosign signature of d, signed by k, to r.
+func (k *keyFixture) sign(t *testing.T, r *fakeRegistry, d core.Digest) {
+	payload := []byte(fmt.Sprintf(
+		`{"critical":{"identity":{"docker-reference":%q},"image":{"docker-manifest-digest":%q},`+
+			`"type":"cosign container image signature"},"optional":null}`, _repo, d))
+	h := sha256.Sum256(payload)
+	er, es, err := ecdsa.Sign(rand.Reader, k.key, h[:])
+	require.NoError(t, err)
+	sig, err := asn1.Marshal(struct{ R, S *big.Int }{er, es})
+	require.NoError(t, err)
+
+	manifest := map[string]interface{}{
+		"schemaVersion": 2,
+		"layers": []interface{}{map[string]interface{}{
+			"mediaType": "application/vnd.dev.cosign.simplesigning.v1+json",
+			"digest":    r.put(payload).String(),
+			"annotations": map[string]string{
+				_cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(sig),
+			},
+		}},
+	}
+	b, err := json.Marshal(manifest)
+	require.NoError(t, err)
+	r.tags[signatureTag(_repo, d)] = r.put(b)
+}
+
+func newVerifier(t *testing.T, mode Mode, r *fakeRegistry, keys ...string) Verifier {
+	v, err := New(Config{
+		Keys:     keys,
+		Policies: []PolicyConfig{{Namespace: "some/.*", Mode: mode}},
+	}, tally.NoopScope, r, r)
+	require.NoError(t, err)
+	return v
+}
+
+func TestVerifyValidSignature(t *testing.T) {
+	k := newKeyFixture(t)
+	r := newFakeRegistry()
+	d := r.put([]byte("manifest"))
+	k.sign(t, r, d)
+
+	v := newVerifier(t, ModeEnforce, r, k.pem)
+	require.NoError(t, v.Verify(_repo+":latest", d, OpServe))
+}
+
+func TestVerifyRejectsInvalidSignatures(t *testing.T) {
+	tests := []struct {
+		desc  string
+		setup func(k *keyFixture, r *fakeRegistry, d core.Digest)
+	}{
+		{"unsigned", func(k *keyFixture, r *fakeRegistry, d core.Digest) {}},
+		{"other key", func(k *keyFixture, r *fakeRegistry, d core.Digest) {
+			newKeyFixture(t).sign(t, r, d)
+		}},
+		{"signed other manifest", func(k *keyFixture, r *fakeRegistry, d core.Digest) {
+			other := r.put([]byte("other manifest"))
+			k.sign(t, r, other)
+			r.tags[signatureTag(_repo, d)] = r.tags[signatureTag(_repo, other)]
+		}},
+		{"signature manifest missing", func(k *keyFixture, r *fakeRegistry, d core.Digest) {
+			r.tags[signatureTag(_repo, d)] = core.DigestFixture()
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			k := newKeyFixture(t)
+			r := newFakeRegistry()
+			d := r.put([]byte("manifest"))
+			test.setup(k, r, d)
+
+			err := newVerifier(t, ModeEnforce, r, k.pem).Verify(_repo+":latest", d, OpServe)
+			require.Error(t, err)
+			require.True(t, IsRejected(err))
+
+			require.NoError(t, newVerifier(t, ModeWarn, r, k.pem).Verify(_repo+":latest", d, OpServe))
+			require.NoError(t, newVerifier(t, ModeOff, r).Verify(_repo+":latest", d, OpServe))
+		})
+	}
+}
+
+func TestVerifyRejectsTamperedPayload(t *testing.T) {
+	k := newKeyFixture(t)
+	r := newFakeRegistry()
+	d := r.put([]byte("manifest"))
+	k.sign(t, r, d)
+
+	// Swap the signature of d with one over a different payload.
+	other := r.put([]byte("other manifest"))
+	k.sign(t, r, other)
+	var m map[string]interface{}
+	require.NoError(t, json.Unmarshal(r.blobs[r.tags[signatureTag(_repo, other)]], &m))
+	layer := m["layers"].([]interface{})[0].(map[string]interface{})
+	var sigManifest map[string]interface{}
+	require.NoError(t, json.Unmarshal(r.blobs[r.tags[signatureTag(_repo, d)]], &sigManifest))
+	sigManifest["layers"].([]interface{})[0].(map[string]interface{})["annotations"] = layer["annotations"]
+	b, err := json.Marshal(sigManifest)
+	require.NoError(t, err)
+	r.tags[signatureTag(_repo, d)] = r.put(b)
+
+	err = newVerifier(t, ModeEnforce, r, k.pem).Verify(_repo+":latest", d, OpServe)
+	require.True(t, IsRejected(err))
+}
+
+func TestVerifySkipsSignatureTags(t *testing.T) {
+	k := newKeyFixture(t)
+	r := newFakeRegistry()
+	d := r.put([]byte("manifest"))
+
+	k.sign(t, r, d)
+
+	v := newVerifier(t, ModeEnforce, r, k.pem)
+	require.NoError(t, v.Verify(signatureTag(_repo, d), r.tags[signatureTag(_repo, d)], OpPush))
+}
+
+func TestVerifyRejectsUnsignedImagesUnderSignatureTags(t *testing.T) {
+	k := newKeyFixture(t)
+	r := newFakeRegistry()
+	d := r.put([]byte(`{"schemaVersion":2,"layers":[{` +
+		`"mediaType":"application/vnd.docker.image.rootfs.diff.tar.gzip",` +
+		`"digest":"sha256:0000000000000000000000000000000000000000000000000000000000000000"}]}`))
+	tag := signatureTag(_repo, core.DigestFixture())
+
+	v := newVerifier(t, ModeEnforce, r, k.pem)
+	for _, op := range []string{OpPush, OpServe} {
+		err := v.Verify(tag, d, op)
+		require.Error(t, err)
+		require.True(t, IsRejected(err))
+	}
+}
+
+func TestVerifyPolicies(t *testing.T) {
+	k := newKeyFixture(t)
+	r := newFakeRegistry()
+	d := r.put([]byte("manifest"))
+
+	v, err := New(Config{
+		Keys: []string{k.pem},
+		Policies: []PolicyConfig{
+			{Namespace: "some/unsigned", Mode: ModeOff},
+			{Namespace: "some/.*", Mode: ModeEnforce},
+		},
+	}, tally.NoopScope, r, r)
+	require.NoError(t, err)
+
+	require.NoError(t, v.Verify("some/unsigned:latest", d, OpPush))
+	require.Error(t, v.Verify("some/repo:latest", d, OpPush))
+	require.NoError(t, v.Verify("other/repo:latest", d, OpPush))
+}
+
+func TestVerifyErrorsAreNotRejections(t *testing.T) {
+	k := newKeyFixture(t)
+	r := newFakeRegistry()
+	r.err = errors.New("some error")
+	d := r.put([]byte("manifest"))
+
+	err := newVerifier(t, ModeEnforce, r, k.pem).Verify(_repo+":latest", d, OpReplicate)
+	require.Error(t, err)
+	require.False(t, IsRejected(err))
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	k := newKeyFixture(t)
+	tests := []struct {
+		desc   string
+		config Config
+	}{
+		{"no keys", Config{DefaultMode: ModeWarn}},
+		{"invalid key", Config{DefaultMode: ModeWarn, Keys: []string{"foo"}}},
+		{"invalid mode", Config{Keys: []string{k.pem}, DefaultMode: "strict"}},
+		{"invalid namespace", Config{
+			Keys: []string{k.pem}, Policies: []PolicyConfig{{Namespace: "(", Mode: ModeWarn}}}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := New(test.config, tally.NoopScope, newFakeRegistry(), newFakeRegistry())
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestSplitTag(t *testing.T) {
+	repo, name, err := splitTag("localhost:5000/some/repo:latest")
+	require.NoError(t, err)
+	require.Equal(t, "localhost:5000/some/repo", repo)
+	require.Equal(t, "latest", name)
+
+	_, _, err = splitTag("localhost:5000/some/repo")
+	require.Error(t, err)
+}
@@ -82,6 +82,12 @@ func (readWriter localFileReadWriter) Seek(offset int64, whence int) (int64, err
 	return readWriter.descriptor.Seek(offset, whence)
 }
 
+// File returns the underlying OS.File object. Serving it directly, instead of
+// through the wrapper, allows the net package to transfer it via sendfile.
+func (readWriter localFileReadWriter) File() *os.File {
+	return readWriter.descriptor
+}
+
 // Size returns the size of the file.
 func (readWriter localFileReadWriter) Size() int64 {
 	// Use file entry instead of descriptor, because descriptor could have been closed.
@@ -30,6 +30,7 @@ type CADownloadStore struct {
 	downloadState base.FileState
 	cacheState    base.FileState
 	cleanup       *cleanupManager
+	quota         *QuotaManager
 }
 
 // NewCADownloadStore creates a new CADownloadStore.
@@ -61,17 +62,31 @@ func NewCADownloadStore(config CADownloadStoreConfig, stats tally.Scope) (*CADow
 		config.CacheCleanup,
 		backend.NewFileOp().AcceptState(cacheState))
 
+	quota, err := newQuotaManager(
+		config.CacheQuota, clock.New(), stats, backend.NewFileOp().AcceptState(cacheState))
+	if err != nil {
+		return nil, fmt.Errorf("new quota manager: %s", err)
+	}
+	quota.start()
+
 	return &CADownloadStore{
 		backend:       backend,
 		downloadState: downloadState,
 		cacheState:    cacheState,
 		cleanup:       cleanup,
+		quota:         quota,
 	}, nil
 }
 
 // Close terminates all goroutines started by s.
 func (s *CADownloadStore) Close() {
 	s.cleanup.stop()
+	s.quota.stop()
+}
+
+// Quota returns the manager of the cache's disk quotas.
+func (s *CADownloadStore) Quota() *QuotaManager {
+	return s.quota
 }
 
 // CreateDownloadFile creates an empty download file initialized with length.
@@ -170,6 +185,11 @@ func (a *CADownloadStoreScope) DeleteFile(name string) error {
 	return a.op.DeleteFile(name)
 }
 
+// ListNames returns the names of all files in the scope.
+func (a *CADownloadStoreScope) ListNames() ([]string, error) {
+	return a.op.ListNames()
+}
+
 // GetMetadata returns the metadata content of md for name.
 func (a *CADownloadStoreScope) GetMetadata(name string, md metadata.Metadata) error {
 	return a.op.GetFileMetadata(name, md)
@@ -14,6 +14,7 @@
 package store
 
 import (
+	"errors"
 	"fmt"
 	"hash"
 	"io"
@@ -29,6 +30,24 @@ import (
 	"github.com/uber/kraken/lib/store/base"
 )
 
+// DigestMismatchError occurs when the content of a file does not hash to the
+// digest it is stored under.
+type DigestMismatchError struct {
+	Expected core.Digest
+	Computed core.Digest
+}
+
+func (e *DigestMismatchError) Error() string {
+	return fmt.Sprintf(
+		"computed digest %s doesn't match expected value %s", e.Computed, e.Expected)
+}
+
+// IsDigestMismatch returns true if err was caused by a DigestMismatchError.
+func IsDigestMismatch(err error) bool {
+	var e *DigestMismatchError
+	return errors.As(err, &e)
+}
+
 // CAStore allows uploading / caching content-addressable files.
 type CAStore struct {
 	config CAStoreConfig
@@ -36,6 +55,7 @@ type CAStore struct {
 	*uploadStore
 	*cacheStore
 	cleanup *cleanupManager
+	quota   *QuotaManager
 }
 
 // NewCAStore creates a new CAStore.
@@ -68,12 +88,24 @@ func NewCAStore(config CAStoreConfig, stats tally.Scope) (*CAStore, error) {
 	cleanup.addJob("upload", config.UploadCleanup, uploadStore.newFileOp())
 	cleanup.addJob("cache", config.CacheCleanup, cacheStore.newFileOp())
 
-	return &CAStore{config, uploadStore, cacheStore, cleanup}, nil
+	quota, err := newQuotaManager(config.CacheQuota, clock.New(), stats, cacheStore.newFileOp())
+	if err != nil {
+		return nil, fmt.Errorf("new quota manager: %s", err)
+	}
+	quota.start()
+
+	return &CAStore{config, uploadStore, cacheStore, cleanup, quota}, nil
 }
 
 // Close terminates any goroutines started by s.
 func (s *CAStore) Close() {
 	s.cleanup.stop()
+	s.quota.stop()
+}
+
+// Quota returns the manager of the cache's disk quotas.
+func (s *CAStore) Quota() *QuotaManager {
+	return s.quota
 }
 
 // MoveUploadFileToCache commits uploadName as cacheName. Clients are expected
@@ -91,14 +123,15 @@ func (s *CAStore) MoveUploadFileToCache(uploadName, cacheName string) error {
 	}
 	defer f.Close()
 	if err := s.verify(f, cacheName); err != nil {
-		return fmt.Errorf("verify digest: %s", err)
+		return fmt.Errorf("verify digest: %w", err)
 	}
 
 	return s.cacheStore.newFileOp().MoveFileFrom(cacheName, s.cacheStore.state, uploadPath)
 }
 
 // CreateCacheFile initializes a cache file for name from r. name should be a raw
-// hex sha256 digest, and the contents of r must hash to name.
+// hex sha256 digest, and the contents of r must hash to name, else an error
+// satisfying IsDigestMismatch is returned.
 func (s *CAStore) CreateCacheFile(name string, r io.Reader) error {
 	return s.WriteCacheFile(name, func(w FileReadWriter) error {
 		_, err := io.Copy(w, r)
@@ -125,7 +158,7 @@ func (s *CAStore) WriteCacheFile(name string, write func(w FileReadWriter) error
 		return err
 	}
 	if err := s.MoveUploadFileToCache(tmp, name); err != nil && !os.IsExist(err) {
-		return fmt.Errorf("move upload file to cache: %s", err)
+		return fmt.Errorf("move upload file to cache: %w", err)
 	}
 	return nil
 }
@@ -146,7 +179,7 @@ func (s *CAStore) verify(r io.Reader, name string) error {
 			return fmt.Errorf("calculate digest: %s", err)
 		}
 		if computed != expected {
-			return fmt.Errorf("computed digest %s doesn't match expected value %s", computed, expected)
+			return &DigestMismatchError{Expected: expected, Computed: computed}
 		}
 	}
 	return nil
@@ -216,3 +216,21 @@ func TestCAStoreCreateCacheFile(t *testing.T) {
 	b2, err := ioutil.ReadAll(r2)
 	require.Equal(s1, string(b2))
 }
+
+func TestCAStoreCreateCacheFileDigestMismatch(t *testing.T) {
+	require := require.New(t)
+
+	s, cleanup := CAStoreFixture()
+	defer cleanup()
+
+	d := core.DigestFixture()
+
+	err := s.CreateCacheFile(d.Hex(), strings.NewReader("corrupt"))
+	require.Error(err)
+	require.True(IsDigestMismatch(err))
+
+	_, err = s.GetCacheFileStat(d.Hex())
+	require.True(os.IsNotExist(err))
+
+	require.False(IsDigestMismatch(fmt.Errorf("some error")))
+}
@@ -29,6 +29,7 @@ type CAStoreConfig struct {
 	Capacity      int           `yaml:"capacity"`
 	UploadCleanup CleanupConfig `yaml:"upload_cleanup"`
 	CacheCleanup  CleanupConfig `yaml:"cache_cleanup"`
+	CacheQuota    QuotaConfig   `yaml:"cache_quota"`
 
 	SkipHashVerification bool `yaml:"skip_hash_verification"`
 }
@@ -55,4 +56,5 @@ type CADownloadStoreConfig struct {
 	CacheDir        string        `yaml:"cache_dir"`
 	DownloadCleanup CleanupConfig `yaml:"download_cleanup"`
 	CacheCleanup    CleanupConfig `yaml:"cache_cleanup"`
+	CacheQuota      QuotaConfig   `yaml:"cache_quota"`
 }
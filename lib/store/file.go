@@ -13,10 +13,36 @@
 // limitations under the License.
 package store
 
-import "github.com/uber/kraken/lib/store/base"
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store/base"
+)
 
 // FileReadWriter is a readable, writable file.
 type FileReadWriter = base.FileReadWriter
 
 // FileReader is a read-only file.
 type FileReader = base.FileReader
+
+// ServeFile writes the blob d, read from f, to w. Range and If-Range headers of
+// r are honored, with the digest of the blob doubling as its ETag, such that
+// interrupted downloads can be resumed. Files on local disk are served via
+// sendfile.
+func ServeFile(w http.ResponseWriter, r *http.Request, d core.Digest, f FileReader) {
+	var content io.ReadSeeker = f
+	if of, ok := f.(interface{ File() *os.File }); ok {
+		content = of.File()
+	}
+	if w.Header().Get("Content-Type") == "" {
+		// Prevents ServeContent from sniffing the content type.
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	w.Header().Set("ETag", fmt.Sprintf("%q", d.Hex()))
+	http.ServeContent(w, r, "", time.Time{}, content)
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package metadata
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const _pinsSuffix = "_pins"
+
+func init() {
+	Register(regexp.MustCompile(_pinsSuffix), &pinsFactory{})
+}
+
+type pinsFactory struct{}
+
+func (f pinsFactory) Create(suffix string) Metadata {
+	return &Pins{}
+}
+
+// Pins records the image tags which pinned a blob to the cache.
+type Pins struct {
+	Tags []string
+}
+
+// NewPins creates a new Pins.
+func NewPins(tags ...string) *Pins {
+	return &Pins{tags}
+}
+
+// Add adds tag to m. Returns false if tag was already present.
+func (m *Pins) Add(tag string) bool {
+	if m.Has(tag) {
+		return false
+	}
+	m.Tags = append(m.Tags, tag)
+	sort.Strings(m.Tags)
+	return true
+}
+
+// Remove removes tag from m. Returns false if tag was not present.
+func (m *Pins) Remove(tag string) bool {
+	for i, t := range m.Tags {
+		if t == tag {
+			m.Tags = append(m.Tags[:i], m.Tags[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Has returns whether m contains tag.
+func (m *Pins) Has(tag string) bool {
+	for _, t := range m.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSuffix returns a static suffix.
+func (m *Pins) GetSuffix() string {
+	return _pinsSuffix
+}
+
+// Movable is true.
+func (m *Pins) Movable() bool {
+	return true
+}
+
+// Serialize converts m to bytes.
+func (m *Pins) Serialize() ([]byte, error) {
+	return []byte(strings.Join(m.Tags, "\n")), nil
+}
+
+// Deserialize loads b into m.
+func (m *Pins) Deserialize(b []byte) error {
+	m.Tags = nil
+	if len(b) > 0 {
+		m.Tags = strings.Split(string(b), "\n")
+	}
+	return nil
+}
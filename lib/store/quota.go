@@ -0,0 +1,431 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/uber/kraken/lib/store/base"
+	"github.com/uber/kraken/lib/store/metadata"
+	"github.com/uber/kraken/utils/log"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/c2h5oh/datasize"
+	"github.com/uber-go/tally"
+)
+
+// Names of built-in eviction policies.
+const (
+	// EvictionPolicyLRU evicts the least recently accessed files first.
+	EvictionPolicyLRU = "lru"
+
+	// EvictionPolicyTTL evicts the oldest files first, regardless of access.
+	EvictionPolicyTTL = "ttl"
+)
+
+var _evictionPolicies = map[string]EvictionPolicy{
+	EvictionPolicyLRU: lruPolicy{},
+	EvictionPolicyTTL: ttlPolicy{},
+}
+
+// EvictionPolicy orders cache files for eviction once a quota is exceeded.
+type EvictionPolicy interface {
+	// Less returns true if a should be evicted before b.
+	Less(a, b FileUsage) bool
+}
+
+// RegisterEvictionPolicy registers an EvictionPolicy under name, such that it
+// can be selected via QuotaConfig.Policy.
+func RegisterEvictionPolicy(name string, policy EvictionPolicy) {
+	_evictionPolicies[name] = policy
+}
+
+type lruPolicy struct{}
+
+func (lruPolicy) Less(a, b FileUsage) bool {
+	return a.LastAccess.Before(b.LastAccess)
+}
+
+type ttlPolicy struct{}
+
+func (ttlPolicy) Less(a, b FileUsage) bool {
+	return a.ModTime.Before(b.ModTime)
+}
+
+// NamespaceQuota limits the size of namespaces.
+type NamespaceQuota struct {
+	// Namespace is a regular expression of namespaces the quota applies to.
+	// Each matching namespace is limited to Size individually.
+	Namespace string            `yaml:"namespace"`
+	Size      datasize.ByteSize `yaml:"size"`
+}
+
+// QuotaConfig defines disk quotas of the cache, and how files are evicted once
+// they are exceeded. Quotas are disabled if neither Capacity nor Namespaces
+// are set.
+type QuotaConfig struct {
+	// Interval is how often usage is checked against quotas.
+	Interval time.Duration `yaml:"interval"`
+
+	// Capacity limits the size of the entire cache.
+	Capacity datasize.ByteSize `yaml:"capacity"`
+
+	// Namespaces limits the size of namespaces. The first matching quota is
+	// applied to each namespace.
+	Namespaces []NamespaceQuota `yaml:"namespaces"`
+
+	// Policy names the eviction policy. Defaults to lru.
+	Policy string `yaml:"policy"`
+
+	// Pinned is a list of regular expressions of namespaces whose files are
+	// never evicted. Files which are marked as persisted are always pinned.
+	Pinned []string `yaml:"pinned"`
+}
+
+func (c QuotaConfig) applyDefaults() QuotaConfig {
+	if c.Interval == 0 {
+		c.Interval = time.Minute
+	}
+	if c.Policy == "" {
+		c.Policy = EvictionPolicyLRU
+	}
+	return c
+}
+
+func (c QuotaConfig) enabled() bool {
+	return c.Capacity > 0 || len(c.Namespaces) > 0
+}
+
+// FileUsage describes a cache file considered for eviction.
+type FileUsage struct {
+	Name      string
+	Namespace string
+	Size      int64
+	ModTime   time.Time
+
+	// LastAccess defaults to ModTime if the file has no last access time.
+	LastAccess time.Time
+
+	Pinned bool
+}
+
+// NamespaceUsage describes the disk usage of a namespace.
+type NamespaceUsage struct {
+	Size   int64 `json:"size"`
+	Files  int   `json:"files"`
+	Pinned int64 `json:"pinned"`
+	Quota  int64 `json:"quota,omitempty"`
+}
+
+// CacheUsage describes the disk usage of the cache. Files without a namespace
+// are accounted under the empty namespace.
+type CacheUsage struct {
+	Size       int64                     `json:"size"`
+	Files      int                       `json:"files"`
+	Pinned     int64                     `json:"pinned"`
+	Capacity   int64                     `json:"capacity,omitempty"`
+	Policy     string                    `json:"policy"`
+	Namespaces map[string]NamespaceUsage `json:"namespaces"`
+}
+
+// EvictionResult describes the files removed by an eviction.
+type EvictionResult struct {
+	Files int   `json:"files"`
+	Size  int64 `json:"size"`
+}
+
+type namespaceQuota struct {
+	regexp *regexp.Regexp
+	size   int64
+}
+
+// QuotaManager enforces disk quotas of a cache by evicting files.
+type QuotaManager struct {
+	config     QuotaConfig
+	clk        clock.Clock
+	stats      tally.Scope
+	op         base.FileOp
+	policy     EvictionPolicy
+	namespaces []namespaceQuota
+	pinned     []*regexp.Regexp
+
+	// Serializes evictions.
+	mu sync.Mutex
+
+	stopOnce sync.Once
+	stopc    chan struct{}
+}
+
+func newQuotaManager(
+	config QuotaConfig, clk clock.Clock, stats tally.Scope, op base.FileOp) (*QuotaManager, error) {
+
+	config = config.applyDefaults()
+
+	policy, ok := _evictionPolicies[config.Policy]
+	if !ok {
+		return nil, fmt.Errorf("no eviction policy defined with name %s", config.Policy)
+	}
+	m := &QuotaManager{
+		config: config,
+		clk:    clk,
+		stats:  stats.SubScope("quota"),
+		op:     op,
+		policy: policy,
+		stopc:  make(chan struct{}),
+	}
+	for _, q := range config.Namespaces {
+		re, err := regexp.Compile(q.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("regexp compile namespace %s: %s", q.Namespace, err)
+		}
+		m.namespaces = append(m.namespaces, namespaceQuota{re, int64(q.Size.Bytes())})
+	}
+	for _, ns := range config.Pinned {
+		re, err := regexp.Compile(ns)
+		if err != nil {
+			return nil, fmt.Errorf("regexp compile pinned namespace %s: %s", ns, err)
+		}
+		m.pinned = append(m.pinned, re)
+	}
+	return m, nil
+}
+
+// start periodically enforces quotas, if any are configured.
+func (m *QuotaManager) start() {
+	if !m.config.enabled() {
+		return
+	}
+	ticker := m.clk.Ticker(m.config.Interval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := m.Enforce(); err != nil {
+					log.Errorf("Error enforcing cache quotas of %s: %s", m.op, err)
+				}
+			case <-m.stopc:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+func (m *QuotaManager) stop() {
+	m.stopOnce.Do(func() { close(m.stopc) })
+}
+
+// Usage returns the current disk usage of the cache.
+func (m *QuotaManager) Usage() (*CacheUsage, error) {
+	files, err := m.scan()
+	if err != nil {
+		return nil, err
+	}
+	u := &CacheUsage{
+		Capacity:   int64(m.config.Capacity.Bytes()),
+		Policy:     m.config.Policy,
+		Namespaces: make(map[string]NamespaceUsage),
+	}
+	for _, f := range files {
+		nu := u.Namespaces[f.Namespace]
+		nu.Size += f.Size
+		nu.Files++
+		u.Size += f.Size
+		u.Files++
+		if f.Pinned {
+			nu.Pinned += f.Size
+			u.Pinned += f.Size
+		}
+		if q, ok := m.quota(f.Namespace); ok {
+			nu.Quota = q
+		}
+		u.Namespaces[f.Namespace] = nu
+	}
+	return u, nil
+}
+
+// Enforce evicts files until all configured quotas are met.
+func (m *QuotaManager) Enforce() (*EvictionResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	files, err := m.scan()
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	usage := make(map[string]int64)
+	for _, f := range files {
+		total += f.Size
+		usage[f.Namespace] += f.Size
+	}
+	candidates := m.sortCandidates(files)
+
+	result := &EvictionResult{}
+	evicted := make(map[string]bool)
+	evict := func(f FileUsage) {
+		if evicted[f.Name] || !m.evictFile(f, result) {
+			return
+		}
+		evicted[f.Name] = true
+		total -= f.Size
+		usage[f.Namespace] -= f.Size
+	}
+	for _, f := range candidates {
+		if q, ok := m.quota(f.Namespace); ok && usage[f.Namespace] > q {
+			evict(f)
+		}
+	}
+	if capacity := int64(m.config.Capacity.Bytes()); capacity > 0 {
+		for _, f := range candidates {
+			if total <= capacity {
+				break
+			}
+			evict(f)
+		}
+	}
+	m.stats.Gauge("usage").Update(float64(total))
+	return result, nil
+}
+
+// Evict evicts unpinned files in eviction policy order until the usage of
+// namespace is at most target bytes, regardless of configured quotas. If
+// namespace is empty, evicts from the entire cache.
+func (m *QuotaManager) Evict(namespace string, target int64) (*EvictionResult, error) {
+	if target < 0 {
+		return nil, errors.New("target must be non-negative")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	files, err := m.scan()
+	if err != nil {
+		return nil, err
+	}
+	var usage int64
+	var scoped []FileUsage
+	for _, f := range files {
+		if namespace == "" || f.Namespace == namespace {
+			usage += f.Size
+			scoped = append(scoped, f)
+		}
+	}
+	result := &EvictionResult{}
+	for _, f := range m.sortCandidates(scoped) {
+		if usage <= target {
+			break
+		}
+		if m.evictFile(f, result) {
+			usage -= f.Size
+		}
+	}
+	return result, nil
+}
+
+// quota returns the quota of namespace, if any.
+func (m *QuotaManager) quota(namespace string) (int64, bool) {
+	for _, q := range m.namespaces {
+		if q.regexp.MatchString(namespace) {
+			return q.size, true
+		}
+	}
+	return 0, false
+}
+
+func (m *QuotaManager) isPinned(namespace string) bool {
+	for _, re := range m.pinned {
+		if re.MatchString(namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortCandidates returns the unpinned files in eviction order.
+func (m *QuotaManager) sortCandidates(files []FileUsage) []FileUsage {
+	var candidates []FileUsage
+	for _, f := range files {
+		if !f.Pinned {
+			candidates = append(candidates, f)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return m.policy.Less(candidates[i], candidates[j])
+	})
+	return candidates
+}
+
+// evictFile deletes f and records it in result. Returns false if f could not
+// be deleted.
+func (m *QuotaManager) evictFile(f FileUsage, result *EvictionResult) bool {
+	if err := m.op.DeleteFile(f.Name); err != nil {
+		if err != base.ErrFilePersisted && !os.IsNotExist(err) {
+			log.With("name", f.Name).Errorf("Error evicting file: %s", err)
+		}
+		return false
+	}
+	result.Files++
+	result.Size += f.Size
+	m.stats.Counter("evicted_files").Inc(1)
+	m.stats.Counter("evicted_bytes").Inc(f.Size)
+	return true
+}
+
+// scan returns the usage of every file in the cache.
+func (m *QuotaManager) scan() ([]FileUsage, error) {
+	names, err := m.op.ListNames()
+	if err != nil {
+		return nil, fmt.Errorf("list names: %s", err)
+	}
+	var files []FileUsage
+	for _, name := range names {
+		info, err := m.op.GetFileStat(name)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.With("name", name).Errorf("Error getting file stat: %s", err)
+			}
+			continue
+		}
+		f := FileUsage{
+			Name:       name,
+			Size:       info.Size(),
+			ModTime:    info.ModTime(),
+			LastAccess: info.ModTime(),
+		}
+		var ns metadata.Namespace
+		if err := m.op.GetFileMetadata(name, &ns); err == nil {
+			f.Namespace = ns.Value
+		}
+		var lat metadata.LastAccessTime
+		if err := m.op.GetFileMetadata(name, &lat); err == nil {
+			f.LastAccess = lat.Time
+		}
+		var persist metadata.Persist
+		if err := m.op.GetFileMetadata(name, &persist); err == nil && persist.Value {
+			f.Pinned = true
+		}
+		if m.isPinned(f.Namespace) {
+			f.Pinned = true
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
@@ -0,0 +1,232 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package store
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/store/base"
+	"github.com/uber/kraken/lib/store/metadata"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/c2h5oh/datasize"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+type quotaFixture struct {
+	clk   *clock.Mock
+	state base.FileState
+	op    base.FileOp
+}
+
+func newQuotaFixture() (*quotaFixture, func()) {
+	clk := clock.NewMock()
+	clk.Set(time.Now())
+	state, op, cleanup := fileOpFixture(clk)
+	return &quotaFixture{clk, state, op}, cleanup
+}
+
+// createFile creates a file of size under namespace, which was last accessed
+// at the current time.
+func (f *quotaFixture) createFile(t *testing.T, namespace string, size int64) string {
+	name := core.DigestFixture().Hex()
+	require.NoError(t, f.op.CreateFile(name, f.state, size))
+	_, err := f.op.SetFileMetadata(name, metadata.NewNamespace(namespace))
+	require.NoError(t, err)
+	_, err = f.op.SetFileMetadata(name, metadata.NewLastAccessTime(f.clk.Now()))
+	require.NoError(t, err)
+	f.clk.Add(time.Minute)
+	return name
+}
+
+func (f *quotaFixture) exists(name string) bool {
+	_, err := f.op.GetFileStat(name)
+	return !os.IsNotExist(err)
+}
+
+func (f *quotaFixture) newQuotaManager(t *testing.T, config QuotaConfig) *QuotaManager {
+	m, err := newQuotaManager(config, f.clk, tally.NoopScope, f.op)
+	require.NoError(t, err)
+	return m
+}
+
+func TestQuotaManagerEnforcesNamespaceQuotas(t *testing.T) {
+	require := require.New(t)
+
+	f, cleanup := newQuotaFixture()
+	defer cleanup()
+
+	m := f.newQuotaManager(t, QuotaConfig{
+		Namespaces: []NamespaceQuota{{Namespace: "foo/.*", Size: 250}},
+	})
+
+	foo1 := f.createFile(t, "foo/a", 100)
+	foo2 := f.createFile(t, "foo/a", 100)
+	foo3 := f.createFile(t, "foo/a", 100)
+	other := f.createFile(t, "foo/b", 200)
+	bar := f.createFile(t, "bar/a", 1000)
+
+	result, err := m.Enforce()
+	require.NoError(err)
+	require.Equal(&EvictionResult{Files: 1, Size: 100}, result)
+
+	// Least recently accessed file of the namespace over its quota is evicted.
+	require.False(f.exists(foo1))
+	require.True(f.exists(foo2))
+	require.True(f.exists(foo3))
+
+	// Each namespace is limited individually, and namespaces without quotas
+	// are not limited.
+	require.True(f.exists(other))
+	require.True(f.exists(bar))
+}
+
+func TestQuotaManagerEnforcesCapacity(t *testing.T) {
+	require := require.New(t)
+
+	f, cleanup := newQuotaFixture()
+	defer cleanup()
+
+	m := f.newQuotaManager(t, QuotaConfig{
+		Capacity: 250,
+		Pinned:   []string{"^pinned/"},
+	})
+
+	pinned := f.createFile(t, "pinned/a", 100)
+	a := f.createFile(t, "foo/a", 100)
+	b := f.createFile(t, "foo/b", 100)
+
+	persisted := f.createFile(t, "foo/c", 100)
+	_, err := f.op.SetFileMetadata(persisted, metadata.NewPersist(true))
+	require.NoError(err)
+
+	// Accessing a makes b the least recently used.
+	_, err = f.op.SetFileMetadata(a, metadata.NewLastAccessTime(f.clk.Now()))
+	require.NoError(err)
+
+	result, err := m.Enforce()
+	require.NoError(err)
+	require.Equal(&EvictionResult{Files: 2, Size: 200}, result)
+
+	require.True(f.exists(pinned))
+	require.True(f.exists(persisted))
+	require.False(f.exists(a))
+	require.False(f.exists(b))
+}
+
+func TestQuotaManagerTTLPolicyIgnoresAccess(t *testing.T) {
+	require := require.New(t)
+
+	f, cleanup := newQuotaFixture()
+	defer cleanup()
+
+	m := f.newQuotaManager(t, QuotaConfig{
+		Capacity: 100,
+		Policy:   EvictionPolicyTTL,
+	})
+
+	old := f.createFile(t, "foo", 100)
+	f.clk.Add(time.Second)
+	// Modification times have second granularity on some file systems.
+	time.Sleep(time.Second)
+	young := f.createFile(t, "foo", 100)
+
+	_, err := f.op.SetFileMetadata(young, metadata.NewLastAccessTime(f.clk.Now().Add(-time.Hour)))
+	require.NoError(err)
+
+	_, err = m.Enforce()
+	require.NoError(err)
+
+	require.False(f.exists(old))
+	require.True(f.exists(young))
+}
+
+func TestQuotaManagerEvict(t *testing.T) {
+	require := require.New(t)
+
+	f, cleanup := newQuotaFixture()
+	defer cleanup()
+
+	m := f.newQuotaManager(t, QuotaConfig{})
+
+	foo1 := f.createFile(t, "foo", 100)
+	foo2 := f.createFile(t, "foo", 100)
+	bar := f.createFile(t, "bar", 100)
+
+	result, err := m.Evict("foo", 100)
+	require.NoError(err)
+	require.Equal(&EvictionResult{Files: 1, Size: 100}, result)
+	require.False(f.exists(foo1))
+	require.True(f.exists(foo2))
+
+	result, err = m.Evict("", 0)
+	require.NoError(err)
+	require.Equal(&EvictionResult{Files: 2, Size: 200}, result)
+	require.False(f.exists(foo2))
+	require.False(f.exists(bar))
+
+	_, err = m.Evict("", -1)
+	require.Error(err)
+}
+
+func TestQuotaManagerUsage(t *testing.T) {
+	require := require.New(t)
+
+	f, cleanup := newQuotaFixture()
+	defer cleanup()
+
+	m := f.newQuotaManager(t, QuotaConfig{
+		Capacity:   datasize.KB,
+		Namespaces: []NamespaceQuota{{Namespace: "foo", Size: 500}},
+		Pinned:     []string{"bar"},
+	})
+
+	f.createFile(t, "foo", 100)
+	f.createFile(t, "foo", 100)
+	f.createFile(t, "bar", 50)
+
+	usage, err := m.Usage()
+	require.NoError(err)
+	require.Equal(&CacheUsage{
+		Size:     250,
+		Files:    3,
+		Pinned:   50,
+		Capacity: 1024,
+		Policy:   EvictionPolicyLRU,
+		Namespaces: map[string]NamespaceUsage{
+			"foo": {Size: 200, Files: 2, Quota: 500},
+			"bar": {Size: 50, Files: 1, Pinned: 50},
+		},
+	}, usage)
+}
+
+func TestQuotaManagerInvalidConfig(t *testing.T) {
+	f, cleanup := newQuotaFixture()
+	defer cleanup()
+
+	for desc, config := range map[string]QuotaConfig{
+		"unknown policy":    {Policy: "foo"},
+		"invalid namespace": {Namespaces: []NamespaceQuota{{Namespace: "("}}},
+		"invalid pinned":    {Pinned: []string{"("}},
+	} {
+		t.Run(desc, func(t *testing.T) {
+			_, err := newQuotaManager(config, f.clk, tally.NoopScope, f.op)
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package gossip
+
+import (
+	"time"
+
+	"github.com/uber/kraken/lib/upstream"
+	"github.com/uber/kraken/tracker/originstore"
+	"github.com/uber/kraken/tracker/peerhandoutpolicy"
+	"github.com/uber/kraken/tracker/peerstore"
+)
+
+// Config defines Node configuration.
+type Config struct {
+	// Enabled replaces the tracker with gossip between agents for peer
+	// discovery. Metainfo is then downloaded directly from the origin cluster.
+	Enabled bool `yaml:"enabled"`
+
+	// Peers are the agent servers of the cluster. Each torrent is announced to
+	// the peers owning its digest in a hash ring of them, which answer with the
+	// other peers announcing to them. HashRing.MaxReplica therefore sets the
+	// number of peers tracking each torrent.
+	Peers upstream.PassiveHashRingConfig `yaml:"peers"`
+
+	// AnnounceInterval is the interval between announces of each torrent.
+	AnnounceInterval time.Duration `yaml:"announce_interval"`
+
+	// PeerHandoutLimit is the max number of peers returned per announce.
+	PeerHandoutLimit int `yaml:"announce_limit"`
+
+	// Timeout is the timeout of each announce sent to a peer.
+	Timeout time.Duration `yaml:"timeout"`
+
+	PeerStore         peerstore.LocalConfig    `yaml:"peerstore"`
+	OriginStore       originstore.Config       `yaml:"originstore"`
+	PeerHandoutPolicy peerhandoutpolicy.Config `yaml:"peerhandoutpolicy"`
+}
+
+func (c *Config) applyDefaults() {
+	if c.AnnounceInterval == 0 {
+		c.AnnounceInterval = 3 * time.Second
+	}
+	if c.PeerHandoutLimit == 0 {
+		c.PeerHandoutLimit = 50
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 5 * time.Second
+	}
+	if c.PeerStore.TTL == 0 {
+		// Peers re-announce every AnnounceInterval, so entries of peers which
+		// went away can expire much sooner than in the tracker.
+		c.PeerStore.TTL = 5 * time.Minute
+	}
+	if c.PeerHandoutPolicy.Priority == "" {
+		c.PeerHandoutPolicy.Priority = "completeness"
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package gossip
+
+import (
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/origin/blobclient"
+	"github.com/uber/kraken/tracker/metainfoclient"
+	"github.com/uber/kraken/utils/httputil"
+
+	"github.com/cenkalti/backoff"
+)
+
+type metaInfoClient struct {
+	origins blobclient.ClientResolver
+}
+
+// NewMetaInfoClient returns a metainfoclient.Client which downloads metainfo
+// directly from the origin cluster, in place of the tracker.
+func NewMetaInfoClient(origins blobclient.ClientResolver) metainfoclient.Client {
+	return &metaInfoClient{origins}
+}
+
+// Download returns the MetaInfo of d, waiting for origins to fetch it from
+// their storage backend if necessary. Returns metainfoclient.ErrNotFound if d
+// does not exist.
+func (c *metaInfoClient) Download(namespace string, d core.Digest) (*core.MetaInfo, error) {
+	var mi *core.MetaInfo
+	err := blobclient.Poll(c.origins, &backoff.ExponentialBackOff{
+		InitialInterval:     time.Second,
+		RandomizationFactor: 0.05,
+		Multiplier:          1.3,
+		MaxInterval:         5 * time.Second,
+		MaxElapsedTime:      15 * time.Minute,
+		Clock:               backoff.SystemClock,
+	}, d, func(client blobclient.Client) error {
+		var err error
+		mi, err = client.GetMetaInfo(namespace, d)
+		return err
+	})
+	if err != nil {
+		if httputil.IsNotFound(err) {
+			return nil, metainfoclient.ErrNotFound
+		}
+		return nil, err
+	}
+	return mi, nil
+}
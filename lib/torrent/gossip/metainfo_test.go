@@ -0,0 +1,69 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package gossip
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/uber/kraken/core"
+	mockblobclient "github.com/uber/kraken/mocks/origin/blobclient"
+	"github.com/uber/kraken/origin/blobclient"
+	"github.com/uber/kraken/tracker/metainfoclient"
+	"github.com/uber/kraken/utils/httputil"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetaInfoClientDownload(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	resolver := mockblobclient.NewMockClientResolver(ctrl)
+	origin := mockblobclient.NewMockClient(ctrl)
+
+	mi := core.MetaInfoFixture()
+	d := mi.Digest()
+
+	resolver.EXPECT().Resolve(d).Return([]blobclient.Client{origin}, nil)
+	gomock.InOrder(
+		origin.EXPECT().GetMetaInfo("some/namespace", d).Return(
+			nil, httputil.StatusError{Status: http.StatusAccepted}),
+		origin.EXPECT().GetMetaInfo("some/namespace", d).Return(mi, nil),
+	)
+
+	result, err := NewMetaInfoClient(resolver).Download("some/namespace", d)
+	require.NoError(err)
+	require.Equal(mi, result)
+}
+
+func TestMetaInfoClientDownloadNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	resolver := mockblobclient.NewMockClientResolver(ctrl)
+	origin := mockblobclient.NewMockClient(ctrl)
+
+	d := core.DigestFixture()
+
+	resolver.EXPECT().Resolve(d).Return([]blobclient.Client{origin}, nil)
+	origin.EXPECT().GetMetaInfo("some/namespace", d).Return(
+		nil, httputil.StatusError{Status: http.StatusNotFound})
+
+	_, err := NewMetaInfoClient(resolver).Download("some/namespace", d)
+	require.Equal(t, metainfoclient.ErrNotFound, err)
+}
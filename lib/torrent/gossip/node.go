@@ -0,0 +1,256 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package gossip
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/hashring"
+	"github.com/uber/kraken/tracker/announceclient"
+	"github.com/uber/kraken/tracker/originstore"
+	"github.com/uber/kraken/tracker/peerhandoutpolicy"
+	"github.com/uber/kraken/tracker/peerstore"
+	"github.com/uber/kraken/utils/errutil"
+	"github.com/uber/kraken/utils/handler"
+	"github.com/uber/kraken/utils/httputil"
+	"github.com/uber/kraken/utils/log"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/go-chi/chi"
+	"github.com/uber-go/tally"
+)
+
+// Node discovers peers of torrents without a tracker. Instead of a central
+// peer store, every torrent is tracked by the few agents which own its digest
+// in a hash ring of the cluster, similar to the nodes closest to a key in a DHT.
+// Announces are sent to each of them, and their answers are merged with the
+// origins seeding the torrent into the peer handout.
+//
+// Node implements announceclient.Client for the scheduler, and serves the
+// announces of other agents via Handler.
+type Node struct {
+	config    Config
+	stats     tally.Scope
+	pctx      core.PeerContext
+	ring      hashring.PassiveRing
+	origins   originstore.Store
+	policy    *peerhandoutpolicy.PriorityPolicy
+	peerStore *peerstore.LocalStore
+	tls       *tls.Config
+}
+
+// New creates a new Node. ring must contain the agent servers of the cluster,
+// including the local one.
+func New(
+	config Config,
+	stats tally.Scope,
+	pctx core.PeerContext,
+	ring hashring.PassiveRing,
+	origins originstore.Store,
+	tls *tls.Config) (*Node, error) {
+
+	config.applyDefaults()
+
+	stats = stats.Tagged(map[string]string{
+		"module": "gossip",
+	})
+
+	policy, err := peerhandoutpolicy.NewPriorityPolicy(stats, config.PeerHandoutPolicy.Priority)
+	if err != nil {
+		return nil, fmt.Errorf("peer handout policy: %s", err)
+	}
+	return &Node{
+		config:    config,
+		stats:     stats,
+		pctx:      pctx,
+		ring:      ring,
+		origins:   origins,
+		policy:    policy,
+		peerStore: peerstore.NewLocalStore(config.PeerStore, clock.New()),
+		tls:       tls,
+	}, nil
+}
+
+// Close stops the Node.
+func (n *Node) Close() {
+	n.peerStore.Close()
+}
+
+// Handler returns the HTTP handler serving announces of other agents. It is
+// meant to be mounted under /x/gossip of the agent server.
+func (n *Node) Handler() http.Handler {
+	r := chi.NewRouter()
+	r.Post("/announce/{infohash}", handler.Wrap(n.announceHandler))
+	return r
+}
+
+// Announce announces the torrent identified by (d, h) to the agents tracking
+// it, and returns the other peers announcing for it along with the origins
+// seeding it. version is ignored, since agents always exchange full handouts.
+// Complete peers receive no handout.
+func (n *Node) Announce(
+	d core.Digest,
+	h core.InfoHash,
+	complete bool,
+	version int) ([]*core.PeerInfo, time.Duration, error) {
+
+	return n.announce(d, h, complete, false)
+}
+
+// Probe returns the peers of the torrent identified by (d, h), like Announce,
+// but without adding this agent to the torrent.
+func (n *Node) Probe(d core.Digest, h core.InfoHash) ([]*core.PeerInfo, error) {
+	peers, _, err := n.announce(d, h, false, true)
+	return peers, err
+}
+
+func (n *Node) announce(
+	d core.Digest,
+	h core.InfoHash,
+	complete bool,
+	probe bool) ([]*core.PeerInfo, time.Duration, error) {
+
+	self := core.PeerInfoFromContext(n.pctx, complete)
+
+	body, err := json.Marshal(&announceclient.Request{
+		Name:     d.Hex(),
+		Digest:   &d,
+		InfoHash: h,
+		Peer:     self,
+		Probe:    probe,
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("marshal request: %s", err)
+	}
+
+	var peers []*core.PeerInfo
+	var errs []error
+	var reached int
+	for _, addr := range n.ring.Locations(d) {
+		resp, err := n.send(addr, h, body)
+		if err != nil {
+			if httputil.IsNetworkError(err) {
+				n.ring.Failed(addr)
+			}
+			errs = append(errs, fmt.Errorf("%s: %s", addr, err))
+			continue
+		}
+		reached++
+		peers = append(peers, resp.Peers...)
+	}
+	if reached == 0 {
+		n.stats.Counter("announce_unreached").Inc(1)
+		log.With("hash", h).Warnf(
+			"Gossip announce reached no agents: %s", errutil.Join(errs))
+	}
+	if complete {
+		return nil, n.config.AnnounceInterval, nil
+	}
+
+	origins, err := n.origins.GetOrigins(d)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("origin store: %s", err))
+	}
+	peers = n.handout(self, append(peers, origins...))
+	if len(peers) == 0 {
+		return nil, 0, fmt.Errorf("no peers available: %s", errutil.Join(errs))
+	}
+	return peers, n.config.AnnounceInterval, nil
+}
+
+func (n *Node) send(
+	addr string, h core.InfoHash, body []byte) (*announceclient.Response, error) {
+
+	httpResp, err := httputil.Post(
+		fmt.Sprintf("http://%s/x/gossip/announce/%s", addr, h.Hex()),
+		httputil.SendBody(bytes.NewReader(body)),
+		httputil.SendTimeout(n.config.Timeout),
+		httputil.SendTLS(n.tls))
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	var resp announceclient.Response
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("decode response: %s", err)
+	}
+	return &resp, nil
+}
+
+// handout dedupes peers received from several agents, excluding self, and
+// returns at most PeerHandoutLimit of them sorted by priority.
+func (n *Node) handout(self *core.PeerInfo, peers []*core.PeerInfo) []*core.PeerInfo {
+	seen := map[core.PeerID]bool{self.PeerID: true}
+	var result []*core.PeerInfo
+	for _, p := range peers {
+		if seen[p.PeerID] {
+			continue
+		}
+		seen[p.PeerID] = true
+		result = append(result, p)
+	}
+	result = n.policy.SortPeers(self, result)
+	if len(result) > n.config.PeerHandoutLimit {
+		result = result[:n.config.PeerHandoutLimit]
+	}
+	return result
+}
+
+// announceHandler records the announcing peer for a torrent this agent tracks,
+// unless it only probes, and returns the other peers which announced for it.
+func (n *Node) announceHandler(w http.ResponseWriter, r *http.Request) error {
+	infohash, err := httputil.ParseParam(r, "infohash")
+	if err != nil {
+		return err
+	}
+	h, err := core.NewInfoHashFromHex(infohash)
+	if err != nil {
+		return handler.Errorf("parse infohash: %s", err).Status(http.StatusBadRequest)
+	}
+	req := new(announceclient.Request)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return handler.Errorf("json decode request: %s", err).Status(http.StatusBadRequest)
+	}
+	if req.Peer == nil {
+		return handler.Errorf("no peer supplied").Status(http.StatusBadRequest)
+	}
+	if !req.Probe {
+		if err := n.peerStore.UpdatePeer(h, req.Peer); err != nil {
+			return fmt.Errorf("update peer: %s", err)
+		}
+	}
+	var peers []*core.PeerInfo
+	if !req.Peer.Complete {
+		// Includes the announcing peer itself, which it filters out of its
+		// handout. The limit is therefore one higher than the handout limit.
+		peers, err = n.peerStore.GetPeers(h, n.config.PeerHandoutLimit+1)
+		if err != nil {
+			return fmt.Errorf("get peers: %s", err)
+		}
+	}
+	resp := &announceclient.Response{
+		Peers:    peers,
+		Interval: n.config.AnnounceInterval,
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		return handler.Errorf("json encode response: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package gossip
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/hashring"
+	"github.com/uber/kraken/lib/healthcheck"
+	"github.com/uber/kraken/lib/hostlist"
+	mockoriginstore "github.com/uber/kraken/mocks/tracker/originstore"
+	"github.com/uber/kraken/utils/testutil"
+
+	"github.com/go-chi/chi"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+type clusterFixture struct {
+	nodes   []*Node
+	origins *mockoriginstore.MockStore
+}
+
+// newClusterFixture starts n agents, each serving gossip announces, plus the
+// addresses of extra agents which are unreachable.
+func newClusterFixture(
+	t *testing.T, n int, unreachable ...string) (*clusterFixture, func()) {
+
+	var cleanup testutil.Cleanup
+	defer cleanup.Recover()
+
+	ctrl := gomock.NewController(t)
+	cleanup.Add(ctrl.Finish)
+
+	origins := mockoriginstore.NewMockStore(ctrl)
+
+	handlers := make([]http.Handler, n)
+	addrs := append([]string{}, unreachable...)
+	for i := 0; i < n; i++ {
+		i := i
+		r := chi.NewRouter()
+		r.Mount("/x/gossip", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers[i].ServeHTTP(w, r)
+		}))
+		s := httptest.NewServer(r)
+		cleanup.Add(s.Close)
+		addrs = append(addrs, strings.TrimPrefix(s.URL, "http://"))
+	}
+
+	var nodes []*Node
+	for i := 0; i < n; i++ {
+		ring := hashring.NewPassive(
+			hashring.Config{MaxReplica: 2}, hostlist.Fixture(addrs...), healthcheck.IdentityFilter{})
+		node, err := New(Config{}, tally.NoopScope, core.PeerContextFixture(), ring, origins, nil)
+		require.NoError(t, err)
+		cleanup.Add(node.Close)
+		handlers[i] = node.Handler()
+		nodes = append(nodes, node)
+	}
+
+	return &clusterFixture{nodes, origins}, cleanup.Run
+}
+
+func peerIDs(peers []*core.PeerInfo) []core.PeerID {
+	var ids []core.PeerID
+	for _, p := range peers {
+		ids = append(ids, p.PeerID)
+	}
+	return ids
+}
+
+func TestAnnounceDiscoversPeers(t *testing.T) {
+	require := require.New(t)
+
+	cluster, cleanup := newClusterFixture(t, 4)
+	defer cleanup()
+
+	mi := core.MetaInfoFixture()
+	d := mi.Digest()
+	h := mi.InfoHash()
+
+	origin := core.OriginPeerInfoFixture()
+	cluster.origins.EXPECT().GetOrigins(d).Return([]*core.PeerInfo{origin}, nil).AnyTimes()
+
+	a, b, c := cluster.nodes[0], cluster.nodes[1], cluster.nodes[2]
+
+	peers, interval, err := a.Announce(d, h, false, 0)
+	require.NoError(err)
+	require.Equal([]core.PeerID{origin.PeerID}, peerIDs(peers))
+	require.Equal(3*time.Second, interval)
+
+	peers, _, err = b.Announce(d, h, false, 0)
+	require.NoError(err)
+	require.ElementsMatch([]core.PeerID{a.pctx.PeerID, origin.PeerID}, peerIDs(peers))
+
+	// Complete peers announce to be discovered, but receive no handout.
+	peers, _, err = c.Announce(d, h, true, 0)
+	require.NoError(err)
+	require.Empty(peers)
+
+	peers, _, err = a.Announce(d, h, false, 0)
+	require.NoError(err)
+	require.ElementsMatch(
+		[]core.PeerID{b.pctx.PeerID, c.pctx.PeerID, origin.PeerID}, peerIDs(peers))
+	for _, p := range peers {
+		require.Equal(p.PeerID == c.pctx.PeerID || p.PeerID == origin.PeerID, p.Complete)
+	}
+}
+
+func TestProbeDoesNotAddPeer(t *testing.T) {
+	require := require.New(t)
+
+	cluster, cleanup := newClusterFixture(t, 3)
+	defer cleanup()
+
+	mi := core.MetaInfoFixture()
+	d := mi.Digest()
+	h := mi.InfoHash()
+
+	origin := core.OriginPeerInfoFixture()
+	cluster.origins.EXPECT().GetOrigins(d).Return([]*core.PeerInfo{origin}, nil).AnyTimes()
+
+	a, b := cluster.nodes[0], cluster.nodes[1]
+
+	_, _, err := a.Announce(d, h, false, 0)
+	require.NoError(err)
+
+	peers, err := b.Probe(d, h)
+	require.NoError(err)
+	require.ElementsMatch([]core.PeerID{a.pctx.PeerID, origin.PeerID}, peerIDs(peers))
+
+	// b is not handed out, since it only probed.
+	peers, _, err = a.Announce(d, h, false, 0)
+	require.NoError(err)
+	require.Equal([]core.PeerID{origin.PeerID}, peerIDs(peers))
+}
+
+func TestAnnounceSkipsUnreachableAgents(t *testing.T) {
+	require := require.New(t)
+
+	cluster, cleanup := newClusterFixture(t, 2, "127.0.0.1:1", "127.0.0.1:2")
+	defer cleanup()
+
+	cluster.origins.EXPECT().GetOrigins(gomock.Any()).Return(nil, errors.New("some error")).AnyTimes()
+
+	a, b := cluster.nodes[0], cluster.nodes[1]
+
+	// Announce several torrents, such that some are owned by the unreachable
+	// agents and some by the reachable ones.
+	var discovered int
+	for i := 0; i < 10; i++ {
+		mi := core.MetaInfoFixture()
+
+		_, _, err := a.Announce(mi.Digest(), mi.InfoHash(), true, 0)
+		require.NoError(err)
+
+		peers, _, err := b.Announce(mi.Digest(), mi.InfoHash(), false, 0)
+		if err != nil {
+			require.Contains(err.Error(), "no peers available")
+			continue
+		}
+		require.Equal([]core.PeerID{a.pctx.PeerID}, peerIDs(peers))
+		discovered++
+	}
+	require.True(discovered > 0)
+}
+
+func TestAnnounceHandlerRejectsInvalidRequests(t *testing.T) {
+	cluster, cleanup := newClusterFixture(t, 1)
+	defer cleanup()
+
+	h := core.InfoHashFixture()
+	for _, test := range []struct {
+		desc string
+		path string
+		body string
+	}{
+		{"invalid infohash", "/announce/foo", "{}"},
+		{"invalid body", "/announce/" + h.Hex(), "foo"},
+		{"no peer", "/announce/" + h.Hex(), "{}"},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", test.path, strings.NewReader(test.body))
+			cluster.nodes[0].Handler().ServeHTTP(w, r)
+			require.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
@@ -28,6 +28,10 @@ import (
 type Config struct {
 	DefaultInterval time.Duration `yaml:"default_interval"`
 	MaxInterval     time.Duration `yaml:"max_interval"`
+
+	// Version is the announce protocol version. V3 requires all trackers to
+	// support it.
+	Version int `yaml:"version"`
 }
 
 func (c Config) applyDefaults() Config {
@@ -37,6 +41,9 @@ func (c Config) applyDefaults() Config {
 	if c.MaxInterval == 0 {
 		c.MaxInterval = time.Minute
 	}
+	if c.Version == 0 {
+		c.Version = announceclient.V2
+	}
 	return c
 }
 
@@ -74,22 +81,12 @@ func New(
 	}
 }
 
-// Default creates a default Announcer.
-// TODO(evelynl94): make announce interval configurable.
-func Default(
-	client announceclient.Client,
-	events Events,
-	clk clock.Clock,
-	logger *zap.SugaredLogger) *Announcer {
-	return New(Config{}, client, events, clk, logger)
-}
-
 // Announce announces through the underlying client and returns the resulting
 // peer handout. Updates the announce interval if it has changed.
 func (a *Announcer) Announce(
 	d core.Digest, h core.InfoHash, complete bool) ([]*core.PeerInfo, error) {
 
-	peers, interval, err := a.client.Announce(d, h, complete, announceclient.V2)
+	peers, interval, err := a.client.Announce(d, h, complete, a.config.Version)
 	if err != nil {
 		return nil, err
 	}
@@ -115,6 +115,25 @@ func TestAnnouncerAnnounceUpdatesInterval(t *testing.T) {
 	mocks.events.expectTick(t)
 }
 
+func TestAnnouncerAnnounceUsesConfiguredVersion(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newAnnouncerMocks(t)
+	defer cleanup()
+
+	announcer := mocks.newAnnouncer(Config{Version: announceclient.V3})
+
+	d := core.DigestFixture()
+	hash := core.InfoHashFixture()
+	peers := []*core.PeerInfo{core.PeerInfoFixture()}
+
+	mocks.client.EXPECT().Announce(d, hash, true, announceclient.V3).Return(peers, time.Second, nil)
+
+	result, err := announcer.Announce(d, hash, true)
+	require.NoError(err)
+	require.Equal(peers, result)
+}
+
 func TestAnnouncerAnnounceErr(t *testing.T) {
 	require := require.New(t)
 
@@ -16,6 +16,7 @@ package scheduler
 import (
 	"time"
 
+	"github.com/uber/kraken/lib/torrent/scheduler/announcer"
 	"github.com/uber/kraken/lib/torrent/scheduler/conn"
 	"github.com/uber/kraken/lib/torrent/scheduler/connstate"
 	"github.com/uber/kraken/lib/torrent/scheduler/dispatch"
@@ -54,6 +55,8 @@ type Config struct {
 
 	ProbeTimeout time.Duration `yaml:"probe_timeout"`
 
+	Announcer announcer.Config `yaml:"announcer"`
+
 	ConnState connstate.Config `yaml:"connstate"`
 
 	Conn conn.Config `yaml:"conn"`
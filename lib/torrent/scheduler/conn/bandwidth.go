@@ -0,0 +1,225 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package conn
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/uber/kraken/utils/bandwidth"
+
+	"go.uber.org/zap"
+)
+
+// NamespaceBandwidthConfig limits the combined bandwidth of all connections for
+// torrents whose namespace matches Namespace.
+type NamespaceBandwidthConfig struct {
+	// Namespace is a regular expression. The first matching entry applies.
+	Namespace string           `yaml:"namespace"`
+	Bandwidth bandwidth.Config `yaml:"bandwidth"`
+}
+
+// BandwidthLimits defines the bandwidth limits applied to connections, which
+// may be adjusted at runtime via Handshaker.SetBandwidthLimits.
+type BandwidthLimits struct {
+	// Global limits the combined bandwidth of all connections.
+	Global bandwidth.Config
+
+	// Peer limits the bandwidth of each individual connection.
+	Peer bandwidth.Config
+
+	Namespaces []NamespaceBandwidthConfig
+}
+
+type namespaceLimiter struct {
+	regexp  *regexp.Regexp
+	limiter *bandwidth.Limiter
+}
+
+// limiterSet holds the limiters built from some BandwidthLimits.
+type limiterSet struct {
+	limits     BandwidthLimits
+	global     *bandwidth.Limiter
+	namespaces []namespaceLimiter
+}
+
+func newLimiterSet(limits BandwidthLimits, logger *zap.SugaredLogger) (*limiterSet, error) {
+	global, err := bandwidth.NewLimiter(limits.Global, bandwidth.WithLogger(logger))
+	if err != nil {
+		return nil, fmt.Errorf("global: %s", err)
+	}
+	// Validates the peer config up front, since peer limiters are created lazily.
+	if _, err := newPeerLimiter(limits.Peer); err != nil {
+		return nil, fmt.Errorf("peer: %s", err)
+	}
+	var namespaces []namespaceLimiter
+	for _, nc := range limits.Namespaces {
+		re, err := regexp.Compile(nc.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("namespace %s: regexp: %s", nc.Namespace, err)
+		}
+		l, err := bandwidth.NewLimiter(nc.Bandwidth, bandwidth.WithLogger(logger))
+		if err != nil {
+			return nil, fmt.Errorf("namespace %s: %s", nc.Namespace, err)
+		}
+		namespaces = append(namespaces, namespaceLimiter{re, l})
+	}
+	return &limiterSet{limits, global, namespaces}, nil
+}
+
+// limitersFor returns the limiters which apply to a connection for namespace,
+// including the connection's own peer limiter.
+func (s *limiterSet) limitersFor(namespace string) ([]*bandwidth.Limiter, error) {
+	ls := []*bandwidth.Limiter{s.global}
+	for _, n := range s.namespaces {
+		if n.regexp.MatchString(namespace) {
+			ls = append(ls, n.limiter)
+			break
+		}
+	}
+	peer, err := newPeerLimiter(s.limits.Peer)
+	if err != nil {
+		return nil, err
+	}
+	return append(ls, peer), nil
+}
+
+func newPeerLimiter(config bandwidth.Config) (*bandwidth.Limiter, error) {
+	// Peer limiters are created per connection, which makes their logs too noisy.
+	return bandwidth.NewLimiter(config, bandwidth.WithLogger(zap.NewNop().Sugar()))
+}
+
+// bandwidthManager manages the limiters of all live connections.
+type bandwidthManager struct {
+	logger *zap.SugaredLogger
+
+	mu      sync.Mutex // Protects the following fields:
+	current *limiterSet
+	conns   map[*connBandwidth]struct{}
+}
+
+func newBandwidthManager(
+	limits BandwidthLimits, logger *zap.SugaredLogger) (*bandwidthManager, error) {
+
+	s, err := newLimiterSet(limits, logger)
+	if err != nil {
+		return nil, err
+	}
+	return &bandwidthManager{
+		logger:  logger,
+		current: s,
+		conns:   make(map[*connBandwidth]struct{}),
+	}, nil
+}
+
+// newConn returns the bandwidth of a new connection for namespace. The
+// returned connBandwidth must be released once the connection closes.
+func (m *bandwidthManager) newConn(namespace string) (*connBandwidth, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ls, err := m.current.limitersFor(namespace)
+	if err != nil {
+		return nil, err
+	}
+	cb := &connBandwidth{manager: m, namespace: namespace, limiters: ls}
+	m.conns[cb] = struct{}{}
+	return cb, nil
+}
+
+func (m *bandwidthManager) release(cb *connBandwidth) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.conns, cb)
+}
+
+func (m *bandwidthManager) limits() BandwidthLimits {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.current.limits
+}
+
+// update replaces the limits of new and live connections. Replaced limiters
+// start with full buckets.
+func (m *bandwidthManager) update(limits BandwidthLimits) error {
+	s, err := newLimiterSet(limits, m.logger)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for cb := range m.conns {
+		ls, err := s.limitersFor(cb.namespace)
+		if err != nil {
+			return err
+		}
+		cb.setLimiters(ls)
+	}
+	m.current = s
+	return nil
+}
+
+// connBandwidth limits the bandwidth of a single connection.
+type connBandwidth struct {
+	manager   *bandwidthManager
+	namespace string
+
+	mu       sync.RWMutex
+	limiters []*bandwidth.Limiter
+}
+
+func (cb *connBandwidth) setLimiters(ls []*bandwidth.Limiter) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.limiters = ls
+}
+
+func (cb *connBandwidth) getLimiters() []*bandwidth.Limiter {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	return cb.limiters
+}
+
+// ReserveEgress blocks until egress bandwidth for nbytes is available under
+// every limit which applies to the connection.
+func (cb *connBandwidth) ReserveEgress(nbytes int64) error {
+	for _, l := range cb.getLimiters() {
+		if err := l.ReserveEgress(nbytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReserveIngress blocks until ingress bandwidth for nbytes is available under
+// every limit which applies to the connection.
+func (cb *connBandwidth) ReserveIngress(nbytes int64) error {
+	for _, l := range cb.getLimiters() {
+		if err := l.ReserveIngress(nbytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cb *connBandwidth) release() {
+	cb.manager.release(cb)
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package conn
+
+import (
+	"net"
+	"testing"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/torrent/storage"
+	"github.com/uber/kraken/utils/bandwidth"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func bandwidthConfigFixture(bps uint64) bandwidth.Config {
+	return bandwidth.Config{
+		EgressBitsPerSec:  bps,
+		IngressBitsPerSec: bps,
+		TokenSize:         1,
+		Enable:            true,
+	}
+}
+
+func TestBandwidthManagerNamespaceLimits(t *testing.T) {
+	require := require.New(t)
+
+	m, err := newBandwidthManager(BandwidthLimits{
+		Global: bandwidthConfigFixture(100),
+		Peer:   bandwidthConfigFixture(10),
+		Namespaces: []NamespaceBandwidthConfig{
+			{"foo/.*", bandwidthConfigFixture(50)},
+			{".*", bandwidthConfigFixture(20)},
+		},
+	}, zap.NewNop().Sugar())
+	require.NoError(err)
+
+	limits := func(cb *connBandwidth) []int64 {
+		var result []int64
+		for _, l := range cb.getLimiters() {
+			result = append(result, l.EgressLimit())
+		}
+		return result
+	}
+
+	foo, err := m.newConn("foo/bar")
+	require.NoError(err)
+	require.Equal([]int64{100, 50, 10}, limits(foo))
+
+	baz, err := m.newConn("baz")
+	require.NoError(err)
+	require.Equal([]int64{100, 20, 10}, limits(baz))
+
+	// Each connection has its own peer limiter.
+	require.True(foo.getLimiters()[0] == baz.getLimiters()[0])
+	require.False(foo.getLimiters()[2] == baz.getLimiters()[2])
+}
+
+func TestBandwidthManagerUpdateAppliesToLiveConns(t *testing.T) {
+	require := require.New(t)
+
+	m, err := newBandwidthManager(BandwidthLimits{
+		Global: bandwidthConfigFixture(100),
+		Peer:   bandwidthConfigFixture(10),
+	}, zap.NewNop().Sugar())
+	require.NoError(err)
+
+	cb, err := m.newConn("foo")
+	require.NoError(err)
+	released, err := m.newConn("foo")
+	require.NoError(err)
+	released.release()
+
+	limits := BandwidthLimits{
+		Global: bandwidthConfigFixture(80),
+		Peer:   bandwidthConfigFixture(5),
+		Namespaces: []NamespaceBandwidthConfig{
+			{"foo", bandwidthConfigFixture(40)},
+		},
+	}
+	require.NoError(m.update(limits))
+	require.Equal(limits, m.limits())
+
+	ls := cb.getLimiters()
+	require.Len(ls, 3)
+	require.Equal(int64(80), ls[0].EgressLimit())
+	require.Equal(int64(40), ls[1].EgressLimit())
+	require.Equal(int64(5), ls[2].IngressLimit())
+
+	// Released connections keep their original limiters.
+	require.Equal(int64(100), released.getLimiters()[0].EgressLimit())
+	require.Len(m.conns, 1)
+}
+
+func TestBandwidthManagerInvalidUpdate(t *testing.T) {
+	original := BandwidthLimits{Global: bandwidthConfigFixture(100)}
+
+	tests := []struct {
+		desc   string
+		limits BandwidthLimits
+	}{
+		{"invalid global", BandwidthLimits{Global: bandwidth.Config{Enable: true}}},
+		{"invalid peer", BandwidthLimits{Peer: bandwidth.Config{Enable: true}}},
+		{"invalid namespace regexp", BandwidthLimits{
+			Namespaces: []NamespaceBandwidthConfig{{"(", bandwidthConfigFixture(10)}},
+		}},
+		{"invalid namespace bandwidth", BandwidthLimits{
+			Namespaces: []NamespaceBandwidthConfig{{"foo", bandwidth.Config{Enable: true}}},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			m, err := newBandwidthManager(original, zap.NewNop().Sugar())
+			require.NoError(err)
+
+			require.Error(m.update(test.limits))
+			require.Equal(original, m.limits())
+		})
+	}
+}
+
+type closeEvents struct {
+	closed chan struct{}
+}
+
+func (e closeEvents) ConnClosed(*Conn) { close(e.closed) }
+
+func TestConnCloseReleasesBandwidth(t *testing.T) {
+	require := require.New(t)
+
+	h := HandshakerFixture(ConfigFixture())
+	nc1, nc2 := net.Pipe()
+	defer nc2.Close()
+
+	c, err := h.newConn(noopDeadline{nc1}, core.PeerIDFixture(), storage.TorrentInfoFixture(1, 1), "", false)
+	require.NoError(err)
+	c.Start()
+	require.Len(h.bandwidth.conns, 1)
+
+	closed := make(chan struct{})
+	c.events = closeEvents{closed}
+	c.Close()
+	<-closed
+	require.Empty(h.bandwidth.conns)
+}
@@ -37,7 +37,17 @@ type Config struct {
 	// is taking a long time to process a message.
 	ReceiverBufferSize int `yaml:"receiver_buffer_size"`
 
+	// Bandwidth limits the combined bandwidth of all connections.
 	Bandwidth bandwidth.Config `yaml:"bandwidth"`
+
+	// PeerBandwidth limits the bandwidth of each individual connection.
+	PeerBandwidth bandwidth.Config `yaml:"peer_bandwidth"`
+
+	// NamespaceBandwidth limits the combined bandwidth of connections by the
+	// namespace of their torrent.
+	NamespaceBandwidth []NamespaceBandwidthConfig `yaml:"namespace_bandwidth"`
+
+	Encryption EncryptionConfig `yaml:"encryption"`
 }
 
 func (c Config) applyDefaults() Config {
@@ -56,5 +66,14 @@ func (c Config) applyDefaults() Config {
 	if c.Bandwidth.IngressBitsPerSec == 0 {
 		c.Bandwidth.IngressBitsPerSec = 300 * 8 * memsize.Mbit
 	}
+	c.Encryption = c.Encryption.applyDefaults()
 	return c
 }
+
+func (c Config) bandwidthLimits() BandwidthLimits {
+	return BandwidthLimits{
+		Global:     c.Bandwidth,
+		Peer:       c.PeerBandwidth,
+		Namespaces: c.NamespaceBandwidth,
+	}
+}
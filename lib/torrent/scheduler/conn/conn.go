@@ -31,7 +31,6 @@ import (
 	"github.com/uber/kraken/lib/torrent/networkevent"
 	"github.com/uber/kraken/lib/torrent/storage"
 	"github.com/uber/kraken/lib/torrent/storage/piecereader"
-	"github.com/uber/kraken/utils/bandwidth"
 	"github.com/uber/kraken/utils/memsize"
 )
 
@@ -50,7 +49,7 @@ type Conn struct {
 	infoHash    core.InfoHash
 	createdAt   time.Time
 	localPeerID core.PeerID
-	bandwidth   *bandwidth.Limiter
+	bandwidth   *connBandwidth
 
 	events Events
 
@@ -85,7 +84,7 @@ func newConn(
 	stats tally.Scope,
 	clk clock.Clock,
 	networkEvents networkevent.Producer,
-	bandwidth *bandwidth.Limiter,
+	bandwidth *connBandwidth,
 	events Events,
 	nc net.Conn,
 	localPeerID core.PeerID,
@@ -185,6 +184,7 @@ func (c *Conn) Close() {
 		close(c.done)
 		c.nc.Close()
 		c.wg.Wait()
+		c.bandwidth.release()
 		c.events.ConnClosed(c)
 	}()
 }
@@ -0,0 +1,201 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package conn
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/uber-go/tally"
+
+	"github.com/uber/kraken/utils/httputil"
+)
+
+// Encryption modes for peer connections.
+const (
+	// EncryptionDisabled sends and accepts plaintext connections only.
+	EncryptionDisabled = "disabled"
+
+	// EncryptionPermissive opens TLS connections, but falls back to plaintext
+	// if the remote peer does not speak TLS, and accepts both TLS and plaintext
+	// connections. Intended for rolling encryption out across a cluster.
+	EncryptionPermissive = "permissive"
+
+	// EncryptionStrict opens and accepts TLS connections only.
+	EncryptionStrict = "strict"
+)
+
+// The first byte of a TLS connection is always the handshake record type,
+// whereas a plaintext connection starts with the big-endian length of the
+// handshake message, which maxMessageSize bounds well below 1<<24.
+const _tlsRecordTypeHandshake = 0x16
+
+// EncryptionConfig defines encryption of peer connections.
+type EncryptionConfig struct {
+	Mode string `yaml:"mode"`
+
+	// TLS supplies the certificate presented by this peer and the CAs used to
+	// verify remote peers. Both sides of a connection must present a valid
+	// certificate for Name. Required unless Mode is disabled.
+	TLS httputil.TLSConfig `yaml:"tls"`
+}
+
+func (c EncryptionConfig) applyDefaults() EncryptionConfig {
+	if c.Mode == "" {
+		c.Mode = EncryptionDisabled
+	}
+	return c
+}
+
+// encryptor upgrades raw network connections to TLS according to the
+// configured mode.
+type encryptor struct {
+	mode    string
+	client  *tls.Config
+	server  *tls.Config
+	timeout time.Duration
+	stats   tally.Scope
+}
+
+// newEncryptor returns a nil encryptor when encryption is disabled.
+func newEncryptor(
+	config EncryptionConfig, timeout time.Duration, stats tally.Scope) (*encryptor, error) {
+
+	switch config.Mode {
+	case EncryptionDisabled:
+		return nil, nil
+	case EncryptionPermissive, EncryptionStrict:
+	default:
+		return nil, fmt.Errorf("invalid mode %q", config.Mode)
+	}
+	if config.TLS.Name == "" {
+		return nil, errors.New("tls name required")
+	}
+	if config.TLS.Client.Disabled || config.TLS.Client.Cert.Path == "" {
+		return nil, errors.New("tls client cert required")
+	}
+	if len(config.TLS.CAs) == 0 {
+		return nil, errors.New("tls cas required")
+	}
+	client, err := config.TLS.BuildClient()
+	if err != nil {
+		return nil, fmt.Errorf("build tls config: %s", err)
+	}
+	// Peers are symmetric, so the same certificate and CAs are used whether the
+	// local peer opened the connection or accepted it. The certificate and CAs
+	// are read through the client config such that reloads apply to both.
+	tlsConfig := &config.TLS
+	base := &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return client.GetClientCertificate(nil)
+		},
+		ClientAuth: tls.RequireAndVerifyClientCert,
+	}
+	server := base.Clone()
+	server.ClientCAs = tlsConfig.CurrentCAs()
+	server.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c := base.Clone()
+		c.ClientCAs = tlsConfig.CurrentCAs()
+		return c, nil
+	}
+	return &encryptor{
+		mode:    config.Mode,
+		client:  client,
+		server:  server,
+		timeout: timeout,
+		stats: stats.Tagged(map[string]string{
+			"encryption": config.Mode,
+		}),
+	}, nil
+}
+
+// accept detects whether the remote peer opened nc with TLS and upgrades it
+// if so. Plaintext connections are rejected in strict mode.
+func (e *encryptor) accept(nc net.Conn) (net.Conn, error) {
+	if e == nil {
+		return nc, nil
+	}
+	if err := nc.SetReadDeadline(time.Now().Add(e.timeout)); err != nil {
+		return nil, fmt.Errorf("set read deadline: %s", err)
+	}
+	var b [1]byte
+	if _, err := io.ReadFull(nc, b[:]); err != nil {
+		return nil, fmt.Errorf("read first byte: %s", err)
+	}
+	pc := &peekedConn{nc, io.MultiReader(bytes.NewReader(b[:]), nc)}
+	if b[0] != _tlsRecordTypeHandshake {
+		if e.mode == EncryptionStrict {
+			e.stats.Counter("plaintext_conns_rejected").Inc(1)
+			return nil, errors.New("plaintext connection rejected by strict encryption")
+		}
+		e.stats.Counter("plaintext_conns_accepted").Inc(1)
+		return pc, nil
+	}
+	tc := tls.Server(pc, e.server)
+	if err := e.handshake(tc); err != nil {
+		return nil, fmt.Errorf("tls handshake: %s", err)
+	}
+	return tc, nil
+}
+
+// dial opens a connection to addr, using TLS unless encryption is disabled.
+// In permissive mode, the connection is re-dialed in plaintext if the TLS
+// handshake fails.
+func (e *encryptor) dial(addr string, timeout time.Duration) (net.Conn, error) {
+	nc, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("dial: %s", err)
+	}
+	if e == nil {
+		return nc, nil
+	}
+	tc := tls.Client(nc, e.client)
+	if err := e.handshake(tc); err != nil {
+		nc.Close()
+		if e.mode == EncryptionStrict {
+			return nil, fmt.Errorf("tls handshake: %s", err)
+		}
+		e.stats.Counter("plaintext_fallbacks").Inc(1)
+		nc, err = net.DialTimeout("tcp", addr, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("dial plaintext: %s", err)
+		}
+		return nc, nil
+	}
+	return tc, nil
+}
+
+func (e *encryptor) handshake(tc *tls.Conn) error {
+	// NOTE: We do not use the clock interface here because the net package uses
+	// the system clock when evaluating deadlines.
+	if err := tc.SetDeadline(time.Now().Add(e.timeout)); err != nil {
+		return fmt.Errorf("set deadline: %s", err)
+	}
+	return tc.Handshake()
+}
+
+// peekedConn replays bytes already read from the underlying Conn.
+type peekedConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *peekedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package conn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/torrent/networkevent"
+	"github.com/uber/kraken/lib/torrent/storage"
+	"github.com/uber/kraken/utils/httputil"
+	"github.com/uber/kraken/utils/testutil"
+)
+
+// genTLSConfig generates a self-signed certificate for "kraken" which every
+// peer presents and trusts.
+func genTLSConfig(t *testing.T) (config httputil.TLSConfig, cleanupFunc func()) {
+	require := require.New(t)
+
+	var cleanup testutil.Cleanup
+	defer cleanup.Recover()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(err)
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kraken"},
+		DNSNames:              []string{"kraken"},
+		NotBefore:             time.Now().Add(-5 * time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, priv.Public(), priv)
+	require.NoError(err)
+	keyDER, err := x509.MarshalECPrivateKey(priv)
+	require.NoError(err)
+
+	certPath, c := testutil.TempFile(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	cleanup.Add(c)
+	keyPath, c := testutil.TempFile(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	cleanup.Add(c)
+
+	config.Name = "kraken"
+	config.CAs = []httputil.Secret{{Path: certPath}}
+	config.Client.Cert.Path = certPath
+	config.Client.Key.Path = keyPath
+
+	return config, cleanup.Run
+}
+
+func encryptionConfigFixture(mode string, tlsConfig httputil.TLSConfig) Config {
+	config := ConfigFixture()
+	config.Encryption = EncryptionConfig{Mode: mode, TLS: tlsConfig}
+	return config
+}
+
+type acceptResult struct {
+	pc  *PendingConn
+	err error
+}
+
+// acceptLoop accepts connections from l until one completes the handshake,
+// since a permissive peer may dial again after a failed TLS handshake.
+func acceptLoop(l net.Listener, h *Handshaker, info *storage.TorrentInfo) <-chan acceptResult {
+	results := make(chan acceptResult, 1)
+	go func() {
+		var err error
+		for {
+			var nc net.Conn
+			nc, err = l.Accept()
+			if err != nil {
+				break
+			}
+			var pc *PendingConn
+			pc, err = h.Accept(nc)
+			if err != nil {
+				nc.Close()
+				continue
+			}
+			if _, err = h.Establish(pc, info, nil); err != nil {
+				break
+			}
+			results <- acceptResult{pc: pc}
+			return
+		}
+		results <- acceptResult{err: err}
+	}()
+	return results
+}
+
+func TestHandshakerEncryption(t *testing.T) {
+	tlsConfig, cleanup := genTLSConfig(t)
+	defer cleanup()
+
+	tests := []struct {
+		desc      string
+		initiator string
+		acceptor  string
+		encrypted bool
+	}{
+		{"strict to strict", EncryptionStrict, EncryptionStrict, true},
+		{"permissive to strict", EncryptionPermissive, EncryptionStrict, true},
+		{"permissive to permissive", EncryptionPermissive, EncryptionPermissive, true},
+		{"disabled to permissive", EncryptionDisabled, EncryptionPermissive, false},
+		{"permissive to disabled", EncryptionPermissive, EncryptionDisabled, false},
+		{"disabled to disabled", EncryptionDisabled, EncryptionDisabled, false},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			l, err := net.Listen("tcp", "localhost:0")
+			require.NoError(err)
+			defer l.Close()
+
+			h1 := HandshakerFixture(encryptionConfigFixture(test.acceptor, tlsConfig))
+			h2 := HandshakerFixture(encryptionConfigFixture(test.initiator, tlsConfig))
+
+			info := storage.TorrentInfoFixture(4, 1)
+			results := acceptLoop(l, h1, info)
+
+			r, err := h2.Initialize(h1.peerID, l.Addr().String(), info, nil, core.TagFixture())
+			require.NoError(err)
+			defer r.Conn.Close()
+			_, ok := r.Conn.nc.(*tls.Conn)
+			require.Equal(test.encrypted, ok)
+
+			result := <-results
+			require.NoError(result.err)
+			defer result.pc.Close()
+			_, ok = result.pc.nc.(*tls.Conn)
+			require.Equal(test.encrypted, ok)
+		})
+	}
+}
+
+func TestHandshakerStrictEncryptionRejectsPlaintext(t *testing.T) {
+	tlsConfig, cleanup := genTLSConfig(t)
+	defer cleanup()
+
+	tests := []struct {
+		desc      string
+		initiator string
+		acceptor  string
+	}{
+		{"strict acceptor", EncryptionDisabled, EncryptionStrict},
+		{"strict initiator", EncryptionStrict, EncryptionDisabled},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			l, err := net.Listen("tcp", "localhost:0")
+			require.NoError(err)
+			defer l.Close()
+
+			h1 := HandshakerFixture(encryptionConfigFixture(test.acceptor, tlsConfig))
+			h2 := HandshakerFixture(encryptionConfigFixture(test.initiator, tlsConfig))
+
+			info := storage.TorrentInfoFixture(4, 1)
+			acceptLoop(l, h1, info)
+
+			_, err = h2.Initialize(h1.peerID, l.Addr().String(), info, nil, core.TagFixture())
+			require.Error(err)
+		})
+	}
+}
+
+func TestNewHandshakerInvalidEncryptionConfig(t *testing.T) {
+	tlsConfig, cleanup := genTLSConfig(t)
+	defer cleanup()
+
+	noCert := tlsConfig
+	noCert.Client = httputil.X509Pair{}
+
+	tests := []struct {
+		desc   string
+		config EncryptionConfig
+	}{
+		{"unknown mode", EncryptionConfig{Mode: "foo", TLS: tlsConfig}},
+		{"no tls config", EncryptionConfig{Mode: EncryptionStrict}},
+		{"no cert", EncryptionConfig{Mode: EncryptionPermissive, TLS: noCert}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			config := ConfigFixture()
+			config.Encryption = test.config
+			_, err := NewHandshaker(
+				config,
+				tally.NewTestScope("", nil),
+				clock.New(),
+				networkevent.NewTestProducer(),
+				core.PeerIDFixture(),
+				noopEvents{},
+				zap.NewNop().Sugar())
+			require.Error(t, err)
+		})
+	}
+}
@@ -22,7 +22,6 @@ import (
 	"github.com/uber/kraken/gen/go/proto/p2p"
 	"github.com/uber/kraken/lib/torrent/networkevent"
 	"github.com/uber/kraken/lib/torrent/storage"
-	"github.com/uber/kraken/utils/bandwidth"
 
 	"github.com/andres-erbsen/clock"
 	"github.com/uber-go/tally"
@@ -188,7 +187,8 @@ type Handshaker struct {
 	config        Config
 	stats         tally.Scope
 	clk           clock.Clock
-	bandwidth     *bandwidth.Limiter
+	bandwidth     *bandwidthManager
+	encryptor     *encryptor
 	networkEvents networkevent.Producer
 	peerID        core.PeerID
 	events        Events
@@ -210,16 +210,22 @@ func NewHandshaker(
 		"module": "conn",
 	})
 
-	bl, err := bandwidth.NewLimiter(config.Bandwidth, bandwidth.WithLogger(logger))
+	bm, err := newBandwidthManager(config.bandwidthLimits(), logger)
 	if err != nil {
 		return nil, fmt.Errorf("bandwidth: %s", err)
 	}
 
+	e, err := newEncryptor(config.Encryption, config.HandshakeTimeout, stats)
+	if err != nil {
+		return nil, fmt.Errorf("encryption: %s", err)
+	}
+
 	return &Handshaker{
 		config:        config,
 		stats:         stats,
 		clk:           clk,
-		bandwidth:     bl,
+		bandwidth:     bm,
+		encryptor:     e,
 		networkEvents: networkEvents,
 		peerID:        peerID,
 		events:        events,
@@ -227,8 +233,13 @@ func NewHandshaker(
 }
 
 // Accept upgrades a raw network connection opened by a remote peer into a
-// PendingConn.
+// PendingConn. The connection is upgraded to TLS first if the remote peer
+// opened it with TLS.
 func (h *Handshaker) Accept(nc net.Conn) (*PendingConn, error) {
+	nc, err := h.encryptor.accept(nc)
+	if err != nil {
+		return nil, fmt.Errorf("encryption: %s", err)
+	}
 	hs, err := h.readHandshake(nc)
 	if err != nil {
 		return nil, fmt.Errorf("read handshake: %s", err)
@@ -248,7 +259,7 @@ func (h *Handshaker) Establish(
 	if err := h.sendHandshake(pc.nc, info, remoteBitfields, ""); err != nil {
 		return nil, fmt.Errorf("send handshake: %s", err)
 	}
-	c, err := h.newConn(pc.nc, pc.handshake.peerID, info, true)
+	c, err := h.newConn(pc.nc, pc.handshake.peerID, info, pc.handshake.namespace, true)
 	if err != nil {
 		return nil, fmt.Errorf("new conn: %s", err)
 	}
@@ -265,9 +276,9 @@ func (h *Handshaker) Initialize(
 	remoteBitfields RemoteBitfields,
 	namespace string) (*HandshakeResult, error) {
 
-	nc, err := net.DialTimeout("tcp", addr, h.config.HandshakeTimeout)
+	nc, err := h.encryptor.dial(addr, h.config.HandshakeTimeout)
 	if err != nil {
-		return nil, fmt.Errorf("dial: %s", err)
+		return nil, err
 	}
 	r, err := h.fullHandshake(nc, peerID, info, remoteBitfields, namespace)
 	if err != nil {
@@ -327,7 +338,7 @@ func (h *Handshaker) fullHandshake(
 	if hs.peerID != peerID {
 		return nil, errors.New("unexpected peer id")
 	}
-	c, err := h.newConn(nc, peerID, info, false)
+	c, err := h.newConn(nc, peerID, info, namespace, false)
 	if err != nil {
 		return nil, fmt.Errorf("new conn: %s", err)
 	}
@@ -338,14 +349,19 @@ func (h *Handshaker) newConn(
 	nc net.Conn,
 	peerID core.PeerID,
 	info *storage.TorrentInfo,
+	namespace string,
 	openedByRemote bool) (*Conn, error) {
 
-	return newConn(
+	cb, err := h.bandwidth.newConn(namespace)
+	if err != nil {
+		return nil, fmt.Errorf("bandwidth: %s", err)
+	}
+	c, err := newConn(
 		h.config,
 		h.stats,
 		h.clk,
 		h.networkEvents,
-		h.bandwidth,
+		cb,
 		h.events,
 		nc,
 		h.peerID,
@@ -353,4 +369,20 @@ func (h *Handshaker) newConn(
 		info,
 		openedByRemote,
 		zap.NewNop().Sugar())
+	if err != nil {
+		cb.release()
+		return nil, err
+	}
+	return c, nil
+}
+
+// BandwidthLimits returns the current bandwidth limits.
+func (h *Handshaker) BandwidthLimits() BandwidthLimits {
+	return h.bandwidth.limits()
+}
+
+// SetBandwidthLimits replaces the bandwidth limits of both new and existing
+// connections.
+func (h *Handshaker) SetBandwidthLimits(limits BandwidthLimits) error {
+	return h.bandwidth.update(limits)
 }
@@ -126,6 +126,17 @@ func (s *State) ActiveConns() []*conn.Conn {
 	return active
 }
 
+// PendingConns returns the peers of all pending connections for h.
+func (s *State) PendingConns(h core.InfoHash) []core.PeerID {
+	var pending []core.PeerID
+	for peerID, e := range s.conns[h] {
+		if e.status == _pending {
+			pending = append(pending, peerID)
+		}
+	}
+	return pending
+}
+
 // Saturated returns true if h is at capacity and all the conns are active.
 func (s *State) Saturated(h core.InfoHash) bool {
 	peers, ok := s.conns[h]
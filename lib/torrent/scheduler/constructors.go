@@ -41,12 +41,34 @@ func NewAgentScheduler(
 	trackers hashring.PassiveRing,
 	tls *tls.Config) (ReloadableScheduler, error) {
 
-	s, err := newScheduler(
+	return NewAgentSchedulerWithClients(
 		config,
-		agentstorage.NewTorrentArchive(stats, cads, metainfoclient.New(trackers, tls)),
 		stats,
 		pctx,
+		cads,
+		netevents,
 		announceclient.New(pctx, trackers, tls),
+		metainfoclient.New(trackers, tls))
+}
+
+// NewAgentSchedulerWithClients creates and starts a ReloadableScheduler
+// configured for an agent which discovers peers and downloads metainfo via
+// the given clients instead of the tracker.
+func NewAgentSchedulerWithClients(
+	config Config,
+	stats tally.Scope,
+	pctx core.PeerContext,
+	cads *store.CADownloadStore,
+	netevents networkevent.Producer,
+	announceClient announceclient.Client,
+	metaInfoClient metainfoclient.Client) (ReloadableScheduler, error) {
+
+	s, err := newScheduler(
+		config,
+		agentstorage.NewTorrentArchive(stats, cads, metaInfoClient),
+		stats,
+		pctx,
+		announceClient,
 		netevents)
 	if err != nil {
 		return nil, fmt.Errorf("new scheduler: %s", err)
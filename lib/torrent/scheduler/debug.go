@@ -0,0 +1,83 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package scheduler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/uber/kraken/lib/torrent/scheduler/conn"
+	"github.com/uber/kraken/utils/handler"
+	"github.com/uber/kraken/utils/httputil"
+
+	"github.com/go-chi/chi"
+)
+
+// debugState is the state of a Scheduler as rendered by DebugHandler.
+type debugState struct {
+	Bandwidth conn.BandwidthLimits `json:"bandwidth"`
+	Torrents  []TorrentStatus      `json:"torrents"`
+}
+
+// DebugHandler returns an http.Handler which exposes the state of s and allows
+// operators to intervene in individual torrents:
+//
+//   GET /                      lists all torrents.
+//   DELETE /{digest}           cancels a torrent (see CancelTorrent).
+//   POST /{digest}/announce    re-announces a torrent (see Reannounce).
+func DebugHandler(s Scheduler) http.Handler {
+	r := chi.NewRouter()
+
+	r.Get("/", handler.Wrap(func(w http.ResponseWriter, r *http.Request) error {
+		torrents, err := s.TorrentStatuses()
+		if err != nil {
+			return handler.Errorf("torrent statuses: %s", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(debugState{s.BandwidthLimits(), torrents}); err != nil {
+			return handler.Errorf("json encode: %s", err)
+		}
+		return nil
+	}))
+
+	r.Delete("/{digest}", handler.Wrap(func(w http.ResponseWriter, r *http.Request) error {
+		d, err := httputil.ParseDigest(r, "digest")
+		if err != nil {
+			return err
+		}
+		if err := s.CancelTorrent(d); err != nil {
+			if err == ErrTorrentNotFound {
+				return handler.ErrorStatus(http.StatusNotFound)
+			}
+			return handler.Errorf("cancel torrent: %s", err)
+		}
+		return nil
+	}))
+
+	r.Post("/{digest}/announce", handler.Wrap(func(w http.ResponseWriter, r *http.Request) error {
+		d, err := httputil.ParseDigest(r, "digest")
+		if err != nil {
+			return err
+		}
+		if err := s.Reannounce(d); err != nil {
+			if err == ErrTorrentNotFound {
+				return handler.ErrorStatus(http.StatusNotFound)
+			}
+			return handler.Errorf("reannounce: %s", err)
+		}
+		return nil
+	}))
+
+	return r
+}
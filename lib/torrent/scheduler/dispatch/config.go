@@ -41,12 +41,29 @@ type Config struct {
 	// at the same time.
 	PipelineLimit int `yaml:"pipeline_limit"`
 
+	// PreemptedPipelineLimit replaces PipelineLimit while the torrent is
+	// preempted by a higher priority torrent.
+	PreemptedPipelineLimit int `yaml:"preempted_pipeline_limit"`
+
 	// EndgameThreshold is the number pieces required to complete the torrent
 	// before the torrent enters "endgame", where we start overloading piece
 	// requests to multiple peers.
 	EndgameThreshold int `yaml:"endgame_threshold"`
 
 	DisableEndgame bool `yaml:"disable_endgame"`
+
+	// DisablePartialSeeding stops incomplete torrents from advertising and
+	// serving their verified pieces, such that peers only seed once the entire
+	// blob has been downloaded.
+	DisablePartialSeeding bool `yaml:"disable_partial_seeding"`
+
+	// SuperSeeding enables super-seeding of complete torrents to peers when the
+	// local peer is the only known seeder. See superSeeder.
+	SuperSeeding bool `yaml:"super_seeding"`
+
+	// SuperSeedRevealLimit is the number of pieces a super-seeded peer may have
+	// revealed to it which it does not have yet.
+	SuperSeedRevealLimit int `yaml:"super_seed_reveal_limit"`
 }
 
 func (c Config) applyDefaults() Config {
@@ -62,6 +79,12 @@ func (c Config) applyDefaults() Config {
 	if c.PipelineLimit == 0 {
 		c.PipelineLimit = 3
 	}
+	if c.PreemptedPipelineLimit == 0 {
+		c.PreemptedPipelineLimit = 1
+	}
+	if c.SuperSeedRevealLimit == 0 {
+		c.SuperSeedRevealLimit = c.PipelineLimit
+	}
 	if c.EndgameThreshold == 0 {
 		c.EndgameThreshold = c.PipelineLimit
 	}
@@ -31,6 +31,7 @@ import (
 	"github.com/andres-erbsen/clock"
 	"github.com/uber-go/tally"
 	"github.com/willf/bitset"
+	"go.uber.org/atomic"
 	"go.uber.org/zap"
 	"golang.org/x/sync/syncmap"
 )
@@ -40,6 +41,7 @@ var (
 	errPieceOutOfBounds        = errors.New("piece index out of bounds")
 	errChunkNotSupported       = errors.New("reading / writing chunk of piece not supported")
 	errRepeatedBitfieldMessage = errors.New("received repeated bitfield message")
+	errPartialSeedingDisabled  = errors.New("partial seeding disabled, torrent not complete")
 )
 
 // Events defines Dispatcher events.
@@ -75,6 +77,9 @@ type Dispatcher struct {
 	pendingPiecesDoneOnce sync.Once
 	pendingPiecesDone     chan struct{}
 	completeOnce          sync.Once
+	preempted             *atomic.Bool
+	superSeeder           *superSeeder
+	decisions             decisionLog
 	events                Events
 	logger                *zap.SugaredLogger
 	torrentlog            *torrentlog.Logger
@@ -132,6 +137,11 @@ func newDispatcher(
 		return nil, fmt.Errorf("piece request manager: %s", err)
 	}
 
+	var ss *superSeeder
+	if config.SuperSeeding {
+		ss = newSuperSeeder(t.NumPieces(), config.SuperSeedRevealLimit)
+	}
+
 	return &Dispatcher{
 		config:              config,
 		stats:               stats,
@@ -144,6 +154,8 @@ func newDispatcher(
 		pieceRequestTimeout: pieceRequestTimeout,
 		pieceRequestManager: pieceRequestManager,
 		pendingPiecesDone:   make(chan struct{}),
+		preempted:           atomic.NewBool(false),
+		superSeeder:         ss,
 		events:              events,
 		logger:              logger,
 		torrentlog:          tlog,
@@ -180,6 +192,34 @@ func (d *Dispatcher) CreatedAt() time.Time {
 	return d.createdAt
 }
 
+// Preempted returns true if d's piece requests are limited in favor of higher
+// priority torrents.
+func (d *Dispatcher) Preempted() bool {
+	return d.preempted.Load()
+}
+
+// SetPreempted limits the number of pending piece requests per peer to
+// Config.PreemptedPipelineLimit while preempted is true, so that higher
+// priority torrents are served first. Clearing preemption immediately
+// requests more pieces from all peers.
+func (d *Dispatcher) SetPreempted(preempted bool) {
+	if !d.preempted.CAS(!preempted, preempted) {
+		return
+	}
+	if preempted {
+		d.pieceRequestManager.SetPipelineLimit(d.config.PreemptedPipelineLimit)
+		return
+	}
+	d.pieceRequestManager.SetPipelineLimit(d.config.PipelineLimit)
+	d.peers.Range(func(k, v interface{}) bool {
+		p := v.(*peer)
+		if _, err := d.maybeRequestMorePieces(p); err != nil {
+			d.log("peer", p).Infof("Error requesting pieces after preemption: %s", err)
+		}
+		return true
+	})
+}
+
 // LastGoodPieceReceived returns when d last received a valid and needed piece
 // from peerID.
 func (d *Dispatcher) LastGoodPieceReceived(peerID core.PeerID) time.Time {
@@ -238,11 +278,57 @@ func (d *Dispatcher) AddPeer(
 	if err != nil {
 		return err
 	}
+	if !d.partialSeeding() && d.Complete() && !p.bitfield.Complete() {
+		// The torrent may have completed after an empty bitfield was
+		// advertised at handshake.
+		p.messages.Send(conn.NewCompleteMessage())
+	}
 	go d.maybeRequestMorePieces(p)
 	go d.feed(p)
 	return nil
 }
 
+// partialSeeding returns true if verified pieces of an incomplete torrent are
+// announced and served to peers.
+func (d *Dispatcher) partialSeeding() bool {
+	return !d.config.DisablePartialSeeding
+}
+
+// SuperSeeding returns true if new peers should be super-seeded, i.e. sent an
+// empty bitfield at handshake and added via AddSuperSeededPeer. Callers should
+// also check that no other seeder is known.
+func (d *Dispatcher) SuperSeeding() bool {
+	return d.superSeeder != nil && d.Complete() && d.superSeeder.active()
+}
+
+// AddSuperSeededPeer adds a peer which was sent an empty bitfield at handshake
+// and reveals the first pieces to it. If super-seeding has since finished, the
+// peer is told that all pieces are available instead.
+func (d *Dispatcher) AddSuperSeededPeer(
+	peerID core.PeerID, b *bitset.BitSet, messages Messages) error {
+
+	p, err := d.addPeer(peerID, b, messages)
+	if err != nil {
+		return err
+	}
+	if d.superSeeder != nil && d.superSeeder.addPeer(peerID) {
+		d.revealPieces(p)
+	} else {
+		p.messages.Send(conn.NewCompleteMessage())
+	}
+	go d.feed(p)
+	return nil
+}
+
+// revealPieces announces the next super-seeded pieces to p.
+func (d *Dispatcher) revealPieces(p *peer) {
+	for _, i := range d.superSeeder.reveal(p.id, p.bitfield.Copy()) {
+		if err := p.messages.Send(conn.NewAnnouncePieceMessage(i)); err != nil {
+			return
+		}
+	}
+}
+
 // addPeer creates and inserts a new peer into the Dispatcher. Split from AddPeer
 // with no goroutine side-effects for testing purposes.
 func (d *Dispatcher) addPeer(
@@ -266,6 +352,9 @@ func (d *Dispatcher) addPeer(
 
 func (d *Dispatcher) removePeer(p *peer) error {
 	d.peers.Delete(p.id)
+	if d.superSeeder != nil {
+		d.superSeeder.removePeer(p.id)
+	}
 	d.pieceRequestManager.ClearPeer(p.id)
 
 	for _, i := range p.bitfield.GetAllSet() {
@@ -340,6 +429,7 @@ func (d *Dispatcher) complete() {
 			RequestsSent:   requested,
 			GoodPiecesReceived: pstats.getGoodPiecesReceived(),
 			DuplicatePiecesReceived: pstats.getDuplicatePiecesReceived(),
+			CorruptPiecesReceived: pstats.getCorruptPiecesReceived(),
 		}
 		summaries = append(summaries, summary)
 		return true
@@ -384,8 +474,9 @@ func (d *Dispatcher) maybeSendPieceRequests(p *peer, candidates *bitset.BitSet)
 		}
 		d.netevents.Produce(
 			networkevent.RequestPieceEvent(d.torrent.InfoHash(), d.localPeerID, p.id, i))
-			p.pstats.incrementPieceRequestsSent()
-		}
+		p.pstats.incrementPieceRequestsSent()
+		d.decide(p.id, DecisionRequest, i, "")
+	}
 	return true, nil
 }
 
@@ -398,6 +489,7 @@ func (d *Dispatcher) resendFailedPieceRequests() {
 
 	var sent int
 	for _, r := range failedRequests {
+		d.decide(r.PeerID, DecisionResend, r.Piece, failedRequestReason(r.Status))
 		d.peers.Range(func(k, v interface{}) bool {
 			p := v.(*peer)
 			if (r.Status == piecerequest.StatusExpired || r.Status == piecerequest.StatusInvalid) &&
@@ -486,6 +578,12 @@ func (d *Dispatcher) handleAnnouncePiece(p *peer, msg *p2p.AnnouncePieceMessage)
 	p.bitfield.Set(uint(i), true)
 	d.numPeersByPiece.Increment(int(i))
 
+	// Peers may obtain revealed pieces from each other, in which case they
+	// need more pieces revealed.
+	if d.superSeeder != nil {
+		d.revealPieces(p)
+	}
+
 	d.maybeRequestMorePieces(p)
 }
 
@@ -499,13 +597,22 @@ func (d *Dispatcher) handlePieceRequest(p *peer, msg *p2p.PieceRequestMessage) {
 	i := int(msg.Index)
 	if !d.isFullPiece(i, int(msg.Offset), int(msg.Length)) {
 		d.log("peer", p, "piece", i).Error("Rejecting piece request: chunk not supported")
+		d.decide(p.id, DecisionReject, i, errChunkNotSupported.Error())
 		p.messages.Send(conn.NewErrorMessage(i, p2p.ErrorMessage_PIECE_REQUEST_FAILED, errChunkNotSupported))
 		return
 	}
 
+	if !d.partialSeeding() && !d.torrent.Complete() {
+		d.log("peer", p, "piece", i).Error("Rejecting piece request: torrent not complete")
+		d.decide(p.id, DecisionReject, i, errPartialSeedingDisabled.Error())
+		p.messages.Send(conn.NewErrorMessage(i, p2p.ErrorMessage_PIECE_REQUEST_FAILED, errPartialSeedingDisabled))
+		return
+	}
+
 	payload, err := d.torrent.GetPieceReader(i)
 	if err != nil {
 		d.log("peer", p, "piece", i).Errorf("Error getting reader for requested piece: %s", err)
+		d.decide(p.id, DecisionReject, i, err.Error())
 		p.messages.Send(conn.NewErrorMessage(i, p2p.ErrorMessage_PIECE_REQUEST_FAILED, err))
 		return
 	}
@@ -519,6 +626,25 @@ func (d *Dispatcher) handlePieceRequest(p *peer, msg *p2p.PieceRequestMessage) {
 
 	// Assume that the peer successfully received the piece.
 	p.bitfield.Set(uint(i), true)
+
+	if d.superSeeder != nil {
+		d.handleSuperSeedPieceSent(p, i)
+	}
+}
+
+func (d *Dispatcher) handleSuperSeedPieceSent(p *peer, i int) {
+	finished := d.superSeeder.markSent(i)
+	if finished == nil {
+		d.revealPieces(p)
+		return
+	}
+	d.log().Info("Super-seeding finished, announcing all pieces")
+	d.stats.Counter("super_seeding_finished").Inc(1)
+	for _, peerID := range finished {
+		if v, ok := d.peers.Load(peerID); ok {
+			v.(*peer).messages.Send(conn.NewCompleteMessage())
+		}
+	}
 }
 
 func (d *Dispatcher) handlePiecePayload(
@@ -529,16 +655,33 @@ func (d *Dispatcher) handlePiecePayload(
 	i := int(msg.Index)
 	if !d.isFullPiece(i, int(msg.Offset), int(msg.Length)) {
 		d.log("peer", p, "piece", i).Error("Rejecting piece payload: chunk not supported")
+		d.decide(p.id, DecisionInvalid, i, errChunkNotSupported.Error())
 		d.pieceRequestManager.MarkInvalid(p.id, i)
 		return
 	}
 
 	if err := d.torrent.WritePiece(payload, i); err != nil {
-		if err != storage.ErrPieceComplete {
+		switch err {
+		case storage.ErrPieceComplete:
+			p.pstats.incrementDuplicatePiecesReceived()
+		case storage.ErrPieceCorrupt:
+			d.log("peer", p, "piece", i).Error("Received corrupt piece payload")
+			d.stats.Tagged(map[string]string{"source": "peer"}).Counter("corrupt_pieces").Inc(1)
+			p.pstats.incrementCorruptPiecesReceived()
+			d.pieceRequestManager.MarkInvalid(p.id, i)
+			d.decide(p.id, DecisionInvalid, i, err.Error())
+		case storage.ErrBlobCorrupt:
+			// Any of the peers may have sent the corrupt data, and the torrent
+			// has been reset, so all pieces are requested again.
+			d.log("peer", p, "piece", i).Error("Completed torrent does not match digest, starting over")
+			d.stats.Tagged(map[string]string{"source": "peer"}).Counter("corrupt_blobs").Inc(1)
+			d.pieceRequestManager.MarkInvalid(p.id, i)
+			d.decide(p.id, DecisionInvalid, i, err.Error())
+			d.maybeRequestMorePieces(p)
+		default:
 			d.log("peer", p, "piece", i).Errorf("Error writing piece payload: %s", err)
 			d.pieceRequestManager.MarkInvalid(p.id, i)
-		} else {
-			p.pstats.incrementDuplicatePiecesReceived()
+			d.decide(p.id, DecisionInvalid, i, err.Error())
 		}
 		return
 	}
@@ -556,6 +699,12 @@ func (d *Dispatcher) handlePiecePayload(
 
 	d.maybeRequestMorePieces(p)
 
+	if !d.partialSeeding() {
+		// Peers are sent a complete message instead once all pieces are
+		// downloaded.
+		return
+	}
+
 	d.peers.Range(func(k, v interface{}) bool {
 		if k.(core.PeerID) == p.id {
 			return true
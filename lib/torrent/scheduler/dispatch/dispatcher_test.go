@@ -15,6 +15,7 @@ package dispatch
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 	"testing"
 	"time"
@@ -174,6 +175,36 @@ func TestDispatcherSendUniquePieceRequestsWithinLimit(t *testing.T) {
 	}
 }
 
+func TestDispatcherPreemptionLimitsPieceRequests(t *testing.T) {
+	require := require.New(t)
+
+	config := Config{
+		PipelineLimit:          3,
+		PreemptedPipelineLimit: 1,
+	}
+	clk := clock.NewMock()
+
+	torrent, cleanup := agentstorage.TorrentFixture(core.SizedBlobFixture(100, 1).MetaInfo)
+	defer cleanup()
+
+	d := testDispatcher(config, clk, torrent)
+
+	d.SetPreempted(true)
+	require.True(d.Preempted())
+
+	p, err := d.addPeer(
+		core.PeerIDFixture(), bitset.New(uint(torrent.NumPieces())).Complement(), newMockMessages())
+	require.NoError(err)
+	_, err = d.maybeRequestMorePieces(p)
+	require.NoError(err)
+	require.Len(numRequestsPerPiece(p.messages), 1)
+
+	// Clearing preemption fills the pipeline back up.
+	d.SetPreempted(false)
+	require.False(d.Preempted())
+	require.Len(numRequestsPerPiece(p.messages), 3)
+}
+
 func TestDispatcherResendFailedPieceRequests(t *testing.T) {
 	require := require.New(t)
 
@@ -367,6 +398,37 @@ func TestDispatcherHandlePiecePayloadSendsCompleteMessage(t *testing.T) {
 	require.True(hasComplete(p2.messages))
 }
 
+func TestDispatcherPartialSeedingDisabled(t *testing.T) {
+	require := require.New(t)
+
+	blob := core.SizedBlobFixture(2, 1)
+
+	torrent, cleanup := agentstorage.TorrentFixture(blob.MetaInfo)
+	defer cleanup()
+
+	d := testDispatcher(Config{DisablePartialSeeding: true}, clock.NewMock(), torrent)
+
+	p1, err := d.addPeer(core.PeerIDFixture(), bitsetutil.FromBools(true, true), newMockMessages())
+	require.NoError(err)
+
+	p2, err := d.addPeer(core.PeerIDFixture(), bitsetutil.FromBools(false, false), newMockMessages())
+	require.NoError(err)
+
+	require.NoError(d.dispatch(p1, conn.NewPiecePayloadMessage(0, piecereader.NewBuffer(blob.Content[0:1]))))
+
+	// Verified pieces are neither announced nor served until complete.
+	require.Empty(announcedPieces(p2.messages))
+
+	require.NoError(d.dispatch(p2, conn.NewPieceRequestMessage(0, 1)))
+	sent := p2.messages.(*mockMessages).sent
+	require.Equal(p2p.Message_ERROR, sent[len(sent)-1].Message.Type)
+
+	require.NoError(d.dispatch(p1, conn.NewPiecePayloadMessage(1, piecereader.NewBuffer(blob.Content[1:2]))))
+
+	require.Empty(announcedPieces(p2.messages))
+	require.True(hasComplete(p2.messages))
+}
+
 func TestDispatcherClosesCompletedPeersWhenComplete(t *testing.T) {
 	require := require.New(t)
 
@@ -472,3 +534,118 @@ func TestDispatcherPeerPieceCounts(t *testing.T) {
 	require.Equal(1, d.numPeersByPiece.Get(1))
 	require.Equal(2, d.numPeersByPiece.Get(2))
 }
+
+func TestDispatcherHandleCorruptPiecePayload(t *testing.T) {
+	require := require.New(t)
+
+	blob := core.SizedBlobFixture(2, 1)
+
+	torrent, cleanup := agentstorage.TorrentFixture(blob.MetaInfo)
+	defer cleanup()
+
+	stats := tally.NewTestScope("", nil)
+	d, err := newDispatcher(
+		Config{},
+		stats,
+		clock.NewMock(),
+		networkevent.NewTestProducer(),
+		noopEvents{},
+		core.PeerIDFixture(),
+		torrent,
+		zap.NewNop().Sugar(),
+		torrentlog.NewNopLogger())
+	require.NoError(err)
+
+	p1, err := d.addPeer(core.PeerIDFixture(), bitsetutil.FromBools(true, true), newMockMessages())
+	require.NoError(err)
+
+	p2, err := d.addPeer(core.PeerIDFixture(), bitsetutil.FromBools(false, false), newMockMessages())
+	require.NoError(err)
+
+	msg := conn.NewPiecePayloadMessage(0, piecereader.NewBuffer([]byte{blob.Content[0] + 1}))
+
+	require.NoError(d.dispatch(p1, msg))
+
+	require.Equal(1, p1.pstats.getCorruptPiecesReceived())
+	require.Equal(0, p1.pstats.getGoodPiecesReceived())
+	require.False(torrent.HasPiece(0))
+
+	// Corrupt pieces are not announced.
+	require.Empty(announcedPieces(p2.messages))
+
+	var corrupt int64
+	for _, c := range stats.Snapshot().Counters() {
+		if c.Name() == "corrupt_pieces" && c.Tags()["source"] == "peer" {
+			corrupt += c.Value()
+		}
+	}
+	require.Equal(int64(1), corrupt)
+}
+
+func TestDispatcherStatus(t *testing.T) {
+	require := require.New(t)
+
+	blob := core.SizedBlobFixture(4, 1)
+
+	torrent, cleanup := agentstorage.TorrentFixture(blob.MetaInfo)
+	defer cleanup()
+
+	d := testDispatcher(Config{DisablePartialSeeding: true}, clock.NewMock(), torrent)
+
+	seeder, err := d.addPeer(
+		core.PeerIDFixture(), bitsetutil.FromBools(true, true, true, true), newMockMessages())
+	require.NoError(err)
+
+	leecher, err := d.addPeer(
+		core.PeerIDFixture(), bitsetutil.FromBools(false, true, false, false), newMockMessages())
+	require.NoError(err)
+
+	_, err = d.maybeSendPieceRequests(seeder, bitsetutil.FromBools(true, true, false, false))
+	require.NoError(err)
+	require.NoError(d.dispatch(seeder, conn.NewPiecePayloadMessage(0, piecereader.NewBuffer(blob.Content[0:1]))))
+	require.NoError(d.dispatch(seeder, conn.NewPiecePayloadMessage(1, piecereader.NewBuffer([]byte{blob.Content[1] + 1}))))
+	require.NoError(d.dispatch(leecher, conn.NewPieceRequestMessage(0, 1)))
+
+	statuses := make(map[core.PeerID]PeerStatus)
+	for _, s := range d.PeerStatuses() {
+		statuses[s.PeerID] = s
+	}
+	require.Len(statuses, 2)
+	require.Equal("1111", statuses[seeder.id].Bitfield)
+	// Receiving piece 0 requests the remaining pieces.
+	require.Equal([]int{2, 3}, statuses[seeder.id].PendingPieces)
+	require.Equal(4, statuses[seeder.id].PieceRequestsSent)
+	require.Equal(1, statuses[seeder.id].GoodPiecesReceived)
+	require.Equal(1, statuses[seeder.id].CorruptPiecesReceived)
+	require.Equal("0100", statuses[leecher.id].Bitfield)
+	require.Equal(1, statuses[leecher.id].PieceRequestsReceived)
+
+	var actions []string
+	for _, decision := range d.RecentDecisions() {
+		actions = append(actions, fmt.Sprintf("%s %d", decision.Action, decision.Piece))
+	}
+	require.Equal([]string{
+		"request 0",
+		"request 1",
+		"request 2",
+		"request 3",
+		"invalid 1",
+		"reject 0",
+	}, actions)
+}
+
+func TestDecisionLogKeepsMostRecent(t *testing.T) {
+	require := require.New(t)
+
+	var l decisionLog
+	require.Empty(l.snapshot())
+
+	for i := 0; i < _maxDecisions+10; i++ {
+		l.add(Decision{Piece: i})
+	}
+	decisions := l.snapshot()
+	require.Len(decisions, _maxDecisions)
+	for i, d := range decisions {
+		require.Equal(i+10, d.Piece)
+	}
+}
@@ -100,6 +100,8 @@ type peerStats struct {
 	goodPiecesReceived int
 	// Pieces we received from the peer that we already had.
 	duplicatePiecesReceived int
+	// Pieces we received from the peer that did not match their piece sum.
+	corruptPiecesReceived int
 }
 
 func (s *peerStats) getPieceRequestsSent() int {
@@ -171,3 +173,17 @@ func (s *peerStats) incrementDuplicatePiecesReceived() {
 
 	s.duplicatePiecesReceived++
 }
+
+func (s *peerStats) getCorruptPiecesReceived() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.corruptPiecesReceived
+}
+
+func (s *peerStats) incrementCorruptPiecesReceived() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.corruptPiecesReceived++
+}
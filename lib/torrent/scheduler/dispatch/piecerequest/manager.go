@@ -94,6 +94,15 @@ func NewManager(
 	return m, nil
 }
 
+// SetPipelineLimit changes the number of requests which may be pending per
+// peer. Requests already pending over the new limit are left untouched.
+func (m *Manager) SetPipelineLimit(n int) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.pipelineLimit = n
+}
+
 // ReservePieces selects the next piece(s) to be requested from given peer.
 // It selects peers on a rarity-first basis using numPeersByPiece.
 // If allowDuplicates is set, may return pieces which have already been
@@ -163,7 +172,7 @@ func (m *Manager) Clear(i int) {
 }
 
 // PendingPieces returns the pieces for all pending requests to peerID in sorted
-// order. Intended primarily for testing purposes.
+// order.
 func (m *Manager) PendingPieces(peerID core.PeerID) []int {
 	m.RLock()
 	defer m.RUnlock()
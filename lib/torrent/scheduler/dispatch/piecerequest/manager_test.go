@@ -62,6 +62,29 @@ func TestManagerPipelineLimit(t *testing.T) {
 	require.Len(m.PendingPieces(peerID), 3)
 }
 
+func TestManagerSetPipelineLimit(t *testing.T) {
+	require := require.New(t)
+
+	m := newManager(clock.NewMock(), 5*time.Second, DefaultPolicy, 3)
+
+	peerID := core.PeerIDFixture()
+	candidates := bitsetutil.FromBools(true, true, true, true, true)
+
+	m.SetPipelineLimit(1)
+	pieces, err := m.ReservePieces(peerID, candidates, countsFromInts(0, 0, 0, 0, 0), false)
+	require.NoError(err)
+	require.Len(pieces, 1)
+
+	pieces, err = m.ReservePieces(peerID, candidates, countsFromInts(0, 0, 0, 0, 0), false)
+	require.NoError(err)
+	require.Empty(pieces)
+
+	m.SetPipelineLimit(3)
+	pieces, err = m.ReservePieces(peerID, candidates, countsFromInts(0, 0, 0, 0, 0), false)
+	require.NoError(err)
+	require.Len(pieces, 2)
+}
+
 func TestManagerReserveExpiredRequest(t *testing.T) {
 	require := require.New(t)
 
@@ -0,0 +1,155 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package dispatch
+
+import (
+	"sync"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/torrent/scheduler/dispatch/piecerequest"
+	"github.com/uber/kraken/utils/bitsetutil"
+)
+
+// Dispatch decision actions.
+const (
+	// DecisionRequest means a piece was requested from a peer.
+	DecisionRequest = "request"
+
+	// DecisionResend means a failed piece request is being resent to other
+	// peers.
+	DecisionResend = "resend"
+
+	// DecisionReject means a piece request from a peer was rejected.
+	DecisionReject = "reject"
+
+	// DecisionInvalid means a piece received from a peer was discarded.
+	DecisionInvalid = "invalid"
+)
+
+// _maxDecisions is the number of recent decisions kept per Dispatcher.
+const _maxDecisions = 100
+
+// Decision describes a piece request decision made by a Dispatcher, intended
+// for debugging.
+type Decision struct {
+	Time   time.Time   `json:"time"`
+	PeerID core.PeerID `json:"peer_id"`
+	Action string      `json:"action"`
+	Piece  int         `json:"piece"`
+	Reason string      `json:"reason,omitempty"`
+}
+
+// decisionLog keeps the most recent decisions in a ring buffer.
+type decisionLog struct {
+	mu        sync.Mutex
+	decisions []Decision
+	next      int
+}
+
+func (l *decisionLog) add(d Decision) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.decisions) < _maxDecisions {
+		l.decisions = append(l.decisions, d)
+		return
+	}
+	l.decisions[l.next] = d
+	l.next = (l.next + 1) % _maxDecisions
+}
+
+// snapshot returns all decisions from oldest to newest.
+func (l *decisionLog) snapshot() []Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	result := make([]Decision, 0, len(l.decisions))
+	result = append(result, l.decisions[l.next:]...)
+	return append(result, l.decisions[:l.next]...)
+}
+
+// PeerStatus describes a peer connected to a Dispatcher.
+type PeerStatus struct {
+	PeerID core.PeerID `json:"peer_id"`
+
+	// Bitfield marks the pieces which the peer has, e.g. "0110".
+	Bitfield string `json:"bitfield"`
+
+	// PendingPieces are the pieces currently requested from the peer.
+	PendingPieces []int `json:"pending_pieces"`
+
+	PieceRequestsSent       int `json:"piece_requests_sent"`
+	PieceRequestsReceived   int `json:"piece_requests_received"`
+	PiecesSent              int `json:"pieces_sent"`
+	GoodPiecesReceived      int `json:"good_pieces_received"`
+	DuplicatePiecesReceived int `json:"duplicate_pieces_received"`
+	CorruptPiecesReceived   int `json:"corrupt_pieces_received"`
+
+	LastGoodPieceReceived time.Time `json:"last_good_piece_received"`
+	LastPieceSent         time.Time `json:"last_piece_sent"`
+}
+
+// PeerStatuses returns the status of all peers connected to d.
+func (d *Dispatcher) PeerStatuses() []PeerStatus {
+	var result []PeerStatus
+	d.peers.Range(func(k, v interface{}) bool {
+		p := v.(*peer)
+		result = append(result, PeerStatus{
+			PeerID:                  p.id,
+			Bitfield:                bitsetutil.String(p.bitfield.Copy()),
+			PendingPieces:           d.pieceRequestManager.PendingPieces(p.id),
+			PieceRequestsSent:       p.pstats.getPieceRequestsSent(),
+			PieceRequestsReceived:   p.pstats.getPieceRequestsReceived(),
+			PiecesSent:              p.pstats.getPiecesSent(),
+			GoodPiecesReceived:      p.pstats.getGoodPiecesReceived(),
+			DuplicatePiecesReceived: p.pstats.getDuplicatePiecesReceived(),
+			CorruptPiecesReceived:   p.pstats.getCorruptPiecesReceived(),
+			LastGoodPieceReceived:   p.getLastGoodPieceReceived(),
+			LastPieceSent:           p.getLastPieceSent(),
+		})
+		return true
+	})
+	return result
+}
+
+// RecentDecisions returns the most recent piece request decisions of d, from
+// oldest to newest.
+func (d *Dispatcher) RecentDecisions() []Decision {
+	return d.decisions.snapshot()
+}
+
+func (d *Dispatcher) decide(peerID core.PeerID, action string, piece int, reason string) {
+	d.decisions.add(Decision{
+		Time:   d.clk.Now(),
+		PeerID: peerID,
+		Action: action,
+		Piece:  piece,
+		Reason: reason,
+	})
+}
+
+// failedRequestReason describes why a piece request is being resent.
+func failedRequestReason(s piecerequest.Status) string {
+	switch s {
+	case piecerequest.StatusExpired:
+		return "request expired"
+	case piecerequest.StatusUnsent:
+		return "request unsent"
+	case piecerequest.StatusInvalid:
+		return "invalid payload"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package dispatch
+
+import (
+	"sync"
+
+	"github.com/uber/kraken/core"
+
+	"github.com/willf/bitset"
+)
+
+// superSeeder tracks which pieces have been revealed to super-seeded peers.
+// While super-seeding, the local seeder advertises an empty bitfield at
+// handshake and only reveals a few pieces at a time to each peer, walking a
+// shared cursor over the torrent so concurrent peers receive disjoint pieces
+// which they then trade amongst themselves. Super-seeding finishes once every
+// piece has been sent at least once, after which peers are told that all
+// pieces are available.
+type superSeeder struct {
+	revealLimit int
+
+	mu       sync.Mutex // Protects the following fields:
+	next     uint
+	sent     *bitset.BitSet
+	revealed map[core.PeerID]*bitset.BitSet
+	done     bool
+}
+
+func newSuperSeeder(numPieces int, revealLimit int) *superSeeder {
+	return &superSeeder{
+		revealLimit: revealLimit,
+		sent:        bitset.New(uint(numPieces)),
+		revealed:    make(map[core.PeerID]*bitset.BitSet),
+	}
+}
+
+// active returns true if super-seeding has not finished.
+func (s *superSeeder) active() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return !s.done
+}
+
+// addPeer registers a super-seeded peer. Returns false if super-seeding has
+// already finished.
+func (s *superSeeder) addPeer(peerID core.PeerID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done {
+		return false
+	}
+	s.revealed[peerID] = bitset.New(s.sent.Len())
+	return true
+}
+
+func (s *superSeeder) removePeer(peerID core.PeerID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.revealed, peerID)
+}
+
+// markSent records that piece i was sent to some peer. Returns the peers which
+// were super-seeded if this completes super-seeding.
+func (s *superSeeder) markSent(i int) (finished []core.PeerID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done {
+		return nil
+	}
+	s.sent.Set(uint(i))
+	if !s.sent.All() {
+		return nil
+	}
+	s.done = true
+	for peerID := range s.revealed {
+		finished = append(finished, peerID)
+	}
+	s.revealed = nil
+	return finished
+}
+
+// reveal selects the next pieces to reveal to a peer, such that at most
+// revealLimit of the pieces revealed to the peer are missing from has.
+func (s *superSeeder) reveal(peerID core.PeerID, has *bitset.BitSet) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revealed, ok := s.revealed[peerID]
+	if !ok {
+		return nil
+	}
+	quota := s.revealLimit - int(revealed.Difference(has).Count())
+	n := s.sent.Len()
+	start := s.next
+	var pieces []int
+	for j := uint(0); j < n && quota > 0; j++ {
+		i := (start + j) % n
+		if revealed.Test(i) || has.Test(i) {
+			continue
+		}
+		revealed.Set(i)
+		pieces = append(pieces, int(i))
+		quota--
+		s.next = (i + 1) % n
+	}
+	return pieces
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package dispatch
+
+import (
+	"testing"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/torrent/scheduler/conn"
+	"github.com/uber/kraken/lib/torrent/storage/agentstorage"
+	"github.com/uber/kraken/lib/torrent/storage/piecereader"
+	"github.com/uber/kraken/utils/bitsetutil"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+	"github.com/willf/bitset"
+)
+
+func TestSuperSeederRevealsDisjointPieces(t *testing.T) {
+	require := require.New(t)
+
+	s := newSuperSeeder(6, 2)
+
+	p1 := core.PeerIDFixture()
+	p2 := core.PeerIDFixture()
+	require.True(s.addPeer(p1))
+	require.True(s.addPeer(p2))
+
+	require.Equal([]int{0, 1}, s.reveal(p1, bitset.New(6)))
+	require.Equal([]int{2, 3}, s.reveal(p2, bitset.New(6)))
+
+	// Nothing more is revealed until the peer has some of its pieces.
+	require.Empty(s.reveal(p1, bitset.New(6)))
+	require.Equal([]int{4}, s.reveal(p1, bitsetutil.FromBools(true, false, false, false, false, false)))
+
+	// Pieces the peer already has are skipped.
+	require.Equal([]int{0}, s.reveal(p2, bitsetutil.FromBools(false, false, true, false, true, true)))
+
+	// Unknown peers have nothing revealed.
+	require.Empty(s.reveal(core.PeerIDFixture(), bitset.New(6)))
+}
+
+func TestSuperSeederFinishesOnceAllPiecesSent(t *testing.T) {
+	require := require.New(t)
+
+	s := newSuperSeeder(2, 1)
+
+	p1 := core.PeerIDFixture()
+	p2 := core.PeerIDFixture()
+	require.True(s.addPeer(p1))
+	require.True(s.addPeer(p2))
+	s.removePeer(p2)
+
+	require.True(s.active())
+	require.Empty(s.markSent(0))
+	require.Empty(s.markSent(0))
+	require.Equal([]core.PeerID{p1}, s.markSent(1))
+	require.False(s.active())
+
+	require.False(s.addPeer(core.PeerIDFixture()))
+}
+
+func TestDispatcherSuperSeeding(t *testing.T) {
+	require := require.New(t)
+
+	blob := core.SizedBlobFixture(4, 1)
+
+	torrent, cleanup := agentstorage.TorrentFixture(blob.MetaInfo)
+	defer cleanup()
+	for i := 0; i < 4; i++ {
+		require.NoError(torrent.WritePiece(piecereader.NewBuffer(blob.Content[i:i+1]), i))
+	}
+
+	d := testDispatcher(Config{
+		SuperSeeding:         true,
+		SuperSeedRevealLimit: 1,
+	}, clock.NewMock(), torrent)
+	require.True(d.SuperSeeding())
+
+	addPeer := func() *peer {
+		peerID := core.PeerIDFixture()
+		require.NoError(d.AddSuperSeededPeer(peerID, bitset.New(4), newMockMessages()))
+		p, ok := d.peers.Load(peerID)
+		require.True(ok)
+		return p.(*peer)
+	}
+	request := func(p *peer, i int) {
+		require.NoError(d.dispatch(p, conn.NewPieceRequestMessage(i, 1)))
+	}
+
+	p1 := addPeer()
+	p2 := addPeer()
+	require.Equal([]int{0}, announcedPieces(p1.messages))
+	require.Equal([]int{1}, announcedPieces(p2.messages))
+
+	// Sending a revealed piece reveals the next one.
+	request(p1, 0)
+	request(p2, 1)
+	require.Equal([]int{0, 2}, announcedPieces(p1.messages))
+	require.Equal([]int{1, 3}, announcedPieces(p2.messages))
+
+	// Once every piece has been sent, all peers are told they are available.
+	request(p1, 2)
+	require.True(d.SuperSeeding())
+	require.False(hasComplete(p1.messages))
+	request(p2, 3)
+	require.False(d.SuperSeeding())
+	require.True(hasComplete(p1.messages))
+	require.True(hasComplete(p2.messages))
+
+	// Peers which were advertised an empty bitfield after super-seeding
+	// finished are told all pieces are available right away.
+	p3 := addPeer()
+	require.Empty(announcedPieces(p3.messages))
+	require.True(hasComplete(p3.messages))
+}
+
+func TestDispatcherSuperSeedingDisabled(t *testing.T) {
+	blob := core.SizedBlobFixture(1, 1)
+
+	torrent, cleanup := agentstorage.TorrentFixture(blob.MetaInfo)
+	defer cleanup()
+	require.NoError(t, torrent.WritePiece(piecereader.NewBuffer(blob.Content), 0))
+
+	d := testDispatcher(Config{}, clock.NewMock(), torrent)
+	require.False(t, d.SuperSeeding())
+}
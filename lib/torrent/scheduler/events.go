@@ -156,11 +156,23 @@ func (e incomingHandshakeEvent) apply(s *state) {
 		e.pc.Close()
 		return
 	}
+	// Super-seed only if neither we nor the remote peer know of another seeder.
+	superSeed := s.sched.config.Dispatch.SuperSeeding && !hasCompleteBitfield(e.pc.RemoteBitfields())
 	var rb conn.RemoteBitfields
 	if ctrl, ok := s.torrentControls[e.pc.InfoHash()]; ok {
 		rb = ctrl.dispatcher.RemoteBitfields()
+		superSeed = superSeed && ctrl.dispatcher.SuperSeeding() && !hasCompleteBitfield(rb)
 	}
-	go s.sched.establishIncomingHandshake(e.pc, rb)
+	go s.sched.establishIncomingHandshake(e.pc, rb, superSeed)
+}
+
+func hasCompleteBitfield(rb conn.RemoteBitfields) bool {
+	for _, b := range rb {
+		if b.All() {
+			return true
+		}
+	}
+	return false
 }
 
 // failedIncomingHandshakeEvent occurs when a pending incoming connection fails
@@ -180,11 +192,12 @@ type incomingConnEvent struct {
 	c         *conn.Conn
 	bitfield  *bitset.BitSet
 	info      *storage.TorrentInfo
+	superSeed bool
 }
 
 // apply transitions a fully-handshaked incoming conn from pending to active.
 func (e incomingConnEvent) apply(s *state) {
-	if err := s.addIncomingConn(e.namespace, e.c, e.bitfield, e.info); err != nil {
+	if err := s.addIncomingConn(e.namespace, e.c, e.bitfield, e.info, e.superSeed); err != nil {
 		s.log("conn", e.c).Errorf("Error adding incoming conn: %s", err)
 		e.c.Close()
 		return
@@ -315,6 +328,7 @@ func (e announceErrEvent) apply(s *state) {
 type newTorrentEvent struct {
 	namespace string
 	torrent   storage.Torrent
+	priority  Priority
 	errc      chan error
 }
 
@@ -323,12 +337,12 @@ func (e newTorrentEvent) apply(s *state) {
 	ctrl, ok := s.torrentControls[e.torrent.InfoHash()]
 	if !ok {
 		var err error
-		ctrl, err = s.addTorrent(e.namespace, e.torrent, true)
+		ctrl, err = s.addTorrent(e.namespace, e.torrent, true, e.priority)
 		if err != nil {
 			e.errc <- err
 			return
 		}
-		s.log("torrent", e.torrent).Info("Added new torrent")
+		s.log("torrent", e.torrent, "priority", e.priority).Info("Added new torrent")
 	}
 	if ctrl.dispatcher.Complete() {
 		e.errc <- nil
@@ -336,6 +350,12 @@ func (e newTorrentEvent) apply(s *state) {
 	}
 	ctrl.errors = append(ctrl.errors, e.errc)
 
+	// A torrent is downloaded at the highest priority it was requested with.
+	if e.priority > ctrl.priority {
+		ctrl.priority = e.priority
+	}
+	s.updatePreemption()
+
 	// Immediately announce new torrents.
 	go s.sched.announce(ctrl.dispatcher.Digest(), ctrl.dispatcher.InfoHash(), ctrl.dispatcher.Complete())
 }
@@ -370,6 +390,8 @@ func (e dispatcherCompleteEvent) apply(s *state) {
 		}
 	}
 
+	s.updatePreemption()
+
 	s.log("hash", infoHash).Info("Torrent complete")
 	s.sched.netevents.Produce(networkevent.TorrentCompleteEvent(infoHash, s.sched.pctx.PeerID))
 
@@ -452,6 +474,14 @@ func (e blacklistSnapshotEvent) apply(s *state) {
 	e.result <- s.conns.BlacklistSnapshot()
 }
 
+type torrentStatusesEvent struct {
+	result chan []TorrentStatus
+}
+
+func (e torrentStatusesEvent) apply(s *state) {
+	e.result <- s.torrentStatuses()
+}
+
 // removeTorrentEvent occurs when a torrent is manually removed via scheduler API.
 type removeTorrentEvent struct {
 	digest core.Digest
@@ -470,6 +500,50 @@ func (e removeTorrentEvent) apply(s *state) {
 	e.errc <- s.sched.torrentArchive.DeleteTorrent(e.digest)
 }
 
+// cancelTorrentEvent occurs when a torrent is manually cancelled via scheduler
+// API.
+type cancelTorrentEvent struct {
+	digest core.Digest
+	errc   chan error
+}
+
+func (e cancelTorrentEvent) apply(s *state) {
+	err := ErrTorrentNotFound
+	for h, ctrl := range s.torrentControls {
+		if ctrl.dispatcher.Digest() == e.digest {
+			s.log(
+				"hash", h,
+				"inprogress", !ctrl.dispatcher.Complete()).Info("Cancelling torrent")
+			if ctrl.dispatcher.Complete() {
+				// Incomplete torrents are torn down on removal.
+				ctrl.dispatcher.TearDown()
+			}
+			s.removeTorrent(h, ErrTorrentCancelled)
+			err = nil
+		}
+	}
+	e.errc <- err
+}
+
+// reannounceEvent occurs when an announce is manually requested via scheduler
+// API.
+type reannounceEvent struct {
+	digest core.Digest
+	errc   chan error
+}
+
+func (e reannounceEvent) apply(s *state) {
+	err := ErrTorrentNotFound
+	for h, ctrl := range s.torrentControls {
+		if ctrl.dispatcher.Digest() == e.digest {
+			s.log("hash", h).Info("Re-announcing torrent")
+			go s.sched.announce(e.digest, h, ctrl.dispatcher.Complete())
+			err = nil
+		}
+	}
+	e.errc <- err
+}
+
 // probeEvent occurs when a probe is manually requested via scheduler API.
 // The event loop is unbuffered, so if a probe can be successfully sent, then
 // the event loop is healthy.
@@ -131,7 +131,7 @@ func TestAnnounceTickEvent(t *testing.T) {
 
 	var ctrls []*torrentControl
 	for i := 0; i < 5; i++ {
-		c, err := state.addTorrent(_testNamespace, mocks.newTorrent(), true)
+		c, err := state.addTorrent(_testNamespace, mocks.newTorrent(), true, PriorityNormal)
 		require.NoError(err)
 		ctrls = append(ctrls, c)
 	}
@@ -164,7 +164,7 @@ func TestAnnounceTickEventSkipsFullTorrents(t *testing.T) {
 		},
 	})
 
-	full, err := state.addTorrent(_testNamespace, mocks.newTorrent(), true)
+	full, err := state.addTorrent(_testNamespace, mocks.newTorrent(), true, PriorityNormal)
 	require.NoError(err)
 
 	info := full.dispatcher.Stat()
@@ -177,7 +177,7 @@ func TestAnnounceTickEventSkipsFullTorrents(t *testing.T) {
 		require.NoError(state.addOutgoingConn(c, info.Bitfield(), info))
 	}
 
-	empty, err := state.addTorrent(_testNamespace, mocks.newTorrent(), true)
+	empty, err := state.addTorrent(_testNamespace, mocks.newTorrent(), true, PriorityNormal)
 	require.NoError(err)
 
 	// The first torrent is full and should be skipped, announcing the empty
@@ -229,3 +229,35 @@ func TestAnnounceTickEventSkipsFullTorrents(t *testing.T) {
 		infoHash: full.dispatcher.InfoHash(),
 	})
 }
+
+func TestUpdatePreemption(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newStateMocks(t)
+	defer cleanup()
+
+	state := mocks.newState(Config{})
+
+	low, err := state.addTorrent(_testNamespace, mocks.newTorrent(), true, PriorityLow)
+	require.NoError(err)
+	normal, err := state.addTorrent(_testNamespace, mocks.newTorrent(), true, PriorityNormal)
+	require.NoError(err)
+	state.updatePreemption()
+
+	require.True(low.dispatcher.Preempted())
+	require.False(normal.dispatcher.Preempted())
+
+	high, err := state.addTorrent(_testNamespace, mocks.newTorrent(), true, PriorityHigh)
+	require.NoError(err)
+	state.updatePreemption()
+
+	require.True(low.dispatcher.Preempted())
+	require.True(normal.dispatcher.Preempted())
+	require.False(high.dispatcher.Preempted())
+
+	// Removing the highest priority torrent resumes the next highest.
+	state.removeTorrent(high.dispatcher.InfoHash(), ErrTorrentRemoved)
+
+	require.True(low.dispatcher.Preempted())
+	require.False(normal.dispatcher.Preempted())
+}
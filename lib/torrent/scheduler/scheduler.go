@@ -14,14 +14,17 @@
 package scheduler
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/andres-erbsen/clock"
 	"github.com/uber-go/tally"
+	"github.com/willf/bitset"
 	"go.uber.org/zap"
 
 	"github.com/uber/kraken/core"
@@ -32,6 +35,7 @@ import (
 	"github.com/uber/kraken/lib/torrent/scheduler/connstate"
 	"github.com/uber/kraken/lib/torrent/scheduler/torrentlog"
 	"github.com/uber/kraken/lib/torrent/storage"
+	"github.com/uber/kraken/tracing"
 	"github.com/uber/kraken/tracker/announceclient"
 	"github.com/uber/kraken/utils/log"
 )
@@ -42,6 +46,7 @@ var (
 	ErrSchedulerStopped  = errors.New("scheduler has been stopped")
 	ErrTorrentTimeout    = errors.New("torrent timed out")
 	ErrTorrentRemoved    = errors.New("torrent manually removed")
+	ErrTorrentCancelled  = errors.New("torrent manually cancelled")
 	ErrSendEventTimedOut = errors.New("event loop send timed out")
 )
 
@@ -49,9 +54,15 @@ var (
 type Scheduler interface {
 	Stop()
 	Download(namespace string, d core.Digest) error
+	DownloadWithPriority(namespace string, d core.Digest, p Priority) error
 	BlacklistSnapshot() ([]connstate.BlacklistedConn, error)
+	TorrentStatuses() ([]TorrentStatus, error)
 	RemoveTorrent(d core.Digest) error
+	CancelTorrent(d core.Digest) error
+	Reannounce(d core.Digest) error
 	Probe() error
+	BandwidthLimits() conn.BandwidthLimits
+	SetBandwidthLimits(limits conn.BandwidthLimits) error
 }
 
 // scheduler manages global state for the peer. This includes:
@@ -172,7 +183,7 @@ func newScheduler(
 		preemptionTick: preemptionTick,
 		emitStatsTick:  overrides.clock.Tick(config.EmitStatsInterval),
 		announceClient: announceClient,
-		announcer:      announcer.Default(announceClient, eventLoop, overrides.clock, slogger),
+		announcer:      announcer.New(config.Announcer, announceClient, eventLoop, overrides.clock, slogger),
 		netevents:      netevents,
 		torrentlog:     tlog,
 		logger:         slogger,
@@ -231,7 +242,20 @@ func (s *scheduler) Stop() {
 	})
 }
 
-func (s *scheduler) doDownload(namespace string, d core.Digest) (size int64, err error) {
+func (s *scheduler) doDownload(
+	namespace string, d core.Digest, p Priority) (size int64, err error) {
+
+	_, span := tracing.Start(
+		context.Background(),
+		"torrent.download",
+		tracing.WithAttribute("namespace", namespace),
+		tracing.WithAttribute("digest", d.Hex()))
+	defer func() {
+		span.SetAttribute("size", size)
+		span.SetError(err)
+		span.End()
+	}()
+
 	t, err := s.torrentArchive.CreateTorrent(namespace, d)
 	if err != nil {
 		if err == storage.ErrNotFound {
@@ -242,17 +266,23 @@ func (s *scheduler) doDownload(namespace string, d core.Digest) (size int64, err
 
 	// Buffer size of 1 so sends do not block.
 	errc := make(chan error, 1)
-	if !s.eventLoop.send(newTorrentEvent{namespace, t, errc}) {
+	if !s.eventLoop.send(newTorrentEvent{namespace, t, p, errc}) {
 		return 0, ErrSchedulerStopped
 	}
 	return t.Length(), <-errc
 }
 
-// Download downloads the torrent given metainfo. Once the torrent is downloaded,
-// it will begin seeding asynchronously.
+// Download downloads the torrent given metainfo with normal priority. Once the
+// torrent is downloaded, it will begin seeding asynchronously.
 func (s *scheduler) Download(namespace string, d core.Digest) error {
+	return s.DownloadWithPriority(namespace, d, PriorityNormal)
+}
+
+// DownloadWithPriority downloads the torrent given metainfo with priority p.
+// If the torrent is already downloading, its priority is raised to p.
+func (s *scheduler) DownloadWithPriority(namespace string, d core.Digest, p Priority) error {
 	start := time.Now()
-	size, err := s.doDownload(namespace, d)
+	size, err := s.doDownload(namespace, d, p)
 	if err != nil {
 		var errTag string
 		switch err {
@@ -264,6 +294,8 @@ func (s *scheduler) Download(namespace string, d core.Digest) error {
 			errTag = "scheduler_stopped"
 		case ErrTorrentRemoved:
 			errTag = "removed"
+		case ErrTorrentCancelled:
+			errTag = "cancelled"
 		default:
 			errTag = "unknown"
 		}
@@ -288,6 +320,16 @@ func (s *scheduler) BlacklistSnapshot() ([]connstate.BlacklistedConn, error) {
 	return <-result, nil
 }
 
+// TorrentStatuses returns a snapshot of all torrents which are currently
+// downloading or seeding.
+func (s *scheduler) TorrentStatuses() ([]TorrentStatus, error) {
+	result := make(chan []TorrentStatus)
+	if !s.eventLoop.send(torrentStatusesEvent{result}) {
+		return nil, ErrSchedulerStopped
+	}
+	return <-result, nil
+}
+
 // RemoveTorrent forcibly stops leeching / seeding torrent for d and removes
 // the torrent from disk.
 func (s *scheduler) RemoveTorrent(d core.Digest) error {
@@ -299,11 +341,46 @@ func (s *scheduler) RemoveTorrent(d core.Digest) error {
 	return <-errc
 }
 
+// CancelTorrent forcibly stops leeching / seeding torrent for d. Unlike
+// RemoveTorrent, completed torrents are kept on disk. Returns
+// ErrTorrentNotFound if d is not downloading or seeding.
+func (s *scheduler) CancelTorrent(d core.Digest) error {
+	// Buffer size of 1 so sends do not block.
+	errc := make(chan error, 1)
+	if !s.eventLoop.send(cancelTorrentEvent{d, errc}) {
+		return ErrSchedulerStopped
+	}
+	return <-errc
+}
+
+// Reannounce immediately announces torrent for d to the tracker, instead of
+// waiting for its turn in the announce queue. Returns ErrTorrentNotFound if d
+// is not downloading or seeding.
+func (s *scheduler) Reannounce(d core.Digest) error {
+	// Buffer size of 1 so sends do not block.
+	errc := make(chan error, 1)
+	if !s.eventLoop.send(reannounceEvent{d, errc}) {
+		return ErrSchedulerStopped
+	}
+	return <-errc
+}
+
 // Probe verifies that the scheduler event loop is running and unblocked.
 func (s *scheduler) Probe() error {
 	return s.eventLoop.sendTimeout(probeEvent{}, s.config.ProbeTimeout)
 }
 
+// BandwidthLimits returns the current bandwidth limits of peer connections.
+func (s *scheduler) BandwidthLimits() conn.BandwidthLimits {
+	return s.handshaker.BandwidthLimits()
+}
+
+// SetBandwidthLimits adjusts the bandwidth limits of new and existing peer
+// connections. Limits set at runtime do not survive a config reload.
+func (s *scheduler) SetBandwidthLimits(limits conn.BandwidthLimits) error {
+	return s.handshaker.SetBandwidthLimits(limits)
+}
+
 func (s *scheduler) runEventLoop(aq announcequeue.Queue) {
 	defer s.wg.Done()
 
@@ -377,20 +454,29 @@ func (s *scheduler) failIncomingHandshake(pc *conn.PendingConn, err error) {
 }
 
 // establishIncomingHandshake attempts to establish a pending conn initialized
-// by a remote peer. Success / failure is communicated via events.
-func (s *scheduler) establishIncomingHandshake(pc *conn.PendingConn, rb conn.RemoteBitfields) {
+// by a remote peer. Success / failure is communicated via events. If superSeed
+// is set and the local torrent is complete, an empty bitfield is advertised so
+// that pieces can be revealed gradually.
+func (s *scheduler) establishIncomingHandshake(
+	pc *conn.PendingConn, rb conn.RemoteBitfields, superSeed bool) {
+
 	info, err := s.torrentArchive.Stat(pc.Namespace(), pc.Digest())
 	if err != nil {
 		s.failIncomingHandshake(pc, fmt.Errorf("torrent stat: %s", err))
 		return
 	}
-	c, err := s.handshaker.Establish(pc, info, rb)
+	advertised := s.advertised(info)
+	superSeed = superSeed && info.Bitfield().All()
+	if superSeed {
+		advertised = info.WithBitfield(bitset.New(info.Bitfield().Len()))
+	}
+	c, err := s.handshaker.Establish(pc, advertised, rb)
 	if err != nil {
 		s.failIncomingHandshake(pc, fmt.Errorf("establish handshake: %s", err))
 		return
 	}
 	s.torrentlog.IncomingConnectionAccept(pc.Digest(), pc.InfoHash(), pc.PeerID())
-	s.eventLoop.send(incomingConnEvent{pc.Namespace(), c, pc.Bitfield(), info})
+	s.eventLoop.send(incomingConnEvent{pc.Namespace(), c, pc.Bitfield(), info, superSeed})
 }
 
 // initializeOutgoingHandshake attempts to initialize a conn to a remote peer.
@@ -398,8 +484,8 @@ func (s *scheduler) establishIncomingHandshake(pc *conn.PendingConn, rb conn.Rem
 func (s *scheduler) initializeOutgoingHandshake(
 	p *core.PeerInfo, info *storage.TorrentInfo, rb conn.RemoteBitfields, namespace string) {
 
-	addr := fmt.Sprintf("%s:%d", p.IP, p.Port)
-	result, err := s.handshaker.Initialize(p.PeerID, addr, info, rb, namespace)
+	addr := net.JoinHostPort(p.IP, strconv.Itoa(p.Port))
+	result, err := s.handshaker.Initialize(p.PeerID, addr, s.advertised(info), rb, namespace)
 	if err != nil {
 		s.log(
 			"peer", p.PeerID,
@@ -413,6 +499,15 @@ func (s *scheduler) initializeOutgoingHandshake(
 	s.eventLoop.send(outgoingConnEvent{result.Conn, result.Bitfield, info})
 }
 
+// advertised returns the torrent info advertised to peers at handshake. If
+// partial seeding is disabled, incomplete torrents advertise no pieces.
+func (s *scheduler) advertised(info *storage.TorrentInfo) *storage.TorrentInfo {
+	if s.config.Dispatch.DisablePartialSeeding && !info.Bitfield().All() {
+		return info.WithBitfield(bitset.New(info.Bitfield().Len()))
+	}
+	return info
+}
+
 func (s *scheduler) log(args ...interface{}) *zap.SugaredLogger {
 	return s.logger.With(args...)
 }
@@ -15,6 +15,7 @@ package scheduler
 
 import (
 	"os"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -24,6 +25,7 @@ import (
 	"github.com/uber/kraken/lib/hostlist"
 	"github.com/uber/kraken/lib/torrent/networkevent"
 	"github.com/uber/kraken/lib/torrent/scheduler/announcequeue"
+	"github.com/uber/kraken/lib/torrent/scheduler/dispatch"
 	"github.com/uber/kraken/lib/torrent/storage/piecereader"
 	"github.com/uber/kraken/tracker/announceclient"
 	"github.com/uber/kraken/utils/bitsetutil"
@@ -130,6 +132,77 @@ func TestDownloadManyTorrentsWithSeederAndManyLeechers(t *testing.T) {
 	wg.Wait()
 }
 
+func TestDownloadTorrentWithSuperSeeder(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newTestMocks(t)
+	defer cleanup()
+
+	config := configFixture()
+	namespace := core.TagFixture()
+
+	seederConfig := configFixture()
+	seederConfig.Dispatch.SuperSeeding = true
+	seederConfig.Dispatch.SuperSeedRevealLimit = 1
+
+	seeder := mocks.newPeer(seederConfig)
+	leechers := mocks.newPeers(5, config)
+
+	blob := core.SizedBlobFixture(64, 4)
+
+	mocks.metaInfoClient.EXPECT().Download(
+		namespace, blob.Digest).Return(blob.MetaInfo, nil).Times(6)
+
+	seeder.writeTorrent(namespace, blob)
+	require.NoError(seeder.scheduler.Download(namespace, blob.Digest))
+
+	var wg sync.WaitGroup
+	for _, p := range leechers {
+		p := p
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(p.scheduler.Download(namespace, blob.Digest))
+			p.checkTorrent(t, namespace, blob)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestDownloadTorrentWithPartialSeedingDisabled(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newTestMocks(t)
+	defer cleanup()
+
+	config := configFixture()
+	config.Dispatch.DisablePartialSeeding = true
+	namespace := core.TagFixture()
+
+	seeder := mocks.newPeer(config)
+	leechers := mocks.newPeers(5, config)
+
+	blob := core.SizedBlobFixture(64, 4)
+
+	mocks.metaInfoClient.EXPECT().Download(
+		namespace, blob.Digest).Return(blob.MetaInfo, nil).Times(6)
+
+	seeder.writeTorrent(namespace, blob)
+	require.NoError(seeder.scheduler.Download(namespace, blob.Digest))
+
+	var wg sync.WaitGroup
+	for _, p := range leechers {
+		p := p
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(p.scheduler.Download(namespace, blob.Digest))
+			p.checkTorrent(t, namespace, blob)
+		}()
+	}
+	wg.Wait()
+}
+
 func TestDownloadTorrentWhenPeersAllHaveDifferentPiece(t *testing.T) {
 	require := require.New(t)
 
@@ -458,6 +531,104 @@ func TestSchedulerRemoveTorrent(t *testing.T) {
 	require.True(os.IsNotExist(err))
 }
 
+func TestSchedulerCancelTorrent(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newTestMocks(t)
+	defer cleanup()
+
+	w := newEventWatcher()
+
+	p := mocks.newPeer(configFixture(), withEventLoop(w))
+
+	blob := core.NewBlobFixture()
+	namespace := core.TagFixture()
+
+	mocks.metaInfoClient.EXPECT().Download(
+		namespace, blob.Digest).Return(blob.MetaInfo, nil)
+
+	errc := make(chan error)
+	go func() { errc <- p.scheduler.Download(namespace, blob.Digest) }()
+
+	w.waitFor(t, newTorrentEvent{})
+
+	require.NoError(p.scheduler.CancelTorrent(blob.Digest))
+
+	require.Equal(ErrTorrentCancelled, <-errc)
+
+	require.Equal(ErrTorrentNotFound, p.scheduler.CancelTorrent(blob.Digest))
+	require.Equal(ErrTorrentNotFound, p.scheduler.Reannounce(blob.Digest))
+}
+
+func TestSchedulerCancelCompleteTorrentKeepsItOnDisk(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newTestMocks(t)
+	defer cleanup()
+
+	p := mocks.newPeer(configFixture())
+
+	blob := core.NewBlobFixture()
+	namespace := core.TagFixture()
+
+	mocks.metaInfoClient.EXPECT().Download(
+		namespace, blob.Digest).Return(blob.MetaInfo, nil)
+
+	p.writeTorrent(namespace, blob)
+	require.NoError(p.scheduler.Download(namespace, blob.Digest))
+	require.NoError(p.scheduler.Reannounce(blob.Digest))
+
+	require.NoError(p.scheduler.CancelTorrent(blob.Digest))
+	waitForTorrentRemoved(t, p.scheduler, blob.MetaInfo.InfoHash())
+
+	_, err := p.torrentArchive.Stat(namespace, blob.Digest)
+	require.NoError(err)
+}
+
+func TestSchedulerTorrentStatuses(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newTestMocks(t)
+	defer cleanup()
+
+	config := configFixture()
+
+	seeder := mocks.newPeer(config)
+	leecher := mocks.newPeer(config)
+
+	blob := core.NewBlobFixture()
+	namespace := core.TagFixture()
+
+	mocks.metaInfoClient.EXPECT().Download(
+		namespace, blob.Digest).Return(blob.MetaInfo, nil).Times(2)
+
+	seeder.writeTorrent(namespace, blob)
+	require.NoError(seeder.scheduler.Download(namespace, blob.Digest))
+	require.NoError(leecher.scheduler.DownloadWithPriority(namespace, blob.Digest, PriorityHigh))
+
+	statuses, err := leecher.scheduler.TorrentStatuses()
+	require.NoError(err)
+	require.Len(statuses, 1)
+	status := statuses[0]
+	require.Equal(namespace, status.Namespace)
+	require.Equal(blob.Digest, status.Digest)
+	require.Equal(blob.MetaInfo.InfoHash(), status.InfoHash)
+	require.Equal("high", status.Priority)
+	require.True(status.LocalRequest)
+	require.True(status.Complete)
+	require.Equal(100, status.PercentDownloaded)
+	require.Equal(strings.Repeat("1", blob.MetaInfo.NumPieces()), status.Bitfield)
+
+	var requested int
+	for _, d := range status.RecentDecisions {
+		require.Equal(seeder.pctx.PeerID, d.PeerID)
+		if d.Action == dispatch.DecisionRequest {
+			requested++
+		}
+	}
+	require.Equal(blob.MetaInfo.NumPieces(), requested)
+}
+
 func TestSchedulerProbe(t *testing.T) {
 	require := require.New(t)
 
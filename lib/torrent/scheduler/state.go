@@ -35,6 +35,7 @@ type torrentControl struct {
 	dispatcher   *dispatch.Dispatcher
 	errors       []chan error
 	localRequest bool
+	priority     Priority
 }
 
 // state is a superset of scheduler, which includes protected state which can
@@ -67,7 +68,10 @@ func newState(s *scheduler, aq announcequeue.Queue) *state {
 // addTorrent initializes a new torrentControl for t. Overwrites any existing
 // torrentControl for t, so callers should check if one exists first.
 func (s *state) addTorrent(
-	namespace string, t storage.Torrent, localRequest bool) (*torrentControl, error) {
+	namespace string,
+	t storage.Torrent,
+	localRequest bool,
+	priority Priority) (*torrentControl, error) {
 
 	d, err := dispatch.New(
 		s.sched.config.Dispatch,
@@ -86,6 +90,7 @@ func (s *state) addTorrent(
 		namespace:    namespace,
 		dispatcher:   d,
 		localRequest: localRequest,
+		priority:     priority,
 	}
 	s.announceQueue.Add(t.InfoHash())
 	s.sched.netevents.Produce(networkevent.AddTorrentEvent(
@@ -114,6 +119,30 @@ func (s *state) removeTorrent(h core.InfoHash, err error) {
 		s.sched.torrentArchive.DeleteTorrent(ctrl.dispatcher.Digest())
 	}
 	delete(s.torrentControls, h)
+	s.updatePreemption()
+}
+
+// updatePreemption preempts every incomplete torrent whose priority is lower
+// than the highest priority of all incomplete torrents. Must be called
+// whenever the set of incomplete torrents or their priorities change.
+func (s *state) updatePreemption() {
+	max := PriorityLow
+	for _, ctrl := range s.torrentControls {
+		if !ctrl.dispatcher.Complete() && ctrl.priority > max {
+			max = ctrl.priority
+		}
+	}
+	for _, ctrl := range s.torrentControls {
+		if ctrl.dispatcher.Complete() {
+			continue
+		}
+		preempted := ctrl.priority < max
+		if preempted != ctrl.dispatcher.Preempted() {
+			s.log("dispatcher", ctrl.dispatcher, "priority", ctrl.priority).Infof(
+				"Setting torrent preempted to %t", preempted)
+			ctrl.dispatcher.SetPreempted(preempted)
+		}
+	}
 }
 
 // addOutgoingConn adds a conn, initialized by us, to state. The conn must already
@@ -135,9 +164,13 @@ func (s *state) addOutgoingConn(c *conn.Conn, b *bitset.BitSet, info *storage.To
 
 // addIncomingConn adds a conn, initialized by a remote peer, to state. The conn
 // must already be in a pending state. Initializes a torrent control if not
-// present.
+// present. If superSeed is set, the remote peer was sent an empty bitfield.
 func (s *state) addIncomingConn(
-	namespace string, c *conn.Conn, b *bitset.BitSet, info *storage.TorrentInfo) error {
+	namespace string,
+	c *conn.Conn,
+	b *bitset.BitSet,
+	info *storage.TorrentInfo,
+	superSeed bool) error {
 
 	if err := s.conns.MovePendingToActive(c); err != nil {
 		return fmt.Errorf("move pending to active: %s", err)
@@ -149,11 +182,17 @@ func (s *state) addIncomingConn(
 		if err != nil {
 			return fmt.Errorf("get torrent: %s", err)
 		}
-		ctrl, err = s.addTorrent(namespace, t, false)
+		ctrl, err = s.addTorrent(namespace, t, false, PriorityNormal)
 		if err != nil {
 			return err
 		}
 	}
+	if superSeed {
+		if err := ctrl.dispatcher.AddSuperSeededPeer(c.PeerID(), b, c); err != nil {
+			return fmt.Errorf("add super-seeded conn to dispatcher: %s", err)
+		}
+		return nil
+	}
 	if err := ctrl.dispatcher.AddPeer(c.PeerID(), b, c); err != nil {
 		return fmt.Errorf("add conn to dispatcher: %s", err)
 	}
@@ -0,0 +1,84 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package scheduler
+
+import (
+	"sort"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/torrent/scheduler/dispatch"
+	"github.com/uber/kraken/utils/bitsetutil"
+)
+
+// TorrentStatus describes a torrent managed by the scheduler, intended for
+// debugging.
+type TorrentStatus struct {
+	Namespace    string        `json:"namespace"`
+	Digest       core.Digest   `json:"digest"`
+	InfoHash     core.InfoHash `json:"info_hash"`
+	Length       int64         `json:"length"`
+	Priority     string        `json:"priority"`
+	LocalRequest bool          `json:"local_request"`
+	Complete     bool          `json:"complete"`
+	Preempted    bool          `json:"preempted"`
+
+	// Bitfield marks the pieces which have been downloaded, e.g. "0110".
+	Bitfield          string `json:"bitfield"`
+	PercentDownloaded int    `json:"percent_downloaded"`
+
+	CreatedAt     time.Time `json:"created_at"`
+	LastReadTime  time.Time `json:"last_read_time"`
+	LastWriteTime time.Time `json:"last_write_time"`
+
+	// Peers are the peers with active connections.
+	Peers []dispatch.PeerStatus `json:"peers"`
+
+	// PendingPeers are the peers with connections which are still handshaking.
+	PendingPeers []core.PeerID `json:"pending_peers"`
+
+	RecentDecisions []dispatch.Decision `json:"recent_decisions"`
+}
+
+// torrentStatuses returns the status of all torrents, sorted by digest. Must
+// be called from the event loop.
+func (s *state) torrentStatuses() []TorrentStatus {
+	result := make([]TorrentStatus, 0, len(s.torrentControls))
+	for h, ctrl := range s.torrentControls {
+		d := ctrl.dispatcher
+		info := d.Stat()
+		result = append(result, TorrentStatus{
+			Namespace:         ctrl.namespace,
+			Digest:            d.Digest(),
+			InfoHash:          h,
+			Length:            d.Length(),
+			Priority:          ctrl.priority.String(),
+			LocalRequest:      ctrl.localRequest,
+			Complete:          d.Complete(),
+			Preempted:         d.Preempted(),
+			Bitfield:          bitsetutil.String(info.Bitfield()),
+			PercentDownloaded: info.PercentDownloaded(),
+			CreatedAt:         d.CreatedAt(),
+			LastReadTime:      d.LastReadTime(),
+			LastWriteTime:     d.LastWriteTime(),
+			Peers:             d.PeerStatuses(),
+			PendingPeers:      s.conns.PendingConns(h),
+			RecentDecisions:   d.RecentDecisions(),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Digest.Hex() < result[j].Digest.Hex()
+	})
+	return result
+}
@@ -198,6 +198,7 @@ type SeederSummary struct {
 	RequestsSent            int
 	GoodPiecesReceived      int
 	DuplicatePiecesReceived int
+	CorruptPiecesReceived   int
 }
 
 // MarshalLogObject marshals a SeederSummary for logging.
@@ -206,6 +207,7 @@ func (s SeederSummary) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 	enc.AddInt("requests_sent", s.RequestsSent)
 	enc.AddInt("good_pieces_received", s.GoodPiecesReceived)
 	enc.AddInt("duplicate_pieces_received", s.DuplicatePiecesReceived)
+	enc.AddInt("corrupt_pieces_received", s.CorruptPiecesReceived)
 	return nil
 }
 
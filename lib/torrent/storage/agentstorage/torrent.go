@@ -54,6 +54,7 @@ type Torrent struct {
 	pieces      []*piece
 	numComplete *atomic.Int32
 	committed   *atomic.Bool
+	committing  *atomic.Bool
 }
 
 // NewTorrent creates a new Torrent.
@@ -63,21 +64,22 @@ func NewTorrent(cads caDownloadStore, mi *core.MetaInfo) (*Torrent, error) {
 		return nil, fmt.Errorf("restore pieces: %s", err)
 	}
 
-	committed := false
-	if numComplete == len(pieces) {
-		if err := cads.MoveDownloadFileToCache(mi.Digest().Hex()); err != nil && !os.IsExist(err) {
-			return nil, fmt.Errorf("move file to cache: %s", err)
-		}
-		committed = true
-	}
-
-	return &Torrent{
+	t := &Torrent{
 		cads:        cads,
 		metaInfo:    mi,
 		pieces:      pieces,
 		numComplete: atomic.NewInt32(int32(numComplete)),
-		committed:   atomic.NewBool(committed),
-	}, nil
+		committed:   atomic.NewBool(false),
+		committing:  atomic.NewBool(false),
+	}
+	if numComplete == len(pieces) {
+		if err := t.commit(); err == storage.ErrBlobCorrupt {
+			log.With("name", mi.Digest().Hex()).Error("Restored corrupt download, starting over")
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
 }
 
 // Digest returns the digest of the target blob.
@@ -189,7 +191,7 @@ func (t *Torrent) writePiece(src storage.PieceReader, pi int) error {
 		return fmt.Errorf("copy: %s", err)
 	}
 	if h.Sum32() != t.metaInfo.GetPieceSum(pi) {
-		return errors.New("invalid piece sum")
+		return storage.ErrPieceCorrupt
 	}
 
 	if err := t.markPieceComplete(pi); err != nil {
@@ -231,20 +233,79 @@ func (t *Torrent) WritePiece(src storage.PieceReader, pi int) error {
 	if err := t.writePiece(src, pi); err != nil {
 		// Allow other threads to write this piece since we mysteriously failed.
 		piece.markEmpty()
+		if err == storage.ErrPieceCorrupt {
+			return err
+		}
 		return fmt.Errorf("write piece: %s", err)
 	}
 
-	if int(t.numComplete.Load()) == len(t.pieces) {
-		// Multiple threads may attempt to move the download file to cache, however
-		// only one will succeed while the others will receive (and ignore) file exist
-		// error.
-		err := t.cads.MoveDownloadFileToCache(t.metaInfo.Digest().Hex())
-		if err != nil && !os.IsExist(err) {
-			return fmt.Errorf("download completed but failed to move file to cache directory: %s", err)
+	// Multiple threads may observe that all pieces are complete, however only
+	// one of them commits the download.
+	if int(t.numComplete.Load()) == len(t.pieces) && t.committing.CAS(false, true) {
+		defer t.committing.Store(false)
+		return t.commit()
+	}
+
+	return nil
+}
+
+// commit verifies the completed download file against the blob digest and
+// moves it to the cache. If verification fails, all pieces are reset and
+// ErrBlobCorrupt is returned.
+func (t *Torrent) commit() error {
+	name := t.metaInfo.Digest().Hex()
+	if err := t.verify(); err != nil {
+		if err != storage.ErrBlobCorrupt {
+			return fmt.Errorf("verify download: %s", err)
+		}
+		if resetErr := t.reset(); resetErr != nil {
+			return fmt.Errorf("reset corrupt download: %s", resetErr)
 		}
-		t.committed.Store(true)
+		return err
+	}
+	err := t.cads.MoveDownloadFileToCache(name)
+	if err != nil && !os.IsExist(err) {
+		return fmt.Errorf("download completed but failed to move file to cache directory: %s", err)
+	}
+	t.committed.Store(true)
+	return nil
+}
+
+// verify checks that the download file hashes to the blob digest. Piece sums
+// only guard against accidental corruption of individual pieces, so the whole
+// blob is verified before it is served to clients.
+func (t *Torrent) verify() error {
+	f, err := t.cads.Download().GetFileReader(t.metaInfo.Digest().Hex())
+	if t.cads.InCacheError(err) {
+		// Already verified and committed by another Torrent instance.
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("get download reader: %s", err)
 	}
+	defer f.Close()
 
+	d, err := core.NewDigester().FromReader(f)
+	if err != nil {
+		return fmt.Errorf("digest: %s", err)
+	}
+	if d != t.metaInfo.Digest() {
+		return storage.ErrBlobCorrupt
+	}
+	return nil
+}
+
+// reset marks all pieces as empty.
+func (t *Torrent) reset() error {
+	name := t.metaInfo.Digest().Hex()
+	for pi, p := range t.pieces {
+		_, err := t.cads.Download().SetMetadataAt(
+			name, &pieceStatusMetadata{}, []byte{byte(_empty)}, int64(pi))
+		if err != nil {
+			return fmt.Errorf("write piece metadata: %s", err)
+		}
+		p.markEmpty()
+	}
+	t.numComplete.Store(0)
 	return nil
 }
 
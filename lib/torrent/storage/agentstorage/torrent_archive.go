@@ -94,6 +94,13 @@ func (a *TorrentArchive) CreateTorrent(namespace string, d core.Digest) (storage
 			!(a.cads.InDownloadError(createErr) || a.cads.InCacheError(createErr)) {
 			return nil, fmt.Errorf("create download file: %s", createErr)
 		}
+		if createErr == nil {
+			// Record the namespace such that cache quotas can be applied per
+			// namespace.
+			if _, err := a.cads.Any().SetMetadata(d.Hex(), metadata.NewNamespace(namespace)); err != nil {
+				return nil, fmt.Errorf("set namespace: %s", err)
+			}
+		}
 		tm.MetaInfo = mi
 		if err := a.cads.Any().GetOrSetMetadata(d.Hex(), &tm); err != nil {
 			return nil, fmt.Errorf("get or set metainfo: %s", err)
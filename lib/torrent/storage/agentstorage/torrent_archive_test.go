@@ -120,6 +120,11 @@ func TestTorrentArchiveCreateTorrent(t *testing.T) {
 	require.NoError(mocks.cads.Any().GetMetadata(mi.Digest().Hex(), &tm))
 	require.Equal(mi, tm.MetaInfo)
 
+	// Check namespace.
+	var ns metadata.Namespace
+	require.NoError(mocks.cads.Any().GetMetadata(mi.Digest().Hex(), &ns))
+	require.Equal(namespace, ns.Value)
+
 	// Create again reads from disk.
 	tor, err = archive.CreateTorrent(namespace, mi.Digest())
 	require.NoError(err)
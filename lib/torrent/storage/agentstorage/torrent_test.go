@@ -248,7 +248,7 @@ func newCoordinatedWriter(f store.FileReadWriter) *coordinatedWriter {
 func (w *coordinatedWriter) Write(b []byte) (int, error) {
 	w.startWriting <- true
 	<-w.stopWriting
-	return len(b), nil
+	return w.FileReadWriter.Write(b)
 }
 
 func TestTorrentWritePieceConflictsDoNotBlock(t *testing.T) {
@@ -256,16 +256,16 @@ func TestTorrentWritePieceConflictsDoNotBlock(t *testing.T) {
 
 	blob := core.SizedBlobFixture(1, 1)
 
-	f, cleanup := store.NewMockFileReadWriter([]byte{})
-	defer cleanup()
-
-	w := newCoordinatedWriter(f)
-
 	cads, cleanup := store.CADownloadStoreFixture()
 	defer cleanup()
 
 	prepareStore(cads, blob.MetaInfo)
 
+	f, err := cads.GetDownloadFileReadWriter(blob.Digest.Hex())
+	require.NoError(err)
+
+	w := newCoordinatedWriter(f)
+
 	mockCADS := &mockGetDownloadFileReadWriterStore{cads, w}
 
 	tor, err := NewTorrent(mockCADS, blob.MetaInfo)
@@ -313,7 +313,15 @@ func TestTorrentWritePieceFailuresRemoveDirtyStatus(t *testing.T) {
 
 		// Second write succeeds.
 		w.EXPECT().Seek(int64(0), 0).Return(int64(0), nil),
-		w.EXPECT().Write(blob.Content).Return(len(blob.Content), nil),
+		w.EXPECT().Write(blob.Content).DoAndReturn(func(b []byte) (int, error) {
+			// Persist the content, since the completed download is verified.
+			f, err := cads.GetDownloadFileReadWriter(blob.Digest.Hex())
+			if err != nil {
+				return 0, err
+			}
+			defer f.Close()
+			return f.Write(b)
+		}),
 		w.EXPECT().Close().Return(nil),
 	)
 
@@ -377,3 +385,89 @@ func TestTorrentRestoreInProgressTorrent(t *testing.T) {
 		storage.ErrPieceComplete,
 		tor.WritePiece(piecereader.NewBuffer([]byte{blob.Content[pi]}), pi))
 }
+
+func TestTorrentWriteCorruptPiece(t *testing.T) {
+	require := require.New(t)
+
+	cads, cleanup := store.CADownloadStoreFixture()
+	defer cleanup()
+
+	blob := core.SizedBlobFixture(2, 1)
+
+	prepareStore(cads, blob.MetaInfo)
+
+	tor, err := NewTorrent(cads, blob.MetaInfo)
+	require.NoError(err)
+
+	require.Equal(
+		storage.ErrPieceCorrupt,
+		tor.WritePiece(piecereader.NewBuffer([]byte{blob.Content[0] + 1}), 0))
+	require.Equal(int64(0), tor.BytesDownloaded())
+
+	// The piece can be written again.
+	require.NoError(tor.WritePiece(piecereader.NewBuffer(blob.Content[:1]), 0))
+	require.Equal(int64(1), tor.BytesDownloaded())
+}
+
+// corruptingReadWriter corrupts all bytes written through it.
+type corruptingReadWriter struct {
+	store.FileReadWriter
+}
+
+func (w corruptingReadWriter) Write(b []byte) (int, error) {
+	c := make([]byte, len(b))
+	for i := range b {
+		c[i] = b[i] + 1
+	}
+	return w.FileReadWriter.Write(c)
+}
+
+// corruptingStore corrupts the content of all pieces written through it, while
+// the piece sums are computed over the original content.
+type corruptingStore struct {
+	*store.CADownloadStore
+}
+
+func (s corruptingStore) GetDownloadFileReadWriter(name string) (store.FileReadWriter, error) {
+	f, err := s.CADownloadStore.GetDownloadFileReadWriter(name)
+	if err != nil {
+		return nil, err
+	}
+	return corruptingReadWriter{f}, nil
+}
+
+func TestTorrentWriteCorruptBlobResetsTorrent(t *testing.T) {
+	require := require.New(t)
+
+	cads, cleanup := store.CADownloadStoreFixture()
+	defer cleanup()
+
+	blob := core.SizedBlobFixture(4, 1)
+
+	prepareStore(cads, blob.MetaInfo)
+
+	tor, err := NewTorrent(corruptingStore{cads}, blob.MetaInfo)
+	require.NoError(err)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(tor.WritePiece(piecereader.NewBuffer(blob.Content[i:i+1]), i))
+	}
+	require.Equal(storage.ErrBlobCorrupt, tor.WritePiece(piecereader.NewBuffer(blob.Content[3:]), 3))
+
+	require.False(tor.Complete())
+	require.Equal(int64(0), tor.BytesDownloaded())
+	require.Len(tor.MissingPieces(), 4)
+
+	_, err = cads.Cache().GetFileStat(blob.Digest.Hex())
+	require.Error(err)
+
+	// Piece statuses are reset on disk too.
+	tor, err = NewTorrent(cads, blob.MetaInfo)
+	require.NoError(err)
+	require.Equal(int64(0), tor.BytesDownloaded())
+
+	for i := range blob.Content {
+		require.NoError(tor.WritePiece(piecereader.NewBuffer(blob.Content[i:i+1]), i))
+	}
+	require.True(tor.Complete())
+}
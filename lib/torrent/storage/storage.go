@@ -29,6 +29,15 @@ var ErrNotFound = errors.New("torrent not found")
 // complete.
 var ErrPieceComplete = errors.New("piece is already complete")
 
+// ErrPieceCorrupt occurs when Torrent rejects a piece because its content does
+// not match the piece sum in the torrent metainfo.
+var ErrPieceCorrupt = errors.New("piece does not match piece sum")
+
+// ErrBlobCorrupt occurs when Torrent rejects a completed download because its
+// content does not match the blob digest. The torrent is reset, such that all
+// pieces are downloaded again.
+var ErrBlobCorrupt = errors.New("blob does not match digest")
+
 // PieceReader defines operations for lazy piece reading.
 type PieceReader interface {
 	io.ReadCloser
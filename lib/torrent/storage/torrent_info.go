@@ -63,3 +63,8 @@ func (i *TorrentInfo) PercentDownloaded() int {
 func (i *TorrentInfo) Bitfield() *bitset.BitSet {
 	return i.bitfield
 }
+
+// WithBitfield returns a copy of i with bitfield replaced by b.
+func (i *TorrentInfo) WithBitfield(b *bitset.BitSet) *TorrentInfo {
+	return NewTorrentInfo(i.metainfo, b)
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/uber/kraken/utils/log"
 
 	"github.com/andres-erbsen/clock"
+	"github.com/uber-go/tally"
 )
 
 // ActiveConfig composes host configuration for an upstream service with an
@@ -29,6 +30,7 @@ type ActiveConfig struct {
 	HealthCheck ActiveHealthCheckConfig `yaml:"healthcheck"`
 
 	checker healthcheck.Checker
+	stats   tally.Scope
 }
 
 // ActiveHealthCheckConfig wraps health check configuration.
@@ -36,6 +38,11 @@ type ActiveHealthCheckConfig struct {
 	Filter   healthcheck.FilterConfig  `yaml:"filter"`
 	Monitor  healthcheck.MonitorConfig `yaml:"monitor"`
 	Disabled bool                      `yaml:"disabled"`
+
+	// Passive optionally layers a passive health check on top of the active
+	// one, such that hosts which fail requests are ejected without waiting
+	// for the next probes.
+	Passive *healthcheck.PassiveFilterConfig `yaml:"passive"`
 }
 
 // ActiveOption allows setting optional ActiveConfig parameters.
@@ -46,6 +53,11 @@ func WithHealthCheck(checker healthcheck.Checker) ActiveOption {
 	return func(c *ActiveConfig) { c.checker = checker }
 }
 
+// WithStats configures ActiveConfig to report health check metrics.
+func WithStats(stats tally.Scope) ActiveOption {
+	return func(c *ActiveConfig) { c.stats = stats }
+}
+
 // Build creates a healthcheck.List with built-in active health checks.
 func (c ActiveConfig) Build(opts ...ActiveOption) (healthcheck.List, error) {
 	hosts, err := hostlist.New(c.Hosts)
@@ -57,11 +69,17 @@ func (c ActiveConfig) Build(opts ...ActiveOption) (healthcheck.List, error) {
 		return healthcheck.NoopFailed(hosts), nil
 	}
 	c.checker = healthcheck.Default(nil)
+	c.stats = tally.NoopScope
 	for _, opt := range opts {
 		opt(&c)
 	}
 	filter := healthcheck.NewFilter(c.HealthCheck.Filter, c.checker)
-	monitor := healthcheck.NewMonitor(c.HealthCheck.Monitor, hosts, filter)
+	monitor := healthcheck.NewMonitor(
+		c.HealthCheck.Monitor, hosts, filter, healthcheck.WithStats(c.stats))
+	if c.HealthCheck.Passive != nil {
+		f := healthcheck.NewPassiveFilter(*c.HealthCheck.Passive, clock.New())
+		return healthcheck.NewPassive(monitor, f, healthcheck.WithStats(c.stats)), nil
+	}
 	return healthcheck.NoopFailed(monitor), nil
 }
 
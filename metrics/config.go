@@ -18,6 +18,8 @@ type Config struct {
 	Backend string       `yaml:"backend"`
 	Statsd  StatsdConfig `yaml:"statsd"`
 	M3      M3Config     `yaml:"m3"`
+
+	Prometheus PrometheusConfig `yaml:"prometheus"`
 }
 
 // StatsdConfig defines statsd configuration.
@@ -32,3 +34,12 @@ type M3Config struct {
 	Service  string `yaml:"service"`
 	Env      string `yaml:"env"`
 }
+
+type PrometheusConfig struct {
+	// ListenAddress optionally serves metrics on a dedicated address, in
+	// addition to the /metrics endpoint of each component's server.
+	ListenAddress string `yaml:"listen_address"`
+
+	// TimerType is either "summary" (default) or "histogram".
+	TimerType string `yaml:"timer_type"`
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/uber/kraken/utils/log"
+
+	prom "github.com/m3db/prometheus_client_golang/prometheus"
+	"github.com/uber-go/tally"
+	"github.com/uber-go/tally/prometheus"
+)
+
+// PrometheusHandlerPath is the path which Prometheus metrics are served on.
+const PrometheusHandlerPath = "/metrics"
+
+// Timer types accepted by PrometheusConfig.
+const (
+	PrometheusTimerSummary   = "summary"
+	PrometheusTimerHistogram = "histogram"
+)
+
+var (
+	_prometheusHandlerOnce sync.Once
+	_prometheusHandler     = &swappableHandler{h: http.NotFoundHandler()}
+)
+
+// swappableHandler serves the scrape handler of the most recently created
+// Prometheus scope.
+type swappableHandler struct {
+	sync.RWMutex
+	h http.Handler
+}
+
+func (s *swappableHandler) set(h http.Handler) {
+	s.Lock()
+	defer s.Unlock()
+	s.h = h
+}
+
+func (s *swappableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.RLock()
+	h := s.h
+	s.RUnlock()
+	h.ServeHTTP(w, r)
+}
+
+// PrometheusHandler returns the handler which serves metrics of the
+// Prometheus backend. It is registered on http.DefaultServeMux, so servers
+// which do not mount the default mux must route PrometheusHandlerPath to it.
+func PrometheusHandler() http.Handler {
+	return _prometheusHandler
+}
+
+func newPrometheusScope(config Config, cluster string) (tally.Scope, io.Closer, error) {
+	var timerType prometheus.TimerType
+	switch config.Prometheus.TimerType {
+	case "", PrometheusTimerSummary:
+		timerType = prometheus.SummaryTimerType
+	case PrometheusTimerHistogram:
+		timerType = prometheus.HistogramTimerType
+	default:
+		return nil, nil, fmt.Errorf("invalid prometheus timer type %q", config.Prometheus.TimerType)
+	}
+
+	registry := prom.NewRegistry()
+	registry.MustRegister(prom.NewGoCollector())
+	registry.MustRegister(prom.NewProcessCollector(os.Getpid(), ""))
+
+	r := prometheus.NewReporter(prometheus.Options{
+		Registerer:       registry,
+		DefaultTimerType: timerType,
+		OnRegisterError: func(err error) {
+			log.Warnf("Error registering prometheus metric: %s", err)
+		},
+	})
+
+	h := r.HTTPHandler()
+	_prometheusHandler.set(h)
+	_prometheusHandlerOnce.Do(func() {
+		http.Handle(PrometheusHandlerPath, _prometheusHandler)
+	})
+
+	if config.Prometheus.ListenAddress != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle(PrometheusHandlerPath, h)
+			log.Infof("Serving prometheus metrics on %s", config.Prometheus.ListenAddress)
+			if err := http.ListenAndServe(config.Prometheus.ListenAddress, mux); err != nil {
+				log.Errorf("Error serving prometheus metrics: %s", err)
+			}
+		}()
+	}
+
+	var tags map[string]string
+	if cluster != "" {
+		tags = map[string]string{"cluster": cluster}
+	}
+	s, c := tally.NewRootScope(tally.ScopeOptions{
+		Tags:            tags,
+		CachedReporter:  r,
+		Separator:       prometheus.DefaultSeparator,
+		SanitizeOptions: &prometheus.DefaultSanitizerOpts,
+	}, time.Second)
+	return s, c, nil
+}
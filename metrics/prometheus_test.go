@@ -0,0 +1,67 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+func scrapePrometheus(t *testing.T) string {
+	w := httptest.NewRecorder()
+	PrometheusHandler().ServeHTTP(w, httptest.NewRequest("GET", PrometheusHandlerPath, nil))
+	b, err := ioutil.ReadAll(w.Result().Body)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestPrometheusScope(t *testing.T) {
+	require := require.New(t)
+
+	s, closer, err := New(Config{Backend: "prometheus"}, "some-cluster")
+	require.NoError(err)
+	defer closer.Close()
+
+	stats := s.Tagged(map[string]string{"module": "foo"}).SubScope("bar")
+	stats.Counter("some-counter").Inc(3)
+	stats.Gauge("gauge").Update(7)
+	stats.Timer("latency").Record(time.Second)
+	stats.Histogram("sizes", tally.ValueBuckets{1, 10}).RecordValue(5)
+
+	// Metrics with labels inconsistent with earlier registrations are dropped.
+	stats.Tagged(map[string]string{"extra": "x"}).Counter("some-counter").Inc(1)
+
+	time.Sleep(1500 * time.Millisecond)
+
+	result := scrapePrometheus(t)
+	require.Contains(result, `bar_some_counter{cluster="some_cluster",module="foo"} 3`)
+	require.Contains(result, `bar_gauge{cluster="some_cluster",module="foo"} 7`)
+	require.Contains(result, `bar_latency_count{cluster="some_cluster",module="foo"} 1`)
+	require.Contains(result, `bar_sizes_bucket{cluster="some_cluster",module="foo",le="10"} 1`)
+	require.NotContains(result, `extra="x"`)
+	require.Contains(result, "go_goroutines")
+}
+
+func TestPrometheusInvalidTimerType(t *testing.T) {
+	_, _, err := New(Config{
+		Backend:    "prometheus",
+		Prometheus: PrometheusConfig{TimerType: "foo"},
+	}, "")
+	require.Error(t, err)
+}
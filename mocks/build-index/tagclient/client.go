@@ -36,6 +36,48 @@ func (m *MockClient) EXPECT() *MockClientMockRecorder {
 	return m.recorder
 }
 
+// Delete mocks base method
+func (m *MockClient) Delete(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete
+func (mr *MockClientMockRecorder) Delete(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockClient)(nil).Delete), arg0)
+}
+
+// DeleteAndReplicate mocks base method
+func (m *MockClient) DeleteAndReplicate(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAndReplicate", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAndReplicate indicates an expected call of DeleteAndReplicate
+func (mr *MockClientMockRecorder) DeleteAndReplicate(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAndReplicate", reflect.TypeOf((*MockClient)(nil).DeleteAndReplicate), arg0)
+}
+
+// DuplicateDelete mocks base method
+func (m *MockClient) DuplicateDelete(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DuplicateDelete", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DuplicateDelete indicates an expected call of DuplicateDelete
+func (mr *MockClientMockRecorder) DuplicateDelete(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DuplicateDelete", reflect.TypeOf((*MockClient)(nil).DuplicateDelete), arg0)
+}
+
 // DuplicatePut mocks base method
 func (m *MockClient) DuplicatePut(arg0 string, arg1 core.Digest, arg2 time.Duration) error {
 	m.ctrl.T.Helper()
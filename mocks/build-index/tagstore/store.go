@@ -34,6 +34,34 @@ func (m *MockStore) EXPECT() *MockStoreMockRecorder {
 	return m.recorder
 }
 
+// Delete mocks base method
+func (m *MockStore) Delete(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete
+func (mr *MockStoreMockRecorder) Delete(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockStore)(nil).Delete), arg0)
+}
+
+// DeleteLocal mocks base method
+func (m *MockStore) DeleteLocal(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteLocal", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteLocal indicates an expected call of DeleteLocal
+func (mr *MockStoreMockRecorder) DeleteLocal(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteLocal", reflect.TypeOf((*MockStore)(nil).DeleteLocal), arg0)
+}
+
 // Get mocks base method
 func (m *MockStore) Get(arg0 string) (core.Digest, error) {
 	m.ctrl.T.Helper()
@@ -7,6 +7,7 @@ package mockhashring
 import (
 	gomock "github.com/golang/mock/gomock"
 	core "github.com/uber/kraken/core"
+	http "net/http"
 	reflect "reflect"
 )
 
@@ -47,6 +48,32 @@ func (mr *MockRingMockRecorder) Contains(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Contains", reflect.TypeOf((*MockRing)(nil).Contains), arg0)
 }
 
+// DebugHandler mocks base method
+func (m *MockRing) DebugHandler() http.Handler {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DebugHandler")
+	ret0, _ := ret[0].(http.Handler)
+	return ret0
+}
+
+// DebugHandler indicates an expected call of DebugHandler
+func (mr *MockRingMockRecorder) DebugHandler() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DebugHandler", reflect.TypeOf((*MockRing)(nil).DebugHandler))
+}
+
+// Drain mocks base method
+func (m *MockRing) Drain(arg0 string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Drain", arg0)
+}
+
+// Drain indicates an expected call of Drain
+func (mr *MockRingMockRecorder) Drain(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Drain", reflect.TypeOf((*MockRing)(nil).Drain), arg0)
+}
+
 // Locations mocks base method
 func (m *MockRing) Locations(arg0 core.Digest) []string {
 	m.ctrl.T.Helper()
@@ -8,6 +8,7 @@ import (
 	gomock "github.com/golang/mock/gomock"
 	core "github.com/uber/kraken/core"
 	scheduler "github.com/uber/kraken/lib/torrent/scheduler"
+	conn "github.com/uber/kraken/lib/torrent/scheduler/conn"
 	connstate "github.com/uber/kraken/lib/torrent/scheduler/connstate"
 	reflect "reflect"
 )
@@ -35,6 +36,20 @@ func (m *MockReloadableScheduler) EXPECT() *MockReloadableSchedulerMockRecorder
 	return m.recorder
 }
 
+// BandwidthLimits mocks base method
+func (m *MockReloadableScheduler) BandwidthLimits() conn.BandwidthLimits {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BandwidthLimits")
+	ret0, _ := ret[0].(conn.BandwidthLimits)
+	return ret0
+}
+
+// BandwidthLimits indicates an expected call of BandwidthLimits
+func (mr *MockReloadableSchedulerMockRecorder) BandwidthLimits() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BandwidthLimits", reflect.TypeOf((*MockReloadableScheduler)(nil).BandwidthLimits))
+}
+
 // BlacklistSnapshot mocks base method
 func (m *MockReloadableScheduler) BlacklistSnapshot() ([]connstate.BlacklistedConn, error) {
 	m.ctrl.T.Helper()
@@ -64,6 +79,20 @@ func (mr *MockReloadableSchedulerMockRecorder) Download(arg0, arg1 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Download", reflect.TypeOf((*MockReloadableScheduler)(nil).Download), arg0, arg1)
 }
 
+// DownloadWithPriority mocks base method
+func (m *MockReloadableScheduler) DownloadWithPriority(arg0 string, arg1 core.Digest, arg2 scheduler.Priority) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DownloadWithPriority", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DownloadWithPriority indicates an expected call of DownloadWithPriority
+func (mr *MockReloadableSchedulerMockRecorder) DownloadWithPriority(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DownloadWithPriority", reflect.TypeOf((*MockReloadableScheduler)(nil).DownloadWithPriority), arg0, arg1, arg2)
+}
+
 // Probe mocks base method
 func (m *MockReloadableScheduler) Probe() error {
 	m.ctrl.T.Helper()
@@ -104,6 +133,20 @@ func (mr *MockReloadableSchedulerMockRecorder) RemoveTorrent(arg0 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveTorrent", reflect.TypeOf((*MockReloadableScheduler)(nil).RemoveTorrent), arg0)
 }
 
+// SetBandwidthLimits mocks base method
+func (m *MockReloadableScheduler) SetBandwidthLimits(arg0 conn.BandwidthLimits) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetBandwidthLimits", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetBandwidthLimits indicates an expected call of SetBandwidthLimits
+func (mr *MockReloadableSchedulerMockRecorder) SetBandwidthLimits(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBandwidthLimits", reflect.TypeOf((*MockReloadableScheduler)(nil).SetBandwidthLimits), arg0)
+}
+
 // Stop mocks base method
 func (m *MockReloadableScheduler) Stop() {
 	m.ctrl.T.Helper()
@@ -115,3 +158,46 @@ func (mr *MockReloadableSchedulerMockRecorder) Stop() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stop", reflect.TypeOf((*MockReloadableScheduler)(nil).Stop))
 }
+
+// CancelTorrent mocks base method
+func (m *MockReloadableScheduler) CancelTorrent(arg0 core.Digest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelTorrent", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelTorrent indicates an expected call of CancelTorrent
+func (mr *MockReloadableSchedulerMockRecorder) CancelTorrent(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelTorrent", reflect.TypeOf((*MockReloadableScheduler)(nil).CancelTorrent), arg0)
+}
+
+// Reannounce mocks base method
+func (m *MockReloadableScheduler) Reannounce(arg0 core.Digest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reannounce", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reannounce indicates an expected call of Reannounce
+func (mr *MockReloadableSchedulerMockRecorder) Reannounce(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reannounce", reflect.TypeOf((*MockReloadableScheduler)(nil).Reannounce), arg0)
+}
+
+// TorrentStatuses mocks base method
+func (m *MockReloadableScheduler) TorrentStatuses() ([]scheduler.TorrentStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TorrentStatuses")
+	ret0, _ := ret[0].([]scheduler.TorrentStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TorrentStatuses indicates an expected call of TorrentStatuses
+func (mr *MockReloadableSchedulerMockRecorder) TorrentStatuses() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TorrentStatuses", reflect.TypeOf((*MockReloadableScheduler)(nil).TorrentStatuses))
+}
@@ -7,6 +7,8 @@ package mockscheduler
 import (
 	gomock "github.com/golang/mock/gomock"
 	core "github.com/uber/kraken/core"
+	scheduler "github.com/uber/kraken/lib/torrent/scheduler"
+	conn "github.com/uber/kraken/lib/torrent/scheduler/conn"
 	connstate "github.com/uber/kraken/lib/torrent/scheduler/connstate"
 	reflect "reflect"
 )
@@ -34,6 +36,20 @@ func (m *MockScheduler) EXPECT() *MockSchedulerMockRecorder {
 	return m.recorder
 }
 
+// BandwidthLimits mocks base method
+func (m *MockScheduler) BandwidthLimits() conn.BandwidthLimits {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BandwidthLimits")
+	ret0, _ := ret[0].(conn.BandwidthLimits)
+	return ret0
+}
+
+// BandwidthLimits indicates an expected call of BandwidthLimits
+func (mr *MockSchedulerMockRecorder) BandwidthLimits() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BandwidthLimits", reflect.TypeOf((*MockScheduler)(nil).BandwidthLimits))
+}
+
 // BlacklistSnapshot mocks base method
 func (m *MockScheduler) BlacklistSnapshot() ([]connstate.BlacklistedConn, error) {
 	m.ctrl.T.Helper()
@@ -63,6 +79,20 @@ func (mr *MockSchedulerMockRecorder) Download(arg0, arg1 interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Download", reflect.TypeOf((*MockScheduler)(nil).Download), arg0, arg1)
 }
 
+// DownloadWithPriority mocks base method
+func (m *MockScheduler) DownloadWithPriority(arg0 string, arg1 core.Digest, arg2 scheduler.Priority) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DownloadWithPriority", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DownloadWithPriority indicates an expected call of DownloadWithPriority
+func (mr *MockSchedulerMockRecorder) DownloadWithPriority(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DownloadWithPriority", reflect.TypeOf((*MockScheduler)(nil).DownloadWithPriority), arg0, arg1, arg2)
+}
+
 // Probe mocks base method
 func (m *MockScheduler) Probe() error {
 	m.ctrl.T.Helper()
@@ -91,6 +121,20 @@ func (mr *MockSchedulerMockRecorder) RemoveTorrent(arg0 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveTorrent", reflect.TypeOf((*MockScheduler)(nil).RemoveTorrent), arg0)
 }
 
+// SetBandwidthLimits mocks base method
+func (m *MockScheduler) SetBandwidthLimits(arg0 conn.BandwidthLimits) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetBandwidthLimits", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetBandwidthLimits indicates an expected call of SetBandwidthLimits
+func (mr *MockSchedulerMockRecorder) SetBandwidthLimits(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBandwidthLimits", reflect.TypeOf((*MockScheduler)(nil).SetBandwidthLimits), arg0)
+}
+
 // Stop mocks base method
 func (m *MockScheduler) Stop() {
 	m.ctrl.T.Helper()
@@ -102,3 +146,46 @@ func (mr *MockSchedulerMockRecorder) Stop() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stop", reflect.TypeOf((*MockScheduler)(nil).Stop))
 }
+
+// CancelTorrent mocks base method
+func (m *MockScheduler) CancelTorrent(arg0 core.Digest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelTorrent", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelTorrent indicates an expected call of CancelTorrent
+func (mr *MockSchedulerMockRecorder) CancelTorrent(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelTorrent", reflect.TypeOf((*MockScheduler)(nil).CancelTorrent), arg0)
+}
+
+// Reannounce mocks base method
+func (m *MockScheduler) Reannounce(arg0 core.Digest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reannounce", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reannounce indicates an expected call of Reannounce
+func (mr *MockSchedulerMockRecorder) Reannounce(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reannounce", reflect.TypeOf((*MockScheduler)(nil).Reannounce), arg0)
+}
+
+// TorrentStatuses mocks base method
+func (m *MockScheduler) TorrentStatuses() ([]scheduler.TorrentStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TorrentStatuses")
+	ret0, _ := ret[0].([]scheduler.TorrentStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TorrentStatuses indicates an expected call of TorrentStatuses
+func (mr *MockSchedulerMockRecorder) TorrentStatuses() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TorrentStatuses", reflect.TypeOf((*MockScheduler)(nil).TorrentStatuses))
+}
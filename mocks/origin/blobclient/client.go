@@ -77,6 +77,20 @@ func (mr *MockClientMockRecorder) DownloadBlob(arg0, arg1, arg2 interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DownloadBlob", reflect.TypeOf((*MockClient)(nil).DownloadBlob), arg0, arg1, arg2)
 }
 
+// DownloadBlobRange mocks base method
+func (m *MockClient) DownloadBlobRange(arg0 string, arg1 core.Digest, arg2 int64, arg3 int64, arg4 io.Writer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DownloadBlobRange", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DownloadBlobRange indicates an expected call of DownloadBlobRange
+func (mr *MockClientMockRecorder) DownloadBlobRange(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DownloadBlobRange", reflect.TypeOf((*MockClient)(nil).DownloadBlobRange), arg0, arg1, arg2, arg3, arg4)
+}
+
 // DuplicateUploadBlob mocks base method
 func (m *MockClient) DuplicateUploadBlob(arg0 string, arg1 core.Digest, arg2 io.Reader, arg3 time.Duration) error {
 	m.ctrl.T.Helper()
@@ -164,6 +178,20 @@ func (mr *MockClientMockRecorder) OverwriteMetaInfo(arg0, arg1 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OverwriteMetaInfo", reflect.TypeOf((*MockClient)(nil).OverwriteMetaInfo), arg0, arg1)
 }
 
+// PurgeBlob mocks base method
+func (m *MockClient) PurgeBlob(arg0 string, arg1 core.Digest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeBlob", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PurgeBlob indicates an expected call of PurgeBlob
+func (mr *MockClientMockRecorder) PurgeBlob(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeBlob", reflect.TypeOf((*MockClient)(nil).PurgeBlob), arg0, arg1)
+}
+
 // ReplicateToRemote mocks base method
 func (m *MockClient) ReplicateToRemote(arg0 string, arg1 core.Digest, arg2 string) error {
 	m.ctrl.T.Helper()
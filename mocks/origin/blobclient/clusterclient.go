@@ -92,6 +92,20 @@ func (mr *MockClusterClientMockRecorder) Owners(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Owners", reflect.TypeOf((*MockClusterClient)(nil).Owners), arg0)
 }
 
+// PurgeBlob mocks base method
+func (m *MockClusterClient) PurgeBlob(arg0 string, arg1 core.Digest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeBlob", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PurgeBlob indicates an expected call of PurgeBlob
+func (mr *MockClusterClientMockRecorder) PurgeBlob(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeBlob", reflect.TypeOf((*MockClusterClient)(nil).PurgeBlob), arg0, arg1)
+}
+
 // ReplicateToRemote mocks base method
 func (m *MockClusterClient) ReplicateToRemote(arg0 string, arg1 core.Digest, arg2 string) error {
 	m.ctrl.T.Helper()
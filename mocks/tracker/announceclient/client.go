@@ -49,3 +49,18 @@ func (mr *MockClientMockRecorder) Announce(arg0, arg1, arg2, arg3 interface{}) *
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Announce", reflect.TypeOf((*MockClient)(nil).Announce), arg0, arg1, arg2, arg3)
 }
+
+// Probe mocks base method
+func (m *MockClient) Probe(arg0 core.Digest, arg1 core.InfoHash) ([]*core.PeerInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Probe", arg0, arg1)
+	ret0, _ := ret[0].([]*core.PeerInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Probe indicates an expected call of Probe
+func (mr *MockClientMockRecorder) Probe(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Probe", reflect.TypeOf((*MockClient)(nil).Probe), arg0, arg1)
+}
@@ -24,6 +24,10 @@ upstream agent-server {
   server {{.agent_server}};
 }
 
+upstream registry-override {
+  server {{.registry_override_server}};
+}
+
 server {
   listen {{.port}};
 
@@ -41,10 +45,25 @@ server {
   gzip_types text/plain test/csv application/json;
 
   location /health {
-    proxy_pass http://agent-server;
+    proxy_pass {{.agent_server_scheme}}://agent-server;
+  }
+
+  location /v2/_catalog {
+    proxy_pass http://registry-override;
+  }
+
+  location ~ ^/v2/.+/(tags/list|referrers/) {
+    proxy_pass http://registry-override;
   }
 
   location / {
+    {{if .registry_mirror}}
+    # Mirror requests are rewritten by registry override before reaching the
+    # registry.
+    if ($arg_ns) {
+      proxy_pass http://registry-override;
+    }
+    {{end}}
     proxy_pass http://registry-backend;
     proxy_next_upstream error timeout http_404 http_500;
   }
@@ -37,7 +37,23 @@ if ($scheme = http) {
 if ($request_method ~ ^(GET|HEAD)$) {
   set $required_verified_client 0;
 }
-if ($remote_addr = "127.0.0.1") {
+if ($remote_addr ~ "^(127\.0\.0\.1|::1)$") {
+  set $required_verified_client 0;
+}
+
+set $verfied_client $required_verified_client$ssl_client_verify;
+if ($verfied_client !~ ^(0.*|1SUCCESS)$) {
+  return 403;
+}
+`
+
+// MutualTLSClientVerification is the nginx configuration for client
+// verification in the server block when mutual TLS is enabled. Verified client
+// certificates are required on every request, except those from localhost.
+const MutualTLSClientVerification = `
+ssl_verify_client optional;
+set $required_verified_client 1;
+if ($remote_addr ~ "^(127\.0\.0\.1|::1)$") {
   set $required_verified_client 0;
 }
 
@@ -59,6 +59,48 @@ server {
     proxy_set_header Host $hostheader:{{.}};
   }
 
+  # Tags are listed from build-index, and the OCI referrers API is served from
+  # tags maintained by the registry.
+  location ~ ^/v2/.+/(tags/list|referrers/) {
+    proxy_pass http://registry-override;
+
+    set $hostheader $hostname;
+    if ( $host = "localhost" ) {
+      set $hostheader "localhost";
+    }
+    if ( $host = "127.0.0.1" ) {
+      set $hostheader "127.0.0.1";
+    }
+    if ( $host = "192.168.65.1" ) {
+      set $hostheader "192.168.65.1";
+    }
+    if ( $host = "host.docker.internal" ) {
+      set $hostheader "host.docker.internal";
+    }
+    proxy_set_header Host $hostheader:{{.}};
+  }
+
+  # Blob upload sessions are resumed by registry override before reaching the
+  # registry.
+  location ~ ^/v2/.+/blobs/uploads/[^/]+$ {
+    proxy_pass http://registry-override;
+
+    set $hostheader $hostname;
+    if ( $host = "localhost" ) {
+      set $hostheader "localhost";
+    }
+    if ( $host = "127.0.0.1" ) {
+      set $hostheader "127.0.0.1";
+    }
+    if ( $host = "192.168.65.1" ) {
+      set $hostheader "192.168.65.1";
+    }
+    if ( $host = "host.docker.internal" ) {
+      set $hostheader "host.docker.internal";
+    }
+    proxy_set_header Host $hostheader:{{.}};
+  }
+
   location / {
     proxy_pass http://registry;
 
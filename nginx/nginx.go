@@ -22,7 +22,9 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"syscall"
 	"text/template"
+	"time"
 
 	"github.com/uber/kraken/nginx/config"
 	"github.com/uber/kraken/utils/httputil"
@@ -119,7 +121,11 @@ func (c *Config) Build(params map[string]interface{}) ([]byte, error) {
 		return nil, fmt.Errorf("get template: %s", err)
 	}
 	if _, ok := params["client_verification"]; !ok {
-		params["client_verification"] = config.DefaultClientVerification
+		if c.tls.MutualTLS {
+			params["client_verification"] = config.MutualTLSClientVerification
+		} else {
+			params["client_verification"] = config.DefaultClientVerification
+		}
 	}
 	site, err := populateTemplate(tmpl, params)
 	if err != nil {
@@ -174,6 +180,9 @@ func Run(config Config, params map[string]interface{}, opts ...Option) error {
 	}
 
 	if config.tls.Server.Disabled {
+		if config.tls.MutualTLS {
+			return errors.New("invalid TLS config: mutual tls requires server tls")
+		}
 		log.Warn("Server TLS is disabled")
 	} else {
 		for _, s := range append(
@@ -185,16 +194,9 @@ func Run(config Config, params map[string]interface{}, opts ...Option) error {
 				return fmt.Errorf("invalid TLS config: %s", err)
 			}
 		}
-
-		// Concat all ca files into bundle.
-		cabundle, err := os.Create(_clientCABundle)
-		if err != nil {
-			return fmt.Errorf("create cabundle: %s", err)
-		}
-		if err := config.tls.WriteCABundle(cabundle); err != nil {
-			return fmt.Errorf("write cabundle: %s", err)
+		if err := config.writeCABundle(); err != nil {
+			return err
 		}
-		cabundle.Close()
 	}
 
 	if err := os.MkdirAll(config.CacheDir, 0775); err != nil {
@@ -227,7 +229,66 @@ func Run(config Config, params map[string]interface{}, opts ...Option) error {
 	cmd := exec.Command(args[0], args[1:]...)
 	cmd.Stdout = stdout
 	cmd.Stderr = stdout
-	return cmd.Run()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if !config.tls.Server.Disabled {
+		done := make(chan struct{})
+		defer close(done)
+		go config.reloadOnTLSChange(cmd.Process, done)
+	}
+	return cmd.Wait()
+}
+
+// writeCABundle concats all ca files into the client ca bundle.
+func (c *Config) writeCABundle() error {
+	cabundle, err := os.Create(_clientCABundle)
+	if err != nil {
+		return fmt.Errorf("create cabundle: %s", err)
+	}
+	defer cabundle.Close()
+	if err := c.tls.WriteCABundle(cabundle); err != nil {
+		return fmt.Errorf("write cabundle: %s", err)
+	}
+	return nil
+}
+
+// reloadOnTLSChange signals nginx to reload its configuration whenever the
+// server certificate or CAs change on disk, such that rotated certificates
+// are served without restarts.
+func (c *Config) reloadOnTLSChange(p *os.Process, done <-chan struct{}) {
+	version, err := c.tls.ServerFilesVersion()
+	if err != nil {
+		log.Errorf("Error checking TLS files, reload disabled: %s", err)
+		return
+	}
+	ticker := time.NewTicker(c.tls.GetReloadInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			v, err := c.tls.ServerFilesVersion()
+			if err != nil {
+				log.Errorf("Error checking TLS files: %s", err)
+				continue
+			}
+			if v == version {
+				continue
+			}
+			if err := c.writeCABundle(); err != nil {
+				log.Errorf("Error reloading TLS files: %s", err)
+				continue
+			}
+			if err := p.Signal(syscall.SIGHUP); err != nil {
+				log.Errorf("Error signaling nginx reload: %s", err)
+				continue
+			}
+			log.Info("TLS files changed, reloaded nginx")
+			version = v
+		}
+	}
 }
 
 func populateTemplate(tmpl string, args map[string]interface{}) ([]byte, error) {
@@ -38,6 +38,7 @@ type Client interface {
 
 	Locations(d core.Digest) ([]string, error)
 	DeleteBlob(d core.Digest) error
+	PurgeBlob(namespace string, d core.Digest) error
 	TransferBlob(d core.Digest, blob io.Reader) error
 
 	Stat(namespace string, d core.Digest) (*core.BlobInfo, error)
@@ -50,6 +51,7 @@ type Client interface {
 	DuplicateUploadBlob(namespace string, d core.Digest, blob io.Reader, delay time.Duration) error
 
 	DownloadBlob(namespace string, d core.Digest, dst io.Writer) error
+	DownloadBlobRange(namespace string, d core.Digest, offset, length int64, dst io.Writer) error
 
 	ReplicateToRemote(namespace string, d core.Digest, remoteDNS string) error
 
@@ -163,6 +165,18 @@ func (c *HTTPClient) DeleteBlob(d core.Digest) error {
 	return err
 }
 
+// PurgeBlob deletes the blob corresponding to d from the origin's disk and
+// from the storage backend of namespace. Purging a missing blob is not an
+// error.
+func (c *HTTPClient) PurgeBlob(namespace string, d core.Digest) error {
+	_, err := httputil.Delete(
+		fmt.Sprintf("http://%s/internal/namespace/%s/blobs/%s",
+			c.addr, url.PathEscape(namespace), d),
+		httputil.SendTimeout(30*time.Second),
+		httputil.SendTLS(c.tls))
+	return err
+}
+
 // TransferBlob uploads a blob to a single origin server. Unlike its cousin UploadBlob,
 // TransferBlob is an internal API which does not replicate the blob.
 func (c *HTTPClient) TransferBlob(d core.Digest, blob io.Reader) error {
@@ -204,6 +218,33 @@ func (c *HTTPClient) DownloadBlob(namespace string, d core.Digest, dst io.Writer
 	return nil
 }
 
+// DownloadBlobRange downloads length bytes of the blob for d, starting at
+// offset. Returns the same errors as DownloadBlob, or an error if the server
+// does not honor the range.
+func (c *HTTPClient) DownloadBlobRange(
+	namespace string, d core.Digest, offset, length int64, dst io.Writer) error {
+
+	r, err := httputil.Get(
+		fmt.Sprintf("http://%s/namespace/%s/blobs/%s", c.addr, url.PathEscape(namespace), d),
+		httputil.SendHeaders(map[string]string{
+			"Range": fmt.Sprintf("bytes=%d-%d", offset, offset+length-1),
+		}),
+		httputil.SendAcceptedCodes(http.StatusPartialContent),
+		httputil.SendTLS(c.tls))
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	n, err := io.Copy(dst, r.Body)
+	if err != nil {
+		return fmt.Errorf("copy body: %s", err)
+	}
+	if n != length {
+		return fmt.Errorf("short range: got %d bytes, expected %d", n, length)
+	}
+	return nil
+}
+
 // ReplicateToRemote replicates the blob of d to a remote origin cluster. If the
 // blob of d is not available yet, returns 202 httputil.StatusError, indicating
 // that the request should be retried later.
@@ -85,6 +85,7 @@ type ClusterClient interface {
 	OverwriteMetaInfo(d core.Digest, pieceLength int64) error
 	Owners(d core.Digest) ([]core.PeerContext, error)
 	ReplicateToRemote(namespace string, d core.Digest, remoteDNS string) error
+	PurgeBlob(namespace string, d core.Digest) error
 }
 
 type clusterClient struct {
@@ -245,6 +246,22 @@ func (c *clusterClient) ReplicateToRemote(namespace string, d core.Digest, remot
 	})
 }
 
+// PurgeBlob deletes d from every origin which owns it, and from the storage
+// backend of namespace.
+func (c *clusterClient) PurgeBlob(namespace string, d core.Digest) error {
+	clients, err := c.resolver.Resolve(d)
+	if err != nil {
+		return fmt.Errorf("resolve clients: %s", err)
+	}
+	var errs []error
+	for _, client := range clients {
+		if err := client.PurgeBlob(namespace, d); err != nil {
+			errs = append(errs, fmt.Errorf("origin %s: %s", client.Addr(), err))
+		}
+	}
+	return errutil.Join(errs)
+}
+
 func shuffle(cs []Client) {
 	for i := range cs {
 		j := rand.Intn(i + 1)
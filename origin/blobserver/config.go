@@ -23,6 +23,10 @@ import (
 type Config struct {
 	Listener                  listener.Config `yaml:"listener"`
 	DuplicateWriteBackStagger time.Duration   `yaml:"duplicate_write_back_stagger"`
+
+	// DebugToken enables the /x/torrents and /x/writeback debug endpoints,
+	// which require it as bearer token.
+	DebugToken string `yaml:"debug_token"`
 }
 
 func (c Config) applyDefaults() Config {
@@ -0,0 +1,163 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package blobserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/origin/blobclient"
+	"github.com/uber/kraken/utils/handler"
+	"github.com/uber/kraken/utils/log"
+)
+
+// drainProgress reports the progress of draining the origin, as rendered by
+// the drain endpoints.
+type drainProgress struct {
+	Draining   bool       `json:"draining"`
+	Done       bool       `json:"done"`
+	Total      int        `json:"total"`
+	Replicated int        `json:"replicated"`
+	Errors     []string   `json:"errors"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// drainer tracks the drain state of the origin.
+type drainer struct {
+	mu       sync.Mutex
+	progress drainProgress
+}
+
+func (d *drainer) draining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.progress.Draining
+}
+
+func (d *drainer) snapshot() drainProgress {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	p := d.progress
+	p.Errors = append([]string(nil), d.progress.Errors...)
+	return p
+}
+
+func (d *drainer) update(f func(p *drainProgress)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	f(&d.progress)
+}
+
+// startDrainHandler marks the origin as draining. A draining origin fails its
+// health checks and rejects new uploads, such that it stops accepting new blobs,
+// and replicates each of its blobs to the origins which take over its
+// digests on the hash ring. Draining cannot be undone without restarting the
+// origin. Returns the drain progress.
+func (s *Server) startDrainHandler(w http.ResponseWriter, r *http.Request) error {
+	start := false
+	s.drainer.update(func(p *drainProgress) {
+		if p.Draining {
+			return
+		}
+		now := s.clk.Now()
+		*p = drainProgress{Draining: true, StartedAt: &now}
+		start = true
+	})
+	if start {
+		log.Info("Draining origin")
+		s.hashRing.Drain(s.addr)
+		go s.drain()
+		w.WriteHeader(http.StatusAccepted)
+	}
+	return s.writeDrainProgress(w)
+}
+
+// getDrainHandler returns the drain progress.
+func (s *Server) getDrainHandler(w http.ResponseWriter, r *http.Request) error {
+	return s.writeDrainProgress(w)
+}
+
+func (s *Server) writeDrainProgress(w http.ResponseWriter) error {
+	if err := json.NewEncoder(w).Encode(s.drainer.snapshot()); err != nil {
+		return handler.Errorf("encode drain progress: %s", err)
+	}
+	return nil
+}
+
+// drain replicates every blob of the origin to its new locations, as if the
+// origin were not part of the hash ring.
+func (s *Server) drain() {
+	names, err := s.cas.ListCacheFiles()
+	if err != nil {
+		s.finishDrain(fmt.Errorf("list cache files: %s", err))
+		return
+	}
+	s.drainer.update(func(p *drainProgress) { p.Total = len(names) })
+	for _, name := range names {
+		if err := s.drainBlob(name); err != nil {
+			s.stats.Counter("drain_errors").Inc(1)
+			log.With("blob", name).Errorf("Error draining blob: %s", err)
+			s.drainer.update(func(p *drainProgress) {
+				p.Errors = append(p.Errors, fmt.Sprintf("%s: %s", name, err))
+			})
+			continue
+		}
+		s.stats.Counter("drained_blobs").Inc(1)
+		s.drainer.update(func(p *drainProgress) { p.Replicated++ })
+	}
+	s.finishDrain(nil)
+}
+
+func (s *Server) drainBlob(name string) error {
+	d, err := core.NewSHA256DigestFromHex(name)
+	if err != nil {
+		return fmt.Errorf("parse digest: %s", err)
+	}
+	// Blobs which have not been written back yet would otherwise only exist
+	// in the replicas, which are transferred without write-back.
+	if err := s.flushWriteBack(name); err != nil {
+		return err
+	}
+	return s.applyToReplicas(d, func(i int, client blobclient.Client) error {
+		f, err := s.cas.GetCacheFileReader(name)
+		if err != nil {
+			return fmt.Errorf("get cache reader: %s", err)
+		}
+		defer f.Close()
+		if err := client.TransferBlob(d, f); err != nil {
+			return fmt.Errorf("transfer blob: %s", err)
+		}
+		return nil
+	})
+}
+
+func (s *Server) finishDrain(err error) {
+	s.drainer.update(func(p *drainProgress) {
+		if err != nil {
+			p.Errors = append(p.Errors, err.Error())
+		}
+		now := s.clk.Now()
+		p.Done = true
+		p.FinishedAt = &now
+	})
+	log.Info("Finished draining origin")
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package blobserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/persistedretry"
+	"github.com/uber/kraken/lib/persistedretry/writeback"
+	"github.com/uber/kraken/utils/httputil"
+)
+
+func getDrainProgress(t *testing.T, addr string) drainProgress {
+	resp, err := httputil.Get(fmt.Sprintf("http://%s/internal/drain", addr))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var p drainProgress
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&p))
+	return p
+}
+
+func TestDrainReplicatesBlobsToNextOrigin(t *testing.T) {
+	require := require.New(t)
+
+	ring := hashRingNoReplica()
+	namespace := core.TagFixture()
+
+	cp := newTestClientProvider()
+
+	s1 := newTestServer(t, master1, ring, cp)
+	defer s1.cleanup()
+
+	s2 := newTestServer(t, master2, ring, cp)
+	defer s2.cleanup()
+
+	// s2 must be next in line for the blob, rather than the third origin on
+	// the ring which is not running.
+	var blob *core.BlobFixture
+	for {
+		blob = core.SizedBlobFixture(32, 4)
+		locs := hashRingSomeReplica().Locations(blob.Digest)
+		if locs[0] == s1.host && locs[1] == s2.host {
+			break
+		}
+	}
+
+	task := writeback.NewTask(namespace, blob.Digest.Hex(), 0)
+	s1.writeBackManager.EXPECT().Add(writeback.MatchTask(task)).Return(nil)
+
+	require.NoError(cp.Provide(s1.host).UploadBlob(namespace, blob.Digest, bytes.NewReader(blob.Content)))
+
+	require.False(getDrainProgress(t, s1.addr).Draining)
+
+	// Pending write-backs are flushed before the blob is handed over.
+	s1.writeBackManager.EXPECT().Find(
+		writeback.NewNameQuery(blob.Digest.Hex())).Return([]persistedretry.Task{task}, nil)
+	s1.writeBackManager.EXPECT().SyncExec(task).Return(nil)
+
+	resp, err := httputil.Post(
+		fmt.Sprintf("http://%s/internal/drain", s1.addr),
+		httputil.SendAcceptedCodes(http.StatusAccepted))
+	require.NoError(err)
+	resp.Body.Close()
+
+	require.Eventually(func() bool {
+		return getDrainProgress(t, s1.addr).Done
+	}, 5*time.Second, 10*time.Millisecond)
+
+	p := getDrainProgress(t, s1.addr)
+	require.True(p.Draining)
+	require.Equal(1, p.Total)
+	require.Equal(1, p.Replicated)
+	require.Empty(p.Errors)
+
+	// The next origin on the ring takes over the blob.
+	require.Equal([]string{s2.host}, ring.Locations(blob.Digest))
+	ensureHasBlob(t, cp.Provide(s2.host), namespace, blob)
+
+	// Starting a drain again is a no-op.
+	resp, err = httputil.Post(fmt.Sprintf("http://%s/internal/drain", s1.addr))
+	require.NoError(err)
+	resp.Body.Close()
+}
+
+func TestDrainingOriginRejectsNewBlobs(t *testing.T) {
+	require := require.New(t)
+
+	ring := hashRingNoReplica()
+	namespace := core.TagFixture()
+
+	cp := newTestClientProvider()
+
+	s := newTestServer(t, master1, ring, cp)
+	defer s.cleanup()
+
+	resp, err := httputil.Post(
+		fmt.Sprintf("http://%s/internal/drain", s.addr),
+		httputil.SendAcceptedCodes(http.StatusAccepted))
+	require.NoError(err)
+	resp.Body.Close()
+
+	_, err = httputil.Get(fmt.Sprintf("http://%s/health", s.addr))
+	require.True(httputil.IsStatus(err, http.StatusServiceUnavailable))
+
+	blob := core.SizedBlobFixture(32, 4)
+
+	err = cp.Provide(s.host).UploadBlob(namespace, blob.Digest, bytes.NewReader(blob.Content))
+	require.True(httputil.IsStatus(err, http.StatusServiceUnavailable))
+
+	err = cp.Provide(s.host).TransferBlob(blob.Digest, bytes.NewReader(blob.Content))
+	require.True(httputil.IsStatus(err, http.StatusServiceUnavailable))
+}
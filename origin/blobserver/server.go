@@ -16,7 +16,6 @@ package blobserver
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	_ "net/http/pprof" // Registers /debug/pprof endpoints in http.DefaultServeMux.
 	"os"
@@ -33,6 +32,8 @@ import (
 	"github.com/uber/kraken/lib/metainfogen"
 	"github.com/uber/kraken/lib/middleware"
 	"github.com/uber/kraken/lib/persistedretry"
+	"github.com/uber/kraken/lib/persistedretry/blobreplication"
+	"github.com/uber/kraken/lib/persistedretry/notification"
 	"github.com/uber/kraken/lib/persistedretry/writeback"
 	"github.com/uber/kraken/lib/store"
 	"github.com/uber/kraken/lib/store/metadata"
@@ -67,6 +68,13 @@ type Server struct {
 	metaInfoGenerator *metainfogen.Generator
 	uploader          *uploader
 	writeBackManager  persistedretry.Manager
+	drainer           *drainer
+
+	// Replicates blobs with remote origin clusters.
+	blobReplicationManager persistedretry.Manager
+	blobReplicationRemotes blobreplication.Remotes
+
+	notifier notification.Notifier
 
 	// This is an unfortunate coupling between the p2p client and the blob server.
 	// Tracker queries the origin cluster to discover which origins can seed
@@ -89,7 +97,10 @@ func New(
 	backends *backend.Manager,
 	blobRefresher *blobrefresh.Refresher,
 	metaInfoGenerator *metainfogen.Generator,
-	writeBackManager persistedretry.Manager) (*Server, error) {
+	writeBackManager persistedretry.Manager,
+	blobReplicationManager persistedretry.Manager,
+	blobReplicationRemotes blobreplication.Remotes,
+	notifier notification.Notifier) (*Server, error) {
 
 	config = config.applyDefaults()
 
@@ -111,7 +122,13 @@ func New(
 		metaInfoGenerator: metaInfoGenerator,
 		uploader:          newUploader(cas),
 		writeBackManager:  writeBackManager,
+		drainer:           &drainer{},
 		pctx:              pctx,
+
+		blobReplicationManager: blobReplicationManager,
+		blobReplicationRemotes: blobReplicationRemotes,
+
+		notifier: notifier,
 	}, nil
 }
 
@@ -124,6 +141,7 @@ func (s *Server) Addr() string {
 func (s *Server) Handler() http.Handler {
 	r := chi.NewRouter()
 
+	r.Use(middleware.Tracing())
 	r.Use(middleware.StatusCounter(s.stats))
 	r.Use(middleware.LatencyTimer(s.stats))
 
@@ -150,11 +168,15 @@ func (s *Server) Handler() http.Handler {
 	r.Put("/internal/blobs/{digest}/uploads/{uid}", handler.Wrap(s.commitTransferHandler))
 
 	r.Delete("/internal/blobs/{digest}", handler.Wrap(s.deleteBlobHandler))
+	r.Delete("/internal/namespace/{namespace}/blobs/{digest}", handler.Wrap(s.purgeBlobHandler))
 
 	r.Post("/internal/blobs/{digest}/metainfo", handler.Wrap(s.overwriteMetaInfoHandler))
 
 	r.Get("/internal/peercontext", handler.Wrap(s.getPeerContextHandler))
 
+	r.Post("/internal/drain", handler.Wrap(s.startDrainHandler))
+	r.Get("/internal/drain", handler.Wrap(s.getDrainHandler))
+
 	r.Head("/internal/namespace/{namespace}/blobs/{digest}", handler.Wrap(s.statHandler))
 
 	r.Get("/internal/namespace/{namespace}/blobs/{digest}/metainfo", handler.Wrap(s.getMetaInfoHandler))
@@ -163,6 +185,8 @@ func (s *Server) Handler() http.Handler {
 		"/internal/duplicate/namespace/{namespace}/blobs/{digest}/uploads/{uid}",
 		handler.Wrap(s.duplicateCommitClusterUploadHandler))
 
+	r.Mount("/debug/hashring", s.hashRing.DebugHandler())
+
 	r.Mount("/", http.DefaultServeMux) // Serves /debug/pprof endpoints.
 
 	return r
@@ -175,6 +199,10 @@ func (s *Server) ListenAndServe(h http.Handler) error {
 }
 
 func (s *Server) healthCheckHandler(w http.ResponseWriter, r *http.Request) error {
+	if s.drainer.draining() {
+		// Fail health checks such that peers stop selecting this origin.
+		return handler.Errorf("draining").Status(http.StatusServiceUnavailable)
+	}
 	fmt.Fprintln(w, "OK")
 	return nil
 }
@@ -238,11 +266,7 @@ func (s *Server) downloadBlobHandler(w http.ResponseWriter, r *http.Request) err
 	if err != nil {
 		return err
 	}
-	if err := s.downloadBlob(namespace, d, w); err != nil {
-		return err
-	}
-	setOctetStreamContentType(w)
-	return nil
+	return s.downloadBlob(w, r, namespace, d)
 }
 
 func (s *Server) replicateToRemoteHandler(w http.ResponseWriter, r *http.Request) error {
@@ -293,6 +317,27 @@ func (s *Server) deleteBlobHandler(w http.ResponseWriter, r *http.Request) error
 	return nil
 }
 
+// purgeBlobHandler deletes a blob from both the storage backend and local
+// disk. Unlike deleteBlobHandler, purging is permanent and is only intended
+// for garbage collection of unreferenced blobs.
+func (s *Server) purgeBlobHandler(w http.ResponseWriter, r *http.Request) error {
+	namespace, err := httputil.ParseParam(r, "namespace")
+	if err != nil {
+		return err
+	}
+	d, err := httputil.ParseDigest(r, "digest")
+	if err != nil {
+		return err
+	}
+	if err := s.purgeBlob(namespace, d); err != nil {
+		return err
+	}
+	setContentLength(w, 0)
+	w.WriteHeader(http.StatusOK)
+	log.With("namespace", namespace, "digest", d).Info("Purged blob")
+	return nil
+}
+
 func (s *Server) getLocationsHandler(w http.ResponseWriter, r *http.Request) error {
 	d, err := httputil.ParseDigest(r, "digest")
 	if err != nil {
@@ -400,7 +445,7 @@ func (s *Server) startRemoteBlobDownload(
 	case blobrefresh.ErrPending, nil:
 		return handler.ErrorStatus(http.StatusAccepted)
 	case blobrefresh.ErrNotFound:
-		return handler.ErrorStatus(http.StatusNotFound)
+		return s.pullFromRemotes(namespace, d)
 	case blobrefresh.ErrWorkersBusy:
 		return handler.ErrorStatus(http.StatusServiceUnavailable)
 	default:
@@ -408,6 +453,44 @@ func (s *Server) startRemoteBlobDownload(
 	}
 }
 
+// pullFromRemotes adds a pull task for the first pull remote which has d, such
+// that blobs missing from the storage backends are replicated from remote
+// origin clusters. Returns 202 if a pull task was added, else 404.
+func (s *Server) pullFromRemotes(namespace string, d core.Digest) error {
+	for _, addr := range s.blobReplicationRemotes.Match(namespace, blobreplication.PolicyPull) {
+		remote, err := s.clusterProvider.Provide(addr)
+		if err != nil {
+			log.With("remote", addr).Errorf("Error providing remote cluster: %s", err)
+			continue
+		}
+		if _, err := remote.Stat(namespace, d); err != nil {
+			if err != blobclient.ErrBlobNotFound {
+				log.With("remote", addr, "blob", d.Hex()).Errorf("Error stating remote blob: %s", err)
+			}
+			continue
+		}
+		task := blobreplication.NewTask(namespace, d, addr, blobreplication.PolicyPull, 0)
+		if err := s.blobReplicationManager.Add(task); err != nil {
+			return handler.Errorf("add blob replication task: %s", err)
+		}
+		return handler.ErrorStatus(http.StatusAccepted)
+	}
+	return handler.ErrorStatus(http.StatusNotFound)
+}
+
+// addPushTasks adds push tasks for every push remote configured for namespace.
+// Errors are not returned, since the reconciler eventually adds any missing
+// tasks.
+func (s *Server) addPushTasks(namespace string, d core.Digest) {
+	for _, addr := range s.blobReplicationRemotes.Match(namespace, blobreplication.PolicyPush) {
+		task := blobreplication.NewTask(namespace, d, addr, blobreplication.PolicyPush, 0)
+		if err := s.blobReplicationManager.Add(task); err != nil {
+			s.stats.Counter("add_blob_replication_task_errors").Inc(1)
+			log.With("remote", addr, "blob", d.Hex()).Errorf("Error adding blob replication task: %s", err)
+		}
+	}
+}
+
 func (s *Server) replicateBlobLocally(d core.Digest) error {
 	return s.applyToReplicas(d, func(i int, client blobclient.Client) error {
 		f, err := s.cas.GetCacheFileReader(d.Hex())
@@ -449,11 +532,13 @@ func (s *Server) applyToReplicas(d core.Digest, f func(i int, c blobclient.Clien
 	return errutil.Join(errs)
 }
 
-// downloadBlob downloads blob for d into dst. If no blob exists under d, a
-// download of the blob from the storage backend configured for namespace will
-// be initiated. This download is asynchronous and downloadBlob will immediately
-// return a "202 Accepted" handler error.
-func (s *Server) downloadBlob(namespace string, d core.Digest, dst io.Writer) error {
+// downloadBlob serves blob for d, or the range of it requested by r, to w. If
+// no blob exists under d, a download of the blob from the storage backend
+// configured for namespace will be initiated. This download is asynchronous and
+// downloadBlob will immediately return a "202 Accepted" handler error.
+func (s *Server) downloadBlob(
+	w http.ResponseWriter, r *http.Request, namespace string, d core.Digest) error {
+
 	f, err := s.cas.GetCacheFileReader(d.Hex())
 	if os.IsNotExist(err) {
 		return s.startRemoteBlobDownload(namespace, d, true)
@@ -462,9 +547,8 @@ func (s *Server) downloadBlob(namespace string, d core.Digest, dst io.Writer) er
 	}
 	defer f.Close()
 
-	if _, err := io.Copy(dst, f); err != nil {
-		return handler.Errorf("copy blob: %s", err)
-	}
+	setOctetStreamContentType(w)
+	store.ServeFile(w, r, d, f)
 	return nil
 }
 
@@ -478,12 +562,39 @@ func (s *Server) deleteBlob(d core.Digest) error {
 	return nil
 }
 
+func (s *Server) purgeBlob(namespace string, d core.Digest) error {
+	client, err := s.backends.GetClient(namespace)
+	if err != nil {
+		return handler.Errorf("get backend client: %s", err)
+	}
+	if err := backend.Delete(client, namespace, d.Hex()); err != nil {
+		if err == backend.ErrDeleteNotSupported {
+			return handler.Errorf(
+				"backend of namespace %s does not support deletion", namespace).Status(http.StatusNotImplemented)
+		}
+		if err != backenderrors.ErrBlobNotFound {
+			return handler.Errorf("backend delete: %s", err)
+		}
+	}
+	// Any pending write-back tasks are dropped once the cache file is gone.
+	if err := s.cas.DeleteCacheFileMetadata(d.Hex(), &metadata.Persist{}); err != nil && !os.IsNotExist(err) {
+		return handler.Errorf("delete persist metadata: %s", err)
+	}
+	if err := s.cas.DeleteCacheFile(d.Hex()); err != nil && !os.IsNotExist(err) {
+		return handler.Errorf("delete blob data: %s", err)
+	}
+	return nil
+}
+
 // startTransferHandler initializes an upload for internal blob transfers.
 func (s *Server) startTransferHandler(w http.ResponseWriter, r *http.Request) error {
 	d, err := httputil.ParseDigest(r, "digest")
 	if err != nil {
 		return err
 	}
+	if s.drainer.draining() {
+		return handler.Errorf("origin is draining").Status(http.StatusServiceUnavailable)
+	}
 	if ok, err := blobExists(s.cas, d); err != nil {
 		return handler.Errorf("check blob: %s", err)
 	} else if ok {
@@ -558,6 +669,10 @@ func (s *Server) startClusterUploadHandler(w http.ResponseWriter, r *http.Reques
 	if err != nil {
 		return err
 	}
+	if s.drainer.draining() {
+		// Clients retry the next origin on 503.
+		return handler.Errorf("origin is draining").Status(http.StatusServiceUnavailable)
+	}
 	uid, err := s.uploader.start(d)
 	if err != nil {
 		return s.handleUploadConflict(err, namespace, d)
@@ -614,6 +729,8 @@ func (s *Server) commitClusterUploadHandler(w http.ResponseWriter, r *http.Reque
 	if err := s.writeBack(namespace, d, 0); err != nil {
 		return err
 	}
+	s.addPushTasks(namespace, d)
+	s.notifyUploaded(namespace, d)
 	err = s.applyToReplicas(d, func(i int, client blobclient.Client) error {
 		delay := s.config.DuplicateWriteBackStagger * time.Duration(i+1)
 		f, err := s.cas.GetCacheFileReader(d.Hex())
@@ -632,6 +749,16 @@ func (s *Server) commitClusterUploadHandler(w http.ResponseWriter, r *http.Reque
 	return nil
 }
 
+// notifyUploaded notifies that the upload of d was committed. Only the origin
+// which received the upload notifies, not the replicas it is duplicated to.
+func (s *Server) notifyUploaded(namespace string, d core.Digest) {
+	var size int64
+	if info, err := s.cas.GetCacheFileStat(d.Hex()); err == nil {
+		size = info.Size()
+	}
+	s.notifier.BlobUploaded(namespace, d, size)
+}
+
 // duplicateCommitClusterUploadHandler commits a duplicate blob upload, which
 // will attempt to write-back after the requested delay.
 func (s *Server) duplicateCommitClusterUploadHandler(w http.ResponseWriter, r *http.Request) error {
@@ -664,6 +791,9 @@ func (s *Server) writeBack(namespace string, d core.Digest, delay time.Duration)
 	if _, err := s.cas.SetCacheFileMetadata(d.Hex(), metadata.NewPersist(true)); err != nil {
 		return handler.Errorf("set persist metadata: %s", err)
 	}
+	if _, err := s.cas.SetCacheFileMetadata(d.Hex(), metadata.NewNamespace(namespace)); err != nil {
+		return handler.Errorf("set namespace metadata: %s", err)
+	}
 	task := writeback.NewTask(namespace, d.Hex(), delay)
 	if err := s.writeBackManager.Add(task); err != nil {
 		return handler.Errorf("add write-back task: %s", err)
@@ -720,26 +850,8 @@ func (s *Server) maybeDelete(name string, ttl time.Duration) (deleted bool, err
 	owns := stringset.FromSlice(s.hashRing.Locations(d)).Has(s.addr)
 	if expired || !owns {
 		// Ensure file is backed up properly before deleting.
-		var pm metadata.Persist
-		if err := s.cas.GetCacheFileMetadata(name, &pm); err != nil && !os.IsNotExist(err) {
-			return false, fmt.Errorf("store: %s", err)
-		}
-		if pm.Value {
-			// Note: It is possible that no writeback tasks exist, but the file
-			// is persisted. We classify this as a leaked file which is safe to
-			// delete.
-			tasks, err := s.writeBackManager.Find(writeback.NewNameQuery(name))
-			if err != nil {
-				return false, fmt.Errorf("find writeback tasks: %s", err)
-			}
-			for _, task := range tasks {
-				if err := s.writeBackManager.SyncExec(task); err != nil {
-					return false, fmt.Errorf("writeback: %s", err)
-				}
-			}
-			if err := s.cas.DeleteCacheFileMetadata(name, &metadata.Persist{}); err != nil {
-				return false, fmt.Errorf("delete persist: %s", err)
-			}
+		if err := s.flushWriteBack(name); err != nil {
+			return false, err
 		}
 		if err := s.cas.DeleteCacheFile(name); err != nil {
 			return false, fmt.Errorf("delete: %s", err)
@@ -748,3 +860,30 @@ func (s *Server) maybeDelete(name string, ttl time.Duration) (deleted bool, err
 	}
 	return false, nil
 }
+
+// flushWriteBack synchronously executes any pending write-back tasks of name
+// and clears its persist metadata, such that the file is safe to delete.
+func (s *Server) flushWriteBack(name string) error {
+	var pm metadata.Persist
+	if err := s.cas.GetCacheFileMetadata(name, &pm); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("store: %s", err)
+	}
+	if !pm.Value {
+		return nil
+	}
+	// Note: It is possible that no writeback tasks exist, but the file is
+	// persisted. We classify this as a leaked file which is safe to delete.
+	tasks, err := s.writeBackManager.Find(writeback.NewNameQuery(name))
+	if err != nil {
+		return fmt.Errorf("find writeback tasks: %s", err)
+	}
+	for _, task := range tasks {
+		if err := s.writeBackManager.SyncExec(task); err != nil {
+			return fmt.Errorf("writeback: %s", err)
+		}
+	}
+	if err := s.cas.DeleteCacheFileMetadata(name, &metadata.Persist{}); err != nil {
+		return fmt.Errorf("delete persist: %s", err)
+	}
+	return nil
+}
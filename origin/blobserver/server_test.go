@@ -15,10 +15,12 @@ package blobserver
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"testing"
 	"time"
 
@@ -28,8 +30,10 @@ import (
 	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/lib/backend/backenderrors"
 	"github.com/uber/kraken/lib/persistedretry"
+	"github.com/uber/kraken/lib/persistedretry/blobreplication"
 	"github.com/uber/kraken/lib/persistedretry/writeback"
 	"github.com/uber/kraken/lib/store/metadata"
+	"github.com/uber/kraken/mocks/origin/blobclient"
 	"github.com/uber/kraken/origin/blobclient"
 	"github.com/uber/kraken/utils/httputil"
 	"github.com/uber/kraken/utils/mockutil"
@@ -53,6 +57,39 @@ func TestHealth(t *testing.T) {
 	require.Equal("OK\n", string(b))
 }
 
+func TestHashRingDebugHandlers(t *testing.T) {
+	require := require.New(t)
+
+	cp := newTestClientProvider()
+
+	s := newTestServer(t, master1, hashRingSomeReplica(), cp)
+	defer s.cleanup()
+
+	resp, err := httputil.Get(fmt.Sprintf("http://%s/debug/hashring", s.addr))
+	require.NoError(err)
+	defer resp.Body.Close()
+	var ring struct {
+		Members []struct {
+			Addr string `json:"addr"`
+		} `json:"members"`
+	}
+	require.NoError(json.NewDecoder(resp.Body).Decode(&ring))
+	require.Len(ring.Members, 3)
+
+	blob := computeBlobForHosts(hashRingSomeReplica(), master1, master2)
+
+	resp, err = httputil.Get(fmt.Sprintf("http://%s/debug/hashring/%s", s.addr, blob.Digest))
+	require.NoError(err)
+	defer resp.Body.Close()
+	var ownership struct {
+		Locations []string `json:"locations"`
+		Satisfied bool     `json:"satisfied"`
+	}
+	require.NoError(json.NewDecoder(resp.Body).Decode(&ownership))
+	require.ElementsMatch([]string{master1, master2}, ownership.Locations)
+	require.True(ownership.Satisfied)
+}
+
 func TestStatHandlerLocalNotFound(t *testing.T) {
 	require := require.New(t)
 
@@ -200,6 +237,91 @@ func TestDownloadBlobNotFound(t *testing.T) {
 	require.Equal(http.StatusNotFound, err.(httputil.StatusError).Status)
 }
 
+func TestDownloadBlobNotFoundPullsFromRemote(t *testing.T) {
+	require := require.New(t)
+
+	cp := newTestClientProvider()
+
+	namespace := core.TagFixture()
+	s := newTestServerWithRemotes(t, master1, hashRingMaxReplica(), cp, blobreplication.RemotesConfig{
+		"remote-a": {Policy: blobreplication.PolicyPull, Namespaces: []string{".*"}},
+		"remote-b": {Policy: blobreplication.PolicyPull, Namespaces: []string{".*"}},
+	})
+	defer s.cleanup()
+
+	d := core.DigestFixture()
+
+	backendClient := s.backendClient(namespace)
+	backendClient.EXPECT().Stat(namespace, d.Hex()).Return(nil, backenderrors.ErrBlobNotFound)
+
+	remoteA := mockblobclient.NewMockClusterClient(s.ctrl)
+	remoteB := mockblobclient.NewMockClusterClient(s.ctrl)
+
+	gomock.InOrder(
+		s.clusterProvider.EXPECT().Provide("remote-a").Return(remoteA, nil),
+		remoteA.EXPECT().Stat(namespace, d).Return(nil, blobclient.ErrBlobNotFound),
+		s.clusterProvider.EXPECT().Provide("remote-b").Return(remoteB, nil),
+		remoteB.EXPECT().Stat(namespace, d).Return(core.NewBlobInfo(1), nil),
+		s.blobReplicationManager.EXPECT().Add(blobreplication.MatchTask(
+			blobreplication.NewTask(namespace, d, "remote-b", blobreplication.PolicyPull, 0))).Return(nil),
+	)
+
+	err := cp.Provide(master1).DownloadBlob(namespace, d, ioutil.Discard)
+	require.Error(err)
+	require.True(httputil.IsAccepted(err))
+}
+
+func TestDownloadBlobRange(t *testing.T) {
+	require := require.New(t)
+
+	cp := newTestClientProvider()
+
+	s := newTestServer(t, master1, hashRingMaxReplica(), cp)
+	defer s.cleanup()
+
+	client := cp.Provide(s.host)
+	blob := core.SizedBlobFixture(256, 8)
+	namespace := core.TagFixture()
+
+	require.NoError(client.TransferBlob(blob.Digest, bytes.NewReader(blob.Content)))
+
+	ensureHasBlob(t, client, namespace, blob)
+
+	blobURL := fmt.Sprintf("http://%s/namespace/%s/blobs/%s", s.addr, url.PathEscape(namespace), blob.Digest)
+
+	resp, err := httputil.Get(
+		blobURL,
+		httputil.SendHeaders(map[string]string{"Range": "bytes=100-199"}),
+		httputil.SendAcceptedCodes(http.StatusPartialContent))
+	require.NoError(err)
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	require.NoError(err)
+	require.Equal(blob.Content[100:200], b)
+	require.Equal("bytes 100-199/256", resp.Header.Get("Content-Range"))
+
+	var buf bytes.Buffer
+	require.NoError(client.DownloadBlobRange(namespace, blob.Digest, 200, 56, &buf))
+	require.Equal(blob.Content[200:], buf.Bytes())
+
+	_, err = httputil.Get(
+		blobURL,
+		httputil.SendHeaders(map[string]string{"Range": "bytes=300-"}),
+		httputil.SendAcceptedCodes(http.StatusPartialContent))
+	require.True(httputil.IsStatus(err, http.StatusRequestedRangeNotSatisfiable))
+
+	// Resumed downloads whose ETag does not match the blob digest restart from
+	// the beginning.
+	resp, err = httputil.Get(
+		blobURL,
+		httputil.SendHeaders(map[string]string{"Range": "bytes=100-", "If-Range": `"foo"`}))
+	require.NoError(err)
+	defer resp.Body.Close()
+	b, err = ioutil.ReadAll(resp.Body)
+	require.NoError(err)
+	require.Equal(blob.Content, b)
+}
+
 func TestDeleteBlob(t *testing.T) {
 	require := require.New(t)
 
@@ -236,6 +358,62 @@ func TestDeleteBlobInvalidParam(t *testing.T) {
 	require.True(httputil.IsStatus(err, http.StatusBadRequest))
 }
 
+func TestPurgeBlob(t *testing.T) {
+	require := require.New(t)
+
+	cp := newTestClientProvider()
+
+	s := newTestServer(t, master1, hashRingMaxReplica(), cp)
+	defer s.cleanup()
+
+	client := cp.Provide(s.host)
+
+	blob := core.NewBlobFixture()
+	namespace := core.TagFixture()
+
+	require.NoError(client.TransferBlob(blob.Digest, bytes.NewReader(blob.Content)))
+	_, err := s.cas.SetCacheFileMetadata(blob.Digest.Hex(), metadata.NewPersist(true))
+	require.NoError(err)
+
+	deleter := s.deletableBackendClient(namespace)
+	deleter.EXPECT().Delete(namespace, blob.Digest.Hex()).Return(nil)
+
+	require.NoError(client.PurgeBlob(namespace, blob.Digest))
+
+	_, err = client.StatLocal(namespace, blob.Digest)
+	require.Equal(blobclient.ErrBlobNotFound, err)
+
+	// Purging is idempotent.
+	deleter.EXPECT().Delete(namespace, blob.Digest.Hex()).Return(backenderrors.ErrBlobNotFound)
+
+	require.NoError(client.PurgeBlob(namespace, blob.Digest))
+}
+
+func TestPurgeBlobDeleteNotSupported(t *testing.T) {
+	require := require.New(t)
+
+	cp := newTestClientProvider()
+
+	s := newTestServer(t, master1, hashRingMaxReplica(), cp)
+	defer s.cleanup()
+
+	client := cp.Provide(s.host)
+
+	blob := core.NewBlobFixture()
+	namespace := core.TagFixture()
+
+	require.NoError(client.TransferBlob(blob.Digest, bytes.NewReader(blob.Content)))
+
+	s.backendClient(namespace)
+
+	err := client.PurgeBlob(namespace, blob.Digest)
+	require.Error(err)
+	require.True(httputil.IsStatus(err, http.StatusNotImplemented))
+
+	// The blob is kept on disk if the backend cannot delete it.
+	ensureHasBlob(t, client, namespace, blob)
+}
+
 func TestGetLocationsOK(t *testing.T) {
 	require := require.New(t)
 
@@ -689,6 +867,62 @@ func TestUploadBlobResilientToDuplicationFailure(t *testing.T) {
 	ensureHasBlob(t, cp.Provide(s.host), namespace, blob)
 }
 
+func TestUploadBlobAddsPushTasks(t *testing.T) {
+	require := require.New(t)
+
+	ring := hashRingNoReplica()
+	namespace := "foo/bar"
+
+	cp := newTestClientProvider()
+
+	s := newTestServerWithRemotes(t, master1, ring, cp, blobreplication.RemotesConfig{
+		"remote-a": {Policy: blobreplication.PolicyPush, Namespaces: []string{"foo/.*"}},
+		"remote-b": {Policy: blobreplication.PolicyPush, Namespaces: []string{"baz/.*"}},
+	})
+	defer s.cleanup()
+
+	blob := computeBlobForHosts(ring, s.host)
+
+	s.writeBackManager.EXPECT().Add(
+		writeback.MatchTask(writeback.NewTask(namespace, blob.Digest.Hex(), 0))).Return(nil)
+	s.blobReplicationManager.EXPECT().Add(blobreplication.MatchTask(
+		blobreplication.NewTask(namespace, blob.Digest, "remote-a", blobreplication.PolicyPush, 0))).Return(nil)
+
+	require.NoError(cp.Provide(s.host).UploadBlob(namespace, blob.Digest, bytes.NewReader(blob.Content)))
+
+	var ns metadata.Namespace
+	require.NoError(s.cas.GetCacheFileMetadata(blob.Digest.Hex(), &ns))
+	require.Equal(namespace, ns.Value)
+}
+
+func TestUploadBlobNotifiesOnlyOnce(t *testing.T) {
+	require := require.New(t)
+
+	ring := hashRingSomeReplica()
+	namespace := core.TagFixture()
+
+	cp := newTestClientProvider()
+
+	s1 := newTestServer(t, master1, ring, cp)
+	defer s1.cleanup()
+
+	s2 := newTestServer(t, master2, ring, cp)
+	defer s2.cleanup()
+
+	blob := computeBlobForHosts(ring, s1.host, s2.host)
+
+	s1.writeBackManager.EXPECT().Add(
+		writeback.MatchTask(writeback.NewTask(namespace, blob.Digest.Hex(), 0))).Return(nil)
+	s2.writeBackManager.EXPECT().Add(
+		writeback.MatchTask(writeback.NewTask(namespace, blob.Digest.Hex(), 30*time.Minute)))
+
+	require.NoError(cp.Provide(s1.host).UploadBlob(namespace, blob.Digest, bytes.NewReader(blob.Content)))
+
+	// Duplicated uploads to replicas do not notify.
+	require.Equal([]core.Digest{blob.Digest}, s1.notifier.getUploads())
+	require.Empty(s2.notifier.getUploads())
+}
+
 func TestForceCleanupTTL(t *testing.T) {
 	require := require.New(t)
 
@@ -15,6 +15,7 @@ package blobserver
 
 import (
 	"bytes"
+	"sync"
 	"testing"
 	"time"
 
@@ -32,6 +33,8 @@ import (
 	"github.com/uber/kraken/lib/healthcheck"
 	"github.com/uber/kraken/lib/hostlist"
 	"github.com/uber/kraken/lib/metainfogen"
+	"github.com/uber/kraken/lib/persistedretry/blobreplication"
+	"github.com/uber/kraken/lib/persistedretry/notification"
 	"github.com/uber/kraken/lib/store"
 	"github.com/uber/kraken/mocks/lib/backend"
 	"github.com/uber/kraken/mocks/lib/persistedretry"
@@ -101,12 +104,45 @@ type testServer struct {
 	backendManager   *backend.Manager
 	writeBackManager *mockpersistedretry.MockManager
 	clk              *clock.Mock
+	notifier         *testNotifier
 	cleanup          func()
+
+	blobReplicationManager *mockpersistedretry.MockManager
+}
+
+// testNotifier records blob upload notifications.
+type testNotifier struct {
+	notification.Notifier
+
+	mu      sync.Mutex
+	uploads []core.Digest
+}
+
+func (n *testNotifier) BlobUploaded(namespace string, d core.Digest, size int64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.uploads = append(n.uploads, d)
+}
+
+func (n *testNotifier) getUploads() []core.Digest {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.uploads
 }
 
 func newTestServer(
 	t *testing.T, host string, ring hashring.Ring, cp *testClientProvider) *testServer {
 
+	return newTestServerWithRemotes(t, host, ring, cp, nil)
+}
+
+func newTestServerWithRemotes(
+	t *testing.T,
+	host string,
+	ring hashring.Ring,
+	cp *testClientProvider,
+	remotes blobreplication.RemotesConfig) *testServer {
+
 	var cleanup testutil.Cleanup
 	defer cleanup.Recover()
 
@@ -124,6 +160,12 @@ func newTestServer(
 
 	writeBackManager := mockpersistedretry.NewMockManager(ctrl)
 
+	blobReplicationManager := mockpersistedretry.NewMockManager(ctrl)
+	blobReplicationRemotes, err := remotes.Build()
+	if err != nil {
+		panic(err)
+	}
+
 	mg := metainfogen.Fixture(cas, 4)
 
 	br := blobrefresh.New(blobrefresh.Config{}, tally.NoopScope, cas, bm, mg)
@@ -131,9 +173,12 @@ func newTestServer(
 	clk := clock.NewMock()
 	clk.Set(time.Now())
 
+	notifier := &testNotifier{Notifier: notification.NewNoopNotifier()}
+
 	s, err := New(
 		Config{}, tally.NoopScope, clk, host, ring, cas, cp, clusterProvider, pctx,
-		bm, br, mg, writeBackManager)
+		bm, br, mg, writeBackManager, blobReplicationManager, blobReplicationRemotes,
+		notifier)
 	if err != nil {
 		panic(err)
 	}
@@ -154,7 +199,10 @@ func newTestServer(
 		backendManager:   bm,
 		writeBackManager: writeBackManager,
 		clk:              clk,
+		notifier:         notifier,
 		cleanup:          cleanup.Run,
+
+		blobReplicationManager: blobReplicationManager,
 	}
 }
 
@@ -166,6 +214,21 @@ func (s *testServer) backendClient(namespace string) *mockbackend.MockClient {
 	return client
 }
 
+// deletableClient is a backend client which supports deletion.
+type deletableClient struct {
+	*mockbackend.MockClient
+	*mockbackend.MockDeleter
+}
+
+func (s *testServer) deletableBackendClient(namespace string) *mockbackend.MockDeleter {
+	deleter := mockbackend.NewMockDeleter(s.ctrl)
+	client := deletableClient{mockbackend.NewMockClient(s.ctrl), deleter}
+	if err := s.backendManager.Register(namespace, client); err != nil {
+		panic(err)
+	}
+	return deleter
+}
+
 func (s *testServer) expectRemoteCluster(dns string) *mockblobclient.MockClusterClient {
 	cc := mockblobclient.NewMockClusterClient(s.ctrl)
 	s.clusterProvider.EXPECT().Provide(dns).Return(cc, nil).MinTimes(1)
@@ -16,9 +16,10 @@ package cmd
 import (
 	"encoding/json"
 	"flag"
-	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
 
 	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/lib/backend"
@@ -27,7 +28,10 @@ import (
 	"github.com/uber/kraken/lib/healthcheck"
 	"github.com/uber/kraken/lib/hostlist"
 	"github.com/uber/kraken/lib/metainfogen"
+	"github.com/uber/kraken/lib/middleware"
 	"github.com/uber/kraken/lib/persistedretry"
+	"github.com/uber/kraken/lib/persistedretry/blobreplication"
+	"github.com/uber/kraken/lib/persistedretry/notification"
 	"github.com/uber/kraken/lib/persistedretry/writeback"
 	"github.com/uber/kraken/lib/store"
 	"github.com/uber/kraken/lib/torrent/networkevent"
@@ -37,6 +41,7 @@ import (
 	"github.com/uber/kraken/nginx"
 	"github.com/uber/kraken/origin/blobclient"
 	"github.com/uber/kraken/origin/blobserver"
+	"github.com/uber/kraken/tracing"
 	"github.com/uber/kraken/utils/configutil"
 	"github.com/uber/kraken/utils/handler"
 	"github.com/uber/kraken/utils/log"
@@ -155,6 +160,12 @@ func Run(flags *Flags, opts ...Option) {
 
 	go metrics.EmitVersion(stats)
 
+	tracingCloser, err := tracing.Init(config.Tracing, "kraken-origin")
+	if err != nil {
+		log.Fatalf("Failed to init tracing: %s", err)
+	}
+	defer tracingCloser.Close()
+
 	var hostname string
 	if flags.BlobServerHostName == "" {
 		var err error
@@ -168,7 +179,7 @@ func Run(flags *Flags, opts ...Option) {
 	log.Infof("Configuring origin with hostname '%s'", hostname)
 
 	if flags.PeerIP == "" {
-		localIP, err := netutil.GetLocalIP()
+		localIP, err := netutil.GetLocalIP(netutil.WithIPv6Fallback())
 		if err != nil {
 			log.Fatalf("Error getting local ip: %s", err)
 		}
@@ -186,7 +197,7 @@ func Run(flags *Flags, opts ...Option) {
 		log.Fatalf("Failed to create peer context: %s", err)
 	}
 
-	backendManager, err := backend.NewManager(config.Backends, config.Auth)
+	backendManager, err := backend.NewManager(config.Backends, config.Auth, backend.WithStats(stats))
 	if err != nil {
 		log.Fatalf("Error creating backend manager: %s", err)
 	}
@@ -196,10 +207,11 @@ func Run(flags *Flags, opts ...Option) {
 		log.Fatalf("Error creating local db: %s", err)
 	}
 
+	writeBackStore := writeback.NewStore(localDB)
 	writeBackManager, err := persistedretry.NewManager(
 		config.WriteBack,
 		stats,
-		writeback.NewStore(localDB),
+		writeBackStore,
 		writeback.NewExecutor(stats, cas, backendManager))
 	if err != nil {
 		log.Fatalf("Error creating write-back manager: %s", err)
@@ -242,15 +254,15 @@ func Run(flags *Flags, opts ...Option) {
 		hashring.WithWatcher(backend.NewBandwidthWatcher(backendManager)))
 	go hashRing.Monitor(nil)
 
-	addr := fmt.Sprintf("%s:%d", hostname, flags.BlobServerPort)
+	addr := net.JoinHostPort(hostname, strconv.Itoa(flags.BlobServerPort))
 	if !hashRing.Contains(addr) {
 		// When DNS is used for hash ring membership, the members will be IP
 		// addresses instead of hostnames.
-		ip, err := netutil.GetLocalIP()
+		ip, err := netutil.GetLocalIP(netutil.WithIPv6Fallback())
 		if err != nil {
 			log.Fatalf("Error getting local ip: %s", err)
 		}
-		addr = fmt.Sprintf("%s:%d", ip, flags.BlobServerPort)
+		addr = net.JoinHostPort(ip, strconv.Itoa(flags.BlobServerPort))
 		if !hashRing.Contains(addr) {
 			log.Fatalf(
 				"Neither %s nor %s (port %d) found in hash ring",
@@ -258,6 +270,44 @@ func Run(flags *Flags, opts ...Option) {
 		}
 	}
 
+	clusterProvider := blobclient.NewClusterProvider(blobclient.WithTLS(tls))
+
+	replicationRemotes, err := config.Replication.Remotes.Build()
+	if err != nil {
+		log.Fatalf("Error building blob replication remotes: %s", err)
+	}
+	replicationStore, err := blobreplication.NewStore(localDB, replicationRemotes)
+	if err != nil {
+		log.Fatalf("Error creating blob replication store: %s", err)
+	}
+	originCluster := blobclient.NewClusterClient(
+		blobclient.NewClientResolver(blobclient.NewProvider(blobclient.WithTLS(tls)), cluster))
+	replicationManager, err := persistedretry.NewManager(
+		config.Replication.Retry,
+		stats,
+		replicationStore,
+		blobreplication.NewExecutor(stats, originCluster, clusterProvider))
+	if err != nil {
+		log.Fatalf("Error creating blob replication manager: %s", err)
+	}
+	if len(replicationRemotes) > 0 {
+		blobreplication.NewReconciler(
+			config.Replication.Reconciler,
+			stats,
+			clock.New(),
+			addr,
+			cas,
+			hashRing,
+			replicationRemotes,
+			clusterProvider,
+			replicationManager).Start()
+	}
+
+	notifier, err := notification.New(config.Notification, stats, localDB)
+	if err != nil {
+		log.Fatalf("Error creating notifier: %s", err)
+	}
+
 	server, err := blobserver.New(
 		config.BlobServer,
 		stats,
@@ -266,17 +316,20 @@ func Run(flags *Flags, opts ...Option) {
 		hashRing,
 		cas,
 		blobclient.NewProvider(blobclient.WithTLS(tls)),
-		blobclient.NewClusterProvider(blobclient.WithTLS(tls)),
+		clusterProvider,
 		pctx,
 		backendManager,
 		blobRefresher,
 		metaInfoGenerator,
-		writeBackManager)
+		writeBackManager,
+		replicationManager,
+		replicationRemotes,
+		notifier)
 	if err != nil {
 		log.Fatalf("Error initializing blob server: %s", err)
 	}
 
-	h := addTorrentDebugEndpoints(server.Handler(), sched)
+	h := addDebugEndpoints(server.Handler(), sched, writeBackStore, config.BlobServer.DebugToken)
 
 	go func() { log.Fatal(server.ListenAndServe(h)) }()
 
@@ -290,9 +343,14 @@ func Run(flags *Flags, opts ...Option) {
 		nginx.WithTLS(config.TLS)))
 }
 
-// addTorrentDebugEndpoints mounts experimental debugging endpoints which are
-// compatible with the agent server.
-func addTorrentDebugEndpoints(h http.Handler, sched scheduler.ReloadableScheduler) http.Handler {
+// addDebugEndpoints mounts experimental debugging endpoints, of which the
+// torrent endpoints are compatible with the agent server.
+func addDebugEndpoints(
+	h http.Handler,
+	sched scheduler.ReloadableScheduler,
+	writeBackStore *writeback.Store,
+	debugToken string) http.Handler {
+
 	r := chi.NewRouter()
 
 	r.Patch("/x/config/scheduler", handler.Wrap(func(w http.ResponseWriter, r *http.Request) error {
@@ -304,6 +362,11 @@ func addTorrentDebugEndpoints(h http.Handler, sched scheduler.ReloadableSchedule
 		return nil
 	}))
 
+	if debugToken != "" {
+		r.Mount("/x/torrents", middleware.RequireToken(debugToken)(scheduler.DebugHandler(sched)))
+		r.Mount("/x/writeback", middleware.RequireToken(debugToken)(writeback.AdminHandler(writeBackStore)))
+	}
+
 	r.Mount("/", h)
 
 	return r
@@ -22,6 +22,8 @@ import (
 	"github.com/uber/kraken/lib/hostlist"
 	"github.com/uber/kraken/lib/metainfogen"
 	"github.com/uber/kraken/lib/persistedretry"
+	"github.com/uber/kraken/lib/persistedretry/blobreplication"
+	"github.com/uber/kraken/lib/persistedretry/notification"
 	"github.com/uber/kraken/lib/store"
 	"github.com/uber/kraken/lib/torrent/networkevent"
 	"github.com/uber/kraken/lib/torrent/scheduler"
@@ -29,6 +31,7 @@ import (
 	"github.com/uber/kraken/metrics"
 	"github.com/uber/kraken/nginx"
 	"github.com/uber/kraken/origin/blobserver"
+	"github.com/uber/kraken/tracing"
 	"github.com/uber/kraken/utils/httputil"
 
 	"go.uber.org/zap"
@@ -48,12 +51,15 @@ type Config struct {
 	NetworkEvent  networkevent.Config      `yaml:"network_event"`
 	PeerIDFactory core.PeerIDFactory       `yaml:"peer_id_factory"`
 	Metrics       metrics.Config           `yaml:"metrics"`
+	Tracing       tracing.Config           `yaml:"tracing"`
 	MetaInfoGen   metainfogen.Config       `yaml:"metainfogen"`
 	Backends      []backend.Config         `yaml:"backends"`
 	Auth          backend.AuthConfig       `yaml:"auth"`
 	BlobRefresh   blobrefresh.Config       `yaml:"blobrefresh"`
 	LocalDB       localdb.Config           `yaml:"localdb"`
 	WriteBack     persistedretry.Config    `yaml:"writeback"`
+	Replication   blobreplication.Config   `yaml:"blob_replication"`
+	Notification  notification.Config      `yaml:"notification"`
 	Nginx         nginx.Config             `yaml:"nginx"`
 	TLS           httputil.TLSConfig       `yaml:"tls"`
 }
@@ -0,0 +1,47 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentpreheat
+
+import "time"
+
+// Config defines Manager configuration.
+type Config struct {
+	// HostConcurrency is the number of agents preheated in parallel per job.
+	HostConcurrency int `yaml:"host_concurrency"`
+
+	// MaxHosts limits the number of agents a single job may target.
+	MaxHosts int `yaml:"max_hosts"`
+
+	// DownloadTimeout bounds how long an agent may take to download a blob.
+	DownloadTimeout time.Duration `yaml:"download_timeout"`
+
+	// JobTTL is how long the status of finished jobs is kept.
+	JobTTL time.Duration `yaml:"job_ttl"`
+}
+
+func (c Config) applyDefaults() Config {
+	if c.HostConcurrency == 0 {
+		c.HostConcurrency = 10
+	}
+	if c.MaxHosts == 0 {
+		c.MaxHosts = 1000
+	}
+	if c.DownloadTimeout == 0 {
+		c.DownloadTimeout = 15 * time.Minute
+	}
+	if c.JobTTL == 0 {
+		c.JobTTL = time.Hour
+	}
+	return c
+}
@@ -0,0 +1,356 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentpreheat
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber/kraken/agent/agentclient"
+	"github.com/uber/kraken/build-index/tagclient"
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/hostlist"
+	"github.com/uber/kraken/origin/blobclient"
+	"github.com/uber/kraken/utils/dockerutil"
+	"github.com/uber/kraken/utils/log"
+	"github.com/uber/kraken/utils/randutil"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/docker/distribution"
+	"github.com/uber-go/tally"
+)
+
+// ErrJobNotFound is returned when a job does not exist or has expired.
+var ErrJobNotFound = errors.New("job not found")
+
+// Job and host states.
+const (
+	StatePending   = "pending"
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+)
+
+// Request defines a preheat request, which selects the agents an image is
+// downloaded to.
+type Request struct {
+	// Tag is the image to preheat, in repo:tag format.
+	Tag string `json:"tag"`
+
+	// Hosts lists agent server addresses in 'host:port' format.
+	Hosts []string `json:"hosts"`
+
+	// DNS is a record resolving to agent server addresses, used instead of
+	// Hosts.
+	DNS string `json:"dns"`
+
+	// Count limits the job to a random subset of the selected agents, if
+	// greater than zero.
+	Count int `json:"count"`
+}
+
+// Validate returns an error if r is not a valid request.
+func (r Request) Validate() error {
+	parts := strings.Split(r.Tag, ":")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("invalid tag %q, expected repo:tag", r.Tag)
+	}
+	if (len(r.Hosts) == 0) == (r.DNS == "") {
+		return errors.New("exactly one of hosts or dns must be set")
+	}
+	if r.Count < 0 {
+		return errors.New("count must not be negative")
+	}
+	return nil
+}
+
+// HostStatus is the progress of a job on a single agent.
+type HostStatus struct {
+	Host      string `json:"host"`
+	State     string `json:"state"`
+	BlobsDone int    `json:"blobs_done"`
+	Error     string `json:"error,omitempty"`
+}
+
+// JobStatus is the progress of a job.
+type JobStatus struct {
+	ID     string       `json:"id"`
+	Tag    string       `json:"tag"`
+	Digest core.Digest  `json:"digest"`
+	Blobs  int          `json:"blobs"`
+	State  string       `json:"state"`
+	Hosts  []HostStatus `json:"hosts"`
+	doneAt time.Time
+}
+
+// Manager runs preheat jobs, which download the blobs of an image to a set of
+// agents ahead of deploys.
+type Manager struct {
+	config       Config
+	stats        tally.Scope
+	clk          clock.Clock
+	tagClient    tagclient.Client
+	originClient blobclient.ClusterClient
+	agents       agentclient.Provider
+
+	mu   sync.Mutex
+	jobs map[string]*JobStatus
+}
+
+// Option allows setting optional Manager parameters.
+type Option func(*Manager)
+
+// WithAgentProvider configures a Manager to create agent clients with p.
+// Intended for tests.
+func WithAgentProvider(p agentclient.Provider) Option {
+	return func(m *Manager) { m.agents = p }
+}
+
+// New creates a new Manager. Agents are reached with agentTLS, which may be
+// nil for plain http.
+func New(
+	config Config,
+	stats tally.Scope,
+	clk clock.Clock,
+	tagClient tagclient.Client,
+	originClient blobclient.ClusterClient,
+	agentTLS *tls.Config,
+	opts ...Option) *Manager {
+
+	config = config.applyDefaults()
+
+	stats = stats.Tagged(map[string]string{
+		"module": "agentpreheat",
+	})
+
+	m := &Manager{
+		config:       config,
+		stats:        stats,
+		clk:          clk,
+		tagClient:    tagClient,
+		originClient: originClient,
+		agents: agentclient.NewProvider(
+			agentclient.WithTLS(agentTLS),
+			agentclient.WithTimeout(config.DownloadTimeout)),
+		jobs: make(map[string]*JobStatus),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Start resolves the image and agents of req and starts downloading the
+// image to the agents in the background. Returns the initial job status.
+func (m *Manager) Start(req Request) (JobStatus, error) {
+	if err := req.Validate(); err != nil {
+		return JobStatus{}, err
+	}
+	hosts, err := m.selectHosts(req)
+	if err != nil {
+		return JobStatus{}, err
+	}
+	repo := strings.Split(req.Tag, ":")[0]
+	d, err := m.tagClient.Get(req.Tag)
+	if err != nil {
+		return JobStatus{}, err
+	}
+	blobs, err := m.resolveBlobs(repo, d)
+	if err != nil {
+		return JobStatus{}, fmt.Errorf("resolve blobs: %s", err)
+	}
+
+	job := &JobStatus{
+		ID:     randutil.Hex(16),
+		Tag:    req.Tag,
+		Digest: d,
+		Blobs:  len(blobs),
+		State:  StateRunning,
+	}
+	for _, h := range hosts {
+		job.Hosts = append(job.Hosts, HostStatus{Host: h, State: StatePending})
+	}
+
+	m.mu.Lock()
+	m.expireJobs()
+	m.jobs[job.ID] = job
+	status := m.copyStatus(job)
+	m.mu.Unlock()
+
+	m.stats.Counter("jobs").Inc(1)
+	log.With("job", job.ID, "tag", req.Tag, "hosts", len(hosts)).Info("Starting preheat job")
+
+	go m.run(job, repo, blobs)
+
+	return status, nil
+}
+
+// Status returns the status of job id.
+func (m *Manager) Status(id string) (JobStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireJobs()
+	job, ok := m.jobs[id]
+	if !ok {
+		return JobStatus{}, ErrJobNotFound
+	}
+	return m.copyStatus(job), nil
+}
+
+func (m *Manager) selectHosts(req Request) ([]string, error) {
+	l, err := hostlist.New(hostlist.Config{Static: req.Hosts, DNS: req.DNS})
+	if err != nil {
+		return nil, fmt.Errorf("hostlist: %s", err)
+	}
+	hosts := l.Resolve().ToSlice()
+	if len(hosts) == 0 {
+		return nil, errors.New("no hosts selected")
+	}
+	if req.Count > 0 && req.Count < len(hosts) {
+		rand.Shuffle(len(hosts), func(i, j int) { hosts[i], hosts[j] = hosts[j], hosts[i] })
+		hosts = hosts[:req.Count]
+	}
+	if len(hosts) > m.config.MaxHosts {
+		return nil, fmt.Errorf("%d hosts selected, at most %d allowed", len(hosts), m.config.MaxHosts)
+	}
+	return hosts, nil
+}
+
+// resolveBlobs returns the manifest d followed by every blob it references.
+func (m *Manager) resolveBlobs(repo string, d core.Digest) ([]core.Digest, error) {
+	manifest, err := m.fetchManifest(repo, d)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := dockerutil.GetAllManifestReferences(manifest, func(d core.Digest) (distribution.Manifest, error) {
+		return m.fetchManifest(repo, d)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]core.Digest{d}, refs...), nil
+}
+
+func (m *Manager) fetchManifest(repo string, d core.Digest) (distribution.Manifest, error) {
+	var buf bytes.Buffer
+	if err := m.originClient.DownloadBlob(repo, d, &buf); err != nil {
+		return nil, fmt.Errorf("download manifest %s: %s", d, err)
+	}
+	manifest, _, err := dockerutil.ParseManifest(&buf)
+	if err != nil {
+		return nil, fmt.Errorf("parse manifest %s: %s", d, err)
+	}
+	return manifest, nil
+}
+
+func (m *Manager) run(job *JobStatus, repo string, blobs []core.Digest) {
+	sem := make(chan struct{}, m.config.HostConcurrency)
+	var wg sync.WaitGroup
+	for i := range job.Hosts {
+		i := i
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			m.preheatHost(job, i, repo, blobs)
+		}()
+	}
+	wg.Wait()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job.State = StateSucceeded
+	for _, h := range job.Hosts {
+		if h.State != StateSucceeded {
+			job.State = StateFailed
+		}
+	}
+	job.doneAt = m.clk.Now()
+	m.stats.Counter("jobs_" + job.State).Inc(1)
+	log.With("job", job.ID, "tag", job.Tag).Infof("Preheat job %s", job.State)
+}
+
+// preheatHost downloads blobs to the i-th host of job, one at a time.
+func (m *Manager) preheatHost(job *JobStatus, i int, repo string, blobs []core.Digest) {
+	m.setHostState(job, i, StateRunning, nil)
+
+	client := m.agents.Provide(job.Hosts[i].Host)
+	for _, d := range blobs {
+		if err := download(client, repo, d); err != nil {
+			m.stats.Counter("host_failures").Inc(1)
+			m.setHostState(job, i, StateFailed, fmt.Errorf("download %s: %s", d, err))
+			return
+		}
+		m.mu.Lock()
+		job.Hosts[i].BlobsDone++
+		m.mu.Unlock()
+	}
+	m.setHostState(job, i, StateSucceeded, nil)
+}
+
+// download triggers the agent to download d. The agent streams the blob back
+// once it is in its cache, which is discarded.
+func download(client agentclient.Client, repo string, d core.Digest) error {
+	r, err := client.Download(repo, d)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		return fmt.Errorf("read: %s", err)
+	}
+	return nil
+}
+
+func (m *Manager) setHostState(job *JobStatus, i int, state string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job.Hosts[i].State = state
+	if err != nil {
+		job.Hosts[i].Error = err.Error()
+	}
+}
+
+// expireJobs removes jobs which finished longer than the job ttl ago. Callers
+// must hold m.mu.
+func (m *Manager) expireJobs() {
+	now := m.clk.Now()
+	for id, job := range m.jobs {
+		if !job.doneAt.IsZero() && now.Sub(job.doneAt) > m.config.JobTTL {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// copyStatus returns a copy of job which is safe to read without m.mu.
+// Callers must hold m.mu.
+func (m *Manager) copyStatus(job *JobStatus) JobStatus {
+	status := *job
+	status.Hosts = append([]HostStatus(nil), job.Hosts...)
+	return status
+}
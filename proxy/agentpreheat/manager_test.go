@@ -0,0 +1,238 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package agentpreheat
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/build-index/tagclient"
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/mocks/agent/agentclient"
+	"github.com/uber/kraken/mocks/build-index/tagclient"
+	"github.com/uber/kraken/mocks/origin/blobclient"
+	"github.com/uber/kraken/utils/dockerutil"
+	"github.com/uber/kraken/utils/mockutil"
+	"github.com/uber/kraken/utils/testutil"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+type managerMocks struct {
+	ctrl         *gomock.Controller
+	tagClient    *mocktagclient.MockClient
+	originClient *mockblobclient.MockClusterClient
+	agents       *mockagentclient.MockProvider
+	clk          *clock.Mock
+}
+
+func newManagerMocks(t *testing.T) (*managerMocks, func()) {
+	ctrl := gomock.NewController(t)
+	return &managerMocks{
+		ctrl:         ctrl,
+		tagClient:    mocktagclient.NewMockClient(ctrl),
+		originClient: mockblobclient.NewMockClusterClient(ctrl),
+		agents:       mockagentclient.NewMockProvider(ctrl),
+		clk:          clock.NewMock(),
+	}, ctrl.Finish
+}
+
+func (m *managerMocks) new(config Config) *Manager {
+	return New(
+		config, tally.NoopScope, m.clk, m.tagClient, m.originClient, nil,
+		WithAgentProvider(m.agents))
+}
+
+// expectImage sets up tag to resolve to a manifest with three layers.
+// Returns the blobs agents are expected to download.
+func (m *managerMocks) expectImage(repo, tag string) []core.Digest {
+	layers := core.DigestListFixture(3)
+	manifest, b := dockerutil.ManifestFixture(layers[0], layers[1], layers[2])
+	m.tagClient.EXPECT().Get(repo+":"+tag).Return(manifest, nil)
+	m.originClient.EXPECT().DownloadBlob(repo, manifest, mockutil.MatchWriter(b)).Return(nil)
+	return append([]core.Digest{manifest}, layers...)
+}
+
+func (m *managerMocks) expectAgent(addr, repo string, blobs []core.Digest, err error) {
+	agent := mockagentclient.NewMockClient(m.ctrl)
+	m.agents.EXPECT().Provide(addr).Return(agent)
+	for _, d := range blobs {
+		agent.EXPECT().Download(repo, d).Return(ioutil.NopCloser(bytes.NewReader(nil)), err)
+		if err != nil {
+			return
+		}
+	}
+}
+
+func waitForJob(t *testing.T, m *Manager, id string) JobStatus {
+	var status JobStatus
+	require.NoError(t, testutil.PollUntilTrue(5*time.Second, func() bool {
+		var err error
+		status, err = m.Status(id)
+		require.NoError(t, err)
+		return status.State != StateRunning
+	}))
+	return status
+}
+
+func TestPreheat(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newManagerMocks(t)
+	defer cleanup()
+
+	m := mocks.new(Config{})
+
+	blobs := mocks.expectImage("repo", "tag")
+	mocks.expectAgent("agent-1:80", "repo", blobs, nil)
+	mocks.expectAgent("agent-2:80", "repo", blobs, nil)
+
+	status, err := m.Start(Request{Tag: "repo:tag", Hosts: []string{"agent-1:80", "agent-2:80"}})
+	require.NoError(err)
+	require.Equal(StateRunning, status.State)
+	require.Equal(blobs[0], status.Digest)
+	require.Equal(4, status.Blobs)
+
+	status = waitForJob(t, m, status.ID)
+	require.Equal(StateSucceeded, status.State)
+	require.ElementsMatch([]HostStatus{
+		{Host: "agent-1:80", State: StateSucceeded, BlobsDone: 4},
+		{Host: "agent-2:80", State: StateSucceeded, BlobsDone: 4},
+	}, status.Hosts)
+}
+
+func TestPreheatReportsHostFailures(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newManagerMocks(t)
+	defer cleanup()
+
+	m := mocks.new(Config{})
+
+	blobs := mocks.expectImage("repo", "tag")
+	mocks.expectAgent("agent-1:80", "repo", blobs, nil)
+	mocks.expectAgent("agent-2:80", "repo", blobs, errors.New("some error"))
+
+	status, err := m.Start(Request{Tag: "repo:tag", Hosts: []string{"agent-1:80", "agent-2:80"}})
+	require.NoError(err)
+
+	status = waitForJob(t, m, status.ID)
+	require.Equal(StateFailed, status.State)
+	for _, h := range status.Hosts {
+		switch h.Host {
+		case "agent-1:80":
+			require.Equal(StateSucceeded, h.State)
+		case "agent-2:80":
+			require.Equal(StateFailed, h.State)
+			require.Equal(0, h.BlobsDone)
+			require.Contains(h.Error, "some error")
+		}
+	}
+}
+
+func TestPreheatCountSelectsSubset(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newManagerMocks(t)
+	defer cleanup()
+
+	m := mocks.new(Config{})
+
+	mocks.expectImage("repo", "tag")
+	agent := mockagentclient.NewMockClient(mocks.ctrl)
+	mocks.agents.EXPECT().Provide(gomock.Any()).Return(agent).Times(2)
+	agent.EXPECT().Download("repo", gomock.Any()).Return(
+		ioutil.NopCloser(bytes.NewReader(nil)), nil).Times(8)
+
+	status, err := m.Start(Request{
+		Tag:   "repo:tag",
+		Hosts: []string{"agent-1:80", "agent-2:80", "agent-3:80", "agent-4:80"},
+		Count: 2,
+	})
+	require.NoError(err)
+	require.Len(status.Hosts, 2)
+
+	require.Equal(StateSucceeded, waitForJob(t, m, status.ID).State)
+}
+
+func TestPreheatErrors(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newManagerMocks(t)
+	defer cleanup()
+
+	m := mocks.new(Config{MaxHosts: 1})
+
+	_, err := m.Start(Request{Tag: "repo:tag", Hosts: []string{"agent-1:80", "agent-2:80"}})
+	require.Error(err)
+
+	mocks.tagClient.EXPECT().Get("repo:tag").Return(core.Digest{}, tagclient.ErrTagNotFound)
+	_, err = m.Start(Request{Tag: "repo:tag", Hosts: []string{"agent-1:80"}})
+	require.Equal(tagclient.ErrTagNotFound, err)
+}
+
+func TestRequestValidate(t *testing.T) {
+	tests := []struct {
+		desc    string
+		req     Request
+		wantErr bool
+	}{
+		{"hosts", Request{Tag: "repo:tag", Hosts: []string{"a:80"}}, false},
+		{"dns", Request{Tag: "repo:tag", DNS: "agents:80"}, false},
+		{"no selector", Request{Tag: "repo:tag"}, true},
+		{"both selectors", Request{Tag: "repo:tag", Hosts: []string{"a:80"}, DNS: "agents:80"}, true},
+		{"invalid tag", Request{Tag: "repo", Hosts: []string{"a:80"}}, true},
+		{"negative count", Request{Tag: "repo:tag", Hosts: []string{"a:80"}, Count: -1}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := test.req.Validate()
+			if test.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestFinishedJobsExpire(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newManagerMocks(t)
+	defer cleanup()
+
+	m := mocks.new(Config{JobTTL: time.Hour})
+
+	blobs := mocks.expectImage("repo", "tag")
+	mocks.expectAgent("agent-1:80", "repo", blobs, nil)
+
+	status, err := m.Start(Request{Tag: "repo:tag", Hosts: []string{"agent-1:80"}})
+	require.NoError(err)
+	waitForJob(t, m, status.ID)
+
+	mocks.clk.Add(time.Hour)
+	_, err = m.Status(status.ID)
+	require.NoError(err)
+
+	mocks.clk.Add(time.Second)
+	_, err = m.Status(status.ID)
+	require.Equal(ErrJobNotFound, err)
+}
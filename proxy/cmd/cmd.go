@@ -22,17 +22,25 @@ import (
 	"github.com/uber/kraken/build-index/tagclient"
 	"github.com/uber/kraken/lib/dockerregistry/transfer"
 	"github.com/uber/kraken/lib/healthcheck"
+	"github.com/uber/kraken/lib/persistedretry/notification"
+	"github.com/uber/kraken/lib/registryauth"
+	"github.com/uber/kraken/lib/signature"
 	"github.com/uber/kraken/lib/store"
 	"github.com/uber/kraken/lib/upstream"
+	"github.com/uber/kraken/localdb"
 	"github.com/uber/kraken/metrics"
 	"github.com/uber/kraken/nginx"
 	"github.com/uber/kraken/origin/blobclient"
+	"github.com/uber/kraken/proxy/agentpreheat"
 	"github.com/uber/kraken/proxy/proxyserver"
 	"github.com/uber/kraken/proxy/registryoverride"
+	"github.com/uber/kraken/tracing"
 	"github.com/uber/kraken/utils/configutil"
 	"github.com/uber/kraken/utils/flagutil"
 	"github.com/uber/kraken/utils/log"
 
+	"github.com/andres-erbsen/clock"
+	"github.com/jmoiron/sqlx"
 	"github.com/uber-go/tally"
 	"go.uber.org/zap"
 )
@@ -132,6 +140,12 @@ func Run(flags *Flags, opts ...Option) {
 
 	go metrics.EmitVersion(stats)
 
+	tracingCloser, err := tracing.Init(config.Tracing, "kraken-proxy")
+	if err != nil {
+		log.Fatalf("Failed to init tracing: %s", err)
+	}
+	defer tracingCloser.Close()
+
 	cas, err := store.NewCAStore(config.CAStore, stats)
 	if err != nil {
 		log.Fatalf("Failed to create store: %s", err)
@@ -142,7 +156,9 @@ func Run(flags *Flags, opts ...Option) {
 		log.Fatalf("Error building client tls config: %s", err)
 	}
 
-	origins, err := config.Origin.Build(upstream.WithHealthCheck(healthcheck.Default(tls)))
+	origins, err := config.Origin.Build(
+		upstream.WithHealthCheck(healthcheck.Default(tls)),
+		upstream.WithStats(stats.SubScope("origin")))
 	if err != nil {
 		log.Fatalf("Error building origin host list: %s", err)
 	}
@@ -150,18 +166,42 @@ func Run(flags *Flags, opts ...Option) {
 	r := blobclient.NewClientResolver(blobclient.NewProvider(blobclient.WithTLS(tls)), origins)
 	originCluster := blobclient.NewClusterClient(r)
 
-	buildIndexes, err := config.BuildIndex.Build(upstream.WithHealthCheck(healthcheck.Default(tls)))
+	buildIndexes, err := config.BuildIndex.Build(
+		upstream.WithHealthCheck(healthcheck.Default(tls)),
+		upstream.WithStats(stats.SubScope("build_index")))
 	if err != nil {
 		log.Fatalf("Error building build-index host list: %s", err)
 	}
 
 	tagClient := tagclient.NewClusterClient(buildIndexes, tls)
 
-	transferer := transfer.NewReadWriteTransferer(stats, tagClient, originCluster, cas)
+	// The local db only persists undelivered notifications, so it is not
+	// required unless notification endpoints are configured.
+	var localDB *sqlx.DB
+	if len(config.Notification.Endpoints) > 0 {
+		localDB, err = localdb.New(config.LocalDB)
+		if err != nil {
+			log.Fatalf("Error creating local db: %s", err)
+		}
+	}
+	notifier, err := notification.New(config.Notification, stats, localDB)
+	if err != nil {
+		log.Fatalf("Error creating notifier: %s", err)
+	}
+
+	verifier, err := signature.New(config.Signature, stats, tagClient, originCluster)
+	if err != nil {
+		log.Fatalf("Error creating signature verifier: %s", err)
+	}
+
+	transferer := transfer.NewReadWriteTransferer(
+		stats, tagClient, originCluster, cas, notifier, verifier)
 
 	// Open preheat function only if server-port was defined.
 	if flags.ServerPort != 0 {
-		server := proxyserver.New(stats, originCluster)
+		agentPreheat := agentpreheat.New(
+			config.AgentPreheat, stats, clock.New(), tagClient, originCluster, tls)
+		server := proxyserver.New(stats, originCluster, agentPreheat)
 		addr := fmt.Sprintf(":%d", flags.ServerPort)
 		log.Infof("Starting http server on %s", addr)
 		go func() {
@@ -169,6 +209,12 @@ func Run(flags *Flags, opts ...Option) {
 		}()
 	}
 
+	authorizer, err := registryauth.New(config.RegistryAuth, stats, clock.New())
+	if err != nil {
+		log.Fatalf("Error creating registry authorizer: %s", err)
+	}
+	authorizer.ConfigureRegistry(&config.Registry.Docker)
+
 	registry, err := config.Registry.Build(config.Registry.ReadWriteParameters(transferer, cas, stats))
 	if err != nil {
 		log.Fatalf("Error creating registry: %s", err)
@@ -178,7 +224,8 @@ func Run(flags *Flags, opts ...Option) {
 		log.Fatal(registry.ListenAndServe())
 	}()
 
-	ros := registryoverride.NewServer(config.RegistryOverride, tagClient)
+	ros := registryoverride.NewServer(
+		config.RegistryOverride, tagClient, transferer, authorizer, config.Registry.ResumableUploads(cas))
 	go func() {
 		log.Fatal(ros.ListenAndServe())
 	}()
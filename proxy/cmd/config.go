@@ -15,11 +15,17 @@ package cmd
 
 import (
 	"github.com/uber/kraken/lib/dockerregistry"
+	"github.com/uber/kraken/lib/persistedretry/notification"
+	"github.com/uber/kraken/lib/registryauth"
+	"github.com/uber/kraken/lib/signature"
 	"github.com/uber/kraken/lib/store"
 	"github.com/uber/kraken/lib/upstream"
+	"github.com/uber/kraken/localdb"
 	"github.com/uber/kraken/metrics"
 	"github.com/uber/kraken/nginx"
+	"github.com/uber/kraken/proxy/agentpreheat"
 	"github.com/uber/kraken/proxy/registryoverride"
+	"github.com/uber/kraken/tracing"
 	"github.com/uber/kraken/utils/httputil"
 
 	"go.uber.org/zap"
@@ -29,11 +35,17 @@ import (
 type Config struct {
 	CAStore          store.CAStoreConfig     `yaml:"castore"`
 	Registry         dockerregistry.Config   `yaml:"registry"`
+	RegistryAuth     registryauth.Config     `yaml:"registry_auth"`
 	BuildIndex       upstream.ActiveConfig   `yaml:"build_index"`
 	Origin           upstream.ActiveConfig   `yaml:"origin"`
 	ZapLogging       zap.Config              `yaml:"zap"`
 	Metrics          metrics.Config          `yaml:"metrics"`
+	Tracing          tracing.Config          `yaml:"tracing"`
 	RegistryOverride registryoverride.Config `yaml:"registryoverride"`
+	AgentPreheat     agentpreheat.Config     `yaml:"agent_preheat"`
+	Notification     notification.Config     `yaml:"notification"`
+	LocalDB          localdb.Config          `yaml:"localdb"`
+	Signature        signature.Config        `yaml:"signature"`
 	Nginx            nginx.Config            `yaml:"nginx"`
 	TLS              httputil.TLSConfig      `yaml:"tls"`
 }
@@ -31,7 +31,8 @@ import (
 	"github.com/uber/kraken/utils/log"
 )
 
-var _manifestRegexp = regexp.MustCompile(`^application/vnd.docker.distribution.manifest.v\d\+(json|prettyjws)`)
+var _manifestRegexp = regexp.MustCompile(
+	`^application/vnd\.(docker\.distribution\.manifest\.(list\.)?v\d|oci\.image\.(manifest|index)\.v1)\+(json|prettyjws)`)
 
 // PreheatHandler defines the handler of preheat.
 type PreheatHandler struct {
@@ -69,15 +70,18 @@ func (ph *PreheatHandler) process(repo, digest string) error {
 	if err != nil {
 		return err
 	}
-	for _, desc := range manifest.References() {
-		d, err := core.ParseSHA256Digest(string(desc.Digest))
-		if err != nil {
-			log.With("repo", repo, "digest", string(desc.Digest)).Errorf("parse digest: %s", err)
-			continue
-		}
+	// Manifest lists are resolved into the layers of every platform.
+	refs, err := dockerutil.GetAllManifestReferences(manifest, func(d core.Digest) (distribution.Manifest, error) {
+		return ph.fetchManifest(repo, d.String())
+	})
+	if err != nil {
+		return fmt.Errorf("get manifest references: %s", err)
+	}
+	for _, d := range refs {
+		d := d
 		go func() {
 			log.With("repo", repo).Debugf("trigger origin cache: %+v", d)
-			_, err = ph.clusterClient.GetMetaInfo(repo, d)
+			_, err := ph.clusterClient.GetMetaInfo(repo, d)
 			if err != nil && !httputil.IsAccepted(err) {
 				log.With("repo", repo, "digest", digest).Errorf("notify origin cache: %s", err)
 			}
@@ -14,37 +14,45 @@
 package proxyserver
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	_ "net/http/pprof" // Registers /debug/pprof endpoints in http.DefaultServeMux.
 
 	"github.com/go-chi/chi"
 	"github.com/uber-go/tally"
+	"github.com/uber/kraken/build-index/tagclient"
 	"github.com/uber/kraken/lib/middleware"
 	"github.com/uber/kraken/origin/blobclient"
+	"github.com/uber/kraken/proxy/agentpreheat"
 	"github.com/uber/kraken/utils/handler"
+	"github.com/uber/kraken/utils/httputil"
 )
 
 // Server defines the proxy HTTP server.
 type Server struct {
 	stats          tally.Scope
 	preheatHandler *PreheatHandler
+	agentPreheat   *agentpreheat.Manager
 }
 
 // New creates a new Server.
 func New(
 	stats tally.Scope,
-	client blobclient.ClusterClient) *Server {
+	client blobclient.ClusterClient,
+	agentPreheat *agentpreheat.Manager) *Server {
 
 	return &Server{
 		stats.Tagged(map[string]string{"module": "proxyserver"}),
-		NewPreheatHandler(client)}
+		NewPreheatHandler(client),
+		agentPreheat}
 }
 
 // Handler returns the HTTP handler.
 func (s *Server) Handler() http.Handler {
 	r := chi.NewRouter()
 
+	r.Use(middleware.Tracing())
 	r.Use(middleware.StatusCounter(s.stats))
 	r.Use(middleware.LatencyTimer(s.stats))
 
@@ -52,6 +60,9 @@ func (s *Server) Handler() http.Handler {
 
 	r.Post("/registry/notifications", handler.Wrap(s.preheatHandler.Handle))
 
+	r.Post("/preheat", handler.Wrap(s.startAgentPreheatHandler))
+	r.Get("/preheat/{id}", handler.Wrap(s.getAgentPreheatHandler))
+
 	// Serves /debug/pprof endpoints.
 	r.Mount("/", http.DefaultServeMux)
 
@@ -62,3 +73,47 @@ func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) error {
 	fmt.Fprintln(w, "OK")
 	return nil
 }
+
+// startAgentPreheatHandler starts downloading an image to a selection of
+// agents. Responds with the status of the started job, which can be polled
+// via getAgentPreheatHandler.
+func (s *Server) startAgentPreheatHandler(w http.ResponseWriter, r *http.Request) error {
+	var req agentpreheat.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return handler.Errorf("decode body: %s", err).Status(http.StatusBadRequest)
+	}
+	if err := req.Validate(); err != nil {
+		return handler.Errorf("invalid request: %s", err).Status(http.StatusBadRequest)
+	}
+	status, err := s.agentPreheat.Start(req)
+	if err != nil {
+		if err == tagclient.ErrTagNotFound {
+			return handler.ErrorStatus(http.StatusNotFound)
+		}
+		return handler.Errorf("start preheat: %s", err)
+	}
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		return handler.Errorf("json encode: %s", err)
+	}
+	return nil
+}
+
+// getAgentPreheatHandler reports the per-host status of a preheat job.
+func (s *Server) getAgentPreheatHandler(w http.ResponseWriter, r *http.Request) error {
+	id, err := httputil.ParseParam(r, "id")
+	if err != nil {
+		return err
+	}
+	status, err := s.agentPreheat.Status(id)
+	if err != nil {
+		if err == agentpreheat.ErrJobNotFound {
+			return handler.ErrorStatus(http.StatusNotFound)
+		}
+		return handler.Errorf("get preheat status: %s", err)
+	}
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		return handler.Errorf("json encode: %s", err)
+	}
+	return nil
+}
@@ -17,6 +17,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sync"
 	"testing"
 
 	"github.com/uber/kraken/utils/dockerutil"
@@ -27,8 +28,12 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/uber/kraken/build-index/tagclient"
 	"github.com/uber/kraken/core"
+	mockagentclient "github.com/uber/kraken/mocks/agent/agentclient"
+	"github.com/uber/kraken/proxy/agentpreheat"
 	"github.com/uber/kraken/utils/mockutil"
+	"github.com/uber/kraken/utils/testutil"
 )
 
 func TestHealth(t *testing.T) {
@@ -142,3 +147,125 @@ func TestPreheat(t *testing.T) {
 		httputil.SendBody(bytes.NewReader(b)))
 	require.NoError(err)
 }
+
+func TestPreheatImageIndex(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr := mocks.startServer()
+
+	repo := "kraken-test/preheat"
+	layers := core.DigestListFixture(3)
+	manifest, manifestBytes := dockerutil.ManifestFixture(layers[0], layers[1], layers[2])
+	index, indexBytes := dockerutil.ImageIndexFixture(manifest)
+
+	notification := &Notification{
+		Events: []Event{
+			{
+				ID:        "1",
+				TimeStamp: time.Now(),
+				Action:    "push",
+				Target: &Target{
+					MediaType:  "application/vnd.oci.image.index.v1+json",
+					Digest:     index.String(),
+					Repository: repo,
+					Tag:        "v1.0.0",
+				},
+			},
+		},
+	}
+
+	b, _ := json.Marshal(notification)
+
+	var wg sync.WaitGroup
+	wg.Add(4)
+	done := func(interface{}, interface{}) { wg.Done() }
+
+	mocks.originClient.EXPECT().DownloadBlob(repo, index, mockutil.MatchWriter(indexBytes)).Return(nil)
+	mocks.originClient.EXPECT().DownloadBlob(repo, manifest, mockutil.MatchWriter(manifestBytes)).Return(nil)
+	for _, d := range append(layers, manifest) {
+		mocks.originClient.EXPECT().GetMetaInfo(repo, d).Do(done).Return(nil, nil)
+	}
+	_, err := httputil.Post(
+		fmt.Sprintf("http://%s/registry/notifications", addr),
+		httputil.SendBody(bytes.NewReader(b)))
+	require.NoError(err)
+
+	wg.Wait()
+}
+
+func TestAgentPreheat(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr := mocks.startServer()
+
+	repo := "some/repo"
+	layers := core.DigestListFixture(3)
+	manifest, b := dockerutil.ManifestFixture(layers[0], layers[1], layers[2])
+	mocks.tagClient.EXPECT().Get(repo+":latest").Return(manifest, nil)
+	mocks.originClient.EXPECT().DownloadBlob(repo, manifest, mockutil.MatchWriter(b)).Return(nil)
+
+	agent := mockagentclient.NewMockClient(mocks.ctrl)
+	mocks.agents.EXPECT().Provide("agent-1:80").Return(agent)
+	for _, d := range append([]core.Digest{manifest}, layers...) {
+		agent.EXPECT().Download(repo, d).Return(ioutil.NopCloser(bytes.NewReader(nil)), nil)
+	}
+
+	body, err := json.Marshal(agentpreheat.Request{Tag: repo + ":latest", Hosts: []string{"agent-1:80"}})
+	require.NoError(err)
+	resp, err := httputil.Post(
+		fmt.Sprintf("http://%s/preheat", addr),
+		httputil.SendBody(bytes.NewReader(body)),
+		httputil.SendAcceptedCodes(http.StatusAccepted))
+	require.NoError(err)
+	defer resp.Body.Close()
+
+	var status agentpreheat.JobStatus
+	require.NoError(json.NewDecoder(resp.Body).Decode(&status))
+
+	require.NoError(testutil.PollUntilTrue(5*time.Second, func() bool {
+		resp, err := httputil.Get(fmt.Sprintf("http://%s/preheat/%s", addr, status.ID))
+		require.NoError(err)
+		defer resp.Body.Close()
+		require.NoError(json.NewDecoder(resp.Body).Decode(&status))
+		return status.State != agentpreheat.StateRunning
+	}))
+	require.Equal(agentpreheat.StateSucceeded, status.State)
+	require.Equal([]agentpreheat.HostStatus{{
+		Host:      "agent-1:80",
+		State:     agentpreheat.StateSucceeded,
+		BlobsDone: 4,
+	}}, status.Hosts)
+}
+
+func TestAgentPreheatErrors(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr := mocks.startServer()
+
+	post := func(req agentpreheat.Request) error {
+		body, err := json.Marshal(req)
+		require.NoError(err)
+		_, err = httputil.Post(
+			fmt.Sprintf("http://%s/preheat", addr),
+			httputil.SendBody(bytes.NewReader(body)),
+			httputil.SendAcceptedCodes(http.StatusAccepted))
+		return err
+	}
+
+	require.True(httputil.IsStatus(post(agentpreheat.Request{Tag: "repo:tag"}), http.StatusBadRequest))
+
+	mocks.tagClient.EXPECT().Get("repo:tag").Return(core.Digest{}, tagclient.ErrTagNotFound)
+	require.True(httputil.IsNotFound(post(agentpreheat.Request{Tag: "repo:tag", Hosts: []string{"a:80"}})))
+
+	_, err := httputil.Get(fmt.Sprintf("http://%s/preheat/some-id", addr))
+	require.True(httputil.IsNotFound(err))
+}
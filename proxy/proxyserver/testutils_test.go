@@ -16,15 +16,22 @@ package proxyserver
 import (
 	"testing"
 
+	"github.com/andres-erbsen/clock"
 	"github.com/golang/mock/gomock"
 	"github.com/uber-go/tally"
 
+	mockagentclient "github.com/uber/kraken/mocks/agent/agentclient"
+	mocktagclient "github.com/uber/kraken/mocks/build-index/tagclient"
 	mockblobclient "github.com/uber/kraken/mocks/origin/blobclient"
+	"github.com/uber/kraken/proxy/agentpreheat"
 	"github.com/uber/kraken/utils/testutil"
 )
 
 type serverMocks struct {
+	ctrl         *gomock.Controller
 	originClient *mockblobclient.MockClusterClient
+	tagClient    *mocktagclient.MockClient
+	agents       *mockagentclient.MockProvider
 	cleanup      *testutil.Cleanup
 }
 
@@ -38,13 +45,19 @@ func newServerMocks(t *testing.T) (*serverMocks, func()) {
 	originClient := mockblobclient.NewMockClusterClient(ctrl)
 
 	return &serverMocks{
+		ctrl:         ctrl,
 		originClient: originClient,
+		tagClient:    mocktagclient.NewMockClient(ctrl),
+		agents:       mockagentclient.NewMockProvider(ctrl),
 		cleanup:      &cleanup,
 	}, cleanup.Run
 }
 
 func (m *serverMocks) startServer() string {
-	s := New(tally.NoopScope, m.originClient)
+	agentPreheat := agentpreheat.New(
+		agentpreheat.Config{}, tally.NoopScope, clock.New(), m.tagClient, m.originClient, nil,
+		agentpreheat.WithAgentProvider(m.agents))
+	s := New(tally.NoopScope, m.originClient, agentPreheat)
 	addr, stop := testutil.StartServer(s.Handler())
 	m.cleanup.Add(stop)
 	return addr
@@ -17,34 +17,63 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 
+	"github.com/docker/distribution/registry/auth"
 	"github.com/go-chi/chi"
 	"github.com/uber/kraken/build-index/tagclient"
+	"github.com/uber/kraken/build-index/tagmodels"
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/dockerregistry/transfer"
+	"github.com/uber/kraken/lib/registryauth"
+	"github.com/uber/kraken/utils/dockerutil"
 	"github.com/uber/kraken/utils/handler"
 	"github.com/uber/kraken/utils/listener"
 	"github.com/uber/kraken/utils/log"
 	"github.com/uber/kraken/utils/stringset"
 )
 
+// Docker registry pagination query params.
+const (
+	_limitQ  = "n"
+	_offsetQ = "last"
+)
+
 // Server overrides Docker registry endpoints.
 type Server struct {
-	config    Config
-	tagClient tagclient.Client
+	config     Config
+	tagClient  tagclient.Client
+	transferer transfer.ImageTransferer
+	authorizer *registryauth.Authorizer
+	registry   http.Handler
 }
 
-// NewServer creates a new Server.
-func NewServer(config Config, tagClient tagclient.Client) *Server {
-	return &Server{config, tagClient}
+// NewServer creates a new Server. Requests for endpoints which are not
+// overridden are passed to registry, e.g. a proxy to the docker registry, or
+// rejected if registry is nil.
+func NewServer(
+	config Config,
+	tagClient tagclient.Client,
+	transferer transfer.ImageTransferer,
+	authorizer *registryauth.Authorizer,
+	registry http.Handler) *Server {
+
+	return &Server{config, tagClient, transferer, authorizer, registry}
 }
 
 // Handler returns a handler for s.
 func (s *Server) Handler() http.Handler {
 	r := chi.NewRouter()
 	r.Get("/v2/_catalog", handler.Wrap(s.catalogHandler))
+	r.Get("/v2/*", handler.Wrap(s.repositoryHandler))
+	if s.registry != nil {
+		r.NotFound(s.registry.ServeHTTP)
+		r.MethodNotAllowed(s.registry.ServeHTTP)
+	}
 	return r
 }
 
@@ -61,35 +90,16 @@ type catalogResponse struct {
 // catalogHandler handles catalog request.
 // https://docs.docker.com/registry/spec/api/#pagination for more reference.
 func (s *Server) catalogHandler(w http.ResponseWriter, r *http.Request) error {
-	limitQ := "n"
-	offsetQ := "last"
+	if err := s.authorize(r, auth.Access{
+		Resource: auth.Resource{Type: "registry", Name: "catalog"},
+		Action:   "*",
+	}); err != nil {
+		return err
+	}
 
-	// Build request for ListWithPagination.
-	var filter tagclient.ListFilter
-	u := r.URL
-	q := u.Query()
-	for k, v := range q {
-		if len(v) != 1 {
-			return handler.Errorf(
-				"invalid query %s:%s", k, v).Status(http.StatusBadRequest)
-		}
-		switch k {
-		case limitQ:
-			limitCount, err := strconv.Atoi(v[0])
-			if err != nil {
-				return handler.Errorf(
-					"invalid limit %s: %s", v, err).Status(http.StatusBadRequest)
-			}
-			if limitCount == 0 {
-				return handler.Errorf(
-					"invalid limit %d", limitCount).Status(http.StatusBadRequest)
-			}
-			filter.Limit = limitCount
-		case offsetQ:
-			filter.Offset = v[0]
-		default:
-			return handler.Errorf("invalid query %s", k).Status(http.StatusBadRequest)
-		}
+	filter, err := parseListFilter(r.URL)
+	if err != nil {
+		return err
 	}
 
 	// List with pagination.
@@ -107,34 +117,197 @@ func (s *Server) catalogHandler(w http.ResponseWriter, r *http.Request) error {
 		repos.Add(parts[0])
 	}
 
-	// Build Link for response.
-	offset, err := listResp.GetOffset()
-	if err != nil && err != io.EOF {
-		return handler.Errorf("invalid offset %s", err)
+	if err := setNextLink(w, r.URL, listResp); err != nil {
+		return err
 	}
-	if offset != "" {
-		nextUrl, err := url.Parse(u.String())
-		if err != nil {
-			return handler.Errorf(
-				"invalid url string: %s", err).Status(http.StatusBadRequest)
+
+	resp := catalogResponse{Repositories: repos.ToSlice()}
+	if err := json.NewEncoder(w).Encode(&resp); err != nil {
+		return handler.Errorf("json encode: %s", err)
+	}
+	return nil
+}
+
+// repositoryHandler dispatches repository scoped endpoints. Repository names
+// may contain slashes, so they cannot be matched by the router.
+func (s *Server) repositoryHandler(w http.ResponseWriter, r *http.Request) error {
+	p := strings.TrimPrefix(r.URL.Path, "/v2/")
+	if strings.HasSuffix(p, "/tags/list") {
+		repo := strings.TrimSuffix(p, "/tags/list")
+		if repo == "" {
+			return handler.ErrorStatus(http.StatusNotFound)
 		}
-		val, err := url.ParseQuery(nextUrl.RawQuery)
-		if err != nil {
-			return handler.Errorf(
-				"invalid url string: %s", err).Status(http.StatusBadRequest)
+		if err := s.authorizePull(r, repo); err != nil {
+			return err
+		}
+		return s.tagsListHandler(w, r, repo)
+	}
+	if i := strings.LastIndex(p, "/referrers/"); i > 0 {
+		if err := s.authorizePull(r, p[:i]); err != nil {
+			return err
+		}
+		return s.referrersHandler(w, r, p[:i], p[i+len("/referrers/"):])
+	}
+	if s.registry != nil {
+		s.registry.ServeHTTP(w, r)
+		return nil
+	}
+	return handler.ErrorStatus(http.StatusNotFound)
+}
+
+// authorize checks r against access, responding with the token auth
+// challenge if it is not authorized.
+func (s *Server) authorize(r *http.Request, access ...auth.Access) error {
+	if _, err := s.authorizer.Authorize(r, access...); err != nil {
+		herr := handler.Errorf("%s", err).Status(http.StatusUnauthorized)
+		if c, ok := err.(*registryauth.Challenge); ok {
+			herr.Header("WWW-Authenticate", c.Header())
 		}
-		val.Set(offsetQ, offset)
-		nextUrl.RawQuery = val.Encode()
+		return herr
+	}
+	return nil
+}
+
+func (s *Server) authorizePull(r *http.Request, repo string) error {
+	return s.authorize(r, auth.Access{
+		Resource: auth.Resource{Type: "repository", Name: repo},
+		Action:   "pull",
+	})
+}
 
-		// Set header (https://docs.docker.com/registry/spec/api/#pagination),
-		// except the host and scheme.
-		// Link: <<url>?n=2&last=b>; rel="next"
-		w.Header().Set("Link", fmt.Sprintf("%s; rel=\"next\"", nextUrl.String()))
+type tagsListResponse struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+// tagsListHandler lists the tags of repo.
+// https://docs.docker.com/registry/spec/api/#listing-image-tags for more
+// reference.
+func (s *Server) tagsListHandler(w http.ResponseWriter, r *http.Request, repo string) error {
+	filter, err := parseListFilter(r.URL)
+	if err != nil {
+		return err
 	}
 
-	resp := catalogResponse{Repositories: repos.ToSlice()}
+	listResp, err := s.tagClient.ListRepositoryWithPagination(repo, filter)
+	if err != nil {
+		return handler.Errorf("list repository: %s", err)
+	}
+
+	if err := setNextLink(w, r.URL, listResp); err != nil {
+		return err
+	}
+
+	resp := tagsListResponse{Name: repo, Tags: listResp.Result}
+	if resp.Tags == nil {
+		resp.Tags = []string{}
+	}
 	if err := json.NewEncoder(w).Encode(&resp); err != nil {
 		return handler.Errorf("json encode: %s", err)
 	}
 	return nil
 }
+
+// referrersHandler implements the OCI referrers API,
+// GET /v2/<name>/referrers/<digest>, which lists the manifests referring to a
+// subject manifest. Referrers indexes are maintained by the registry under
+// the referrers tag schema, which is served here. An empty index is returned
+// if the subject has no referrers.
+func (s *Server) referrersHandler(
+	w http.ResponseWriter, r *http.Request, repo string, digest string) error {
+
+	subject, err := core.ParseSHA256Digest(digest)
+	if err != nil {
+		return handler.Errorf("parse digest: %s", err).Status(http.StatusBadRequest)
+	}
+
+	index := dockerutil.NewReferrersIndex()
+	d, err := s.transferer.GetTag(fmt.Sprintf("%s:%s", repo, dockerutil.ReferrersTag(subject)))
+	if err == nil {
+		blob, err := s.transferer.Download(repo, d)
+		if err != nil {
+			return handler.Errorf("download referrers index: %s", err)
+		}
+		defer blob.Close()
+		b, err := ioutil.ReadAll(blob)
+		if err != nil {
+			return handler.Errorf("read referrers index: %s", err)
+		}
+		if err := json.Unmarshal(b, index); err != nil {
+			return handler.Errorf("unmarshal referrers index: %s", err)
+		}
+	} else if err != transfer.ErrTagNotFound {
+		return handler.Errorf("get referrers tag: %s", err)
+	}
+
+	if artifactType := r.URL.Query().Get("artifactType"); artifactType != "" {
+		index = index.Filter(artifactType)
+		w.Header().Set("OCI-Filters-Applied", "artifactType")
+	}
+	w.Header().Set("Content-Type", index.MediaType)
+	if err := json.NewEncoder(w).Encode(index); err != nil {
+		return handler.Errorf("json encode: %s", err)
+	}
+	return nil
+}
+
+// parseListFilter builds a list filter from Docker registry pagination query
+// params. The `last` param holds the opaque offset returned by build-index
+// rather than the last returned entry.
+func parseListFilter(u *url.URL) (tagclient.ListFilter, error) {
+	var filter tagclient.ListFilter
+	for k, v := range u.Query() {
+		if len(v) != 1 {
+			return filter, handler.Errorf(
+				"invalid query %s:%s", k, v).Status(http.StatusBadRequest)
+		}
+		switch k {
+		case _limitQ:
+			limitCount, err := strconv.Atoi(v[0])
+			if err != nil {
+				return filter, handler.Errorf(
+					"invalid limit %s: %s", v, err).Status(http.StatusBadRequest)
+			}
+			if limitCount == 0 {
+				return filter, handler.Errorf(
+					"invalid limit %d", limitCount).Status(http.StatusBadRequest)
+			}
+			filter.Limit = limitCount
+		case _offsetQ:
+			filter.Offset = v[0]
+		default:
+			return filter, handler.Errorf("invalid query %s", k).Status(http.StatusBadRequest)
+		}
+	}
+	return filter, nil
+}
+
+// setNextLink sets the Link header pointing to the next page of listResp, if
+// there is one.
+func setNextLink(w http.ResponseWriter, u *url.URL, listResp tagmodels.ListResponse) error {
+	offset, err := listResp.GetOffset()
+	if err != nil && err != io.EOF {
+		return handler.Errorf("invalid offset %s", err)
+	}
+	if offset == "" {
+		return nil
+	}
+	nextUrl, err := url.Parse(u.String())
+	if err != nil {
+		return handler.Errorf(
+			"invalid url string: %s", err).Status(http.StatusBadRequest)
+	}
+	val, err := url.ParseQuery(nextUrl.RawQuery)
+	if err != nil {
+		return handler.Errorf(
+			"invalid url string: %s", err).Status(http.StatusBadRequest)
+	}
+	val.Set(_offsetQ, offset)
+	nextUrl.RawQuery = val.Encode()
+
+	// Set header (https://docs.docker.com/registry/spec/api/#pagination),
+	// except the host and scheme.
+	// Link: <<url>?n=2&last=b>; rel="next"
+	w.Header().Set("Link", fmt.Sprintf("%s; rel=\"next\"", nextUrl.String()))
+	return nil
+}
@@ -0,0 +1,248 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package registryoverride
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/uber/kraken/build-index/tagclient"
+	"github.com/uber/kraken/build-index/tagmodels"
+	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/lib/dockerregistry/transfer"
+	"github.com/uber/kraken/lib/registryauth"
+	"github.com/uber/kraken/lib/store"
+	"github.com/uber/kraken/mocks/build-index/tagclient"
+	"github.com/uber/kraken/utils/dockerutil"
+	"github.com/uber/kraken/utils/httputil"
+	"github.com/uber/kraken/utils/testutil"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+type serverMocks struct {
+	tagClient  *mocktagclient.MockClient
+	transferer transfer.ImageTransferer
+	authorizer *registryauth.Authorizer
+}
+
+func newServerMocks(t *testing.T) (*serverMocks, func()) {
+	var cleanup testutil.Cleanup
+	defer cleanup.Recover()
+
+	ctrl := gomock.NewController(t)
+	cleanup.Add(ctrl.Finish)
+
+	cas, c := store.CAStoreFixture()
+	cleanup.Add(c)
+
+	authorizer, err := registryauth.New(registryauth.Config{}, tally.NoopScope, clock.New())
+	if err != nil {
+		panic(err)
+	}
+
+	return &serverMocks{
+		tagClient:  mocktagclient.NewMockClient(ctrl),
+		transferer: transfer.NewTestTransferer(cas),
+		authorizer: authorizer,
+	}, cleanup.Run
+}
+
+func (m *serverMocks) start() (addr string, stop func()) {
+	return testutil.StartServer(NewServer(Config{}, m.tagClient, m.transferer, m.authorizer, nil).Handler())
+}
+
+func TestTagsList(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr, stop := mocks.start()
+	defer stop()
+
+	repo := "namespace-foo/repo-bar"
+
+	var resp tagmodels.ListResponse
+	resp.Links.Next = "/repositories/namespace-foo%2Frepo-bar/tags?limit=2&offset=some-token"
+	resp.Result = []string{"a", "b"}
+	mocks.tagClient.EXPECT().ListRepositoryWithPagination(
+		repo, tagclient.ListFilter{Limit: 2}).Return(resp, nil)
+
+	r, err := httputil.Get(fmt.Sprintf("http://%s/v2/%s/tags/list?n=2", addr, repo))
+	require.NoError(err)
+	defer r.Body.Close()
+
+	var result tagsListResponse
+	require.NoError(json.NewDecoder(r.Body).Decode(&result))
+	require.Equal(tagsListResponse{Name: repo, Tags: []string{"a", "b"}}, result)
+	require.Equal(
+		fmt.Sprintf(`/v2/%s/tags/list?last=some-token&n=2; rel="next"`, repo),
+		r.Header.Get("Link"))
+
+	mocks.tagClient.EXPECT().ListRepositoryWithPagination(
+		repo, tagclient.ListFilter{Limit: 2, Offset: "some-token"}).Return(tagmodels.ListResponse{}, nil)
+
+	r, err = httputil.Get(fmt.Sprintf("http://%s/v2/%s/tags/list?n=2&last=some-token", addr, repo))
+	require.NoError(err)
+	defer r.Body.Close()
+
+	require.NoError(json.NewDecoder(r.Body).Decode(&result))
+	require.Equal(tagsListResponse{Name: repo, Tags: []string{}}, result)
+	require.Empty(r.Header.Get("Link"))
+}
+
+func TestTagsListInvalidQuery(t *testing.T) {
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr, stop := mocks.start()
+	defer stop()
+
+	_, err := httputil.Get(fmt.Sprintf("http://%s/v2/repo/tags/list?n=foo", addr))
+	require.True(t, httputil.IsStatus(err, http.StatusBadRequest))
+}
+
+func TestReferrers(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr, stop := mocks.start()
+	defer stop()
+
+	repo := "namespace-foo/repo-bar"
+	subject := core.DigestFixture()
+
+	getReferrers := func(query string) dockerutil.ReferrersIndex {
+		r, err := httputil.Get(fmt.Sprintf("http://%s/v2/%s/referrers/%s%s", addr, repo, subject, query))
+		require.NoError(err)
+		defer r.Body.Close()
+		require.Equal("application/vnd.oci.image.index.v1+json", r.Header.Get("Content-Type"))
+		var index dockerutil.ReferrersIndex
+		require.NoError(json.NewDecoder(r.Body).Decode(&index))
+		return index
+	}
+
+	// No referrers.
+	require.Empty(getReferrers("").Manifests)
+
+	sig := dockerutil.Descriptor{ArtifactType: "sig", Digest: core.DigestFixture()}
+	sbom := dockerutil.Descriptor{ArtifactType: "sbom", Digest: core.DigestFixture()}
+	index := dockerutil.NewReferrersIndex()
+	index.Add(sig)
+	index.Add(sbom)
+	b, err := json.Marshal(index)
+	require.NoError(err)
+	d, err := core.NewDigester().FromBytes(b)
+	require.NoError(err)
+	require.NoError(mocks.transferer.Upload(repo, d, store.NewBufferFileReader(b)))
+	require.NoError(mocks.transferer.PutTag(
+		fmt.Sprintf("%s:%s", repo, dockerutil.ReferrersTag(subject)), d))
+
+	require.Equal([]dockerutil.Descriptor{sig, sbom}, getReferrers("").Manifests)
+	require.Equal([]dockerutil.Descriptor{sbom}, getReferrers("?artifactType=sbom").Manifests)
+}
+
+func TestRepositoryHandlerNotFound(t *testing.T) {
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	addr, stop := mocks.start()
+	defer stop()
+
+	for _, p := range []string{"repo/manifests/latest", "/tags/list", "repo/referrers/foo"} {
+		_, err := httputil.Get(fmt.Sprintf("http://%s/v2/%s", addr, p))
+		require.Error(t, err, p)
+	}
+}
+
+func TestRegistryPassthrough(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	registry := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Registry", r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusAccepted)
+	})
+	addr, stop := testutil.StartServer(
+		NewServer(Config{}, mocks.tagClient, mocks.transferer, mocks.authorizer, registry).Handler())
+	defer stop()
+
+	for _, method := range []string{"GET", "PATCH", "PUT"} {
+		p := "/v2/repo/blobs/uploads/some-uuid"
+		r, err := httputil.Send(
+			method, fmt.Sprintf("http://%s%s", addr, p), httputil.SendAcceptedCodes(http.StatusAccepted))
+		require.NoError(err, method)
+		r.Body.Close()
+		require.Equal(http.StatusAccepted, r.StatusCode)
+		require.Equal(method+" "+p, r.Header.Get("X-Registry"))
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t)
+	defer cleanup()
+
+	issuer, stopIssuer := registryauth.NewIssuerFixture()
+	defer stopIssuer()
+
+	authorizer, err := registryauth.New(issuer.Config(), tally.NoopScope, clock.New())
+	require.NoError(err)
+	mocks.authorizer = authorizer
+
+	addr, stop := mocks.start()
+	defer stop()
+
+	repo := "namespace-foo/repo-bar"
+	tagsURL := fmt.Sprintf("http://%s/v2/%s/tags/list", addr, repo)
+
+	_, err = httputil.Get(tagsURL)
+	require.True(httputil.IsStatus(err, http.StatusUnauthorized))
+	require.Equal(
+		`Bearer realm="https://auth.example.com/token",service="kraken",scope="repository:namespace-foo/repo-bar:pull"`,
+		err.(httputil.StatusError).Header.Get("WWW-Authenticate"))
+
+	_, err = httputil.Get(
+		fmt.Sprintf("http://%s/v2/_catalog", addr),
+		httputil.SendHeaders(map[string]string{
+			"Authorization": "Bearer " + issuer.Token("some-user", auth.Access{
+				Resource: auth.Resource{Type: "repository", Name: repo},
+				Action:   "pull",
+			}),
+		}))
+	require.True(httputil.IsStatus(err, http.StatusUnauthorized))
+
+	mocks.tagClient.EXPECT().ListRepositoryWithPagination(
+		repo, tagclient.ListFilter{}).Return(tagmodels.ListResponse{}, nil)
+
+	_, err = httputil.Get(tagsURL, httputil.SendHeaders(map[string]string{
+		"Authorization": "Bearer " + issuer.Token("some-user", auth.Access{
+			Resource: auth.Resource{Type: "repository", Name: repo},
+			Action:   "pull",
+		}),
+	}))
+	require.NoError(err)
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/uber/kraken/utils/httputil"
+)
+
+type member struct {
+	Addr    string  `json:"addr"`
+	Weight  int     `json:"weight"`
+	Share   float64 `json:"share"`
+	Healthy bool    `json:"healthy"`
+	Drained bool    `json:"drained"`
+}
+
+type ring struct {
+	MaxReplica int      `json:"max_replica"`
+	Members    []member `json:"members"`
+}
+
+type owner struct {
+	Addr    string `json:"addr"`
+	Healthy bool   `json:"healthy"`
+}
+
+type ownership struct {
+	Digest          string   `json:"digest"`
+	MaxReplica      int      `json:"max_replica"`
+	Owners          []owner  `json:"owners"`
+	Locations       []string `json:"locations"`
+	HealthyReplicas int      `json:"healthy_replicas"`
+	Satisfied       bool     `json:"satisfied"`
+}
+
+func get(url string, v interface{}) error {
+	resp, err := httputil.Get(url, httputil.SendTimeout(5*time.Second))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func printRing(addr string) error {
+	var r ring
+	if err := get(fmt.Sprintf("http://%s/debug/hashring", addr), &r); err != nil {
+		return err
+	}
+	fmt.Printf("max replica: %d\n\n", r.MaxReplica)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ADDR\tWEIGHT\tSHARE\tHEALTHY\tDRAINED")
+	for _, m := range r.Members {
+		fmt.Fprintf(w, "%s\t%d\t%.1f%%\t%t\t%t\n", m.Addr, m.Weight, 100*m.Share, m.Healthy, m.Drained)
+	}
+	return w.Flush()
+}
+
+func printOwners(addr, digest string) error {
+	var o ownership
+	if err := get(fmt.Sprintf("http://%s/debug/hashring/%s", addr, digest), &o); err != nil {
+		return err
+	}
+	fmt.Printf("digest: %s\n", o.Digest)
+	fmt.Printf("replicas: %d/%d healthy, satisfied: %t\n", o.HealthyReplicas, o.MaxReplica, o.Satisfied)
+	fmt.Printf("locations: %v\n\n", o.Locations)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "OWNER\tHEALTHY")
+	for _, h := range o.Owners {
+		fmt.Fprintf(w, "%s\t%t\n", h.Addr, h.Healthy)
+	}
+	return w.Flush()
+}
+
+// hashring prints the hash ring of an origin cluster, or the owners of a
+// digest if one is given, e.g.:
+//
+//	hashring -origin origin1:15002 sha256:abc...
+func main() {
+	origin := flag.String("origin", "", "origin server address")
+	flag.Parse()
+
+	if *origin == "" {
+		panic("-origin required")
+	}
+	if flag.NArg() > 1 {
+		panic("at most one digest may be given")
+	}
+
+	var err error
+	if flag.NArg() == 1 {
+		err = printOwners(*origin, flag.Arg(0))
+	} else {
+		err = printRing(*origin)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
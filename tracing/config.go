@@ -0,0 +1,67 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tracing
+
+import "time"
+
+// Config defines tracing configuration. Tracing is disabled unless an OTLP
+// endpoint is configured.
+type Config struct {
+	// Endpoint is the OTLP/HTTP traces endpoint of a collector, e.g.
+	// http://localhost:4318/v1/traces. Spans are exported as JSON.
+	Endpoint string `yaml:"endpoint"`
+
+	// Headers are added to export requests, e.g. for authentication.
+	Headers map[string]string `yaml:"headers"`
+
+	// SampleRatio is the fraction of traces started locally which are sampled.
+	// Traces started by remote peers follow the sampling decision of the peer.
+	SampleRatio float64 `yaml:"sample_ratio"`
+
+	// BatchSize is the maximum number of spans per export request.
+	BatchSize int `yaml:"batch_size"`
+
+	// QueueSize is the maximum number of spans buffered for export. Spans
+	// finished while the queue is full are dropped.
+	QueueSize int `yaml:"queue_size"`
+
+	// FlushInterval is the maximum duration spans are buffered before export.
+	FlushInterval time.Duration `yaml:"flush_interval"`
+
+	// Timeout is the timeout of export requests.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+func (c Config) applyDefaults() Config {
+	if c.SampleRatio == 0 {
+		c.SampleRatio = 1
+	}
+	if c.BatchSize == 0 {
+		c.BatchSize = 512
+	}
+	if c.QueueSize == 0 {
+		c.QueueSize = 2048
+	}
+	if c.FlushInterval == 0 {
+		c.FlushInterval = 5 * time.Second
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 10 * time.Second
+	}
+	return c
+}
+
+func (c Config) enabled() bool {
+	return c.Endpoint != ""
+}
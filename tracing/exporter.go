@@ -0,0 +1,234 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/uber/kraken/utils/log"
+)
+
+// Types below mirror the JSON encoding of the OTLP ExportTraceServiceRequest.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              Kind            `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// OTLP status codes.
+const (
+	_statusUnset = 0
+	_statusError = 2
+)
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+func newOTLPAttributes(attrs map[string]string) []otlpAttribute {
+	var result []otlpAttribute
+	for k, v := range attrs {
+		result = append(result, otlpAttribute{k, otlpValue{v}})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+func newOTLPSpan(s *Span) otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	span := otlpSpan{
+		TraceID:           s.sc.TraceID.String(),
+		SpanID:            s.sc.SpanID.String(),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		Attributes:        newOTLPAttributes(s.attrs),
+		Status:            otlpStatus{Code: _statusUnset},
+	}
+	if s.parent != (SpanID{}) {
+		span.ParentSpanID = s.parent.String()
+	}
+	if s.err != nil {
+		span.Status = otlpStatus{Code: _statusError, Message: s.err.Error()}
+	}
+	return span
+}
+
+// otlpExporter batches finished spans and posts them to an OTLP/HTTP
+// collector. Export requests bypass httputil, which traces its own requests.
+type otlpExporter struct {
+	config   Config
+	resource otlpResource
+	client   *http.Client
+	spans    chan *Span
+	done     chan struct{}
+	wg       sync.WaitGroup
+	once     sync.Once
+}
+
+func newOTLPExporter(config Config, service string) *otlpExporter {
+	e := &otlpExporter{
+		config: config,
+		resource: otlpResource{newOTLPAttributes(map[string]string{
+			"service.name": service,
+			"host.name":    hostname(),
+		})},
+		client: &http.Client{Timeout: config.Timeout},
+		spans:  make(chan *Span, config.QueueSize),
+		done:   make(chan struct{}),
+	}
+	e.wg.Add(1)
+	go e.loop()
+	return e
+}
+
+func (e *otlpExporter) export(s *Span) {
+	select {
+	case e.spans <- s:
+	default:
+		// Tracing must never block the traced operation.
+	}
+}
+
+func (e *otlpExporter) loop() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(e.config.FlushInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.post(batch); err != nil {
+			log.Errorf("Error exporting %d spans: %s", len(batch), err)
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case s := <-e.spans:
+			batch = append(batch, s)
+			if len(batch) >= e.config.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.done:
+			for {
+				select {
+				case s := <-e.spans:
+					batch = append(batch, s)
+					if len(batch) >= e.config.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *otlpExporter) post(batch []*Span) error {
+	spans := make([]otlpSpan, len(batch))
+	for i, s := range batch {
+		spans[i] = newOTLPSpan(s)
+	}
+	b, err := json.Marshal(otlpRequest{[]otlpResourceSpans{{
+		Resource:   e.resource,
+		ScopeSpans: []otlpScopeSpans{{otlpScope{"github.com/uber/kraken"}, spans}},
+	}}})
+	if err != nil {
+		return fmt.Errorf("json: %s", err)
+	}
+	req, err := http.NewRequest("POST", e.config.Endpoint, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("new request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.config.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Close flushes all buffered spans.
+func (e *otlpExporter) Close() error {
+	e.once.Do(func() { close(e.done) })
+	e.wg.Wait()
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type collector struct {
+	sync.Mutex
+	requests []otlpRequest
+	headers  []http.Header
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req otlpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.requests = append(c.requests, req)
+	c.headers = append(c.headers, r.Header)
+}
+
+func (c *collector) spans() []otlpSpan {
+	c.Lock()
+	defer c.Unlock()
+	var result []otlpSpan
+	for _, req := range c.requests {
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				result = append(result, ss.Spans...)
+			}
+		}
+	}
+	return result
+}
+
+func TestExporterFlushesOnClose(t *testing.T) {
+	require := require.New(t)
+
+	c := &collector{}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	tracer, err := New(Config{
+		Endpoint:      server.URL,
+		Headers:       map[string]string{"Authorization": "token"},
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+	}, "kraken-test")
+	require.NoError(err)
+
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	_, child := tracer.Start(ctx, "child", WithKind(KindClient), WithAttribute("foo", "bar"))
+	child.SetError(errors.New("some error"))
+	child.End()
+	parent.End()
+	_, other := tracer.Start(context.Background(), "other")
+	other.End()
+
+	require.NoError(tracer.Close())
+
+	spans := c.spans()
+	require.Len(spans, 3)
+	require.Len(c.requests, 2)
+	require.Equal("token", c.headers[0].Get("Authorization"))
+
+	require.Equal("child", spans[0].Name)
+	require.Equal(KindClient, spans[0].Kind)
+	require.Equal(parent.Context().SpanID.String(), spans[0].ParentSpanID)
+	require.Equal(parent.Context().TraceID.String(), spans[0].TraceID)
+	require.Equal([]otlpAttribute{{"foo", otlpValue{"bar"}}}, spans[0].Attributes)
+	require.Equal(otlpStatus{_statusError, "some error"}, spans[0].Status)
+
+	require.Equal("parent", spans[1].Name)
+	require.Empty(spans[1].ParentSpanID)
+	require.Equal(otlpStatus{Code: _statusUnset}, spans[1].Status)
+
+	var service string
+	for _, attr := range c.requests[0].ResourceSpans[0].Resource.Attributes {
+		if attr.Key == "service.name" {
+			service = attr.Value.StringValue
+		}
+	}
+	require.Equal("kraken-test", service)
+}
+
+func TestExporterFlushesOnInterval(t *testing.T) {
+	c := &collector{}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	tracer, err := New(Config{
+		Endpoint:      server.URL,
+		FlushInterval: 10 * time.Millisecond,
+	}, "kraken-test")
+	require.NoError(t, err)
+	defer tracer.Close()
+
+	_, span := tracer.Start(context.Background(), "foo")
+	span.End()
+
+	require.Eventually(t, func() bool { return len(c.spans()) == 1 }, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tracing
+
+import "sync"
+
+// Recorder records finished spans in memory for testing purposes.
+type Recorder struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func (r *Recorder) export(s *Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+// Close is a no-op.
+func (r *Recorder) Close() error { return nil }
+
+// Spans returns all finished spans, in the order they were ended.
+func (r *Recorder) Spans() []*Span {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Span(nil), r.spans...)
+}
+
+// TracerFixture returns a Tracer which samples all traces and records them.
+func TracerFixture() (*Tracer, *Recorder) {
+	r := &Recorder{}
+	return newTracer(Config{}.applyDefaults(), "test", r), r
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TraceparentHeader is the W3C Trace Context header which carries the span
+// context across process boundaries.
+const TraceparentHeader = "Traceparent"
+
+const _sampledFlag = 0x01
+
+// FormatTraceparent formats sc as a traceparent header value.
+func FormatTraceparent(sc SpanContext) string {
+	var flags byte
+	if sc.Sampled {
+		flags = _sampledFlag
+	}
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent parses a traceparent header value.
+func ParseTraceparent(s string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 {
+		return SpanContext{}, errors.New("expected version-traceid-spanid-flags")
+	}
+	if len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}, fmt.Errorf("invalid version %q", parts[0])
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, errors.New("unexpected fields for version 00")
+	}
+	var sc SpanContext
+	if err := decodeHex(sc.TraceID[:], parts[1]); err != nil {
+		return SpanContext{}, fmt.Errorf("trace id: %s", err)
+	}
+	if err := decodeHex(sc.SpanID[:], parts[2]); err != nil {
+		return SpanContext{}, fmt.Errorf("span id: %s", err)
+	}
+	var flags [1]byte
+	if err := decodeHex(flags[:], parts[3]); err != nil {
+		return SpanContext{}, fmt.Errorf("flags: %s", err)
+	}
+	sc.Sampled = flags[0]&_sampledFlag != 0
+	if !sc.Valid() {
+		return SpanContext{}, errors.New("all zero trace or span id")
+	}
+	return sc, nil
+}
+
+func decodeHex(dst []byte, s string) error {
+	if len(s) != 2*len(dst) || strings.ToLower(s) != s {
+		return fmt.Errorf("expected %d lowercase hex characters", 2*len(dst))
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
+
+// Inject sets the traceparent header of h to the span context carried by
+// ctx, if any.
+func Inject(ctx context.Context, h http.Header) {
+	if sc, ok := SpanContextFromContext(ctx); ok {
+		h.Set(TraceparentHeader, FormatTraceparent(sc))
+	}
+}
+
+// Extract returns a copy of ctx which carries the span context of the
+// traceparent header of h. Missing or malformed headers are ignored.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	v := h.Get(TraceparentHeader)
+	if v == "" {
+		return ctx
+	}
+	sc, err := ParseTraceparent(v)
+	if err != nil {
+		return ctx
+	}
+	return ContextWithSpanContext(ctx, sc)
+}
@@ -0,0 +1,340 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package tracing implements distributed tracing compatible with
+// OpenTelemetry: trace context is propagated between components via W3C
+// traceparent headers, and finished spans are exported to an OTLP/HTTP
+// collector.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// SpanContext identifies a span across process boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// Valid returns true if sc identifies a span.
+func (sc SpanContext) Valid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Kind describes the relationship of a span to its remote parent or children.
+// Values match the OTLP span kinds.
+type Kind int
+
+// Span kinds.
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a copy of ctx which carries sc, such that
+// spans started from it are children of sc.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context carried by ctx.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok && sc.Valid()
+}
+
+// Span measures an operation. All methods are safe to call on a nil Span,
+// which is returned when tracing is disabled.
+type Span struct {
+	tracer *Tracer
+	name   string
+	kind   Kind
+	sc     SpanContext
+	parent SpanID
+	start  time.Time
+
+	mu    sync.Mutex // Protects the following fields:
+	attrs map[string]string
+	err   error
+	end   time.Time
+	ended bool
+}
+
+// Context returns the span context of s.
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// Name returns the name of s.
+func (s *Span) Name() string {
+	if s == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.name
+}
+
+// Parent returns the id of the parent of s, which is zero for root spans.
+func (s *Span) Parent() SpanID {
+	if s == nil {
+		return SpanID{}
+	}
+	return s.parent
+}
+
+// Attribute returns the value of attribute key of s.
+func (s *Span) Attribute(key string) string {
+	if s == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attrs[key]
+}
+
+// Err returns the error s was marked failed with.
+func (s *Span) Err() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// SetName overrides the name of s, for names which are only known once the
+// operation is underway, such as matched routes.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+// SetAttribute annotates s with key and the string form of value.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs[key] = fmt.Sprint(value)
+}
+
+// SetError marks s as failed with err. Nil errors are ignored.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// End finishes s. Calls after the first are no-ops.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	if s.sc.Sampled {
+		s.tracer.exporter.export(s)
+	}
+}
+
+// SpanOption configures a span at start.
+type SpanOption func(*Span)
+
+// WithKind sets the kind of a span. Defaults to KindInternal.
+func WithKind(k Kind) SpanOption {
+	return func(s *Span) { s.kind = k }
+}
+
+// WithAttribute annotates a span at start.
+func WithAttribute(key string, value interface{}) SpanOption {
+	return func(s *Span) { s.attrs[key] = fmt.Sprint(value) }
+}
+
+// exporter exports finished spans.
+type exporter interface {
+	export(s *Span)
+	io.Closer
+}
+
+// Tracer creates spans for a single service.
+type Tracer struct {
+	config   Config
+	service  string
+	exporter exporter
+}
+
+// New creates a new Tracer which exports spans of service to the configured
+// OTLP endpoint.
+func New(config Config, service string) (*Tracer, error) {
+	config = config.applyDefaults()
+	if !config.enabled() {
+		return nil, fmt.Errorf("no endpoint configured")
+	}
+	if config.SampleRatio < 0 || config.SampleRatio > 1 {
+		return nil, fmt.Errorf("sample ratio must be within [0, 1]: %f", config.SampleRatio)
+	}
+	return newTracer(config, service, newOTLPExporter(config, service)), nil
+}
+
+func newTracer(config Config, service string, e exporter) *Tracer {
+	return &Tracer{config, service, e}
+}
+
+// Close flushes all finished spans.
+func (t *Tracer) Close() error {
+	return t.exporter.Close()
+}
+
+// Start starts a span, which is a child of the span carried by ctx if any.
+// Returns a copy of ctx which carries the new span.
+func (t *Tracer) Start(ctx context.Context, name string, opts ...SpanOption) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{
+		tracer: t,
+		name:   name,
+		kind:   KindInternal,
+		start:  time.Now(),
+		attrs:  make(map[string]string),
+	}
+	if parent, ok := SpanContextFromContext(ctx); ok {
+		s.sc.TraceID = parent.TraceID
+		s.sc.Sampled = parent.Sampled
+		s.parent = parent.SpanID
+	} else {
+		s.sc.TraceID = newTraceID()
+		s.sc.Sampled = t.sample(s.sc.TraceID)
+	}
+	s.sc.SpanID = newSpanID()
+	for _, opt := range opts {
+		opt(s)
+	}
+	return ContextWithSpanContext(ctx, s.sc), s
+}
+
+// sample deterministically samples traces by their id, such that all
+// components agree on the decision for root spans with equal ids.
+func (t *Tracer) sample(id TraceID) bool {
+	if t.config.SampleRatio >= 1 {
+		return true
+	}
+	x := binary.BigEndian.Uint64(id[8:]) >> 1
+	return float64(x) < t.config.SampleRatio*float64(uint64(1)<<63)
+}
+
+func newTraceID() (id TraceID) {
+	rand.Read(id[:])
+	return id
+}
+
+func newSpanID() (id SpanID) {
+	rand.Read(id[:])
+	return id
+}
+
+var (
+	_globalMu sync.RWMutex
+	_global   *Tracer
+)
+
+// Init sets the global tracer from config, which is used by Start. Tracing is
+// left disabled if config has no endpoint. The returned closer flushes all
+// finished spans.
+func Init(config Config, service string) (io.Closer, error) {
+	if !config.enabled() {
+		return nopCloser{}, nil
+	}
+	t, err := New(config, service)
+	if err != nil {
+		return nil, err
+	}
+	SetGlobal(t)
+	return t, nil
+}
+
+// SetGlobal sets the global tracer. A nil tracer disables tracing.
+func SetGlobal(t *Tracer) {
+	_globalMu.Lock()
+	defer _globalMu.Unlock()
+	_global = t
+}
+
+// Enabled returns true if a global tracer is set.
+func Enabled() bool {
+	_globalMu.RLock()
+	defer _globalMu.RUnlock()
+	return _global != nil
+}
+
+// Start starts a span with the global tracer. See Tracer.Start.
+func Start(ctx context.Context, name string, opts ...SpanOption) (context.Context, *Span) {
+	_globalMu.RLock()
+	t := _global
+	_globalMu.RUnlock()
+	return t.Start(ctx, name, opts...)
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+func hostname() string {
+	h, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return h
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	for _, sampled := range []bool{true, false} {
+		sc := SpanContext{newTraceID(), newSpanID(), sampled}
+		result, err := ParseTraceparent(FormatTraceparent(sc))
+		require.NoError(t, err)
+		require.Equal(t, sc, result)
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	require := require.New(t)
+
+	sc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(err)
+	require.Equal("4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	require.Equal("00f067aa0ba902b7", sc.SpanID.String())
+	require.True(sc.Sampled)
+
+	// Future versions may append fields.
+	_, err = ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-foo")
+	require.NoError(err)
+}
+
+func TestParseTraceparentErrors(t *testing.T) {
+	tests := []struct {
+		desc string
+		s    string
+	}{
+		{"empty", ""},
+		{"missing fields", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7"},
+		{"invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{"extra fields", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-foo"},
+		{"short trace id", "00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01"},
+		{"uppercase span id", "00-4bf92f3577b34da6a3ce929d0e0e4736-00F067AA0BA902B7-01"},
+		{"zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{"zero span id", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := ParseTraceparent(test.s)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestInjectExtract(t *testing.T) {
+	require := require.New(t)
+
+	tracer, _ := TracerFixture()
+	ctx, span := tracer.Start(context.Background(), "foo")
+
+	h := make(http.Header)
+	Inject(ctx, h)
+	sc, ok := SpanContextFromContext(Extract(context.Background(), h))
+	require.True(ok)
+	require.Equal(span.Context(), sc)
+
+	// Malformed headers are ignored.
+	h.Set(TraceparentHeader, "foo")
+	_, ok = SpanContextFromContext(Extract(context.Background(), h))
+	require.False(ok)
+}
+
+func TestStartInheritsParent(t *testing.T) {
+	require := require.New(t)
+
+	tracer, recorder := TracerFixture()
+
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	_, child := tracer.Start(ctx, "child", WithKind(KindClient), WithAttribute("size", 5))
+	child.SetError(errors.New("some error"))
+	child.End()
+	parent.End()
+	parent.End()
+
+	require.Equal(parent.Context().TraceID, child.Context().TraceID)
+	require.NotEqual(parent.Context().SpanID, child.Context().SpanID)
+	require.Equal(parent.Context().SpanID, child.Parent())
+	require.Equal(SpanID{}, parent.Parent())
+	require.Equal("5", child.Attribute("size"))
+	require.Error(child.Err())
+
+	spans := recorder.Spans()
+	require.Len(spans, 2)
+	require.Equal("child", spans[0].Name())
+	require.Equal("parent", spans[1].Name())
+}
+
+func TestSampling(t *testing.T) {
+	require := require.New(t)
+
+	_, recorder := TracerFixture()
+	tracer := newTracer(Config{SampleRatio: 0.5}.applyDefaults(), "test", recorder)
+
+	var sampled int
+	for i := 0; i < 1000; i++ {
+		ctx, span := tracer.Start(context.Background(), "foo")
+		// Children follow the decision of their parent.
+		_, child := tracer.Start(ctx, "bar")
+		require.Equal(span.Context().Sampled, child.Context().Sampled)
+		if span.Context().Sampled {
+			sampled++
+		}
+		child.End()
+		span.End()
+	}
+	require.InDelta(500, sampled, 100)
+	require.Len(recorder.Spans(), 2*sampled)
+
+	// Unsampled remote parents are respected.
+	ctx := ContextWithSpanContext(context.Background(), SpanContext{newTraceID(), newSpanID(), false})
+	_, span := tracer.Start(ctx, "foo")
+	require.False(span.Context().Sampled)
+}
+
+func TestNilTracerAndSpan(t *testing.T) {
+	require := require.New(t)
+
+	var tracer *Tracer
+	ctx, span := tracer.Start(context.Background(), "foo")
+	require.Nil(span)
+	_, ok := SpanContextFromContext(ctx)
+	require.False(ok)
+
+	span.SetName("bar")
+	span.SetAttribute("foo", "bar")
+	span.SetError(errors.New("some error"))
+	span.End()
+	require.False(span.Context().Valid())
+	require.Equal("", span.Name())
+	require.Equal("", span.Attribute("foo"))
+	require.NoError(span.Err())
+}
+
+func TestInitDisabled(t *testing.T) {
+	require := require.New(t)
+
+	closer, err := Init(Config{}, "test")
+	require.NoError(err)
+	require.NoError(closer.Close())
+	require.False(Enabled())
+
+	_, err = Init(Config{Endpoint: "http://localhost:4318/v1/traces", SampleRatio: 2}, "test")
+	require.Error(err)
+}
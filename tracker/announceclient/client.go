@@ -15,15 +15,19 @@ package announceclient
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/lib/hashring"
+	"github.com/uber/kraken/tracing"
 	"github.com/uber/kraken/utils/httputil"
 )
 
@@ -36,6 +40,14 @@ type Request struct {
 	Digest   *core.Digest   `json:"digest"` // Optional (for now).
 	InfoHash core.InfoHash  `json:"info_hash"`
 	Peer     *core.PeerInfo `json:"peer"`
+
+	// Since is the token of the last V3 handout the peer received for the
+	// torrent, if any.
+	Since uint64 `json:"since,omitempty"`
+
+	// Probe requests a handout without recording Peer, such that peers can
+	// check whether a torrent is shared before joining it.
+	Probe bool `json:"probe,omitempty"`
 }
 
 // GetDigest is a backwards compatible accessor of the request digest.
@@ -63,28 +75,37 @@ type Client interface {
 		h core.InfoHash,
 		complete bool,
 		version int) ([]*core.PeerInfo, time.Duration, error)
+
+	Probe(d core.Digest, h core.InfoHash) ([]*core.PeerInfo, error)
 }
 
 type client struct {
-	pctx core.PeerContext
-	ring hashring.PassiveRing
-	tls  *tls.Config
+	pctx   core.PeerContext
+	ring   hashring.PassiveRing
+	tls    *tls.Config
+	deltas *deltaState
 }
 
 // New creates a new client.
 func New(pctx core.PeerContext, ring hashring.PassiveRing, tls *tls.Config) Client {
-	return &client{pctx, ring, tls}
+	return &client{pctx, ring, tls, newDeltaState()}
 }
 
 // Announce versionss.
 const (
 	V1 = 1
 	V2 = 2
+
+	// V3 announces receive binary encoded DeltaResponses.
+	V3 = 3
 )
 
 func getEndpoint(version int, addr string, h core.InfoHash) (method, url string) {
-	if version == V1 {
+	switch version {
+	case V1:
 		return "GET", fmt.Sprintf("http://%s/announce", addr)
+	case V3:
+		return "POST", fmt.Sprintf("http://%s/announce/v3/%s", addr, h.String())
 	}
 	return "POST", fmt.Sprintf("http://%s/announce/%s", addr, h.String())
 }
@@ -98,39 +119,206 @@ func (c *client) Announce(
 	complete bool,
 	version int) (peers []*core.PeerInfo, interval time.Duration, err error) {
 
-	body, err := json.Marshal(&Request{
+	ctx, span := tracing.Start(
+		context.Background(),
+		"announce",
+		tracing.WithKind(tracing.KindClient),
+		tracing.WithAttribute("digest", d.Hex()),
+		tracing.WithAttribute("info_hash", h.Hex()),
+		tracing.WithAttribute("complete", complete))
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
+	req := &Request{
 		Name:     d.Hex(), // For backwards compatability. TODO(codyg): Remove.
 		Digest:   &d,
 		InfoHash: h,
 		Peer:     core.PeerInfoFromContext(c.pctx, complete),
-	})
+	}
+	var since uint64
+	if version == V3 {
+		since = c.deltas.token(h)
+		req.Since = since
+	}
+	httpResp, err := c.send(ctx, version, req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer httpResp.Body.Close()
+	if version == V3 {
+		return c.readDeltaResponse(h, since, complete, httpResp)
+	}
+	var resp Response
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, 0, fmt.Errorf("decode response: %s", err)
+	}
+	return resp.Peers, resp.Interval, nil
+}
+
+// Probe returns the peers of the torrent identified by (d, h), like Announce,
+// but without adding this peer to the torrent. Trackers which predate probes
+// add it nonetheless.
+func (c *client) Probe(d core.Digest, h core.InfoHash) ([]*core.PeerInfo, error) {
+	req := &Request{
+		Name:     d.Hex(), // For backwards compatability. TODO(codyg): Remove.
+		Digest:   &d,
+		InfoHash: h,
+		Peer:     core.PeerInfoFromContext(c.pctx, false),
+		Probe:    true,
+	}
+	httpResp, err := c.send(context.Background(), V2, req)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	var resp Response
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("decode response: %s", err)
+	}
+	return resp.Peers, nil
+}
+
+// send sends req to the first reachable tracker of its torrent.
+func (c *client) send(ctx context.Context, version int, req *Request) (*http.Response, error) {
+	body, err := json.Marshal(req)
 	if err != nil {
-		return nil, 0, fmt.Errorf("marshal request: %s", err)
+		return nil, fmt.Errorf("marshal request: %s", err)
 	}
 	var httpResp *http.Response
-	for _, addr := range c.ring.Locations(d) {
-		method, url := getEndpoint(version, addr, h)
+	for _, addr := range c.ring.Locations(*req.Digest) {
+		method, url := getEndpoint(version, addr, req.InfoHash)
 		httpResp, err = httputil.Send(
 			method,
 			url,
 			httputil.SendBody(bytes.NewReader(body)),
 			httputil.SendTimeout(10*time.Second),
-			httputil.SendTLS(c.tls))
+			httputil.SendTLS(c.tls),
+			httputil.SendContext(ctx))
 		if err != nil {
 			if httputil.IsNetworkError(err) {
 				c.ring.Failed(addr)
 				continue
 			}
-			return nil, 0, err
+			return nil, err
+		}
+		return httpResp, nil
+	}
+	if err == nil {
+		err = errors.New("no trackers")
+	}
+	return nil, err
+}
+
+func (c *client) readDeltaResponse(
+	h core.InfoHash,
+	since uint64,
+	complete bool,
+	httpResp *http.Response) ([]*core.PeerInfo, time.Duration, error) {
+
+	b, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read response: %s", err)
+	}
+	var resp DeltaResponse
+	if err := resp.UnmarshalBinary(b); err != nil {
+		return nil, 0, fmt.Errorf("decode response: %s", err)
+	}
+	if complete {
+		// Complete peers receive no handouts, so there is no point in keeping
+		// track of them.
+		c.deltas.remove(h)
+		return resp.Added, resp.Interval, nil
+	}
+	return c.deltas.apply(h, since, &resp), resp.Interval, nil
+}
+
+// deltaState tracks the last V3 handout received for each torrent, such that
+// DeltaResponses can be applied to it.
+type deltaState struct {
+	mu        sync.Mutex
+	handouts  map[core.InfoHash]*handout
+	lastSweep time.Time
+}
+
+type handout struct {
+	token         uint64
+	peers         []*core.PeerInfo
+	lastAnnounced time.Time
+}
+
+// _handoutTTL is the duration a handout is kept without any announces for its
+// torrent, e.g. because the torrent was cancelled.
+const _handoutTTL = 10 * time.Minute
+
+func newDeltaState() *deltaState {
+	return &deltaState{
+		handouts:  make(map[core.InfoHash]*handout),
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *deltaState) token(h core.InfoHash) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ho, ok := s.handouts[h]; ok {
+		return ho.token
+	}
+	return 0
+}
+
+func (s *deltaState) remove(h core.InfoHash) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.handouts, h)
+}
+
+// apply applies resp, which is relative to the handout identified by since, to
+// the last handout of h and returns the resulting peers. Added peers are placed
+// first in the order given by the tracker, followed by the remaining peers of
+// the last handout in their previous order.
+func (s *deltaState) apply(h core.InfoHash, since uint64, resp *DeltaResponse) []*core.PeerInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) > _handoutTTL {
+		for k, ho := range s.handouts {
+			if now.Sub(ho.lastAnnounced) > _handoutTTL {
+				delete(s.handouts, k)
+			}
 		}
-		defer httpResp.Body.Close()
-		var resp Response
-		if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
-			return nil, 0, fmt.Errorf("decode response: %s", err)
+		s.lastSweep = now
+	}
+
+	token := resp.Token
+	peers := resp.Added
+	prev, ok := s.handouts[h]
+	if !resp.Full && (!ok || prev.token != since) {
+		// The delta is relative to a handout we no longer have, so clear the
+		// token to receive a full handout on the next announce.
+		token = 0
+	} else if !resp.Full {
+		dropped := make(map[core.PeerID]bool, len(resp.Removed)+len(resp.Added))
+		for _, id := range resp.Removed {
+			dropped[id] = true
+		}
+		for _, p := range resp.Added {
+			dropped[p.PeerID] = true
+		}
+		peers = make([]*core.PeerInfo, len(resp.Added), len(resp.Added)+len(prev.peers))
+		copy(peers, resp.Added)
+		for _, p := range prev.peers {
+			if !dropped[p.PeerID] {
+				peers = append(peers, p)
+			}
 		}
-		return resp.Peers, resp.Interval, nil
 	}
-	return nil, 0, err
+	s.handouts[h] = &handout{token, peers, now}
+	return peers
 }
 
 // DisabledClient rejects all announces. Suitable for origin peers which should
@@ -148,3 +336,8 @@ func (c DisabledClient) Announce(
 
 	return nil, 0, ErrDisabled
 }
+
+// Probe always returns error.
+func (c DisabledClient) Probe(d core.Digest, h core.InfoHash) ([]*core.PeerInfo, error) {
+	return nil, ErrDisabled
+}
@@ -0,0 +1,244 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package announceclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/uber/kraken/core"
+)
+
+// Tags of encoded peer addresses.
+const (
+	_hostnameTag byte = 0
+	_ipv4Tag     byte = net.IPv4len
+	_ipv6Tag     byte = net.IPv6len
+)
+
+// Bits of encoded flags.
+const (
+	_fullFlag     byte = 1 << 0
+	_originFlag   byte = 1 << 0
+	_completeFlag byte = 1 << 1
+)
+
+// _minPeerLen is the smallest possible length of an encoded peer, i.e. a peer
+// id, an empty hostname, a port and flags.
+const _minPeerLen = len(core.PeerID{}) + 2 + 2 + 1
+
+// DeltaResponse defines a V3 announce response, which only contains the changes
+// to the peer handout since the last announce of the same peer for the same
+// torrent.
+type DeltaResponse struct {
+	// Full is set if Added is the entire peer handout, i.e. the tracker did not
+	// recognize Since.
+	Full bool
+
+	// Token identifies this handout, and should be sent as Request.Since on the
+	// next announce.
+	Token uint64
+
+	// Interval is the duration the peer should wait before announcing again.
+	Interval time.Duration
+
+	// Added are new or changed peers, sorted by priority.
+	Added []*core.PeerInfo
+
+	// Removed are peers which are no longer part of the handout.
+	Removed []core.PeerID
+}
+
+// MarshalBinary encodes r as flags, a big endian token, the interval in
+// milliseconds as a uvarint, then uvarint counts of added peers and removed
+// peer ids each followed by their elements.
+func (r *DeltaResponse) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	var flags byte
+	if r.Full {
+		flags |= _fullFlag
+	}
+	buf.WriteByte(flags)
+	writeUint64(&buf, r.Token)
+	writeUvarint(&buf, uint64(r.Interval/time.Millisecond))
+	writeUvarint(&buf, uint64(len(r.Added)))
+	for _, p := range r.Added {
+		if err := writePeer(&buf, p); err != nil {
+			return nil, fmt.Errorf("peer %s: %s", p.PeerID, err)
+		}
+	}
+	writeUvarint(&buf, uint64(len(r.Removed)))
+	for _, id := range r.Removed {
+		buf.Write(id[:])
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes the output of MarshalBinary into r.
+func (r *DeltaResponse) UnmarshalBinary(b []byte) error {
+	rd := bytes.NewReader(b)
+	flags, err := rd.ReadByte()
+	if err != nil {
+		return errors.New("missing flags")
+	}
+	var token [8]byte
+	if _, err := io.ReadFull(rd, token[:]); err != nil {
+		return errors.New("missing token")
+	}
+	interval, err := binary.ReadUvarint(rd)
+	if err != nil {
+		return errors.New("invalid interval")
+	}
+	numAdded, err := readCount(rd, _minPeerLen)
+	if err != nil {
+		return fmt.Errorf("added peers: %s", err)
+	}
+	added := make([]*core.PeerInfo, numAdded)
+	for i := range added {
+		p, err := readPeer(rd)
+		if err != nil {
+			return fmt.Errorf("added peer %d: %s", i, err)
+		}
+		added[i] = p
+	}
+	numRemoved, err := readCount(rd, len(core.PeerID{}))
+	if err != nil {
+		return fmt.Errorf("removed peers: %s", err)
+	}
+	removed := make([]core.PeerID, numRemoved)
+	for i := range removed {
+		if _, err := io.ReadFull(rd, removed[i][:]); err != nil {
+			return fmt.Errorf("removed peer %d truncated", i)
+		}
+	}
+	if rd.Len() > 0 {
+		return fmt.Errorf("%d trailing bytes", rd.Len())
+	}
+	*r = DeltaResponse{
+		Full:     flags&_fullFlag != 0,
+		Token:    binary.BigEndian.Uint64(token[:]),
+		Interval: time.Duration(interval) * time.Millisecond,
+		Added:    added,
+		Removed:  removed,
+	}
+	return nil
+}
+
+func writeUint64(buf *bytes.Buffer, x uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], x)
+	buf.Write(b[:])
+}
+
+func writeUvarint(buf *bytes.Buffer, x uint64) {
+	var b [binary.MaxVarintLen64]byte
+	buf.Write(b[:binary.PutUvarint(b[:], x)])
+}
+
+// writePeer encodes p as its peer id, its ip, a big endian port and flags. IPs
+// are written in their 4 or 16 byte form behind a tag of the same value, and
+// hostnames behind a zero tag and a uvarint length.
+func writePeer(buf *bytes.Buffer, p *core.PeerInfo) error {
+	if p.Port < 0 || p.Port > 0xffff {
+		return fmt.Errorf("invalid port %d", p.Port)
+	}
+	buf.Write(p.PeerID[:])
+	if ip := net.ParseIP(p.IP); ip == nil {
+		buf.WriteByte(_hostnameTag)
+		writeUvarint(buf, uint64(len(p.IP)))
+		buf.WriteString(p.IP)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		buf.WriteByte(_ipv4Tag)
+		buf.Write(ip4)
+	} else {
+		buf.WriteByte(_ipv6Tag)
+		buf.Write(ip.To16())
+	}
+	var port [2]byte
+	binary.BigEndian.PutUint16(port[:], uint16(p.Port))
+	buf.Write(port[:])
+	var flags byte
+	if p.Origin {
+		flags |= _originFlag
+	}
+	if p.Complete {
+		flags |= _completeFlag
+	}
+	buf.WriteByte(flags)
+	return nil
+}
+
+func readPeer(rd *bytes.Reader) (*core.PeerInfo, error) {
+	var peerID core.PeerID
+	if _, err := io.ReadFull(rd, peerID[:]); err != nil {
+		return nil, errors.New("peer id truncated")
+	}
+	tag, err := rd.ReadByte()
+	if err != nil {
+		return nil, errors.New("missing ip")
+	}
+	var ip string
+	switch tag {
+	case _hostnameTag:
+		l, err := readCount(rd, 1)
+		if err != nil {
+			return nil, fmt.Errorf("hostname: %s", err)
+		}
+		b := make([]byte, l)
+		if _, err := io.ReadFull(rd, b); err != nil {
+			return nil, errors.New("hostname truncated")
+		}
+		ip = string(b)
+	case _ipv4Tag, _ipv6Tag:
+		b := make([]byte, tag)
+		if _, err := io.ReadFull(rd, b); err != nil {
+			return nil, errors.New("ip truncated")
+		}
+		ip = net.IP(b).String()
+	default:
+		return nil, fmt.Errorf("invalid ip tag %d", tag)
+	}
+	var port [2]byte
+	if _, err := io.ReadFull(rd, port[:]); err != nil {
+		return nil, errors.New("port truncated")
+	}
+	flags, err := rd.ReadByte()
+	if err != nil {
+		return nil, errors.New("missing flags")
+	}
+	return core.NewPeerInfo(
+		peerID,
+		ip,
+		int(binary.BigEndian.Uint16(port[:])),
+		flags&_originFlag != 0,
+		flags&_completeFlag != 0), nil
+}
+
+// readCount reads a uvarint count of elements which take at least minLen bytes
+// each, which bounds allocations for corrupt counts.
+func readCount(rd *bytes.Reader, minLen int) (int, error) {
+	n, err := binary.ReadUvarint(rd)
+	if err != nil {
+		return 0, errors.New("invalid count")
+	}
+	if n > uint64(rd.Len()/minLen) {
+		return 0, fmt.Errorf("count %d exceeds remaining %d bytes", n, rd.Len())
+	}
+	return int(n), nil
+}
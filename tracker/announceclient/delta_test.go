@@ -0,0 +1,141 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package announceclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeltaResponseBinaryRoundTrip(t *testing.T) {
+	tests := []struct {
+		desc string
+		resp DeltaResponse
+	}{
+		{"empty", DeltaResponse{Added: []*core.PeerInfo{}, Removed: []core.PeerID{}}},
+		{
+			"full",
+			DeltaResponse{
+				Full:     true,
+				Token:    1<<63 + 5,
+				Interval: 3 * time.Second,
+				Added: []*core.PeerInfo{
+					core.NewPeerInfo(core.PeerIDFixture(), "10.0.0.1", 8080, false, false),
+					core.NewPeerInfo(core.PeerIDFixture(), "fd00::1", 8080, false, true),
+					core.NewPeerInfo(core.PeerIDFixture(), "origin.example.com", 15002, true, true),
+				},
+				Removed: []core.PeerID{},
+			},
+		}, {
+			"delta",
+			DeltaResponse{
+				Token:    42,
+				Interval: 1500 * time.Millisecond,
+				Added:    []*core.PeerInfo{core.PeerInfoFixture()},
+				Removed:  []core.PeerID{core.PeerIDFixture(), core.PeerIDFixture()},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			b, err := test.resp.MarshalBinary()
+			require.NoError(err)
+
+			var result DeltaResponse
+			require.NoError(result.UnmarshalBinary(b))
+			require.Equal(test.resp, result)
+		})
+	}
+}
+
+func TestDeltaResponseIsCompact(t *testing.T) {
+	peers := make([]*core.PeerInfo, 50)
+	for i := range peers {
+		peers[i] = core.PeerInfoFixture()
+	}
+	b, err := (&DeltaResponse{Added: peers}).MarshalBinary()
+	require.NoError(t, err)
+
+	// Peer id, tagged ipv4 address, port and flags.
+	require.True(t, len(b) < 50*(20+1+4+2+1)+16)
+}
+
+func TestDeltaResponseUnmarshalBinaryErrors(t *testing.T) {
+	b, err := (&DeltaResponse{
+		Token:   1,
+		Added:   []*core.PeerInfo{core.PeerInfoFixture()},
+		Removed: []core.PeerID{core.PeerIDFixture()},
+	}).MarshalBinary()
+	require.NoError(t, err)
+
+	tests := []struct {
+		desc string
+		b    []byte
+	}{
+		{"empty", nil},
+		{"truncated token", b[:5]},
+		{"truncated peer", b[:len(b)-30]},
+		{"truncated removed", b[:len(b)-1]},
+		{"trailing bytes", append(append([]byte{}, b...), 0)},
+		{"count too large", []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 100}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var resp DeltaResponse
+			require.Error(t, resp.UnmarshalBinary(test.b))
+		})
+	}
+}
+
+func TestDeltaStateApply(t *testing.T) {
+	require := require.New(t)
+
+	s := newDeltaState()
+	h := core.InfoHashFixture()
+
+	p1 := core.PeerInfoFixture()
+	p2 := core.PeerInfoFixture()
+	p3 := core.PeerInfoFixture()
+
+	require.Equal(uint64(0), s.token(h))
+	require.Equal(
+		[]*core.PeerInfo{p1, p2},
+		s.apply(h, 0, &DeltaResponse{Full: true, Token: 1, Added: []*core.PeerInfo{p1, p2}}))
+	require.Equal(uint64(1), s.token(h))
+
+	// Added peers come first, and changed peers replace their previous version.
+	p2Complete := *p2
+	p2Complete.Complete = true
+	require.Equal(
+		[]*core.PeerInfo{p3, &p2Complete},
+		s.apply(h, 1, &DeltaResponse{
+			Token:   2,
+			Added:   []*core.PeerInfo{p3, &p2Complete},
+			Removed: []core.PeerID{p1.PeerID},
+		}))
+	require.Equal(uint64(2), s.token(h))
+
+	// Deltas relative to an unknown handout reset the token.
+	s.apply(h, 5, &DeltaResponse{Token: 3})
+	require.Equal(uint64(0), s.token(h))
+
+	s.remove(h)
+	require.Equal(uint64(0), s.token(h))
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/uber/kraken/metrics"
 	"github.com/uber/kraken/nginx"
 	"github.com/uber/kraken/origin/blobclient"
+	"github.com/uber/kraken/tracing"
 	"github.com/uber/kraken/tracker/originstore"
 	"github.com/uber/kraken/tracker/peerhandoutpolicy"
 	"github.com/uber/kraken/tracker/peerstore"
@@ -121,7 +122,13 @@ func Run(flags *Flags, opts ...Option) {
 
 	go metrics.EmitVersion(stats)
 
-	peerStore, err := peerstore.New(config.PeerStore)
+	tracingCloser, err := tracing.Init(config.Tracing, "kraken-tracker")
+	if err != nil {
+		log.Fatalf("Failed to init tracing: %s", err)
+	}
+	defer tracingCloser.Close()
+
+	peerStore, err := peerstore.New(config.PeerStore, stats)
 	if err != nil {
 		log.Fatalf("Could not create PeerStore: %s", err)
 	}
@@ -132,7 +139,9 @@ func Run(flags *Flags, opts ...Option) {
 		log.Fatalf("Error building client tls config: %s", err)
 	}
 
-	origins, err := config.Origin.Build(upstream.WithHealthCheck(healthcheck.Default(tls)))
+	origins, err := config.Origin.Build(
+		upstream.WithHealthCheck(healthcheck.Default(tls)),
+		upstream.WithStats(stats.SubScope("origin")))
 	if err != nil {
 		log.Fatalf("Error building origin host list: %s", err)
 	}
@@ -19,6 +19,7 @@ import (
 	"github.com/uber/kraken/lib/upstream"
 	"github.com/uber/kraken/metrics"
 	"github.com/uber/kraken/nginx"
+	"github.com/uber/kraken/tracing"
 	"github.com/uber/kraken/tracker/originstore"
 	"github.com/uber/kraken/tracker/peerhandoutpolicy"
 	"github.com/uber/kraken/tracker/peerstore"
@@ -35,6 +36,7 @@ type Config struct {
 	PeerHandoutPolicy peerhandoutpolicy.Config `yaml:"peerhandoutpolicy"`
 	Origin            upstream.ActiveConfig    `yaml:"origin"`
 	Metrics           metrics.Config           `yaml:"metrics"`
+	Tracing           tracing.Config           `yaml:"tracing"`
 	Nginx             nginx.Config             `yaml:"nginx"`
 	TLS               httputil.TLSConfig       `yaml:"tls"`
 }
@@ -40,8 +40,14 @@ func (c *LocalConfig) applyDefaults() {
 // RedisConfig defines RedisStore configuration.
 // TODO(evelynl94): rename
 type RedisConfig struct {
-	Enabled           bool          `yaml:"enabled"`
-	Addr              string        `yaml:"addr"`
+	Enabled bool   `yaml:"enabled"`
+	Addr    string `yaml:"addr"`
+
+	// ClusterAddrs are the seed nodes of a Redis Cluster. If set, peer sets are
+	// sharded across the cluster by info hash and Addr is ignored. Connection
+	// pool settings apply to each node.
+	ClusterAddrs []string `yaml:"cluster_addrs"`
+
 	DialTimeout       time.Duration `yaml:"dial_timeout"`
 	ReadTimeout       time.Duration `yaml:"read_timeout"`
 	WriteTimeout      time.Duration `yaml:"write_timeout"`
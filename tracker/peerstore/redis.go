@@ -25,9 +25,17 @@ import (
 
 	"github.com/andres-erbsen/clock"
 	"github.com/garyburd/redigo/redis"
+	"github.com/uber-go/tally"
 )
 
-func peerSetKey(h core.InfoHash, window int64) string {
+// peerSetKey returns the key of the peer set of h in window. In cluster mode,
+// the info hash is used as the hash tag, such that all windows of a torrent are
+// stored on the same Redis Cluster node. Single node stores keep the untagged
+// format, so existing peer sets remain readable.
+func peerSetKey(h core.InfoHash, window int64, cluster bool) string {
+	if cluster {
+		return fmt.Sprintf("peerset:{%s}:%d", h.String(), window)
+	}
 	return fmt.Sprintf("peerset:%s:%d", h.String(), window)
 }
 
@@ -46,71 +54,94 @@ type peerIdentity struct {
 }
 
 func deserializePeer(s string) (id peerIdentity, complete bool, err error) {
-	parts := strings.Split(s, ":")
-	if len(parts) != 4 {
-		return id, false, fmt.Errorf("invalid peer encoding: expected 'pid:ip:port:complete'")
+	// Ipv6 addresses contain colons, so the peer id and complete bit are split
+	// from either end and the port from the end of the remainder.
+	errInvalid := fmt.Errorf("invalid peer encoding: expected 'pid:ip:port:complete'")
+	first := strings.Index(s, ":")
+	last := strings.LastIndex(s, ":")
+	if first < 0 || first == last {
+		return id, false, errInvalid
+	}
+	addr := s[first+1 : last]
+	i := strings.LastIndex(addr, ":")
+	if i < 0 {
+		return id, false, errInvalid
 	}
-	peerID, err := core.NewPeerID(parts[0])
+	peerID, err := core.NewPeerID(s[:first])
 	if err != nil {
 		return id, false, fmt.Errorf("parse peer id: %s", err)
 	}
-	ip := parts[1]
-	port, err := strconv.Atoi(parts[2])
+	ip := addr[:i]
+	if ip == "" {
+		return id, false, errInvalid
+	}
+	port, err := strconv.Atoi(addr[i+1:])
 	if err != nil {
 		return id, false, fmt.Errorf("parse port: %s", err)
 	}
 	id = peerIdentity{peerID, ip, port}
-	complete = parts[3] == "1"
+	complete = s[last+1:] == "1"
 	return id, complete, nil
 }
 
-// RedisStore is a Store backed by Redis.
+// RedisStore is a Store backed by Redis. If RedisConfig.ClusterAddrs is set,
+// peer sets are sharded across a Redis Cluster by info hash.
 type RedisStore struct {
 	config RedisConfig
-	pool   *redis.Pool
+	client redisClient
+	stats  tally.Scope
 	clk    clock.Clock
 }
 
 // NewRedisStore creates a new RedisStore.
-func NewRedisStore(config RedisConfig, clk clock.Clock) (*RedisStore, error) {
+func NewRedisStore(config RedisConfig, stats tally.Scope, clk clock.Clock) (*RedisStore, error) {
 	config.applyDefaults()
 
-	if config.Addr == "" {
-		return nil, errors.New("invalid config: missing addr")
-	}
+	stats = stats.Tagged(map[string]string{
+		"module": "peerstore",
+	})
 
-	s := &RedisStore{
-		config: config,
-		pool: &redis.Pool{
-			Dial: func() (redis.Conn, error) {
-				// TODO Add options
-				return redis.Dial(
-					"tcp",
-					config.Addr,
-					redis.DialConnectTimeout(config.DialTimeout),
-					redis.DialReadTimeout(config.ReadTimeout),
-					redis.DialWriteTimeout(config.WriteTimeout))
-			},
-			MaxIdle:     config.MaxIdleConns,
-			MaxActive:   config.MaxActiveConns,
-			IdleTimeout: config.IdleConnTimeout,
-			Wait:        true,
-		},
-		clk: clk,
-	}
+	var client redisClient
+	if len(config.ClusterAddrs) > 0 {
+		c, err := newClusterClient(config)
+		if err != nil {
+			return nil, fmt.Errorf("new cluster client: %s", err)
+		}
+		client = c
+	} else {
+		if config.Addr == "" {
+			return nil, errors.New("invalid config: missing addr")
+		}
+		pool := newRedisPool(config.Addr, config)
 
-	// Ensure we can connect to Redis.
-	c, err := s.pool.Dial()
-	if err != nil {
-		return nil, fmt.Errorf("dial redis: %s", err)
+		// Ensure we can connect to Redis.
+		c, err := pool.Dial()
+		if err != nil {
+			return nil, fmt.Errorf("dial redis: %s", err)
+		}
+		c.Close()
+
+		client = &poolClient{pool}
 	}
-	c.Close()
 
-	return s, nil
+	return &RedisStore{
+		config: config,
+		client: client,
+		stats:  stats,
+		clk:    clk,
+	}, nil
 }
 
 // Close implements Store.
-func (s *RedisStore) Close() {}
+func (s *RedisStore) Close() {
+	if err := s.client.close(); err != nil {
+		log.Errorf("Error closing redis client: %s", err)
+	}
+}
+
+func (s *RedisStore) peerSetKey(h core.InfoHash, window int64) string {
+	return peerSetKey(h, window, len(s.config.ClusterAddrs) > 0)
+}
 
 func (s *RedisStore) curPeerSetWindow() int64 {
 	t := s.clk.Now().Unix()
@@ -128,37 +159,43 @@ func (s *RedisStore) peerSetWindows() []int64 {
 
 // UpdatePeer writes p to Redis with a TTL.
 func (s *RedisStore) UpdatePeer(h core.InfoHash, p *core.PeerInfo) error {
-	c := s.pool.Get()
-	defer c.Close()
+	timer := s.stats.Timer("update_peer").Start()
+	defer timer.Stop()
 
 	w := s.curPeerSetWindow()
 	expireAt := w + int64(s.config.PeerSetWindowSize.Seconds())*int64(s.config.MaxPeerSetWindows)
 
 	// Add p to the current window.
-	k := peerSetKey(h, w)
+	k := s.peerSetKey(h, w)
 
-	if err := c.Send("SADD", k, serializePeer(p)); err != nil {
-		return fmt.Errorf("send SADD: %s", err)
-	}
-	if err := c.Send("EXPIREAT", k, expireAt); err != nil {
-		return fmt.Errorf("send EXPIREAT: %s", err)
-	}
-	if err := c.Flush(); err != nil {
-		return fmt.Errorf("flush: %s", err)
-	}
-	if _, err := c.Receive(); err != nil {
-		return fmt.Errorf("SADD: %s", err)
-	}
-	if _, err := c.Receive(); err != nil {
-		return fmt.Errorf("EXPIREAT: %s", err)
+	err := s.client.do(k, func(c redis.Conn) error {
+		if err := c.Send("SADD", k, serializePeer(p)); err != nil {
+			return fmt.Errorf("send SADD: %w", err)
+		}
+		if err := c.Send("EXPIREAT", k, expireAt); err != nil {
+			return fmt.Errorf("send EXPIREAT: %w", err)
+		}
+		if err := c.Flush(); err != nil {
+			return fmt.Errorf("flush: %w", err)
+		}
+		if _, err := c.Receive(); err != nil {
+			return fmt.Errorf("SADD: %w", err)
+		}
+		if _, err := c.Receive(); err != nil {
+			return fmt.Errorf("EXPIREAT: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		s.stats.Counter("update_peer_errors").Inc(1)
 	}
-	return nil
+	return err
 }
 
 // GetPeers returns at most n PeerInfos associated with h.
 func (s *RedisStore) GetPeers(h core.InfoHash, n int) ([]*core.PeerInfo, error) {
-	c := s.pool.Get()
-	defer c.Close()
+	timer := s.stats.Timer("get_peers").Start()
+	defer timer.Stop()
 
 	// Try to sample n peers from each window in randomized order until we have
 	// collected n distinct peers. This achieves random sampling across multiple
@@ -173,11 +210,17 @@ func (s *RedisStore) GetPeers(h core.InfoHash, n int) ([]*core.PeerInfo, error)
 	selected := make(map[peerIdentity]bool)
 
 	for i := 0; len(selected) < n && i < len(windows); i++ {
-		k := peerSetKey(h, windows[i])
-		result, err := redis.Strings(c.Do("SRANDMEMBER", k, n-len(selected)))
+		k := s.peerSetKey(h, windows[i])
+		var result []string
+		err := s.client.do(k, func(c redis.Conn) error {
+			var err error
+			result, err = redis.Strings(c.Do("SRANDMEMBER", k, n-len(selected)))
+			return err
+		})
 		if err == redis.ErrNil {
 			continue
 		} else if err != nil {
+			s.stats.Counter("get_peers_errors").Inc(1)
 			return nil, err
 		}
 		for _, s := range result {
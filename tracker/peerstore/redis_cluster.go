@@ -0,0 +1,327 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package peerstore
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/uber/kraken/utils/errutil"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// _numSlots is the number of hash slots keys are sharded across in Redis
+// Cluster.
+const _numSlots = 16384
+
+// _maxRedirects is the maximum number of MOVED / ASK redirections followed for
+// a single command.
+const _maxRedirects = 3
+
+// redisClient provides connections to the Redis node which owns a key.
+type redisClient interface {
+	// do runs f with a connection to the node owning key. f must return any
+	// Redis errors it encounters, so that redirections may be followed.
+	do(key string, f func(redis.Conn) error) error
+	close() error
+}
+
+func newRedisPool(addr string, config RedisConfig) *redis.Pool {
+	return &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial(
+				"tcp",
+				addr,
+				redis.DialConnectTimeout(config.DialTimeout),
+				redis.DialReadTimeout(config.ReadTimeout),
+				redis.DialWriteTimeout(config.WriteTimeout))
+		},
+		MaxIdle:     config.MaxIdleConns,
+		MaxActive:   config.MaxActiveConns,
+		IdleTimeout: config.IdleConnTimeout,
+		Wait:        true,
+	}
+}
+
+// poolClient is a redisClient for a single Redis node.
+type poolClient struct {
+	pool *redis.Pool
+}
+
+func (c *poolClient) do(key string, f func(redis.Conn) error) error {
+	conn := c.pool.Get()
+	defer conn.Close()
+
+	return f(conn)
+}
+
+func (c *poolClient) close() error {
+	return c.pool.Close()
+}
+
+// slotRange is a range of hash slots owned by a single node.
+type slotRange struct {
+	start, end int
+	addr       string
+}
+
+// clusterClient is a redisClient for Redis Cluster. It maintains a connection
+// pool per node and routes each key to the node owning its hash slot, following
+// MOVED and ASK redirections when slots are migrated between nodes.
+type clusterClient struct {
+	config RedisConfig
+
+	mu    sync.RWMutex // Protects the following fields:
+	slots [_numSlots]string
+	pools map[string]*redis.Pool
+}
+
+func newClusterClient(config RedisConfig) (*clusterClient, error) {
+	c := &clusterClient{
+		config: config,
+		pools:  make(map[string]*redis.Pool),
+	}
+	if err := c.refresh(); err != nil {
+		c.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// refresh reloads the slot mapping from the first node which responds to
+// CLUSTER SLOTS.
+func (c *clusterClient) refresh() error {
+	var errs []error
+	for _, addr := range c.config.ClusterAddrs {
+		ranges, err := c.clusterSlots(addr)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", addr, err))
+			continue
+		}
+		c.mu.Lock()
+		for i := range c.slots {
+			c.slots[i] = ""
+		}
+		for _, r := range ranges {
+			for i := r.start; i <= r.end; i++ {
+				c.slots[i] = r.addr
+			}
+		}
+		c.mu.Unlock()
+		return nil
+	}
+	return fmt.Errorf("cluster slots: %s", errutil.Join(errs))
+}
+
+func (c *clusterClient) clusterSlots(addr string) ([]slotRange, error) {
+	conn := c.pool(addr).Get()
+	defer conn.Close()
+
+	reply, err := conn.Do("CLUSTER", "SLOTS")
+	if err != nil {
+		return nil, err
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("parse addr: %s", err)
+	}
+	return parseClusterSlots(reply, host)
+}
+
+// parseClusterSlots parses the reply of CLUSTER SLOTS into the ranges served by
+// each master. Masters which report an empty ip are assumed to be defaultHost.
+func parseClusterSlots(reply interface{}, defaultHost string) ([]slotRange, error) {
+	entries, err := redis.Values(reply, nil)
+	if err != nil {
+		return nil, err
+	}
+	var ranges []slotRange
+	for _, e := range entries {
+		fields, err := redis.Values(e, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) < 3 {
+			return nil, errors.New("invalid slot range: expected start, end and master")
+		}
+		start, err := redis.Int(fields[0], nil)
+		if err != nil {
+			return nil, fmt.Errorf("parse start: %s", err)
+		}
+		end, err := redis.Int(fields[1], nil)
+		if err != nil {
+			return nil, fmt.Errorf("parse end: %s", err)
+		}
+		if start < 0 || end >= _numSlots || start > end {
+			return nil, fmt.Errorf("invalid slot range %d-%d", start, end)
+		}
+		master, err := redis.Values(fields[2], nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(master) < 2 {
+			return nil, errors.New("invalid master: expected ip and port")
+		}
+		host, err := redis.String(master[0], nil)
+		if err != nil {
+			return nil, fmt.Errorf("parse ip: %s", err)
+		}
+		if host == "" {
+			host = defaultHost
+		}
+		port, err := redis.Int(master[1], nil)
+		if err != nil {
+			return nil, fmt.Errorf("parse port: %s", err)
+		}
+		ranges = append(ranges, slotRange{start, end, net.JoinHostPort(host, strconv.Itoa(port))})
+	}
+	return ranges, nil
+}
+
+func (c *clusterClient) pool(addr string) *redis.Pool {
+	c.mu.RLock()
+	p, ok := c.pools[addr]
+	c.mu.RUnlock()
+	if ok {
+		return p
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if p, ok := c.pools[addr]; ok {
+		return p
+	}
+	p = newRedisPool(addr, c.config)
+	c.pools[addr] = p
+	return p
+}
+
+// nodeAddr returns the address of the node owning slot. Unassigned slots are
+// sent to a seed node, which will redirect as necessary.
+func (c *clusterClient) nodeAddr(slot int) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if addr := c.slots[slot]; addr != "" {
+		return addr
+	}
+	return c.config.ClusterAddrs[0]
+}
+
+func (c *clusterClient) setNodeAddr(slot int, addr string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.slots[slot] = addr
+}
+
+func (c *clusterClient) do(key string, f func(redis.Conn) error) error {
+	addr := c.nodeAddr(keySlot(key))
+	var asking bool
+	for i := 0; ; i++ {
+		err := c.doNode(addr, asking, f)
+		r, ok := parseRedirect(err)
+		if !ok || i == _maxRedirects {
+			return err
+		}
+		if !r.ask {
+			// The slot has been permanently moved.
+			c.setNodeAddr(r.slot, r.addr)
+		}
+		addr, asking = r.addr, r.ask
+	}
+}
+
+func (c *clusterClient) doNode(addr string, asking bool, f func(redis.Conn) error) error {
+	conn := c.pool(addr).Get()
+	defer conn.Close()
+
+	if asking {
+		if _, err := conn.Do("ASKING"); err != nil {
+			return fmt.Errorf("ASKING: %s", err)
+		}
+	}
+	return f(conn)
+}
+
+func (c *clusterClient) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+	for _, p := range c.pools {
+		if err := p.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errutil.Join(errs)
+}
+
+// redirect is a MOVED or ASK redirection returned by a cluster node.
+type redirect struct {
+	ask  bool
+	slot int
+	addr string
+}
+
+// parseRedirect parses errors of the form "MOVED <slot> <addr>" or
+// "ASK <slot> <addr>".
+func parseRedirect(err error) (redirect, bool) {
+	var rerr redis.Error
+	if !errors.As(err, &rerr) {
+		return redirect{}, false
+	}
+	parts := strings.Fields(string(rerr))
+	if len(parts) != 3 || (parts[0] != "MOVED" && parts[0] != "ASK") {
+		return redirect{}, false
+	}
+	slot, err := strconv.Atoi(parts[1])
+	if err != nil || slot < 0 || slot >= _numSlots {
+		return redirect{}, false
+	}
+	return redirect{parts[0] == "ASK", slot, parts[2]}, true
+}
+
+// keySlot returns the hash slot of key. If key contains a non-empty hash tag,
+// i.e. a substring enclosed in braces, only the hash tag is hashed.
+func keySlot(key string) int {
+	if i := strings.IndexByte(key, '{'); i >= 0 {
+		if j := strings.IndexByte(key[i+1:], '}'); j > 0 {
+			key = key[i+1 : i+1+j]
+		}
+	}
+	return int(crc16(key) % _numSlots)
+}
+
+// crc16 implements the CRC16-CCITT (XMODEM) checksum used by Redis Cluster.
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,227 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package peerstore
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+
+	"github.com/alicebob/miniredis"
+	"github.com/alicebob/miniredis/server"
+	"github.com/andres-erbsen/clock"
+	"github.com/garyburd/redigo/redis"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+// fakeClusterNode returns a seed node which answers CLUSTER SLOTS with the
+// result of slots, and redirects peer set commands to the node owning the slot
+// of their key according to owner.
+func fakeClusterNode(
+	t *testing.T, slots func() []slotRange, owner func(slot int) string) *server.Server {
+
+	s, err := server.NewServer("127.0.0.1:0")
+	require.NoError(t, err)
+
+	s.Register("CLUSTER", func(c *server.Peer, cmd string, args []string) {
+		ranges := slots()
+		c.WriteLen(len(ranges))
+		for _, r := range ranges {
+			host, port, err := net.SplitHostPort(r.addr)
+			require.NoError(t, err)
+			p, err := strconv.Atoi(port)
+			require.NoError(t, err)
+			c.WriteLen(3)
+			c.WriteInt(r.start)
+			c.WriteInt(r.end)
+			c.WriteLen(2)
+			c.WriteBulk(host)
+			c.WriteInt(p)
+		}
+	})
+	for _, cmd := range []string{"SADD", "EXPIREAT", "SRANDMEMBER"} {
+		s.Register(cmd, func(c *server.Peer, cmd string, args []string) {
+			slot := keySlot(args[0])
+			c.WriteError(fmt.Sprintf("MOVED %d %s", slot, owner(slot)))
+		})
+	}
+	return s
+}
+
+func clusterNodesFixture(t *testing.T) (nodes [2]*miniredis.Miniredis, owner func(slot int) string) {
+	for i := range nodes {
+		m, err := miniredis.Run()
+		require.NoError(t, err)
+		nodes[i] = m
+	}
+	owner = func(slot int) string {
+		if slot < _numSlots/2 {
+			return nodes[0].Addr()
+		}
+		return nodes[1].Addr()
+	}
+	return nodes, owner
+}
+
+func redisClusterConfigFixture(seed string) RedisConfig {
+	return RedisConfig{
+		ClusterAddrs:      []string{seed},
+		PeerSetWindowSize: 30 * time.Second,
+		MaxPeerSetWindows: 4,
+	}
+}
+
+func requireClusterStoreRoundTrips(t *testing.T, s *RedisStore) []core.InfoHash {
+	var hashes []core.InfoHash
+	for i := 0; i < 20; i++ {
+		h := core.InfoHashFixture()
+		hashes = append(hashes, h)
+
+		p := core.PeerInfoFixture()
+		require.NoError(t, s.UpdatePeer(h, p))
+
+		peers, err := s.GetPeers(h, 1)
+		require.NoError(t, err)
+		require.Equal(t, []*core.PeerInfo{p}, peers)
+	}
+	return hashes
+}
+
+func TestRedisClusterStoreShardsByInfoHash(t *testing.T) {
+	require := require.New(t)
+
+	nodes, owner := clusterNodesFixture(t)
+	seed := fakeClusterNode(t, func() []slotRange {
+		return []slotRange{
+			{0, _numSlots/2 - 1, nodes[0].Addr()},
+			{_numSlots / 2, _numSlots - 1, nodes[1].Addr()},
+		}
+	}, owner)
+	defer seed.Close()
+
+	s, err := NewRedisStore(
+		redisClusterConfigFixture(seed.Addr().String()), tally.NoopScope, clock.New())
+	require.NoError(err)
+	defer s.Close()
+
+	requireClusterStoreRoundTrips(t, s)
+
+	for _, m := range nodes {
+		require.NotEmpty(m.Keys())
+		for _, k := range m.Keys() {
+			require.Equal(m.Addr(), owner(keySlot(k)))
+		}
+	}
+}
+
+func TestRedisClusterStoreFollowsMovedRedirects(t *testing.T) {
+	require := require.New(t)
+
+	_, owner := clusterNodesFixture(t)
+	var seed *server.Server
+	seed = fakeClusterNode(t, func() []slotRange {
+		// Stale mapping: the seed claims to own every slot.
+		return []slotRange{{0, _numSlots - 1, seed.Addr().String()}}
+	}, owner)
+	defer seed.Close()
+
+	s, err := NewRedisStore(
+		redisClusterConfigFixture(seed.Addr().String()), tally.NoopScope, clock.New())
+	require.NoError(err)
+	defer s.Close()
+
+	hashes := requireClusterStoreRoundTrips(t, s)
+
+	client := s.client.(*clusterClient)
+	for _, h := range hashes {
+		slot := keySlot(peerSetKey(h, 0, true))
+		require.Equal(owner(slot), client.nodeAddr(slot))
+	}
+}
+
+func TestRedisClusterStoreUnreachableSeeds(t *testing.T) {
+	config := redisClusterConfigFixture("127.0.0.1:0")
+	config.DialTimeout = time.Second
+
+	_, err := NewRedisStore(config, tally.NoopScope, clock.New())
+	require.Error(t, err)
+}
+
+func TestKeySlot(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(uint16(0x31C3), crc16("123456789"))
+	require.Equal(12182, keySlot("foo"))
+	require.Equal(keySlot("bar"), keySlot("foo{bar}baz"))
+	require.Equal(keySlot("{user}.following"), keySlot("{user}.followers"))
+
+	// Empty hash tags are ignored.
+	require.Equal(int(crc16("foo{}{bar}")%_numSlots), keySlot("foo{}{bar}"))
+
+	// All windows of a torrent share a slot.
+	h := core.InfoHashFixture()
+	require.Equal(keySlot(peerSetKey(h, 0, true)), keySlot(peerSetKey(h, 3600, true)))
+}
+
+func TestParseRedirect(t *testing.T) {
+	tests := []struct {
+		desc     string
+		err      error
+		expected redirect
+		ok       bool
+	}{
+		{"moved", redis.Error("MOVED 3999 10.0.0.1:6379"), redirect{false, 3999, "10.0.0.1:6379"}, true},
+		{"ask", redis.Error("ASK 3999 10.0.0.1:6379"), redirect{true, 3999, "10.0.0.1:6379"}, true},
+		{"wrapped", fmt.Errorf("SADD: %w", redis.Error("MOVED 1 a:1")), redirect{false, 1, "a:1"}, true},
+		{"nil", nil, redirect{}, false},
+		{"other error", redis.Error("ERR some error"), redirect{}, false},
+		{"invalid slot", redis.Error("MOVED 16384 a:1"), redirect{}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			r, ok := parseRedirect(test.err)
+			require.Equal(t, test.ok, ok)
+			require.Equal(t, test.expected, r)
+		})
+	}
+}
+
+func TestParseClusterSlots(t *testing.T) {
+	require := require.New(t)
+
+	reply := []interface{}{
+		[]interface{}{int64(0), int64(8191), []interface{}{[]byte("10.0.0.1"), int64(6379), []byte("id1")}},
+		[]interface{}{
+			int64(8192), int64(16383),
+			[]interface{}{[]byte(""), int64(6380), []byte("id2")},
+			[]interface{}{[]byte("10.0.0.3"), int64(6379), []byte("id3")},
+		},
+	}
+	ranges, err := parseClusterSlots(reply, "10.0.0.2")
+	require.NoError(err)
+	require.Equal([]slotRange{
+		{0, 8191, "10.0.0.1:6379"},
+		{8192, 16383, "10.0.0.2:6380"},
+	}, ranges)
+
+	_, err = parseClusterSlots([]interface{}{
+		[]interface{}{int64(0), int64(16384), []interface{}{[]byte("10.0.0.1"), int64(6379)}},
+	}, "")
+	require.Error(err)
+}
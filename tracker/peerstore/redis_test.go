@@ -22,6 +22,7 @@ import (
 	"github.com/alicebob/miniredis"
 	"github.com/andres-erbsen/clock"
 	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
 )
 
 func redisConfigFixture() RedisConfig {
@@ -41,7 +42,7 @@ func TestRedisStoreGetPeersPopulatesPeerInfoFields(t *testing.T) {
 
 	config := redisConfigFixture()
 
-	s, err := NewRedisStore(config, clock.New())
+	s, err := NewRedisStore(config, tally.NoopScope, clock.New())
 	require.NoError(err)
 
 	h := core.InfoHashFixture()
@@ -56,6 +57,64 @@ func TestRedisStoreGetPeersPopulatesPeerInfoFields(t *testing.T) {
 	require.Equal(peers, []*core.PeerInfo{p})
 }
 
+func TestRedisStoreIPv6Peers(t *testing.T) {
+	require := require.New(t)
+
+	s, err := NewRedisStore(redisConfigFixture(), tally.NoopScope, clock.New())
+	require.NoError(err)
+
+	h := core.InfoHashFixture()
+
+	p := core.PeerInfoFixture()
+	p.IP = "fd00::1"
+
+	require.NoError(s.UpdatePeer(h, p))
+
+	peers, err := s.GetPeers(h, 1)
+	require.NoError(err)
+	require.Equal(peers, []*core.PeerInfo{p})
+}
+
+func TestDeserializePeer(t *testing.T) {
+	pid := core.PeerIDFixture()
+	tests := []struct {
+		desc     string
+		s        string
+		ip       string
+		port     int
+		complete bool
+	}{
+		{"ipv4", pid.String() + ":10.0.0.1:80:1", "10.0.0.1", 80, true},
+		{"ipv6", pid.String() + ":fd00::1:80:0", "fd00::1", 80, false},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			id, complete, err := deserializePeer(test.s)
+			require.NoError(t, err)
+			require.Equal(t, peerIdentity{pid, test.ip, test.port}, id)
+			require.Equal(t, test.complete, complete)
+		})
+	}
+}
+
+func TestDeserializePeerErrors(t *testing.T) {
+	pid := core.PeerIDFixture().String()
+	for _, s := range []string{"", pid, pid + ":1", pid + ":80:1", pid + ":10.0.0.1:x:1", "x:10.0.0.1:80:1"} {
+		t.Run(s, func(t *testing.T) {
+			_, _, err := deserializePeer(s)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestPeerSetKey(t *testing.T) {
+	h := core.InfoHashFixture()
+
+	// Single node keys keep the format predating cluster support.
+	require.Equal(t, "peerset:"+h.String()+":60", peerSetKey(h, 60, false))
+	require.Equal(t, "peerset:{"+h.String()+"}:60", peerSetKey(h, 60, true))
+}
+
 func TestRedisStoreGetPeersFromMultipleWindows(t *testing.T) {
 	require := require.New(t)
 
@@ -66,7 +125,7 @@ func TestRedisStoreGetPeersFromMultipleWindows(t *testing.T) {
 	clk := clock.NewMock()
 	clk.Set(time.Now())
 
-	s, err := NewRedisStore(config, clk)
+	s, err := NewRedisStore(config, tally.NoopScope, clk)
 	require.NoError(err)
 
 	// Reset time to the beginning of a window.
@@ -101,7 +160,7 @@ func TestRedisStoreGetPeersLimit(t *testing.T) {
 	clk := clock.NewMock()
 	clk.Set(time.Now())
 
-	s, err := NewRedisStore(config, clk)
+	s, err := NewRedisStore(config, tally.NoopScope, clk)
 	require.NoError(err)
 
 	// Reset time to the beginning of a window.
@@ -132,7 +191,7 @@ func TestRedisStoreGetPeersCollapsesCompleteBits(t *testing.T) {
 
 	config := redisConfigFixture()
 
-	s, err := NewRedisStore(config, clock.New())
+	s, err := NewRedisStore(config, tally.NoopScope, clock.New())
 	require.NoError(err)
 
 	h := core.InfoHashFixture()
@@ -161,7 +220,7 @@ func TestRedisStorePeerExpiration(t *testing.T) {
 	config.PeerSetWindowSize = time.Second
 	config.MaxPeerSetWindows = 2
 
-	s, err := NewRedisStore(config, clock.New())
+	s, err := NewRedisStore(config, tally.NoopScope, clock.New())
 	require.NoError(err)
 
 	h := core.InfoHashFixture()
@@ -17,6 +17,7 @@ import (
 	"fmt"
 
 	"github.com/andres-erbsen/clock"
+	"github.com/uber-go/tally"
 	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/utils/log"
 )
@@ -34,10 +35,10 @@ type Store interface {
 }
 
 // New creates a new Store implementation based on config.
-func New(config Config) (Store, error) {
+func New(config Config, stats tally.Scope) (Store, error) {
 	if config.Redis.Enabled {
 		log.Info("Redis peer store enabled")
-		s, err := NewRedisStore(config.Redis, clock.New())
+		s, err := NewRedisStore(config.Redis, stats, clock.New())
 		if err != nil {
 			return nil, fmt.Errorf("new redis store: %s", err)
 		}
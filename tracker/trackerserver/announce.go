@@ -17,6 +17,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/tracker/announceclient"
@@ -35,7 +36,7 @@ func (s *Server) announceHandlerV1(w http.ResponseWriter, r *http.Request) error
 	if err != nil {
 		return handler.Errorf("get request digest: %s", err)
 	}
-	resp, err := s.announce(d, req.InfoHash, req.Peer)
+	resp, err := s.announce(d, req.InfoHash, req.Peer, req.Probe)
 	if err != nil {
 		return err
 	}
@@ -62,7 +63,7 @@ func (s *Server) announceHandlerV2(w http.ResponseWriter, r *http.Request) error
 	if err != nil {
 		return handler.Errorf("get request digest: %s", err)
 	}
-	resp, err := s.announce(d, h, req.Peer)
+	resp, err := s.announce(d, h, req.Peer, req.Probe)
 	if err != nil {
 		return err
 	}
@@ -72,8 +73,41 @@ func (s *Server) announceHandlerV2(w http.ResponseWriter, r *http.Request) error
 	return nil
 }
 
-func (s *Server) announce(
-	d core.Digest, h core.InfoHash, peer *core.PeerInfo) (*announceclient.Response, error) {
+func (s *Server) announceHandlerV3(w http.ResponseWriter, r *http.Request) error {
+	infohash, err := httputil.ParseParam(r, "infohash")
+	if err != nil {
+		return err
+	}
+	h, err := core.NewInfoHashFromHex(infohash)
+	if err != nil {
+		return fmt.Errorf("parse infohash: %s", err)
+	}
+	req := new(announceclient.Request)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return handler.Errorf("json decode request: %s", err)
+	}
+	d, err := req.GetDigest()
+	if err != nil {
+		return handler.Errorf("get request digest: %s", err)
+	}
+	resp, err := s.announceDelta(d, h, req.Peer, req.Since)
+	if err != nil {
+		return err
+	}
+	b, err := resp.MarshalBinary()
+	if err != nil {
+		return handler.Errorf("encode response: %s", err)
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(b)
+	return nil
+}
+
+func (s *Server) announceDelta(
+	d core.Digest,
+	h core.InfoHash,
+	peer *core.PeerInfo,
+	since uint64) (*announceclient.DeltaResponse, error) {
 
 	if err := s.peerStore.UpdatePeer(h, peer); err != nil {
 		log.With(
@@ -84,6 +118,65 @@ func (s *Server) announce(
 	if err != nil {
 		return nil, err
 	}
+	interval := s.announceInterval(peer, peers)
+	if peer.Complete {
+		s.handouts.remove(h, peer.PeerID)
+		return &announceclient.DeltaResponse{Full: true, Interval: interval}, nil
+	}
+	token, full, added, removed := s.handouts.update(h, peer.PeerID, since, peers)
+	if full {
+		s.stats.Counter("full_handouts").Inc(1)
+	} else {
+		s.stats.Counter("delta_handouts").Inc(1)
+	}
+	return &announceclient.DeltaResponse{
+		Full:     full,
+		Token:    token,
+		Interval: interval,
+		Added:    added,
+		Removed:  removed,
+	}, nil
+}
+
+// announceInterval returns the interval until the next V3 announce of peer
+// given its handout. Leechers back off by AnnounceInterval for every seeder in
+// the handout other than origins, since they depend less on discovering new
+// peers quickly in healthy swarms. Complete peers only announce to remain in the
+// peer store, so they back off fully.
+func (s *Server) announceInterval(peer *core.PeerInfo, handout []*core.PeerInfo) time.Duration {
+	if peer.Complete {
+		return s.config.MaxAnnounceInterval
+	}
+	interval := s.config.AnnounceInterval
+	for _, p := range handout {
+		if p.Complete && !p.Origin {
+			interval += s.config.AnnounceInterval
+		}
+	}
+	if interval > s.config.MaxAnnounceInterval {
+		interval = s.config.MaxAnnounceInterval
+	}
+	return interval
+}
+
+// announce records peer, unless probe is set, and returns its handout.
+func (s *Server) announce(
+	d core.Digest,
+	h core.InfoHash,
+	peer *core.PeerInfo,
+	probe bool) (*announceclient.Response, error) {
+
+	if probe {
+		s.stats.Counter("probes").Inc(1)
+	} else if err := s.peerStore.UpdatePeer(h, peer); err != nil {
+		log.With(
+			"hash", h,
+			"peer_id", peer.PeerID).Errorf("Error updating peer: %s", err)
+	}
+	peers, err := s.getPeerHandout(d, h, peer)
+	if err != nil {
+		return nil, err
+	}
 	return &announceclient.Response{
 		Peers:    peers,
 		Interval: s.config.AnnounceInterval,
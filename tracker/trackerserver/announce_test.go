@@ -27,6 +27,7 @@ import (
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
 )
 
 func newAnnounceClient(pctx core.PeerContext, addr string) announceclient.Client {
@@ -34,7 +35,7 @@ func newAnnounceClient(pctx core.PeerContext, addr string) announceclient.Client
 }
 
 func TestAnnounceSinglePeerResponse(t *testing.T) {
-	for _, version := range []int{announceclient.V1, announceclient.V2} {
+	for _, version := range []int{announceclient.V1, announceclient.V2, announceclient.V3} {
 		t.Run(fmt.Sprintf("V%d", version), func(t *testing.T) {
 			require := require.New(t)
 
@@ -68,6 +69,118 @@ func TestAnnounceSinglePeerResponse(t *testing.T) {
 	}
 }
 
+func TestProbeDoesNotUpdatePeer(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t, Config{})
+	defer cleanup()
+
+	addr, stop := testutil.StartServer(mocks.handler())
+	defer stop()
+
+	blob := core.NewBlobFixture()
+	client := newAnnounceClient(core.PeerContextFixture(), addr)
+
+	peers := []*core.PeerInfo{core.PeerInfoFixture()}
+
+	// No UpdatePeer is expected.
+	mocks.originStore.EXPECT().GetOrigins(blob.Digest).Return(nil, nil)
+	mocks.peerStore.EXPECT().GetPeers(
+		blob.MetaInfo.InfoHash(), gomock.Any()).Return(peers, nil)
+
+	result, err := client.Probe(blob.Digest, blob.MetaInfo.InfoHash())
+	require.NoError(err)
+	require.Equal(peers, result)
+}
+
+func TestAnnounceV3SendsDeltas(t *testing.T) {
+	require := require.New(t)
+
+	mocks, cleanup := newServerMocks(t, Config{})
+	defer cleanup()
+
+	addr, stop := testutil.StartServer(mocks.handler())
+	defer stop()
+
+	blob := core.NewBlobFixture()
+	h := blob.MetaInfo.InfoHash()
+	pctx := core.PeerContextFixture()
+
+	client := newAnnounceClient(pctx, addr)
+
+	p1 := core.PeerInfoFixture()
+	p2 := core.PeerInfoFixture()
+	p3 := core.PeerInfoFixture()
+
+	mocks.originStore.EXPECT().GetOrigins(blob.Digest).Return(nil, nil).Times(2)
+	mocks.peerStore.EXPECT().UpdatePeer(h, core.PeerInfoFromContext(pctx, false)).Return(nil).Times(2)
+	gomock.InOrder(
+		mocks.peerStore.EXPECT().GetPeers(h, gomock.Any()).Return([]*core.PeerInfo{p1, p2}, nil),
+		mocks.peerStore.EXPECT().GetPeers(h, gomock.Any()).Return([]*core.PeerInfo{p2, p3}, nil),
+	)
+
+	result, _, err := client.Announce(blob.Digest, h, false, announceclient.V3)
+	require.NoError(err)
+	require.ElementsMatch([]*core.PeerInfo{p1, p2}, result)
+
+	result, _, err = client.Announce(blob.Digest, h, false, announceclient.V3)
+	require.NoError(err)
+	require.Equal([]*core.PeerInfo{p3, p2}, result)
+
+	counters := mocks.stats.(tally.TestScope).Snapshot().Counters()
+	require.Equal(int64(1), counters["testing.full_handouts+module=trackerserver"].Value())
+	require.Equal(int64(1), counters["testing.delta_handouts+module=trackerserver"].Value())
+}
+
+func TestAnnounceV3IntervalBacksOffWithSeeders(t *testing.T) {
+	config := Config{
+		AnnounceInterval:    time.Second,
+		MaxAnnounceInterval: 3 * time.Second,
+	}
+	seeder := func() *core.PeerInfo {
+		return core.NewPeerInfo(core.PeerIDFixture(), "10.0.0.1", 8080, false, true)
+	}
+	tests := []struct {
+		desc     string
+		complete bool
+		peers    []*core.PeerInfo
+		expected time.Duration
+	}{
+		{"no seeders", false, []*core.PeerInfo{core.PeerInfoFixture()}, time.Second},
+		{"origins do not count", false, []*core.PeerInfo{core.OriginPeerInfoFixture()}, time.Second},
+		{"one seeder", false, []*core.PeerInfo{seeder(), core.PeerInfoFixture()}, 2 * time.Second},
+		{"capped", false, []*core.PeerInfo{seeder(), seeder(), seeder()}, 3 * time.Second},
+		{"complete peer", true, nil, 3 * time.Second},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			require := require.New(t)
+
+			mocks, cleanup := newServerMocks(t, config)
+			defer cleanup()
+
+			addr, stop := testutil.StartServer(mocks.handler())
+			defer stop()
+
+			blob := core.NewBlobFixture()
+			h := blob.MetaInfo.InfoHash()
+			pctx := core.PeerContextFixture()
+
+			mocks.peerStore.EXPECT().UpdatePeer(
+				h, core.PeerInfoFromContext(pctx, test.complete)).Return(nil)
+			if !test.complete {
+				mocks.originStore.EXPECT().GetOrigins(blob.Digest).Return(nil, nil)
+				mocks.peerStore.EXPECT().GetPeers(h, gomock.Any()).Return(test.peers, nil)
+			}
+
+			_, interval, err := newAnnounceClient(pctx, addr).Announce(
+				blob.Digest, h, test.complete, announceclient.V3)
+			require.NoError(err)
+			require.Equal(test.expected, interval)
+		})
+	}
+}
+
 func TestAnnounceUnavailablePeerStoreCanStillProvideOrigins(t *testing.T) {
 	require := require.New(t)
 
@@ -29,6 +29,10 @@ type Config struct {
 
 	AnnounceInterval time.Duration `yaml:"announce_interval"`
 
+	// MaxAnnounceInterval bounds the intervals of V3 announces, which back off
+	// from AnnounceInterval as swarms gain seeders.
+	MaxAnnounceInterval time.Duration `yaml:"max_announce_interval"`
+
 	Listener listener.Config `yaml:"listener"`
 }
 
@@ -42,5 +46,11 @@ func (c Config) applyDefaults() Config {
 	if c.AnnounceInterval == 0 {
 		c.AnnounceInterval = 3 * time.Second
 	}
+	if c.MaxAnnounceInterval == 0 {
+		c.MaxAnnounceInterval = 30 * time.Second
+	}
+	if c.MaxAnnounceInterval < c.AnnounceInterval {
+		c.MaxAnnounceInterval = c.AnnounceInterval
+	}
 	return c
 }
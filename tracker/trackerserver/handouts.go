@@ -0,0 +1,134 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package trackerserver
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/uber/kraken/core"
+
+	"github.com/andres-erbsen/clock"
+)
+
+type handoutKey struct {
+	h      core.InfoHash
+	peerID core.PeerID
+}
+
+type handoutEntry struct {
+	token     uint64
+	peers     map[core.PeerID]core.PeerInfo
+	expiresAt time.Time
+}
+
+// handoutCache remembers the last V3 handout given to each peer of each torrent,
+// such that following announces can be answered with deltas. Entries expire if
+// the peer stops announcing.
+type handoutCache struct {
+	clk clock.Clock
+	ttl time.Duration
+
+	mu        sync.Mutex // Protects the following fields:
+	entries   map[handoutKey]*handoutEntry
+	lastSweep time.Time
+	rand      *rand.Rand
+}
+
+func newHandoutCache(clk clock.Clock, ttl time.Duration) *handoutCache {
+	return &handoutCache{
+		clk:       clk,
+		ttl:       ttl,
+		entries:   make(map[handoutKey]*handoutEntry),
+		lastSweep: clk.Now(),
+		rand:      rand.New(rand.NewSource(clk.Now().UnixNano())),
+	}
+}
+
+// update records peers as the handout of peerID for h. If since identifies the
+// previous handout, the peers which were added / changed and removed since
+// then are returned, with full unset. Otherwise, full is set and added is all
+// peers.
+func (c *handoutCache) update(
+	h core.InfoHash,
+	peerID core.PeerID,
+	since uint64,
+	peers []*core.PeerInfo) (token uint64, full bool, added []*core.PeerInfo, removed []core.PeerID) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clk.Now()
+	c.sweep(now)
+
+	cur := make(map[core.PeerID]core.PeerInfo, len(peers))
+	for _, p := range peers {
+		cur[p.PeerID] = *p
+	}
+
+	k := handoutKey{h, peerID}
+	prev, ok := c.entries[k]
+	if !ok || since == 0 || prev.token != since {
+		full = true
+		added = peers
+	} else {
+		for _, p := range peers {
+			if old, ok := prev.peers[p.PeerID]; !ok || old != *p {
+				added = append(added, p)
+			}
+		}
+		for id := range prev.peers {
+			if _, ok := cur[id]; !ok {
+				removed = append(removed, id)
+			}
+		}
+	}
+
+	// Zero is reserved for peers which have no handout.
+	token = c.rand.Uint64()
+	for token == 0 || (ok && token == prev.token) {
+		token = c.rand.Uint64()
+	}
+	c.entries[k] = &handoutEntry{token, cur, now.Add(c.ttl)}
+	return token, full, added, removed
+}
+
+// remove forgets the handout of peerID for h.
+func (c *handoutCache) remove(h core.InfoHash, peerID core.PeerID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, handoutKey{h, peerID})
+}
+
+func (c *handoutCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+// sweep removes expired entries at most once per ttl.
+func (c *handoutCache) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < c.ttl {
+		return
+	}
+	for k, e := range c.entries {
+		if now.After(e.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	c.lastSweep = now
+}
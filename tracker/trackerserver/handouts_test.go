@@ -0,0 +1,78 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package trackerserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/uber/kraken/core"
+
+	"github.com/andres-erbsen/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandoutCacheDeltas(t *testing.T) {
+	require := require.New(t)
+
+	c := newHandoutCache(clock.NewMock(), time.Minute)
+
+	h := core.InfoHashFixture()
+	peerID := core.PeerIDFixture()
+
+	p1 := core.PeerInfoFixture()
+	p2 := core.PeerInfoFixture()
+
+	token, full, added, removed := c.update(h, peerID, 0, []*core.PeerInfo{p1, p2})
+	require.True(full)
+	require.NotZero(token)
+	require.Equal([]*core.PeerInfo{p1, p2}, added)
+	require.Empty(removed)
+
+	p2Complete := *p2
+	p2Complete.Complete = true
+	token, full, added, removed = c.update(h, peerID, token, []*core.PeerInfo{&p2Complete})
+	require.False(full)
+	require.Equal([]*core.PeerInfo{&p2Complete}, added)
+	require.Equal([]core.PeerID{p1.PeerID}, removed)
+
+	// Unchanged handouts have empty deltas.
+	token, full, added, removed = c.update(h, peerID, token, []*core.PeerInfo{&p2Complete})
+	require.False(full)
+	require.Empty(added)
+	require.Empty(removed)
+
+	// Stale tokens receive full handouts.
+	_, full, added, _ = c.update(h, peerID, token+1, []*core.PeerInfo{p1})
+	require.True(full)
+	require.Equal([]*core.PeerInfo{p1}, added)
+
+	c.remove(h, peerID)
+	require.Equal(0, c.len())
+}
+
+func TestHandoutCacheExpiresIdlePeers(t *testing.T) {
+	require := require.New(t)
+
+	clk := clock.NewMock()
+	c := newHandoutCache(clk, time.Minute)
+
+	h := core.InfoHashFixture()
+	p := []*core.PeerInfo{core.PeerInfoFixture()}
+
+	c.update(h, core.PeerIDFixture(), 0, p)
+	clk.Add(61 * time.Second)
+	c.update(h, core.PeerIDFixture(), 0, p)
+	require.Equal(1, c.len())
+}
@@ -18,11 +18,13 @@ import (
 	"net/http"
 	_ "net/http/pprof" // Registers /debug/pprof endpoints in http.DefaultServeMux.
 
+	"github.com/andres-erbsen/clock"
 	"github.com/go-chi/chi"
 	chimiddleware "github.com/go-chi/chi/middleware"
 	"github.com/uber-go/tally"
 
 	"github.com/uber/kraken/lib/middleware"
+	"github.com/uber/kraken/metrics"
 	"github.com/uber/kraken/origin/blobclient"
 	"github.com/uber/kraken/tracker/originstore"
 	"github.com/uber/kraken/tracker/peerhandoutpolicy"
@@ -42,6 +44,8 @@ type Server struct {
 	policy      *peerhandoutpolicy.PriorityPolicy
 
 	originCluster blobclient.ClusterClient
+
+	handouts *handoutCache
 }
 
 // New creates a new Server.
@@ -66,6 +70,8 @@ func New(
 		originStore:   originStore,
 		policy:        policy,
 		originCluster: originCluster,
+		// Entries outlive a few missed announces at the max interval.
+		handouts: newHandoutCache(clock.New(), 3*config.MaxAnnounceInterval),
 	}
 }
 
@@ -73,16 +79,20 @@ func New(
 func (s *Server) Handler() http.Handler {
 	r := chi.NewRouter()
 
+	r.Use(middleware.Tracing())
 	r.Use(middleware.StatusCounter(s.stats))
 	r.Use(middleware.LatencyTimer(s.stats))
 
 	r.Get("/health", handler.Wrap(s.healthHandler))
 	r.Get("/announce", handler.Wrap(s.announceHandlerV1))
 	r.Post("/announce/{infohash}", handler.Wrap(s.announceHandlerV2))
+	r.Post("/announce/v3/{infohash}", handler.Wrap(s.announceHandlerV3))
 	r.Get("/namespace/{namespace}/blobs/{digest}/metainfo", handler.Wrap(s.getMetaInfoHandler))
 
 	r.Mount("/debug", chimiddleware.Profiler())
 
+	r.Handle(metrics.PrometheusHandlerPath, metrics.PrometheusHandler())
+
 	return r
 }
 
@@ -23,3 +23,17 @@ func FromBools(bs ...bool) *bitset.BitSet {
 	}
 	return s
 }
+
+// String renders b as a string of 0s and 1s, where the i-th character is 1 if
+// bit i is set.
+func String(b *bitset.BitSet) string {
+	s := make([]byte, b.Len())
+	for i := range s {
+		if b.Test(uint(i)) {
+			s[i] = '1'
+		} else {
+			s[i] = '0'
+		}
+	}
+	return string(s)
+}
@@ -15,6 +15,7 @@ package dedup
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/andres-erbsen/clock"
@@ -29,7 +30,7 @@ type IntervalTask interface {
 type IntervalTrap struct {
 	sync.RWMutex
 	clk      clock.Clock
-	interval time.Duration
+	interval int64 // Nanoseconds, accessed atomically.
 	prev     time.Time
 	task     IntervalTask
 }
@@ -40,14 +41,21 @@ func NewIntervalTrap(
 
 	return &IntervalTrap{
 		clk:      clk,
-		interval: interval,
+		interval: int64(interval),
 		prev:     clk.Now(),
 		task:     task,
 	}
 }
 
 func (t *IntervalTrap) ready() bool {
-	return t.clk.Now().After(t.prev.Add(t.interval))
+	interval := time.Duration(atomic.LoadInt64(&t.interval))
+	return t.clk.Now().After(t.prev.Add(interval))
+}
+
+// SetInterval changes the interval at which the task runs, measured from the
+// last task run. Safe to call from within the task itself.
+func (t *IntervalTrap) SetInterval(interval time.Duration) {
+	atomic.StoreInt64(&t.interval, int64(interval))
 }
 
 // Trap quickly checks if the interval has passed since the last task run, and if
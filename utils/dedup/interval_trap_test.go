@@ -54,6 +54,33 @@ func TestIntervalTrap(t *testing.T) {
 	trap.Trap() // Noop.
 }
 
+func TestIntervalTrapSetInterval(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	clk := clock.NewMock()
+	clk.Set(time.Now())
+	task := mockdedup.NewMockIntervalTask(ctrl)
+
+	trap := NewIntervalTrap(time.Minute, clk, task)
+
+	trap.SetInterval(time.Second)
+
+	clk.Add(time.Second + 1)
+	task.EXPECT().Run().Do(func() {
+		// Changing the interval from within the task must not deadlock.
+		trap.SetInterval(time.Hour)
+	})
+	trap.Trap()
+
+	clk.Add(time.Minute + 1)
+	trap.Trap() // Noop.
+
+	clk.Add(time.Hour)
+	task.EXPECT().Run()
+	trap.Trap()
+}
+
 func TestIntervalTrapConcurrency(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
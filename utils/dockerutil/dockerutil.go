@@ -14,33 +14,71 @@
 package dockerutil
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"strings"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/ocischema"
 	"github.com/docker/distribution/manifest/schema2"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/uber/kraken/core"
 )
 
 const _v2ManifestType = "application/vnd.docker.distribution.manifest.v2+json"
 const _v2ManifestListType = "application/vnd.docker.distribution.manifest.list.v2+json"
 
+// ManifestFetcher fetches the manifest identified by d.
+type ManifestFetcher func(d core.Digest) (distribution.Manifest, error)
+
+// ParseManifest parses a v2 manifest, v2 manifest list, OCI image manifest or
+// OCI image index, and returns it along with its digest.
 func ParseManifest(r io.Reader) (distribution.Manifest, core.Digest, error) {
 	b, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, core.Digest{}, fmt.Errorf("read: %s", err)
 	}
 
-	manifest, d, err := ParseManifestV2(b)
-	if err == nil {
-		return manifest, d, err
+	mediaType, err := detectMediaType(b)
+	if err != nil {
+		return nil, core.Digest{}, err
+	}
+	switch mediaType {
+	case schema2.MediaTypeManifest:
+		return ParseManifestV2(b)
+	case manifestlist.MediaTypeManifestList:
+		return ParseManifestV2List(b)
+	case v1.MediaTypeImageManifest:
+		return ParseManifestOCI(b)
+	case v1.MediaTypeImageIndex:
+		return ParseManifestOCIIndex(b)
+	default:
+		return nil, core.Digest{}, fmt.Errorf("unsupported manifest media type: %q", mediaType)
 	}
+}
 
-	// Retry with v2 manifest list.
-	return ParseManifestV2List(b)
+// detectMediaType returns the media type of a raw manifest. The media type is
+// optional in OCI manifests and indexes, in which case indexes are identified
+// by their list of manifests.
+func detectMediaType(b []byte) (string, error) {
+	var m struct {
+		MediaType string          `json:"mediaType"`
+		Manifests json.RawMessage `json:"manifests"`
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return "", fmt.Errorf("unmarshal manifest: %s", err)
+	}
+	if m.MediaType != "" {
+		return m.MediaType, nil
+	}
+	if m.Manifests != nil {
+		return v1.MediaTypeImageIndex, nil
+	}
+	return v1.MediaTypeImageManifest, nil
 }
 
 // ParseManifestV2 returns a parsed v2 manifest and its digest.
@@ -85,6 +123,58 @@ func ParseManifestV2List(bytes []byte) (distribution.Manifest, core.Digest, erro
 	return manifestList, d, nil
 }
 
+// ParseManifestOCI returns a parsed OCI image manifest and its digest.
+func ParseManifestOCI(bytes []byte) (distribution.Manifest, core.Digest, error) {
+	manifest, desc, err := distribution.UnmarshalManifest(v1.MediaTypeImageManifest, bytes)
+	if err != nil {
+		return nil, core.Digest{}, fmt.Errorf("unmarshal oci manifest: %s", err)
+	}
+	deserializedManifest, ok := manifest.(*ocischema.DeserializedManifest)
+	if !ok {
+		return nil, core.Digest{}, errors.New("expected ocischema.DeserializedManifest")
+	}
+	version := deserializedManifest.Manifest.Versioned.SchemaVersion
+	if version != 2 {
+		return nil, core.Digest{}, fmt.Errorf("unsupported oci manifest version: %d", version)
+	}
+	d, err := core.ParseSHA256Digest(string(desc.Digest))
+	if err != nil {
+		return nil, core.Digest{}, fmt.Errorf("parse digest: %s", err)
+	}
+	return manifest, d, nil
+}
+
+// ParseManifestOCIIndex returns a parsed OCI image index and its digest.
+func ParseManifestOCIIndex(bytes []byte) (distribution.Manifest, core.Digest, error) {
+	index, desc, err := distribution.UnmarshalManifest(v1.MediaTypeImageIndex, bytes)
+	if err != nil {
+		return nil, core.Digest{}, fmt.Errorf("unmarshal oci index: %s", err)
+	}
+	deserializedIndex, ok := index.(*manifestlist.DeserializedManifestList)
+	if !ok {
+		return nil, core.Digest{}, errors.New("expected manifestlist.DeserializedManifestList")
+	}
+	version := deserializedIndex.ManifestList.Versioned.SchemaVersion
+	if version != 2 {
+		return nil, core.Digest{}, fmt.Errorf("unsupported oci index version: %d", version)
+	}
+	d, err := core.ParseSHA256Digest(string(desc.Digest))
+	if err != nil {
+		return nil, core.Digest{}, fmt.Errorf("parse digest: %s", err)
+	}
+	return index, d, nil
+}
+
+// IsManifestList returns true if mediaType is a v2 manifest list or OCI image
+// index, i.e. a manifest whose references are other manifests.
+func IsManifestList(mediaType string) bool {
+	return mediaType == manifestlist.MediaTypeManifestList || mediaType == v1.MediaTypeImageIndex
+}
+
+func isManifest(mediaType string) bool {
+	return mediaType == schema2.MediaTypeManifest || mediaType == v1.MediaTypeImageManifest
+}
+
 // GetManifestReferences returns a list of references by a V2 manifest
 func GetManifestReferences(manifest distribution.Manifest) ([]core.Digest, error) {
 	var refs []core.Digest
@@ -98,6 +188,48 @@ func GetManifestReferences(manifest distribution.Manifest) ([]core.Digest, error
 	return refs, nil
 }
 
+// GetAllManifestReferences returns every blob referenced by manifest. The
+// per-platform manifests of manifest lists and OCI image indexes are fetched
+// via fetch and resolved recursively, such that each of their layers is
+// included after the manifest itself. Duplicate references are removed.
+func GetAllManifestReferences(manifest distribution.Manifest, fetch ManifestFetcher) ([]core.Digest, error) {
+	var refs []core.Digest
+	seen := make(map[core.Digest]bool)
+	var visit func(m distribution.Manifest) error
+	visit = func(m distribution.Manifest) error {
+		for _, desc := range m.References() {
+			d, err := core.ParseSHA256Digest(string(desc.Digest))
+			if err != nil {
+				return fmt.Errorf("parse digest: %s", err)
+			}
+			if seen[d] {
+				continue
+			}
+			seen[d] = true
+			if IsManifestList(desc.MediaType) || isManifest(desc.MediaType) {
+				child, err := fetch(d)
+				if err != nil {
+					return fmt.Errorf("fetch manifest %s: %s", d, err)
+				}
+				if err := visit(child); err != nil {
+					return err
+				}
+			}
+			refs = append(refs, d)
+		}
+		return nil
+	}
+	if err := visit(manifest); err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
 func GetSupportedManifestTypes() string {
-	return fmt.Sprintf("%s,%s", _v2ManifestType, _v2ManifestListType)
+	return strings.Join([]string{
+		_v2ManifestType,
+		_v2ManifestListType,
+		v1.MediaTypeImageManifest,
+		v1.MediaTypeImageIndex,
+	}, ",")
 }
@@ -1,10 +1,16 @@
 package dockerutil_test
 
 import (
+	"bytes"
+	"errors"
 	"testing"
 
+	"github.com/docker/distribution"
 	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/require"
+	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/utils/dockerutil"
 )
 
@@ -88,3 +94,141 @@ func TestParseManifestV2List(t *testing.T) {
 		})
 	}
 }
+
+var testOCIManifestBytes = []byte(`{
+	"schemaVersion": 2,
+	"config": {
+	   "mediaType": "application/vnd.oci.image.config.v1+json",
+	   "size": 985,
+	   "digest": "sha256:1a9ec845ee94c202b2d5da74a24f0ed2058318bfa9879fa541efaecba272e86b"
+	},
+	"layers": [
+	   {
+		  "mediaType": "application/vnd.oci.image.layer.v1.tar+gzip",
+		  "size": 153263,
+		  "digest": "sha256:62d8908bee94c202b2d35224a221aaa2058318bfa9879fa541efaecba272331b"
+	   }
+	]
+ }`)
+
+var testOCIIndexBytes = []byte(`{
+	"schemaVersion": 2,
+	"manifests": [
+	   {
+		  "mediaType": "application/vnd.oci.image.manifest.v1+json",
+		  "size": 985,
+		  "digest": "sha256:1a9ec845ee94c202b2d5da74a24f0ed2058318bfa9879fa541efaecba272e86b",
+		  "platform": {
+			 "architecture": "arm64",
+			 "os": "linux"
+		  }
+	   }
+	]
+ }`)
+
+func TestParseManifest(t *testing.T) {
+	tests := []struct {
+		name          string
+		manifestBytes []byte
+		mediaType     string
+	}{
+		{"v2 manifest", testManifestBytes, schema2.MediaTypeManifest},
+		{"v2 manifest list", testManifestListBytes, manifestlist.MediaTypeManifestList},
+		{"oci manifest without media type", testOCIManifestBytes, v1.MediaTypeImageManifest},
+		{"oci index without media type", testOCIIndexBytes, v1.MediaTypeImageIndex},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+
+			manifest, d, err := dockerutil.ParseManifest(bytes.NewReader(tt.manifestBytes))
+			require.NoError(err)
+			mediaType, _, err := manifest.Payload()
+			require.NoError(err)
+			require.Equal(tt.mediaType, mediaType)
+			expected, err := core.NewDigester().FromBytes(tt.manifestBytes)
+			require.NoError(err)
+			require.Equal(expected, d)
+		})
+	}
+}
+
+func TestParseManifestUnsupportedMediaType(t *testing.T) {
+	_, _, err := dockerutil.ParseManifest(bytes.NewReader([]byte(`{"mediaType": "application/json"}`)))
+	require.Error(t, err)
+}
+
+func TestGetAllManifestReferences(t *testing.T) {
+	require := require.New(t)
+
+	layers := core.DigestListFixture(4)
+	amd64, amd64Bytes := dockerutil.ManifestFixture(layers[0], layers[1], layers[2])
+	arm64, arm64Bytes := dockerutil.ManifestFixture(layers[0], layers[1], layers[3])
+	index, indexBytes := dockerutil.ImageIndexFixture(amd64, arm64)
+
+	blobs := map[core.Digest][]byte{amd64: amd64Bytes, arm64: arm64Bytes}
+	fetch := func(d core.Digest) (distribution.Manifest, error) {
+		b, ok := blobs[d]
+		if !ok {
+			return nil, errors.New("not found")
+		}
+		m, _, err := dockerutil.ParseManifest(bytes.NewReader(b))
+		return m, err
+	}
+
+	manifest, d, err := dockerutil.ParseManifest(bytes.NewReader(indexBytes))
+	require.NoError(err)
+	require.Equal(index, d)
+
+	refs, err := dockerutil.GetAllManifestReferences(manifest, fetch)
+	require.NoError(err)
+	require.Equal([]core.Digest{layers[0], layers[1], layers[2], amd64, layers[3], arm64}, refs)
+
+	// Missing platform manifests fail resolution.
+	delete(blobs, arm64)
+	_, err = dockerutil.GetAllManifestReferences(manifest, fetch)
+	require.Error(err)
+}
+
+func TestParseReferrer(t *testing.T) {
+	require := require.New(t)
+
+	subject := core.DigestFixture()
+	d, b := dockerutil.ReferrerManifestFixture(subject, "application/vnd.dev.cosign.artifact.sig.v1+json", core.DigestFixture())
+
+	s, desc, ok, err := dockerutil.ParseReferrer(b)
+	require.NoError(err)
+	require.True(ok)
+	require.Equal(subject, s)
+	require.Equal(dockerutil.Descriptor{
+		MediaType:    v1.MediaTypeImageManifest,
+		ArtifactType: "application/vnd.dev.cosign.artifact.sig.v1+json",
+		Digest:       d,
+		Size:         int64(len(b)),
+	}, desc)
+
+	// Artifacts are parsed like any other OCI manifest.
+	_, parsed, err := dockerutil.ParseManifest(bytes.NewReader(b))
+	require.NoError(err)
+	require.Equal(d, parsed)
+
+	_, _, ok, err = dockerutil.ParseReferrer(testManifestBytes)
+	require.NoError(err)
+	require.False(ok)
+}
+
+func TestReferrersIndex(t *testing.T) {
+	require := require.New(t)
+
+	sig := dockerutil.Descriptor{ArtifactType: "sig", Digest: core.DigestFixture()}
+	sbom := dockerutil.Descriptor{ArtifactType: "sbom", Digest: core.DigestFixture()}
+
+	idx := dockerutil.NewReferrersIndex()
+	require.True(idx.Add(sig))
+	require.True(idx.Add(sbom))
+	require.False(idx.Add(sig))
+
+	require.Equal([]dockerutil.Descriptor{sig, sbom}, idx.Manifests)
+	require.Equal([]dockerutil.Descriptor{sbom}, idx.Filter("sbom").Manifests)
+	require.Empty(idx.Filter("other").Manifests)
+}
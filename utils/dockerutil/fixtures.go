@@ -15,6 +15,7 @@ package dockerutil
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/uber/kraken/core"
 )
@@ -50,3 +51,66 @@ func ManifestFixture(config core.Digest, layer1 core.Digest, layer2 core.Digest)
 
 	return d, raw
 }
+
+// ImageIndexFixture creates an OCI image index blob referencing the given
+// per-platform manifests for testing purposes.
+func ImageIndexFixture(manifests ...core.Digest) (core.Digest, []byte) {
+	var descs []string
+	for _, m := range manifests {
+		descs = append(descs, fmt.Sprintf(`{
+		  "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+		  "size": 528,
+		  "digest": "%s",
+		  "platform": {
+			 "architecture": "amd64",
+			 "os": "linux"
+		  }
+	   }`, m))
+	}
+	raw := []byte(fmt.Sprintf(`{
+	   "schemaVersion": 2,
+	   "mediaType": "application/vnd.oci.image.index.v1+json",
+	   "manifests": [%s]
+	}`, strings.Join(descs, ",")))
+
+	d, err := core.NewDigester().FromBytes(raw)
+	if err != nil {
+		panic(err)
+	}
+
+	return d, raw
+}
+
+// ReferrerManifestFixture creates an OCI artifact manifest blob of artifactType
+// which refers to subject, e.g. a signature, for testing purposes.
+func ReferrerManifestFixture(subject core.Digest, artifactType string, layer core.Digest) (core.Digest, []byte) {
+	raw := []byte(fmt.Sprintf(`{
+	   "schemaVersion": 2,
+	   "mediaType": "application/vnd.oci.image.manifest.v1+json",
+	   "artifactType": "%s",
+	   "config": {
+		  "mediaType": "application/vnd.oci.empty.v1+json",
+		  "size": 2,
+		  "digest": "sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"
+	   },
+	   "layers": [
+		  {
+			 "mediaType": "application/octet-stream",
+			 "size": 1024,
+			 "digest": "%s"
+		  }
+	   ],
+	   "subject": {
+		  "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+		  "size": 528,
+		  "digest": "%s"
+	   }
+	}`, artifactType, layer, subject))
+
+	d, err := core.NewDigester().FromBytes(raw)
+	if err != nil {
+		panic(err)
+	}
+
+	return d, raw
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package dockerutil
+
+import (
+	"encoding/json"
+	"fmt"
+
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/uber/kraken/core"
+)
+
+// Descriptor describes a manifest in a referrers index. It extends the OCI
+// descriptor with the artifact type introduced by OCI 1.1.
+type Descriptor struct {
+	MediaType    string            `json:"mediaType"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Digest       core.Digest       `json:"digest"`
+	Size         int64             `json:"size"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+// ReferrersIndex is an OCI image index listing the manifests which refer to a
+// subject manifest, as served by the OCI referrers API.
+type ReferrersIndex struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Manifests     []Descriptor `json:"manifests"`
+}
+
+// NewReferrersIndex returns an empty ReferrersIndex.
+func NewReferrersIndex() *ReferrersIndex {
+	return &ReferrersIndex{
+		SchemaVersion: 2,
+		MediaType:     v1.MediaTypeImageIndex,
+		Manifests:     []Descriptor{},
+	}
+}
+
+// Add adds desc to idx. Returns false if desc was already present.
+func (idx *ReferrersIndex) Add(desc Descriptor) bool {
+	for _, m := range idx.Manifests {
+		if m.Digest == desc.Digest {
+			return false
+		}
+	}
+	idx.Manifests = append(idx.Manifests, desc)
+	return true
+}
+
+// Filter returns a copy of idx which only includes manifests of artifactType.
+func (idx *ReferrersIndex) Filter(artifactType string) *ReferrersIndex {
+	result := NewReferrersIndex()
+	for _, m := range idx.Manifests {
+		if m.ArtifactType == artifactType {
+			result.Manifests = append(result.Manifests, m)
+		}
+	}
+	return result
+}
+
+// ReferrersTag returns the tag under which the referrers index of subject is
+// stored, following the OCI referrers tag schema. Clients which do not support
+// the referrers API fall back to this tag.
+func ReferrersTag(subject core.Digest) string {
+	return fmt.Sprintf("%s-%s", subject.Algo(), subject.Hex())
+}
+
+// ParseReferrer parses a raw manifest and returns its subject and a descriptor
+// of the manifest itself. Returns false if the manifest has no subject, i.e.
+// it does not refer to another manifest.
+func ParseReferrer(b []byte) (core.Digest, Descriptor, bool, error) {
+	var m struct {
+		MediaType    string `json:"mediaType"`
+		ArtifactType string `json:"artifactType"`
+		Config       struct {
+			MediaType string `json:"mediaType"`
+		} `json:"config"`
+		Subject *struct {
+			Digest core.Digest `json:"digest"`
+		} `json:"subject"`
+		Annotations map[string]string `json:"annotations"`
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return core.Digest{}, Descriptor{}, false, fmt.Errorf("unmarshal manifest: %s", err)
+	}
+	if m.Subject == nil {
+		return core.Digest{}, Descriptor{}, false, nil
+	}
+	d, err := core.NewDigester().FromBytes(b)
+	if err != nil {
+		return core.Digest{}, Descriptor{}, false, fmt.Errorf("digest: %s", err)
+	}
+	mediaType := m.MediaType
+	if mediaType == "" {
+		mediaType = v1.MediaTypeImageManifest
+	}
+	// Per the OCI spec, the config media type is the artifact type of
+	// manifests which do not set one explicitly.
+	artifactType := m.ArtifactType
+	if artifactType == "" {
+		artifactType = m.Config.MediaType
+	}
+	return m.Subject.Digest, Descriptor{
+		MediaType:    mediaType,
+		ArtifactType: artifactType,
+		Digest:       d,
+		Size:         int64(len(b)),
+		Annotations:  m.Annotations,
+	}, true, nil
+}
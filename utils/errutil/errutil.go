@@ -33,6 +33,12 @@ func (e MultiError) Error() string {
 	return b.String()
 }
 
+// Unwrap returns the individual errors contained in e, allowing errors.Is and
+// errors.As to inspect each of them.
+func (e MultiError) Unwrap() []error {
+	return e
+}
+
 // Join converts errs into an error interface.
 func Join(errs []error) error {
 	if errs == nil {
@@ -57,3 +57,13 @@ func TestJoinNonNil(t *testing.T) {
 	}
 	require.Error(t, f())
 }
+
+func TestMultiErrorUnwrap(t *testing.T) {
+	a := errors.New("a")
+	b := errors.New("b")
+
+	err := Join([]error{a, b})
+	require.True(t, errors.Is(err, a))
+	require.True(t, errors.Is(err, b))
+	require.False(t, errors.Is(err, errors.New("c")))
+}
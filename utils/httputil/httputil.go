@@ -28,6 +28,7 @@ import (
 	"github.com/go-chi/chi"
 
 	"github.com/uber/kraken/core"
+	"github.com/uber/kraken/tracing"
 	"github.com/uber/kraken/utils/handler"
 )
 
@@ -273,7 +274,7 @@ func SendContext(ctx context.Context) SendOption {
 }
 
 // Send sends an HTTP request. May return NetworkError or StatusError (see above).
-func Send(method, rawurl string, options ...SendOption) (*http.Response, error) {
+func Send(method, rawurl string, options ...SendOption) (resp *http.Response, err error) {
 	u, err := url.Parse(rawurl)
 	if err != nil {
 		return nil, fmt.Errorf("parse url: %s", err)
@@ -293,6 +294,10 @@ func Send(method, rawurl string, options ...SendOption) (*http.Response, error)
 		o(opts)
 	}
 
+	if span := startClientSpan(method, opts); span != nil {
+		defer func() { endClientSpan(span, resp, err) }()
+	}
+
 	req, err := newRequest(method, opts)
 	if err != nil {
 		return nil, err
@@ -304,7 +309,6 @@ func Send(method, rawurl string, options ...SendOption) (*http.Response, error)
 		Transport:     opts.transport,
 	}
 
-	var resp *http.Response
 	for {
 		resp, err = client.Do(req)
 		// Retry without tls. During migration there would be a time when the
@@ -434,6 +438,43 @@ func ParseDigest(r *http.Request, name string) (core.Digest, error) {
 	return d, nil
 }
 
+// startClientSpan starts a client span for requests whose context carries a
+// span, and propagates it to the server via the traceparent header. Requests
+// without a traced context are not traced, to avoid root spans for background
+// requests such as health checks.
+func startClientSpan(method string, opts *sendOptions) *tracing.Span {
+	if _, ok := tracing.SpanContextFromContext(opts.ctx); !ok {
+		return nil
+	}
+	ctx, span := tracing.Start(
+		opts.ctx,
+		method,
+		tracing.WithKind(tracing.KindClient),
+		tracing.WithAttribute("http.method", method),
+		tracing.WithAttribute("http.url", opts.url.Scheme+"://"+opts.url.Host+opts.url.Path))
+	if span == nil {
+		return nil
+	}
+	opts.ctx = ctx
+	headers := make(map[string]string, len(opts.headers)+1)
+	for k, v := range opts.headers {
+		headers[k] = v
+	}
+	headers[tracing.TraceparentHeader] = tracing.FormatTraceparent(span.Context())
+	opts.headers = headers
+	return span
+}
+
+func endClientSpan(span *tracing.Span, resp *http.Response, err error) {
+	if serr, ok := err.(StatusError); ok {
+		span.SetAttribute("http.status_code", serr.Status)
+	} else if resp != nil {
+		span.SetAttribute("http.status_code", resp.StatusCode)
+	}
+	span.SetError(err)
+	span.End()
+}
+
 func newRequest(method string, opts *sendOptions) (*http.Request, error) {
 	req, err := http.NewRequest(method, opts.url.String(), opts.body)
 	if err != nil {
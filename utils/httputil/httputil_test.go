@@ -29,6 +29,7 @@ import (
 
 	"github.com/uber/kraken/core"
 	"github.com/uber/kraken/mocks/utils/httputil"
+	"github.com/uber/kraken/tracing"
 )
 
 const _testURL = "http://localhost:0/test"
@@ -313,3 +314,38 @@ func TestParseDigestInvalid(t *testing.T) {
 	_, err := ParseDigest(r, "digest")
 	require.Error(err)
 }
+
+func TestSendTracing(t *testing.T) {
+	require := require.New(t)
+
+	tracer, recorder := tracing.TracerFixture()
+	tracing.SetGlobal(tracer)
+	defer tracing.SetGlobal(nil)
+
+	var traceparents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents = append(traceparents, r.Header.Get(tracing.TraceparentHeader))
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	// Requests without a traced context are not traced.
+	_, err := Get(server.URL + "/test")
+	require.True(IsNotFound(err))
+	require.Equal([]string{""}, traceparents)
+	require.Empty(recorder.Spans())
+
+	headers := map[string]string{"foo": "bar"}
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	_, err = Get(server.URL+"/test?x=y", SendContext(ctx), SendHeaders(headers))
+	require.True(IsNotFound(err))
+	require.Equal(map[string]string{"foo": "bar"}, headers)
+
+	spans := recorder.Spans()
+	require.Len(spans, 1)
+	require.Equal(parent.Context().SpanID, spans[0].Parent())
+	require.Equal(tracing.FormatTraceparent(spans[0].Context()), traceparents[1])
+	require.Equal(server.URL+"/test", spans[0].Attribute("http.url"))
+	require.Equal("404", spans[0].Attribute("http.status_code"))
+	require.Error(spans[0].Err())
+}
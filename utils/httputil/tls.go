@@ -22,6 +22,10 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/uber/kraken/utils/log"
 )
@@ -29,6 +33,8 @@ import (
 // ErrEmptyCommonName is returned when common name is not provided for key generation.
 var ErrEmptyCommonName = errors.New("empty common name")
 
+const _defaultReloadInterval = 30 * time.Second
+
 // TLSConfig defines TLS configuration.
 type TLSConfig struct {
 	Name   string   `yaml:"name"`
@@ -36,8 +42,29 @@ type TLSConfig struct {
 	Client X509Pair `yaml:"client"`
 	CAs    []Secret `yaml:"cas"`
 
+	// MutualTLS requires servers to verify client certificates on every
+	// request, rather than only on mutating requests.
+	MutualTLS bool `yaml:"mutual_tls"`
+
+	// ReloadInterval is how often certificate and CA files are checked for
+	// changes. Changed certificates and CAs are reloaded without restarts,
+	// which allows short-lived certificates and trust bundles to be rotated on
+	// disk.
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+
 	// Lazy init.
-	tls *tls.Config
+	tls    *tls.Config
+	server *tls.Config
+	cas    *caReloader
+}
+
+// GetReloadInterval returns the interval at which certificate files are
+// checked for changes.
+func (c *TLSConfig) GetReloadInterval() time.Duration {
+	if c.ReloadInterval == 0 {
+		return _defaultReloadInterval
+	}
+	return c.ReloadInterval
 }
 
 // X509Pair contains x509 cert configuration.
@@ -64,38 +91,303 @@ func (c *TLSConfig) BuildClient() (*tls.Config, error) {
 		return c.tls, nil
 	}
 
-	var caPool *x509.CertPool
-	var certs []tls.Certificate
-	var err error
-	if len(c.CAs) > 0 {
-		caPool, err = createCertPool(c.CAs)
-		if err != nil {
-			return nil, fmt.Errorf("create cert pool: %s", err)
-		}
+	cas, err := c.getCAReloader()
+	if err != nil {
+		return nil, err
+	}
+	c.tls = &tls.Config{
+		ServerName:               c.Name,
+		PreferServerCipherSuites: true,
+		InsecureSkipVerify:       false, // This is important to enforce verification of server.
+	}
+	if cas != nil {
+		// The server is verified against the current CAs in VerifyConnection
+		// instead, since RootCAs cannot change once the config is in use.
+		c.tls.RootCAs = cas.get()
+		c.tls.InsecureSkipVerify = true
+		c.tls.VerifyConnection = cas.verifyServer
 	}
 	if c.Client.Cert.Path != "" {
-		certPEM, err := parseCert(c.Client.Cert.Path)
+		r, err := newCertReloader(c.Client, c.GetReloadInterval())
 		if err != nil {
-			return nil, fmt.Errorf("parse client cert: %s", err)
+			return nil, fmt.Errorf("client cert: %s", err)
+		}
+		c.tls.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.get(), nil
 		}
-		keyPEM, err := parseKey(c.Client.Key.Path, c.Client.Passphrase.Path)
+	}
+	return c.tls, nil
+}
+
+// BuildServer builds tls.Config for http servers which terminate TLS
+// themselves rather than behind nginx. Client certificates are verified
+// against the current CAs if given. Since loopback clients are exempt from MutualTLS, it
+// does not require certificates in the handshake, so servers must enforce it
+// with middleware.RequireClientCert.
+func (c *TLSConfig) BuildServer() (*tls.Config, error) {
+	if c.Server.Disabled {
+		log.Infof("Server TLS is disabled")
+		return nil, nil
+	}
+	if c.server != nil {
+		return c.server, nil
+	}
+	r, err := newCertReloader(c.Server, c.GetReloadInterval())
+	if err != nil {
+		return nil, fmt.Errorf("server cert: %s", err)
+	}
+	cas, err := c.getCAReloader()
+	if err != nil {
+		return nil, err
+	}
+	if cas == nil && c.MutualTLS {
+		return nil, errors.New("mutual tls requires cas")
+	}
+	c.server = &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return r.get(), nil
+		},
+		ClientAuth:               tls.VerifyClientCertIfGiven,
+		PreferServerCipherSuites: true,
+	}
+	if cas != nil {
+		c.server.ClientCAs = cas.get()
+		c.server.GetConfigForClient = cas.configWithClientCAs(c.server.Clone())
+	}
+	return c.server, nil
+}
+
+// CurrentCAs returns the pool of CAs as of their last reload, or nil if no CAs
+// are configured. Only valid after BuildClient or BuildServer.
+func (c *TLSConfig) CurrentCAs() *x509.CertPool {
+	if c.cas == nil {
+		return nil
+	}
+	return c.cas.get()
+}
+
+// getCAReloader returns the reloader of the CAs, shared by client and server
+// configs. Nil if no CAs are configured.
+func (c *TLSConfig) getCAReloader() (*caReloader, error) {
+	if len(c.CAs) == 0 {
+		return nil, nil
+	}
+	if c.cas == nil {
+		r, err := newCAReloader(c.CAs, c.GetReloadInterval())
 		if err != nil {
-			return nil, fmt.Errorf("parse client key: %s", err)
+			return nil, fmt.Errorf("cas: %s", err)
 		}
-		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		c.cas = r
+	}
+	return c.cas, nil
+}
+
+// ServerFilesVersion returns a version of the server certificate and CA
+// files, which changes whenever any of them is modified.
+func (c *TLSConfig) ServerFilesVersion() (string, error) {
+	paths := []string{c.Server.Cert.Path, c.Server.Key.Path, c.Server.Passphrase.Path}
+	for _, s := range c.CAs {
+		paths = append(paths, s.Path)
+	}
+	return filesVersion(paths)
+}
+
+// filesVersion returns a version of the files at paths, derived from their
+// sizes and modification times. Empty paths are ignored.
+func filesVersion(paths []string) (string, error) {
+	var parts []string
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		info, err := os.Stat(p)
 		if err != nil {
-			return nil, fmt.Errorf("load client x509 key pair: %s", err)
+			return "", err
 		}
-		certs = []tls.Certificate{cert}
+		parts = append(parts, fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano()))
 	}
-	c.tls = &tls.Config{
-		Certificates:             certs,
-		RootCAs:                  caPool,
-		ServerName:               c.Name,
-		PreferServerCipherSuites: true,
-		InsecureSkipVerify:       false, // This is important to enforce verification of server.
+	return strings.Join(parts, ","), nil
+}
+
+// certReloader serves the certificate of an X509Pair, reloading it from disk
+// when its files change.
+type certReloader struct {
+	pair     X509Pair
+	interval time.Duration
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	version   string
+	lastCheck time.Time
+}
+
+func newCertReloader(pair X509Pair, interval time.Duration) (*certReloader, error) {
+	r := &certReloader{pair: pair, interval: interval}
+	version, err := r.filesVersion()
+	if err != nil {
+		return nil, fmt.Errorf("stat: %s", err)
+	}
+	cert, err := loadX509Pair(pair)
+	if err != nil {
+		return nil, err
+	}
+	r.cert = cert
+	r.version = version
+	r.lastCheck = time.Now()
+	return r, nil
+}
+
+func (r *certReloader) filesVersion() (string, error) {
+	return filesVersion([]string{r.pair.Cert.Path, r.pair.Key.Path, r.pair.Passphrase.Path})
+}
+
+// get returns the current certificate. Files are checked for changes at most
+// once per interval. If a changed certificate fails to load, e.g. because its
+// files are only partially written, the previous certificate is kept and
+// loading is retried on the next check.
+func (r *certReloader) get() *tls.Certificate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastCheck) < r.interval {
+		return r.cert
+	}
+	r.lastCheck = time.Now()
+	version, err := r.filesVersion()
+	if err != nil {
+		log.With("cert", r.pair.Cert.Path).Errorf("Error checking certificate files: %s", err)
+		return r.cert
+	}
+	if version == r.version {
+		return r.cert
+	}
+	cert, err := loadX509Pair(r.pair)
+	if err != nil {
+		log.With("cert", r.pair.Cert.Path).Errorf("Error reloading certificate: %s", err)
+		return r.cert
+	}
+	log.With("cert", r.pair.Cert.Path).Info("Reloaded certificate")
+	r.cert = cert
+	r.version = version
+	return r.cert
+}
+
+// loadX509Pair reads and parses the certificate and key of pair.
+func loadX509Pair(pair X509Pair) (*tls.Certificate, error) {
+	certPEM, err := parseCert(pair.Cert.Path)
+	if err != nil {
+		return nil, fmt.Errorf("parse cert: %s", err)
+	}
+	keyPEM, err := parseKey(pair.Key.Path, pair.Passphrase.Path)
+	if err != nil {
+		return nil, fmt.Errorf("parse key: %s", err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("load x509 key pair: %s", err)
+	}
+	return &cert, nil
+}
+
+// caReloader serves a pool of CAs, reloading it from disk when any of the CA
+// files change.
+type caReloader struct {
+	secrets  []Secret
+	interval time.Duration
+
+	mu        sync.Mutex
+	pool      *x509.CertPool
+	version   string
+	lastCheck time.Time
+}
+
+func newCAReloader(secrets []Secret, interval time.Duration) (*caReloader, error) {
+	r := &caReloader{secrets: secrets, interval: interval}
+	version, err := r.filesVersion()
+	if err != nil {
+		return nil, fmt.Errorf("stat: %s", err)
+	}
+	pool, err := createCertPool(secrets)
+	if err != nil {
+		return nil, fmt.Errorf("create cert pool: %s", err)
+	}
+	r.pool = pool
+	r.version = version
+	r.lastCheck = time.Now()
+	return r, nil
+}
+
+func (r *caReloader) filesVersion() (string, error) {
+	var paths []string
+	for _, s := range r.secrets {
+		paths = append(paths, s.Path)
+	}
+	return filesVersion(paths)
+}
+
+// get returns the current pool. Like certReloader.get, files are checked for
+// changes at most once per interval, and the previous pool is kept if the
+// changed files fail to load.
+func (r *caReloader) get() *x509.CertPool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastCheck) < r.interval {
+		return r.pool
+	}
+	r.lastCheck = time.Now()
+	version, err := r.filesVersion()
+	if err != nil {
+		log.Errorf("Error checking CA files: %s", err)
+		return r.pool
+	}
+	if version == r.version {
+		return r.pool
+	}
+	pool, err := createCertPool(r.secrets)
+	if err != nil {
+		log.Errorf("Error reloading CAs: %s", err)
+		return r.pool
+	}
+	log.Info("Reloaded CAs")
+	r.pool = pool
+	r.version = version
+	return r.pool
+}
+
+// verifyServer verifies the certificate chain and name of a server against the
+// current pool. Connections without a server name are rejected, since the
+// name cannot be verified otherwise.
+func (r *caReloader) verifyServer(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("no server certificate")
+	}
+	if cs.ServerName == "" {
+		return errors.New("no server name to verify")
+	}
+	opts := x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         r.get(),
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// configWithClientCAs returns a tls.Config.GetConfigForClient callback which
+// returns copies of base verifying client certificates against the current
+// pool.
+func (r *caReloader) configWithClientCAs(
+	base *tls.Config) func(*tls.ClientHelloInfo) (*tls.Config, error) {
+
+	return func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		config := base.Clone()
+		config.ClientCAs = r.get()
+		return config, nil
 	}
-	return c.tls, nil
 }
 
 // WriteCABundle writes a list of CA to a writer.
@@ -22,8 +22,11 @@ import (
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"fmt"
+	"io/ioutil"
 	"math/big"
+	"net"
 	"net/http"
+	"os"
 	"testing"
 	"time"
 
@@ -46,6 +49,7 @@ func genKeyPair(t *testing.T, caPEM, caKeyPEM, caSercret []byte) (certPEM, keyPE
 			Organization: []string{"kraken"},
 			CommonName:   "kraken",
 		},
+		DNSNames:  []string{"kraken"},
 		NotBefore: time.Now().Add(-5 * time.Minute),
 		NotAfter:  time.Now().Add(time.Hour * 24 * 180),
 
@@ -253,3 +257,156 @@ func TestTLSClientFallbackError(t *testing.T) {
 	_, err = Get("https://some-non-existent-addr/", SendTLS(tls))
 	require.Error(err)
 }
+
+// rotateCert overwrites the client cert, key and passphrase of c with a new
+// key pair signed by the same CA. Returns the new cert.
+func rotateCert(t *testing.T, c *TLSConfig, caPEM, caKeyPEM, caSecret []byte) []byte {
+	require := require.New(t)
+
+	certPEM, keyPEM, secret := genKeyPair(t, caPEM, caKeyPEM, caSecret)
+	mtime := time.Now().Add(time.Minute)
+	for p, b := range map[string][]byte{
+		c.Client.Cert.Path:       certPEM,
+		c.Client.Key.Path:        keyPEM,
+		c.Client.Passphrase.Path: secret,
+	} {
+		require.NoError(ioutil.WriteFile(p, b, 0644))
+		require.NoError(os.Chtimes(p, mtime, mtime))
+	}
+	return certPEM
+}
+
+func TestTLSClientCertReload(t *testing.T) {
+	require := require.New(t)
+
+	caPEM, caKeyPEM, caSecret := genKeyPair(t, nil, nil, nil)
+	certPEM, keyPEM, secret := genKeyPair(t, caPEM, caKeyPEM, caSecret)
+
+	var cleanup testutil.Cleanup
+	defer cleanup.Run()
+
+	c := &TLSConfig{ReloadInterval: time.Nanosecond}
+	for _, x := range []struct {
+		secret *Secret
+		b      []byte
+	}{
+		{&c.Client.Cert, certPEM},
+		{&c.Client.Key, keyPEM},
+		{&c.Client.Passphrase, secret},
+	} {
+		p, f := testutil.TempFile(x.b)
+		cleanup.Add(f)
+		x.secret.Path = p
+	}
+
+	config, err := c.BuildClient()
+	require.NoError(err)
+
+	leaf := func() []byte {
+		cert, err := config.GetClientCertificate(nil)
+		require.NoError(err)
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	}
+	require.Equal(certPEM, leaf())
+
+	rotated := rotateCert(t, c, caPEM, caKeyPEM, caSecret)
+	require.Equal(rotated, leaf())
+
+	// A partially written pair keeps the previous cert until it is complete.
+	require.NoError(ioutil.WriteFile(c.Client.Key.Path, []byte("partial"), 0644))
+	require.Equal(rotated, leaf())
+
+	rotated = rotateCert(t, c, caPEM, caKeyPEM, caSecret)
+	require.Equal(rotated, leaf())
+}
+
+// handshake runs a TLS handshake between client and server over an in-memory
+// connection, returning the first error of either side.
+func handshake(client, server *tls.Config) error {
+	cc, sc := net.Pipe()
+	defer sc.Close()
+	errc := make(chan error, 1)
+	go func() { errc <- tls.Server(sc, server).Handshake() }()
+	err := tls.Client(cc, client).Handshake()
+	cc.Close()
+	if serr := <-errc; err == nil {
+		err = serr
+	}
+	return err
+}
+
+func TestTLSCAReload(t *testing.T) {
+	require := require.New(t)
+
+	oldCA, _, _ := genKeyPair(t, nil, nil, nil)
+	newCA, newCAKey, newCASecret := genKeyPair(t, nil, nil, nil)
+	certPEM, keyPEM, secret := genKeyPair(t, newCA, newCAKey, newCASecret)
+
+	var cleanup testutil.Cleanup
+	defer cleanup.Run()
+
+	tempFile := func(b []byte) string {
+		p, f := testutil.TempFile(b)
+		cleanup.Add(f)
+		return p
+	}
+	pair := X509Pair{
+		Cert:       Secret{tempFile(certPEM)},
+		Key:        Secret{tempFile(keyPEM)},
+		Passphrase: Secret{tempFile(secret)},
+	}
+	c := &TLSConfig{
+		Name:           "kraken",
+		Server:         pair,
+		Client:         pair,
+		CAs:            []Secret{{tempFile(oldCA)}},
+		ReloadInterval: time.Nanosecond,
+	}
+	client, err := c.BuildClient()
+	require.NoError(err)
+	server, err := c.BuildServer()
+	require.NoError(err)
+
+	cert, err := loadX509Pair(pair)
+	require.NoError(err)
+	// Peers which do not verify, such that each side is checked alone.
+	plainServer := &tls.Config{Certificates: []tls.Certificate{*cert}}
+	plainClient := &tls.Config{Certificates: []tls.Certificate{*cert}, InsecureSkipVerify: true}
+
+	require.Error(handshake(client, plainServer))
+	require.Error(handshake(plainClient, server))
+
+	// Trust bundle is rotated to the CA which signed the certificate.
+	mtime := time.Now().Add(time.Minute)
+	require.NoError(ioutil.WriteFile(c.CAs[0].Path, newCA, 0644))
+	require.NoError(os.Chtimes(c.CAs[0].Path, mtime, mtime))
+
+	require.NoError(handshake(client, plainServer))
+	require.NoError(handshake(plainClient, server))
+	require.NoError(handshake(client, server))
+
+	// Without a server name, the server cannot be verified.
+	noName := client.Clone()
+	noName.ServerName = ""
+	require.Error(handshake(noName, plainServer))
+}
+
+func TestTLSServerMutualTLS(t *testing.T) {
+	require := require.New(t)
+
+	c, cleanup := genCerts(t)
+	defer cleanup()
+
+	c.Server = c.Client
+	c.MutualTLS = true
+
+	config, err := c.BuildServer()
+	require.NoError(err)
+	require.Equal(tls.VerifyClientCertIfGiven, config.ClientAuth)
+	_, err = config.GetCertificate(nil)
+	require.NoError(err)
+
+	noCAs := &TLSConfig{Server: c.Client, MutualTLS: true}
+	_, err = noCAs.BuildServer()
+	require.Error(err)
+}
@@ -14,9 +14,11 @@
 package netutil
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"net"
+	"sort"
 	"time"
 )
 
@@ -64,41 +66,164 @@ func GetIP(host string) (net.IP, error) {
 	return nil, errors.New("no ips found")
 }
 
-// GetLocalIP returns the ip address of the local machine.
-func GetLocalIP() (string, error) {
+// Address orderings which may be used in WithAddrOrdering.
+const (
+	// OrderFirst selects the first eligible address of an interface, in the
+	// order reported by the operating system.
+	OrderFirst = "first"
+
+	// OrderLowest selects the numerically lowest eligible address of an
+	// interface, which is stable regardless of the order in which the
+	// operating system reports addresses.
+	OrderLowest = "lowest"
+)
+
+// localIPOptions configures GetLocalIP.
+type localIPOptions struct {
+	interfaces    []string
+	anyInterface  bool
+	ordering      string
+	ipv6Fallback  bool
+	getInterfaces func() ([]localInterface, error)
+}
+
+// LocalIPOption configures GetLocalIP.
+type LocalIPOption func(*localIPOptions)
+
+// WithPreferredInterfaces overrides the ordered list of interfaces from which
+// the local ip is determined, which defaults to eth0 then ib0.
+func WithPreferredInterfaces(names ...string) LocalIPOption {
+	return func(o *localIPOptions) { o.interfaces = names }
+}
+
+// WithAnyInterface falls back to the remaining interfaces, sorted by name, if
+// none of the preferred interfaces has an eligible address.
+func WithAnyInterface() LocalIPOption {
+	return func(o *localIPOptions) { o.anyInterface = true }
+}
+
+// WithAddrOrdering sets how an address is chosen when an interface has
+// several eligible addresses. Must be OrderFirst (the default) or OrderLowest.
+func WithAddrOrdering(ordering string) LocalIPOption {
+	return func(o *localIPOptions) { o.ordering = ordering }
+}
+
+// WithIPv6Fallback selects a global unicast ipv6 address if no interface has
+// an eligible ipv4 address, e.g. on ipv6-only hosts. Since ipv4 is checked
+// across all interfaces first, dual-stack hosts are unaffected.
+func WithIPv6Fallback() LocalIPOption {
+	return func(o *localIPOptions) { o.ipv6Fallback = true }
+}
+
+// localInterface is the subset of net.Interface used by GetLocalIP, for
+// testing.
+type localInterface struct {
+	name  string
+	addrs []net.Addr
+}
+
+func getLocalInterfaces() ([]localInterface, error) {
 	ifaces, err := net.Interfaces()
 	if err != nil {
-		return "", fmt.Errorf("interfaces: %s", err)
+		return nil, fmt.Errorf("interfaces: %s", err)
 	}
-	ips := map[string]string{}
+	var result []localInterface
 	for _, i := range ifaces {
 		addrs, err := i.Addrs()
 		if err != nil {
-			return "", fmt.Errorf("addrs: %s", err)
+			return nil, fmt.Errorf("addrs: %s", err)
 		}
-		for _, addr := range addrs {
-			var ip net.IP
-			switch v := addr.(type) {
-			case *net.IPNet:
-				ip = v.IP
-			case *net.IPAddr:
-				ip = v.IP
-			}
-			if ip == nil || ip.IsLoopback() {
-				continue
-			}
-			ip = ip.To4()
-			if ip == nil {
-				continue
-			}
-			ips[i.Name] = ip.String()
-			break
+		result = append(result, localInterface{i.Name, addrs})
+	}
+	return result, nil
+}
+
+// GetLocalIP returns the ipv4 address of the local machine, selected from the
+// first preferred interface with a non-loopback ipv4 address (or, with
+// WithIPv6Fallback, a global unicast ipv6 address if there is none). Since peers
+// identify nodes by this address, the selection is deterministic for a given
+// set of interfaces and options.
+func GetLocalIP(opts ...LocalIPOption) (string, error) {
+	o := localIPOptions{
+		interfaces:    _supportedInterfaces,
+		ordering:      OrderFirst,
+		getInterfaces: getLocalInterfaces,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.ordering != OrderFirst && o.ordering != OrderLowest {
+		return "", fmt.Errorf("invalid addr ordering %q", o.ordering)
+	}
+	ifaces, err := o.getInterfaces()
+	if err != nil {
+		return "", err
+	}
+	ip, err := selectInterfaceIP(ifaces, o, false)
+	if err != nil && o.ipv6Fallback {
+		return selectInterfaceIP(ifaces, o, true)
+	}
+	return ip, err
+}
+
+// selectInterfaceIP selects the ip of the first preferred interface with an
+// eligible address of the given family.
+func selectInterfaceIP(ifaces []localInterface, o localIPOptions, ipv6 bool) (string, error) {
+	ips := map[string]string{}
+	for _, i := range ifaces {
+		if ip := selectIP(i.addrs, o.ordering, ipv6); ip != nil {
+			ips[i.name] = ip.String()
 		}
 	}
-	for _, i := range _supportedInterfaces {
+	for _, i := range o.interfaces {
 		if ip, ok := ips[i]; ok {
 			return ip, nil
 		}
 	}
+	if o.anyInterface {
+		var names []string
+		for name := range ips {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) > 0 {
+			return ips[names[0]], nil
+		}
+	}
 	return "", errors.New("no ip found")
 }
+
+// selectIP returns the non-loopback ipv4 address of addrs to use according to
+// ordering, or nil if there is none. If ipv6 is set, global unicast ipv6
+// addresses are selected instead.
+func selectIP(addrs []net.Addr, ordering string, ipv6 bool) net.IP {
+	var result net.IP
+	for _, addr := range addrs {
+		var ip net.IP
+		switch v := addr.(type) {
+		case *net.IPNet:
+			ip = v.IP
+		case *net.IPAddr:
+			ip = v.IP
+		}
+		if ip == nil || ip.IsLoopback() {
+			continue
+		}
+		if ipv6 {
+			// Link-local addresses are only reachable with a zone, so cannot
+			// identify the host to peers.
+			if ip.To4() != nil || !ip.IsGlobalUnicast() {
+				continue
+			}
+		} else if ip = ip.To4(); ip == nil {
+			continue
+		}
+		if ordering == OrderFirst {
+			return ip
+		}
+		if result == nil || bytes.Compare(ip, result) < 0 {
+			result = ip
+		}
+	}
+	return result
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package netutil
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func withFakeInterfaces(ifaces ...localInterface) LocalIPOption {
+	return func(o *localIPOptions) {
+		o.getInterfaces = func() ([]localInterface, error) { return ifaces, nil }
+	}
+}
+
+func fakeInterface(name string, ips ...string) localInterface {
+	i := localInterface{name: name}
+	for _, ip := range ips {
+		i.addrs = append(i.addrs, &net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(24, 32)})
+	}
+	return i
+}
+
+func TestGetLocalIP(t *testing.T) {
+	ifaces := withFakeInterfaces(
+		fakeInterface("lo", "127.0.0.1"),
+		fakeInterface("ib0", "10.0.1.1"),
+		fakeInterface("eth0", "::1", "fd00::2", "10.0.0.9", "10.0.0.3"),
+		fakeInterface("docker0", "172.17.0.1"),
+	)
+	tests := []struct {
+		desc     string
+		opts     []LocalIPOption
+		expected string
+	}{
+		{"default", nil, "10.0.0.9"},
+		{"lowest", []LocalIPOption{WithAddrOrdering(OrderLowest)}, "10.0.0.3"},
+		{"preferred interface", []LocalIPOption{WithPreferredInterfaces("ib0", "eth0")}, "10.0.1.1"},
+		{"skips missing preferred interface", []LocalIPOption{WithPreferredInterfaces("bond0", "eth0")}, "10.0.0.9"},
+		{
+			"any interface",
+			[]LocalIPOption{WithPreferredInterfaces("bond0"), WithAnyInterface()},
+			"172.17.0.1",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ip, err := GetLocalIP(append([]LocalIPOption{ifaces}, test.opts...)...)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, ip)
+		})
+	}
+}
+
+func TestGetLocalIPLowestIsStableAcrossAddrOrder(t *testing.T) {
+	require := require.New(t)
+
+	a, err := GetLocalIP(
+		withFakeInterfaces(fakeInterface("eth0", "10.0.0.2", "10.0.0.1")), WithAddrOrdering(OrderLowest))
+	require.NoError(err)
+	b, err := GetLocalIP(
+		withFakeInterfaces(fakeInterface("eth0", "10.0.0.1", "10.0.0.2")), WithAddrOrdering(OrderLowest))
+	require.NoError(err)
+	require.Equal("10.0.0.1", a)
+	require.Equal(a, b)
+}
+
+func TestGetLocalIPIPv6Fallback(t *testing.T) {
+	tests := []struct {
+		desc     string
+		ifaces   []localInterface
+		expected string
+	}{
+		{
+			"ipv6 only",
+			[]localInterface{fakeInterface("eth0", "::1", "fe80::1", "fd00::3", "fd00::2")},
+			"fd00::3",
+		}, {
+			"ipv4 on any preferred interface wins",
+			[]localInterface{fakeInterface("eth0", "fd00::2"), fakeInterface("ib0", "10.0.1.1")},
+			"10.0.1.1",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ip, err := GetLocalIP(withFakeInterfaces(test.ifaces...), WithIPv6Fallback())
+			require.NoError(t, err)
+			require.Equal(t, test.expected, ip)
+		})
+	}
+}
+
+func TestGetLocalIPErrors(t *testing.T) {
+	tests := []struct {
+		desc string
+		opts []LocalIPOption
+	}{
+		{"no eligible interface", []LocalIPOption{withFakeInterfaces(fakeInterface("eth0", "127.0.0.1", "fd00::1"))}},
+		{"not preferred", []LocalIPOption{withFakeInterfaces(fakeInterface("docker0", "172.17.0.1"))}},
+		{"only link-local ipv6", []LocalIPOption{
+			withFakeInterfaces(fakeInterface("eth0", "fe80::1")), WithIPv6Fallback()}},
+		{"invalid ordering", []LocalIPOption{withFakeInterfaces(), WithAddrOrdering("random")}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := GetLocalIP(test.opts...)
+			require.Error(t, err)
+		})
+	}
+}
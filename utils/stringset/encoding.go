@@ -0,0 +1,98 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package stringset
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// sorted returns the elements of s in sorted order, such that encodings of s
+// are deterministic.
+func (s Set) sorted() []string {
+	xs := make([]string, 0, len(s))
+	for x := range s {
+		xs = append(xs, x)
+	}
+	sort.Strings(xs)
+	return xs
+}
+
+// MarshalBinary encodes s as a uvarint element count, followed by each element
+// in sorted order as a uvarint length and its bytes.
+func (s Set) MarshalBinary() ([]byte, error) {
+	xs := s.sorted()
+	size := binary.MaxVarintLen64
+	for _, x := range xs {
+		size += binary.MaxVarintLen64 + len(x)
+	}
+	buf := make([]byte, size)
+	n := binary.PutUvarint(buf, uint64(len(xs)))
+	for _, x := range xs {
+		n += binary.PutUvarint(buf[n:], uint64(len(x)))
+		n += copy(buf[n:], x)
+	}
+	return buf[:n], nil
+}
+
+// UnmarshalBinary decodes the output of MarshalBinary into s, replacing any
+// previous contents.
+func (s *Set) UnmarshalBinary(b []byte) error {
+	count, n := binary.Uvarint(b)
+	if n <= 0 {
+		return errors.New("invalid element count")
+	}
+	b = b[n:]
+	// Every element takes at least one byte, which bounds the allocation below
+	// for corrupt counts.
+	if count > uint64(len(b)) {
+		return fmt.Errorf("element count %d exceeds remaining %d bytes", count, len(b))
+	}
+	result := make(Set, count)
+	for i := uint64(0); i < count; i++ {
+		l, n := binary.Uvarint(b)
+		if n <= 0 {
+			return fmt.Errorf("invalid length of element %d", i)
+		}
+		b = b[n:]
+		if l > uint64(len(b)) {
+			return fmt.Errorf("element %d truncated", i)
+		}
+		result.Add(string(b[:l]))
+		b = b[l:]
+	}
+	if len(b) > 0 {
+		return fmt.Errorf("%d trailing bytes", len(b))
+	}
+	*s = result
+	return nil
+}
+
+// MarshalJSON encodes s as a sorted JSON array.
+func (s Set) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.sorted())
+}
+
+// UnmarshalJSON decodes a JSON array into s, replacing any previous contents.
+func (s *Set) UnmarshalJSON(b []byte) error {
+	var xs []string
+	if err := json.Unmarshal(b, &xs); err != nil {
+		return err
+	}
+	*s = FromSlice(xs)
+	return nil
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package stringset
+
+// FrozenSet is a read-only view of a Set. Since nothing can mutate it, a
+// FrozenSet may be shared freely across goroutines without locking or copying.
+// The zero value is an empty set.
+type FrozenSet struct {
+	s Set
+}
+
+// Freeze returns a FrozenSet of the elements of s. Later changes to s are not
+// reflected in the result.
+func (s Set) Freeze() FrozenSet {
+	return FrozenSet{s.Copy()}
+}
+
+// Has returns true if x is in f.
+func (f FrozenSet) Has(x string) bool {
+	return f.s.Has(x)
+}
+
+// Len returns the number of elements in f.
+func (f FrozenSet) Len() int {
+	return len(f.s)
+}
+
+// Each calls fn on each element of f in arbitrary order, stopping early if fn
+// returns false. Returns false if iteration was stopped early.
+func (f FrozenSet) Each(fn func(string) bool) bool {
+	return f.s.Each(fn)
+}
+
+// ToSlice converts f to a slice.
+func (f FrozenSet) ToSlice() []string {
+	return f.s.ToSlice()
+}
+
+// Thaw returns a mutable copy of f.
+func (f FrozenSet) Thaw() Set {
+	return f.s.Copy()
+}
+
+// MarshalJSON encodes f like Set.MarshalJSON.
+func (f FrozenSet) MarshalJSON() ([]byte, error) {
+	return f.s.MarshalJSON()
+}
@@ -112,3 +112,61 @@ func (s Set) Sample(n int) Set {
 	}
 	return s
 }
+
+// Filter returns a new set containing the elements of s for which pred
+// returns true.
+func (s Set) Filter(pred func(string) bool) Set {
+	result := make(Set)
+	for x := range s {
+		if pred(x) {
+			result.Add(x)
+		}
+	}
+	return result
+}
+
+// Map returns a new set containing the result of applying fn to each element
+// of s. Elements which fn maps to the same value are collapsed into one.
+func (s Set) Map(fn func(string) string) Set {
+	result := make(Set, len(s))
+	for x := range s {
+		result.Add(fn(x))
+	}
+	return result
+}
+
+// Each calls fn on each element of s in arbitrary order, stopping early if fn
+// returns false. Returns false if iteration was stopped early.
+func (s Set) Each(fn func(string) bool) bool {
+	for x := range s {
+		if !fn(x) {
+			return false
+		}
+	}
+	return true
+}
+
+// Pop removes and returns an arbitrary element of s. Returns false if s is
+// empty. Note that Pop mutates s.
+func (s Set) Pop() (string, bool) {
+	for x := range s {
+		s.Remove(x)
+		return x, true
+	}
+	return "", false
+}
+
+// PopN removes and returns up to n arbitrary elements of s. If there are <= n
+// elements in s, all of them are returned and s is left empty. Note that PopN
+// mutates s.
+func (s Set) PopN(n int) []string {
+	var xs []string
+	for x := range s {
+		if len(xs) >= n {
+			break
+		}
+		s.Remove(x)
+		xs = append(xs, x)
+	}
+	return xs
+}
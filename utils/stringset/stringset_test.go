@@ -0,0 +1,185 @@
+// Copyright (c) 2016-2019 Uber Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package stringset
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter(t *testing.T) {
+	s := New("a:80", "b:80", "c:90")
+
+	result := s.Filter(func(x string) bool { return strings.HasSuffix(x, ":80") })
+
+	require.Equal(t, New("a:80", "b:80"), result)
+	require.Equal(t, New("a:80", "b:80", "c:90"), s)
+}
+
+func TestMap(t *testing.T) {
+	s := New("a:80", "b:80")
+
+	result := s.Map(func(x string) string { return strings.Replace(x, ":80", ":90", 1) })
+
+	require.Equal(t, New("a:90", "b:90"), result)
+	require.Equal(t, New("a:80", "b:80"), s)
+}
+
+func TestMapCollapsesCollisions(t *testing.T) {
+	s := New("a:80", "a:90", "b:80")
+
+	result := s.Map(func(x string) string { return strings.Split(x, ":")[0] })
+
+	require.Equal(t, New("a", "b"), result)
+}
+
+func TestEach(t *testing.T) {
+	require := require.New(t)
+
+	s := New("a", "b", "c")
+
+	var visited []string
+	require.True(s.Each(func(x string) bool {
+		visited = append(visited, x)
+		return true
+	}))
+	require.ElementsMatch([]string{"a", "b", "c"}, visited)
+
+	var n int
+	require.False(s.Each(func(x string) bool {
+		n++
+		return n < 2
+	}))
+	require.Equal(2, n)
+}
+
+func TestPop(t *testing.T) {
+	require := require.New(t)
+
+	s := New("a", "b")
+
+	x, ok := s.Pop()
+	require.True(ok)
+	y, ok := s.Pop()
+	require.True(ok)
+	require.ElementsMatch([]string{"a", "b"}, []string{x, y})
+	require.Empty(s)
+
+	_, ok = s.Pop()
+	require.False(ok)
+}
+
+func TestPopN(t *testing.T) {
+	require := require.New(t)
+
+	s := New("a", "b", "c")
+
+	xs := s.PopN(2)
+	require.Len(xs, 2)
+	require.Len(s, 1)
+	for _, x := range xs {
+		require.False(s.Has(x))
+	}
+
+	require.Len(s.PopN(5), 1)
+	require.Empty(s)
+	require.Empty(s.PopN(1))
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	for _, s := range []Set{New(), New("a:80"), New("c:80", "a:80", "b:80", "")} {
+		b, err := s.MarshalBinary()
+		require.NoError(t, err)
+
+		var result Set
+		require.NoError(t, result.UnmarshalBinary(b))
+		require.Equal(t, s, result)
+	}
+}
+
+func TestBinaryIsDeterministic(t *testing.T) {
+	require := require.New(t)
+
+	b1, err := New("a", "b", "c").MarshalBinary()
+	require.NoError(err)
+	b2, err := New("c", "b", "a").MarshalBinary()
+	require.NoError(err)
+	require.Equal(b1, b2)
+	require.Equal([]byte{3, 1, 'a', 1, 'b', 1, 'c'}, b1)
+}
+
+func TestUnmarshalBinaryErrors(t *testing.T) {
+	tests := []struct {
+		desc string
+		b    []byte
+	}{
+		{"empty", nil},
+		{"count too large", []byte{5, 1, 'a'}},
+		{"truncated element", []byte{1, 3, 'a'}},
+		{"trailing bytes", []byte{1, 1, 'a', 'b'}},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var s Set
+			require.Error(t, s.UnmarshalBinary(test.b))
+		})
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	require := require.New(t)
+
+	s := New("b:80", "a:80")
+	b, err := json.Marshal(s)
+	require.NoError(err)
+	require.Equal(`["a:80","b:80"]`, string(b))
+
+	var result Set
+	require.NoError(json.Unmarshal(b, &result))
+	require.Equal(s, result)
+}
+
+func TestFreeze(t *testing.T) {
+	require := require.New(t)
+
+	s := New("a", "b")
+	f := s.Freeze()
+
+	// Later changes to s are not reflected.
+	s.Add("c")
+	require.Equal(2, f.Len())
+	require.True(f.Has("a"))
+	require.False(f.Has("c"))
+	require.ElementsMatch([]string{"a", "b"}, f.ToSlice())
+
+	// Nor are changes to thawed copies.
+	thawed := f.Thaw()
+	thawed.Add("d")
+	require.False(f.Has("d"))
+
+	b, err := json.Marshal(f)
+	require.NoError(err)
+	require.Equal(`["a","b"]`, string(b))
+}
+
+func TestFrozenSetZeroValue(t *testing.T) {
+	var f FrozenSet
+	require.Equal(t, 0, f.Len())
+	require.False(t, f.Has("a"))
+	require.True(t, f.Each(func(string) bool { return false }))
+	require.Equal(t, New(), f.Thaw())
+}